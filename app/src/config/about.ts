@@ -209,6 +209,11 @@ ${checkUpdateHTML}
             about.element.querySelector("#isInsider").innerHTML = "<span class='ft__secondary'>Insider Preview</span>";
         }
         const tokenElement = about.element.querySelector("#token") as HTMLInputElement;
+        fetchPost("/api/system/getAPIToken", {}, (response) => {
+            window.siyuan.config.api.token = response.data.token;
+            tokenElement.value = response.data.token;
+            about.element.querySelector("#tokenTip").innerHTML = window.siyuan.languages.about14.replace("${token}", window.siyuan.config.api.token);
+        });
         tokenElement.addEventListener("click", () => {
             tokenElement.select();
         });
@@ -52,6 +52,18 @@ export const fileTree = {
     <span class="fn__space"></span>
     <input class="b3-switch fn__flex-center" id="useSingleLineSave" type="checkbox"${window.siyuan.config.fileTree.useSingleLineSave ? " checked" : ""}/>
 </label>
+<div class="fn__flex b3-label config__item">
+    <div class="fn__flex-1">
+        ${window.siyuan.languages.fileTree22}
+        <div class="b3-label__text">${window.siyuan.languages.fileTree23}</div>
+    </div>
+    <span class="fn__space"></span>
+    <select class="b3-select fn__flex-center fn__size200" id="syCompression">
+        <option value="" ${window.siyuan.config.fileTree.syCompression === "" ? "selected" : ""}>${window.siyuan.languages.disable}</option>
+        <option value="gzip" ${window.siyuan.config.fileTree.syCompression === "gzip" ? "selected" : ""}>gzip</option>
+        <option value="zstd" ${window.siyuan.config.fileTree.syCompression === "zstd" ? "selected" : ""}>zstd</option>
+    </select>
+</div>
 <div class="fn__flex b3-label config__item">
     <div class="fn__flex-1">
         ${window.siyuan.languages.fileTree16}
@@ -113,6 +125,7 @@ export const fileTree = {
             allowCreateDeeper: (fileTree.element.querySelector("#allowCreateDeeper") as HTMLInputElement).checked,
             removeDocWithoutConfirm: (fileTree.element.querySelector("#removeDocWithoutConfirm") as HTMLInputElement).checked,
             useSingleLineSave: (fileTree.element.querySelector("#useSingleLineSave") as HTMLInputElement).checked,
+            syCompression: (fileTree.element.querySelector("#syCompression") as HTMLSelectElement).value,
             maxListCount: parseInt((fileTree.element.querySelector("#maxListCount") as HTMLInputElement).value),
             maxOpenTabCount: inputMaxOpenTabCount,
         }, response => {
@@ -70,6 +70,7 @@ export abstract class Constants {
     public static readonly SIZE_LINK_TEXT_MAX: number = 64;
     public static readonly SIZE_TOOLBAR_HEIGHT: number = isMobile() ? 0 : 32;
     public static readonly SIZE_GET_MAX = 102400;
+    public static readonly SIZE_PASTE_STREAM = 1024 * 1024;
     public static readonly SIZE_UNDO = 64;
     public static readonly SIZE_TITLE = 512;
     public static readonly SIZE_EDITOR_WIDTH = 760;
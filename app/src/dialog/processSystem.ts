@@ -2,6 +2,9 @@ import {Constants} from "../constants";
 import {fetchPost} from "../util/fetch";
 /// #if !MOBILE
 import {exportLayout} from "../layout/util";
+import {openFileById} from "../editor/util";
+/// #else
+import {openMobileFileById} from "../mobile/editor";
 /// #endif
 /// #if !BROWSER
 import {ipcRenderer} from "electron";
@@ -284,6 +287,34 @@ export const transactionError = () => {
 };
 
 let statusTimeout: number;
+export const remindBlock = (app: App, data: {
+    reminder: { id: string, content: string },
+    systemNotification: boolean
+}) => {
+    const openBlock = () => {
+        /// #if MOBILE
+        openMobileFileById(app, data.reminder.id, [Constants.CB_GET_HL]);
+        /// #else
+        openFileById({app, id: data.reminder.id, action: [Constants.CB_GET_FOCUS, Constants.CB_GET_HL]});
+        /// #endif
+    };
+    const messageId = "reminder-" + data.reminder.id;
+    showMessage(`${window.siyuan.languages.reminder} ${escapeHtml(data.reminder.content || data.reminder.id)}`, 0, "info", messageId);
+    document.querySelector(`#message .b3-snackbar[data-id="${messageId}"] .b3-snackbar__content`)?.addEventListener("click", () => {
+        hideMessage(messageId);
+        openBlock();
+    });
+    /// #if !BROWSER
+    if (data.systemNotification && typeof Notification !== "undefined") {
+        const notification = new Notification(window.siyuan.languages.reminder, {body: data.reminder.content});
+        notification.onclick = () => {
+            ipcRenderer.send(Constants.SIYUAN_CMD, "show");
+            openBlock();
+        };
+    }
+    /// #endif
+};
+
 export const progressStatus = (data: IWebSocketData) => {
     const statusElement = document.querySelector("#status") as HTMLElement;
     if (!statusElement) {
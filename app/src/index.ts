@@ -18,6 +18,7 @@ import {
     progressLoading,
     progressStatus,
     reloadSync,
+    remindBlock,
     setTitle,
     transactionError
 } from "./dialog/processSystem";
@@ -130,6 +131,9 @@ export class App {
                             case "txerr":
                                 transactionError();
                                 break;
+                            case "reminder":
+                                remindBlock(this, data.data);
+                                break;
                             case "syncing":
                                 processSync(data, this.plugins);
                                 break;
@@ -37,7 +37,7 @@ export class Model {
         msgCallback?: (data: IWebSocketData) => void
     }) {
         const websocketURL = `${window.location.protocol === "https:" ? "wss" : "ws"}://${window.location.host}/ws`;
-        const ws = new WebSocket(`${websocketURL}?app=${Constants.SIYUAN_APPID}&id=${options.id}${options.type ? "&type=" + options.type : ""}`);
+        const ws = new WebSocket(`${websocketURL}?app=${Constants.SIYUAN_APPID}&id=${options.id}${options.type ? "&type=" + options.type : ""}&batch=1`);
         ws.onopen = () => {
             if (options.callback) {
                 options.callback.call(this);
@@ -56,8 +56,12 @@ export class Model {
         };
         ws.onmessage = (event) => {
             if (options.msgCallback) {
-                const data = processMessage(JSON.parse(event.data));
-                options.msgCallback.call(this, data);
+                // 内核会将短时间内的多条推送合并为数组发送
+                const messages = JSON.parse(event.data);
+                (Array.isArray(messages) ? messages : [messages]).forEach((item: IWebSocketData) => {
+                    const data = processMessage(item);
+                    options.msgCallback.call(this, data);
+                });
             }
         };
         ws.onclose = (ev) => {
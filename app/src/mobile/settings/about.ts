@@ -364,6 +364,11 @@ export const initAbout = () => {
                 });
             });
             const tokenElement = modelMainElement.querySelector("#token") as HTMLInputElement;
+            fetchPost("/api/system/getAPIToken", {}, (response) => {
+                window.siyuan.config.api.token = response.data.token;
+                tokenElement.value = response.data.token;
+                modelMainElement.querySelector("#tokenTip").innerHTML = window.siyuan.languages.about14.replace("${token}", window.siyuan.config.api.token);
+            });
             tokenElement.addEventListener("change", () => {
                 fetchPost("/api/system/setAPIToken", {token: tokenElement.value}, () => {
                     window.siyuan.config.api.token = tokenElement.value;
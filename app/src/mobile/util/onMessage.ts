@@ -1,5 +1,12 @@
 import {openMobileFileById} from "../editor";
-import {processSync, progressLoading, progressStatus, reloadSync, transactionError} from "../../dialog/processSystem";
+import {
+    processSync,
+    progressLoading,
+    progressStatus,
+    reloadSync,
+    remindBlock,
+    transactionError
+} from "../../dialog/processSystem";
 import {Constants} from "../../constants";
 import {App} from "../../index";
 import {reloadPlugin} from "../../plugin/loader";
@@ -34,6 +41,9 @@ export const onMessage = (app: App, data: IWebSocketData) => {
             case"txerr":
                 transactionError();
                 break;
+            case "reminder":
+                remindBlock(app, data.data);
+                break;
             case"statusbar":
                 progressStatus(data);
                 break;
@@ -409,6 +409,16 @@ export const paste = async (protyle: IProtyle, event: (ClipboardEvent | DragEven
                     e.remove();
                 }
             });
+            const pasteBlockElement = hasClosestBlock(range.startContainer);
+            if (pasteBlockElement && tempElement.innerHTML.length > Constants.SIZE_PASTE_STREAM) {
+                // 超大 HTML 交由内核分块解析写入，结果通过 transactions 推送
+                fetchPost("/api/import/importStream", {
+                    dataType: "html",
+                    data: tempElement.innerHTML,
+                    previousID: pasteBlockElement.getAttribute("data-node-id")
+                });
+                return;
+            }
             fetchPost("/api/lute/html2BlockDOM", {
                 dom: tempElement.innerHTML
             }, (response) => {
@@ -601,6 +601,12 @@ declare namespace Config {
          * Whether to save the content of the .sy file as a single-line JSON object
          */
         useSingleLineSave: boolean;
+        /**
+         * Compression algorithm of .sy files, empty means uncompressed
+         * - `gzip`
+         * - `zstd`
+         */
+        syCompression: "" | "gzip" | "zstd";
     }
 
     /**
@@ -21,6 +21,7 @@ import (
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -55,3 +56,239 @@ func chatGPTWithAction(c *gin.Context) {
 	action := arg["action"].(string)
 	ret.Data = model.ChatGPTWithAction(ids, action)
 }
+
+func chatStream(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	msg, _ := arg["msg"].(string)
+	var ids []string
+	if idsArg, ok := arg["ids"].([]interface{}); ok {
+		for _, id := range idsArg {
+			ids = append(ids, id.(string))
+		}
+	}
+	action, _ := arg["action"].(string)
+	targetID, _ := arg["targetID"].(string)
+	if "" != targetID {
+		if util.InvalidIDPattern(targetID, ret) {
+			return
+		}
+		if util.ReadOnly {
+			ret.Code = -1
+			ret.Msg = model.Conf.Language(34)
+			return
+		}
+	}
+
+	id, err := model.StartAIStream(msg, ids, action, targetID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{"id": id}
+}
+
+func cancelStream(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	ret.Data = model.CancelAIStream(id)
+}
+
+func getRelatedBlocks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	threshold := model.Conf.AI.RelatedBlocksThreshold
+	if thresholdArg, ok := arg["threshold"].(float64); ok {
+		threshold = thresholdArg
+	}
+	limit := 10
+	if limitArg, ok := arg["limit"].(float64); ok {
+		limit = int(limitArg)
+	}
+	ret.Data = model.GetRelatedBlocks(id, threshold, limit)
+}
+
+func getAISuggestions(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetAISuggestions()
+}
+
+func acceptAISuggestion(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var tags []string
+	if tagsArg, ok := arg["tags"].([]interface{}); ok {
+		tags = []string{}
+		for _, tag := range tagsArg {
+			tags = append(tags, tag.(string))
+		}
+	}
+	var attrs map[string]string
+	if attrsArg, ok := arg["attrs"].(map[string]interface{}); ok {
+		attrs = map[string]string{}
+		for name, value := range attrsArg {
+			attrs[name], _ = value.(string)
+		}
+	}
+
+	if err := model.AcceptAISuggestion(id, tags, attrs); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
+
+func rejectAISuggestion(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	model.RejectAISuggestion(id)
+}
+
+func getAIActions(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetAIActions()
+}
+
+func setAIAction(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	action := &conf.AIAction{}
+	if err = gulu.JSON.UnmarshalJSON(data, action); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	action, err = model.SetAIAction(action)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = action
+}
+
+func removeAIAction(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	model.RemoveAIAction(id)
+}
+
+func runAIAction(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	idsArg := arg["ids"].([]interface{})
+	var ids []string
+	for _, blockID := range idsArg {
+		ids = append(ids, blockID.(string))
+	}
+
+	result, err := model.RunAIAction(id, ids)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
+
+func summarizeSubtree(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	targetID := ""
+	if targetIDArg := arg["targetID"]; nil != targetIDArg {
+		targetID = targetIDArg.(string)
+	}
+	if "" != targetID && util.ReadOnly {
+		ret.Code = -1
+		ret.Msg = model.Conf.Language(34)
+		return
+	}
+	chunkSize := 0
+	if chunkSizeArg := arg["chunkSize"]; nil != chunkSizeArg {
+		chunkSize = int(chunkSizeArg.(float64))
+	}
+	includeFolded := false
+	if includeFoldedArg := arg["includeFolded"]; nil != includeFoldedArg {
+		includeFolded = includeFoldedArg.(bool)
+	}
+
+	result, err := model.SummarizeSubtree(id, targetID, chunkSize, includeFolded)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
@@ -112,8 +112,16 @@ func getImageOCRText(c *gin.Context) {
 		force = forceArg.(bool)
 	}
 
+	var langs []string
+	if langsArg := arg["langs"]; nil != langsArg {
+		for _, lang := range langsArg.([]interface{}) {
+			langs = append(langs, lang.(string))
+		}
+	}
+
 	ret.Data = map[string]interface{}{
-		"text": util.GetAssetText(path, force),
+		"text":     util.GetAssetText(path, force, langs),
+		"provider": util.GetOCRProviderName(),
 	}
 }
 
@@ -131,6 +139,68 @@ func setImageOCRText(c *gin.Context) {
 	util.SetAssetText(path, text)
 }
 
+func getOCRStatus(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var path, status string
+	if pathArg := arg["path"]; nil != pathArg {
+		path = pathArg.(string)
+	}
+	if statusArg := arg["status"]; nil != statusArg {
+		status = statusArg.(string)
+	}
+	ret.Data = model.GetOCRQueueStatus(path, status)
+}
+
+func reOCRAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var langs []string
+	if langsArg := arg["langs"]; nil != langsArg {
+		for _, lang := range langsArg.([]interface{}) {
+			langs = append(langs, lang.(string))
+		}
+	}
+
+	all := false
+	if allArg := arg["all"]; nil != allArg {
+		all = allArg.(bool)
+	}
+
+	var count int
+	var err error
+	if all {
+		count, err = model.ReOCRAllAssets(langs)
+	} else {
+		var paths []string
+		if pathsArg := arg["paths"]; nil != pathsArg {
+			for _, p := range pathsArg.([]interface{}) {
+				paths = append(paths, p.(string))
+			}
+		}
+		count, err = model.ReOCRAssets(paths, langs)
+	}
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = map[string]interface{}{"count": count}
+}
+
 func renameAsset(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -151,6 +221,27 @@ func renameAsset(c *gin.Context) {
 	}
 }
 
+func renameAssetWithRefs(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	oldPath := arg["oldPath"].(string)
+	newName := arg["newName"].(string)
+	result, err := model.RenameAssetWithRefs(oldPath, newName)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = result
+}
+
 func getDocImageAssets(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -278,6 +369,131 @@ func getUnusedAssets(c *gin.Context) {
 	}
 }
 
+func getUnusedAssetsImpact(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetUnusedAssetsImpact()
+}
+
+func getQuarantinedAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"assets": model.ListQuarantinedAssets(),
+	}
+}
+
+func restoreQuarantinedAsset(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	batch := arg["batch"].(string)
+	p := arg["path"].(string)
+	if err := model.RestoreQuarantinedAsset(batch, p); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func purgeQuarantinedAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	model.PurgeQuarantinedAssets(true)
+}
+
+func getOffloadedAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"assets": model.ListOffloadedAssets(),
+	}
+}
+
+func offloadAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	count, err := model.OffloadAssets()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"count": count, "closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"count": count,
+	}
+}
+
+func restoreOffloadedAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	count, err := model.RestoreOffloadedAssets()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"count": count, "closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"count": count,
+	}
+}
+
+func getAssetUsage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	p := arg["path"].(string)
+	ret.Data = model.GetAssetUsage(p)
+}
+
+func getLargestAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	limit := 64
+	if limitArg := arg["limit"]; nil != limitArg {
+		limit = int(limitArg.(float64))
+	}
+	ret.Data = map[string]interface{}{
+		"assets": model.GetLargestAssets(limit),
+	}
+}
+
+func getAssetConversions(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"conversions": model.GetAssetConversions(),
+	}
+}
+
 func getMissingAssets(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/88250/gulu"
@@ -27,6 +28,125 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+// checkAttributeViewAccess 检查调用方的角色是否可以写入属性视图中指定视图的指定列，不可写入时设置返回结果。
+func checkAttributeViewAccess(c *gin.Context, ret *gulu.Result, avID, viewID, keyID string) bool {
+	if err := model.CheckAttributeViewAccess(avID, viewID, keyID, model.GetRole(c)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return false
+	}
+	return true
+}
+
+func bulkUpdateAttributeViewCells(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	var viewID, formula string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	if formulaArg := arg["formula"]; nil != formulaArg {
+		formula = formulaArg.(string)
+	}
+	value := arg["value"]
+	dryRun := false
+	if dryRunArg := arg["dryRun"]; nil != dryRunArg {
+		dryRun = dryRunArg.(bool)
+	}
+
+	if !checkAttributeViewAccess(c, ret, avID, viewID, keyID) {
+		return
+	}
+
+	rowIDs, err := model.BulkUpdateAttributeViewCells(avID, viewID, keyID, value, formula, dryRun)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		var validationErr *av.ValidationError
+		if errors.As(err, &validationErr) {
+			ret.Data = validationErr
+		}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"count":  len(rowIDs),
+		"rowIDs": rowIDs,
+		"dryRun": dryRun,
+	}
+
+	if !dryRun && 0 < len(rowIDs) {
+		util.PushReloadAttrView(avID)
+	}
+}
+
+func syncAttributeViewDataSource(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewRowsAccess(avID, model.GetRole(c)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err := model.SyncAttributeViewDataSource(avID); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func mergeAttributeViews(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	srcAvID := arg["srcAvID"].(string)
+	destAvID := arg["destAvID"].(string)
+	keyMapping := map[string]string{}
+	if keyMappingArg := arg["keyMapping"]; nil != keyMappingArg {
+		for srcKeyID, destKeyID := range keyMappingArg.(map[string]interface{}) {
+			keyMapping[srcKeyID] = destKeyID.(string)
+		}
+	}
+	var conflict string
+	if conflictArg := arg["conflict"]; nil != conflictArg {
+		conflict = conflictArg.(string)
+	}
+
+	if err := model.CheckAttributeViewRowsAccess(destAvID, model.GetRole(c)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	result, err := model.MergeAttributeViews(srcAvID, destAvID, keyMapping, model.MergeConflict(conflict))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
+
 func duplicateAttributeViewBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -36,8 +156,12 @@ func duplicateAttributeViewBlock(c *gin.Context) {
 		return
 	}
 	avID := arg["avID"].(string)
+	withData := true
+	if withDataArg := arg["withData"]; nil != withDataArg {
+		withData = withDataArg.(bool)
+	}
 
-	newAvID, newBlockID, err := model.DuplicateDatabaseBlock(avID)
+	newAvID, newBlockID, err := model.DuplicateDatabaseBlock(avID, withData)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -59,7 +183,14 @@ func getAttributeViewKeysByAvID(c *gin.Context) {
 		return
 	}
 	avID := arg["avID"].(string)
-	ret.Data = model.GetAttributeViewKeysByAvID(avID)
+	role := model.GetRole(c)
+	keys := []*av.Key{}
+	for _, key := range model.GetAttributeViewKeysByAvID(avID) {
+		if !key.Access.IsHidden(role) {
+			keys = append(keys, key)
+		}
+	}
+	ret.Data = keys
 }
 
 func getMirrorDatabaseBlocks(c *gin.Context) {
@@ -188,6 +319,12 @@ func removeAttributeViewValues(c *gin.Context) {
 		srcIDs = append(srcIDs, v.(string))
 	}
 
+	if err := model.CheckAttributeViewRowsAccess(avID, model.GetRole(c)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	err := model.RemoveAttributeViewBlock(srcIDs, avID)
 	if nil != err {
 		ret.Code = -1
@@ -235,6 +372,9 @@ func removeAttributeViewKey(c *gin.Context) {
 
 	avID := arg["avID"].(string)
 	keyID := arg["keyID"].(string)
+	if !checkAttributeViewAccess(c, ret, avID, "", keyID) {
+		return
+	}
 
 	err := model.RemoveAttributeViewKey(avID, keyID)
 	if nil != err {
@@ -262,6 +402,9 @@ func sortAttributeViewViewKey(c *gin.Context) {
 	}
 	keyID := arg["keyID"].(string)
 	previousKeyID := arg["previousKeyID"].(string)
+	if !checkAttributeViewAccess(c, ret, avID, viewID, "") {
+		return
+	}
 
 	err := model.SortAttributeViewViewKey(avID, viewID, keyID, previousKeyID)
 	if nil != err {
@@ -361,8 +504,12 @@ func getAttributeView(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	attrView := model.GetAttributeView(id)
+	if nil != attrView {
+		attrView.RemoveHidden(model.GetRole(c))
+	}
 	ret.Data = map[string]interface{}{
-		"av": model.GetAttributeView(id),
+		"av": attrView,
 	}
 }
 
@@ -512,8 +659,22 @@ func renderAttributeView(c *gin.Context) {
 		return
 	}
 
+	role := model.GetRole(c)
+	if attrView.IsViewHidden(view.GetID(), role) {
+		ret.Code = -1
+		ret.Msg = av.ErrAccessDenied.Error()
+		return
+	}
+	if table, ok := view.(*av.Table); ok {
+		table.RemoveHiddenColumns(attrView, role)
+	}
+
 	var views []map[string]interface{}
 	for _, v := range attrView.Views {
+		if v.Access.IsHidden(role) {
+			continue
+		}
+
 		view := map[string]interface{}{
 			"id":               v.ID,
 			"icon":             v.Icon,
@@ -547,6 +708,17 @@ func getAttributeViewKeys(c *gin.Context) {
 
 	id := arg["id"].(string)
 	blockAttributeViewKeys := model.GetBlockAttributeViewKeys(id)
+	if role := model.GetRole(c); "" != role {
+		for _, blockAttributeViewKey := range blockAttributeViewKeys {
+			var keyValues []*av.KeyValues
+			for _, kv := range blockAttributeViewKey.KeyValues {
+				if !kv.Key.Access.IsHidden(role) {
+					keyValues = append(keyValues, kv)
+				}
+			}
+			blockAttributeViewKey.KeyValues = keyValues
+		}
+	}
 	ret.Data = blockAttributeViewKeys
 }
 
@@ -564,8 +736,151 @@ func setAttributeViewBlockAttr(c *gin.Context) {
 	rowID := arg["rowID"].(string)
 	cellID := arg["cellID"].(string)
 	value := arg["value"].(interface{})
-	blockAttributeViewKeys := model.UpdateAttributeViewCell(nil, avID, keyID, rowID, cellID, value)
-	ret.Data = blockAttributeViewKeys
+	if !checkAttributeViewAccess(c, ret, avID, "", keyID) {
+		return
+	}
+
+	if err := model.UpdateAttributeViewCell(nil, avID, keyID, rowID, cellID, value); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		var validationErr *av.ValidationError
+		if errors.As(err, &validationErr) {
+			ret.Data = validationErr
+		}
+		return
+	}
 
 	util.PushReloadAttrView(avID)
 }
+
+func renderAttributeViewGallery(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var viewID, query string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	if queryArg := arg["query"]; nil != queryArg {
+		query = queryArg.(string)
+	}
+
+	page := 1
+	if pageArg := arg["page"]; nil != pageArg {
+		page = int(pageArg.(float64))
+	}
+
+	pageSize := -1
+	if pageSizeArg := arg["pageSize"]; nil != pageSizeArg {
+		pageSize = int(pageSizeArg.(float64))
+	}
+
+	gallery, err := model.RenderAttributeViewGallery(id, viewID, query, page, pageSize, model.GetRole(c))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = gallery
+}
+
+func renderAttributeViewCalendar(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	keyID := arg["keyID"].(string)
+	var viewID, endKeyID string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	if endKeyIDArg := arg["endKeyID"]; nil != endKeyIDArg {
+		endKeyID = endKeyIDArg.(string)
+	}
+
+	rangeType := av.CalendarRangeMonth
+	if rangeArg := arg["range"]; nil != rangeArg {
+		rangeType = av.CalendarRange(rangeArg.(string))
+	}
+
+	var anchor int64
+	if anchorArg := arg["date"]; nil != anchorArg {
+		anchor = int64(anchorArg.(float64))
+	}
+
+	weekStart := 0
+	if weekStartArg := arg["weekStart"]; nil != weekStartArg {
+		weekStartNum, ok := weekStartArg.(float64)
+		if !ok || weekStartNum != float64(int(weekStartNum)) || 0 > weekStartNum || 6 < weekStartNum {
+			ret.Code = -1
+			ret.Msg = "invalid weekStart, it must be an integer between 0 (Sunday) and 6 (Saturday)"
+			return
+		}
+		weekStart = int(weekStartNum)
+	}
+
+	calendar, err := model.RenderAttributeViewCalendar(id, viewID, keyID, endKeyID, rangeType, anchor, weekStart, model.GetRole(c))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = calendar
+}
+
+func getAttributeViewRows(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var viewID, query, cursor string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	if queryArg := arg["query"]; nil != queryArg {
+		query = queryArg.(string)
+	}
+	if cursorArg := arg["cursor"]; nil != cursorArg {
+		cursor = cursorArg.(string)
+	}
+
+	page := 1
+	if pageArg := arg["page"]; nil != pageArg {
+		page = int(pageArg.(float64))
+	}
+	pageSize := -1
+	if pageSizeArg := arg["pageSize"]; nil != pageSizeArg {
+		pageSize = int(pageSizeArg.(float64))
+	}
+
+	rows, total, nextCursor, err := model.GetAttributeViewRows(id, viewID, query, cursor, page, pageSize, model.GetRole(c))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"rows":       rows,
+		"total":      total,
+		"page":       page,
+		"nextCursor": nextCursor,
+		"hasMore":    "" != nextCursor,
+	}
+}
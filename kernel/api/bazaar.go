@@ -21,6 +21,7 @@ import (
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/bazaar"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -518,3 +519,12 @@ func uninstallBazaarTheme(c *gin.Context) {
 		"appearance": model.Conf.Appearance,
 	}
 }
+
+func serveBazaarMirror(c *gin.Context) {
+	p, err := bazaar.MirrorFilePath(c.Param("path"))
+	if nil != err {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.File(p)
+}
@@ -0,0 +1,124 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getBoard(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+	var attr string
+	if nil != arg["attr"] {
+		attr = arg["attr"].(string)
+	}
+	var columns []string
+	if nil != arg["columns"] {
+		for _, column := range arg["columns"].([]interface{}) {
+			columns = append(columns, column.(string))
+		}
+	}
+
+	board, err := model.GetBoard(id, attr, columns)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = board
+}
+
+func moveBoardCard(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+	cardID := arg["cardID"].(string)
+	if util.InvalidIDPattern(cardID, ret) {
+		return
+	}
+	var attr, value, previousID string
+	if nil != arg["attr"] {
+		attr = arg["attr"].(string)
+	}
+	if nil != arg["value"] {
+		value = arg["value"].(string)
+	}
+	if nil != arg["previousID"] {
+		previousID = arg["previousID"].(string)
+	}
+	if "" != previousID && util.InvalidIDPattern(previousID, ret) {
+		return
+	}
+
+	board, err := model.MoveBoardCard(id, attr, cardID, value, previousID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = board
+}
+
+func reorderBoardCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+	var ids []string
+	for _, cardID := range arg["ids"].([]interface{}) {
+		ids = append(ids, cardID.(string))
+	}
+
+	if err := model.ReorderBoardCards(id, ids); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
@@ -0,0 +1,149 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func searchCitations(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var keyword string
+	if nil != arg["k"] {
+		keyword = arg["k"].(string)
+	}
+	items, err := model.SearchCitations(keyword)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = items
+}
+
+func getCitationItems(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var keys []string
+	for _, key := range arg["keys"].([]interface{}) {
+		keys = append(keys, key.(string))
+	}
+	items, missing, err := model.GetCitationItems(keys)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if nil == missing {
+		missing = []string{}
+	}
+	ret.Data = map[string]interface{}{
+		"items":   items,
+		"missing": missing,
+	}
+}
+
+func insertCitation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var keys []string
+	for _, key := range arg["keys"].([]interface{}) {
+		keys = append(keys, key.(string))
+	}
+	var parentID, previousID string
+	if nil != arg["parentID"] {
+		parentID = arg["parentID"].(string)
+	}
+	if nil != arg["previousID"] {
+		previousID = arg["previousID"].(string)
+	}
+	if "" == parentID && "" == previousID {
+		ret.Code = -1
+		ret.Msg = "parentID or previousID is required"
+		return
+	}
+	if "" != parentID && util.InvalidIDPattern(parentID, ret) {
+		return
+	}
+	if "" != previousID && util.InvalidIDPattern(previousID, ret) {
+		return
+	}
+
+	id, err := model.InsertCitation(keys, parentID, previousID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"id": id,
+	}
+}
+
+func getBibliography(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	entries, missing, err := model.GetBibliography(id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if nil == missing {
+		missing = []string{}
+	}
+	ret.Data = map[string]interface{}{
+		"entries": entries,
+		"missing": missing,
+	}
+}
@@ -0,0 +1,82 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func setCollabPresence(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	presence := &model.CollabPresence{
+		App:     arg["app"].(string),
+		Session: arg["session"].(string),
+		RootID:  arg["rootID"].(string),
+	}
+	presence.BlockID, _ = arg["blockID"].(string)
+	presence.User, _ = arg["user"].(string)
+	if oidcSession := model.GetOIDCSession(c); nil != oidcSession {
+		presence.User = oidcSession.OIDCName
+	}
+
+	rev, presences := model.SetCollabPresence(presence)
+	ret.Data = map[string]interface{}{
+		"rev":       rev,
+		"presences": presences,
+	}
+}
+
+func removeCollabPresence(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	session := arg["session"].(string)
+	model.RemoveCollabPresence(session)
+}
+
+func getCollabPresences(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["rootID"].(string)
+	ret.Data = map[string]interface{}{
+		"rev":       model.GetCollabDocRev(rootID),
+		"presences": model.GetCollabPresences(rootID),
+	}
+}
@@ -641,3 +641,34 @@ func exportAsFile(c *gin.Context) {
 		"file": path.Join("/export/", name),
 	}
 }
+
+func renderDiagram(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	lang := arg["lang"].(string)
+	code := arg["code"].(string)
+	format := model.Conf.Export.DiagramFormat
+	if nil != arg["format"] {
+		format = arg["format"].(string)
+	}
+	if "png" != format {
+		format = "svg"
+	}
+
+	data, err := model.RenderDiagram(lang, code, format)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"format":  format,
+		"dataURI": model.DiagramDataURI(data, format),
+	}
+}
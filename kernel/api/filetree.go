@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/88250/gulu"
@@ -587,7 +588,16 @@ func createDailyNote(c *gin.Context) {
 	}
 
 	notebook := arg["notebook"].(string)
-	p, existed, err := model.CreateDailyNote(notebook)
+	date := time.Now()
+	if nil != arg["date"] {
+		var parseErr error
+		if date, parseErr = model.ParseDailyNoteDate(arg["date"].(string)); nil != parseErr {
+			ret.Code = -1
+			ret.Msg = parseErr.Error()
+			return
+		}
+	}
+	p, existed, err := model.CreateDailyNoteByDate(notebook, date)
 	if nil != err {
 		if model.ErrBoxNotFound == err {
 			ret.Code = 1
@@ -636,6 +646,91 @@ func createDailyNote(c *gin.Context) {
 	}
 }
 
+func getDailyNote(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	var dateArg string
+	if nil != arg["date"] {
+		dateArg = arg["date"].(string)
+	}
+	date, err := model.ParseDailyNoteDate(dateArg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	create := false
+	if nil != arg["create"] {
+		create = arg["create"].(bool)
+	}
+	if create && util.ReadOnly {
+		ret.Code = -1
+		ret.Msg = model.Conf.Language(34)
+		return
+	}
+
+	var note *model.DailyNote
+	if create {
+		note, err = model.GetOrCreateDailyNote(notebook, date)
+	} else {
+		note, err = model.GetDailyNote(notebook, date)
+	}
+	if nil != err {
+		if model.ErrBoxNotFound == err {
+			ret.Code = 1
+		} else {
+			ret.Code = -1
+		}
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = note
+}
+
+func backfillDailyNotes(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	from, err := model.ParseDailyNoteDate(arg["from"].(string))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	to, err := model.ParseDailyNoteDate(arg["to"].(string))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	notes, err := model.BackfillDailyNotes(notebook, from, to)
+	if nil != err {
+		if model.ErrBoxNotFound == err {
+			ret.Code = 1
+		} else {
+			ret.Code = -1
+		}
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = notes
+}
+
 func createDocWithMd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -875,6 +970,21 @@ func listDocsByPath(c *gin.Context) {
 		showHidden = arg["showHidden"].(bool)
 	}
 
+	if model.IsSnapshotNotebook(notebook) {
+		files, err := model.ListSnapshotDocTree(notebook, p)
+		if nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+		ret.Data = map[string]interface{}{
+			"box":   notebook,
+			"path":  p,
+			"files": files,
+		}
+		return
+	}
+
 	files, totals, err := model.ListDocTree(notebook, p, sortMode, flashcard, showHidden, maxListCount)
 	if nil != err {
 		ret.Code = -1
@@ -1001,3 +1111,67 @@ func pushCreate(box *model.Box, p, treeID string, arg map[string]interface{}) {
 	evt.Callback = arg["callback"]
 	util.PushEvent(evt)
 }
+
+func getDocWindow(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["id"].(string)
+	by := arg["by"].(string)
+	anchorID, _ := arg["anchorID"].(string)
+	startID, _ := arg["startID"].(string)
+	endID, _ := arg["endID"].(string)
+	var before, after int
+	if nil != arg["before"] {
+		before = int(arg["before"].(float64))
+	}
+	if nil != arg["after"] {
+		after = int(arg["after"].(float64))
+	}
+
+	window, err := model.LoadDocWindow(rootID, by, anchorID, startID, endID, before, after)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = window
+}
+
+func saveDocWindow(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["id"].(string)
+	start := int(arg["start"].(float64))
+	end := int(arg["end"].(float64))
+	startID := arg["startID"].(string)
+	endID := arg["endID"].(string)
+	version := arg["version"].(string)
+	dom := arg["dom"].(string)
+
+	window, err := model.SaveDocWindow(rootID, start, end, startID, endID, version, dom)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = window
+}
+
+func migrateSyCompression(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	model.MigrateSyCompression()
+}
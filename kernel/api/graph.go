@@ -80,7 +80,22 @@ func getGraph(c *gin.Context) {
 	model.Conf.Graph.Global = global
 	model.Conf.Save()
 
-	boxID, nodes, links := model.BuildGraph(query)
+	filter := &model.GraphFilter{}
+	if nil != arg["filter"] {
+		filterArg, marshalErr := gulu.JSON.MarshalJSON(arg["filter"])
+		if nil != marshalErr {
+			ret.Code = -1
+			ret.Msg = marshalErr.Error()
+			return
+		}
+		if err = gulu.JSON.UnmarshalJSON(filterArg, filter); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	boxID, nodes, links := model.BuildGraph(query, filter)
 	ret.Data = map[string]interface{}{
 		"nodes": nodes,
 		"links": links,
@@ -137,3 +152,75 @@ func getLocalGraph(c *gin.Context) {
 	}
 	util.RandomSleep(200, 500)
 }
+
+func getBlockGraph(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	depth := 1
+	if nil != arg["depth"] {
+		depth = int(arg["depth"].(float64))
+	}
+	maxNodes := 0
+	if nil != arg["maxNodes"] {
+		maxNodes = int(arg["maxNodes"].(float64))
+	}
+
+	nodes, links, err := model.BuildBlockGraph(id, depth, maxNodes)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"id":    id,
+		"nodes": nodes,
+		"links": links,
+	}
+}
+
+func getDocMetrics(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if nil != arg["id"] {
+		ret.Data = model.GetDocMetricsByID(arg["id"].(string))
+		return
+	}
+
+	var boxes []string
+	if boxesArg, ok := arg["boxes"].([]interface{}); ok {
+		for _, box := range boxesArg {
+			boxes = append(boxes, box.(string))
+		}
+	}
+	orphan := false
+	if nil != arg["orphan"] {
+		orphan = arg["orphan"].(bool)
+	}
+	sortBy := ""
+	if nil != arg["sort"] {
+		sortBy = arg["sort"].(string)
+	}
+	limit := 0
+	if nil != arg["limit"] {
+		limit = int(arg["limit"].(float64))
+	}
+
+	docs, total := model.GetDocMetrics(boxes, orphan, sortBy, limit)
+	ret.Data = map[string]interface{}{
+		"docs":  docs,
+		"total": total,
+	}
+}
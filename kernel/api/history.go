@@ -53,7 +53,8 @@ func searchHistory(c *gin.Context) {
 	if nil != arg["op"] {
 		op = arg["op"].(string)
 	}
-	histories, pageCount, totalCount := model.FullTextSearchHistory(query, notebook, op, typ, page)
+	filter := parseHistorySearchFilter(arg)
+	histories, pageCount, totalCount := model.FullTextSearchHistory(query, notebook, op, typ, page, filter)
 	ret.Data = map[string]interface{}{
 		"histories":  histories,
 		"pageCount":  pageCount,
@@ -86,12 +87,34 @@ func getHistoryItems(c *gin.Context) {
 	if nil != arg["op"] {
 		op = arg["op"].(string)
 	}
-	histories := model.FullTextSearchHistoryItems(created, query, notebook, op, typ)
+	filter := parseHistorySearchFilter(arg)
+	histories := model.FullTextSearchHistoryItems(created, query, notebook, op, typ, filter)
 	ret.Data = map[string]interface{}{
 		"items": histories,
 	}
 }
 
+func parseHistorySearchFilter(arg map[string]interface{}) (ret *model.HistorySearchFilter) {
+	ret = &model.HistorySearchFilter{}
+	if pathPrefixArg := arg["pathPrefix"]; nil != pathPrefixArg {
+		ret.PathPrefix = pathPrefixArg.(string)
+	}
+	if fromArg := arg["from"]; nil != fromArg {
+		ret.From = int64(fromArg.(float64))
+	}
+	if toArg := arg["to"]; nil != toArg {
+		ret.To = int64(toArg.(float64))
+	}
+	return
+}
+
+func compactHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	model.CompactHistory()
+}
+
 func reindexHistory(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -154,12 +177,43 @@ func getDocHistoryContent(c *gin.Context) {
 		return
 	}
 
-	ret.Data = map[string]interface{}{
+	data := map[string]interface{}{
 		"id":         id,
 		"rootID":     rootID,
 		"content":    content,
 		"isLargeDoc": isLargeDoc,
 	}
+	if diffArg := arg["diff"]; nil != diffArg && diffArg.(bool) {
+		// 和当前文档比较，文档已经被删除时不返回差异
+		if diff, diffErr := model.DiffDocHistory(historyPath, ""); nil == diffErr {
+			data["diff"] = diff
+		}
+	}
+	ret.Data = data
+}
+
+func diffDocHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	leftPath := arg["leftPath"].(string)
+	var rightPath string
+	if rightPathArg := arg["rightPath"]; nil != rightPathArg {
+		rightPath = rightPathArg.(string)
+	}
+
+	diff, err := model.DiffDocHistory(leftPath, rightPath)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = diff
 }
 
 func rollbackDocHistory(c *gin.Context) {
@@ -184,3 +184,53 @@ func importStdMd(c *gin.Context) {
 		return
 	}
 }
+
+func importAppleNotes(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	localPath := arg["localPath"].(string)
+	toPath := arg["toPath"].(string)
+	err := model.ImportAppleNotes(notebook, localPath, toPath)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func importStream(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	dataType := "markdown"
+	if dataTypeArg := arg["dataType"]; nil != dataTypeArg {
+		dataType = dataTypeArg.(string)
+	}
+	data := arg["data"].(string)
+	var parentID, previousID string
+	if parentIDArg := arg["parentID"]; nil != parentIDArg {
+		parentID = parentIDArg.(string)
+	}
+	if previousIDArg := arg["previousID"]; nil != previousIDArg {
+		previousID = previousIDArg.(string)
+	}
+
+	err := model.ImportStream(dataType, data, parentID, previousID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
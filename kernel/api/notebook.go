@@ -334,7 +334,18 @@ func setNotebookConf(c *gin.Context) {
 
 	boxConf.DocCreateSavePath = strings.TrimSpace(boxConf.DocCreateSavePath)
 
+	if 0 > boxConf.HistoryRetentionDays {
+		boxConf.HistoryRetentionDays = 0
+	}
+	if -1 > boxConf.GenerateHistoryInterval {
+		boxConf.GenerateHistoryInterval = -1
+	}
+	if 1440 < boxConf.GenerateHistoryInterval {
+		boxConf.GenerateHistoryInterval = 1440
+	}
+
 	box.SaveConf(boxConf)
+	model.ResetHistoryTick()
 	ret.Data = boxConf
 }
 
@@ -361,6 +372,7 @@ func lsNotebooks(c *gin.Context) {
 		if nil != err {
 			return
 		}
+		notebooks = append(notebooks, model.GetSnapshotNotebooks()...)
 	}
 
 	ret.Data = map[string]interface{}{
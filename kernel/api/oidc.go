@@ -0,0 +1,100 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getOIDCSession(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	workspaceSession := model.GetOIDCSession(c)
+	if nil == workspaceSession {
+		ret.Data = map[string]interface{}{"enabled": model.OIDCEnabled()}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"enabled": true,
+		"subject": workspaceSession.OIDCSubject,
+		"name":    workspaceSession.OIDCName,
+		"role":    workspaceSession.OIDCRole,
+		"expired": workspaceSession.OIDCExpired,
+		"tokens":  model.GetOIDCTokens(workspaceSession.OIDCSubject),
+	}
+}
+
+func mintOIDCToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if util.ReadOnly {
+		ret.Code = -1
+		ret.Msg = model.Conf.Language(34)
+		return
+	}
+
+	workspaceSession := model.GetOIDCSession(c)
+	if nil == workspaceSession {
+		ret.Code = -1
+		ret.Msg = "not logged in via OIDC"
+		return
+	}
+
+	memo, _ := arg["memo"].(string)
+	ret.Data = model.MintOIDCToken(workspaceSession, memo)
+}
+
+func revokeOIDCTokens(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	workspaceSession := model.GetOIDCSession(c)
+	if nil == workspaceSession {
+		ret.Code = -1
+		ret.Msg = "not logged in via OIDC"
+		return
+	}
+
+	// token 为空时吊销当前用户签发的全部 token，否则按照 token 末尾匹配（支持 getSession 返回的脱敏 token）
+	token, _ := arg["token"].(string)
+	token = strings.TrimLeft(token, "*")
+	if 0 < len(token) && 4 > len(token) {
+		ret.Code = -1
+		ret.Msg = "token is too short"
+		return
+	}
+	ret.Data = map[string]interface{}{"count": model.RevokeOIDCTokens(workspaceSession.OIDCSubject, token)}
+}
@@ -61,3 +61,380 @@ func setPetalEnabled(c *gin.Context) {
 
 	ret.Data = data
 }
+
+func getPetalBackends(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetPluginBackends()
+}
+
+func reloadPetalBackend(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	packageName := arg["packageName"].(string)
+	if err := model.ReloadPluginBackend(packageName); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func getPetalPermissions(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	packageName := arg["packageName"].(string)
+	requested, granted := model.GetPluginPermissions(packageName)
+	ret.Data = map[string]interface{}{
+		"requested": requested,
+		"granted":   granted,
+	}
+}
+
+func setPetalPermission(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	packageName := arg["packageName"].(string)
+	permission := arg["permission"].(string)
+	granted := arg["granted"].(bool)
+	model.SetPluginPermissionGranted(packageName, permission, granted)
+}
+
+// getPetalStorageName 获取插件存储的命名空间，通过插件 token 访问时只能访问插件自己的存储。
+func getPetalStorageName(c *gin.Context, arg map[string]interface{}) string {
+	if plugin := c.GetString(model.PluginContextKey); "" != plugin {
+		return plugin
+	}
+	packageName, _ := arg["packageName"].(string)
+	return packageName
+}
+
+func getPetalStorage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	var keys []string
+	if keysArg, ok := arg["keys"].([]interface{}); ok {
+		for _, key := range keysArg {
+			keys = append(keys, key.(string))
+		}
+	}
+
+	data, err := model.GetPluginKV(name, keys)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = data
+}
+
+func listPetalStorage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	var prefix string
+	if nil != arg["prefix"] {
+		prefix = arg["prefix"].(string)
+	}
+
+	keys, err := model.ListPluginKVKeys(name, prefix)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = keys
+}
+
+func batchPetalStorage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	data, err := gulu.JSON.MarshalJSON(arg["ops"])
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	var ops []*model.PluginStorageOp
+	if err = gulu.JSON.UnmarshalJSON(data, &ops); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = model.BatchPluginKV(name, ops); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func putPetalBlob(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	name := getPetalStorageName(c, map[string]interface{}{"packageName": c.PostForm("packageName")})
+	key := c.PostForm("key")
+	file, err := c.FormFile("file")
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	reader, err := file.Open()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer reader.Close()
+
+	if err = model.PutPluginBlob(name, key, reader); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func getPetalBlob(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		c.JSON(http.StatusOK, ret)
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	p, err := model.GetPluginBlobPath(name, arg["key"].(string))
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		c.JSON(http.StatusOK, ret)
+		return
+	}
+	c.File(p)
+}
+
+func removePetalBlob(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	if err := model.RemovePluginBlob(name, arg["key"].(string)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func getPetalStorageUsage(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	usage, err := model.GetPluginStorageUsage(name)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = usage
+}
+
+func subscribePetalEvents(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	var topics []string
+	if topicsArg, ok := arg["topics"].([]interface{}); ok {
+		for _, topic := range topicsArg {
+			if t, ok := topic.(string); ok {
+				topics = append(topics, t)
+			}
+		}
+	}
+	filter := &model.PluginEventFilter{}
+	if filterArg, ok := arg["filter"].(map[string]interface{}); ok {
+		data, err := gulu.JSON.MarshalJSON(filterArg)
+		if nil == err {
+			err = gulu.JSON.UnmarshalJSON(data, filter)
+		}
+		if nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	sub, err := model.SubscribePluginEvents(name, model.PluginEventTargetWebSocket, topics, filter)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = sub
+}
+
+func unsubscribePetalEvents(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	model.UnsubscribePluginEvents(name, model.PluginEventTargetWebSocket)
+}
+
+func getPetalEventSubscriptions(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	ret.Data = model.GetPluginEventSubscriptions(name)
+}
+
+func getPetalJobs(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	ret.Data = model.GetPluginJobs(name)
+}
+
+func setPetalJob(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	job := &model.PluginJob{}
+	if err = gulu.JSON.UnmarshalJSON(data, job); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	job.Plugin = getPetalStorageName(c, arg)
+	job.Persistent = true
+
+	job, err = model.SetPluginJob(job)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = job
+}
+
+func removePetalJob(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	id, _ := arg["id"].(string)
+	if "" == id {
+		ret.Code = -1
+		ret.Msg = "job id is required"
+		return
+	}
+	model.RemovePluginJob(name, id)
+}
+
+func runPetalJob(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := getPetalStorageName(c, arg)
+	id, _ := arg["id"].(string)
+	if err := model.RunPluginJob(name, id); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
@@ -0,0 +1,91 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+const maxPluginRouteBodySize = 4 * 1024 * 1024
+
+func servePluginRoute(c *gin.Context) {
+	name := c.Param("name")
+	if plugin := c.GetString(model.PluginContextKey); "" != plugin && plugin != name {
+		// 插件之间不能互相调用注册的 API
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "plugin [" + plugin + "] is not allowed to access plugin [" + name + "] api"})
+		return
+	}
+	if http.MethodGet != c.Request.Method && http.MethodHead != c.Request.Method {
+		if model.CheckReadonly(c); c.IsAborted() {
+			return
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPluginRouteBodySize+1))
+	if nil != err {
+		c.JSON(http.StatusBadRequest, map[string]interface{}{"code": -1, "msg": err.Error()})
+		return
+	}
+	if maxPluginRouteBodySize < len(body) {
+		c.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{"code": -1, "msg": "request body is too large"})
+		return
+	}
+
+	header := map[string]string{}
+	for key := range c.Request.Header {
+		if "Authorization" == key || "Cookie" == key {
+			continue
+		}
+		header[key] = c.Request.Header.Get(key)
+	}
+
+	req := &model.PluginRouteRequest{
+		Method: c.Request.Method,
+		Path:   c.Param("path"),
+		Query:  c.Request.URL.Query(),
+		Header: header,
+		Body:   string(body),
+	}
+	status, respHeader, respBody, err := model.ServePluginRoute(name, req)
+	if nil != err {
+		ret := gulu.Ret.NewResult()
+		ret.Code = -1
+		ret.Msg = err.Error()
+		if model.ErrPluginRouteNotFound == err {
+			c.JSON(http.StatusNotFound, ret)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ret)
+		return
+	}
+
+	contentType := "application/json"
+	for key, value := range respHeader {
+		if strings.EqualFold("Content-Type", key) {
+			contentType = value
+			continue
+		}
+		c.Header(key, value)
+	}
+	c.Data(status, contentType, respBody)
+}
@@ -0,0 +1,90 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getQuota(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if idArg := arg["id"]; nil != idArg {
+		id = idArg.(string)
+	}
+	ret.Data = map[string]interface{}{
+		"quota":     model.Conf.Quota,
+		"effective": model.GetEffectiveQuota(id),
+	}
+}
+
+func setQuotaOverride(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	override := conf.NewQuotaOverride()
+	if maxDocBlocks := arg["maxDocBlocks"]; nil != maxDocBlocks {
+		override.MaxDocBlocks = int(maxDocBlocks.(float64))
+	}
+	if maxEmbedResults := arg["maxEmbedResults"]; nil != maxEmbedResults {
+		override.MaxEmbedResults = int(maxEmbedResults.(float64))
+	}
+	if maxAssetSize := arg["maxAssetSize"]; nil != maxAssetSize {
+		override.MaxAssetSize = int(maxAssetSize.(float64))
+	}
+
+	effective, err := model.SetQuotaOverride(id, override)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = effective
+}
+
+func removeQuotaOverride(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	model.RemoveQuotaOverride(id)
+	ret.Data = model.GetEffectiveQuota(id)
+}
@@ -91,18 +91,35 @@ func getBacklink2(c *gin.Context) {
 	id := arg["id"].(string)
 	keyword := arg["k"].(string)
 	mentionKeyword := arg["mk"].(string)
+	// 未指定排序和过滤条件时使用文档保存的配置
+	backlinkConf := model.GetBacklinkConf(id)
 	sortArg := arg["sort"]
-	sort := util.SortModeUpdatedDESC
+	sort := backlinkConf.Sort
 	if nil != sortArg {
 		sort, _ = strconv.Atoi(sortArg.(string))
 	}
 	mentionSortArg := arg["mSort"]
-	mentionSort := util.SortModeUpdatedDESC
+	mentionSort := backlinkConf.MentionSort
 	if nil != mentionSortArg {
 		mentionSort, _ = strconv.Atoi(mentionSortArg.(string))
 	}
-	boxID, backlinks, backmentions, linkRefsCount, mentionsCount := model.GetBacklink2(id, keyword, mentionKeyword, sort, mentionSort)
-	ret.Data = map[string]interface{}{
+	filter := backlinkConf.Filter
+	if nil != arg["filter"] {
+		filter = &model.BacklinkFilter{}
+		data, err := gulu.JSON.MarshalJSON(arg["filter"])
+		if nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+		if err = gulu.JSON.UnmarshalJSON(data, filter); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+	boxID, backlinks, backmentions, linkRefsCount, mentionsCount := model.GetBacklink2(id, keyword, mentionKeyword, sort, mentionSort, filter)
+	data := map[string]interface{}{
 		"backlinks":     backlinks,
 		"linkRefsCount": linkRefsCount,
 		"backmentions":  backmentions,
@@ -110,7 +127,15 @@ func getBacklink2(c *gin.Context) {
 		"k":             keyword,
 		"mk":            mentionKeyword,
 		"box":           boxID,
+		"sort":          sort,
+		"mSort":         mentionSort,
+		"filter":        filter,
 	}
+	if model.Conf.AI.SemanticIndex {
+		// 在反链面板中展示语义相关的文档
+		data["related"] = model.GetRelatedBlocks(id, model.Conf.AI.RelatedBlocksThreshold, 5)
+	}
+	ret.Data = data
 }
 
 func getBacklink(c *gin.Context) {
@@ -145,3 +170,96 @@ func getBacklink(c *gin.Context) {
 	}
 	util.RandomSleep(200, 500)
 }
+
+func auditWorkspace(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	issues, err := model.AuditWorkspace()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"issues": issues,
+	}
+}
+
+func repairRefIssues(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(arg["repairs"])
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	var repairs []*model.RefRepair
+	if err = gulu.JSON.UnmarshalJSON(data, &repairs); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	transactions, err := model.RepairRefIssues(repairs)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = transactions
+}
+
+func getBacklinkConf(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	ret.Data = model.GetBacklinkConf(id)
+}
+
+func setBacklinkConf(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var backlinkConf *model.BacklinkConf
+	if nil != arg["conf"] {
+		data, err := gulu.JSON.MarshalJSON(arg["conf"])
+		if nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+		backlinkConf = &model.BacklinkConf{}
+		if err = gulu.JSON.UnmarshalJSON(data, backlinkConf); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	if err := model.SetBacklinkConf(id, backlinkConf); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = model.GetBacklinkConf(id)
+}
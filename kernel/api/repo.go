@@ -84,6 +84,170 @@ func openRepoSnapshotDoc(c *gin.Context) {
 	}
 }
 
+func getSnapshotAssets(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var dir string
+	if dirArg := arg["dir"]; nil != dirArg {
+		dir = dirArg.(string)
+	}
+	assets, err := model.GetSnapshotAssets(id, dir)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"assets": assets,
+	}
+}
+
+func restoreSnapshotAsset(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	p := arg["path"].(string)
+	count, err := model.RestoreSnapshotAsset(id, p)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"count": count,
+	}
+}
+
+func mountSnapshotNotebook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	notebook := arg["notebook"].(string)
+	box, err := model.MountSnapshotNotebook(id, notebook)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"notebook": box,
+	}
+}
+
+func unmountSnapshotNotebook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	model.UnmountSnapshotNotebook(notebook)
+}
+
+func getPreOperationSnapshots(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	snapshots, err := model.GetPreOperationSnapshots()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"snapshots": snapshots,
+	}
+}
+
+func rollbackPreOperationSnapshot(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if err := model.RollbackPreOperationSnapshot(id); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func setRepoEventWebhooks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var urls []string
+	if nil != arg["webhooks"] {
+		for _, url := range arg["webhooks"].([]interface{}) {
+			urls = append(urls, url.(string))
+		}
+	}
+	if err := model.SetRepoEventWebhooks(urls); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = model.Conf.Repo.EventWebhooks
+}
+
+func getSnapshotNotebookDoc(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	id := arg["id"].(string)
+	content, isProtyleDoc, updated, err := model.GetSnapshotNotebookDoc(notebook, id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"content":      content,
+		"isProtyleDoc": isProtyleDoc,
+		"updated":      updated,
+	}
+}
+
 func diffRepoSnapshots(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
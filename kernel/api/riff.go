@@ -164,6 +164,350 @@ func getRiffCards(c *gin.Context) {
 	}
 }
 
+func getRiffReviewHeatmap(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID, days := getRiffStatArgs(arg, 365)
+	ret.Data = map[string]interface{}{
+		"days": model.GetReviewHeatmap(deckID, days),
+	}
+}
+
+func getRiffReviewStat(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID, days := getRiffStatArgs(arg, 30)
+	ret.Data = model.GetReviewStat(deckID, days)
+}
+
+func getRiffDueForecast(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID, days := getRiffStatArgs(arg, 30)
+	forecast, newCount := model.GetDueForecast(deckID, days)
+	ret.Data = map[string]interface{}{
+		"days":     forecast,
+		"newCount": newCount,
+	}
+}
+
+func getRiffStatArgs(arg map[string]interface{}, defaultDays int) (deckID string, days int) {
+	if nil != arg["deckID"] {
+		deckID = arg["deckID"].(string)
+	}
+	days = defaultDays
+	if nil != arg["days"] {
+		days = int(arg["days"].(float64))
+	}
+	if 1 > days || 3660 < days {
+		days = defaultDays
+	}
+	return
+}
+
+func addRiffClozeCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID := arg["deckID"].(string)
+	var blockIDs []string
+	for _, blockID := range arg["blockIDs"].([]interface{}) {
+		blockIDs = append(blockIDs, blockID.(string))
+	}
+
+	if err := model.AddClozeFlashcards(deckID, blockIDs); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func createRiffFilteredDeck(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := ""
+	if nil != arg["name"] {
+		name = arg["name"].(string)
+	}
+	param, err := gulu.JSON.MarshalJSON(arg["query"])
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	query := &model.FilteredDeckQuery{}
+	if err = gulu.JSON.UnmarshalJSON(param, query); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	filteredDeck, err := model.CreateFilteredDeck(name, query)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = filteredDeck
+}
+
+func getRiffFilteredDecks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetFilteredDecks()
+}
+
+func removeRiffFilteredDeck(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	model.RemoveFilteredDeck(arg["id"].(string))
+}
+
+func getRiffFilteredDeckDueCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	reviewedCardIDs := getReviewedCards(arg)
+	cards, stat, err := model.GetFilteredDeckDueCards(id, reviewedCardIDs)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"cards":           cards,
+		"unreviewedCount": len(cards),
+		"stat":            stat,
+	}
+}
+
+func reviewRiffFilteredDeckCard(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	cardID := arg["cardID"].(string)
+	rating := int(arg["rating"].(float64))
+	reviewedCardIDs := getReviewedCards(arg)
+	if err := model.ReviewFilteredDeckCard(id, cardID, riff.Rating(rating), reviewedCardIDs); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func suspendRiffCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	cardIDs := getRiffCardIDsArg(arg)
+	suspend := true
+	if nil != arg["suspend"] {
+		suspend = arg["suspend"].(bool)
+	}
+	if err := model.SuspendFlashcards(cardIDs, suspend); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func buryRiffCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	cardIDs := getRiffCardIDsArg(arg)
+	siblings := false
+	if nil != arg["siblings"] {
+		siblings = arg["siblings"].(bool)
+	}
+	if err := model.BuryFlashcards(cardIDs, siblings); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func unburyRiffCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if err := model.UnburyFlashcards(getRiffCardIDsArg(arg)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func rescheduleRiffCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	cardIDs := getRiffCardIDsArg(arg)
+	var due time.Time
+	if nil != arg["due"] {
+		var err error
+		if due, err = time.ParseInLocation("20060102150405", arg["due"].(string), time.Local); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+	interval := -1
+	if nil != arg["interval"] {
+		interval = int(arg["interval"].(float64))
+	}
+	if err := model.RescheduleFlashcards(cardIDs, due, interval); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func getRiffCardIDsArg(arg map[string]interface{}) (ret []string) {
+	if nil == arg["cardIDs"] {
+		return
+	}
+	for _, cardID := range arg["cardIDs"].([]interface{}) {
+		ret = append(ret, cardID.(string))
+	}
+	return
+}
+
+func browseRiffCards(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	query := &model.FlashcardQuery{MaxLapses: -1, Page: 1, PageSize: 20}
+	if nil != arg["deckID"] {
+		query.DeckID = arg["deckID"].(string)
+	}
+	if nil != arg["dueFrom"] {
+		query.DueFrom = int64(arg["dueFrom"].(float64))
+	}
+	if nil != arg["dueTo"] {
+		query.DueTo = int64(arg["dueTo"].(float64))
+	}
+	if nil != arg["minLapses"] {
+		query.MinLapses = int(arg["minLapses"].(float64))
+	}
+	if nil != arg["maxLapses"] {
+		query.MaxLapses = int(arg["maxLapses"].(float64))
+	}
+	if nil != arg["states"] {
+		for _, state := range arg["states"].([]interface{}) {
+			query.States = append(query.States, int(state.(float64)))
+		}
+	}
+	if nil != arg["suspended"] {
+		query.Suspended = int(arg["suspended"].(float64))
+	}
+	if nil != arg["notebook"] {
+		query.Notebook = arg["notebook"].(string)
+	}
+	if nil != arg["tag"] {
+		query.Tag = arg["tag"].(string)
+	}
+	if nil != arg["minDifficulty"] {
+		query.MinDifficulty = arg["minDifficulty"].(float64)
+	}
+	if nil != arg["maxDifficulty"] {
+		query.MaxDifficulty = arg["maxDifficulty"].(float64)
+	}
+	if nil != arg["sortBy"] {
+		query.SortBy = arg["sortBy"].(string)
+	}
+	if nil != arg["sortDesc"] {
+		query.SortDesc = arg["sortDesc"].(bool)
+	}
+	if nil != arg["page"] {
+		query.Page = int(arg["page"].(float64))
+	}
+	if nil != arg["pageSize"] {
+		query.PageSize = int(arg["pageSize"].(float64))
+	}
+
+	blocks, total, pageCount := model.BrowseFlashcards(query)
+	ret.Data = map[string]interface{}{
+		"blocks":    blocks,
+		"total":     total,
+		"pageCount": pageCount,
+	}
+}
+
 func reviewRiffCard(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -406,6 +750,38 @@ func removeRiffDeck(c *gin.Context) {
 	}
 }
 
+func setRiffDeckConf(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID := arg["deckID"].(string)
+	param, err := gulu.JSON.MarshalJSON(arg["conf"])
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	deckConf := &model.DeckConf{}
+	if err = gulu.JSON.UnmarshalJSON(param, deckConf); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = model.SetDeckConf(deckID, deckConf); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = deckConf
+}
+
 func createRiffDeck(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -441,11 +817,14 @@ func getRiffDecks(c *gin.Context) {
 }
 
 func deckData(deck *riff.Deck) map[string]interface{} {
+	deckConf := model.GetDeckConf(deck.ID)
 	return map[string]interface{}{
 		"id":      deck.ID,
 		"name":    deck.Name,
 		"size":    deck.CountCards(),
 		"created": time.UnixMilli(deck.Created).Format("2006-01-02 15:04:05"),
 		"updated": time.UnixMilli(deck.Updated).Format("2006-01-02 15:04:05"),
+		"parent":  deckConf.Parent,
+		"conf":    deckConf,
 	}
 }
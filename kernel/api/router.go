@@ -26,6 +26,7 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("GET", "/api/system/bootProgress", bootProgress)
 	ginServer.Handle("POST", "/api/system/bootProgress", bootProgress)
+	ginServer.Handle("POST", "/api/system/getBootStages", model.CheckAuth, getBootStages)
 	ginServer.Handle("GET", "/api/system/version", version)
 	ginServer.Handle("POST", "/api/system/version", version)
 	ginServer.Handle("POST", "/api/system/currentTime", currentTime)
@@ -33,31 +34,47 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/system/loginAuth", model.LoginAuth)
 	ginServer.Handle("POST", "/api/system/logoutAuth", model.LogoutAuth)
 	ginServer.Handle("GET", "/api/system/getCaptcha", model.GetCaptcha)
+	ginServer.Handle("GET", "/api/oidc/login", model.OIDCLogin)
+	ginServer.Handle("GET", "/api/oidc/callback", model.OIDCCallback)
+	ginServer.Handle("POST", "/api/oidc/getSession", model.CheckAuth, getOIDCSession)
+	ginServer.Handle("POST", "/api/oidc/mintToken", model.CheckAuth, mintOIDCToken)
+	ginServer.Handle("POST", "/api/oidc/revokeTokens", model.CheckAuth, revokeOIDCTokens)
 	ginServer.Handle("POST", "/api/system/setUILayout", setUILayout) // 这里不加鉴权 After modifying the access authentication code on the browser side, the other side does not refresh https://github.com/siyuan-note/siyuan/issues/8028
 	ginServer.Handle("GET", "/snippets/*filepath", serveSnippets)
 
 	// 需要鉴权
 
 	ginServer.Handle("POST", "/api/system/getEmojiConf", model.CheckAuth, getEmojiConf)
-	ginServer.Handle("POST", "/api/system/setAPIToken", model.CheckAuth, model.CheckReadonly, setAPIToken)
-	ginServer.Handle("POST", "/api/system/setAccessAuthCode", model.CheckAuth, model.CheckReadonly, setAccessAuthCode)
-	ginServer.Handle("POST", "/api/system/setFollowSystemLockScreen", model.CheckAuth, model.CheckReadonly, setFollowSystemLockScreen)
-	ginServer.Handle("POST", "/api/system/setNetworkServe", model.CheckAuth, model.CheckReadonly, setNetworkServe)
-	ginServer.Handle("POST", "/api/system/setUploadErrLog", model.CheckAuth, model.CheckReadonly, setUploadErrLog)
-	ginServer.Handle("POST", "/api/system/setAutoLaunch", model.CheckAuth, model.CheckReadonly, setAutoLaunch)
-	ginServer.Handle("POST", "/api/system/setGoogleAnalytics", model.CheckAuth, model.CheckReadonly, setGoogleAnalytics)
-	ginServer.Handle("POST", "/api/system/setDownloadInstallPkg", model.CheckAuth, model.CheckReadonly, setDownloadInstallPkg)
-	ginServer.Handle("POST", "/api/system/setNetworkProxy", model.CheckAuth, model.CheckReadonly, setNetworkProxy)
-	ginServer.Handle("POST", "/api/system/setWorkspaceDir", model.CheckAuth, model.CheckReadonly, setWorkspaceDir)
-	ginServer.Handle("POST", "/api/system/getWorkspaces", model.CheckAuth, getWorkspaces)
-	ginServer.Handle("POST", "/api/system/getMobileWorkspaces", model.CheckAuth, getMobileWorkspaces)
-	ginServer.Handle("POST", "/api/system/checkWorkspaceDir", model.CheckAuth, model.CheckReadonly, checkWorkspaceDir)
-	ginServer.Handle("POST", "/api/system/createWorkspaceDir", model.CheckAuth, model.CheckReadonly, createWorkspaceDir)
-	ginServer.Handle("POST", "/api/system/removeWorkspaceDir", model.CheckAuth, model.CheckReadonly, removeWorkspaceDir)
-	ginServer.Handle("POST", "/api/system/removeWorkspaceDirPhysically", model.CheckAuth, model.CheckReadonly, removeWorkspaceDirPhysically)
+	ginServer.Handle("POST", "/api/system/setAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAPIToken)
+	ginServer.Handle("POST", "/api/system/getAPIToken", model.CheckAuth, model.CheckAdminRole, getAPIToken)
+	ginServer.Handle("POST", "/api/system/setAccessAuthCode", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAccessAuthCode)
+	ginServer.Handle("POST", "/api/system/getAuthSessions", model.CheckAuth, model.CheckAdminRole, getAuthSessions)
+	ginServer.Handle("POST", "/api/system/revokeAuthSession", model.CheckAuth, model.CheckAdminRole, revokeAuthSession)
+	ginServer.Handle("POST", "/api/system/setupTOTP", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setupTOTP)
+	ginServer.Handle("POST", "/api/system/enableTOTP", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, enableTOTP)
+	ginServer.Handle("POST", "/api/system/disableTOTP", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, disableTOTP)
+	ginServer.Handle("POST", "/api/system/regenerateTOTPRecoveryCodes", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, regenerateTOTPRecoveryCodes)
+	ginServer.Handle("POST", "/api/system/setFollowSystemLockScreen", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setFollowSystemLockScreen)
+	ginServer.Handle("POST", "/api/system/setNetworkServe", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNetworkServe)
+	ginServer.Handle("POST", "/api/system/setUploadErrLog", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setUploadErrLog)
+	ginServer.Handle("POST", "/api/system/setAutoLaunch", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAutoLaunch)
+	ginServer.Handle("POST", "/api/system/setGoogleAnalytics", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setGoogleAnalytics)
+	ginServer.Handle("POST", "/api/system/setDownloadInstallPkg", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setDownloadInstallPkg)
+	ginServer.Handle("POST", "/api/system/setDataWatcher", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setDataWatcher)
+	ginServer.Handle("POST", "/api/system/setNetworkProxy", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNetworkProxy)
+	ginServer.Handle("POST", "/api/system/setNetworkTLS", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNetworkTLS)
+	ginServer.Handle("POST", "/api/system/renewNetworkTLSCert", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, renewNetworkTLSCert)
+	ginServer.Handle("POST", "/api/system/setNetworkACL", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNetworkACL)
+	ginServer.Handle("POST", "/api/system/setWorkspaceDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setWorkspaceDir)
+	ginServer.Handle("POST", "/api/system/getWorkspaces", model.CheckAuth, model.CheckAdminRole, getWorkspaces)
+	ginServer.Handle("POST", "/api/system/getMobileWorkspaces", model.CheckAuth, model.CheckAdminRole, getMobileWorkspaces)
+	ginServer.Handle("POST", "/api/system/checkWorkspaceDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, checkWorkspaceDir)
+	ginServer.Handle("POST", "/api/system/createWorkspaceDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createWorkspaceDir)
+	ginServer.Handle("POST", "/api/system/removeWorkspaceDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeWorkspaceDir)
+	ginServer.Handle("POST", "/api/system/removeWorkspaceDirPhysically", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeWorkspaceDirPhysically)
 	ginServer.Handle("POST", "/api/system/setAppearanceMode", model.CheckAuth, setAppearanceMode)
 	ginServer.Handle("POST", "/api/system/getSysFonts", model.CheckAuth, getSysFonts)
-	ginServer.Handle("POST", "/api/system/exit", model.CheckAuth, exit)
+	ginServer.Handle("POST", "/api/system/exit", model.CheckAuth, model.CheckAdminRole, exit)
 	ginServer.Handle("POST", "/api/system/getConf", model.CheckAuth, getConf)
 	ginServer.Handle("POST", "/api/system/checkUpdate", model.CheckAuth, checkUpdate)
 	ginServer.Handle("POST", "/api/system/exportLog", model.CheckAuth, exportLog)
@@ -73,11 +90,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/storage/removeCriterion", model.CheckAuth, model.CheckReadonly, removeCriterion)
 	ginServer.Handle("POST", "/api/storage/getRecentDocs", model.CheckAuth, getRecentDocs)
 
-	ginServer.Handle("POST", "/api/account/login", model.CheckAuth, model.CheckReadonly, login)
-	ginServer.Handle("POST", "/api/account/checkActivationcode", model.CheckAuth, model.CheckReadonly, checkActivationcode)
-	ginServer.Handle("POST", "/api/account/useActivationcode", model.CheckAuth, model.CheckReadonly, useActivationcode)
-	ginServer.Handle("POST", "/api/account/deactivate", model.CheckAuth, model.CheckReadonly, deactivateUser)
-	ginServer.Handle("POST", "/api/account/startFreeTrial", model.CheckAuth, model.CheckReadonly, startFreeTrial)
+	ginServer.Handle("POST", "/api/account/login", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, login)
+	ginServer.Handle("POST", "/api/account/checkActivationcode", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, checkActivationcode)
+	ginServer.Handle("POST", "/api/account/useActivationcode", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, useActivationcode)
+	ginServer.Handle("POST", "/api/account/deactivate", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, deactivateUser)
+	ginServer.Handle("POST", "/api/account/startFreeTrial", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, startFreeTrial)
 
 	ginServer.Handle("POST", "/api/notebook/lsNotebooks", model.CheckAuth, lsNotebooks)
 	ginServer.Handle("POST", "/api/notebook/openNotebook", model.CheckAuth, model.CheckReadonly, openNotebook)
@@ -93,11 +110,15 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/filetree/searchDocs", model.CheckAuth, searchDocs)
 	ginServer.Handle("POST", "/api/filetree/listDocsByPath", model.CheckAuth, listDocsByPath)
 	ginServer.Handle("POST", "/api/filetree/getDoc", model.CheckAuth, getDoc)
+	ginServer.Handle("POST", "/api/filetree/getDocWindow", model.CheckAuth, getDocWindow)
+	ginServer.Handle("POST", "/api/filetree/saveDocWindow", model.CheckAuth, model.CheckReadonly, saveDocWindow)
 	ginServer.Handle("POST", "/api/filetree/getDocCreateSavePath", model.CheckAuth, getDocCreateSavePath)
 	ginServer.Handle("POST", "/api/filetree/getRefCreateSavePath", model.CheckAuth, getRefCreateSavePath)
 	ginServer.Handle("POST", "/api/filetree/changeSort", model.CheckAuth, model.CheckReadonly, changeSort)
 	ginServer.Handle("POST", "/api/filetree/createDocWithMd", model.CheckAuth, model.CheckReadonly, createDocWithMd)
 	ginServer.Handle("POST", "/api/filetree/createDailyNote", model.CheckAuth, model.CheckReadonly, createDailyNote)
+	ginServer.Handle("POST", "/api/filetree/getDailyNote", model.CheckAuth, getDailyNote)
+	ginServer.Handle("POST", "/api/filetree/backfillDailyNotes", model.CheckAuth, model.CheckReadonly, backfillDailyNotes)
 	ginServer.Handle("POST", "/api/filetree/createDoc", model.CheckAuth, model.CheckReadonly, createDoc)
 	ginServer.Handle("POST", "/api/filetree/renameDoc", model.CheckAuth, model.CheckReadonly, renameDoc)
 	ginServer.Handle("POST", "/api/filetree/removeDoc", model.CheckAuth, model.CheckReadonly, removeDoc)
@@ -116,6 +137,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/filetree/upsertIndexes", model.CheckAuth, model.CheckReadonly, upsertIndexes)
 	ginServer.Handle("POST", "/api/filetree/removeIndexes", model.CheckAuth, model.CheckReadonly, removeIndexes)
 	ginServer.Handle("POST", "/api/filetree/listDocTree", model.CheckAuth, model.CheckReadonly, listDocTree)
+	ginServer.Handle("POST", "/api/filetree/migrateSyCompression", model.CheckAuth, model.CheckReadonly, migrateSyCompression)
 
 	ginServer.Handle("POST", "/api/format/autoSpace", model.CheckAuth, model.CheckReadonly, autoSpace)
 	ginServer.Handle("POST", "/api/format/netImg2LocalAssets", model.CheckAuth, model.CheckReadonly, netImg2LocalAssets)
@@ -125,9 +147,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/history/rollbackNotebookHistory", model.CheckAuth, model.CheckReadonly, rollbackNotebookHistory)
 	ginServer.Handle("POST", "/api/history/rollbackAssetsHistory", model.CheckAuth, model.CheckReadonly, rollbackAssetsHistory)
 	ginServer.Handle("POST", "/api/history/getDocHistoryContent", model.CheckAuth, getDocHistoryContent)
+	ginServer.Handle("POST", "/api/history/diffDocHistory", model.CheckAuth, diffDocHistory)
 	ginServer.Handle("POST", "/api/history/rollbackDocHistory", model.CheckAuth, model.CheckReadonly, rollbackDocHistory)
 	ginServer.Handle("POST", "/api/history/clearWorkspaceHistory", model.CheckAuth, model.CheckReadonly, clearWorkspaceHistory)
 	ginServer.Handle("POST", "/api/history/reindexHistory", model.CheckAuth, model.CheckReadonly, reindexHistory)
+	ginServer.Handle("POST", "/api/history/compactHistory", model.CheckAuth, model.CheckReadonly, compactHistory)
 	ginServer.Handle("POST", "/api/history/searchHistory", model.CheckAuth, searchHistory)
 	ginServer.Handle("POST", "/api/history/getHistoryItems", model.CheckAuth, getHistoryItems)
 
@@ -145,6 +169,8 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/query/sql", model.CheckAuth, SQL)
 	ginServer.Handle("POST", "/api/sqlite/flushTransaction", model.CheckAuth, model.CheckReadonly, flushTransaction)
+	ginServer.Handle("POST", "/api/sqlite/getQueueStatus", model.CheckAuth, getQueueStatus)
+	ginServer.Handle("POST", "/api/sqlite/getBlockCacheStat", model.CheckAuth, getBlockCacheStat)
 
 	ginServer.Handle("POST", "/api/search/searchTag", model.CheckAuth, searchTag)
 	ginServer.Handle("POST", "/api/search/searchTemplate", model.CheckAuth, searchTemplate)
@@ -202,20 +228,24 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/block/getBlockSiblingID", model.CheckAuth, getBlockSiblingID)
 	ginServer.Handle("POST", "/api/block/getBlockTreeInfos", model.CheckAuth, getBlockTreeInfos)
 
-	ginServer.Handle("POST", "/api/file/getFile", model.CheckAuth, getFile)
-	ginServer.Handle("POST", "/api/file/putFile", model.CheckAuth, model.CheckReadonly, putFile)
-	ginServer.Handle("POST", "/api/file/copyFile", model.CheckAuth, model.CheckReadonly, copyFile)
-	ginServer.Handle("POST", "/api/file/globalCopyFiles", model.CheckAuth, model.CheckReadonly, globalCopyFiles)
-	ginServer.Handle("POST", "/api/file/removeFile", model.CheckAuth, model.CheckReadonly, removeFile)
-	ginServer.Handle("POST", "/api/file/renameFile", model.CheckAuth, model.CheckReadonly, renameFile)
-	ginServer.Handle("POST", "/api/file/readDir", model.CheckAuth, readDir)
-	ginServer.Handle("POST", "/api/file/getUniqueFilename", model.CheckAuth, getUniqueFilename)
+	ginServer.Handle("POST", "/api/file/getFile", model.CheckAuth, model.CheckNoRole, getFile)
+	ginServer.Handle("POST", "/api/file/putFile", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, putFile)
+	ginServer.Handle("POST", "/api/file/copyFile", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, copyFile)
+	ginServer.Handle("POST", "/api/file/globalCopyFiles", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, globalCopyFiles)
+	ginServer.Handle("POST", "/api/file/removeFile", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, removeFile)
+	ginServer.Handle("POST", "/api/file/renameFile", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, renameFile)
+	ginServer.Handle("POST", "/api/file/readDir", model.CheckAuth, model.CheckNoRole, readDir)
+	ginServer.Handle("POST", "/api/file/getUniqueFilename", model.CheckAuth, model.CheckNoRole, getUniqueFilename)
 
 	ginServer.Handle("POST", "/api/ref/refreshBacklink", model.CheckAuth, refreshBacklink)
 	ginServer.Handle("POST", "/api/ref/getBacklink", model.CheckAuth, getBacklink)
 	ginServer.Handle("POST", "/api/ref/getBacklink2", model.CheckAuth, getBacklink2)
 	ginServer.Handle("POST", "/api/ref/getBacklinkDoc", model.CheckAuth, getBacklinkDoc)
 	ginServer.Handle("POST", "/api/ref/getBackmentionDoc", model.CheckAuth, getBackmentionDoc)
+	ginServer.Handle("POST", "/api/ref/getBacklinkConf", model.CheckAuth, getBacklinkConf)
+	ginServer.Handle("POST", "/api/ref/setBacklinkConf", model.CheckAuth, model.CheckReadonly, setBacklinkConf)
+	ginServer.Handle("POST", "/api/ref/auditWorkspace", model.CheckAuth, auditWorkspace)
+	ginServer.Handle("POST", "/api/ref/repairRefIssues", model.CheckAuth, model.CheckReadonly, repairRefIssues)
 
 	ginServer.Handle("POST", "/api/attr/getBookmarkLabels", model.CheckAuth, getBookmarkLabels)
 	ginServer.Handle("POST", "/api/attr/resetBlockAttrs", model.CheckAuth, model.CheckReadonly, resetBlockAttrs)
@@ -225,14 +255,14 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/cloud/getCloudSpace", model.CheckAuth, getCloudSpace)
 
-	ginServer.Handle("POST", "/api/sync/setSyncEnable", model.CheckAuth, model.CheckReadonly, setSyncEnable)
-	ginServer.Handle("POST", "/api/sync/setSyncPerception", model.CheckAuth, model.CheckReadonly, setSyncPerception)
-	ginServer.Handle("POST", "/api/sync/setSyncGenerateConflictDoc", model.CheckAuth, model.CheckReadonly, setSyncGenerateConflictDoc)
-	ginServer.Handle("POST", "/api/sync/setSyncMode", model.CheckAuth, model.CheckReadonly, setSyncMode)
-	ginServer.Handle("POST", "/api/sync/setSyncProvider", model.CheckAuth, model.CheckReadonly, setSyncProvider)
-	ginServer.Handle("POST", "/api/sync/setSyncProviderS3", model.CheckAuth, model.CheckReadonly, setSyncProviderS3)
-	ginServer.Handle("POST", "/api/sync/setSyncProviderWebDAV", model.CheckAuth, model.CheckReadonly, setSyncProviderWebDAV)
-	ginServer.Handle("POST", "/api/sync/setCloudSyncDir", model.CheckAuth, model.CheckReadonly, setCloudSyncDir)
+	ginServer.Handle("POST", "/api/sync/setSyncEnable", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncEnable)
+	ginServer.Handle("POST", "/api/sync/setSyncPerception", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncPerception)
+	ginServer.Handle("POST", "/api/sync/setSyncGenerateConflictDoc", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncGenerateConflictDoc)
+	ginServer.Handle("POST", "/api/sync/setSyncMode", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncMode)
+	ginServer.Handle("POST", "/api/sync/setSyncProvider", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProvider)
+	ginServer.Handle("POST", "/api/sync/setSyncProviderS3", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProviderS3)
+	ginServer.Handle("POST", "/api/sync/setSyncProviderWebDAV", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSyncProviderWebDAV)
+	ginServer.Handle("POST", "/api/sync/setCloudSyncDir", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setCloudSyncDir)
 	ginServer.Handle("POST", "/api/sync/createCloudSyncDir", model.CheckAuth, model.CheckReadonly, createCloudSyncDir)
 	ginServer.Handle("POST", "/api/sync/removeCloudSyncDir", model.CheckAuth, model.CheckReadonly, removeCloudSyncDir)
 	ginServer.Handle("POST", "/api/sync/listCloudSyncDir", model.CheckAuth, listCloudSyncDir)
@@ -263,10 +293,23 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/asset/getMissingAssets", model.CheckAuth, getMissingAssets)
 	ginServer.Handle("POST", "/api/asset/removeUnusedAsset", model.CheckAuth, model.CheckReadonly, removeUnusedAsset)
 	ginServer.Handle("POST", "/api/asset/removeUnusedAssets", model.CheckAuth, model.CheckReadonly, removeUnusedAssets)
+	ginServer.Handle("POST", "/api/asset/getUnusedAssetsImpact", model.CheckAuth, getUnusedAssetsImpact)
+	ginServer.Handle("POST", "/api/asset/getQuarantinedAssets", model.CheckAuth, getQuarantinedAssets)
+	ginServer.Handle("POST", "/api/asset/restoreQuarantinedAsset", model.CheckAuth, model.CheckReadonly, restoreQuarantinedAsset)
+	ginServer.Handle("POST", "/api/asset/purgeQuarantinedAssets", model.CheckAuth, model.CheckReadonly, purgeQuarantinedAssets)
+	ginServer.Handle("POST", "/api/asset/getAssetUsage", model.CheckAuth, getAssetUsage)
+	ginServer.Handle("POST", "/api/asset/getAssetConversions", model.CheckAuth, getAssetConversions)
+	ginServer.Handle("POST", "/api/asset/getLargestAssets", model.CheckAuth, getLargestAssets)
+	ginServer.Handle("POST", "/api/asset/getOffloadedAssets", model.CheckAuth, getOffloadedAssets)
+	ginServer.Handle("POST", "/api/asset/offloadAssets", model.CheckAuth, model.CheckReadonly, offloadAssets)
+	ginServer.Handle("POST", "/api/asset/restoreOffloadedAssets", model.CheckAuth, model.CheckReadonly, restoreOffloadedAssets)
 	ginServer.Handle("POST", "/api/asset/getDocImageAssets", model.CheckAuth, getDocImageAssets)
 	ginServer.Handle("POST", "/api/asset/renameAsset", model.CheckAuth, model.CheckReadonly, renameAsset)
+	ginServer.Handle("POST", "/api/asset/renameAssetWithRefs", model.CheckAuth, model.CheckReadonly, renameAssetWithRefs)
 	ginServer.Handle("POST", "/api/asset/getImageOCRText", model.CheckAuth, model.CheckReadonly, getImageOCRText)
 	ginServer.Handle("POST", "/api/asset/setImageOCRText", model.CheckAuth, model.CheckReadonly, setImageOCRText)
+	ginServer.Handle("POST", "/api/asset/getOCRStatus", model.CheckAuth, getOCRStatus)
+	ginServer.Handle("POST", "/api/asset/reOCRAssets", model.CheckAuth, model.CheckReadonly, reOCRAssets)
 	ginServer.Handle("POST", "/api/asset/fullReindexAssetContent", model.CheckAuth, model.CheckReadonly, fullReindexAssetContent)
 	ginServer.Handle("POST", "/api/asset/statAsset", model.CheckAuth, statAsset)
 
@@ -297,95 +340,140 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/export/exportRTF", model.CheckAuth, exportRTF)
 	ginServer.Handle("POST", "/api/export/exportEPUB", model.CheckAuth, exportEPUB)
 	ginServer.Handle("POST", "/api/export/exportAttributeView", model.CheckAuth, exportAttributeView)
+	ginServer.Handle("POST", "/api/export/renderDiagram", model.CheckAuth, renderDiagram)
 
-	ginServer.Handle("POST", "/api/import/importStdMd", model.CheckAuth, model.CheckReadonly, importStdMd)
-	ginServer.Handle("POST", "/api/import/importData", model.CheckAuth, model.CheckReadonly, importData)
-	ginServer.Handle("POST", "/api/import/importSY", model.CheckAuth, model.CheckReadonly, importSY)
+	ginServer.Handle("POST", "/api/import/importStdMd", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, importStdMd)
+	ginServer.Handle("POST", "/api/import/importData", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, importData)
+	ginServer.Handle("POST", "/api/import/importSY", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, importSY)
+	ginServer.Handle("POST", "/api/import/importAppleNotes", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, importAppleNotes)
+	ginServer.Handle("POST", "/api/import/importStream", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, importStream)
 
 	ginServer.Handle("POST", "/api/convert/pandoc", model.CheckAuth, model.CheckReadonly, pandoc)
 
 	ginServer.Handle("POST", "/api/template/render", model.CheckAuth, renderTemplate)
 	ginServer.Handle("POST", "/api/template/docSaveAsTemplate", model.CheckAuth, model.CheckReadonly, docSaveAsTemplate)
 	ginServer.Handle("POST", "/api/template/renderSprig", model.CheckAuth, renderSprig)
+	ginServer.Handle("POST", "/api/template/dryRun", model.CheckAuth, dryRunTemplate)
+	ginServer.Handle("POST", "/api/template/getVarSchema", model.CheckAuth, getTemplateVarSchema)
+	ginServer.Handle("POST", "/api/template/getSchedules", model.CheckAuth, getTemplateSchedules)
+	ginServer.Handle("POST", "/api/template/setSchedule", model.CheckAuth, model.CheckReadonly, setTemplateSchedule)
+	ginServer.Handle("POST", "/api/template/removeSchedule", model.CheckAuth, model.CheckReadonly, removeTemplateSchedule)
+	ginServer.Handle("POST", "/api/template/runSchedule", model.CheckAuth, model.CheckReadonly, runTemplateSchedule)
+	ginServer.Handle("POST", "/api/template/getScheduleRuns", model.CheckAuth, getTemplateScheduleRuns)
 
 	ginServer.Handle("POST", "/api/transactions", model.CheckAuth, model.CheckReadonly, performTransactions)
 
-	ginServer.Handle("POST", "/api/setting/setAccount", model.CheckAuth, model.CheckReadonly, setAccount)
-	ginServer.Handle("POST", "/api/setting/setEditor", model.CheckAuth, model.CheckReadonly, setEditor)
-	ginServer.Handle("POST", "/api/setting/setExport", model.CheckAuth, model.CheckReadonly, setExport)
-	ginServer.Handle("POST", "/api/setting/setFiletree", model.CheckAuth, model.CheckReadonly, setFiletree)
-	ginServer.Handle("POST", "/api/setting/setSearch", model.CheckAuth, model.CheckReadonly, setSearch)
-	ginServer.Handle("POST", "/api/setting/setKeymap", model.CheckAuth, model.CheckReadonly, setKeymap)
-	ginServer.Handle("POST", "/api/setting/setAppearance", model.CheckAuth, model.CheckReadonly, setAppearance)
+	ginServer.Handle("POST", "/api/setting/setAccount", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAccount)
+	ginServer.Handle("POST", "/api/setting/setEditor", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEditor)
+	ginServer.Handle("POST", "/api/setting/setExport", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setExport)
+	ginServer.Handle("POST", "/api/setting/setFiletree", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setFiletree)
+	ginServer.Handle("POST", "/api/setting/setSearch", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setSearch)
+	ginServer.Handle("POST", "/api/setting/setPerformance", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setPerformance)
+	ginServer.Handle("POST", "/api/setting/setMonitor", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setMonitor)
+	ginServer.Handle("POST", "/api/setting/setQuota", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setQuota)
+	ginServer.Handle("POST", "/api/setting/setReminder", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setReminder)
+	ginServer.Handle("POST", "/api/setting/setCitation", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setCitation)
+	ginServer.Handle("POST", "/api/setting/setAsset", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAsset)
+	ginServer.Handle("POST", "/api/setting/setKeymap", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setKeymap)
+	ginServer.Handle("POST", "/api/setting/setAppearance", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAppearance)
 	ginServer.Handle("POST", "/api/setting/getCloudUser", model.CheckAuth, getCloudUser)
-	ginServer.Handle("POST", "/api/setting/logoutCloudUser", model.CheckAuth, model.CheckReadonly, logoutCloudUser)
-	ginServer.Handle("POST", "/api/setting/login2faCloudUser", model.CheckAuth, model.CheckReadonly, login2faCloudUser)
-	ginServer.Handle("POST", "/api/setting/setEmoji", model.CheckAuth, model.CheckReadonly, setEmoji)
-	ginServer.Handle("POST", "/api/setting/setFlashcard", model.CheckAuth, model.CheckReadonly, setFlashcard)
-	ginServer.Handle("POST", "/api/setting/setAI", model.CheckAuth, model.CheckReadonly, setAI)
-	ginServer.Handle("POST", "/api/setting/setBazaar", model.CheckAuth, model.CheckReadonly, setBazaar)
-	ginServer.Handle("POST", "/api/setting/refreshVirtualBlockRef", model.CheckAuth, model.CheckReadonly, refreshVirtualBlockRef)
-	ginServer.Handle("POST", "/api/setting/addVirtualBlockRefInclude", model.CheckAuth, model.CheckReadonly, addVirtualBlockRefInclude)
-	ginServer.Handle("POST", "/api/setting/addVirtualBlockRefExclude", model.CheckAuth, model.CheckReadonly, addVirtualBlockRefExclude)
-	ginServer.Handle("POST", "/api/setting/setSnippet", model.CheckAuth, model.CheckReadonly, setConfSnippet)
-	ginServer.Handle("POST", "/api/setting/setEditorReadOnly", model.CheckAuth, model.CheckReadonly, setEditorReadOnly)
+	ginServer.Handle("POST", "/api/setting/logoutCloudUser", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, logoutCloudUser)
+	ginServer.Handle("POST", "/api/setting/login2faCloudUser", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, login2faCloudUser)
+	ginServer.Handle("POST", "/api/setting/setEmoji", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEmoji)
+	ginServer.Handle("POST", "/api/setting/setFlashcard", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setFlashcard)
+	ginServer.Handle("POST", "/api/setting/setTemplate", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setTemplate)
+	ginServer.Handle("POST", "/api/setting/setAI", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAI)
+	ginServer.Handle("POST", "/api/setting/setOIDC", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setOIDC)
+	ginServer.Handle("POST", "/api/setting/setBazaar", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setBazaar)
+	ginServer.Handle("POST", "/api/setting/refreshVirtualBlockRef", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, refreshVirtualBlockRef)
+	ginServer.Handle("POST", "/api/setting/addVirtualBlockRefInclude", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, addVirtualBlockRefInclude)
+	ginServer.Handle("POST", "/api/setting/addVirtualBlockRefExclude", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, addVirtualBlockRefExclude)
+	ginServer.Handle("POST", "/api/setting/getVirtualBlockRefDicts", model.CheckAuth, getVirtualBlockRefDicts)
+	ginServer.Handle("POST", "/api/setting/setVirtualBlockRefDicts", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setVirtualBlockRefDicts)
+	ginServer.Handle("POST", "/api/setting/setSnippet", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setConfSnippet)
+	ginServer.Handle("POST", "/api/setting/setEditorReadOnly", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEditorReadOnly)
 
 	ginServer.Handle("POST", "/api/graph/resetGraph", model.CheckAuth, model.CheckReadonly, resetGraph)
 	ginServer.Handle("POST", "/api/graph/resetLocalGraph", model.CheckAuth, model.CheckReadonly, resetLocalGraph)
 	ginServer.Handle("POST", "/api/graph/getGraph", model.CheckAuth, getGraph)
 	ginServer.Handle("POST", "/api/graph/getLocalGraph", model.CheckAuth, getLocalGraph)
+	ginServer.Handle("POST", "/api/graph/getBlockGraph", model.CheckAuth, getBlockGraph)
+	ginServer.Handle("POST", "/api/graph/getDocMetrics", model.CheckAuth, getDocMetrics)
+
+	ginServer.Handle("POST", "/api/share/createShare", model.CheckAuth, model.CheckReadonly, createShare)
+	ginServer.Handle("POST", "/api/share/getShares", model.CheckAuth, getShares)
+	ginServer.Handle("POST", "/api/share/revokeShares", model.CheckAuth, model.CheckReadonly, revokeShares)
+	ginServer.Handle("POST", "/api/share/setShareComment", model.CheckAuth, model.CheckReadonly, setShareComment)
+	ginServer.Handle("POST", "/api/share/getShareComments", model.CheckAuth, getShareComments)
+	ginServer.Handle("POST", "/api/share/moderateShareComments", model.CheckAuth, model.CheckReadonly, moderateShareComments)
+	ginServer.Handle("POST", "/api/share/removeShareComments", model.CheckAuth, model.CheckReadonly, removeShareComments)
+
+	ginServer.Handle("POST", "/api/collab/setPresence", model.CheckAuth, setCollabPresence)
+	ginServer.Handle("POST", "/api/collab/removePresence", model.CheckAuth, removeCollabPresence)
+	ginServer.Handle("POST", "/api/collab/getPresences", model.CheckAuth, getCollabPresences)
 
 	ginServer.Handle("POST", "/api/bazaar/getBazaarPlugin", model.CheckAuth, getBazaarPlugin)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledPlugin", model.CheckAuth, getInstalledPlugin)
-	ginServer.Handle("POST", "/api/bazaar/installBazaarPlugin", model.CheckAuth, model.CheckReadonly, installBazaarPlugin)
-	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarPlugin", model.CheckAuth, model.CheckReadonly, uninstallBazaarPlugin)
+	ginServer.Handle("POST", "/api/bazaar/installBazaarPlugin", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, installBazaarPlugin)
+	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarPlugin", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, uninstallBazaarPlugin)
 	ginServer.Handle("POST", "/api/bazaar/getBazaarWidget", model.CheckAuth, getBazaarWidget)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledWidget", model.CheckAuth, getInstalledWidget)
-	ginServer.Handle("POST", "/api/bazaar/installBazaarWidget", model.CheckAuth, model.CheckReadonly, installBazaarWidget)
-	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarWidget", model.CheckAuth, model.CheckReadonly, uninstallBazaarWidget)
+	ginServer.Handle("POST", "/api/bazaar/installBazaarWidget", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, installBazaarWidget)
+	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarWidget", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, uninstallBazaarWidget)
 	ginServer.Handle("POST", "/api/bazaar/getBazaarIcon", model.CheckAuth, getBazaarIcon)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledIcon", model.CheckAuth, getInstalledIcon)
-	ginServer.Handle("POST", "/api/bazaar/installBazaarIcon", model.CheckAuth, model.CheckReadonly, installBazaarIcon)
-	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarIcon", model.CheckAuth, model.CheckReadonly, uninstallBazaarIcon)
+	ginServer.Handle("POST", "/api/bazaar/installBazaarIcon", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, installBazaarIcon)
+	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarIcon", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, uninstallBazaarIcon)
 	ginServer.Handle("POST", "/api/bazaar/getBazaarTemplate", model.CheckAuth, getBazaarTemplate)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledTemplate", model.CheckAuth, getInstalledTemplate)
-	ginServer.Handle("POST", "/api/bazaar/installBazaarTemplate", model.CheckAuth, model.CheckReadonly, installBazaarTemplate)
-	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarTemplate", model.CheckAuth, model.CheckReadonly, uninstallBazaarTemplate)
+	ginServer.Handle("POST", "/api/bazaar/installBazaarTemplate", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, installBazaarTemplate)
+	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarTemplate", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, uninstallBazaarTemplate)
 	ginServer.Handle("POST", "/api/bazaar/getBazaarTheme", model.CheckAuth, getBazaarTheme)
 	ginServer.Handle("POST", "/api/bazaar/getInstalledTheme", model.CheckAuth, getInstalledTheme)
-	ginServer.Handle("POST", "/api/bazaar/installBazaarTheme", model.CheckAuth, model.CheckReadonly, installBazaarTheme)
-	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarTheme", model.CheckAuth, model.CheckReadonly, uninstallBazaarTheme)
+	ginServer.Handle("POST", "/api/bazaar/installBazaarTheme", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, installBazaarTheme)
+	ginServer.Handle("POST", "/api/bazaar/uninstallBazaarTheme", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, uninstallBazaarTheme)
 	ginServer.Handle("POST", "/api/bazaar/getBazaarPackageREAME", model.CheckAuth, getBazaarPackageREAME)
 	ginServer.Handle("POST", "/api/bazaar/getUpdatedPackage", model.CheckAuth, getUpdatedPackage)
-	ginServer.Handle("POST", "/api/bazaar/batchUpdatePackage", model.CheckAuth, batchUpdatePackage)
-
-	ginServer.Handle("POST", "/api/repo/initRepoKey", model.CheckAuth, model.CheckReadonly, initRepoKey)
-	ginServer.Handle("POST", "/api/repo/initRepoKeyFromPassphrase", model.CheckAuth, model.CheckReadonly, initRepoKeyFromPassphrase)
-	ginServer.Handle("POST", "/api/repo/resetRepo", model.CheckAuth, model.CheckReadonly, resetRepo)
-	ginServer.Handle("POST", "/api/repo/purgeRepo", model.CheckAuth, model.CheckReadonly, purgeRepo)
-	ginServer.Handle("POST", "/api/repo/purgeCloudRepo", model.CheckAuth, model.CheckReadonly, purgeCloudRepo)
-	ginServer.Handle("POST", "/api/repo/importRepoKey", model.CheckAuth, model.CheckReadonly, importRepoKey)
-	ginServer.Handle("POST", "/api/repo/createSnapshot", model.CheckAuth, model.CheckReadonly, createSnapshot)
-	ginServer.Handle("POST", "/api/repo/tagSnapshot", model.CheckAuth, model.CheckReadonly, tagSnapshot)
-	ginServer.Handle("POST", "/api/repo/checkoutRepo", model.CheckAuth, model.CheckReadonly, checkoutRepo)
-	ginServer.Handle("POST", "/api/repo/getRepoSnapshots", model.CheckAuth, getRepoSnapshots)
-	ginServer.Handle("POST", "/api/repo/getRepoTagSnapshots", model.CheckAuth, getRepoTagSnapshots)
-	ginServer.Handle("POST", "/api/repo/removeRepoTagSnapshot", model.CheckAuth, model.CheckReadonly, removeRepoTagSnapshot)
-	ginServer.Handle("POST", "/api/repo/getCloudRepoTagSnapshots", model.CheckAuth, getCloudRepoTagSnapshots)
-	ginServer.Handle("POST", "/api/repo/getCloudRepoSnapshots", model.CheckAuth, getCloudRepoSnapshots)
-	ginServer.Handle("POST", "/api/repo/removeCloudRepoTagSnapshot", model.CheckAuth, model.CheckReadonly, removeCloudRepoTagSnapshot)
-	ginServer.Handle("POST", "/api/repo/uploadCloudSnapshot", model.CheckAuth, model.CheckReadonly, uploadCloudSnapshot)
-	ginServer.Handle("POST", "/api/repo/downloadCloudSnapshot", model.CheckAuth, model.CheckReadonly, downloadCloudSnapshot)
-	ginServer.Handle("POST", "/api/repo/diffRepoSnapshots", model.CheckAuth, diffRepoSnapshots)
-	ginServer.Handle("POST", "/api/repo/openRepoSnapshotDoc", model.CheckAuth, openRepoSnapshotDoc)
-	ginServer.Handle("POST", "/api/repo/getRepoFile", model.CheckAuth, getRepoFile)
+	ginServer.Handle("POST", "/api/bazaar/batchUpdatePackage", model.CheckAuth, model.CheckNoRole, batchUpdatePackage)
+	ginServer.Handle("GET", "/api/bazaar/mirror/*path", model.CheckAuth, serveBazaarMirror)
+
+	ginServer.Handle("POST", "/api/repo/initRepoKey", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, initRepoKey)
+	ginServer.Handle("POST", "/api/repo/initRepoKeyFromPassphrase", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, initRepoKeyFromPassphrase)
+	ginServer.Handle("POST", "/api/repo/resetRepo", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resetRepo)
+	ginServer.Handle("POST", "/api/repo/purgeRepo", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, purgeRepo)
+	ginServer.Handle("POST", "/api/repo/purgeCloudRepo", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, purgeCloudRepo)
+	ginServer.Handle("POST", "/api/repo/importRepoKey", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importRepoKey)
+	ginServer.Handle("POST", "/api/repo/createSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, createSnapshot)
+	ginServer.Handle("POST", "/api/repo/tagSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, tagSnapshot)
+	ginServer.Handle("POST", "/api/repo/checkoutRepo", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, checkoutRepo)
+	ginServer.Handle("POST", "/api/repo/getRepoSnapshots", model.CheckAuth, model.CheckNoRole, getRepoSnapshots)
+	ginServer.Handle("POST", "/api/repo/getRepoTagSnapshots", model.CheckAuth, model.CheckNoRole, getRepoTagSnapshots)
+	ginServer.Handle("POST", "/api/repo/removeRepoTagSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, removeRepoTagSnapshot)
+	ginServer.Handle("POST", "/api/repo/getCloudRepoTagSnapshots", model.CheckAuth, model.CheckNoRole, getCloudRepoTagSnapshots)
+	ginServer.Handle("POST", "/api/repo/getCloudRepoSnapshots", model.CheckAuth, model.CheckNoRole, getCloudRepoSnapshots)
+	ginServer.Handle("POST", "/api/repo/removeCloudRepoTagSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, removeCloudRepoTagSnapshot)
+	ginServer.Handle("POST", "/api/repo/uploadCloudSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, uploadCloudSnapshot)
+	ginServer.Handle("POST", "/api/repo/downloadCloudSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, downloadCloudSnapshot)
+	ginServer.Handle("POST", "/api/repo/diffRepoSnapshots", model.CheckAuth, model.CheckNoRole, diffRepoSnapshots)
+	ginServer.Handle("POST", "/api/repo/openRepoSnapshotDoc", model.CheckAuth, model.CheckNoRole, openRepoSnapshotDoc)
+	ginServer.Handle("POST", "/api/repo/getRepoFile", model.CheckAuth, model.CheckNoRole, getRepoFile)
+	ginServer.Handle("POST", "/api/repo/getSnapshotAssets", model.CheckAuth, model.CheckNoRole, getSnapshotAssets)
+	ginServer.Handle("POST", "/api/repo/restoreSnapshotAsset", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, restoreSnapshotAsset)
+	ginServer.Handle("POST", "/api/repo/mountSnapshotNotebook", model.CheckAuth, model.CheckNoRole, mountSnapshotNotebook)
+	ginServer.Handle("POST", "/api/repo/unmountSnapshotNotebook", model.CheckAuth, model.CheckNoRole, unmountSnapshotNotebook)
+	ginServer.Handle("POST", "/api/repo/getSnapshotNotebookDoc", model.CheckAuth, model.CheckNoRole, getSnapshotNotebookDoc)
+	ginServer.Handle("POST", "/api/repo/setRepoEventWebhooks", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setRepoEventWebhooks)
+	ginServer.Handle("POST", "/api/repo/getPreOperationSnapshots", model.CheckAuth, model.CheckNoRole, getPreOperationSnapshots)
+	ginServer.Handle("POST", "/api/repo/rollbackPreOperationSnapshot", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, rollbackPreOperationSnapshot)
 
 	ginServer.Handle("POST", "/api/riff/createRiffDeck", model.CheckAuth, model.CheckReadonly, createRiffDeck)
 	ginServer.Handle("POST", "/api/riff/renameRiffDeck", model.CheckAuth, model.CheckReadonly, renameRiffDeck)
 	ginServer.Handle("POST", "/api/riff/removeRiffDeck", model.CheckAuth, model.CheckReadonly, removeRiffDeck)
+	ginServer.Handle("POST", "/api/riff/setRiffDeckConf", model.CheckAuth, model.CheckReadonly, setRiffDeckConf)
 	ginServer.Handle("POST", "/api/riff/getRiffDecks", model.CheckAuth, getRiffDecks)
 	ginServer.Handle("POST", "/api/riff/addRiffCards", model.CheckAuth, model.CheckReadonly, addRiffCards)
 	ginServer.Handle("POST", "/api/riff/removeRiffCards", model.CheckAuth, model.CheckReadonly, removeRiffCards)
+	ginServer.Handle("POST", "/api/riff/addRiffClozeCards", model.CheckAuth, model.CheckReadonly, addRiffClozeCards)
 	ginServer.Handle("POST", "/api/riff/getRiffDueCards", model.CheckAuth, getRiffDueCards)
 	ginServer.Handle("POST", "/api/riff/getTreeRiffDueCards", model.CheckAuth, getTreeRiffDueCards)
 	ginServer.Handle("POST", "/api/riff/getNotebookRiffDueCards", model.CheckAuth, getNotebookRiffDueCards)
@@ -397,15 +485,31 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/riff/resetRiffCards", model.CheckAuth, model.CheckReadonly, resetRiffCards)
 	ginServer.Handle("POST", "/api/riff/batchSetRiffCardsDueTime", model.CheckAuth, model.CheckReadonly, batchSetRiffCardsDueTime)
 	ginServer.Handle("POST", "/api/riff/getRiffCardsByBlockIDs", model.CheckAuth, model.CheckReadonly, getRiffCardsByBlockIDs)
+	ginServer.Handle("POST", "/api/riff/browseRiffCards", model.CheckAuth, browseRiffCards)
+	ginServer.Handle("POST", "/api/riff/suspendRiffCards", model.CheckAuth, model.CheckReadonly, suspendRiffCards)
+	ginServer.Handle("POST", "/api/riff/buryRiffCards", model.CheckAuth, model.CheckReadonly, buryRiffCards)
+	ginServer.Handle("POST", "/api/riff/unburyRiffCards", model.CheckAuth, model.CheckReadonly, unburyRiffCards)
+	ginServer.Handle("POST", "/api/riff/rescheduleRiffCards", model.CheckAuth, model.CheckReadonly, rescheduleRiffCards)
+	ginServer.Handle("POST", "/api/riff/createRiffFilteredDeck", model.CheckAuth, model.CheckReadonly, createRiffFilteredDeck)
+	ginServer.Handle("POST", "/api/riff/getRiffFilteredDecks", model.CheckAuth, getRiffFilteredDecks)
+	ginServer.Handle("POST", "/api/riff/removeRiffFilteredDeck", model.CheckAuth, model.CheckReadonly, removeRiffFilteredDeck)
+	ginServer.Handle("POST", "/api/riff/getRiffFilteredDeckDueCards", model.CheckAuth, getRiffFilteredDeckDueCards)
+	ginServer.Handle("POST", "/api/riff/reviewRiffFilteredDeckCard", model.CheckAuth, model.CheckReadonly, reviewRiffFilteredDeckCard)
+	ginServer.Handle("POST", "/api/riff/getRiffReviewHeatmap", model.CheckAuth, getRiffReviewHeatmap)
+	ginServer.Handle("POST", "/api/riff/getRiffReviewStat", model.CheckAuth, getRiffReviewStat)
+	ginServer.Handle("POST", "/api/riff/getRiffDueForecast", model.CheckAuth, getRiffDueForecast)
 
 	ginServer.Handle("POST", "/api/notification/pushMsg", model.CheckAuth, pushMsg)
 	ginServer.Handle("POST", "/api/notification/pushErrMsg", model.CheckAuth, pushErrMsg)
 
 	ginServer.Handle("POST", "/api/snippet/getSnippet", model.CheckAuth, getSnippet)
-	ginServer.Handle("POST", "/api/snippet/setSnippet", model.CheckAuth, model.CheckReadonly, setSnippet)
+	ginServer.Handle("POST", "/api/snippet/setSnippet", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, setSnippet)
 	ginServer.Handle("POST", "/api/snippet/removeSnippet", model.CheckAuth, model.CheckReadonly, removeSnippet)
 
 	ginServer.Handle("POST", "/api/av/renderAttributeView", model.CheckAuth, renderAttributeView)
+	ginServer.Handle("POST", "/api/av/renderAttributeViewCalendar", model.CheckAuth, renderAttributeViewCalendar)
+	ginServer.Handle("POST", "/api/av/renderAttributeViewGallery", model.CheckAuth, renderAttributeViewGallery)
+	ginServer.Handle("POST", "/api/av/getAttributeViewRows", model.CheckAuth, getAttributeViewRows)
 	ginServer.Handle("POST", "/api/av/renderHistoryAttributeView", model.CheckAuth, renderHistoryAttributeView)
 	ginServer.Handle("POST", "/api/av/renderSnapshotAttributeView", model.CheckAuth, renderSnapshotAttributeView)
 	ginServer.Handle("POST", "/api/av/getAttributeViewKeys", model.CheckAuth, getAttributeViewKeys)
@@ -426,12 +530,46 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/av/getMirrorDatabaseBlocks", model.CheckAuth, model.CheckReadonly, getMirrorDatabaseBlocks)
 	ginServer.Handle("POST", "/api/av/getAttributeViewKeysByAvID", model.CheckAuth, model.CheckReadonly, getAttributeViewKeysByAvID)
 	ginServer.Handle("POST", "/api/av/duplicateAttributeViewBlock", model.CheckAuth, model.CheckReadonly, duplicateAttributeViewBlock)
+	ginServer.Handle("POST", "/api/av/mergeAttributeViews", model.CheckAuth, model.CheckReadonly, mergeAttributeViews)
+	ginServer.Handle("POST", "/api/av/bulkUpdateAttributeViewCells", model.CheckAuth, model.CheckReadonly, bulkUpdateAttributeViewCells)
+	ginServer.Handle("POST", "/api/av/syncAttributeViewDataSource", model.CheckAuth, model.CheckReadonly, syncAttributeViewDataSource)
 
 	ginServer.Handle("POST", "/api/ai/chatGPT", model.CheckAuth, chatGPT)
 	ginServer.Handle("POST", "/api/ai/chatGPTWithAction", model.CheckAuth, chatGPTWithAction)
+	ginServer.Handle("POST", "/api/ai/chatStream", model.CheckAuth, chatStream)
+	ginServer.Handle("POST", "/api/ai/cancelStream", model.CheckAuth, cancelStream)
+	ginServer.Handle("POST", "/api/ai/getRelatedBlocks", model.CheckAuth, getRelatedBlocks)
+	ginServer.Handle("POST", "/api/ai/getSuggestions", model.CheckAuth, getAISuggestions)
+	ginServer.Handle("POST", "/api/ai/acceptSuggestion", model.CheckAuth, model.CheckReadonly, acceptAISuggestion)
+	ginServer.Handle("POST", "/api/ai/rejectSuggestion", model.CheckAuth, model.CheckReadonly, rejectAISuggestion)
+	ginServer.Handle("POST", "/api/ai/getActions", model.CheckAuth, getAIActions)
+	ginServer.Handle("POST", "/api/ai/setAction", model.CheckAuth, model.CheckReadonly, setAIAction)
+	ginServer.Handle("POST", "/api/ai/removeAction", model.CheckAuth, model.CheckReadonly, removeAIAction)
+	ginServer.Handle("POST", "/api/ai/runAction", model.CheckAuth, model.CheckReadonly, runAIAction)
+	ginServer.Handle("POST", "/api/ai/summarizeSubtree", model.CheckAuth, summarizeSubtree)
 
 	ginServer.Handle("POST", "/api/petal/loadPetals", model.CheckAuth, loadPetals)
-	ginServer.Handle("POST", "/api/petal/setPetalEnabled", model.CheckAuth, model.CheckReadonly, setPetalEnabled)
+	ginServer.Handle("POST", "/api/petal/setPetalEnabled", model.CheckAuth, model.CheckNoRole, model.CheckReadonly, setPetalEnabled)
+	ginServer.Handle("POST", "/api/petal/getPetalBackends", model.CheckAuth, getPetalBackends)
+	ginServer.Handle("POST", "/api/petal/reloadPetalBackend", model.CheckAuth, model.CheckReadonly, reloadPetalBackend)
+	ginServer.Handle("POST", "/api/petal/getPetalPermissions", model.CheckAuth, getPetalPermissions)
+	ginServer.Handle("POST", "/api/petal/setPetalPermission", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setPetalPermission)
+	ginServer.Handle("POST", "/api/petal/storage/get", model.CheckAuth, getPetalStorage)
+	ginServer.Handle("POST", "/api/petal/storage/list", model.CheckAuth, listPetalStorage)
+	ginServer.Handle("POST", "/api/petal/storage/batch", model.CheckAuth, model.CheckReadonly, batchPetalStorage)
+	ginServer.Handle("POST", "/api/petal/storage/putBlob", model.CheckAuth, model.CheckReadonly, putPetalBlob)
+	ginServer.Handle("POST", "/api/petal/storage/getBlob", model.CheckAuth, getPetalBlob)
+	ginServer.Handle("POST", "/api/petal/storage/removeBlob", model.CheckAuth, model.CheckReadonly, removePetalBlob)
+	ginServer.Handle("POST", "/api/petal/storage/getUsage", model.CheckAuth, getPetalStorageUsage)
+	ginServer.Handle("POST", "/api/petal/event/subscribe", model.CheckAuth, subscribePetalEvents)
+	ginServer.Handle("POST", "/api/petal/event/unsubscribe", model.CheckAuth, unsubscribePetalEvents)
+	ginServer.Handle("POST", "/api/petal/event/getSubscriptions", model.CheckAuth, getPetalEventSubscriptions)
+	ginServer.Handle("POST", "/api/petal/job/getJobs", model.CheckAuth, getPetalJobs)
+	ginServer.Handle("POST", "/api/petal/job/setJob", model.CheckAuth, model.CheckReadonly, setPetalJob)
+	ginServer.Handle("POST", "/api/petal/job/removeJob", model.CheckAuth, model.CheckReadonly, removePetalJob)
+	ginServer.Handle("POST", "/api/petal/job/runJob", model.CheckAuth, model.CheckReadonly, runPetalJob)
+
+	ginServer.Any("/api/plugin/:name/*path", model.CheckAuth, servePluginRoute)
 
 	ginServer.Any("/api/network/echo", model.CheckAuth, echo)
 	ginServer.Handle("POST", "/api/network/forwardProxy", model.CheckAuth, forwardProxy)
@@ -443,4 +581,25 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/archive/zip", model.CheckAuth, model.CheckReadonly, zip)
 	ginServer.Handle("POST", "/api/archive/unzip", model.CheckAuth, model.CheckReadonly, unzip)
+
+	ginServer.Handle("POST", "/api/quota/getQuota", model.CheckAuth, getQuota)
+	ginServer.Handle("POST", "/api/quota/setOverride", model.CheckAuth, model.CheckReadonly, setQuotaOverride)
+	ginServer.Handle("POST", "/api/quota/removeOverride", model.CheckAuth, model.CheckReadonly, removeQuotaOverride)
+
+	ginServer.Handle("POST", "/api/reminder/listReminders", model.CheckAuth, listReminders)
+	ginServer.Handle("POST", "/api/reminder/setReminder", model.CheckAuth, model.CheckReadonly, setBlockReminder)
+	ginServer.Handle("POST", "/api/reminder/removeReminder", model.CheckAuth, model.CheckReadonly, removeReminder)
+	ginServer.Handle("POST", "/api/reminder/snoozeReminder", model.CheckAuth, model.CheckReadonly, snoozeReminder)
+	ginServer.Handle("POST", "/api/reminder/completeReminder", model.CheckAuth, model.CheckReadonly, completeReminder)
+
+	ginServer.Handle("POST", "/api/tasks/list", model.CheckAuth, listTasks)
+
+	ginServer.Handle("POST", "/api/citation/search", model.CheckAuth, searchCitations)
+	ginServer.Handle("POST", "/api/citation/getItems", model.CheckAuth, getCitationItems)
+	ginServer.Handle("POST", "/api/citation/insert", model.CheckAuth, model.CheckReadonly, insertCitation)
+	ginServer.Handle("POST", "/api/citation/bibliography", model.CheckAuth, getBibliography)
+
+	ginServer.Handle("POST", "/api/board/getBoard", model.CheckAuth, getBoard)
+	ginServer.Handle("POST", "/api/board/moveCard", model.CheckAuth, model.CheckReadonly, moveBoardCard)
+	ginServer.Handle("POST", "/api/board/reorderCards", model.CheckAuth, model.CheckReadonly, reorderBoardCards)
 }
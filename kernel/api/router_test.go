@@ -0,0 +1,79 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestOIDCRoleGetFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	util.WorkspaceDir = t.TempDir()
+	util.ConfDir = filepath.Join(util.WorkspaceDir, "conf")
+	if err := os.MkdirAll(util.ConfDir, 0755); nil != err {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(util.ConfDir, "conf.json"), []byte(`{"accessAuthCode":"secret"}`), 0644); nil != err {
+		t.Fatal(err)
+	}
+	model.Conf = &model.AppConf{OIDC: &conf.OIDC{Enabled: true, Issuer: "https://idp.example.com", ClientID: "siyuan"}}
+
+	ginServer := gin.New()
+	ginServer.Use(sessions.Sessions("siyuan", cookie.NewStore([]byte("test"))))
+	ginServer.GET("/test/oidcLogin", func(c *gin.Context) {
+		session := util.GetSession(c)
+		workspaceSession := util.GetWorkspaceSession(session)
+		workspaceSession.OIDCSubject = "user"
+		workspaceSession.OIDCName = "user"
+		workspaceSession.OIDCRole = c.Query("role")
+		workspaceSession.OIDCExpired = time.Now().Add(time.Hour).UnixMilli()
+		session.Save(c)
+	})
+	ServeAPI(ginServer)
+
+	getFile := func(role string) *httptest.ResponseRecorder {
+		login := httptest.NewRecorder()
+		ginServer.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/test/oidcLogin?role="+role, nil))
+		req := httptest.NewRequest(http.MethodPost, "/api/file/getFile", strings.NewReader(`{"path":"conf/conf.json"}`))
+		for _, c := range login.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		ginServer.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := getFile("reader"); http.StatusForbidden != rec.Code || strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("non-admin OIDC session should be denied, got [%d] %s", rec.Code, rec.Body.String())
+	}
+	if rec := getFile(conf.OIDCRoleAdmin); http.StatusOK != rec.Code || !strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("admin OIDC session should be allowed, got [%d] %s", rec.Code, rec.Body.String())
+	}
+}
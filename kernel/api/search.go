@@ -17,6 +17,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -94,7 +95,14 @@ func fullTextSearchAssetContent(c *gin.Context) {
 	}
 
 	page, pageSize, query, types, method, orderBy := parseSearchAssetContentArgs(arg)
-	assetContents, matchedAssetCount, pageCount := model.FullTextSearchAssetContent(query, types, method, orderBy, page, pageSize)
+	metaFilter, err := parseAssetMetaFilterArg(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	assetContents, matchedAssetCount, pageCount := model.FullTextSearchAssetContent(query, types, metaFilter, method, orderBy, page, pageSize)
 	ret.Data = map[string]interface{}{
 		"assetContents":     assetContents,
 		"matchedAssetCount": matchedAssetCount,
@@ -262,9 +270,13 @@ func getEmbedBlock(c *gin.Context) {
 		breadcrumb = breadcrumbArg.(bool)
 	}
 
-	blocks := model.GetEmbedBlock(embedBlockID, includeIDs, headingMode, breadcrumb)
+	blocks, truncated := model.GetEmbedBlock(embedBlockID, includeIDs, headingMode, breadcrumb)
+	if truncated {
+		ret.Msg = fmt.Sprintf(model.Conf.Language(250), model.GetEffectiveQuota(embedBlockID).MaxEmbedResults)
+	}
 	ret.Data = map[string]interface{}{
-		"blocks": blocks,
+		"blocks":    blocks,
+		"truncated": truncated,
 	}
 }
 
@@ -315,9 +327,13 @@ func searchEmbedBlock(c *gin.Context) {
 		breadcrumb = breadcrumbArg.(bool)
 	}
 
-	blocks := model.SearchEmbedBlock(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
+	blocks, truncated := model.SearchEmbedBlock(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
+	if truncated {
+		ret.Msg = fmt.Sprintf(model.Conf.Language(250), model.GetEffectiveQuota(embedBlockID).MaxEmbedResults)
+	}
 	ret.Data = map[string]interface{}{
-		"blocks": blocks,
+		"blocks":    blocks,
+		"truncated": truncated,
 	}
 }
 
@@ -440,6 +456,23 @@ func parseSearchBlockArgs(arg map[string]interface{}) (page, pageSize int, query
 	return
 }
 
+// parseAssetMetaFilterArg 解析资源文件元数据过滤条件，参数 meta 的结构为 model.AssetMetaFilter。
+func parseAssetMetaFilterArg(arg map[string]interface{}) (ret *model.AssetMetaFilter, err error) {
+	metaArg := arg["meta"]
+	if nil == metaArg {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(metaArg)
+	if nil != err {
+		return
+	}
+
+	ret = &model.AssetMetaFilter{}
+	err = gulu.JSON.UnmarshalJSON(data, ret)
+	return
+}
+
 func parseSearchAssetContentArgs(arg map[string]interface{}) (page, pageSize int, query string, types map[string]bool, method, orderBy int) {
 	page = 1
 	if nil != arg["page"] {
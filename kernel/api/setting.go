@@ -20,10 +20,13 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
 	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -121,6 +124,44 @@ func addVirtualBlockRefInclude(c *gin.Context) {
 	util.BroadcastByType("main", "setConf", 0, "", model.Conf)
 }
 
+func getVirtualBlockRefDicts(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetVirtualBlockRefDicts()
+}
+
+func setVirtualBlockRefDicts(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg["dicts"])
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	var dicts []*conf.VirtualBlockRefDict
+	if err = gulu.JSON.UnmarshalJSON(param, &dicts); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = model.SetVirtualBlockRefDicts(dicts); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = model.Conf.Editor.VirtualBlockRefDicts
+	util.BroadcastByType("main", "setConf", 0, "", model.Conf)
+}
+
 func refreshVirtualBlockRef(c *gin.Context) {
 	// Add internal kernel API `/api/setting/refreshVirtualBlockRef` https://github.com/siyuan-note/siyuan/issues/9829
 
@@ -156,6 +197,7 @@ func setBazaar(c *gin.Context) {
 
 	model.Conf.Bazaar = bazaar
 	model.Conf.Save()
+	model.ApplyBazaarConf()
 
 	ret.Data = bazaar
 }
@@ -182,6 +224,7 @@ func setAI(c *gin.Context) {
 		ret.Msg = err.Error()
 		return
 	}
+	model.FixAIConf(ai)
 
 	if 5 > ai.OpenAI.APITimeout {
 		ai.OpenAI.APITimeout = 5
@@ -202,12 +245,82 @@ func setAI(c *gin.Context) {
 		ai.OpenAI.APIMaxContexts = 7
 	}
 
+	for _, provider := range ai.Providers {
+		switch provider.Type {
+		case conf.AIProviderOpenAI, conf.AIProviderAzure, conf.AIProviderOllama, conf.AIProviderLlamaCpp, conf.AIProviderAnthropic, conf.AIProviderGemini:
+		default:
+			ret.Code = -1
+			ret.Msg = "unsupported AI provider type [" + provider.Type + "]"
+			return
+		}
+	}
+
 	model.Conf.AI = ai
 	model.Conf.Save()
 
 	ret.Data = ai
 }
 
+func setOIDC(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can set OIDC"
+		return
+	}
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	oidc := conf.NewOIDC()
+	if err = gulu.JSON.UnmarshalJSON(param, oidc); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	oidc.Issuer = strings.TrimSpace(oidc.Issuer)
+	if oidc.Enabled && ("" == oidc.Issuer || "" == oidc.ClientID) {
+		ret.Code = -1
+		ret.Msg = "OIDC issuer and client ID are required"
+		return
+	}
+	if model.MaskedAccessAuthCode == oidc.ClientSecret {
+		oidc.ClientSecret = model.Conf.OIDC.ClientSecret
+	}
+	if nil == oidc.RoleMappings {
+		oidc.RoleMappings = []*conf.OIDCRoleMapping{}
+	}
+	if 1 > oidc.SessionTTL {
+		oidc.SessionTTL = 24 * 7
+	}
+	if 1 > oidc.TokenTTL {
+		oidc.TokenTTL = 24 * 30
+	}
+
+	model.Conf.OIDC = oidc
+	model.Conf.Save()
+
+	maskedConf, err := model.GetMaskedConf()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = maskedConf.OIDC
+}
+
 func setFlashcard(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -245,6 +358,42 @@ func setFlashcard(c *gin.Context) {
 	ret.Data = flashcard
 }
 
+func setTemplate(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	tpl := &conf.Template{}
+	if err = gulu.JSON.UnmarshalJSON(param, tpl); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if nil == tpl.HTTPAllowDomains {
+		tpl.HTTPAllowDomains = []string{}
+	}
+	if 1 > tpl.HTTPTimeout || 60 < tpl.HTTPTimeout {
+		tpl.HTTPTimeout = conf.NewTemplate().HTTPTimeout
+	}
+
+	model.Conf.Template = tpl
+	model.Conf.Save()
+
+	ret.Data = tpl
+}
+
 func setAccount(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -291,6 +440,7 @@ func setEditor(c *gin.Context) {
 	}
 
 	oldGenerateHistoryInterval := model.Conf.Editor.GenerateHistoryInterval
+	oldHistoryCompression := model.Conf.Editor.HistoryCompression
 
 	editor := conf.NewEditor()
 	if err = gulu.JSON.UnmarshalJSON(param, editor); nil != err {
@@ -307,6 +457,12 @@ func setEditor(c *gin.Context) {
 		editor.KaTexMacros = "{}"
 	}
 
+	dictsChanged := nil != arg["virtualBlockRefDicts"]
+	if !dictsChanged {
+		// 虚拟引用词典通过单独的接口维护，未传入时保留
+		editor.VirtualBlockRefDicts = model.Conf.Editor.VirtualBlockRefDicts
+	}
+
 	oldVirtualBlockRef := model.Conf.Editor.VirtualBlockRef
 	oldVirtualBlockRefInclude := model.Conf.Editor.VirtualBlockRefInclude
 	oldVirtualBlockRefExclude := model.Conf.Editor.VirtualBlockRefExclude
@@ -316,12 +472,16 @@ func setEditor(c *gin.Context) {
 	model.Conf.Save()
 
 	if oldGenerateHistoryInterval != model.Conf.Editor.GenerateHistoryInterval {
-		model.ChangeHistoryTick(editor.GenerateHistoryInterval)
+		model.ResetHistoryTick()
+	}
+
+	if oldHistoryCompression != model.Conf.Editor.HistoryCompression {
+		model.MigrateHistoryCompression()
 	}
 
 	if oldVirtualBlockRef != model.Conf.Editor.VirtualBlockRef ||
 		oldVirtualBlockRefInclude != model.Conf.Editor.VirtualBlockRefInclude ||
-		oldVirtualBlockRefExclude != model.Conf.Editor.VirtualBlockRefExclude {
+		oldVirtualBlockRefExclude != model.Conf.Editor.VirtualBlockRefExclude || dictsChanged {
 		model.ResetVirtualBlockRefCache()
 	}
 
@@ -368,6 +528,19 @@ func setExport(c *gin.Context) {
 		}
 	}
 
+	// 图表渲染程序会在导出时执行，只接受校验通过的可执行文件
+	for _, bin := range [][2]string{{export.MermaidBin, "mmdc"}, {export.GraphvizBin, "dot"}} {
+		if "" == bin[0] {
+			continue
+		}
+		if err = model.CheckDiagramBin(bin[0], bin[1]); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			ret.Data = map[string]interface{}{"closeTimeout": 5000}
+			return
+		}
+	}
+
 	model.Conf.Export = export
 	model.Conf.Save()
 
@@ -412,14 +585,251 @@ func setFiletree(c *gin.Context) {
 	if 32 < fileTree.MaxOpenTabCount {
 		fileTree.MaxOpenTabCount = 32
 	}
+	if !filesys.IsValidSyCompression(fileTree.SyCompression) {
+		ret.Code = -1
+		ret.Msg = "invalid sy compression [" + fileTree.SyCompression + "]"
+		return
+	}
+	oldSyCompression := model.Conf.FileTree.SyCompression
 	model.Conf.FileTree = fileTree
 	model.Conf.Save()
 
 	util.UseSingleLineSave = model.Conf.FileTree.UseSingleLineSave
+	util.SyCompression = model.Conf.FileTree.SyCompression
+	if oldSyCompression != model.Conf.FileTree.SyCompression {
+		model.MigrateSyCompression()
+	}
 
 	ret.Data = model.Conf.FileTree
 }
 
+func setAsset(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	asset := &conf.Asset{}
+	if err = gulu.JSON.UnmarshalJSON(param, asset); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if 0 > asset.QuarantineDays {
+		asset.QuarantineDays = 0
+	}
+	if 0 > asset.AutoCleanUnusedDays {
+		asset.AutoCleanUnusedDays = 0
+	}
+	asset.LastAutoCleanUnused = model.Conf.Asset.LastAutoCleanUnused
+	if nil == asset.Storage {
+		asset.Storage = model.Conf.Asset.Storage
+	}
+	if nil == asset.ImageConvert {
+		asset.ImageConvert = model.Conf.Asset.ImageConvert
+	}
+	if nil == asset.WatchFolders {
+		asset.WatchFolders = model.Conf.Asset.WatchFolders
+	}
+	if nil == asset.ContentIndex {
+		asset.ContentIndex = model.Conf.Asset.ContentIndex
+	}
+	for _, folder := range asset.WatchFolders {
+		if "" == folder.ID {
+			folder.ID = ast.NewNodeID()
+		}
+	}
+
+	model.Conf.Asset = asset
+	model.Conf.Save()
+
+	ret.Data = model.Conf.Asset
+}
+
+func setPerformance(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	performance := conf.NewPerformance()
+	if err = gulu.JSON.UnmarshalJSON(param, performance); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if 0 > performance.IndexWorkers {
+		performance.IndexWorkers = 0
+	}
+	if conf.MaxIndexWorkers < performance.IndexWorkers {
+		performance.IndexWorkers = conf.MaxIndexWorkers
+	}
+	if 1 > performance.IndexBatchSize {
+		performance.IndexBatchSize = 1
+	}
+	if conf.MaxIndexBatchSize < performance.IndexBatchSize {
+		performance.IndexBatchSize = conf.MaxIndexBatchSize
+	}
+	if conf.MinSQLFlushInterval > performance.SQLFlushInterval {
+		performance.SQLFlushInterval = conf.MinSQLFlushInterval
+	}
+	if conf.MaxSQLFlushInterval < performance.SQLFlushInterval {
+		performance.SQLFlushInterval = conf.MaxSQLFlushInterval
+	}
+	if 0 > performance.SQLFlushBatchSize {
+		performance.SQLFlushBatchSize = 0
+	}
+	if conf.MaxSQLFlushBatchSize < performance.SQLFlushBatchSize {
+		performance.SQLFlushBatchSize = conf.MaxSQLFlushBatchSize
+	}
+	if 1 > performance.BlockCacheSize {
+		performance.BlockCacheSize = 1
+	}
+	if conf.MaxBlockCacheSize < performance.BlockCacheSize {
+		performance.BlockCacheSize = conf.MaxBlockCacheSize
+	}
+	if 0 > performance.TreeSaveDebounce {
+		performance.TreeSaveDebounce = 0
+	}
+	if conf.MaxTreeSaveDebounce < performance.TreeSaveDebounce {
+		performance.TreeSaveDebounce = conf.MaxTreeSaveDebounce
+	}
+
+	model.Conf.Performance = performance
+	model.Conf.Save()
+	sql.SetFlushPolicy(time.Duration(performance.SQLFlushInterval)*time.Millisecond, performance.SQLFlushBatchSize)
+	sql.SetBlockCacheBudget(int64(performance.BlockCacheSize) * 1024 * 1024)
+	filesys.SetTreeSaveDebounce(time.Duration(performance.TreeSaveDebounce) * time.Millisecond)
+	ret.Data = performance
+}
+
+func setQuota(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	quota := conf.NewQuota()
+	quota.Overrides = nil // 未传入 overrides 时保留已有的文档配额覆盖
+	if err = gulu.JSON.UnmarshalJSON(param, quota); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if 0 > quota.MaxDocBlocks {
+		quota.MaxDocBlocks = 0
+	}
+	if conf.MaxQuotaDocBlocks < quota.MaxDocBlocks {
+		quota.MaxDocBlocks = conf.MaxQuotaDocBlocks
+	}
+	if 0 > quota.MaxEmbedResults {
+		quota.MaxEmbedResults = 0
+	}
+	if conf.MaxQuotaEmbedResults < quota.MaxEmbedResults {
+		quota.MaxEmbedResults = conf.MaxQuotaEmbedResults
+	}
+	if 0 > quota.MaxAssetSize {
+		quota.MaxAssetSize = 0
+	}
+	if conf.MaxQuotaAssetSize < quota.MaxAssetSize {
+		quota.MaxAssetSize = conf.MaxQuotaAssetSize
+	}
+
+	ret.Data = model.SetQuota(quota)
+}
+
+func setCitation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	citation := conf.NewCitation()
+	if err = gulu.JSON.UnmarshalJSON(param, citation); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if citation, err = model.SetCitation(citation); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = citation
+}
+
+func setReminder(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	systemNotification := model.Conf.Reminder.SystemNotification
+	if nil != arg["systemNotification"] {
+		systemNotification = arg["systemNotification"].(bool)
+	}
+	urls := model.Conf.Reminder.Webhooks
+	if nil != arg["webhooks"] {
+		urls = nil
+		for _, url := range arg["webhooks"].([]interface{}) {
+			urls = append(urls, url.(string))
+		}
+	}
+	if err := model.SetReminderConf(systemNotification, urls); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = model.Conf.Reminder
+}
+
 func setSearch(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -595,3 +1005,36 @@ func setEmoji(c *gin.Context) {
 
 	model.Conf.Editor.Emoji = emoji
 }
+
+func setMonitor(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can set monitor"
+		return
+	}
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	monitor := conf.NewMonitor()
+	if err = gulu.JSON.UnmarshalJSON(param, monitor); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	model.SetMonitor(monitor)
+	ret.Data = monitor
+}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/88250/gulu"
@@ -32,6 +33,21 @@ func flushTransaction(c *gin.Context) {
 	defer c.JSON(http.StatusOK, ret)
 
 	sql.FlushQueue()
+	ret.Data = sql.QueueStatus()
+}
+
+func getBlockCacheStat(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = sql.BlockCacheStat()
+}
+
+func getQueueStatus(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = sql.QueueStatus()
 }
 
 func SQL(c *gin.Context) {
@@ -44,7 +60,20 @@ func SQL(c *gin.Context) {
 	}
 
 	stmt := arg["stmt"].(string)
-	result, err := sql.Query(stmt, model.Conf.Search.Limit)
+	var result []map[string]interface{}
+	var err error
+	if role := model.GetRole(c); "" != role {
+		// 带角色的调用方只能执行查询语句，并且查询不到隐藏列的单元格
+		result, err = sql.QueryRole(stmt, model.Conf.Search.Limit, model.RoleHiddenKeyIDs(role))
+	} else if plugin := c.GetString(model.PluginContextKey); "" != plugin && !model.IsPluginPermissionGranted(plugin, model.PluginPermSQLWrite) {
+		// 未授予 sql.write 权限的插件只能执行只读语句，是否只读由 SQLite 执行时判断，不能通过语句前缀判断（比如 WITH ... DELETE）
+		result, err = sql.QueryReadonly(stmt, model.Conf.Search.Limit)
+		if errors.Is(err, sql.ErrReadonlyStmt) {
+			err = errors.New(model.RequestPluginPermission(plugin, model.PluginPermSQLWrite, c.Request.URL.Path))
+		}
+	} else {
+		result, err = sql.Query(stmt, model.Conf.Search.Limit)
+	}
 	if nil != err {
 		ret.Code = 1
 		ret.Msg = err.Error()
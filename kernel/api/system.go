@@ -45,7 +45,10 @@ func getNetwork(c *gin.Context) {
 	}
 
 	ret.Data = map[string]interface{}{
-		"proxy": maskedConf.System.NetworkProxy,
+		"proxy":     maskedConf.System.NetworkProxy,
+		"tls":       maskedConf.System.NetworkTLS,
+		"tlsStatus": model.GetNetworkTLSStatus(),
+		"acl":       maskedConf.System.NetworkACL,
 	}
 }
 
@@ -265,10 +268,22 @@ func setAPIToken(c *gin.Context) {
 	}
 
 	token := arg["token"].(string)
+	if model.MaskedAccessAuthCode == token {
+		return
+	}
 	model.Conf.Api.Token = token
 	model.Conf.Save()
 }
 
+func getAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"token": model.Conf.Api.Token,
+	}
+}
+
 func setAccessAuthCode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -341,6 +356,19 @@ func bootProgress(c *gin.Context) {
 	ret.Data = map[string]interface{}{"progress": progress, "details": details}
 }
 
+func getBootStages(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	stages, elapsed := util.GetBootStages()
+	ret.Data = map[string]interface{}{
+		"stages":         stages,
+		"elapsed":        elapsed,
+		"uiLoaded":       util.IsUILoaded,
+		"deferBootTasks": model.Conf.Performance.DeferBootTasks,
+	}
+}
+
 func setAppearanceMode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -412,6 +440,31 @@ func setUploadErrLog(c *gin.Context) {
 	time.Sleep(time.Second * 3)
 }
 
+func setDataWatcher(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	enabled, ok := arg["enabled"].(bool)
+	if !ok {
+		ret.Code = -1
+		ret.Msg = "invalid enabled"
+		return
+	}
+
+	model.Conf.System.DisableDataWatcher = !enabled
+	model.Conf.Save()
+
+	model.CloseWatchData()
+	if enabled {
+		model.WatchData()
+	}
+}
+
 func setAutoLaunch(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -464,6 +517,134 @@ func setNetworkProxy(c *gin.Context) {
 	util.PushMsg(model.Conf.Language(102), 3000)
 }
 
+func setNetworkTLS(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	networkTLS := conf.NewNetworkTLS()
+	if err = gulu.JSON.UnmarshalJSON(param, networkTLS); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	// DNS hook 会被内核直接执行，只能通过配置文件或者环境变量 SIYUAN_ACME_DNS_HOOK 设置
+	networkTLS.DNSHook = model.Conf.System.NetworkTLS.DNSHook
+
+	var domains []string
+	for _, domain := range networkTLS.Domains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); "" != domain && !gulu.Str.Contains(domain, domains) {
+			domains = append(domains, domain)
+		}
+	}
+	networkTLS.Domains = domains
+	if nil == networkTLS.Domains {
+		networkTLS.Domains = []string{}
+	}
+	switch networkTLS.Challenge {
+	case conf.ACMEChallengeHTTP01:
+		if 1 > networkTLS.HTTPPort {
+			networkTLS.HTTPPort = 80
+		}
+	case conf.ACMEChallengeDNS01:
+		if networkTLS.Enabled {
+			if err = model.CheckACMEDNSHook(model.GetACMEDNSHook()); nil != err {
+				ret.Code = -1
+				ret.Msg = err.Error()
+				return
+			}
+		}
+	default:
+		ret.Code = -1
+		ret.Msg = "unsupported ACME challenge [" + networkTLS.Challenge + "]"
+		return
+	}
+	if networkTLS.Enabled && 1 > len(networkTLS.Domains) {
+		ret.Code = -1
+		ret.Msg = "domains are required"
+		return
+	}
+	if 1 > networkTLS.HTTPSPort || 65535 < networkTLS.HTTPSPort {
+		networkTLS.HTTPSPort = 443
+	}
+	if 0 > networkTLS.DNSWait {
+		networkTLS.DNSWait = 0
+	}
+
+	model.Conf.System.NetworkTLS = networkTLS
+	model.Conf.Save()
+
+	ret.Data = networkTLS
+	util.PushMsg(model.Conf.Language(42), 1000*15)
+}
+
+func renewNetworkTLSCert(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	if err := model.ObtainNetworkTLSCert(); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+	ret.Data = model.GetNetworkTLSStatus()
+}
+
+func setNetworkACL(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	networkACL := conf.NewNetworkACL()
+	if err = gulu.JSON.UnmarshalJSON(param, networkACL); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	networkACL.Allow = trimNetworkACLItems(networkACL.Allow)
+	networkACL.Deny = trimNetworkACLItems(networkACL.Deny)
+	networkACL.TrustedProxies = trimNetworkACLItems(networkACL.TrustedProxies)
+
+	if err = model.SetNetworkACL(c, networkACL); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = networkACL
+}
+
+func trimNetworkACLItems(items []string) (ret []string) {
+	ret = []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); "" != item && !gulu.Str.Contains(item, ret) {
+			ret = append(ret, item)
+		}
+	}
+	return
+}
+
 func addUIProcess(c *gin.Context) {
 	pid := c.Query("pid")
 	util.UIProcessIDs.Store(pid, true)
@@ -502,3 +683,139 @@ func exit(c *gin.Context) {
 		ret.Data = map[string]interface{}{"closeTimeout": 0}
 	}
 }
+
+func getAuthSessions(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can list sessions"
+		return
+	}
+
+	ret.Data = model.GetAuthSessions(c)
+}
+
+func revokeAuthSession(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can revoke sessions"
+		return
+	}
+
+	id := arg["id"].(string)
+	if err := model.RevokeAuthSession(id); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func setupTOTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can set up TOTP"
+		return
+	}
+
+	secret, uri := model.SetupTOTP()
+	ret.Data = map[string]interface{}{
+		"secret": secret,
+		"uri":    uri,
+	}
+}
+
+func enableTOTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can enable TOTP"
+		return
+	}
+
+	code := arg["code"].(string)
+	enforce, _ := arg["enforce"].(string)
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	recoveryCodes, err := model.EnableTOTP(workspaceSession, code, enforce)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if err = session.Save(c); nil != err {
+		logging.LogErrorf("save session failed: " + err.Error())
+	}
+	ret.Data = map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	}
+}
+
+func disableTOTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can disable TOTP"
+		return
+	}
+
+	code := arg["code"].(string)
+	if err := model.DisableTOTP(code); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func regenerateTOTPRecoveryCodes(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	if "" != model.GetRole(c) {
+		ret.Code = -1
+		ret.Msg = "only administrator can regenerate TOTP recovery codes"
+		return
+	}
+
+	code := arg["code"].(string)
+	recoveryCodes, err := model.RegenerateTOTPRecoveryCodes(code)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	}
+}
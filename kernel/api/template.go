@@ -85,7 +85,12 @@ func renderTemplate(c *gin.Context) {
 		preview = previewArg.(bool)
 	}
 
-	_, content, err := model.RenderTemplate(p, id, preview)
+	var vars map[string]interface{}
+	if varsArg := arg["vars"]; nil != varsArg {
+		vars = varsArg.(map[string]interface{})
+	}
+
+	_, content, err := model.RenderTemplate(p, id, preview, vars)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = util.EscapeHTML(err.Error())
@@ -97,3 +102,152 @@ func renderTemplate(c *gin.Context) {
 		"content": content,
 	}
 }
+
+func dryRunTemplate(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	var p, content string
+	if nil != arg["path"] {
+		p = arg["path"].(string)
+	}
+	if nil != arg["content"] {
+		content = arg["content"].(string)
+	}
+
+	var vars map[string]interface{}
+	if varsArg := arg["vars"]; nil != varsArg {
+		vars = varsArg.(map[string]interface{})
+	}
+
+	result, err := model.DryRunTemplate(p, content, id, vars)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = util.EscapeHTML(err.Error())
+		return
+	}
+	ret.Data = result
+}
+
+func getTemplateVarSchema(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	p := arg["path"].(string)
+	vars, err := model.GetTemplateVarSchema(p)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = util.EscapeHTML(err.Error())
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"path": p,
+		"vars": vars,
+	}
+}
+
+func getTemplateSchedules(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetTemplateSchedules()
+}
+
+func setTemplateSchedule(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	schedule := &model.TemplateSchedule{}
+	if err = gulu.JSON.UnmarshalJSON(param, schedule); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	schedule, err = model.SetTemplateSchedule(schedule)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = util.EscapeHTML(err.Error())
+		return
+	}
+	ret.Data = schedule
+}
+
+func removeTemplateSchedule(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if err := model.RemoveTemplateSchedule(id); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func runTemplateSchedule(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	run, err := model.RunTemplateSchedule(id)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = run
+}
+
+func getTemplateScheduleRuns(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var id string
+	if nil != arg["id"] {
+		id = arg["id"].(string)
+	}
+	ret.Data = model.GetTemplateScheduleRuns(id)
+}
@@ -60,16 +60,31 @@ func performTransactions(c *gin.Context) {
 		ret.Msg = "parses request failed"
 		return
 	}
+	if err = model.CheckTransactionsAccess(transactions, model.GetRole(c)); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	app := arg["app"].(string)
+	session := arg["session"].(string)
+	collab := false
 	for _, transaction := range transactions {
 		transaction.Timestamp = timestamp
+		transaction.SetSession(session)
+		collab = collab || "" != transaction.RootID
 	}
 
 	model.PerformTransactions(&transactions)
+	if collab {
+		// 协同编辑时需要等待事务变换和提交完成，以便返回修订号和冲突
+		for _, transaction := range transactions {
+			transaction.WaitForFlush()
+		}
+	}
 
 	ret.Data = transactions
 
-	app := arg["app"].(string)
-	session := arg["session"].(string)
 	pushTransactions(app, session, transactions)
 
 	if model.IsFoldHeading(&transactions) || model.IsUnfoldHeading(&transactions) || model.IsMoveOutlineHeading(&transactions) {
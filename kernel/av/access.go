@@ -0,0 +1,160 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"errors"
+
+	"github.com/88250/gulu"
+)
+
+// Access 描述了列或视图的访问限制，按照 API token 的角色生效，角色为空时（访问授权码或主 API token）不受限制。
+type Access struct {
+	Hidden   []string `json:"hidden,omitempty"`   // 对这些角色隐藏，隐藏时也不可写
+	Readonly []string `json:"readonly,omitempty"` // 对这些角色只读
+}
+
+var ErrAccessDenied = errors.New("access denied")
+
+func (access *Access) IsHidden(role string) bool {
+	if nil == access || "" == role {
+		return false
+	}
+	return gulu.Str.Contains(role, access.Hidden)
+}
+
+// HasHidden 判断是否对某些角色隐藏。
+func (access *Access) HasHidden() bool {
+	return nil != access && 0 < len(access.Hidden)
+}
+
+func (access *Access) IsReadonly(role string) bool {
+	if nil == access || "" == role {
+		return false
+	}
+	return gulu.Str.Contains(role, access.Readonly) || gulu.Str.Contains(role, access.Hidden)
+}
+
+// IsViewHidden 判断视图是否对该角色隐藏，viewID 为空时判断当前视图。
+func (av *AttributeView) IsViewHidden(viewID, role string) bool {
+	if "" == role {
+		return false
+	}
+
+	if "" == viewID {
+		viewID = av.ViewID
+	}
+	view := av.GetView(viewID)
+	return nil != view && view.Access.IsHidden(role)
+}
+
+// IsKeyHidden 判断列是否对该角色隐藏。
+func (av *AttributeView) IsKeyHidden(keyID, role string) bool {
+	key, _ := av.GetKey(keyID)
+	return nil != key && key.Access.IsHidden(role)
+}
+
+// CheckWritable 检查该角色是否可以写入指定视图中的指定列，viewID 和 keyID 都可以为空。
+func (av *AttributeView) CheckWritable(viewID, keyID, role string) error {
+	if "" == role {
+		return nil
+	}
+
+	if "" != viewID {
+		if view := av.GetView(viewID); nil != view && view.Access.IsReadonly(role) {
+			return ErrAccessDenied
+		}
+	}
+	if "" != keyID {
+		if key, _ := av.GetKey(keyID); nil != key && key.Access.IsReadonly(role) {
+			return ErrAccessDenied
+		}
+	}
+	return nil
+}
+
+// CheckRowsWritable 检查该角色是否可以删除行，删除行会同时删除所有列的值，所以存在只读列时不可删除。
+func (av *AttributeView) CheckRowsWritable(role string) error {
+	if "" == role {
+		return nil
+	}
+
+	for _, kv := range av.KeyValues {
+		if kv.Key.Access.IsReadonly(role) {
+			return ErrAccessDenied
+		}
+	}
+	return nil
+}
+
+// RemoveHidden 移除对该角色隐藏的列和视图，用于返回给受限的调用方，移除后的属性视图不能保存。
+func (av *AttributeView) RemoveHidden(role string) {
+	if "" == role {
+		return
+	}
+
+	var keyValues []*KeyValues
+	for _, kv := range av.KeyValues {
+		if !kv.Key.Access.IsHidden(role) {
+			keyValues = append(keyValues, kv)
+		}
+	}
+	av.KeyValues = keyValues
+
+	var views []*View
+	for _, view := range av.Views {
+		if !view.Access.IsHidden(role) {
+			views = append(views, view)
+		}
+	}
+	av.Views = views
+}
+
+// RemoveHiddenColumns 移除表格中对该角色隐藏的列及其单元格。
+func (table *Table) RemoveHiddenColumns(attrView *AttributeView, role string) {
+	if "" == role {
+		return
+	}
+
+	var columns []*TableColumn
+	for _, column := range table.Columns {
+		if !attrView.IsKeyHidden(column.ID, role) {
+			columns = append(columns, column)
+		}
+	}
+	table.Columns = columns
+	table.Rows = RemoveHiddenCells(table.Rows, attrView, role)
+}
+
+// RemoveHiddenCells 移除行中对该角色隐藏的列的单元格。
+func RemoveHiddenCells(rows []*TableRow, attrView *AttributeView, role string) []*TableRow {
+	if "" == role {
+		return rows
+	}
+
+	for _, row := range rows {
+		var cells []*TableCell
+		for _, cell := range row.Cells {
+			if nil != cell.Value && attrView.IsKeyHidden(cell.Value.KeyID, role) {
+				continue
+			}
+			cells = append(cells, cell)
+		}
+		row.Cells = cells
+	}
+	return rows
+}
@@ -0,0 +1,95 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+// Automation 描述了属性视图自动化规则的结构：当触发条件满足时依次执行动作。
+type Automation struct {
+	ID      string              `json:"id"`      // 规则 ID
+	Name    string              `json:"name"`    // 规则名称
+	Enabled bool                `json:"enabled"` // 是否启用
+	Trigger *AutomationTrigger  `json:"trigger"` // 触发条件
+	Actions []*AutomationAction `json:"actions"` // 动作列表
+}
+
+// AutomationTrigger 描述了自动化规则的触发条件。
+//
+// 仅在列值从不满足条件变为满足条件时触发，Operator 为空时列值任意变化都会触发。
+type AutomationTrigger struct {
+	KeyID    string         `json:"keyID"`    // 监听的列 ID
+	Operator FilterOperator `json:"operator"` // 条件运算符，和过滤器一致
+	Value    *Value         `json:"value"`    // 条件值
+}
+
+type AutomationActionType string
+
+const (
+	AutomationActionSetValue AutomationActionType = "setValue" // 设置其他列的值
+	AutomationActionAddTag   AutomationActionType = "addTag"   // 为绑定块添加标签
+	AutomationActionMoveDoc  AutomationActionType = "moveDoc"  // 移动绑定块所在文档
+	AutomationActionWebhook  AutomationActionType = "webhook"  // 调用 Webhook
+)
+
+// AutomationAction 描述了自动化规则的动作。
+type AutomationAction struct {
+	Type AutomationActionType `json:"type"` // 动作类型
+
+	// 设置列值
+	KeyID string `json:"keyID,omitempty"` // 目标列 ID
+	Value *Value `json:"value,omitempty"` // 目标值
+
+	// 添加标签
+	Tag string `json:"tag,omitempty"` // 标签
+
+	// 移动文档
+	Notebook string `json:"notebook,omitempty"` // 目标笔记本 ID
+	ParentID string `json:"parentID,omitempty"` // 目标父文档 ID，为空时移动到笔记本根目录
+
+	// Webhook
+	URL string `json:"url,omitempty"` // 请求地址，使用 POST 发送 JSON
+}
+
+// GetTriggeredAutomations 返回列值变化后需要执行的自动化规则。
+func (av *AttributeView) GetTriggeredAutomations(keyID, rowID string, oldVal, newVal *Value) (ret []*Automation) {
+	if nil == newVal {
+		return
+	}
+
+	for _, automation := range av.Automations {
+		if !automation.Enabled || nil == automation.Trigger || keyID != automation.Trigger.KeyID || 1 > len(automation.Actions) {
+			continue
+		}
+
+		trigger := automation.Trigger
+		if "" == trigger.Operator {
+			if nil == oldVal || oldVal.String(false) != newVal.String(false) {
+				ret = append(ret, automation)
+			}
+			continue
+		}
+
+		filter := &ViewFilter{Column: keyID, Operator: trigger.Operator, Value: trigger.Value}
+		if !newVal.Filter(filter, av, rowID) {
+			continue
+		}
+		if nil != oldVal && oldVal.Filter(filter, av, rowID) {
+			// 之前已经满足条件，不重复触发
+			continue
+		}
+		ret = append(ret, automation)
+	}
+	return
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -44,6 +45,9 @@ type AttributeView struct {
 	KeyIDs    []string     `json:"keyIDs"`    // 属性视图属性键 ID，用于排序
 	ViewID    string       `json:"viewID"`    // 当前视图 ID
 	Views     []*View      `json:"views"`     // 视图
+
+	Automations []*Automation `json:"automations,omitempty"` // 自动化规则
+	DataSource  *DataSource   `json:"dataSource,omitempty"`  // 外部数据源
 }
 
 // KeyValues 描述了属性视图属性列值的结构。
@@ -82,6 +86,10 @@ const (
 	KeyTypeRelation   KeyType = "relation"
 	KeyTypeRollup     KeyType = "rollup"
 	KeyTypeLineNumber KeyType = "lineNumber"
+	KeyTypeRating     KeyType = "rating"
+	KeyTypeProgress   KeyType = "progress"
+	KeyTypeCurrency   KeyType = "currency"
+	KeyTypeDuration   KeyType = "duration"
 )
 
 // Key 描述了属性视图属性列的基础结构。
@@ -110,6 +118,18 @@ type Key struct {
 
 	// 日期
 	Date *Date `json:"date,omitempty"` // 日期设置
+
+	// 评分
+	Rating *Rating `json:"rating,omitempty"` // 评分设置
+
+	// 货币
+	Currency *Currency `json:"currency,omitempty"` // 货币设置
+
+	// 时长
+	Duration *Duration `json:"duration,omitempty"` // 时长设置
+
+	Validation *Validation `json:"validation,omitempty"` // 校验规则
+	Access     *Access     `json:"access,omitempty"`     // 访问限制
 }
 
 func NewKey(id, name, icon string, keyType KeyType) *Key {
@@ -135,6 +155,43 @@ type Date struct {
 	AutoFillNow bool `json:"autoFillNow"` // 是否自动填充当前时间 The database date field supports filling the current time by default https://github.com/siyuan-note/siyuan/issues/10823
 }
 
+type Rating struct {
+	Max int `json:"max"` // 最大评分
+}
+
+const DefaultRatingMax = 5
+
+func (rating *Rating) GetMax() int {
+	if nil == rating || 1 > rating.Max {
+		return DefaultRatingMax
+	}
+	return rating.Max
+}
+
+type Currency struct {
+	Code string `json:"code"` // ISO 4217 货币代码，比如 USD、CNY
+}
+
+const DefaultCurrencyCode = "USD"
+
+func (currency *Currency) GetCode() string {
+	if nil == currency || "" == currency.Code {
+		return DefaultCurrencyCode
+	}
+	return currency.Code
+}
+
+type Duration struct {
+	Format DurationFormat `json:"format"` // 时长格式
+}
+
+func (duration *Duration) GetFormat() DurationFormat {
+	if nil == duration {
+		return DurationFormatNone
+	}
+	return duration.Format
+}
+
 type Rollup struct {
 	RelationKeyID string      `json:"relationKeyID"` // 关联列 ID
 	KeyID         string      `json:"keyID"`         // 目标列 ID
@@ -164,15 +221,26 @@ type View struct {
 	Name             string `json:"name"`             // 视图名称
 	HideAttrViewName bool   `json:"hideAttrViewName"` // 是否隐藏属性视图名称
 
-	LayoutType LayoutType   `json:"type"`            // 当前布局类型
-	Table      *LayoutTable `json:"table,omitempty"` // 表格布局
+	LayoutType LayoutType     `json:"type"`              // 当前布局类型
+	Table      *LayoutTable   `json:"table,omitempty"`   // 表格布局，卡片布局也使用其中的列、过滤、排序和分页设置
+	Gallery    *LayoutGallery `json:"gallery,omitempty"` // 卡片布局
+
+	RowTemplate *RowTemplate `json:"rowTemplate,omitempty"` // 新建行模板
+	Access      *Access      `json:"access,omitempty"`      // 访问限制
+}
+
+// RowTemplate 描述了视图新建行时使用的模板。
+type RowTemplate struct {
+	Values  []*Value `json:"values"`  // 预填的列值，通过 Value.KeyID 指定列
+	Content string   `json:"content"` // 新建绑定块时填充的内容模板，使用 .action{} 作为模板动作分隔符
 }
 
 // LayoutType 描述了视图布局的类型。
 type LayoutType string
 
 const (
-	LayoutTypeTable LayoutType = "table" // 属性视图类型 - 表格
+	LayoutTypeTable   LayoutType = "table"   // 属性视图类型 - 表格
+	LayoutTypeGallery LayoutType = "gallery" // 属性视图类型 - 卡片
 )
 
 func NewTableView() (ret *View) {
@@ -191,6 +259,14 @@ func NewTableView() (ret *View) {
 	return
 }
 
+func NewGalleryView() (ret *View) {
+	ret = NewTableView()
+	ret.Name = getI18nName("gallery")
+	ret.LayoutType = LayoutTypeGallery
+	ret.Gallery = NewLayoutGallery()
+	return
+}
+
 func NewTableViewWithBlockKey(blockKeyID string) (view *View, blockKey, selectKey *Key) {
 	name := getI18nName("table")
 	view = &View{
@@ -419,7 +495,7 @@ func SaveAttributeView(av *AttributeView) (err error) {
 
 				for _, view := range av.Views {
 					switch view.LayoutType {
-					case LayoutTypeTable:
+					case LayoutTypeTable, LayoutTypeGallery:
 						for _, column := range view.Table.Columns {
 							if "" == column.ID {
 								column.ID = kv.Key.ID
@@ -457,7 +533,7 @@ func SaveAttributeView(av *AttributeView) (err error) {
 	// 补全过滤器 Value
 	for _, view := range av.Views {
 		if nil != view.Table {
-			for _, f := range view.Table.Filters {
+			for _, f := range view.Table.GetAllFilters() {
 				if nil != f.Value {
 					continue
 				}
@@ -516,6 +592,8 @@ func SaveAttributeView(av *AttributeView) (err error) {
 		logging.LogErrorf("save attribute view [%s] failed: %s", av.ID, err)
 		return
 	}
+
+	eventbus.Publish(util.EvtAttributeViewSaved, av.ID)
 	return
 }
 
@@ -672,12 +750,36 @@ func (av *AttributeView) ShallowClone() (ret *AttributeView) {
 		}
 		view.Table.RowIDs = []string{}
 
-		for _, f := range view.Table.Filters {
+		for _, f := range view.Table.GetAllFilters() {
 			f.Column = keyIDMap[f.Column]
 		}
 		for _, s := range view.Table.Sorts {
 			s.Column = keyIDMap[s.Column]
 		}
+		if nil != view.RowTemplate {
+			for _, v := range view.RowTemplate.Values {
+				v.KeyID = keyIDMap[v.KeyID]
+			}
+		}
+	}
+	for _, automation := range ret.Automations {
+		if nil != automation.Trigger {
+			automation.Trigger.KeyID = keyIDMap[automation.Trigger.KeyID]
+		}
+		for _, action := range automation.Actions {
+			if "" != action.KeyID {
+				action.KeyID = keyIDMap[action.KeyID]
+			}
+		}
+	}
+	if nil != ret.DataSource {
+		// 克隆的属性视图没有行，需要重新同步
+		for _, mapping := range ret.DataSource.Mappings {
+			mapping.KeyID = keyIDMap[mapping.KeyID]
+		}
+		ret.DataSource.Rows = nil
+		ret.DataSource.EditedValues = nil
+		ret.DataSource.LastSynced = 0
 	}
 	ret.ViewID = ret.Views[0].ID
 	return
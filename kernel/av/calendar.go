@@ -0,0 +1,135 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"time"
+)
+
+// CalendarRange 描述了日历视图的查询范围类型。
+type CalendarRange string
+
+const (
+	CalendarRangeMonth CalendarRange = "month" // 按月
+	CalendarRangeWeek  CalendarRange = "week"  // 按周
+)
+
+// Calendar 描述了日历实例的结构。
+type Calendar struct {
+	Start int64          `json:"start"` // 范围开始时间（包含）
+	End   int64          `json:"end"`   // 范围结束时间（不包含）
+	Days  []*CalendarDay `json:"days"`  // 按天分组的行
+}
+
+// CalendarDay 描述了日历中某一天的结构。
+type CalendarDay struct {
+	Date  string          `json:"date"`  // 日期，格式为 2006-01-02
+	Items []*CalendarItem `json:"items"` // 当天的行
+}
+
+// CalendarItem 描述了日历中某一行的结构。
+type CalendarItem struct {
+	ID        string    `json:"id"`        // 行 ID
+	Content   string    `json:"content"`   // 主键内容
+	Start     int64     `json:"start"`     // 开始时间
+	End       int64     `json:"end"`       // 结束时间，没有结束时间时为 0
+	IsNotTime bool      `json:"isNotTime"` // 是否不包含时间
+	IsFirst   bool      `json:"isFirst"`   // 是否是跨天范围的第一天
+	Row       *TableRow `json:"row"`       // 行数据
+}
+
+// GetCalendarRange 根据锚点时间计算日历的查询范围，weekStart 为一周的开始（0 为周日，1 为周一），超出 0 到 6 时按周日处理。
+func GetCalendarRange(anchor time.Time, rangeType CalendarRange, weekStart int) (start, end time.Time) {
+	if 0 > weekStart || 6 < weekStart {
+		weekStart = 0
+	}
+
+	anchor = beginOfDay(anchor)
+	switch rangeType {
+	case CalendarRangeWeek:
+		offset := (int(anchor.Weekday()) - weekStart + 7) % 7
+		start = anchor.AddDate(0, 0, -offset)
+		end = start.AddDate(0, 0, 7)
+	default:
+		start = time.Date(anchor.Year(), anchor.Month(), 1, 0, 0, 0, 0, anchor.Location())
+		end = start.AddDate(0, 1, 0)
+	}
+	return
+}
+
+// BuildCalendar 将表格行按日期列分组到 [start, end) 范围内的每一天。
+//
+// endKeyID 为空时使用日期列自身的结束时间作为范围结束，否则使用 endKeyID 对应日期列的开始时间。
+func (table *Table) BuildCalendar(keyID, endKeyID string, start, end time.Time) (ret *Calendar) {
+	ret = &Calendar{Start: start.UnixMilli(), End: end.UnixMilli(), Days: []*CalendarDay{}}
+	days := map[string]*CalendarDay{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		calendarDay := &CalendarDay{Date: day.Format("2006-01-02"), Items: []*CalendarItem{}}
+		days[calendarDay.Date] = calendarDay
+		ret.Days = append(ret.Days, calendarDay)
+	}
+
+	for _, row := range table.Rows {
+		date := row.GetValue(keyID)
+		if nil == date || nil == date.Date || !date.Date.IsNotEmpty {
+			continue
+		}
+
+		item := &CalendarItem{ID: row.ID, Start: date.Date.Content, IsNotTime: date.Date.IsNotTime, Row: row}
+		if block := row.GetBlockValue(); nil != block && nil != block.Block {
+			item.Content = block.Block.Content
+		}
+
+		if "" == endKeyID {
+			if date.Date.HasEndDate && date.Date.IsNotEmpty2 {
+				item.End = date.Date.Content2
+			}
+		} else if endDate := row.GetValue(endKeyID); nil != endDate && nil != endDate.Date && endDate.Date.IsNotEmpty {
+			item.End = endDate.Date.Content
+		}
+		if item.End < item.Start {
+			item.End = 0
+		}
+
+		first := beginOfDay(time.UnixMilli(item.Start))
+		last := first
+		if 0 < item.End {
+			last = beginOfDay(time.UnixMilli(item.End))
+		}
+		if !last.Before(start) && first.Before(end) {
+			from := first
+			if from.Before(start) {
+				from = start
+			}
+			for day := from; !day.After(last) && day.Before(end); day = day.AddDate(0, 0, 1) {
+				calendarDay := days[day.Format("2006-01-02")]
+				if nil == calendarDay {
+					continue
+				}
+
+				dayItem := *item
+				dayItem.IsFirst = day.Equal(first)
+				calendarDay.Items = append(calendarDay.Items, &dayItem)
+			}
+		}
+	}
+	return
+}
+
+func beginOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
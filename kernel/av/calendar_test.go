@@ -0,0 +1,48 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetCalendarRangeWeekStart(t *testing.T) {
+	anchor := time.Date(2026, 10, 16, 15, 4, 5, 0, time.UTC) // 周五
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	sunday := time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		weekStart int
+		start     time.Time
+	}{
+		{0, sunday},
+		{1, monday},
+		{-1, sunday},
+		{7, sunday},
+		{-100, sunday},
+	}
+	for _, c := range cases {
+		start, end := GetCalendarRange(anchor, CalendarRangeWeek, c.weekStart)
+		if !start.Equal(c.start) {
+			t.Errorf("weekStart [%d] start = %s, want %s", c.weekStart, start, c.start)
+		}
+		if !end.Equal(c.start.AddDate(0, 0, 7)) {
+			t.Errorf("weekStart [%d] end = %s, want %s", c.weekStart, end, c.start.AddDate(0, 0, 7))
+		}
+	}
+}
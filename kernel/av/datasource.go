@@ -0,0 +1,159 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/araddon/dateparse"
+)
+
+type DataSourceType string
+
+const (
+	DataSourceTypeCSV      DataSourceType = "csv"      // CSV 文件地址
+	DataSourceTypeJSON     DataSourceType = "json"     // 返回 JSON 数组的 API
+	DataSourceTypeAirtable DataSourceType = "airtable" // Airtable 数据表
+)
+
+// DataSource 描述了属性视图绑定的外部数据源。
+//
+// 同步时按 KeyField 字段的值匹配行，不存在的行新建为游离行，存在的行更新映射列的值。本地编辑过的值不会被覆盖。
+type DataSource struct {
+	Type    DataSourceType    `json:"type"`              // 数据源类型
+	URL     string            `json:"url,omitempty"`     // CSV/JSON 地址
+	Headers map[string]string `json:"headers,omitempty"` // 请求头，仅用于设置数据源时传入，保存在配置中
+	Path    string            `json:"path,omitempty"`    // JSON 中记录数组的路径，使用 . 分隔，为空时使用根节点
+
+	AirtableBaseID string `json:"airtableBaseID,omitempty"` // Airtable Base ID
+	AirtableTable  string `json:"airtableTable,omitempty"`  // Airtable 数据表名称或 ID
+	AirtableToken  string `json:"airtableToken,omitempty"`  // Airtable 访问令牌，仅用于设置数据源时传入，保存在配置中
+
+	KeyField string               `json:"keyField"` // 用于匹配行的外部字段
+	Mappings []*DataSourceMapping `json:"mappings"` // 外部字段到列的映射
+	Interval int                  `json:"interval"` // 自动刷新间隔（分钟），为 0 时不自动刷新
+
+	LastSynced   int64             `json:"lastSynced"`             // 上次同步时间
+	LastError    string            `json:"lastError,omitempty"`    // 上次同步错误
+	Rows         map[string]string `json:"rows,omitempty"`         // 外部键值到行 ID 的映射
+	EditedValues []string          `json:"editedValues,omitempty"` // 本地编辑过的值 ID
+}
+
+// DataSourceMapping 描述了外部字段到属性视图列的映射。
+type DataSourceMapping struct {
+	Field string `json:"field"` // 外部字段名
+	KeyID string `json:"keyID"` // 列 ID
+}
+
+// IsDue 判断数据源是否到了自动刷新的时间。
+func (ds *DataSource) IsDue(now time.Time) bool {
+	if 1 > ds.Interval {
+		return false
+	}
+	return now.Sub(time.UnixMilli(ds.LastSynced)) >= time.Duration(ds.Interval)*time.Minute
+}
+
+// MarkEdited 将值标记为本地编辑过，后续同步不再覆盖。
+func (ds *DataSource) MarkEdited(valueID string) {
+	if "" == valueID || ds.IsEdited(valueID) {
+		return
+	}
+	ds.EditedValues = append(ds.EditedValues, valueID)
+}
+
+func (ds *DataSource) IsEdited(valueID string) bool {
+	return gulu.Str.Contains(valueID, ds.EditedValues)
+}
+
+// IsMapped 判断列是否映射了外部字段。
+func (ds *DataSource) IsMapped(keyID string) bool {
+	for _, mapping := range ds.Mappings {
+		if mapping.KeyID == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// SetValueByString 使用外部数据源的字符串内容设置值，不支持的列类型返回 false。
+func (value *Value) SetValueByString(key *Key, content string) bool {
+	content = strings.TrimSpace(content)
+	value.Type = key.Type
+	switch key.Type {
+	case KeyTypeBlock:
+		value.Block = &ValueBlock{ID: value.BlockID, Content: content}
+	case KeyTypeText:
+		value.Text = &ValueText{Content: content}
+	case KeyTypeNumber:
+		number, err := strconv.ParseFloat(content, 64)
+		if nil != err {
+			value.Number = &ValueNumber{Format: key.NumberFormat}
+			break
+		}
+		value.Number = NewFormattedValueNumber(number, key.NumberFormat)
+	case KeyTypeDate:
+		t, err := dateparse.ParseIn(content, time.Local)
+		if nil != err {
+			value.Date = &ValueDate{}
+			break
+		}
+		value.Date = NewFormattedValueDate(t.UnixMilli(), 0, DateFormatNone, false, false)
+	case KeyTypeSelect, KeyTypeMSelect:
+		value.MSelect = []*ValueSelect{}
+		for _, name := range strings.Split(content, ",") {
+			name = strings.TrimSpace(name)
+			if "" == name {
+				continue
+			}
+
+			valSelect := &ValueSelect{Content: name, Color: "1"}
+			if opt := key.GetOption(name); nil != opt {
+				valSelect.Color = opt.Color
+			}
+			value.MSelect = append(value.MSelect, valSelect)
+			if KeyTypeSelect == key.Type {
+				break
+			}
+		}
+	case KeyTypeURL:
+		value.URL = &ValueURL{Content: content}
+	case KeyTypeEmail:
+		value.Email = &ValueEmail{Content: content}
+	case KeyTypePhone:
+		value.Phone = &ValuePhone{Content: content}
+	case KeyTypeCheckbox:
+		switch strings.ToLower(content) {
+		case "true", "1", "yes", "y", "√", "✓", "checked":
+			value.Checkbox = &ValueCheckbox{Checked: true}
+		default:
+			value.Checkbox = &ValueCheckbox{}
+		}
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		number, err := strconv.ParseFloat(strings.TrimSuffix(content, "%"), 64)
+		if KeyTypeCurrency == key.Type && nil == value.Currency {
+			value.Currency = &ValueCurrency{Code: key.Currency.GetCode()}
+		}
+		value.setMeasure(number, nil == err && "" != content)
+		value.FormatMeasure(key)
+	default:
+		return false
+	}
+	return true
+}
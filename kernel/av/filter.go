@@ -36,6 +36,162 @@ type ViewFilter struct {
 	RelativeDate2 *RelativeDate  `json:"relativeDate2"`
 }
 
+// FilterGroupOperator 描述了过滤条件组内条件的组合方式。
+type FilterGroupOperator string
+
+const (
+	FilterGroupOperatorAnd FilterGroupOperator = "and" // 满足全部条件
+	FilterGroupOperatorOr  FilterGroupOperator = "or"  // 满足任一条件
+)
+
+// ViewFilterGroup 描述了可嵌套的过滤条件组。
+type ViewFilterGroup struct {
+	Operator FilterGroupOperator `json:"operator"`         // 组合方式
+	Not      bool                `json:"not"`              // 是否对组的结果取反
+	Filters  []*ViewFilter       `json:"filters"`          // 组内过滤条件
+	Groups   []*ViewFilterGroup  `json:"groups,omitempty"` // 嵌套的条件组
+}
+
+// GetFilters 返回条件组及其嵌套组中的所有过滤条件。
+func (group *ViewFilterGroup) GetFilters() (ret []*ViewFilter) {
+	if nil == group {
+		return
+	}
+
+	ret = append(ret, group.Filters...)
+	for _, g := range group.Groups {
+		ret = append(ret, g.GetFilters()...)
+	}
+	return
+}
+
+// RemoveFilters 移除条件组及其嵌套组中满足 fn 的过滤条件，移除后为空的嵌套组也会被移除。
+func (group *ViewFilterGroup) RemoveFilters(fn func(filter *ViewFilter) bool) {
+	if nil == group {
+		return
+	}
+
+	filters := []*ViewFilter{}
+	for _, f := range group.Filters {
+		if !fn(f) {
+			filters = append(filters, f)
+		}
+	}
+	group.Filters = filters
+
+	var groups []*ViewFilterGroup
+	for _, g := range group.Groups {
+		g.RemoveFilters(fn)
+		if 0 < len(g.Filters) || 0 < len(g.Groups) {
+			groups = append(groups, g)
+		}
+	}
+	group.Groups = groups
+}
+
+// match 判断行是否满足条件组，colIndexes 为列 ID 到列下标的映射。
+//
+// 无效的过滤条件（比如列已经被删除或者没有过滤值）会被跳过，没有有效过滤条件的组不参与判断，顶层组没有有效过滤条件时匹配所有行。
+func (group *ViewFilterGroup) match(row *TableRow, colIndexes map[string]int, attrView *AttributeView) (ret bool) {
+	if !group.hasValidFilter(row, colIndexes) {
+		return true
+	}
+
+	isOr := FilterGroupOperatorOr == group.Operator
+	ret = !isOr
+	for _, f := range group.Filters {
+		if !isValidFilter(f, row, colIndexes) {
+			continue
+		}
+
+		pass := row.passFilter(colIndexes[f.Column], f, attrView)
+		if isOr && pass {
+			ret = true
+			break
+		}
+		if !isOr && !pass {
+			ret = false
+			break
+		}
+	}
+
+	if ret == !isOr {
+		// 组内条件还不能决定结果时继续判断嵌套组
+		for _, g := range group.Groups {
+			if !g.hasValidFilter(row, colIndexes) {
+				continue
+			}
+
+			pass := g.match(row, colIndexes, attrView)
+			if isOr && pass {
+				ret = true
+				break
+			}
+			if !isOr && !pass {
+				ret = false
+				break
+			}
+		}
+	}
+
+	if group.Not {
+		ret = !ret
+	}
+	return
+}
+
+// hasValidFilter 判断条件组及其嵌套组中是否存在有效的过滤条件。
+func (group *ViewFilterGroup) hasValidFilter(row *TableRow, colIndexes map[string]int) bool {
+	if nil == group {
+		return false
+	}
+
+	for _, f := range group.Filters {
+		if isValidFilter(f, row, colIndexes) {
+			return true
+		}
+	}
+	for _, g := range group.Groups {
+		if g.hasValidFilter(row, colIndexes) {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidFilter 判断过滤条件的列是否存在，以及过滤条件是否带有过滤值。
+func isValidFilter(filter *ViewFilter, row *TableRow, colIndexes map[string]int) bool {
+	if nil == filter {
+		return false
+	}
+
+	index, ok := colIndexes[filter.Column]
+	if !ok || 0 > index || len(row.Cells) <= index {
+		return false
+	}
+
+	switch filter.Operator {
+	case FilterOperatorIsEmpty, FilterOperatorIsNotEmpty:
+		return true
+	}
+	return nil != filter.Value || nil != filter.RelativeDate
+}
+
+// passFilter 判断行的某列是否满足过滤条件。
+func (row *TableRow) passFilter(index int, filter *ViewFilter, attrView *AttributeView) bool {
+	cell := row.Cells[index]
+	if nil == cell.Value {
+		switch filter.Operator {
+		case FilterOperatorIsNotEmpty:
+			return false
+		case FilterOperatorIsEmpty:
+			return true
+		}
+		return KeyTypeText == cell.ValueType
+	}
+	return cell.Value.Filter(filter, attrView, row.ID)
+}
+
 type RelativeDateUnit int
 
 const (
@@ -127,6 +283,13 @@ func (value *Value) Filter(filter *ViewFilter, attrView *AttributeView, rowID st
 			return false
 		}
 
+		if nil != key.Rollup.Calc && CalcOperatorNone != key.Rollup.Calc.Operator && 1 == len(value.Rollup.Contents) {
+			// 设置了计算方式时使用计算结果进行过滤
+			if calcVal := value.Rollup.Contents[0]; calcVal.Type == filter.Value.Rollup.Contents[0].Type {
+				return calcVal.filter(filter.Value.Rollup.Contents[0], filter.RelativeDate, filter.RelativeDate2, filter.Operator)
+			}
+		}
+
 		for _, blockID := range relVal.Relation.BlockIDs {
 			destVal := destAv.GetValue(key.Rollup.KeyID, blockID)
 			if nil == destVal {
@@ -298,6 +461,33 @@ func (value *Value) filter(other *Value, relativeDate, relativeDate2 *RelativeDa
 				return value.Number.IsNotEmpty
 			}
 		}
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		content, ok := value.Measure()
+		otherContent, otherOK := other.Measure()
+		switch operator {
+		case FilterOperatorIsEqual:
+			if !otherOK {
+				return true
+			}
+			return ok && content == otherContent
+		case FilterOperatorIsNotEqual:
+			if !otherOK {
+				return true
+			}
+			return !ok || content != otherContent
+		case FilterOperatorIsGreater:
+			return ok && content > otherContent
+		case FilterOperatorIsGreaterOrEqual:
+			return ok && content >= otherContent
+		case FilterOperatorIsLess:
+			return ok && content < otherContent
+		case FilterOperatorIsLessOrEqual:
+			return ok && content <= otherContent
+		case FilterOperatorIsEmpty:
+			return !ok
+		case FilterOperatorIsNotEmpty:
+			return ok
+		}
 	case KeyTypeDate:
 		if nil != value.Date {
 			switch operator {
@@ -934,6 +1124,26 @@ func (filter *ViewFilter) GetAffectValue(key *Key, defaultVal *Value) (ret *Valu
 			ret.MAsset = []*ValueAsset{}
 		case FilterOperatorIsNotEmpty:
 		}
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		content, _ := filter.Value.Measure()
+		switch filter.Operator {
+		case FilterOperatorIsEqual, FilterOperatorIsGreaterOrEqual, FilterOperatorIsLessOrEqual:
+			ret.setMeasure(content, true)
+		case FilterOperatorIsNotEqual:
+			if 0 == content {
+				ret.setMeasure(1, true)
+			} else {
+				ret.setMeasure(0, true)
+			}
+		case FilterOperatorIsGreater:
+			ret.setMeasure(content+1, true)
+		case FilterOperatorIsLess:
+			ret.setMeasure(content-1, true)
+		case FilterOperatorIsEmpty:
+			ret.setMeasure(0, false)
+		case FilterOperatorIsNotEmpty:
+			ret.setMeasure(0, true)
+		}
 	case KeyTypeCheckbox:
 		switch filter.Operator {
 		case FilterOperatorIsTrue:
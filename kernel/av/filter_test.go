@@ -0,0 +1,75 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import "testing"
+
+func TestViewFilterGroupMatch(t *testing.T) {
+	textVal := func(content string) *Value {
+		return &Value{Type: KeyTypeText, Text: &ValueText{Content: content}}
+	}
+	eq := func(column, content string) *ViewFilter {
+		return &ViewFilter{Column: column, Operator: FilterOperatorIsEqual, Value: textVal(content)}
+	}
+
+	row := &TableRow{ID: "row", Cells: []*TableCell{
+		{ValueType: KeyTypeText, Value: textVal("Done")},
+		{ValueType: KeyTypeText, Value: textVal("work")},
+	}}
+	colIndexes := map[string]int{"status": 0, "tag": 1}
+	missing := &ViewFilter{Column: "deleted", Operator: FilterOperatorIsEqual, Value: textVal("x")}
+	noValue := &ViewFilter{Column: "status", Operator: FilterOperatorIsEqual}
+
+	cases := []struct {
+		name     string
+		group    *ViewFilterGroup
+		expected bool
+	}{
+		{"and all pass", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done"), eq("tag", "work")}}, true},
+		{"and one fails", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done"), eq("tag", "home")}}, false},
+		{"or one passes", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("status", "Todo"), eq("tag", "work")}}, true},
+		{"or none passes", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("status", "Todo"), eq("tag", "home")}}, false},
+		{"and with nested or", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("tag", "home"), eq("tag", "work")}}}}, true},
+		{"and with failing nested or", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("tag", "home"), eq("tag", "life")}}}}, false},
+		{"or with nested and", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("status", "Todo")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done"), eq("tag", "work")}}}}, true},
+		{"deeply nested", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("status", "Todo")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done")},
+				Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("tag", "home")}}}}}}, false},
+		{"not group", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Not: true, Filters: []*ViewFilter{eq("status", "Done")}}, false},
+		{"nested not group", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorOr, Not: true, Filters: []*ViewFilter{eq("tag", "home")}}}}, true},
+		{"empty group", &ViewFilterGroup{Operator: FilterGroupOperatorOr}, true},
+		{"only invalid filters in or", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{missing, noValue, nil}}, true},
+		{"only invalid filters in not group", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Not: true, Filters: []*ViewFilter{missing}}, true},
+		{"invalid filter skipped in or", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{missing, eq("status", "Todo")}}, false},
+		{"invalid filter skipped in and", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{missing, eq("status", "Done")}}, true},
+		{"invalid nested group skipped in or", &ViewFilterGroup{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{eq("status", "Todo")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{missing}}}}, false},
+		{"invalid nested group skipped in and", &ViewFilterGroup{Operator: FilterGroupOperatorAnd, Filters: []*ViewFilter{eq("status", "Done")},
+			Groups: []*ViewFilterGroup{{Operator: FilterGroupOperatorOr, Filters: []*ViewFilter{missing}}}}, true},
+	}
+
+	attrView := &AttributeView{}
+	for _, c := range cases {
+		if ret := c.group.match(row, colIndexes, attrView); c.expected != ret {
+			t.Errorf("%s: expected [%v], got [%v]", c.name, c.expected, ret)
+		}
+	}
+}
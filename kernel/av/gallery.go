@@ -0,0 +1,177 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import "github.com/siyuan-note/siyuan/kernel/util"
+
+// CoverFrom 描述了卡片封面的来源。
+type CoverFrom int
+
+const (
+	CoverFromNone         CoverFrom = iota // 无封面
+	CoverFromContentImage                  // 绑定块内容中的第一张图片
+	CoverFromAssetField                    // 资源列中的第一张图片
+)
+
+// CardSize 描述了卡片的尺寸。
+type CardSize string
+
+const (
+	CardSizeSmall  CardSize = "small"  // 小
+	CardSizeMedium CardSize = "medium" // 中
+	CardSizeLarge  CardSize = "large"  // 大
+)
+
+// LayoutGallery 描述了卡片布局的设置。
+//
+// 卡片视图和表格视图共用 View.Table 中的列、过滤、排序和分页设置，这里仅保存卡片特有的展示设置。
+type LayoutGallery struct {
+	CoverFrom           CoverFrom `json:"coverFrom"`           // 封面来源
+	CoverFromAssetKeyID string    `json:"coverFromAssetKeyID"` // 封面来源为资源列时的列 ID
+	CardSize            CardSize  `json:"cardSize"`            // 卡片尺寸
+	FitImage            bool      `json:"fitImage"`            // 封面图片是否适应卡片（不裁剪）
+	WrapField           bool      `json:"wrapField"`           // 字段内容是否换行
+	FieldIDs            []string  `json:"fieldIDs"`            // 卡片上显示的列 ID，按显示顺序排列
+}
+
+func NewLayoutGallery() *LayoutGallery {
+	return &LayoutGallery{
+		CoverFrom: CoverFromContentImage,
+		CardSize:  CardSizeMedium,
+		FieldIDs:  []string{},
+	}
+}
+
+// GetCardSize 返回卡片尺寸，未设置或者设置无效时返回中等尺寸。
+func (gallery *LayoutGallery) GetCardSize() CardSize {
+	switch gallery.CardSize {
+	case CardSizeSmall, CardSizeMedium, CardSizeLarge:
+		return gallery.CardSize
+	}
+	return CardSizeMedium
+}
+
+// Gallery 描述了卡片实例的结构。
+type Gallery struct {
+	ID               string         `json:"id"`               // 卡片布局 ID
+	Icon             string         `json:"icon"`             // 视图图标
+	Name             string         `json:"name"`             // 视图名称
+	HideAttrViewName bool           `json:"hideAttrViewName"` // 是否隐藏属性视图名称
+	CoverFrom        CoverFrom      `json:"coverFrom"`        // 封面来源
+	CardSize         CardSize       `json:"cardSize"`         // 卡片尺寸
+	FitImage         bool           `json:"fitImage"`         // 封面图片是否适应卡片
+	WrapField        bool           `json:"wrapField"`        // 字段内容是否换行
+	Fields           []*TableColumn `json:"fields"`           // 卡片上显示的列
+	Cards            []*GalleryCard `json:"cards"`            // 卡片
+	CardCount        int            `json:"cardCount"`        // 卡片总数
+	PageSize         int            `json:"pageSize"`         // 每页卡片数
+}
+
+// GalleryCard 描述了卡片视图中某一行的结构。
+type GalleryCard struct {
+	ID         string       `json:"id"`         // 行 ID
+	BlockID    string       `json:"blockID"`    // 绑定块 ID，未绑定块时为空
+	IsDetached bool         `json:"isDetached"` // 是否未绑定块
+	Content    string       `json:"content"`    // 主键内容
+	CoverURL   string       `json:"coverURL"`   // 封面图片地址，没有封面时为空
+	CoverThumb string       `json:"coverThumb"` // 封面缩略图地址，无法生成缩略图时和 CoverURL 相同
+	Values     []*TableCell `json:"values"`     // 卡片上显示的字段值，和 Gallery.Fields 一一对应
+}
+
+// BuildGallery 将表格行转换为卡片，仅保留 layout.FieldIDs 中指定的字段。
+//
+// contentCover 用于获取绑定块内容中的第一张图片，仅在封面来源为 CoverFromContentImage 时调用。
+func (table *Table) BuildGallery(layout *LayoutGallery, contentCover func(blockIDs []string) map[string]string) (ret *Gallery) {
+	ret = &Gallery{
+		ID:               table.ID,
+		Icon:             table.Icon,
+		Name:             table.Name,
+		HideAttrViewName: table.HideAttrViewName,
+		CoverFrom:        layout.CoverFrom,
+		CardSize:         layout.GetCardSize(),
+		FitImage:         layout.FitImage,
+		WrapField:        layout.WrapField,
+		Fields:           []*TableColumn{},
+		Cards:            []*GalleryCard{},
+		CardCount:        table.RowCount,
+		PageSize:         table.PageSize,
+	}
+
+	var fieldIndexes []int
+	for _, fieldID := range layout.FieldIDs {
+		for i, col := range table.Columns {
+			if col.ID == fieldID {
+				ret.Fields = append(ret.Fields, col)
+				fieldIndexes = append(fieldIndexes, i)
+				break
+			}
+		}
+	}
+
+	var boundBlockIDs []string
+	for _, row := range table.Rows {
+		card := &GalleryCard{ID: row.ID, Values: []*TableCell{}}
+		if block := row.GetBlockValue(); nil != block {
+			card.IsDetached = block.IsDetached
+			if nil != block.Block {
+				card.Content = block.Block.Content
+			}
+			if !block.IsDetached {
+				card.BlockID = block.BlockID
+				boundBlockIDs = append(boundBlockIDs, block.BlockID)
+			}
+		}
+
+		for _, i := range fieldIndexes {
+			if i < len(row.Cells) {
+				card.Values = append(card.Values, row.Cells[i])
+			}
+		}
+
+		if CoverFromAssetField == layout.CoverFrom {
+			card.CoverURL = getAssetCover(row.GetValue(layout.CoverFromAssetKeyID))
+		}
+		ret.Cards = append(ret.Cards, card)
+	}
+
+	if CoverFromContentImage == layout.CoverFrom && 0 < len(boundBlockIDs) && nil != contentCover {
+		covers := contentCover(boundBlockIDs)
+		for _, card := range ret.Cards {
+			if "" != card.BlockID {
+				card.CoverURL = covers[card.BlockID]
+			}
+		}
+	}
+
+	for _, card := range ret.Cards {
+		card.CoverThumb = util.AssetThumbPath(card.CoverURL)
+	}
+	return
+}
+
+func getAssetCover(value *Value) string {
+	if nil == value {
+		return ""
+	}
+
+	for _, asset := range value.MAsset {
+		if AssetTypeImage == asset.Type && "" != asset.Content {
+			return asset.Content
+		}
+	}
+	return ""
+}
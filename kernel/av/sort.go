@@ -226,6 +226,25 @@ func (value *Value) Compare(other *Value, attrView *AttributeView) int {
 			}
 			return strings.Compare(value.Template.Content, other.Template.Content)
 		}
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		v1, ok1 := value.Measure()
+		v2, ok2 := other.Measure()
+		if ok1 {
+			if !ok2 {
+				return -1
+			}
+			if v1 > v2 {
+				return 1
+			}
+			if v1 < v2 {
+				return -1
+			}
+			return 0
+		}
+		if ok2 {
+			return 1
+		}
+		return 0
 	case KeyTypeCheckbox:
 		if nil != value.Checkbox && nil != other.Checkbox {
 			if value.Checkbox.Checked && !other.Checkbox.Checked {
@@ -268,9 +287,9 @@ func (value *Value) Compare(other *Value, attrView *AttributeView) int {
 		}
 	case KeyTypeRollup:
 		if nil != value.Rollup && nil != other.Rollup {
-			if 1 < len(value.Rollup.Contents) && 1 < len(other.Rollup.Contents) && KeyTypeNumber == value.Rollup.Contents[0].Type && KeyTypeNumber == other.Rollup.Contents[0].Type {
-				v1, ok1 := util.Convert2Float(value.Rollup.Contents[0].String(false))
-				v2, ok2 := util.Convert2Float(other.Rollup.Contents[0].String(false))
+			if 0 < len(value.Rollup.Contents) && 0 < len(other.Rollup.Contents) && isNumberValueType(value.Rollup.Contents[0].Type) && isNumberValueType(other.Rollup.Contents[0].Type) {
+				v1, ok1 := value.Rollup.Contents[0].rollupNumber()
+				v2, ok2 := other.Rollup.Contents[0].rollupNumber()
 				if ok1 && ok2 {
 					if v1 > v2 {
 						return 1
@@ -282,6 +301,19 @@ func (value *Value) Compare(other *Value, attrView *AttributeView) int {
 				}
 			}
 
+			if 1 == len(value.Rollup.Contents) && 1 == len(other.Rollup.Contents) {
+				// 计算结果为日期（最早、最晚）时按时间排序
+				if d1, d2 := value.Rollup.Contents[0].rollupDate(), other.Rollup.Contents[0].rollupDate(); nil != d1 && nil != d2 {
+					if d1.Content > d2.Content {
+						return 1
+					}
+					if d1.Content < d2.Content {
+						return -1
+					}
+					return 0
+				}
+			}
+
 			vContentBuf := bytes.Buffer{}
 			for _, c := range value.Rollup.Contents {
 				vContentBuf.WriteString(c.String(true))
@@ -299,3 +331,7 @@ func (value *Value) Compare(other *Value, attrView *AttributeView) int {
 	}
 	return 0
 }
+
+func isNumberValueType(typ KeyType) bool {
+	return KeyTypeNumber == typ || IsMeasureKeyType(typ)
+}
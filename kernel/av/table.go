@@ -30,6 +30,15 @@ type LayoutTable struct {
 	Filters  []*ViewFilter      `json:"filters"`  // 过滤规则
 	Sorts    []*ViewSort        `json:"sorts"`    // 排序规则
 	PageSize int                `json:"pageSize"` // 每页行数
+
+	FilterGroup *ViewFilterGroup `json:"filterGroup,omitempty"` // 嵌套过滤条件组，和 Filters 同时满足
+}
+
+// GetAllFilters 返回过滤规则以及嵌套过滤条件组中的所有过滤条件。
+func (table *LayoutTable) GetAllFilters() (ret []*ViewFilter) {
+	ret = append(ret, table.Filters...)
+	ret = append(ret, table.FilterGroup.GetFilters()...)
+	return
 }
 
 type ViewTableColumn struct {
@@ -44,16 +53,17 @@ type ViewTableColumn struct {
 
 // Table 描述了表格实例的结构。
 type Table struct {
-	ID               string         `json:"id"`               // 表格布局 ID
-	Icon             string         `json:"icon"`             // 表格图标
-	Name             string         `json:"name"`             // 表格名称
-	HideAttrViewName bool           `json:"hideAttrViewName"` // 是否隐藏属性视图名称
-	Filters          []*ViewFilter  `json:"filters"`          // 过滤规则
-	Sorts            []*ViewSort    `json:"sorts"`            // 排序规则
-	Columns          []*TableColumn `json:"columns"`          // 表格列
-	Rows             []*TableRow    `json:"rows"`             // 表格行
-	RowCount         int            `json:"rowCount"`         // 表格总行数
-	PageSize         int            `json:"pageSize"`         // 每页行数
+	ID               string           `json:"id"`               // 表格布局 ID
+	Icon             string           `json:"icon"`             // 表格图标
+	Name             string           `json:"name"`             // 表格名称
+	HideAttrViewName bool             `json:"hideAttrViewName"` // 是否隐藏属性视图名称
+	Filters          []*ViewFilter    `json:"filters"`          // 过滤规则
+	FilterGroup      *ViewFilterGroup `json:"filterGroup"`      // 嵌套过滤条件组
+	Sorts            []*ViewSort      `json:"sorts"`            // 排序规则
+	Columns          []*TableColumn   `json:"columns"`          // 表格列
+	Rows             []*TableRow      `json:"rows"`             // 表格行
+	RowCount         int              `json:"rowCount"`         // 表格总行数
+	PageSize         int              `json:"pageSize"`         // 每页行数
 }
 
 type TableColumn struct {
@@ -75,6 +85,11 @@ type TableColumn struct {
 	Relation     *Relation       `json:"relation,omitempty"` // 关联列
 	Rollup       *Rollup         `json:"rollup,omitempty"`   // 汇总列
 	Date         *Date           `json:"date,omitempty"`     // 日期设置
+	Rating       *Rating         `json:"rating,omitempty"`   // 评分设置
+	Currency     *Currency       `json:"currency,omitempty"` // 货币设置
+	Duration     *Duration       `json:"duration,omitempty"` // 时长设置
+
+	Validation *Validation `json:"validation,omitempty"` // 校验规则
 }
 
 type TableCell struct {
@@ -118,6 +133,40 @@ func (table *Table) GetID() string {
 	return table.ID
 }
 
+// PageRows 返回指定页的行，cursor 不为空时返回该行之后的 pageSize 行。nextCursor 为空表示没有更多行。
+func (table *Table) PageRows(cursor string, page, pageSize int) (rows []*TableRow, nextCursor string) {
+	if 1 > page {
+		page = 1
+	}
+	if 1 > pageSize {
+		pageSize = 50
+	}
+
+	start := (page - 1) * pageSize
+	if "" != cursor {
+		start = len(table.Rows)
+		for i, row := range table.Rows {
+			if row.ID == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if len(table.Rows) < start {
+		start = len(table.Rows)
+	}
+
+	end := start + pageSize
+	if len(table.Rows) < end || 0 > end {
+		end = len(table.Rows)
+	}
+	rows = table.Rows[start:end]
+	if end < len(table.Rows) && 0 < len(rows) {
+		nextCursor = rows[len(rows)-1].ID
+	}
+	return
+}
+
 func (table *Table) SortRows(attrView *AttributeView) {
 	if 1 > len(table.Sorts) {
 		return
@@ -232,7 +281,7 @@ func (table *Table) SortRows(attrView *AttributeView) {
 }
 
 func (table *Table) FilterRows(attrView *AttributeView) {
-	if 1 > len(table.Filters) {
+	if 1 > len(table.Filters) && nil == table.FilterGroup {
 		return
 	}
 
@@ -246,6 +295,13 @@ func (table *Table) FilterRows(attrView *AttributeView) {
 		}
 	}
 
+	groupColIndexes := map[string]int{}
+	if nil != table.FilterGroup {
+		for i, c := range table.Columns {
+			groupColIndexes[c.ID] = i
+		}
+	}
+
 	rows := []*TableRow{}
 	for _, row := range table.Rows {
 		pass := true
@@ -271,6 +327,9 @@ func (table *Table) FilterRows(attrView *AttributeView) {
 				break
 			}
 		}
+		if pass && nil != table.FilterGroup {
+			pass = table.FilterGroup.match(row, groupColIndexes, attrView)
+		}
 		if pass {
 			rows = append(rows, row)
 		}
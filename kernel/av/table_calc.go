@@ -65,6 +65,8 @@ func (table *Table) CalcCols() {
 			table.calcColRelation(col, i)
 		case KeyTypeRollup:
 			table.calcColRollup(col, i)
+		case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+			table.calcColMeasure(col, i)
 		}
 	}
 }
@@ -1444,3 +1446,78 @@ func (table *Table) calcColRollup(col *TableColumn, colIndex int) {
 		}
 	}
 }
+
+func (table *Table) calcColMeasure(col *TableColumn, colIndex int) {
+	var values []float64
+	for _, row := range table.Rows {
+		if nil != row.Cells[colIndex] {
+			if v, ok := row.Cells[colIndex].Value.Measure(); ok {
+				values = append(values, v)
+			}
+		}
+	}
+
+	switch col.Calc.Operator {
+	case CalcOperatorCountAll:
+		col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(len(table.Rows)), NumberFormatNone)}
+	case CalcOperatorCountValues, CalcOperatorCountNotEmpty:
+		col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(len(values)), NumberFormatNone)}
+	case CalcOperatorCountUniqueValues:
+		uniqueValues := map[float64]bool{}
+		for _, v := range values {
+			uniqueValues[v] = true
+		}
+		col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(len(uniqueValues)), NumberFormatNone)}
+	case CalcOperatorCountEmpty:
+		col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(len(table.Rows)-len(values)), NumberFormatNone)}
+	case CalcOperatorPercentEmpty:
+		if 0 < len(table.Rows) {
+			col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(len(table.Rows)-len(values))/float64(len(table.Rows)), NumberFormatPercent)}
+		}
+	case CalcOperatorPercentNotEmpty:
+		if 0 < len(table.Rows) {
+			col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(len(values))/float64(len(table.Rows)), NumberFormatPercent)}
+		}
+	case CalcOperatorSum:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		col.Calc.Result = newMeasureValue(col.Type, sum, col.Currency, col.Duration)
+	case CalcOperatorAverage:
+		if 0 < len(values) {
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			col.Calc.Result = newMeasureValue(col.Type, sum/float64(len(values)), col.Currency, col.Duration)
+		}
+	case CalcOperatorMedian:
+		sort.Float64s(values)
+		if 0 < len(values) {
+			if 0 == len(values)%2 {
+				col.Calc.Result = newMeasureValue(col.Type, (values[len(values)/2-1]+values[len(values)/2])/2, col.Currency, col.Duration)
+			} else {
+				col.Calc.Result = newMeasureValue(col.Type, values[len(values)/2], col.Currency, col.Duration)
+			}
+		}
+	case CalcOperatorMin, CalcOperatorMax, CalcOperatorRange:
+		if 1 > len(values) {
+			break
+		}
+
+		minVal, maxVal := values[0], values[0]
+		for _, v := range values {
+			minVal = math.Min(minVal, v)
+			maxVal = math.Max(maxVal, v)
+		}
+		switch col.Calc.Operator {
+		case CalcOperatorMin:
+			col.Calc.Result = newMeasureValue(col.Type, minVal, col.Currency, col.Duration)
+		case CalcOperatorMax:
+			col.Calc.Result = newMeasureValue(col.Type, maxVal, col.Currency, col.Duration)
+		case CalcOperatorRange:
+			col.Calc.Result = newMeasureValue(col.Type, maxVal-minVal, col.Currency, col.Duration)
+		}
+	}
+}
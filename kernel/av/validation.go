@@ -0,0 +1,135 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validation 描述了列的校验规则，在写入列值时校验。
+type Validation struct {
+	Required bool     `json:"required"`          // 是否必填
+	Pattern  string   `json:"pattern,omitempty"` // 正则表达式，仅校验非空值
+	Min      *float64 `json:"min,omitempty"`     // 最小值，仅校验数值列
+	Max      *float64 `json:"max,omitempty"`     // 最大值，仅校验数值列
+	Unique   bool     `json:"unique"`            // 是否唯一
+}
+
+type ValidationRule string
+
+const (
+	ValidationRuleRequired ValidationRule = "required"
+	ValidationRulePattern  ValidationRule = "pattern"
+	ValidationRuleMin      ValidationRule = "min"
+	ValidationRuleMax      ValidationRule = "max"
+	ValidationRuleUnique   ValidationRule = "unique"
+)
+
+// ValidationError 描述了列值校验失败的详细信息，前端根据 Rule 进行提示。
+type ValidationError struct {
+	AvID           string         `json:"avID"`
+	KeyID          string         `json:"keyID"`
+	KeyName        string         `json:"keyName"`
+	RowID          string         `json:"rowID"`
+	Rule           ValidationRule `json:"rule"`
+	Value          string         `json:"value"`
+	DuplicateRowID string         `json:"duplicateRowID,omitempty"` // 违反唯一约束时已经存在该值的行
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("value [%s] of column [%s] violates rule [%s]", err.Value, err.KeyName, err.Rule)
+}
+
+// CheckValidation 校验规则本身是否合法。
+func (validation *Validation) CheckValidation() error {
+	if "" != validation.Pattern {
+		if _, err := regexp.Compile(validation.Pattern); nil != err {
+			return fmt.Errorf("invalid pattern [%s]: %s", validation.Pattern, err)
+		}
+	}
+	if nil != validation.Min && nil != validation.Max && *validation.Min > *validation.Max {
+		return fmt.Errorf("min [%v] is greater than max [%v]", *validation.Min, *validation.Max)
+	}
+	return nil
+}
+
+// ValidateValue 按照列的校验规则校验值，校验通过时返回 nil。
+func (av *AttributeView) ValidateValue(key *Key, value *Value) error {
+	if nil == key || nil == key.Validation || nil == value {
+		return nil
+	}
+
+	validation := key.Validation
+	content := value.String(false)
+	newErr := func(rule ValidationRule) *ValidationError {
+		return &ValidationError{AvID: av.ID, KeyID: key.ID, KeyName: key.Name, RowID: value.BlockID, Rule: rule, Value: content}
+	}
+
+	if value.IsEmpty() {
+		if validation.Required {
+			return newErr(ValidationRuleRequired)
+		}
+		return nil
+	}
+
+	if "" != validation.Pattern {
+		if re, err := regexp.Compile(validation.Pattern); nil == err && !re.MatchString(content) {
+			return newErr(ValidationRulePattern)
+		}
+	}
+
+	if number, ok := value.validationNumber(); ok {
+		if nil != validation.Min && number < *validation.Min {
+			return newErr(ValidationRuleMin)
+		}
+		if nil != validation.Max && number > *validation.Max {
+			return newErr(ValidationRuleMax)
+		}
+	}
+
+	if validation.Unique {
+		keyValues, _ := av.GetKeyValues(key.ID)
+		if nil != keyValues {
+			for _, v := range keyValues.Values {
+				if v.BlockID == value.BlockID || v.IsEmpty() {
+					continue
+				}
+
+				if strings.EqualFold(strings.TrimSpace(v.String(false)), strings.TrimSpace(content)) {
+					err := newErr(ValidationRuleUnique)
+					err.DuplicateRowID = v.BlockID
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (value *Value) validationNumber() (ret float64, ok bool) {
+	switch value.Type {
+	case KeyTypeNumber:
+		if nil != value.Number && value.Number.IsNotEmpty {
+			return value.Number.Content, true
+		}
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		return value.Measure()
+	}
+	return
+}
@@ -56,6 +56,10 @@ type Value struct {
 	Checkbox *ValueCheckbox `json:"checkbox,omitempty"`
 	Relation *ValueRelation `json:"relation,omitempty"`
 	Rollup   *ValueRollup   `json:"rollup,omitempty"`
+	Rating   *ValueRating   `json:"rating,omitempty"`
+	Progress *ValueProgress `json:"progress,omitempty"`
+	Currency *ValueCurrency `json:"currency,omitempty"`
+	Duration *ValueDuration `json:"duration,omitempty"`
 }
 
 func (value *Value) SetUpdatedAt(mills int64) {
@@ -174,6 +178,35 @@ func (value *Value) String(format bool) string {
 			ret = append(ret, v.String(format))
 		}
 		return strings.TrimSpace(strings.Join(ret, ", "))
+	case KeyTypeRating:
+		if nil == value.Rating || !value.Rating.IsNotEmpty {
+			return ""
+		}
+		return strconv.FormatFloat(value.Rating.Content, 'f', -1, 64)
+	case KeyTypeProgress:
+		if nil == value.Progress || !value.Progress.IsNotEmpty {
+			return ""
+		}
+		if format {
+			return value.Progress.FormattedContent
+		}
+		return strconv.FormatFloat(value.Progress.Content, 'f', -1, 64)
+	case KeyTypeCurrency:
+		if nil == value.Currency || !value.Currency.IsNotEmpty {
+			return ""
+		}
+		if format {
+			return value.Currency.FormattedContent
+		}
+		return strconv.FormatFloat(value.Currency.Content, 'f', -1, 64)
+	case KeyTypeDuration:
+		if nil == value.Duration || !value.Duration.IsNotEmpty {
+			return ""
+		}
+		if format {
+			return value.Duration.FormattedContent
+		}
+		return strconv.FormatInt(value.Duration.Content, 10)
 	default:
 		return ""
 	}
@@ -290,6 +323,9 @@ func (value *Value) IsEmpty() bool {
 		return 1 > len(value.Relation.Contents)
 	case KeyTypeRollup:
 		return 1 > len(value.Rollup.Contents)
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		_, ok := value.Measure()
+		return !ok
 	}
 	return false
 }
@@ -328,6 +364,14 @@ func (value *Value) SetValByType(typ KeyType, val interface{}) {
 		value.Relation = val.(*ValueRelation)
 	case KeyTypeRollup:
 		value.Rollup = val.(*ValueRollup)
+	case KeyTypeRating:
+		value.Rating = val.(*ValueRating)
+	case KeyTypeProgress:
+		value.Progress = val.(*ValueProgress)
+	case KeyTypeCurrency:
+		value.Currency = val.(*ValueCurrency)
+	case KeyTypeDuration:
+		value.Duration = val.(*ValueDuration)
 	}
 }
 
@@ -365,6 +409,14 @@ func (value *Value) GetValByType(typ KeyType) (ret interface{}) {
 		return value.Relation
 	case KeyTypeRollup:
 		return value.Rollup
+	case KeyTypeRating:
+		return value.Rating
+	case KeyTypeProgress:
+		return value.Progress
+	case KeyTypeCurrency:
+		return value.Currency
+	case KeyTypeDuration:
+		return value.Duration
 	}
 	return
 }
@@ -664,6 +716,240 @@ type ValueCheckbox struct {
 	Checked bool `json:"checked"`
 }
 
+type ValueRating struct {
+	Content    float64 `json:"content"` // 评分，支持半星
+	IsNotEmpty bool    `json:"isNotEmpty"`
+}
+
+type ValueProgress struct {
+	Content          float64 `json:"content"` // 进度百分比，范围 0-100
+	IsNotEmpty       bool    `json:"isNotEmpty"`
+	FormattedContent string  `json:"formattedContent"`
+}
+
+func NewFormattedValueProgress(content float64) (ret *ValueProgress) {
+	ret = &ValueProgress{Content: content, IsNotEmpty: true}
+	ret.FormatProgress()
+	return
+}
+
+func (progress *ValueProgress) FormatProgress() {
+	s := fmt.Sprintf("%.2f", progress.Content)
+	progress.FormattedContent = strings.TrimRight(strings.TrimRight(s, "0"), ".") + "%"
+}
+
+type ValueCurrency struct {
+	Content          float64 `json:"content"`
+	IsNotEmpty       bool    `json:"isNotEmpty"`
+	Code             string  `json:"code"` // ISO 4217 货币代码
+	FormattedContent string  `json:"formattedContent"`
+}
+
+func NewFormattedValueCurrency(content float64, code string) (ret *ValueCurrency) {
+	ret = &ValueCurrency{Content: content, IsNotEmpty: true, Code: code}
+	ret.FormatCurrency()
+	return
+}
+
+func (currency *ValueCurrency) FormatCurrency() {
+	currency.FormattedContent = formatCurrency(currency.Content, currency.Code)
+}
+
+var currencyNumberFormats = map[string]NumberFormat{
+	"USD": NumberFormatUSDollar,
+	"CNY": NumberFormatYuan,
+	"EUR": NumberFormatEuro,
+	"GBP": NumberFormatPound,
+	"JPY": NumberFormatYen,
+	"RUB": NumberFormatRuble,
+	"INR": NumberFormatRupee,
+	"KRW": NumberFormatWon,
+	"CAD": NumberFormatCanadianDollar,
+	"CHF": NumberFormatFranc,
+}
+
+func formatCurrency(content float64, code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if "" == code {
+		code = DefaultCurrencyCode
+	}
+	if format, ok := currencyNumberFormats[code]; ok {
+		return formatNumber(content, format)
+	}
+
+	// 没有内置格式的货币使用代码作为前缀
+	p := message.NewPrinter(language.English)
+	return p.Sprintf("%s %.2f", code, content)
+}
+
+type ValueDuration struct {
+	Content          int64          `json:"content"` // 时长，单位为秒
+	IsNotEmpty       bool           `json:"isNotEmpty"`
+	Format           DurationFormat `json:"format"`
+	FormattedContent string         `json:"formattedContent"`
+}
+
+type DurationFormat string
+
+const (
+	DurationFormatNone  DurationFormat = ""      // 1h 30m 5s
+	DurationFormatClock DurationFormat = "clock" // 1:30:05
+)
+
+func NewFormattedValueDuration(content int64, format DurationFormat) (ret *ValueDuration) {
+	ret = &ValueDuration{Content: content, IsNotEmpty: true, Format: format}
+	ret.FormatDuration()
+	return
+}
+
+func (duration *ValueDuration) FormatDuration() {
+	duration.FormattedContent = formatDuration(duration.Content, duration.Format)
+}
+
+func formatDuration(seconds int64, format DurationFormat) string {
+	sign := ""
+	if 0 > seconds {
+		sign = "-"
+		seconds = -seconds
+	}
+
+	h, m, s := seconds/3600, seconds%3600/60, seconds%60
+	switch format {
+	case DurationFormatClock:
+		return fmt.Sprintf("%s%d:%02d:%02d", sign, h, m, s)
+	default:
+		var parts []string
+		if 0 < h {
+			parts = append(parts, fmt.Sprintf("%dh", h))
+		}
+		if 0 < m {
+			parts = append(parts, fmt.Sprintf("%dm", m))
+		}
+		if 0 < s || 1 > len(parts) {
+			parts = append(parts, fmt.Sprintf("%ds", s))
+		}
+		return sign + strings.Join(parts, " ")
+	}
+}
+
+// IsMeasureKeyType 判断列类型是否为评分、进度、货币或时长，这些列的值都可以作为数值参与排序、过滤和计算。
+func IsMeasureKeyType(typ KeyType) bool {
+	switch typ {
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		return true
+	}
+	return false
+}
+
+// Measure 返回评分、进度、货币或时长值的数值，值为空时 ok 为 false。
+func (value *Value) Measure() (ret float64, ok bool) {
+	if nil == value {
+		return
+	}
+
+	switch value.Type {
+	case KeyTypeRating:
+		if nil != value.Rating && value.Rating.IsNotEmpty {
+			return value.Rating.Content, true
+		}
+	case KeyTypeProgress:
+		if nil != value.Progress && value.Progress.IsNotEmpty {
+			return value.Progress.Content, true
+		}
+	case KeyTypeCurrency:
+		if nil != value.Currency && value.Currency.IsNotEmpty {
+			return value.Currency.Content, true
+		}
+	case KeyTypeDuration:
+		if nil != value.Duration && value.Duration.IsNotEmpty {
+			return float64(value.Duration.Content), true
+		}
+	}
+	return
+}
+
+// setMeasure 设置评分、进度、货币或时长值的数值，保留原有的货币代码和时长格式。
+func (value *Value) setMeasure(content float64, isNotEmpty bool) {
+	if !isNotEmpty {
+		content = 0
+	}
+
+	switch value.Type {
+	case KeyTypeRating:
+		value.Rating = &ValueRating{Content: content, IsNotEmpty: isNotEmpty}
+	case KeyTypeProgress:
+		value.Progress = &ValueProgress{Content: content, IsNotEmpty: isNotEmpty}
+		if isNotEmpty {
+			value.Progress.FormatProgress()
+		}
+	case KeyTypeCurrency:
+		code := DefaultCurrencyCode
+		if nil != value.Currency && "" != value.Currency.Code {
+			code = value.Currency.Code
+		}
+		value.Currency = &ValueCurrency{Content: content, IsNotEmpty: isNotEmpty, Code: code}
+		if isNotEmpty {
+			value.Currency.FormatCurrency()
+		}
+	case KeyTypeDuration:
+		var format DurationFormat
+		if nil != value.Duration {
+			format = value.Duration.Format
+		}
+		value.Duration = &ValueDuration{Content: int64(math.Round(content)), IsNotEmpty: isNotEmpty, Format: format}
+		if isNotEmpty {
+			value.Duration.FormatDuration()
+		}
+	}
+}
+
+// FormatMeasure 按照列设置格式化评分、进度、货币或时长值。
+func (value *Value) FormatMeasure(key *Key) {
+	if nil == key {
+		return
+	}
+
+	switch value.Type {
+	case KeyTypeRating:
+		if nil != value.Rating && value.Rating.IsNotEmpty {
+			maxRating := float64(key.Rating.GetMax())
+			value.Rating.Content = math.Max(0, math.Min(maxRating, math.Round(value.Rating.Content*2)/2))
+		}
+	case KeyTypeProgress:
+		if nil != value.Progress && value.Progress.IsNotEmpty {
+			value.Progress.Content = math.Max(0, math.Min(100, value.Progress.Content))
+			value.Progress.FormatProgress()
+		}
+	case KeyTypeCurrency:
+		if nil != value.Currency && value.Currency.IsNotEmpty {
+			if "" == value.Currency.Code {
+				value.Currency.Code = key.Currency.GetCode()
+			}
+			value.Currency.FormatCurrency()
+		}
+	case KeyTypeDuration:
+		if nil != value.Duration && value.Duration.IsNotEmpty {
+			value.Duration.Format = key.Duration.GetFormat()
+			value.Duration.FormatDuration()
+		}
+	}
+}
+
+func newMeasureValue(typ KeyType, content float64, currency *Currency, duration *Duration) (ret *Value) {
+	ret = &Value{Type: typ}
+	switch typ {
+	case KeyTypeRating:
+		ret.Rating = &ValueRating{Content: Round(content, 2), IsNotEmpty: true}
+	case KeyTypeProgress:
+		ret.Progress = NewFormattedValueProgress(content)
+	case KeyTypeCurrency:
+		ret.Currency = NewFormattedValueCurrency(content, currency.GetCode())
+	case KeyTypeDuration:
+		ret.Duration = NewFormattedValueDuration(int64(math.Round(content)), duration.GetFormat())
+	}
+	return
+}
+
 type ValueRelation struct {
 	BlockIDs []string `json:"blockIDs"`
 	Contents []*Value `json:"contents"`
@@ -733,61 +1019,67 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 	case CalcOperatorSum:
 		sum := 0.0
 		for _, v := range r.Contents {
-			if KeyTypeNumber == v.Type && nil != v.Number && v.Number.IsNotEmpty {
-				sum += v.Number.Content
+			if number, ok := v.rollupNumber(); ok {
+				sum += number
 			}
 		}
-		r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(sum, destKey.NumberFormat)}}
+		r.Contents = []*Value{newRollupNumberValue(sum, destKey)}
 	case CalcOperatorAverage:
 		sum := 0.0
 		count := 0
 		for _, v := range r.Contents {
-			if KeyTypeNumber == v.Type && nil != v.Number && v.Number.IsNotEmpty {
-				sum += v.Number.Content
+			if number, ok := v.rollupNumber(); ok {
+				sum += number
 				count++
 			}
 		}
 		if 0 < count {
-			r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(sum/float64(count), destKey.NumberFormat)}}
+			r.Contents = []*Value{newRollupNumberValue(sum/float64(count), destKey)}
 		}
 	case CalcOperatorMedian:
 		var numbers []float64
 		for _, v := range r.Contents {
-			if KeyTypeNumber == v.Type && nil != v.Number && v.Number.IsNotEmpty {
-				numbers = append(numbers, v.Number.Content)
+			if number, ok := v.rollupNumber(); ok {
+				numbers = append(numbers, number)
 			}
 		}
 		sort.Float64s(numbers)
 		if 0 < len(numbers) {
 			if 0 == len(numbers)%2 {
-				r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber((numbers[len(numbers)/2-1]+numbers[len(numbers)/2])/2, destKey.NumberFormat)}}
+				r.Contents = []*Value{newRollupNumberValue((numbers[len(numbers)/2-1]+numbers[len(numbers)/2])/2, destKey)}
 			} else {
-				r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(numbers[len(numbers)/2], destKey.NumberFormat)}}
+				r.Contents = []*Value{newRollupNumberValue(numbers[len(numbers)/2], destKey)}
 			}
 		}
 	case CalcOperatorMin:
 		minVal := math.MaxFloat64
 		for _, v := range r.Contents {
-			if KeyTypeNumber == v.Type && nil != v.Number && v.Number.IsNotEmpty {
-				if v.Number.Content < minVal {
-					minVal = v.Number.Content
+			if number, ok := v.rollupNumber(); ok {
+				if number < minVal {
+					minVal = number
 				}
 			}
 		}
 		if math.MaxFloat64 != minVal {
-			r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(minVal, destKey.NumberFormat)}}
+			r.Contents = []*Value{newRollupNumberValue(minVal, destKey)}
+		} else {
+			// 没有数字时按日期取最早
+			r.RenderContents(&RollupCalc{Operator: CalcOperatorEarliest}, destKey)
 		}
 	case CalcOperatorMax:
 		maxVal := -math.MaxFloat64
 		for _, v := range r.Contents {
-			if KeyTypeNumber == v.Type && nil != v.Number && v.Number.IsNotEmpty {
-				if v.Number.Content > maxVal {
-					maxVal = v.Number.Content
+			if number, ok := v.rollupNumber(); ok {
+				if number > maxVal {
+					maxVal = number
 				}
 			}
 		}
 		if -math.MaxFloat64 != maxVal {
-			r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(maxVal, destKey.NumberFormat)}}
+			r.Contents = []*Value{newRollupNumberValue(maxVal, destKey)}
+		} else {
+			// 没有数字时按日期取最晚
+			r.RenderContents(&RollupCalc{Operator: CalcOperatorLatest}, destKey)
 		}
 	case CalcOperatorRange:
 		if 2 > len(r.Contents) {
@@ -800,29 +1092,29 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 		latest := int64(0)
 		var isNotTime, hasEndDate bool
 		for _, v := range r.Contents {
-			if KeyTypeNumber == v.Type && nil != v.Number && v.Number.IsNotEmpty {
-				if v.Number.Content < minVal {
-					minVal = v.Number.Content
+			if number, ok := v.rollupNumber(); ok {
+				if number < minVal {
+					minVal = number
 				}
-				if v.Number.Content > maxVal {
-					maxVal = v.Number.Content
+				if number > maxVal {
+					maxVal = number
 				}
-			} else if KeyTypeDate == v.Type && nil != v.Date && v.Date.IsNotEmpty {
-				if 0 == earliest || v.Date.Content < earliest {
-					earliest = v.Date.Content
-					isNotTime = v.Date.IsNotTime
-					hasEndDate = v.Date.HasEndDate
+			} else if date := v.rollupDate(); nil != date {
+				if 0 == earliest || date.Content < earliest {
+					earliest = date.Content
+					isNotTime = date.IsNotTime
+					hasEndDate = date.HasEndDate
 				}
-				if 0 == latest || v.Date.Content > latest {
-					latest = v.Date.Content
-					isNotTime = v.Date.IsNotTime
-					hasEndDate = v.Date.HasEndDate
+				if 0 == latest || date.Content > latest {
+					latest = date.Content
+					isNotTime = date.IsNotTime
+					hasEndDate = date.HasEndDate
 				}
 			}
 		}
 
 		if math.MaxFloat64 != minVal && -math.MaxFloat64 != maxVal {
-			r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(maxVal-minVal, destKey.NumberFormat)}}
+			r.Contents = []*Value{newRollupNumberValue(maxVal-minVal, destKey)}
 		}
 		if 0 != earliest && 0 != latest {
 			r.Contents = []*Value{{Type: KeyTypeDate, Date: NewFormattedValueDate(earliest, latest, DateFormatDuration, isNotTime, hasEndDate)}}
@@ -831,11 +1123,11 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 		earliest := int64(0)
 		var isNotTime, hasEndDate bool
 		for _, v := range r.Contents {
-			if KeyTypeDate == v.Type && nil != v.Date && v.Date.IsNotEmpty {
-				if 0 == earliest || v.Date.Content < earliest {
-					earliest = v.Date.Content
-					isNotTime = v.Date.IsNotTime
-					hasEndDate = v.Date.HasEndDate
+			if date := v.rollupDate(); nil != date {
+				if 0 == earliest || date.Content < earliest {
+					earliest = date.Content
+					isNotTime = date.IsNotTime
+					hasEndDate = date.HasEndDate
 				}
 			}
 		}
@@ -846,11 +1138,11 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 		latest := int64(0)
 		var isNotTime, hasEndDate bool
 		for _, v := range r.Contents {
-			if KeyTypeDate == v.Type && nil != v.Date && v.Date.IsNotEmpty {
-				if 0 == latest || latest < v.Date.Content {
-					latest = v.Date.Content
-					isNotTime = v.Date.IsNotTime
-					hasEndDate = v.Date.HasEndDate
+			if date := v.rollupDate(); nil != date {
+				if 0 == latest || latest < date.Content {
+					latest = date.Content
+					isNotTime = date.IsNotTime
+					hasEndDate = date.HasEndDate
 				}
 			}
 		}
@@ -904,6 +1196,69 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 	}
 }
 
+// rollupNumber 返回汇总时参与数值计算的值，支持数字、可解析为数字的文本/模板以及已计算过的汇总值。
+func (value *Value) rollupNumber() (ret float64, ok bool) {
+	switch value.Type {
+	case KeyTypeNumber:
+		if nil != value.Number && value.Number.IsNotEmpty {
+			return value.Number.Content, true
+		}
+	case KeyTypeText:
+		if nil != value.Text {
+			number, err := strconv.ParseFloat(strings.TrimSpace(value.Text.Content), 64)
+			return number, nil == err
+		}
+	case KeyTypeTemplate:
+		if nil != value.Template {
+			number, err := strconv.ParseFloat(strings.TrimSpace(value.Template.Content), 64)
+			return number, nil == err
+		}
+	case KeyTypeRollup:
+		if nil != value.Rollup && 1 == len(value.Rollup.Contents) {
+			return value.Rollup.Contents[0].rollupNumber()
+		}
+	case KeyTypeRating, KeyTypeProgress, KeyTypeCurrency, KeyTypeDuration:
+		return value.Measure()
+	}
+	return
+}
+
+// newRollupNumberValue 生成汇总数值计算结果，目标列为评分、进度、货币或时长时保持目标列的类型和格式。
+func newRollupNumberValue(content float64, destKey *Key) *Value {
+	if nil != destKey && IsMeasureKeyType(destKey.Type) {
+		return newMeasureValue(destKey.Type, content, destKey.Currency, destKey.Duration)
+	}
+
+	var format NumberFormat
+	if nil != destKey {
+		format = destKey.NumberFormat
+	}
+	return &Value{Type: KeyTypeNumber, Number: NewFormattedValueNumber(content, format)}
+}
+
+// rollupDate 返回汇总时参与日期计算的值，支持日期、创建时间和更新时间。
+func (value *Value) rollupDate() (ret *ValueDate) {
+	switch value.Type {
+	case KeyTypeDate:
+		if nil != value.Date && value.Date.IsNotEmpty {
+			ret = value.Date
+		}
+	case KeyTypeCreated:
+		if nil != value.Created && value.Created.IsNotEmpty {
+			ret = &ValueDate{Content: value.Created.Content, IsNotEmpty: true}
+		}
+	case KeyTypeUpdated:
+		if nil != value.Updated && value.Updated.IsNotEmpty {
+			ret = &ValueDate{Content: value.Updated.Content, IsNotEmpty: true}
+		}
+	case KeyTypeRollup:
+		if nil != value.Rollup && 1 == len(value.Rollup.Contents) {
+			ret = value.Rollup.Contents[0].rollupDate()
+		}
+	}
+	return
+}
+
 func GetAttributeViewDefaultValue(valueID, keyID, blockID string, typ KeyType) (ret *Value) {
 	if "" == valueID {
 		valueID = ast.NewNodeID()
@@ -953,6 +1308,14 @@ func GetAttributeViewDefaultValue(valueID, keyID, blockID string, typ KeyType) (
 		ret.Relation = &ValueRelation{}
 	case KeyTypeRollup:
 		ret.Rollup = &ValueRollup{}
+	case KeyTypeRating:
+		ret.Rating = &ValueRating{}
+	case KeyTypeProgress:
+		ret.Progress = &ValueProgress{}
+	case KeyTypeCurrency:
+		ret.Currency = &ValueCurrency{}
+	case KeyTypeDuration:
+		ret.Duration = &ValueDuration{}
 	}
 	return
 }
@@ -25,7 +25,6 @@ import (
 
 	"github.com/88250/go-humanize"
 	ants "github.com/panjf2000/ants/v2"
-	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -58,16 +57,10 @@ func Icons() (icons []*Icon) {
 		}
 
 		icon := &Icon{}
-		innerU := util.BazaarOSSServer + "/package/" + repoURL + "/icon.json"
-		innerResp, innerErr := httpclient.NewBrowserRequest().SetSuccessResult(icon).Get(innerU)
-		if nil != innerErr {
+		if innerErr := getPackageJSON(repoURL, "icon.json", icon); nil != innerErr {
 			logging.LogErrorf("get bazaar package [%s] failed: %s", repoURL, innerErr)
 			return
 		}
-		if 200 != innerResp.StatusCode {
-			logging.LogErrorf("get bazaar package [%s] failed: %d", innerU, innerResp.StatusCode)
-			return
-		}
 
 		if disallowDisplayBazaarPackage(icon.Package) {
 			return
@@ -77,9 +70,9 @@ func Icons() (icons []*Icon) {
 		repoURLHash := strings.Split(repoURL, "@")
 		icon.RepoURL = "https://github.com/" + repoURLHash[0]
 		icon.RepoHash = repoURLHash[1]
-		icon.PreviewURL = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageslim"
-		icon.PreviewURLThumb = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
-		icon.IconURL = util.BazaarOSSServer + "/package/" + repoURL + "/icon.png"
+		icon.PreviewURL = packageServer() + "/package/" + repoURL + "/preview.png?imageslim"
+		icon.PreviewURLThumb = packageServer() + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
+		icon.IconURL = packageServer() + "/package/" + repoURL + "/icon.png"
 		icon.Funding = repo.Package.Funding
 		icon.PreferredFunding = getPreferredFunding(icon.Funding)
 		icon.PreferredName = GetPreferredName(icon.Package)
@@ -191,7 +184,7 @@ func InstallIcon(repoURL, repoHash, installPath string, systemID string) error {
 	if nil != err {
 		return err
 	}
-	return installPackage(data, installPath, repoURLHash)
+	return installPackage(data, "icons", installPath, repoURLHash)
 }
 
 func UninstallIcon(installPath string) error {
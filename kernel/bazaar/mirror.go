@@ -0,0 +1,256 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package bazaar
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 自建集市镜像，用于无法访问官方集市的隔离部署环境。镜像的目录结构与官方集市对象存储一致：
+//
+//	stage/{plugins,themes,icons,templates,widgets}.json  集市包索引
+//	package/{owner}/{repo}@{hash}                        集市包 zip
+//	package/{owner}/{repo}@{hash}/{plugin.json,README.md,preview.png,icon.png...}
+//	index.json                                           下载统计（可选）
+//	manifest.json                                        集市包校验清单 {"packages": {"owner/repo@hash": {"sha256": "..."}}}
+//	manifest.json.sig                                    清单的 Ed25519 签名（Base64），配置了公钥时必须存在
+//
+// 镜像地址可以是 http(s) 地址，也可以是本地文件夹路径。
+
+// MirrorServePath 是内核提供本地文件夹镜像静态文件（预览图、图标）的路由前缀。
+const MirrorServePath = "/api/bazaar/mirror"
+
+type mirrorManifest struct {
+	Packages map[string]*mirrorManifestPackage `json:"packages"`
+}
+
+type mirrorManifestPackage struct {
+	SHA256 string `json:"sha256"`
+}
+
+var (
+	mirrorURL       string
+	mirrorPublicKey string
+	mirrorLock      = sync.RWMutex{}
+
+	cachedMirrorManifest    *mirrorManifest
+	mirrorManifestCacheTime int64
+	mirrorManifestCacheLock = sync.Mutex{}
+)
+
+// SetMirror 设置集市镜像，url 为空时使用官方集市。
+func SetMirror(url, publicKey string) {
+	mirrorLock.Lock()
+	defer mirrorLock.Unlock()
+
+	url = strings.TrimSpace(url)
+	url = strings.TrimSuffix(strings.TrimPrefix(url, "file://"), "/")
+	if mirrorURL != url || mirrorPublicKey != publicKey {
+		mirrorURL, mirrorPublicKey = url, strings.TrimSpace(publicKey)
+
+		stageIndexLock.Lock()
+		cachedStageIndex = map[string]*StageIndex{}
+		stageIndexCacheTime = 0
+		stageIndexLock.Unlock()
+
+		bazaarIndexLock.Lock()
+		cachedBazaarIndex = map[string]*bazaarPackage{}
+		bazaarIndexCacheTime = 0
+		bazaarIndexLock.Unlock()
+
+		mirrorManifestCacheLock.Lock()
+		cachedMirrorManifest = nil
+		mirrorManifestCacheLock.Unlock()
+
+		packageCache.Flush()
+	}
+}
+
+func getMirror() (url, publicKey string) {
+	mirrorLock.RLock()
+	defer mirrorLock.RUnlock()
+	return mirrorURL, mirrorPublicKey
+}
+
+func isMirrorEnabled() bool {
+	url, _ := getMirror()
+	return "" != url
+}
+
+func isMirrorDir(url string) bool {
+	return "" != url && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://")
+}
+
+// packageServer 返回前端加载集市包预览图和图标的地址前缀。
+func packageServer() string {
+	url, _ := getMirror()
+	if "" == url {
+		return util.BazaarOSSServer
+	}
+	if isMirrorDir(url) {
+		return MirrorServePath
+	}
+	return url
+}
+
+// MirrorFilePath 返回本地文件夹镜像中的文件路径，用于内核提供静态文件。
+func MirrorFilePath(rel string) (ret string, err error) {
+	url, _ := getMirror()
+	if !isMirrorDir(url) {
+		err = errors.New("bazaar mirror is not a local folder")
+		return
+	}
+
+	ret = filepath.Join(url, filepath.FromSlash(rel))
+	if !util.IsSubPath(url, ret) {
+		err = errors.New("invalid bazaar mirror path")
+	}
+	return
+}
+
+// getMirrorFile 从镜像中读取文件，rel 为相对于镜像根的路径。
+func getMirrorFile(rel string) (ret []byte, err error) {
+	url, _ := getMirror()
+	if isMirrorDir(url) {
+		p, pathErr := MirrorFilePath(rel)
+		if nil != pathErr {
+			return nil, pathErr
+		}
+		return os.ReadFile(p)
+	}
+
+	u := url + "/" + rel
+	resp, err := httpclient.NewCloudFileRequest2m().Get(u)
+	if nil != err {
+		logging.LogErrorf("get bazaar mirror file [%s] failed: %s", u, err)
+		return
+	}
+	if 200 != resp.StatusCode {
+		err = fmt.Errorf("get bazaar mirror file [%s] failed: %d", u, resp.StatusCode)
+		return
+	}
+	ret = resp.Bytes()
+	return
+}
+
+// getPackageJSON 获取集市包中的 JSON 文件，比如 plugin.json。
+func getPackageJSON(repoURL, name string, v interface{}) (err error) {
+	if isMirrorEnabled() {
+		data, getErr := getMirrorFile("package/" + repoURL + "/" + name)
+		if nil != getErr {
+			return getErr
+		}
+		return gulu.JSON.UnmarshalJSON(data, v)
+	}
+
+	u := util.BazaarOSSServer + "/package/" + repoURL + "/" + name
+	resp, err := httpclient.NewBrowserRequest().SetSuccessResult(v).Get(u)
+	if nil != err {
+		return
+	}
+	if 200 != resp.StatusCode {
+		err = fmt.Errorf("get [%s] failed: %d", u, resp.StatusCode)
+	}
+	return
+}
+
+// verifyMirrorPackage 使用镜像清单校验集市包的 SHA256，清单中没有该包时拒绝安装。
+func verifyMirrorPackage(repoURLHash string, data []byte) (err error) {
+	manifest, err := getMirrorManifest()
+	if nil != err {
+		return
+	}
+
+	pkg := manifest.Packages[repoURLHash]
+	if nil == pkg || "" == pkg.SHA256 {
+		return fmt.Errorf("package [%s] is not listed in the bazaar mirror manifest", repoURLHash)
+	}
+
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), pkg.SHA256) {
+		return fmt.Errorf("package [%s] hash mismatch with the bazaar mirror manifest", repoURLHash)
+	}
+	return
+}
+
+func getMirrorManifest() (ret *mirrorManifest, err error) {
+	mirrorManifestCacheLock.Lock()
+	defer mirrorManifestCacheLock.Unlock()
+
+	now := time.Now().Unix()
+	if nil != cachedMirrorManifest && 3600 >= now-mirrorManifestCacheTime {
+		return cachedMirrorManifest, nil
+	}
+
+	data, err := getMirrorFile("manifest.json")
+	if nil != err {
+		return
+	}
+
+	if _, publicKey := getMirror(); "" != publicKey {
+		if err = verifyMirrorManifestSignature(data, publicKey); nil != err {
+			return
+		}
+	}
+
+	ret = &mirrorManifest{}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		return
+	}
+	if nil == ret.Packages {
+		ret.Packages = map[string]*mirrorManifestPackage{}
+	}
+	cachedMirrorManifest = ret
+	mirrorManifestCacheTime = now
+	return
+}
+
+func verifyMirrorManifestSignature(manifest []byte, publicKey string) (err error) {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if nil != err || ed25519.PublicKeySize != len(key) {
+		return errors.New("invalid bazaar mirror public key")
+	}
+
+	sigData, err := getMirrorFile("manifest.json.sig")
+	if nil != err {
+		return fmt.Errorf("get bazaar mirror manifest signature failed: %s", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if nil != err {
+		return errors.New("invalid bazaar mirror manifest signature")
+	}
+
+	if !ed25519.Verify(key, manifest, sig) {
+		return errors.New("bazaar mirror manifest signature verification failed")
+	}
+	return
+}
@@ -127,12 +127,14 @@ type StageRepo struct {
 	OpenIssues  int    `json:"openIssues"`
 	Size        int64  `json:"size"`
 	InstallSize int64  `json:"installSize"`
+	Signature   string `json:"signature,omitempty"` // 集市包 zip 的 Ed25519 签名（Base64）
 
 	Package *StagePackage `json:"package"`
 }
 
 type StageIndex struct {
-	Repos []*StageRepo `json:"repos"`
+	Repos      []*StageRepo               `json:"repos"`
+	Publishers map[string]*StagePublisher `json:"publishers,omitempty"` // 发布者（GitHub 用户名）-> 公钥
 }
 
 func getPreferredReadme(readme *Readme) string {
@@ -349,6 +351,10 @@ var stageIndexCacheTime int64
 var stageIndexLock = sync.Mutex{}
 
 func getStageIndex(pkgType string) (ret *StageIndex, err error) {
+	if isMirrorEnabled() {
+		return getMirrorStageIndex(pkgType)
+	}
+
 	rhyRet, err := util.GetRhyResult(false)
 	if nil != err {
 		return
@@ -382,6 +388,32 @@ func getStageIndex(pkgType string) (ret *StageIndex, err error) {
 	return
 }
 
+func getMirrorStageIndex(pkgType string) (ret *StageIndex, err error) {
+	stageIndexLock.Lock()
+	defer stageIndexLock.Unlock()
+
+	now := time.Now().Unix()
+	if 3600 >= now-stageIndexCacheTime && nil != cachedStageIndex[pkgType] {
+		ret = cachedStageIndex[pkgType]
+		return
+	}
+
+	data, err := getMirrorFile("stage/" + pkgType + ".json")
+	if nil != err {
+		logging.LogErrorf("get bazaar mirror stage index [%s] failed: %s", pkgType, err)
+		return
+	}
+	ret = &StageIndex{}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		logging.LogErrorf("parse bazaar mirror stage index [%s] failed: %s", pkgType, err)
+		return
+	}
+
+	stageIndexCacheTime = now
+	cachedStageIndex[pkgType] = ret
+	return
+}
+
 func isOutdatedTheme(theme *Theme, bazaarThemes []*Theme) bool {
 	if !strings.HasPrefix(theme.URL, "https://github.com/") {
 		return false
@@ -561,6 +593,21 @@ func downloadPackage(repoURLHash string, pushProgress bool, systemID string) (da
 	defer lock.Unlock()
 
 	repoURLHash = strings.TrimPrefix(repoURLHash, "https://github.com/")
+	if isMirrorEnabled() {
+		data, err = getMirrorFile("package/" + repoURLHash)
+		if nil != err {
+			logging.LogErrorf("get bazaar package [%s] from mirror failed: %s", repoURLHash, err)
+			return nil, errors.New("get bazaar package from mirror failed")
+		}
+		if !strings.Contains(repoURLHash[strings.LastIndex(repoURLHash, "@"):], "/") { // 仅校验集市包，不校验 README 等文件
+			if err = verifyMirrorPackage(repoURLHash, data); nil != err {
+				logging.LogErrorf("verify bazaar package [%s] failed: %s", repoURLHash, err)
+				return nil, err
+			}
+		}
+		return
+	}
+
 	u := util.BazaarOSSServer + "/package/" + repoURLHash
 	buf := &bytes.Buffer{}
 	resp, err := httpclient.NewCloudFileRequest2m().SetOutput(buf).SetDownloadCallback(func(info req.DownloadInfo) {
@@ -607,7 +654,12 @@ func uninstallPackage(installPath string) (err error) {
 	return
 }
 
-func installPackage(data []byte, installPath, repoURLHash string) (err error) {
+func installPackage(data []byte, pkgType, installPath, repoURLHash string) (err error) {
+	if err = verifyPackageSignature(pkgType, repoURLHash, data); nil != err {
+		logging.LogErrorf("verify bazaar package [%s] signature failed: %s", repoURLHash, err)
+		return
+	}
+
 	err = installPackage0(data, installPath)
 	if nil != err {
 		return
@@ -682,6 +734,18 @@ func getBazaarIndex() map[string]*bazaarPackage {
 		return cachedBazaarIndex
 	}
 
+	if isMirrorEnabled() {
+		// 镜像中的下载统计是可选的
+		if data, err := getMirrorFile("index.json"); nil == err {
+			index := map[string]*bazaarPackage{}
+			if err = gulu.JSON.UnmarshalJSON(data, &index); nil == err {
+				cachedBazaarIndex = index
+			}
+		}
+		bazaarIndexCacheTime = now
+		return cachedBazaarIndex
+	}
+
 	request := httpclient.NewBrowserRequest()
 	u := util.BazaarStatServer + "/bazaar/index.json"
 	resp, reqErr := request.SetSuccessResult(&cachedBazaarIndex).Get(u)
@@ -26,14 +26,26 @@ import (
 
 	"github.com/88250/go-humanize"
 	ants "github.com/panjf2000/ants/v2"
-	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 type Plugin struct {
 	*Package
-	Enabled bool `json:"enabled"`
+	Enabled     bool           `json:"enabled"`
+	Backend     *PluginBackend `json:"backend,omitempty"` // 后端组件，为空时表示纯前端插件
+	Permissions []string       `json:"permissions"`       // 申请的内核 API 权限：network、filesystem、sql.write、export
+}
+
+// PluginBackend 描述了插件的后端（WASM）组件，在 plugin.json 中声明：
+//
+//	"backend": {
+//	  "main": "backend.wasm",
+//	  "capabilities": ["block.read", "sql.query", "scheduler", "event"]
+//	}
+type PluginBackend struct {
+	Main         string   `json:"main"`         // WASM 文件路径，相对于插件目录
+	Capabilities []string `json:"capabilities"` // 申请使用的宿主能力，需要用户逐项授权后才能使用
 }
 
 func Plugins(frontend string) (plugins []*Plugin) {
@@ -61,16 +73,10 @@ func Plugins(frontend string) (plugins []*Plugin) {
 		}
 
 		plugin := &Plugin{}
-		innerU := util.BazaarOSSServer + "/package/" + repoURL + "/plugin.json"
-		innerResp, innerErr := httpclient.NewBrowserRequest().SetSuccessResult(plugin).Get(innerU)
-		if nil != innerErr {
+		if innerErr := getPackageJSON(repoURL, "plugin.json", plugin); nil != innerErr {
 			logging.LogErrorf("get bazaar package [%s] failed: %s", repoURL, innerErr)
 			return
 		}
-		if 200 != innerResp.StatusCode {
-			logging.LogErrorf("get bazaar package [%s] failed: %d", innerU, innerResp.StatusCode)
-			return
-		}
 
 		if disallowDisplayBazaarPackage(plugin.Package) {
 			return
@@ -82,9 +88,9 @@ func Plugins(frontend string) (plugins []*Plugin) {
 		repoURLHash := strings.Split(repoURL, "@")
 		plugin.RepoURL = "https://github.com/" + repoURLHash[0]
 		plugin.RepoHash = repoURLHash[1]
-		plugin.PreviewURL = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageslim"
-		plugin.PreviewURLThumb = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
-		plugin.IconURL = util.BazaarOSSServer + "/package/" + repoURL + "/icon.png"
+		plugin.PreviewURL = packageServer() + "/package/" + repoURL + "/preview.png?imageslim"
+		plugin.PreviewURLThumb = packageServer() + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
+		plugin.IconURL = packageServer() + "/package/" + repoURL + "/icon.png"
 		plugin.Funding = repo.Package.Funding
 		plugin.PreferredFunding = getPreferredFunding(plugin.Funding)
 		plugin.PreferredName = GetPreferredName(plugin.Package)
@@ -226,7 +232,7 @@ func InstallPlugin(repoURL, repoHash, installPath string, systemID string) error
 	if nil != err {
 		return err
 	}
-	return installPackage(data, installPath, repoURLHash)
+	return installPackage(data, "plugins", installPath, repoURLHash)
 }
 
 func UninstallPlugin(installPath string) error {
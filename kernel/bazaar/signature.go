@@ -0,0 +1,200 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package bazaar
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 集市包签名：集市索引 stage/{type}.json 中包含发布者公钥和每个集市包的签名：
+//
+//	{
+//	  "publishers": {"{owner}": {"publicKeys": ["Base64 Ed25519 公钥"]}},
+//	  "repos": [{"url": "{owner}/{repo}@{hash}", "signature": "Base64 Ed25519 签名", ...}]
+//	}
+//
+// 签名对象是集市包 zip 文件的完整内容。发布者可以配置多个公钥以便轮换。
+//
+// 集市索引和集市包来自同一个来源，为避免索引被篡改后替换公钥，首次校验通过的公钥会被固定（TOFU）在
+// data/storage/bazaar/publishers.json 中，之后该发布者的集市包只使用固定的公钥校验，并且必须带有签名。
+// 发布者轮换公钥时需要用户确认后删除该文件中对应的发布者。
+
+// StagePublisher 描述了集市包发布者。
+type StagePublisher struct {
+	PublicKeys []string `json:"publicKeys"`
+}
+
+var requireSignature atomic.Bool
+
+// SetRequireSignature 设置是否要求集市包必须带有有效签名，用于托管部署。
+func SetRequireSignature(require bool) {
+	requireSignature.Store(require)
+}
+
+// verifyPackageSignature 使用集市索引中的发布者公钥校验集市包签名。
+//
+// 集市包带有签名时签名必须有效；没有签名时仅在要求签名的情况下拒绝安装。
+func verifyPackageSignature(pkgType, repoURLHash string, data []byte) (err error) {
+	repoURLHash = strings.TrimPrefix(repoURLHash, "https://github.com/")
+	required := requireSignature.Load()
+
+	stageIndex, err := getStageIndex(pkgType)
+	if nil != err || nil == stageIndex {
+		if required {
+			return fmt.Errorf("get bazaar index for package [%s] signature verification failed", repoURLHash)
+		}
+		return nil
+	}
+
+	owner := strings.Split(repoURLHash, "/")[0]
+	pinnedKeys := getPinnedPublisherKeys(owner)
+
+	var repo *StageRepo
+	for _, r := range stageIndex.Repos {
+		if r.URL == repoURLHash {
+			repo = r
+			break
+		}
+	}
+	if nil == repo || "" == repo.Signature {
+		if 0 < len(pinnedKeys) {
+			// 签过名的发布者不允许降级为不签名
+			return fmt.Errorf("package [%s] is not signed but its publisher [%s] has pinned public keys", repoURLHash, owner)
+		}
+		if required {
+			return fmt.Errorf("package [%s] is not signed", repoURLHash)
+		}
+		return nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(repo.Signature)
+	if nil != err || ed25519.SignatureSize != len(sig) {
+		return fmt.Errorf("invalid signature of package [%s]", repoURLHash)
+	}
+
+	if 0 < len(pinnedKeys) {
+		// 已经固定公钥的发布者忽略索引中的公钥，索引中的公钥变更不会被信任
+		if "" != verifySignature(owner, pinnedKeys, data, sig) {
+			return nil
+		}
+		return fmt.Errorf("package [%s] is not signed by the pinned public keys of publisher [%s], the publisher keys may have been changed", repoURLHash, owner)
+	}
+
+	publisher := stageIndex.Publishers[owner]
+	if nil == publisher || 1 > len(publisher.PublicKeys) {
+		if required {
+			return fmt.Errorf("publisher [%s] of package [%s] has no public key", owner, repoURLHash)
+		}
+		logging.LogWarnf("publisher [%s] of package [%s] has no public key, skip signature verification", owner, repoURLHash)
+		return nil
+	}
+
+	if key := verifySignature(owner, publisher.PublicKeys, data, sig); "" != key {
+		pinPublisherKey(owner, key)
+		return nil
+	}
+	return errors.New("package [" + repoURLHash + "] signature verification failed")
+}
+
+// verifySignature 使用公钥列表校验签名，返回校验通过的公钥，都不通过时返回空字符串。
+func verifySignature(owner string, publicKeys []string, data, sig []byte) string {
+	for _, publicKey := range publicKeys {
+		publicKey = strings.TrimSpace(publicKey)
+		key, decodeErr := base64.StdEncoding.DecodeString(publicKey)
+		if nil != decodeErr || ed25519.PublicKeySize != len(key) {
+			logging.LogWarnf("invalid public key of publisher [%s]", owner)
+			continue
+		}
+		if ed25519.Verify(key, data, sig) {
+			return publicKey
+		}
+	}
+	return ""
+}
+
+var pinnedPublisherKeysLock = sync.Mutex{}
+
+func getPinnedPublisherKeysPath() string {
+	return filepath.Join(util.DataDir, "storage", "bazaar", "publishers.json")
+}
+
+// loadPinnedPublisherKeys 加载固定的发布者公钥，发布者（GitHub 用户名）-> 公钥。
+func loadPinnedPublisherKeys() (ret map[string][]string) {
+	ret = map[string][]string{}
+	p := getPinnedPublisherKeysPath()
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read pinned publisher keys failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal pinned publisher keys failed: %s", err)
+		ret = map[string][]string{}
+	}
+	return
+}
+
+func getPinnedPublisherKeys(owner string) []string {
+	pinnedPublisherKeysLock.Lock()
+	defer pinnedPublisherKeysLock.Unlock()
+
+	return loadPinnedPublisherKeys()[owner]
+}
+
+func pinPublisherKey(owner, publicKey string) {
+	pinnedPublisherKeysLock.Lock()
+	defer pinnedPublisherKeysLock.Unlock()
+
+	pinned := loadPinnedPublisherKeys()
+	if gulu.Str.Contains(publicKey, pinned[owner]) {
+		return
+	}
+	pinned[owner] = append(pinned[owner], publicKey)
+
+	data, err := gulu.JSON.MarshalIndentJSON(pinned, "", "\t")
+	if nil != err {
+		logging.LogErrorf("marshal pinned publisher keys failed: %s", err)
+		return
+	}
+	p := getPinnedPublisherKeysPath()
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		logging.LogErrorf("create pinned publisher keys dir failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write pinned publisher keys failed: %s", err)
+		return
+	}
+	logging.LogInfof("pinned public key of bazaar publisher [%s]", owner)
+}
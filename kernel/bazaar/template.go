@@ -26,7 +26,6 @@ import (
 
 	"github.com/88250/go-humanize"
 	"github.com/panjf2000/ants/v2"
-	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -59,16 +58,10 @@ func Templates() (templates []*Template) {
 		}
 
 		template := &Template{}
-		innerU := util.BazaarOSSServer + "/package/" + repoURL + "/template.json"
-		innerResp, innerErr := httpclient.NewBrowserRequest().SetSuccessResult(template).Get(innerU)
-		if nil != innerErr {
+		if innerErr := getPackageJSON(repoURL, "template.json", template); nil != innerErr {
 			logging.LogErrorf("get community template [%s] failed: %s", repoURL, innerErr)
 			return
 		}
-		if 200 != innerResp.StatusCode {
-			logging.LogErrorf("get bazaar package [%s] failed: %d", innerU, innerResp.StatusCode)
-			return
-		}
 
 		if disallowDisplayBazaarPackage(template.Package) {
 			return
@@ -78,9 +71,9 @@ func Templates() (templates []*Template) {
 		repoURLHash := strings.Split(repoURL, "@")
 		template.RepoURL = "https://github.com/" + repoURLHash[0]
 		template.RepoHash = repoURLHash[1]
-		template.PreviewURL = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageslim"
-		template.PreviewURLThumb = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
-		template.IconURL = util.BazaarOSSServer + "/package/" + repoURL + "/icon.png"
+		template.PreviewURL = packageServer() + "/package/" + repoURL + "/preview.png?imageslim"
+		template.PreviewURLThumb = packageServer() + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
+		template.IconURL = packageServer() + "/package/" + repoURL + "/icon.png"
 		template.Funding = repo.Package.Funding
 		template.PreferredFunding = getPreferredFunding(template.Funding)
 		template.PreferredName = GetPreferredName(template.Package)
@@ -188,7 +181,7 @@ func InstallTemplate(repoURL, repoHash, installPath string, systemID string) err
 	if nil != err {
 		return err
 	}
-	return installPackage(data, installPath, repoURLHash)
+	return installPackage(data, "templates", installPath, repoURLHash)
 }
 
 func UninstallTemplate(installPath string) error {
@@ -25,7 +25,6 @@ import (
 
 	"github.com/88250/go-humanize"
 	ants "github.com/panjf2000/ants/v2"
-	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -60,14 +59,8 @@ func Themes() (ret []*Theme) {
 		}
 
 		theme := &Theme{}
-		innerU := util.BazaarOSSServer + "/package/" + repoURL + "/theme.json"
-		innerResp, innerErr := httpclient.NewBrowserRequest().SetSuccessResult(theme).Get(innerU)
-		if nil != innerErr {
-			logging.LogErrorf("get bazaar package [%s] failed: %s", innerU, innerErr)
-			return
-		}
-		if 200 != innerResp.StatusCode {
-			logging.LogErrorf("get bazaar package [%s] failed: %d", innerU, innerResp.StatusCode)
+		if innerErr := getPackageJSON(repoURL, "theme.json", theme); nil != innerErr {
+			logging.LogErrorf("get bazaar package [%s] failed: %s", repoURL, innerErr)
 			return
 		}
 
@@ -79,9 +72,9 @@ func Themes() (ret []*Theme) {
 		repoURLHash := strings.Split(repoURL, "@")
 		theme.RepoURL = "https://github.com/" + repoURLHash[0]
 		theme.RepoHash = repoURLHash[1]
-		theme.PreviewURL = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageslim"
-		theme.PreviewURLThumb = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
-		theme.IconURL = util.BazaarOSSServer + "/package/" + repoURL + "/icon.png"
+		theme.PreviewURL = packageServer() + "/package/" + repoURL + "/preview.png?imageslim"
+		theme.PreviewURLThumb = packageServer() + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
+		theme.IconURL = packageServer() + "/package/" + repoURL + "/icon.png"
 		theme.Funding = repo.Package.Funding
 		theme.PreferredFunding = getPreferredFunding(theme.Funding)
 		theme.PreferredName = GetPreferredName(theme.Package)
@@ -193,7 +186,7 @@ func InstallTheme(repoURL, repoHash, installPath string, systemID string) error
 	if nil != err {
 		return err
 	}
-	return installPackage(data, installPath, repoURLHash)
+	return installPackage(data, "themes", installPath, repoURLHash)
 }
 
 func UninstallTheme(installPath string) error {
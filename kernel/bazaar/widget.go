@@ -25,7 +25,6 @@ import (
 
 	"github.com/88250/go-humanize"
 	ants "github.com/panjf2000/ants/v2"
-	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -59,16 +58,10 @@ func Widgets() (widgets []*Widget) {
 		}
 
 		widget := &Widget{}
-		innerU := util.BazaarOSSServer + "/package/" + repoURL + "/widget.json"
-		innerResp, innerErr := httpclient.NewBrowserRequest().SetSuccessResult(widget).Get(innerU)
-		if nil != innerErr {
+		if innerErr := getPackageJSON(repoURL, "widget.json", widget); nil != innerErr {
 			logging.LogErrorf("get bazaar package [%s] failed: %s", repoURL, innerErr)
 			return
 		}
-		if 200 != innerResp.StatusCode {
-			logging.LogErrorf("get bazaar package [%s] failed: %d", innerU, innerResp.StatusCode)
-			return
-		}
 
 		if disallowDisplayBazaarPackage(widget.Package) {
 			return
@@ -78,9 +71,9 @@ func Widgets() (widgets []*Widget) {
 		repoURLHash := strings.Split(repoURL, "@")
 		widget.RepoURL = "https://github.com/" + repoURLHash[0]
 		widget.RepoHash = repoURLHash[1]
-		widget.PreviewURL = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageslim"
-		widget.PreviewURLThumb = util.BazaarOSSServer + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
-		widget.IconURL = util.BazaarOSSServer + "/package/" + repoURL + "/icon.png"
+		widget.PreviewURL = packageServer() + "/package/" + repoURL + "/preview.png?imageslim"
+		widget.PreviewURLThumb = packageServer() + "/package/" + repoURL + "/preview.png?imageView2/2/w/436/h/232"
+		widget.IconURL = packageServer() + "/package/" + repoURL + "/icon.png"
 		widget.Funding = repo.Package.Funding
 		widget.PreferredFunding = getPreferredFunding(widget.Funding)
 		widget.PreferredName = GetPreferredName(widget.Package)
@@ -186,7 +179,7 @@ func InstallWidget(repoURL, repoHash, installPath string, systemID string) error
 	if nil != err {
 		return err
 	}
-	return installPackage(data, installPath, repoURLHash)
+	return installPackage(data, "widgets", installPath, repoURLHash)
 }
 
 func UninstallWidget(installPath string) error {
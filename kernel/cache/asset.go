@@ -65,6 +65,7 @@ func ExistAsset(path string) (ret bool) {
 
 func LoadAssets() {
 	defer logging.Recover()
+	defer util.StartBootStage("assets", false)()
 
 	start := time.Now()
 	assetsLock.Lock()
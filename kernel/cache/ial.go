@@ -27,6 +27,7 @@ var docIALCache, _ = ristretto.NewCache(&ristretto.Config{
 	NumCounters: 102400,
 	MaxCost:     10240,
 	BufferItems: 64,
+	Metrics:     true,
 })
 
 func PutDocIAL(p string, ial map[string]string) {
@@ -58,6 +59,7 @@ var blockIALCache, _ = ristretto.NewCache(&ristretto.Config{
 	NumCounters: 102400,
 	MaxCost:     10240,
 	BufferItems: 64,
+	Metrics:     true,
 })
 
 func PutBlockIAL(id string, ial map[string]string) {
@@ -79,3 +81,18 @@ func RemoveBlockIAL(id string) {
 func ClearBlocksIAL() {
 	blockIALCache.Clear()
 }
+
+// Stat 描述了缓存的命中统计。
+type Stat struct {
+	Name   string
+	Hits   uint64
+	Misses uint64
+}
+
+// IALStats 返回文档和块属性缓存的命中统计。
+func IALStats() []*Stat {
+	return []*Stat{
+		{Name: "doc_ial", Hits: docIALCache.Metrics.Hits(), Misses: docIALCache.Metrics.Misses()},
+		{Name: "block_ial", Hits: blockIALCache.Metrics.Hits(), Misses: blockIALCache.Metrics.Misses()},
+	}
+}
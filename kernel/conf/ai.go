@@ -25,7 +25,83 @@ import (
 )
 
 type AI struct {
-	OpenAI *OpenAI `json:"openAI"`
+	OpenAI    *OpenAI       `json:"openAI"`
+	Providers []*AIProvider `json:"providers"` // 其他 AI 服务提供方
+	Features  *AIFeatures   `json:"features"`  // 各功能使用的提供方和模型
+	Actions   []*AIAction   `json:"actions"`   // 自定义 AI 动作
+
+	SemanticIndex          bool    `json:"semanticIndex"`          // 是否在后台计算文档向量，用于相关文档推荐
+	RelatedBlocksThreshold float64 `json:"relatedBlocksThreshold"` // 相关文档的最低相似度
+	SuggestTags            string  `json:"suggestTags"`            // 为新建或修改的文档生成标签和属性建议：空为关闭，ai 使用 AI 提供方，local 使用本地分类器
+}
+
+const (
+	AIProviderOpenAI    = "openai"    // OpenAI 及兼容 OpenAI API 的服务
+	AIProviderAzure     = "azure"     // Azure OpenAI
+	AIProviderOllama    = "ollama"    // Ollama 本地模型
+	AIProviderLlamaCpp  = "llamacpp"  // llama.cpp server
+	AIProviderAnthropic = "anthropic" // Anthropic
+	AIProviderGemini    = "gemini"    // Google Gemini
+)
+
+// AIProvider 描述了一个 AI 服务提供方。
+type AIProvider struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"` // openai、azure、ollama、llamacpp、anthropic、gemini
+	APIKey     string                 `json:"apiKey"`
+	BaseURL    string                 `json:"baseURL"`
+	APIVersion string                 `json:"apiVersion"` // Azure API 版本或者 Anthropic API 版本
+	Proxy      string                 `json:"proxy"`
+	Timeout    int                    `json:"timeout"` // 秒
+	Options    map[string]interface{} `json:"options"` // 提供方特有的参数，比如 Ollama 的 num_ctx、Gemini 的 safetySettings
+}
+
+// AIFeatures 描述了各功能使用的模型，未配置提供方时使用 OpenAI 设置。
+type AIFeatures struct {
+	Chat       *AIFeatureModel `json:"chat"`
+	Summarize  *AIFeatureModel `json:"summarize"`
+	Embeddings *AIFeatureModel `json:"embeddings"`
+}
+
+type AIFeatureModel struct {
+	Provider    string  `json:"provider"` // 提供方 ID，为空时使用 OpenAI 设置
+	Model       string  `json:"model"`    // 为空时使用提供方的默认模型
+	MaxTokens   int     `json:"maxTokens"`
+	Temperature float64 `json:"temperature"`
+}
+
+const (
+	AIActionOutputReplace = "replace" // 替换选中的块
+	AIActionOutputAppend  = "append"  // 插入到选中的块后面
+	AIActionOutputNewDoc  = "newDoc"  // 在当前文档下创建子文档
+)
+
+// AIAction 描述了一个可复用的 AI 动作。
+type AIAction struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Prompt string          `json:"prompt"` // Go 模板，可以使用 .Content、.Title、.HPath 和 .Blocks
+	Model  *AIFeatureModel `json:"model"`  // 使用的提供方和模型，为空时使用对话功能的设置
+	Output string          `json:"output"` // replace、append 或者 newDoc
+}
+
+func NewAIFeatures() *AIFeatures {
+	return &AIFeatures{
+		Chat:       &AIFeatureModel{},
+		Summarize:  &AIFeatureModel{},
+		Embeddings: &AIFeatureModel{},
+	}
+}
+
+// GetProvider 根据 ID 获取提供方，找不到时返回 nil。
+func (ai *AI) GetProvider(id string) *AIProvider {
+	for _, provider := range ai.Providers {
+		if provider.ID == id {
+			return provider
+		}
+	}
+	return nil
 }
 
 type OpenAI struct {
@@ -94,5 +170,5 @@ func NewAI() *AI {
 	if userAgent := os.Getenv("SIYUAN_OPENAI_API_USER_AGENT"); "" != userAgent {
 		openAI.APIUserAgent = userAgent
 	}
-	return &AI{OpenAI: openAI}
+	return &AI{OpenAI: openAI, Providers: []*AIProvider{}, Features: NewAIFeatures(), Actions: []*AIAction{}, RelatedBlocksThreshold: 0.75}
 }
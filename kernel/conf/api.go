@@ -19,7 +19,18 @@ package conf
 import "github.com/88250/gulu"
 
 type API struct {
+	Token        string         `json:"token"`
+	ScopedTokens []*ScopedToken `json:"scopedTokens"` // 带角色的 API token，用于服务器部署时限制属性视图列和视图的访问
+}
+
+// ScopedToken 描述了带角色的 API token。
+type ScopedToken struct {
 	Token string `json:"token"`
+	Role  string `json:"role"`
+	Memo  string `json:"memo"`
+
+	Subject string `json:"subject,omitempty"` // 通过 OIDC 会话签发时为用户标识
+	Expired int64  `json:"expired,omitempty"` // 过期时间（毫秒），0 表示不过期
 }
 
 func NewAPI() *API {
@@ -0,0 +1,111 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type Asset struct {
+	QuarantineDays      int            `json:"quarantineDays"`      // 清理的未引用资源文件在隔离区保留的天数，0 表示不使用隔离区
+	AutoCleanUnusedDays int            `json:"autoCleanUnusedDays"` // 自动清理未引用资源文件的间隔天数，0 表示不自动清理
+	LastAutoCleanUnused int64          `json:"lastAutoCleanUnused"` // 上次自动清理未引用资源文件的时间
+	Storage             *AssetStorage  `json:"storage"`             // 资源文件存储
+	ImageConvert        *ImageConvert  `json:"imageConvert"`        // 上传图片时的格式转换
+	WatchFolders        []*WatchFolder `json:"watchFolders"`        // 自动导入的监听文件夹
+	ContentIndex        *ContentIndex  `json:"contentIndex"`        // 资源文件内容索引
+}
+
+// ContentIndex 描述了资源文件内容索引的大小限制，大小单位均为 MB。
+type ContentIndex struct {
+	OfficeMaxSize       int64 `json:"officeMaxSize"`       // docx、pptx、xlsx 和 epub 文件的大小上限
+	ArchiveMaxSize      int64 `json:"archiveMaxSize"`      // zip 压缩包的大小上限，0 表示不索引压缩包
+	ArchiveEntryMaxSize int64 `json:"archiveEntryMaxSize"` // 压缩包中单个文件解压后的大小上限
+	ArchiveTotalMaxSize int64 `json:"archiveTotalMaxSize"` // 压缩包中所有文件解压后的总大小上限
+	ArchiveMaxEntries   int   `json:"archiveMaxEntries"`   // 压缩包中索引的文件数量上限
+}
+
+// WatchFolder 描述了一个监听文件夹，文件夹中新增的文件会被自动导入到指定笔记本的指定路径下。
+type WatchFolder struct {
+	ID      string `json:"id"`      // 监听文件夹 ID
+	Enabled bool   `json:"enabled"` // 是否启用
+	Path    string `json:"path"`    // 监听文件夹的绝对路径
+	Box     string `json:"box"`     // 导入的目标笔记本 ID
+	HPath   string `json:"hPath"`   // 导入的目标文档路径，比如 /Inbox/Scans
+	Remove  bool   `json:"remove"`  // 导入后是否删除源文件
+}
+
+// ImageConvert 描述了上传图片时的格式转换配置。
+type ImageConvert struct {
+	Enabled      bool     `json:"enabled"`      // 是否启用
+	Format       string   `json:"format"`       // 转换的目标格式，jpeg 或者 webp
+	Quality      int      `json:"quality"`      // 编码质量，1-100
+	MaxDimension int      `json:"maxDimension"` // 最长边上限，超出时等比缩小，0 表示不缩小
+	Exts         []string `json:"exts"`         // 需要转换格式的图片后缀
+}
+
+// AssetStorage 描述了资源文件的外部存储配置，启用后 data/assets/ 下的资源文件会被转存到对象存储中。
+type AssetStorage struct {
+	Provider        int    `json:"provider"`        // 存储服务提供方，0：本地，1：S3 协议对象存储
+	S3              *S3    `json:"s3"`              // S3 对象存储服务配置
+	Prefix          string `json:"prefix"`          // 对象键前缀
+	ServeMode       int    `json:"serveMode"`       // 读取方式，0：内核代理，1：重定向到签名地址
+	SignedURLExpire int    `json:"signedURLExpire"` // 签名地址有效期，单位：秒
+	HotCacheSize    int64  `json:"hotCacheSize"`    // 本地热缓存上限，单位：MB
+}
+
+const (
+	AssetStorageProviderLocal = 0 // 资源文件存放在本地工作空间
+	AssetStorageProviderS3    = 1 // 资源文件存放在 S3 协议对象存储中
+
+	AssetStorageServeProxy  = 0 // 由内核下载后响应
+	AssetStorageServeSigned = 1 // 重定向到对象存储的签名地址
+)
+
+func NewAsset() *Asset {
+	return &Asset{
+		QuarantineDays: 30,
+		Storage:        NewAssetStorage(),
+		ImageConvert:   NewImageConvert(),
+		ContentIndex:   NewContentIndex(),
+	}
+}
+
+func NewContentIndex() *ContentIndex {
+	return &ContentIndex{
+		OfficeMaxSize:       64,
+		ArchiveMaxSize:      128,
+		ArchiveEntryMaxSize: 16,
+		ArchiveTotalMaxSize: 256,
+		ArchiveMaxEntries:   1024,
+	}
+}
+
+func NewImageConvert() *ImageConvert {
+	return &ImageConvert{
+		Enabled: false,
+		Format:  "jpeg",
+		Quality: 85,
+		Exts:    []string{".heic", ".heif", ".tif", ".tiff", ".bmp"},
+	}
+}
+
+func NewAssetStorage() *AssetStorage {
+	return &AssetStorage{
+		Provider:        AssetStorageProviderLocal,
+		S3:              &S3{PathStyle: true, Timeout: 60},
+		ServeMode:       AssetStorageServeProxy,
+		SignedURLExpire: 3600,
+		HotCacheSize:    1024,
+	}
+}
@@ -17,13 +17,22 @@
 package conf
 
 type Bazaar struct {
-	Trust         bool `json:"trust"`
-	PetalDisabled bool `json:"petalDisabled"`
+	Trust            bool   `json:"trust"`
+	PetalDisabled    bool   `json:"petalDisabled"`
+	Mirror           string `json:"mirror"`           // 自建集市镜像地址，可以是 http(s) 地址或者本地文件夹路径，为空时使用官方集市
+	MirrorPublicKey  string `json:"mirrorPublicKey"`  // 镜像清单签名公钥（Ed25519，Base64），为空时不校验清单签名
+	RequireSignature bool   `json:"requireSignature"` // 是否要求集市包必须带有发布者的有效签名，也可以通过环境变量 SIYUAN_BAZAAR_REQUIRE_SIGNATURE 强制开启
+
+	PetalStorageQuota   int `json:"petalStorageQuota"`   // 每个插件的存储配额，单位：MB
+	PetalJobConcurrency int `json:"petalJobConcurrency"` // 插件定时任务的并发执行数
 }
 
 func NewBazaar() *Bazaar {
 	return &Bazaar{
 		Trust:         false,
 		PetalDisabled: false,
+
+		PetalStorageQuota:   16,
+		PetalJobConcurrency: 2,
 	}
 }
@@ -31,6 +31,9 @@ type BoxConf struct {
 	DailyNoteSavePath     string `json:"dailyNoteSavePath"`     // 新建日记存储路径
 	DailyNoteTemplatePath string `json:"dailyNoteTemplatePath"` // 新建日记使用的模板路径
 	SortMode              int    `json:"sortMode"`              // 排序方式
+
+	HistoryRetentionDays    int `json:"historyRetentionDays"`    // 历史保留天数，0 表示使用全局设置
+	GenerateHistoryInterval int `json:"generateHistoryInterval"` // 生成历史时间间隔，单位：分钟，0 表示使用全局设置，-1 表示不生成历史
 }
 
 func NewBoxConf() *BoxConf {
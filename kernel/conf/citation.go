@@ -0,0 +1,39 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// Citation 描述了文献引用配置。
+type Citation struct {
+	Source            string `json:"source"`            // 文献库来源，zotero：Zotero Better BibTeX，csl：本地 CSL JSON 文件，为空表示未启用
+	ZoteroURL         string `json:"zoteroURL"`         // Better BibTeX JSON-RPC 端点
+	LibraryPath       string `json:"libraryPath"`       // CSL JSON 文件路径，相对路径基于工作空间 data 目录
+	Style             string `json:"style"`             // 引用样式，可选 apa、chicago-author-date、mla、ieee、harvard
+	BibliographyTitle string `json:"bibliographyTitle"` // 导出时参考文献小节的标题，为空时使用默认标题
+}
+
+const (
+	CitationSourceZotero = "zotero"
+	CitationSourceCSL    = "csl"
+)
+
+func NewCitation() *Citation {
+	return &Citation{
+		Source:    "",
+		ZoteroURL: "http://127.0.0.1:23119/better-bibtex/json-rpc",
+		Style:     "apa",
+	}
+}
@@ -0,0 +1,36 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// DataSource 描述了属性视图外部数据源的配置。
+type DataSource struct {
+	Secrets map[string]*DataSourceSecret `json:"secrets"` // 属性视图 ID -> 访问凭据
+}
+
+// DataSourceSecret 描述了属性视图外部数据源的访问凭据。
+//
+// 属性视图 JSON 会随数据同步、导出，并且可以通过 API 读取，所以凭据只保存在配置中。
+type DataSourceSecret struct {
+	AirtableToken string            `json:"airtableToken,omitempty"` // Airtable 访问令牌
+	Headers       map[string]string `json:"headers,omitempty"`       // 请求头
+}
+
+func NewDataSource() *DataSource {
+	return &DataSource{
+		Secrets: map[string]*DataSourceSecret{},
+	}
+}
@@ -40,6 +40,10 @@ type Export struct {
 	PDFWatermarkDesc      string `json:"pdfWatermarkDesc"`      // PDF 导出时水印位置、大小和样式等
 	ImageWatermarkStr     string `json:"imageWatermarkStr"`     // 图片导出时水印文本或水印文件路径
 	ImageWatermarkDesc    string `json:"imageWatermarkDesc"`    // 图片导出时水印位置、大小和样式等
+	DiagramExportMode     int    `json:"diagramExportMode"`     // 图表导出模式，0：渲染为图片，1：保留源码。仅作用于 PDF、HTML、Word 和分享页面
+	DiagramFormat         string `json:"diagramFormat"`         // 图表渲染格式，svg 或 png
+	MermaidBin            string `json:"mermaidBin"`            // Mermaid CLI（mmdc）可执行文件路径，为空时从 PATH 中查找
+	GraphvizBin           string `json:"graphvizBin"`           // Graphviz（dot）可执行文件路径，为空时从 PATH 中查找
 }
 
 func NewExport() *Export {
@@ -56,5 +60,7 @@ func NewExport() *Export {
 		PandocBin:               "",
 		MarkdownYFM:             false,
 		PDFFooter:               "%page / %pages",
+		DiagramExportMode:       0,
+		DiagramFormat:           "svg",
 	}
 }
@@ -0,0 +1,30 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// Monitor 描述了运行监控配置，用于自托管时观测和剖析内核。
+type Monitor struct {
+	Metrics bool `json:"metrics"` // 是否开放 /metrics 指标端点（Prometheus 文本格式）
+	Pprof   bool `json:"pprof"`   // 是否在生产环境开放 /debug/pprof/ 性能剖析端点
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{
+		Metrics: false,
+		Pprof:   false,
+	}
+}
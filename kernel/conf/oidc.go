@@ -0,0 +1,51 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// OIDC 描述了 OpenID Connect 单点登录配置，用于服务器部署时通过外部身份提供方登录。
+type OIDC struct {
+	Enabled      bool               `json:"enabled"`
+	Issuer       string             `json:"issuer"`       // 身份提供方地址，用于拉取 /.well-known/openid-configuration
+	ClientID     string             `json:"clientID"`     // 客户端 ID
+	ClientSecret string             `json:"clientSecret"` // 客户端密钥
+	RedirectURL  string             `json:"redirectURL"`  // 回调地址，需要在身份提供方登记，形如 https://example.com/api/oidc/callback
+	Scopes       []string           `json:"scopes"`       // 授权范围，openid 会自动添加
+	GroupsClaim  string             `json:"groupsClaim"`  // 用户组声明字段名
+	RoleMappings []*OIDCRoleMapping `json:"roleMappings"` // 用户组到角色的映射，按顺序匹配
+	DefaultRole  string             `json:"defaultRole"`  // 没有匹配到用户组时使用的角色，为空时拒绝登录
+	SessionTTL   int                `json:"sessionTTL"`   // 会话有效期（小时）
+	TokenTTL     int                `json:"tokenTTL"`     // 通过会话签发的 API token 有效期（小时）
+}
+
+// OIDCRoleMapping 描述了用户组到角色的映射。
+type OIDCRoleMapping struct {
+	Group string `json:"group"`
+	Role  string `json:"role"` // admin 表示不受限，其他值同带角色的 API token
+}
+
+// OIDCRoleAdmin 表示不受属性视图访问限制的管理员角色。
+const OIDCRoleAdmin = "admin"
+
+func NewOIDC() *OIDC {
+	return &OIDC{
+		Scopes:       []string{"openid", "profile", "email"},
+		GroupsClaim:  "groups",
+		RoleMappings: []*OIDCRoleMapping{},
+		SessionTTL:   24 * 7,
+		TokenTTL:     24 * 30,
+	}
+}
@@ -0,0 +1,52 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// Performance 描述了重建索引等耗时操作的性能配置。
+type Performance struct {
+	IndexWorkers      int  `json:"indexWorkers"`      // 重建索引时并发解析文档和构建块的协程数，0 表示按 CPU 核数自动确定
+	IndexBatchSize    int  `json:"indexBatchSize"`    // 重建索引时每个数据库事务写入的文档数
+	SQLFlushInterval  int  `json:"sqlFlushInterval"`  // 数据库队列写入间隔（毫秒）
+	SQLFlushBatchSize int  `json:"sqlFlushBatchSize"` // 数据库队列每次写入的最大操作数，队列超过该值时提前写入，0 表示不限制
+	BlockCacheSize    int  `json:"blockCacheSize"`    // 块缓存的内存预算（MB）
+	DeferBootTasks    bool `json:"deferBootTasks"`    // 是否将资源文件 OCR、集市刷新等非关键启动任务推迟到界面可交互后执行
+	TreeSaveDebounce  int  `json:"treeSaveDebounce"`  // 文档保存防抖窗口（毫秒），窗口内的多次保存合并为一次写入，0 表示不防抖
+}
+
+const (
+	MaxIndexWorkers   = 32
+	MaxIndexBatchSize = 1024
+
+	MinSQLFlushInterval  = 200
+	MaxSQLFlushInterval  = 60 * 1000
+	MaxSQLFlushBatchSize = 100000
+
+	MaxBlockCacheSize = 4096
+
+	MaxTreeSaveDebounce = 10 * 1000
+)
+
+func NewPerformance() *Performance {
+	return &Performance{
+		IndexWorkers:      0,
+		IndexBatchSize:    64,
+		SQLFlushInterval:  3000,
+		SQLFlushBatchSize: 0,
+		BlockCacheSize:    64,
+		TreeSaveDebounce:  1000,
+	}
+}
@@ -0,0 +1,55 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// Quota 描述了工作空间级别的资源配额，避免单个异常文档拖垮整个工作空间。
+type Quota struct {
+	MaxDocBlocks    int                       `json:"maxDocBlocks"`    // 单个文档的最大块数，0 表示不限制
+	MaxEmbedResults int                       `json:"maxEmbedResults"` // 嵌入块的最大查询结果数，0 表示不限制
+	MaxAssetSize    int                       `json:"maxAssetSize"`    // 单个资源文件的最大大小（MB），0 表示不限制
+	Overrides       map[string]*QuotaOverride `json:"overrides"`       // 按文档 ID 覆盖配额
+}
+
+// QuotaOverride 描述了单个文档的配额覆盖，-1 表示沿用工作空间配额，0 表示不限制。
+type QuotaOverride struct {
+	MaxDocBlocks    int `json:"maxDocBlocks"`
+	MaxEmbedResults int `json:"maxEmbedResults"`
+	MaxAssetSize    int `json:"maxAssetSize"`
+}
+
+const (
+	MaxQuotaDocBlocks    = 10000000
+	MaxQuotaEmbedResults = 100000
+	MaxQuotaAssetSize    = 100 * 1024
+)
+
+func NewQuota() *Quota {
+	return &Quota{
+		MaxDocBlocks:    100000,
+		MaxEmbedResults: 1024,
+		MaxAssetSize:    0,
+		Overrides:       map[string]*QuotaOverride{},
+	}
+}
+
+func NewQuotaOverride() *QuotaOverride {
+	return &QuotaOverride{
+		MaxDocBlocks:    -1,
+		MaxEmbedResults: -1,
+		MaxAssetSize:    -1,
+	}
+}
@@ -0,0 +1,30 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// Reminder 描述了块提醒的通知配置。
+type Reminder struct {
+	SystemNotification bool     `json:"systemNotification"` // 桌面端是否同时发送系统通知
+	Webhooks           []string `json:"webhooks"`           // 提醒触发时回调的地址
+}
+
+func NewReminder() *Reminder {
+	return &Reminder{
+		SystemNotification: true,
+		Webhooks:           []string{},
+	}
+}
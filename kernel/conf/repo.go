@@ -29,11 +29,15 @@ type Repo struct {
 	// If the data repo indexing time is greater than 12s, prompt user to purge the data repo https://github.com/siyuan-note/siyuan/issues/9613
 	// Supports configuring data sync index time-consuming prompts https://github.com/siyuan-note/siyuan/issues/9698
 	SyncIndexTiming int64 `json:"syncIndexTiming"`
+
+	// 历史和快照事件回调地址，生成文件历史或者数据快照后会将事件 POST 到这些地址，用于外部备份脚本增量同步
+	EventWebhooks []string `json:"eventWebhooks"`
 }
 
 func NewRepo() *Repo {
 	return &Repo{
 		SyncIndexTiming: 12 * 1000,
+		EventWebhooks:   []string{},
 	}
 }
 
@@ -38,12 +38,15 @@ type System struct {
 
 	NetworkServe bool          `json:"networkServe"` // 是否开启网络伺服
 	NetworkProxy *NetworkProxy `json:"networkProxy"`
+	NetworkTLS   *NetworkTLS   `json:"networkTLS"` // 通过 ACME 自动申请证书并直接提供 HTTPS 服务
+	NetworkACL   *NetworkACL   `json:"networkACL"` // 网络访问控制列表
 
 	UploadErrLog           bool `json:"uploadErrLog"`
 	DisableGoogleAnalytics bool `json:"disableGoogleAnalytics"`
 	DownloadInstallPkg     bool `json:"downloadInstallPkg"`
-	AutoLaunch2            int  `json:"autoLaunch2"`    // 0：不自动启动，1：自动启动，2：自动启动+隐藏主窗口
-	LockScreenMode         int  `json:"lockScreenMode"` // 0：手动，1：手动+跟随系统 https://github.com/siyuan-note/siyuan/issues/9087
+	AutoLaunch2            int  `json:"autoLaunch2"`        // 0：不自动启动，1：自动启动，2：自动启动+隐藏主窗口
+	LockScreenMode         int  `json:"lockScreenMode"`     // 0：手动，1：手动+跟随系统 https://github.com/siyuan-note/siyuan/issues/9087
+	DisableDataWatcher     bool `json:"disableDataWatcher"` // 是否关闭数据文件变更监听，macOS 上通过轮询监听，数据较多时可以关闭以降低资源占用
 }
 
 func NewSystem() *System {
@@ -52,6 +55,8 @@ func NewSystem() *System {
 		Name:               util.GetDeviceName(),
 		KernelVersion:      util.Ver,
 		NetworkProxy:       &NetworkProxy{},
+		NetworkTLS:         NewNetworkTLS(),
+		NetworkACL:         NewNetworkACL(),
 		DownloadInstallPkg: true,
 	}
 }
@@ -68,3 +73,47 @@ func (np *NetworkProxy) String() string {
 	}
 	return np.Scheme + "://" + np.Host + ":" + np.Port
 }
+
+// NetworkTLS 描述了内核通过 ACME（例如 Let's Encrypt）申请和续期证书的配置。
+type NetworkTLS struct {
+	Enabled      bool     `json:"enabled"`
+	Domains      []string `json:"domains"`      // 证书域名，需要解析到当前主机
+	Email        string   `json:"email"`        // ACME 帐号邮箱，用于接收证书过期提醒
+	Challenge    string   `json:"challenge"`    // 验证方式：http-01 或者 dns-01
+	DNSHook      string   `json:"dnsHook"`      // dns-01 时设置 TXT 记录的可执行文件的绝对路径，调用参数为 present/cleanup、记录名和记录值，仅能通过配置文件设置
+	DNSWait      int      `json:"dnsWait"`      // dns-01 时等待 TXT 记录生效的秒数
+	DirectoryURL string   `json:"directoryURL"` // ACME 服务目录地址，为空时使用 Let's Encrypt
+	HTTPSPort    int      `json:"httpsPort"`    // HTTPS 服务端口
+	HTTPPort     int      `json:"httpPort"`     // http-01 验证和跳转到 HTTPS 使用的端口，0 表示不监听
+}
+
+const (
+	ACMEChallengeHTTP01 = "http-01"
+	ACMEChallengeDNS01  = "dns-01"
+)
+
+func NewNetworkTLS() *NetworkTLS {
+	return &NetworkTLS{
+		Domains:   []string{},
+		Challenge: ACMEChallengeHTTP01,
+		DNSWait:   60,
+		HTTPSPort: 443,
+		HTTPPort:  80,
+	}
+}
+
+// NetworkACL 描述了按客户端 IP 限制访问内核服务的配置，回环地址总是允许访问。
+type NetworkACL struct {
+	Enabled        bool     `json:"enabled"`
+	Allow          []string `json:"allow"`          // 允许访问的 IP 或 CIDR，为空时允许所有未被拒绝的地址
+	Deny           []string `json:"deny"`           // 拒绝访问的 IP 或 CIDR，优先于允许列表
+	TrustedProxies []string `json:"trustedProxies"` // 受信任的反向代理 IP 或 CIDR，仅使用来自这些地址的 X-Forwarded-For 和 X-Real-IP
+}
+
+func NewNetworkACL() *NetworkACL {
+	return &NetworkACL{
+		Allow:          []string{},
+		Deny:           []string{},
+		TrustedProxies: []string{},
+	}
+}
@@ -0,0 +1,31 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type Template struct {
+	HTTPAllowDomains []string `json:"httpAllowDomains"` // 模板中 httpGet/httpPostJSON 允许访问的域名，为空时禁止访问网络
+	HTTPTimeout      int      `json:"httpTimeout"`      // 模板网络请求超时，单位：秒
+	DateFuncs        bool     `json:"dateFuncs"`        // 是否启用扩展的日期计算函数（dateAdd、startOf、formatDate 等），关闭时与旧版本模板保持兼容
+}
+
+func NewTemplate() *Template {
+	return &Template{
+		HTTPAllowDomains: []string{},
+		HTTPTimeout:      10,
+		DateFuncs:        true,
+	}
+}
@@ -0,0 +1,38 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// TOTP 描述了浏览器访问时在访问授权码之外的 TOTP 两步验证配置。
+type TOTP struct {
+	Enabled       bool     `json:"enabled"`
+	Secret        string   `json:"secret"`        // Base32 编码的共享密钥
+	RecoveryCodes []string `json:"recoveryCodes"` // 恢复码的 SHA-256 哈希，每个恢复码只能使用一次
+	Enforce       string   `json:"enforce"`       // 强制范围：remote 仅非本机访问，all 包括本机访问
+}
+
+const (
+	TOTPEnforceRemote = "remote"
+	TOTPEnforceAll    = "all"
+	TOTPEnforceOff    = "off" // 仅用于环境变量 SIYUAN_TOTP_ENFORCE 临时关闭
+)
+
+func NewTOTP() *TOTP {
+	return &TOTP{
+		RecoveryCodes: []string{},
+		Enforce:       TOTPEnforceRemote,
+	}
+}
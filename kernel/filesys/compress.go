@@ -0,0 +1,164 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// .sy 文件可以使用 gzip 或者 zstd 压缩存储，压缩后文件名不变，读取时根据文件头透明解压。
+// 默认不压缩，以便和不支持压缩的旧版本通过同步共享数据。
+
+const (
+	SyCompressionNone = ""
+	SyCompressionGzip = "gzip"
+	SyCompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	syZstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	syZstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// IsValidSyCompression 判断压缩算法是否受支持。
+func IsValidSyCompression(compression string) bool {
+	return SyCompressionNone == compression || SyCompressionGzip == compression || SyCompressionZstd == compression
+}
+
+// SyCompressionOf 返回数据使用的压缩算法，未压缩时返回空字符串。
+func SyCompressionOf(data []byte) string {
+	if bytes.HasPrefix(data, zstdMagic) {
+		return SyCompressionZstd
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		return SyCompressionGzip
+	}
+	return SyCompressionNone
+}
+
+// IsSyCompressed 判断数据是否已经压缩。
+func IsSyCompressed(data []byte) bool {
+	return SyCompressionNone != SyCompressionOf(data)
+}
+
+// DecodeSyData 解压 .sy 数据，未压缩的数据原样返回。
+func DecodeSyData(data []byte) (ret []byte, err error) {
+	switch SyCompressionOf(data) {
+	case SyCompressionZstd:
+		return syZstdDecoder.DecodeAll(data, nil)
+	case SyCompressionGzip:
+		reader, gzErr := gzip.NewReader(bytes.NewReader(data))
+		if nil != gzErr {
+			return nil, gzErr
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	return data, nil
+}
+
+// EncodeSyData 使用指定的压缩算法压缩 .sy 数据，已经压缩过的数据会先解压。
+func EncodeSyData(data []byte, compression string) (ret []byte, err error) {
+	if data, err = DecodeSyData(data); nil != err {
+		return
+	}
+
+	switch compression {
+	case SyCompressionZstd:
+		ret = syZstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/4))
+	case SyCompressionGzip:
+		buf := bytes.Buffer{}
+		buf.Grow(len(data) / 4)
+		writer := gzip.NewWriter(&buf)
+		if _, err = writer.Write(data); nil != err {
+			return
+		}
+		if err = writer.Close(); nil != err {
+			return
+		}
+		ret = buf.Bytes()
+	default:
+		ret = data
+	}
+	return
+}
+
+// ReadSyFile 读取 .sy 文件，压缩过的文件会被透明解压，等待防抖写入的文档返回最新数据。
+func ReadSyFile(absPath string) (ret []byte, err error) {
+	if data, ok := pendingTreeData(absPath); ok {
+		return data, nil
+	}
+
+	if ret, err = filelock.ReadFile(absPath); nil != err {
+		return
+	}
+
+	if ret, err = DecodeSyData(ret); nil != err {
+		logging.LogErrorf("decompress [%s] failed: %s", absPath, err)
+	}
+	return
+}
+
+// WriteSyFile 按照当前的压缩设置写入 .sy 文件。
+func WriteSyFile(absPath string, data []byte) (err error) {
+	if data, err = EncodeSyData(data, util.SyCompression); nil != err {
+		return
+	}
+	if err = filelock.WriteFile(absPath, data); nil != err {
+		return
+	}
+	RecordWrittenFile(absPath)
+	return
+}
+
+// ConvertSyFile 将 .sy 文件转换为指定的压缩算法，保留文件的修改时间，返回文件是否被改写。
+func ConvertSyFile(absPath, compression string) (converted bool, err error) {
+	info, err := os.Stat(absPath)
+	if nil != err {
+		return
+	}
+
+	data, err := filelock.ReadFile(absPath)
+	if nil != err {
+		return
+	}
+	if compression == SyCompressionOf(data) {
+		return
+	}
+
+	if data, err = EncodeSyData(data, compression); nil != err {
+		return
+	}
+	if err = filelock.WriteFile(absPath, data); nil != err {
+		return
+	}
+	os.Chtimes(absPath, info.ModTime(), info.ModTime())
+	RecordWrittenFile(absPath)
+	converted = true
+	return
+}
@@ -28,6 +28,10 @@ import (
 )
 
 func ParseJSONWithoutFix(jsonData []byte, options *parse.Options) (ret *parse.Tree, err error) {
+	if jsonData, err = DecodeSyData(jsonData); nil != err {
+		return
+	}
+
 	root := &ast.Node{}
 	err = unmarshalJSON(jsonData, root)
 	if nil != err {
@@ -50,6 +54,10 @@ func ParseJSONWithoutFix(jsonData []byte, options *parse.Options) (ret *parse.Tr
 }
 
 func ParseJSON(jsonData []byte, options *parse.Options) (ret *parse.Tree, needFix bool, err error) {
+	if jsonData, err = DecodeSyData(jsonData); nil != err {
+		return
+	}
+
 	root := &ast.Node{}
 	err = unmarshalJSON(jsonData, root)
 	if nil != err {
@@ -29,7 +29,6 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -38,7 +37,7 @@ import (
 
 func LoadTree(boxID, p string, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
 	filePath := filepath.Join(util.DataDir, boxID, p)
-	data, err := filelock.ReadFile(filePath)
+	data, err := ReadSyFile(filePath)
 	if nil != err {
 		logging.LogErrorf("load tree [%s] failed: %s", p, err)
 		return
@@ -79,7 +78,7 @@ func LoadTreeByData(data []byte, boxID, p string, luteEngine *lute.Lute) (ret *p
 		parentAbsPath += ".sy"
 		parentPath := parentAbsPath
 		parentAbsPath = filepath.Join(util.DataDir, boxID, parentAbsPath)
-		parentData, readErr := filelock.ReadFile(parentAbsPath)
+		parentData, readErr := ReadSyFile(parentAbsPath)
 		if nil != readErr {
 			if os.IsNotExist(readErr) {
 				// 子文档缺失父文档时自动补全 https://github.com/siyuan-note/siyuan/issues/7376
@@ -120,7 +119,7 @@ func WriteTree(tree *parse.Tree) (err error) {
 		return
 	}
 
-	if err = filelock.WriteFile(filePath, data); nil != err {
+	if err = writeTreeDirectly(filePath, data); nil != err {
 		msg := fmt.Sprintf("write data [%s] failed: %s", filePath, err)
 		logging.LogErrorf(msg)
 		return errors.New(msg)
@@ -205,7 +204,7 @@ func parseJSON2Tree(boxID, p string, jsonData []byte, luteEngine *lute.Lute) (re
 		if err = os.MkdirAll(filepath.Dir(filePath), 0755); nil != err {
 			return
 		}
-		if err = filelock.WriteFile(filePath, data); nil != err {
+		if err = WriteSyFile(filePath, data); nil != err {
 			msg := fmt.Sprintf("write data [%s] failed: %s", filePath, err)
 			logging.LogErrorf(msg)
 		}
@@ -215,6 +214,10 @@ func parseJSON2Tree(boxID, p string, jsonData []byte, luteEngine *lute.Lute) (re
 
 func ReadDocIAL(data []byte) (ret map[string]string) {
 	ret = map[string]string{}
+	data, err := DecodeSyData(data)
+	if nil != err {
+		return
+	}
 	val := jsoniter.Get(data, "Properties")
 	if nil == val || val.ValueType() == jsoniter.InvalidValue {
 		return
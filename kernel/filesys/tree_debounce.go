@@ -0,0 +1,363 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 文档保存防抖：事务提交时先将文档数据压缩后追加到保存日志并落盘，然后在窗口期结束后再写入 .sy 文件，
+// 窗口期内同一文档的多次保存会被合并为一次写入。启动时回放日志，保证崩溃后不丢失已经提交的修改。
+//
+// 等待写入期间通过 ReadSyFile 和 LoadTree 读取到的是最新数据；移动、删除文档和数据快照前需要调用 FlushTreeSaves 写入。
+// 等待期间 .sy 文件被直接写入或者删除时（比如回滚文档历史、导入），等待中的数据已经过期，会被丢弃。
+
+var (
+	treeSaveDebounce atomic.Int64 // 防抖窗口（毫秒），0 表示不防抖
+
+	pendingTrees     = map[string]*pendingTree{} // 等待写入的文档，键为文件绝对路径
+	pendingTreesLock = sync.Mutex{}
+	flushTreesLock   = sync.Mutex{} // 保证同一文档的数据按提交顺序写入
+
+	treeJournal     *os.File
+	treeJournalSize int64
+)
+
+const maxTreeJournalSize = 64 * 1024 * 1024 // 保存日志超过该大小时立即写入所有文档并截断日志
+
+type pendingTree struct {
+	data     []byte
+	first    time.Time // 首次等待写入的时间，用于限制最长等待时间
+	deadline time.Time
+	modTime  time.Time // 最近一次等待写入时 .sy 文件的修改时间和大小，不一致时说明文件被直接写入了
+	size     int64
+}
+
+// isOverwritten 判断等待期间 .sy 文件是否被直接写入或者删除。
+func (pending *pendingTree) isOverwritten(filePath string) bool {
+	info, err := os.Stat(filePath)
+	return nil != err || !info.ModTime().Equal(pending.modTime) || info.Size() != pending.size
+}
+
+// SetTreeSaveDebounce 设置文档保存的防抖窗口，关闭防抖时立即写入所有等待中的文档。
+func SetTreeSaveDebounce(window time.Duration) {
+	treeSaveDebounce.Store(window.Milliseconds())
+	if 0 >= window {
+		FlushTreeSaves()
+	}
+}
+
+// WriteTreeDebounced 保存文档，开启防抖且文档已经存在时先写保存日志，稍后再写入 .sy 文件。
+func WriteTreeDebounced(tree *parse.Tree) (err error) {
+	window := time.Duration(treeSaveDebounce.Load()) * time.Millisecond
+	filePath := filepath.Join(util.DataDir, tree.Box, tree.Path)
+	if 0 >= window || !filelock.IsExist(filePath) {
+		return WriteTree(tree)
+	}
+
+	data, filePath, err := prepareWriteTree(tree)
+	if nil != err {
+		return
+	}
+
+	if err = queueTreeSave(filePath, data, window); nil != err {
+		logging.LogWarnf("queue tree save failed, write [%s] directly: %s", filePath, err)
+		return WriteTree(tree)
+	}
+
+	afterWriteTree(tree)
+	return
+}
+
+func queueTreeSave(filePath string, data []byte, window time.Duration) (err error) {
+	// 等待正在进行的写入完成，否则记录的文件修改时间会在写入后失效
+	flushTreesLock.Lock()
+	defer flushTreesLock.Unlock()
+
+	info, err := os.Stat(filePath)
+	if nil != err {
+		return
+	}
+
+	pendingTreesLock.Lock()
+	defer pendingTreesLock.Unlock()
+	if err = appendTreeJournal(filePath, data); nil != err {
+		return
+	}
+
+	now := time.Now()
+	pending := pendingTrees[filePath]
+	if nil == pending {
+		pending = &pendingTree{first: now}
+		pendingTrees[filePath] = pending
+	}
+	pending.data = data
+	pending.deadline = now.Add(window)
+	if maxDeadline := pending.first.Add(window * 5); pending.deadline.After(maxDeadline) {
+		pending.deadline = maxDeadline
+	}
+	pending.modTime, pending.size = info.ModTime(), info.Size()
+	return
+}
+
+// FlushTreeSavesJob 写入防抖窗口已经结束的文档。
+func FlushTreeSavesJob() {
+	flushTreeSaves(false)
+}
+
+// FlushTreeSaves 立即写入所有等待中的文档。
+func FlushTreeSaves() {
+	flushTreeSaves(true)
+}
+
+// PendingTreeCount 返回等待写入的文档数。
+func PendingTreeCount() int {
+	pendingTreesLock.Lock()
+	defer pendingTreesLock.Unlock()
+	return len(pendingTrees)
+}
+
+func flushTreeSaves(all bool) {
+	flushTreesLock.Lock()
+	defer flushTreesLock.Unlock()
+
+	pendingTreesLock.Lock()
+	now := time.Now()
+	all = all || maxTreeJournalSize < treeJournalSize
+	flushing := map[string][]byte{}
+	for filePath, pending := range pendingTrees {
+		if pending.isOverwritten(filePath) {
+			// 等待期间文档已经被直接写入、删除或者移动
+			discardPendingTree(filePath)
+			continue
+		}
+		if all || now.After(pending.deadline) {
+			flushing[filePath] = pending.data
+			delete(pendingTrees, filePath)
+		}
+	}
+	pendingTreesLock.Unlock()
+
+	for filePath, data := range flushing {
+		if err := WriteSyFile(filePath, data); nil != err {
+			logging.LogErrorf("write data [%s] failed: %s", filePath, err)
+		}
+	}
+
+	pendingTreesLock.Lock()
+	if 1 > len(pendingTrees) {
+		truncateTreeJournal()
+	}
+	pendingTreesLock.Unlock()
+}
+
+// writeTreeDirectly 直接写入文档并丢弃等待中的旧数据。
+func writeTreeDirectly(filePath string, data []byte) (err error) {
+	flushTreesLock.Lock()
+	defer flushTreesLock.Unlock()
+
+	pendingTreesLock.Lock()
+	if _, ok := pendingTrees[filePath]; ok {
+		discardPendingTree(filePath)
+	}
+	pendingTreesLock.Unlock()
+	return WriteSyFile(filePath, data)
+}
+
+func pendingTreeData(filePath string) (ret []byte, ok bool) {
+	pendingTreesLock.Lock()
+	defer pendingTreesLock.Unlock()
+	pending := pendingTrees[filePath]
+	if nil == pending {
+		return
+	}
+	if pending.isOverwritten(filePath) {
+		discardPendingTree(filePath)
+		return
+	}
+	return bytes.Clone(pending.data), true
+}
+
+// discardPendingTree 丢弃等待中的数据，并在保存日志中记录，避免启动时回放覆盖之后写入的数据。调用方需要持有 pendingTreesLock。
+func discardPendingTree(filePath string) {
+	delete(pendingTrees, filePath)
+	if 1 > len(pendingTrees) {
+		truncateTreeJournal()
+		return
+	}
+	if err := appendTreeJournal(filePath, nil); nil != err {
+		logging.LogErrorf("append tree journal failed: %s", err)
+	}
+}
+
+// 保存日志的每条记录为：CRC32（4 字节）+ 路径长度（4 字节）+ 数据长度（4 字节）+ 相对 data 目录的路径 + zstd 压缩的文档数据。
+// 数据为空的记录表示丢弃该文档之前的记录。
+
+func treeJournalPath() string {
+	return filepath.Join(util.TempDir, "tree.journal")
+}
+
+func appendTreeJournal(filePath string, data []byte) (err error) {
+	if nil == treeJournal {
+		if treeJournal, err = os.OpenFile(treeJournalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); nil != err {
+			treeJournal = nil
+			return
+		}
+	}
+
+	p, err := filepath.Rel(util.DataDir, filePath)
+	if nil != err {
+		return
+	}
+	p = filepath.ToSlash(p)
+	var compressed []byte
+	if 0 < len(data) {
+		compressed = syZstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/4))
+	}
+
+	record := encodeTreeJournalRecord(p, compressed)
+	if _, err = treeJournal.Write(record); nil != err {
+		return
+	}
+	if err = treeJournal.Sync(); nil != err {
+		return
+	}
+	treeJournalSize += int64(len(record))
+	return
+}
+
+func truncateTreeJournal() {
+	if nil == treeJournal || 1 > treeJournalSize {
+		return
+	}
+
+	if err := treeJournal.Truncate(0); nil != err {
+		logging.LogErrorf("truncate tree journal failed: %s", err)
+		return
+	}
+	treeJournalSize = 0
+}
+
+// ReplayTreeJournal 回放上次退出前没有写入的文档，启动时在加载文档之前调用。
+func ReplayTreeJournal() {
+	journalPath := treeJournalPath()
+	file, err := os.Open(journalPath)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			logging.LogErrorf("open tree journal failed: %s", err)
+		}
+		return
+	}
+
+	latest := map[string][]byte{}
+	reader := bufio.NewReader(file)
+	for {
+		p, data, readErr := readTreeJournalRecord(reader)
+		if nil != readErr {
+			if io.EOF != readErr {
+				// 最后一条记录可能只写了一部分，忽略即可
+				logging.LogWarnf("read tree journal stopped: %s", readErr)
+			}
+			break
+		}
+		if 1 > len(data) {
+			delete(latest, p)
+			continue
+		}
+		latest[p] = data
+	}
+	file.Close()
+
+	var count int
+	for p, compressed := range latest {
+		filePath := filepath.Join(util.DataDir, filepath.FromSlash(p))
+		if !util.IsSubPath(util.DataDir, filePath) || !gulu.File.IsExist(filePath) {
+			continue
+		}
+
+		data, decodeErr := syZstdDecoder.DecodeAll(compressed, nil)
+		if nil != decodeErr {
+			logging.LogErrorf("decode tree journal record [%s] failed: %s", p, decodeErr)
+			continue
+		}
+		if writeErr := WriteSyFile(filePath, data); nil != writeErr {
+			logging.LogErrorf("replay tree journal record [%s] failed: %s", p, writeErr)
+			continue
+		}
+		count++
+	}
+	if err = os.Remove(journalPath); nil != err {
+		logging.LogErrorf("remove tree journal failed: %s", err)
+	}
+	if 0 < count {
+		logging.LogInfof("replayed tree journal [%d]", count)
+	}
+}
+
+// encodeTreeJournalRecord 编码一条保存日志记录：4 字节校验和、4 字节路径长度、4 字节数据长度、路径、数据，校验和覆盖校验和之后的所有内容。
+func encodeTreeJournalRecord(p string, data []byte) (ret []byte) {
+	ret = make([]byte, 12, 12+len(p)+len(data))
+	binary.LittleEndian.PutUint32(ret[4:], uint32(len(p)))
+	binary.LittleEndian.PutUint32(ret[8:], uint32(len(data)))
+	ret = append(ret, p...)
+	ret = append(ret, data...)
+	binary.LittleEndian.PutUint32(ret[0:], crc32.ChecksumIEEE(ret[4:]))
+	return
+}
+
+func readTreeJournalRecord(reader io.Reader) (p string, data []byte, err error) {
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(reader, header); nil != err {
+		return
+	}
+
+	pathLen, dataLen := binary.LittleEndian.Uint32(header[4:]), binary.LittleEndian.Uint32(header[8:])
+	if 4096 < pathLen || 1024*1024*1024 < dataLen {
+		err = errors.New("invalid record header")
+		return
+	}
+	body := make([]byte, int(pathLen)+int(dataLen))
+	if _, err = io.ReadFull(reader, body); nil != err {
+		return
+	}
+
+	checksum := crc32.NewIEEE()
+	checksum.Write(header[4:])
+	checksum.Write(body)
+	if checksum.Sum32() != binary.LittleEndian.Uint32(header[0:]) {
+		err = errors.New("checksum mismatch")
+		return
+	}
+	p, data = string(body[:pathLen]), body[pathLen:]
+	return
+}
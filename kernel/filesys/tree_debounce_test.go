@@ -0,0 +1,138 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestReadTreeJournalRecord(t *testing.T) {
+	first := encodeTreeJournalRecord("20240101000000-abcdefg/20240101000000-hijklmn.sy", []byte("first"))
+	second := encodeTreeJournalRecord("20240101000000-abcdefg/20240101000000-opqrstu.sy", []byte("second"))
+
+	reader := bytes.NewReader(append(append([]byte{}, first...), second...))
+	for _, expected := range []string{"first", "second"} {
+		_, data, err := readTreeJournalRecord(reader)
+		if nil != err {
+			t.Fatalf("read record failed: %s", err)
+		}
+		if expected != string(data) {
+			t.Fatalf("expected [%s], got [%s]", expected, data)
+		}
+	}
+	if _, _, err := readTreeJournalRecord(reader); io.EOF != err {
+		t.Fatalf("expected EOF, got [%v]", err)
+	}
+
+	corrupt := func(fn func(record []byte) []byte) []byte {
+		return fn(append([]byte{}, first...))
+	}
+	cases := []struct {
+		name   string
+		record []byte
+	}{
+		{"truncated header", first[:7]},
+		{"truncated path", first[:20]},
+		{"truncated data", first[:len(first)-1]},
+		{"corrupt checksum", corrupt(func(record []byte) []byte { record[0] ^= 0xff; return record })},
+		{"corrupt path", corrupt(func(record []byte) []byte { record[12] ^= 0xff; return record })},
+		{"corrupt data", corrupt(func(record []byte) []byte { record[len(record)-1] ^= 0xff; return record })},
+		{"corrupt data length", corrupt(func(record []byte) []byte {
+			binary.LittleEndian.PutUint32(record[8:], 3)
+			return record
+		})},
+		{"path too long", corrupt(func(record []byte) []byte {
+			binary.LittleEndian.PutUint32(record[4:], 4097)
+			return record
+		})},
+		{"data too large", corrupt(func(record []byte) []byte {
+			binary.LittleEndian.PutUint32(record[8:], 0xffffffff)
+			return record
+		})},
+	}
+	for _, c := range cases {
+		p, data, err := readTreeJournalRecord(bytes.NewReader(c.record))
+		if nil == err || io.EOF == err {
+			t.Errorf("%s: expected error, got [%v] with path [%s] and data [%s]", c.name, err, p, data)
+		}
+	}
+
+	// 日志末尾的记录只写了一部分时，之前的记录仍然可以读取
+	reader = bytes.NewReader(append(append([]byte{}, first...), second[:len(second)-2]...))
+	if _, data, err := readTreeJournalRecord(reader); nil != err || "first" != string(data) {
+		t.Fatalf("expected first record, got [%s] with error [%v]", data, err)
+	}
+	if _, _, err := readTreeJournalRecord(reader); io.ErrUnexpectedEOF != err {
+		t.Fatalf("expected unexpected EOF, got [%v]", err)
+	}
+}
+
+func TestQueueTreeSaveDirectWrite(t *testing.T) {
+	util.DataDir, util.TempDir = t.TempDir(), t.TempDir()
+	defer func() {
+		if nil != treeJournal {
+			treeJournal.Close()
+			treeJournal, treeJournalSize = nil, 0
+		}
+	}()
+
+	overwritten := filepath.Join(util.DataDir, "20240101000000-abcdefg.sy")
+	other := filepath.Join(util.DataDir, "20240101000000-hijklmn.sy")
+	for _, p := range []string{overwritten, other} {
+		if err := os.WriteFile(p, []byte("old"), 0644); nil != err {
+			t.Fatal(err)
+		}
+		if err := queueTreeSave(p, []byte("debounced"), time.Minute); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	// 等待期间直接写入文件，比如回滚文档历史
+	if err := os.WriteFile(overwritten, []byte("direct write"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	if data, err := ReadSyFile(overwritten); nil != err || "direct write" != string(data) {
+		t.Fatalf("expected direct write, got [%s] with error [%v]", data, err)
+	}
+
+	// 启动时回放保存日志不能覆盖直接写入的数据
+	ReplayTreeJournal()
+	for p, expected := range map[string]string{overwritten: "direct write", other: "debounced"} {
+		if data, _ := os.ReadFile(p); expected != string(data) {
+			t.Fatalf("replay [%s]: expected [%s], got [%s]", filepath.Base(p), expected, data)
+		}
+	}
+
+	if err := queueTreeSave(overwritten, []byte("debounced"), time.Minute); nil != err {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overwritten, []byte("direct write again"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	FlushTreeSaves()
+	if data, _ := os.ReadFile(overwritten); "direct write again" != string(data) {
+		t.Fatalf("flush: expected direct write, got [%s]", data)
+	}
+}
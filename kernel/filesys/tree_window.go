@@ -0,0 +1,187 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TreeChild 描述了文档的一个顶层块，仅保留原始 JSON 和定位窗口需要的字段，不构建语法树。
+type TreeChild struct {
+	ID           string
+	Type         string
+	HeadingLevel int
+	Data         json.RawMessage
+}
+
+// ScannedTree 描述了流式扫描后的文档 JSON，用于超大文档的分段加载和保存。
+type ScannedTree struct {
+	Root     map[string]json.RawMessage // 除 Children 以外的根节点字段
+	Children []*TreeChild
+
+	keys []string // 根节点字段顺序，写回时保持不变
+}
+
+// ScanTree 流式扫描文档 JSON，只解析根节点字段和顶层块的 ID、类型等字段。
+func ScanTree(data []byte) (ret *ScannedTree, err error) {
+	if data, err = DecodeSyData(data); nil != err {
+		return
+	}
+
+	ret = &ScannedTree{Root: map[string]json.RawMessage{}}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err = expectDelim(decoder, '{'); nil != err {
+		return
+	}
+
+	for decoder.More() {
+		var token json.Token
+		if token, err = decoder.Token(); nil != err {
+			return
+		}
+		key, _ := token.(string)
+		if "Children" != key {
+			var value json.RawMessage
+			if err = decoder.Decode(&value); nil != err {
+				return
+			}
+			ret.Root[key] = value
+			ret.keys = append(ret.keys, key)
+			continue
+		}
+
+		if err = expectDelim(decoder, '['); nil != err {
+			return
+		}
+		for decoder.More() {
+			child := &TreeChild{}
+			if err = decoder.Decode(&child.Data); nil != err {
+				return
+			}
+			header := &struct {
+				ID           string
+				Type         string
+				HeadingLevel int
+			}{}
+			if err = json.Unmarshal(child.Data, header); nil != err {
+				return
+			}
+			child.ID, child.Type, child.HeadingLevel = header.ID, header.Type, header.HeadingLevel
+			ret.Children = append(ret.Children, child)
+		}
+		if err = expectDelim(decoder, ']'); nil != err {
+			return
+		}
+	}
+	return
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if nil != err {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected token [%v], expected [%v]", token, delim)
+	}
+	return nil
+}
+
+// Marshal 将扫描结果重新组装为文档 JSON。
+func (scanned *ScannedTree) Marshal(children []*TreeChild) (ret []byte, err error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for _, key := range scanned.keys {
+		keyData, _ := json.Marshal(key)
+		buf.Write(keyData)
+		buf.WriteByte(':')
+		buf.Write(scanned.Root[key])
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"Children":[`)
+	for i, child := range children {
+		if 0 < i {
+			buf.WriteByte(',')
+		}
+		buf.Write(child.Data)
+	}
+	buf.WriteString("]}")
+	ret = buf.Bytes()
+	return
+}
+
+// LoadTreeWindow 只构建文档中 [start, end) 范围内的顶层块，返回的树不能用于写入文件。
+func LoadTreeWindow(scanned *ScannedTree, boxID, p string, start, end int, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
+	data, err := scanned.Marshal(scanned.Children[start:end])
+	if nil != err {
+		return
+	}
+
+	if ret, err = ParseJSONWithoutFix(data, luteEngine.ParseOptions); nil != err {
+		return
+	}
+	ret.Box, ret.Path = boxID, p
+	ret.Root.Box, ret.Root.Path = boxID, p
+	return
+}
+
+// RenderTreeChildren 将树的顶层块渲染为 JSON，用于替换文档中的一段顶层块。
+func RenderTreeChildren(tree *parse.Tree) (ret []*TreeChild, err error) {
+	luteEngine := util.NewLute() // 不关注用户的自定义解析渲染选项
+	renderer := render.NewJSONRenderer(tree, luteEngine.RenderOptions)
+	scanned, err := ScanTree(renderer.Render())
+	if nil != err {
+		return
+	}
+	ret = scanned.Children
+	return
+}
+
+// WriteScannedTree 将扫描结果写回文档文件，不需要构建整个文档的语法树。
+func WriteScannedTree(scanned *ScannedTree, boxID, p string) (err error) {
+	data, err := scanned.Marshal(scanned.Children)
+	if nil != err {
+		return
+	}
+
+	if !util.UseSingleLineSave {
+		buf := bytes.Buffer{}
+		buf.Grow(len(data) + len(data)/4)
+		if err = json.Indent(&buf, data, "", "\t"); nil != err {
+			return
+		}
+		data = buf.Bytes()
+	}
+
+	filePath := filepath.Join(util.DataDir, boxID, p)
+	if err = writeTreeDirectly(filePath, data); nil != err {
+		msg := fmt.Sprintf("write data [%s] failed: %s", filePath, err)
+		logging.LogErrorf(msg)
+		return errors.New(msg)
+	}
+	return
+}
@@ -0,0 +1,98 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package filesys
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// writtenFiles 记录内核最近写入的数据文件指纹，外部文件变更监听据此忽略内核自身的写入。
+var (
+	writtenFiles     = map[string]*writtenFile{}
+	writtenFilesLock = sync.Mutex{}
+)
+
+type writtenFile struct {
+	fingerprint string
+	time        time.Time
+}
+
+// RecordWrittenFile 记录内核写入的文件。
+func RecordWrittenFile(absPath string) {
+	info, err := os.Stat(absPath)
+	if nil != err {
+		return
+	}
+
+	writtenFilesLock.Lock()
+	defer writtenFilesLock.Unlock()
+	now := time.Now()
+	writtenFiles[absPath] = &writtenFile{fingerprint: fileFingerprint(info), time: now}
+	if 4096 < len(writtenFiles) {
+		for p, f := range writtenFiles {
+			if now.Sub(f.time) > time.Minute {
+				delete(writtenFiles, p)
+			}
+		}
+	}
+}
+
+// removedFingerprint 是内核移走的文件的指纹。
+const removedFingerprint = "removed"
+
+// RecordRenamedFile 记录内核重命名（移动）的文件或者文件夹，新路径按写入记录，旧路径按移走记录。
+func RecordRenamedFile(fromAbsPath, toAbsPath string) {
+	filepath.Walk(toAbsPath, func(p string, info os.FileInfo, err error) error {
+		if nil != err || info.IsDir() {
+			return nil
+		}
+
+		RecordWrittenFile(p)
+		if rel, relErr := filepath.Rel(toAbsPath, p); nil == relErr {
+			recordRemovedFile(filepath.Join(fromAbsPath, rel))
+		}
+		return nil
+	})
+	recordRemovedFile(fromAbsPath)
+}
+
+func recordRemovedFile(absPath string) {
+	writtenFilesLock.Lock()
+	defer writtenFilesLock.Unlock()
+	writtenFiles[absPath] = &writtenFile{fingerprint: removedFingerprint, time: time.Now()}
+}
+
+// IsWrittenByKernel 判断文件的当前内容是否由内核写入，即写入后没有被外部修改过。文件不存在时判断是否由内核移走。
+func IsWrittenByKernel(absPath string) bool {
+	fingerprint := removedFingerprint
+	if info, err := os.Stat(absPath); nil == err {
+		fingerprint = fileFingerprint(info)
+	}
+
+	writtenFilesLock.Lock()
+	defer writtenFilesLock.Unlock()
+	f := writtenFiles[absPath]
+	return nil != f && f.fingerprint == fingerprint
+}
+
+func fileFingerprint(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10) + "-" + strconv.FormatInt(info.Size(), 10)
+}
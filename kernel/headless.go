@@ -0,0 +1,93 @@
+//go:build !mobile
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/job"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 命令行模式不启动 HTTP 服务和界面，执行一次子命令后退出，用于定时任务和持续集成中操作工作空间，比如：
+//
+//	SiYuan-Kernel export --workspace=/path/to/workspace --id=20210808180117-czj9bvb --format=md --out=/path/to/out
+//
+// 子命令需要放在第一个参数，其他参数和正常启动时相同。工作空间被其他内核进程伺服时无法执行。
+var headlessCommands = []string{"export", "sync", "index", "snapshot", "check"}
+
+type headlessArgs struct {
+	id     *string
+	format *string
+	out    *string
+	memo   *string
+}
+
+// parseHeadlessCommand 识别命令行模式的子命令并注册子命令参数，不是命令行模式时返回空。
+func parseHeadlessCommand() (cmd string, args *headlessArgs) {
+	if 2 > len(os.Args) || !gulu.Str.Contains(os.Args[1], headlessCommands) {
+		return
+	}
+
+	cmd = os.Args[1]
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	args = &headlessArgs{
+		id:     flag.String("id", "", "[export] ID of the document or notebook to export"),
+		format: flag.String("format", "md", "[export] md/sy/docx"),
+		out:    flag.String("out", "", "[export] output file or directory path"),
+		memo:   flag.String("memo", "", "[snapshot] memo of the data snapshot"),
+	}
+	return
+}
+
+// runHeadless 启动命令行模式需要的子系统并执行子命令，返回进程退出码。
+func runHeadless(cmd string, args *headlessArgs) (exitCode int) {
+	util.Boot()
+
+	model.InitConf()
+	sql.InitDatabase(false)
+	sql.InitHistoryDatabase(false)
+	sql.InitAssetContentDatabase(false)
+	sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
+	sql.SetIndexAssetPath(model.Conf.Search.IndexAssetPath)
+	job.StartHeadlessCron()
+
+	model.InitBoxes()
+	util.LoadAssetsTexts()
+	util.SetBooted()
+	task.WaitForQueue()
+	defer model.HeadlessClose()
+
+	var err error
+	switch cmd {
+	case "export":
+		var exported string
+		if exported, err = model.HeadlessExport(strings.TrimSpace(*args.id), *args.format, strings.TrimSpace(*args.out)); nil == err {
+			fmt.Println(exported)
+		}
+	case "sync":
+		err = model.HeadlessSync()
+	case "index":
+		model.HeadlessReindex()
+	case "snapshot":
+		memo := *args.memo
+		if "" == strings.TrimSpace(memo) {
+			memo = "[CLI] snapshot"
+		}
+		err = model.IndexRepo(memo)
+	case "check":
+		model.HeadlessCheckIndex()
+	}
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", cmd, err)
+		return 1
+	}
+	return 0
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/task"
@@ -33,16 +34,52 @@ func StartCron() {
 	go every(5*time.Second, treenode.SaveBlockTreeJob)
 	go every(5*time.Second, model.SyncDataJob)
 	go every(2*time.Hour, model.StatJob)
-	go every(2*time.Hour, model.RefreshCheckJob)
+	go everyAfterInteractive("bazaarRefresh", 2*time.Hour, model.RefreshCheckJob)
 	go every(3*time.Second, model.FlushUpdateRefTextRenameDocJob)
-	go every(util.SQLFlushInterval, sql.FlushTxJob)
+	go every(200*time.Millisecond, sql.FlushTxJob) // 按照写入策略判断是否需要写入
+	go every(200*time.Millisecond, filesys.FlushTreeSavesJob)
 	go every(util.SQLFlushInterval, sql.FlushHistoryTxJob)
 	go every(util.SQLFlushInterval, sql.FlushAssetContentTxJob)
 	go every(10*time.Minute, model.IndexEmbedBlockJob)
 	go every(10*time.Minute, model.CacheVirtualBlockRefJob)
-	go every(30*time.Second, model.OCRAssetsJob)
+	go everyAfterInteractive("assetOCR", 30*time.Second, model.OCRAssetsJob)
 	go every(30*time.Second, model.FlushAssetsTextsJob)
 	go every(30*time.Second, model.HookDesktopUIProcJob)
+	go every(time.Minute, model.SyncAttributeViewDataSourcesJob)
+	go every(time.Hour, model.AutoCleanUnusedAssetsJob)
+	go every(10*time.Second, model.WatchFoldersJob)
+	go every(time.Minute, model.TemplateScheduleJob)
+	go every(15*time.Second, model.ReminderJob)
+	go every(5*time.Second, model.PluginJobSchedulerJob)
+	go every(5*time.Minute, model.EmbedDocsJob)
+	go every(5*time.Minute, model.SuggestTagsJob)
+}
+
+// StartHeadlessCron 启动命令行模式需要的任务，只包括任务队列和数据写入，不包括同步、统计和界面相关的任务。
+func StartHeadlessCron() {
+	go every(100*time.Millisecond, task.ExecTaskJob)
+	go every(5*time.Second, treenode.SaveBlockTreeJob)
+	go every(200*time.Millisecond, sql.FlushTxJob)
+	go every(200*time.Millisecond, filesys.FlushTreeSavesJob)
+	go every(util.SQLFlushInterval, sql.FlushHistoryTxJob)
+	go every(util.SQLFlushInterval, sql.FlushAssetContentTxJob)
+}
+
+// everyAfterInteractive 与 every 相同，但开启推迟非关键启动任务后会等待界面可交互再开始执行，首次执行记录为启动阶段 stage。
+func everyAfterInteractive(stage string, interval time.Duration, f func()) {
+	deferred := model.Conf.Performance.DeferBootTasks
+	if deferred {
+		util.WaitForInteractive(time.Minute)
+	}
+
+	first := true
+	every(interval, func() {
+		if first {
+			first = false
+			defer util.StartBootStage(stage, deferred)()
+		}
+		f()
+	})
 }
 
 func every(interval time.Duration, f func()) {
@@ -19,6 +19,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/job"
 	"github.com/siyuan-note/siyuan/kernel/model"
@@ -28,6 +30,10 @@ import (
 )
 
 func main() {
+	if cmd, args := parseHeadlessCommand(); "" != cmd {
+		os.Exit(runHeadless(cmd, args))
+	}
+
 	util.Boot()
 
 	model.InitConf()
@@ -49,9 +55,11 @@ func main() {
 
 	job.StartCron()
 	go model.AutoGenerateFileHistory()
+	go model.LoadPluginBackends()
 	go cache.LoadAssets()
 	go util.CheckFileSysStatus()
 
 	model.WatchAssets()
+	model.WatchData()
 	model.HandleSignal()
 }
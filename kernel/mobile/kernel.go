@@ -65,6 +65,7 @@ func StartKernel(container, appDir, workspaceBaseDir, timezoneID, localIPs, lang
 
 		job.StartCron()
 		go model.AutoGenerateFileHistory()
+		go model.LoadPluginBackends()
 		go cache.LoadAssets()
 	}()
 }
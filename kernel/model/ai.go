@@ -22,13 +22,12 @@ import (
 
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
-	"github.com/sashabaranov/go-openai"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 func ChatGPT(msg string) (ret string) {
-	if !isOpenAIAPIEnabled() {
+	if !isAIEnabled() {
 		return
 	}
 
@@ -36,7 +35,7 @@ func ChatGPT(msg string) (ret string) {
 }
 
 func ChatGPTWithAction(ids []string, action string) (ret string) {
-	if !isOpenAIAPIEnabled() {
+	if !isAIEnabled() {
 		return
 	}
 
@@ -92,7 +91,13 @@ func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret str
 	if cloud {
 		gpt = &CloudGPT{}
 	} else {
-		gpt = &OpenAIGPT{c: util.NewOpenAIClient(Conf.AI.OpenAI.APIKey, Conf.AI.OpenAI.APIProxy, Conf.AI.OpenAI.APIBaseURL, Conf.AI.OpenAI.APIUserAgent, Conf.AI.OpenAI.APIVersion, Conf.AI.OpenAI.APIProvider)}
+		feature, featureErr := getAIFeature(AIFeatureChat)
+		if nil != featureErr {
+			util.PushErrMsg(featureErr.Error(), 5000)
+			err = featureErr
+			return
+		}
+		gpt = feature
 	}
 
 	buf := &bytes.Buffer{}
@@ -113,8 +118,8 @@ func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret str
 	return
 }
 
-func isOpenAIAPIEnabled() bool {
-	if "" == Conf.AI.OpenAI.APIKey {
+func isAIEnabled() bool {
+	if "" == Conf.AI.OpenAI.APIKey && "" == Conf.AI.Features.Chat.Provider {
 		util.PushMsg(Conf.Language(193), 5000)
 		return false
 	}
@@ -165,14 +170,6 @@ type GPT interface {
 	chat(msg string, contextMsgs []string) (partRet string, stop bool, err error)
 }
 
-type OpenAIGPT struct {
-	c *openai.Client
-}
-
-func (gpt *OpenAIGPT) chat(msg string, contextMsgs []string) (partRet string, stop bool, err error) {
-	return util.ChatGPT(msg, contextMsgs, gpt.c, Conf.AI.OpenAI.APIModel, Conf.AI.OpenAI.APIMaxTokens, Conf.AI.OpenAI.APITemperature, Conf.AI.OpenAI.APITimeout)
-}
-
 type CloudGPT struct {
 }
 
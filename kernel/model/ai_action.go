@@ -0,0 +1,229 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AIActionResult 描述了 AI 动作的执行结果。
+type AIActionResult struct {
+	Content      string         `json:"content"`
+	DocID        string         `json:"docID,omitempty"` // 输出到新文档时的文档 ID
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// aiActionBlock 是提示词模板中可以使用的块。
+type aiActionBlock struct {
+	ID      string
+	Type    string
+	Content string // Markdown
+}
+
+func GetAIActions() (ret []*conf.AIAction) {
+	return Conf.AI.Actions
+}
+
+func SetAIAction(action *conf.AIAction) (ret *conf.AIAction, err error) {
+	action.Name = strings.TrimSpace(action.Name)
+	if "" == action.Name {
+		err = errors.New("action name is empty")
+		return
+	}
+	switch action.Output {
+	case conf.AIActionOutputReplace, conf.AIActionOutputAppend, conf.AIActionOutputNewDoc:
+	default:
+		err = fmt.Errorf("invalid action output [%s]", action.Output)
+		return
+	}
+	if _, err = template.New(action.Name).Parse(action.Prompt); nil != err {
+		return
+	}
+	if nil != action.Model && "" != action.Model.Provider && nil == Conf.AI.GetProvider(action.Model.Provider) {
+		err = fmt.Errorf("AI provider [%s] not found", action.Model.Provider)
+		return
+	}
+
+	if "" == action.ID {
+		action.ID = ast.NewNodeID()
+		Conf.AI.Actions = append(Conf.AI.Actions, action)
+	} else {
+		found := false
+		for i, a := range Conf.AI.Actions {
+			if a.ID == action.ID {
+				Conf.AI.Actions[i] = action
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = fmt.Errorf("AI action [%s] not found", action.ID)
+			return
+		}
+	}
+	Conf.Save()
+	ret = action
+	return
+}
+
+func RemoveAIAction(id string) {
+	var actions []*conf.AIAction
+	for _, a := range Conf.AI.Actions {
+		if a.ID != id {
+			actions = append(actions, a)
+		}
+	}
+	if nil == actions {
+		actions = []*conf.AIAction{}
+	}
+	Conf.AI.Actions = actions
+	Conf.Save()
+}
+
+// RunAIAction 对选中的块执行 AI 动作，并按照动作的输出方式写入结果。
+func RunAIAction(id string, ids []string) (ret *AIActionResult, err error) {
+	var action *conf.AIAction
+	for _, a := range Conf.AI.Actions {
+		if a.ID == id {
+			action = a
+			break
+		}
+	}
+	if nil == action {
+		err = fmt.Errorf("AI action [%s] not found", id)
+		return
+	}
+	if 1 > len(ids) {
+		err = errors.New("no block selected")
+		return
+	}
+	bt := treenode.GetBlockTree(ids[0])
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+
+	prompt, err := renderAIActionPrompt(action, ids, bt)
+	if nil != err {
+		return
+	}
+
+	featureModel := Conf.AI.Features.Chat
+	if nil != action.Model && ("" != action.Model.Provider || "" != action.Model.Model) {
+		featureModel = action.Model
+	}
+	feature, err := newAIFeature(AIFeatureChat, featureModel)
+	if nil != err {
+		return
+	}
+
+	util.PushEndlessProgress("Requesting...")
+	content, _, err := feature.chat(prompt, nil)
+	util.ClearPushProgress(100)
+	if nil != err {
+		return
+	}
+	if "" == content {
+		err = errors.New("AI returned empty content")
+		return
+	}
+
+	ret = &AIActionResult{Content: content}
+	switch action.Output {
+	case conf.AIActionOutputNewDoc:
+		title := action.Name + " " + time.Now().Format("2006-01-02 15:04:05")
+		ret.DocID, err = CreateWithMarkdown(bt.BoxID, path.Join(bt.HPath, title), content, bt.RootID, "", false)
+	default:
+		ret.Transactions, err = writeAIActionOutput(action.Output, ids, content)
+	}
+	return
+}
+
+func renderAIActionPrompt(action *conf.AIAction, ids []string, bt *treenode.BlockTree) (ret string, err error) {
+	tpl, err := template.New(action.Name).Parse(action.Prompt)
+	if nil != err {
+		return
+	}
+
+	var blocks []*aiActionBlock
+	for _, id := range ids {
+		b := treenode.GetBlockTree(id)
+		if nil == b {
+			continue
+		}
+		blocks = append(blocks, &aiActionBlock{ID: id, Type: b.Type, Content: getBlocksContent([]string{id})})
+	}
+
+	buf := &bytes.Buffer{}
+	err = tpl.Execute(buf, map[string]interface{}{
+		"Content": getBlocksContent(ids),
+		"Title":   path.Base(bt.HPath),
+		"HPath":   bt.HPath,
+		"Blocks":  blocks,
+	})
+	ret = buf.String()
+	return
+}
+
+func writeAIActionOutput(output string, ids []string, content string) (ret []*Transaction, err error) {
+	luteEngine := util.NewLute()
+	dom := luteEngine.Md2BlockDOM(content, true)
+
+	lastID := ids[len(ids)-1]
+	last := treenode.GetBlockTree(lastID)
+	if nil == last {
+		err = ErrBlockNotFound
+		return
+	}
+
+	var ops []*Operation
+	if "d" == last.Type {
+		// 选中的是文档时追加到文档末尾
+		if conf.AIActionOutputReplace == output {
+			err = errors.New("can not replace a document")
+			return
+		}
+		ops = append(ops, &Operation{Action: "appendInsert", Data: dom, ParentID: lastID})
+	} else {
+		ops = append(ops, &Operation{Action: "insert", Data: dom, PreviousID: lastID})
+		if conf.AIActionOutputReplace == output {
+			for _, id := range ids {
+				ops = append(ops, &Operation{Action: "delete", ID: id})
+			}
+		}
+	}
+
+	ret = []*Transaction{{DoOperations: ops}}
+	PerformTransactions(&ret)
+	WaitForWritingFiles()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = ret
+	util.PushEvent(evt)
+	return
+}
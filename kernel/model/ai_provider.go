@@ -0,0 +1,686 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AI 功能，每个功能可以单独选择提供方和模型
+const (
+	AIFeatureChat       = "chat"
+	AIFeatureSummarize  = "summarize"
+	AIFeatureEmbeddings = "embeddings"
+)
+
+// AIMessage 描述了一条对话消息。
+type AIMessage struct {
+	Role    string `json:"role"` // system、user、assistant
+	Content string `json:"content"`
+}
+
+// AIChatRequest 描述了一次对话请求。
+type AIChatRequest struct {
+	Model       string
+	Messages    []*AIMessage
+	MaxTokens   int
+	Temperature float64
+}
+
+// AIProvider 是 AI 服务提供方的抽象。
+type AIProvider interface {
+	// chat 返回回复内容，stop 为 false 时表示因长度限制被截断，可以继续请求。
+	chat(ctx context.Context, req *AIChatRequest) (ret string, stop bool, err error)
+
+	// chatStream 以流式方式返回回复内容，每收到一段内容调用一次 onDelta。
+	chatStream(ctx context.Context, req *AIChatRequest, onDelta func(delta string)) (stop bool, err error)
+
+	// embed 返回输入文本的向量，提供方不支持时返回 ErrAIEmbeddingsUnsupported。
+	embed(ctx context.Context, model string, inputs []string) (ret [][]float32, err error)
+}
+
+var ErrAIEmbeddingsUnsupported = errors.New("the AI provider does not support embeddings")
+
+// aiFeature 是某个功能解析后的提供方和模型参数。
+type aiFeature struct {
+	provider    AIProvider
+	model       string
+	maxTokens   int
+	temperature float64
+	timeout     int
+}
+
+// getAIFeature 获取功能使用的提供方，未配置提供方时使用 OpenAI 设置。
+func getAIFeature(feature string) (ret *aiFeature, err error) {
+	var featureModel *conf.AIFeatureModel
+	switch feature {
+	case AIFeatureChat:
+		featureModel = Conf.AI.Features.Chat
+	case AIFeatureSummarize:
+		featureModel = Conf.AI.Features.Summarize
+		if "" == featureModel.Provider && "" == featureModel.Model {
+			featureModel = Conf.AI.Features.Chat
+		}
+	case AIFeatureEmbeddings:
+		featureModel = Conf.AI.Features.Embeddings
+	default:
+		err = fmt.Errorf("unknown AI feature [%s]", feature)
+		return
+	}
+	return newAIFeature(feature, featureModel)
+}
+
+// newAIFeature 根据提供方和模型配置创建功能，AI 动作可以指定自己的提供方和模型。
+func newAIFeature(feature string, featureModel *conf.AIFeatureModel) (ret *aiFeature, err error) {
+	openAI := Conf.AI.OpenAI
+	ret = &aiFeature{model: featureModel.Model, maxTokens: featureModel.MaxTokens, temperature: featureModel.Temperature}
+	if "" == featureModel.Provider {
+		if "" == openAI.APIKey {
+			err = errors.New(Conf.Language(193))
+			return
+		}
+		ret.provider = &openAIProvider{c: util.NewOpenAIClient(openAI.APIKey, openAI.APIProxy, openAI.APIBaseURL, openAI.APIUserAgent, openAI.APIVersion, openAI.APIProvider)}
+		ret.timeout = openAI.APITimeout
+		if "" == ret.model {
+			ret.model = openAI.APIModel
+			if AIFeatureEmbeddings == feature {
+				ret.model = string(openai.SmallEmbedding3)
+			}
+		}
+	} else {
+		provider := Conf.AI.GetProvider(featureModel.Provider)
+		if nil == provider {
+			err = fmt.Errorf("AI provider [%s] not found", featureModel.Provider)
+			return
+		}
+		if ret.provider, err = newAIProvider(provider); nil != err {
+			return
+		}
+		ret.timeout = provider.Timeout
+	}
+	if 1 > ret.maxTokens {
+		ret.maxTokens = openAI.APIMaxTokens
+	}
+	if 0 >= ret.temperature {
+		ret.temperature = openAI.APITemperature
+	}
+	return
+}
+
+func (feature *aiFeature) chat(msg string, contextMsgs []string) (ret string, stop bool, err error) {
+	req := &AIChatRequest{Model: feature.model, MaxTokens: feature.maxTokens, Temperature: feature.temperature}
+	for _, ctxMsg := range contextMsgs {
+		req.Messages = append(req.Messages, &AIMessage{Role: "user", Content: ctxMsg})
+	}
+	req.Messages = append(req.Messages, &AIMessage{Role: "user", Content: msg})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(feature.timeout)*time.Second)
+	defer cancel()
+	ret, stop, err = feature.provider.chat(ctx, req)
+	if nil != err {
+		util.PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
+		logging.LogErrorf("AI chat failed: %s", err)
+		stop = true
+		return
+	}
+	ret = strings.TrimSpace(ret)
+	return
+}
+
+func (feature *aiFeature) embed(inputs []string) (ret [][]float32, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(feature.timeout)*time.Second)
+	defer cancel()
+	ret, err = feature.provider.embed(ctx, feature.model, inputs)
+	if nil == err && len(ret) != len(inputs) {
+		err = fmt.Errorf("AI embeddings count mismatch [%d/%d]", len(ret), len(inputs))
+	}
+	return
+}
+
+func newAIProvider(provider *conf.AIProvider) (ret AIProvider, err error) {
+	switch provider.Type {
+	case conf.AIProviderOpenAI, conf.AIProviderLlamaCpp:
+		// llama.cpp server 提供兼容 OpenAI 的 /v1 接口
+		ret = &openAIProvider{c: util.NewOpenAIClient(provider.APIKey, provider.Proxy, provider.BaseURL, util.UserAgent, "", "OpenAI")}
+	case conf.AIProviderAzure:
+		ret = &openAIProvider{c: util.NewOpenAIClient(provider.APIKey, provider.Proxy, provider.BaseURL, util.UserAgent, provider.APIVersion, "Azure")}
+	case conf.AIProviderOllama:
+		ret = &ollamaProvider{conf: provider, client: util.NewAIHTTPClient(provider.Proxy, util.UserAgent)}
+	case conf.AIProviderAnthropic:
+		ret = &anthropicProvider{conf: provider, client: util.NewAIHTTPClient(provider.Proxy, util.UserAgent)}
+	case conf.AIProviderGemini:
+		ret = &geminiProvider{conf: provider, client: util.NewAIHTTPClient(provider.Proxy, util.UserAgent)}
+	default:
+		err = fmt.Errorf("unsupported AI provider type [%s]", provider.Type)
+	}
+	return
+}
+
+// FixAIConf 补全 AI 配置缺失的字段。
+func FixAIConf(ai *conf.AI) {
+	if nil == ai.OpenAI {
+		ai.OpenAI = conf.NewAI().OpenAI
+	}
+	if nil == ai.Providers {
+		ai.Providers = []*conf.AIProvider{}
+	}
+	if nil == ai.Features {
+		ai.Features = conf.NewAIFeatures()
+	}
+	if nil == ai.Features.Chat {
+		ai.Features.Chat = &conf.AIFeatureModel{}
+	}
+	if nil == ai.Features.Summarize {
+		ai.Features.Summarize = &conf.AIFeatureModel{}
+	}
+	if nil == ai.Features.Embeddings {
+		ai.Features.Embeddings = &conf.AIFeatureModel{}
+	}
+	if nil == ai.Actions {
+		ai.Actions = []*conf.AIAction{}
+	}
+	if 0 >= ai.RelatedBlocksThreshold || 1 < ai.RelatedBlocksThreshold {
+		ai.RelatedBlocksThreshold = 0.75
+	}
+
+	for _, provider := range ai.Providers {
+		if "" == provider.ID {
+			provider.ID = gulu.Rand.String(7)
+		}
+		provider.BaseURL = strings.TrimSuffix(strings.TrimSpace(provider.BaseURL), "/")
+		if "" == provider.BaseURL {
+			provider.BaseURL = defaultAIProviderBaseURL(provider.Type)
+		}
+		if 5 > provider.Timeout {
+			provider.Timeout = 30
+		}
+		if 600 < provider.Timeout {
+			provider.Timeout = 600
+		}
+		if nil == provider.Options {
+			provider.Options = map[string]interface{}{}
+		}
+	}
+}
+
+func defaultAIProviderBaseURL(typ string) string {
+	switch typ {
+	case conf.AIProviderOpenAI:
+		return "https://api.openai.com/v1"
+	case conf.AIProviderOllama:
+		return "http://127.0.0.1:11434"
+	case conf.AIProviderLlamaCpp:
+		return "http://127.0.0.1:8080/v1"
+	case conf.AIProviderAnthropic:
+		return "https://api.anthropic.com"
+	case conf.AIProviderGemini:
+		return "https://generativelanguage.googleapis.com"
+	}
+	return ""
+}
+
+// openAIProvider 用于 OpenAI、Azure OpenAI 以及其他兼容 OpenAI API 的服务。
+type openAIProvider struct {
+	c *openai.Client
+}
+
+func (provider *openAIProvider) chat(ctx context.Context, req *AIChatRequest) (ret string, stop bool, err error) {
+	chatReq := openai.ChatCompletionRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: float32(req.Temperature),
+	}
+	for _, msg := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content})
+	}
+	resp, err := provider.c.CreateChatCompletion(ctx, chatReq)
+	if nil != err {
+		return
+	}
+	if 1 > len(resp.Choices) {
+		stop = true
+		return
+	}
+
+	choice := resp.Choices[0]
+	ret = choice.Message.Content
+	stop = openai.FinishReasonLength != choice.FinishReason
+	return
+}
+
+func (provider *openAIProvider) chatStream(ctx context.Context, req *AIChatRequest, onDelta func(delta string)) (stop bool, err error) {
+	chatReq := openai.ChatCompletionRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: float32(req.Temperature),
+		Stream:      true,
+	}
+	for _, msg := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content})
+	}
+	stream, err := provider.c.CreateChatCompletionStream(ctx, chatReq)
+	if nil != err {
+		return
+	}
+	defer stream.Close()
+
+	stop = true
+	for {
+		resp, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			return
+		}
+		if nil != recvErr {
+			err = recvErr
+			return
+		}
+		if 1 > len(resp.Choices) {
+			continue
+		}
+		choice := resp.Choices[0]
+		if "" != choice.Delta.Content {
+			onDelta(choice.Delta.Content)
+		}
+		if openai.FinishReasonLength == choice.FinishReason {
+			stop = false
+		}
+	}
+}
+
+func (provider *openAIProvider) embed(ctx context.Context, model string, inputs []string) (ret [][]float32, err error) {
+	resp, err := provider.c.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: inputs, Model: openai.EmbeddingModel(model)})
+	if nil != err {
+		return
+	}
+	ret = make([][]float32, len(resp.Data))
+	for _, data := range resp.Data {
+		if data.Index < len(ret) {
+			ret[data.Index] = data.Embedding
+		}
+	}
+	return
+}
+
+// ollamaProvider 使用 Ollama 原生 API，Options 会作为模型参数传递，比如 num_ctx。
+type ollamaProvider struct {
+	conf   *conf.AIProvider
+	client *http.Client
+}
+
+func (provider *ollamaProvider) chat(ctx context.Context, req *AIChatRequest) (ret string, stop bool, err error) {
+	options := map[string]interface{}{}
+	for k, v := range provider.conf.Options {
+		options[k] = v
+	}
+	options["temperature"] = req.Temperature
+	if 0 < req.MaxTokens {
+		options["num_predict"] = req.MaxTokens
+	}
+
+	result := &struct {
+		Message    *AIMessage `json:"message"`
+		DoneReason string     `json:"done_reason"`
+	}{}
+	body := map[string]interface{}{"model": req.Model, "messages": req.Messages, "stream": false, "options": options}
+	if err = postAIJSON(ctx, provider.client, provider.conf.BaseURL+"/api/chat", nil, body, result); nil != err {
+		return
+	}
+	if nil != result.Message {
+		ret = result.Message.Content
+	}
+	stop = "length" != result.DoneReason
+	return
+}
+
+func (provider *ollamaProvider) chatStream(ctx context.Context, req *AIChatRequest, onDelta func(delta string)) (stop bool, err error) {
+	options := map[string]interface{}{}
+	for k, v := range provider.conf.Options {
+		options[k] = v
+	}
+	options["temperature"] = req.Temperature
+	if 0 < req.MaxTokens {
+		options["num_predict"] = req.MaxTokens
+	}
+
+	stop = true
+	body := map[string]interface{}{"model": req.Model, "messages": req.Messages, "stream": true, "options": options}
+	// Ollama 流式返回的每一行都是一个 JSON 对象
+	err = postAIStream(ctx, provider.client, provider.conf.BaseURL+"/api/chat", nil, body, func(line string) {
+		chunk := &struct {
+			Message    *AIMessage `json:"message"`
+			Done       bool       `json:"done"`
+			DoneReason string     `json:"done_reason"`
+		}{}
+		if nil != gulu.JSON.UnmarshalJSON([]byte(line), chunk) {
+			return
+		}
+		if nil != chunk.Message && "" != chunk.Message.Content {
+			onDelta(chunk.Message.Content)
+		}
+		if chunk.Done && "length" == chunk.DoneReason {
+			stop = false
+		}
+	})
+	return
+}
+
+func (provider *ollamaProvider) embed(ctx context.Context, model string, inputs []string) (ret [][]float32, err error) {
+	result := &struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}{}
+	body := map[string]interface{}{"model": model, "input": inputs}
+	if err = postAIJSON(ctx, provider.client, provider.conf.BaseURL+"/api/embed", nil, body, result); nil != err {
+		return
+	}
+	ret = result.Embeddings
+	return
+}
+
+// anthropicProvider 使用 Anthropic Messages API，APIVersion 对应请求头 anthropic-version。
+type anthropicProvider struct {
+	conf   *conf.AIProvider
+	client *http.Client
+}
+
+func (provider *anthropicProvider) chat(ctx context.Context, req *AIChatRequest) (ret string, stop bool, err error) {
+	headers, body := provider.request(req)
+	result := &struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+	}{}
+	if err = postAIJSON(ctx, provider.client, provider.conf.BaseURL+"/v1/messages", headers, body, result); nil != err {
+		return
+	}
+	buf := &strings.Builder{}
+	for _, content := range result.Content {
+		if "text" == content.Type {
+			buf.WriteString(content.Text)
+		}
+	}
+	ret = buf.String()
+	stop = "max_tokens" != result.StopReason
+	return
+}
+
+func (provider *anthropicProvider) chatStream(ctx context.Context, req *AIChatRequest, onDelta func(delta string)) (stop bool, err error) {
+	headers, body := provider.request(req)
+	body["stream"] = true
+
+	stop = true
+	err = postAIStream(ctx, provider.client, provider.conf.BaseURL+"/v1/messages", headers, body, func(line string) {
+		if !strings.HasPrefix(line, "data:") {
+			return
+		}
+		event := &struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+		}{}
+		if nil != gulu.JSON.UnmarshalJSON([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), event) {
+			return
+		}
+		switch event.Type {
+		case "content_block_delta":
+			if "" != event.Delta.Text {
+				onDelta(event.Delta.Text)
+			}
+		case "message_delta":
+			if "max_tokens" == event.Delta.StopReason {
+				stop = false
+			}
+		}
+	})
+	return
+}
+
+func (provider *anthropicProvider) request(req *AIChatRequest) (headers map[string]string, body map[string]interface{}) {
+	version := provider.conf.APIVersion
+	if "" == version {
+		version = "2023-06-01"
+	}
+	headers = map[string]string{"x-api-key": provider.conf.APIKey, "anthropic-version": version}
+
+	maxTokens := req.MaxTokens
+	if 1 > maxTokens {
+		maxTokens = 4096 // Anthropic 要求必须指定 max_tokens
+	}
+	body = map[string]interface{}{"model": req.Model, "max_tokens": maxTokens, "temperature": req.Temperature}
+	for k, v := range provider.conf.Options {
+		body[k] = v
+	}
+	var messages []*AIMessage
+	for _, msg := range req.Messages {
+		if "system" == msg.Role {
+			body["system"] = msg.Content
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	body["messages"] = messages
+	return
+}
+
+func (provider *anthropicProvider) embed(ctx context.Context, model string, inputs []string) (ret [][]float32, err error) {
+	err = ErrAIEmbeddingsUnsupported
+	return
+}
+
+// geminiProvider 使用 Gemini generateContent API，Options 会合并到请求中，比如 safetySettings。
+type geminiProvider struct {
+	conf   *conf.AIProvider
+	client *http.Client
+}
+
+type geminiResult struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+}
+
+func (result *geminiResult) text() (ret string, stop bool) {
+	stop = true
+	if 1 > len(result.Candidates) {
+		return
+	}
+
+	buf := &strings.Builder{}
+	for _, part := range result.Candidates[0].Content.Parts {
+		buf.WriteString(part.Text)
+	}
+	ret = buf.String()
+	stop = "MAX_TOKENS" != result.Candidates[0].FinishReason
+	return
+}
+
+func (provider *geminiProvider) chat(ctx context.Context, req *AIChatRequest) (ret string, stop bool, err error) {
+	result := &geminiResult{}
+	u := provider.conf.BaseURL + "/v1beta/models/" + url.PathEscape(req.Model) + ":generateContent?key=" + url.QueryEscape(provider.conf.APIKey)
+	if err = postAIJSON(ctx, provider.client, u, nil, provider.request(req), result); nil != err {
+		return
+	}
+	ret, stop = result.text()
+	return
+}
+
+func (provider *geminiProvider) chatStream(ctx context.Context, req *AIChatRequest, onDelta func(delta string)) (stop bool, err error) {
+	stop = true
+	u := provider.conf.BaseURL + "/v1beta/models/" + url.PathEscape(req.Model) + ":streamGenerateContent?alt=sse&key=" + url.QueryEscape(provider.conf.APIKey)
+	err = postAIStream(ctx, provider.client, u, nil, provider.request(req), func(line string) {
+		if !strings.HasPrefix(line, "data:") {
+			return
+		}
+		result := &geminiResult{}
+		if nil != gulu.JSON.UnmarshalJSON([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), result) {
+			return
+		}
+		delta, chunkStop := result.text()
+		if "" != delta {
+			onDelta(delta)
+		}
+		if !chunkStop {
+			stop = false
+		}
+	})
+	return
+}
+
+func (provider *geminiProvider) request(req *AIChatRequest) (body map[string]interface{}) {
+	body = map[string]interface{}{}
+	for k, v := range provider.conf.Options {
+		body[k] = v
+	}
+	generationConfig := map[string]interface{}{"temperature": req.Temperature}
+	if 0 < req.MaxTokens {
+		generationConfig["maxOutputTokens"] = req.MaxTokens
+	}
+	body["generationConfig"] = generationConfig
+
+	var contents []map[string]interface{}
+	for _, msg := range req.Messages {
+		part := []map[string]interface{}{{"text": msg.Content}}
+		switch msg.Role {
+		case "system":
+			body["systemInstruction"] = map[string]interface{}{"parts": part}
+		case "assistant":
+			contents = append(contents, map[string]interface{}{"role": "model", "parts": part})
+		default:
+			contents = append(contents, map[string]interface{}{"role": "user", "parts": part})
+		}
+	}
+	body["contents"] = contents
+	return
+}
+
+func (provider *geminiProvider) embed(ctx context.Context, model string, inputs []string) (ret [][]float32, err error) {
+	var requests []map[string]interface{}
+	for _, input := range inputs {
+		requests = append(requests, map[string]interface{}{
+			"model":   "models/" + model,
+			"content": map[string]interface{}{"parts": []map[string]interface{}{{"text": input}}},
+		})
+	}
+
+	result := &struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}{}
+	u := provider.conf.BaseURL + "/v1beta/models/" + url.PathEscape(model) + ":batchEmbedContents?key=" + url.QueryEscape(provider.conf.APIKey)
+	if err = postAIJSON(ctx, provider.client, u, nil, map[string]interface{}{"requests": requests}, result); nil != err {
+		return
+	}
+	for _, embedding := range result.Embeddings {
+		ret = append(ret, embedding.Values)
+	}
+	return
+}
+
+func postAIJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, body, result interface{}) (err error) {
+	data, err := gulu.JSON.MarshalJSON(body)
+	if nil != err {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if nil != err {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if nil != err {
+		return
+	}
+	if http.StatusOK != resp.StatusCode {
+		msg := string(respData)
+		if 512 < len(msg) {
+			msg = msg[:512]
+		}
+		return fmt.Errorf("request [%s] failed [%d]: %s", strings.Split(u, "?")[0], resp.StatusCode, msg)
+	}
+	return gulu.JSON.UnmarshalJSON(respData, result)
+}
+
+// postAIStream 发送请求并逐行读取流式响应。
+func postAIStream(ctx context.Context, client *http.Client, u string, headers map[string]string, body interface{}, onLine func(line string)) (err error) {
+	data, err := gulu.JSON.MarshalJSON(body)
+	if nil != err {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if nil != err {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+
+	if http.StatusOK != resp.StatusCode {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request [%s] failed [%d]: %s", strings.Split(u, "?")[0], resp.StatusCode, msg)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); "" != line {
+			onLine(line)
+		}
+	}
+	return scanner.Err()
+}
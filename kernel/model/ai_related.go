@@ -0,0 +1,231 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/gob"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// RelatedBlock 描述了一个语义相关的文档。
+type RelatedBlock struct {
+	ID    string  `json:"id"`
+	Box   string  `json:"box"`
+	HPath string  `json:"hPath"`
+	Score float64 `json:"score"` // 余弦相似度
+}
+
+// docEmbedding 是文档的向量，Updated 和 Model 变化时重新计算。
+type docEmbedding struct {
+	Updated string
+	Model   string
+	Vector  []float32
+}
+
+const (
+	embedDocsBatchSize   = 16
+	embedDocMaxRuneCount = 6000
+)
+
+var (
+	docEmbeddings       map[string]*docEmbedding // rootID -> 向量，仅保存在当前设备
+	docEmbeddingsLock   = sync.RWMutex{}
+	docEmbeddingsLoaded bool
+)
+
+// EmbedDocsJob 在后台计算文档向量，需要在 AI 设置中开启语义索引。
+func EmbedDocsJob() {
+	if !util.IsBooted() || util.IsExiting.Load() || !Conf.AI.SemanticIndex {
+		return
+	}
+
+	feature, err := getAIFeature(AIFeatureEmbeddings)
+	if nil != err {
+		return
+	}
+
+	loadDocEmbeddings()
+
+	var pending []*treenode.BlockTree
+	docs := map[string]bool{}
+	for _, bt := range treenode.GetBlockTreesByType("d") {
+		docs[bt.ID] = true
+		docEmbeddingsLock.RLock()
+		embedding := docEmbeddings[bt.ID]
+		docEmbeddingsLock.RUnlock()
+		if nil == embedding || embedding.Updated != bt.Updated || embedding.Model != feature.model {
+			pending = append(pending, bt)
+		}
+	}
+
+	changed := false
+	docEmbeddingsLock.Lock()
+	for id := range docEmbeddings {
+		if !docs[id] {
+			delete(docEmbeddings, id)
+			changed = true
+		}
+	}
+	docEmbeddingsLock.Unlock()
+
+	if embedDocsBatchSize < len(pending) {
+		pending = pending[:embedDocsBatchSize]
+	}
+	var inputs []string
+	var embedded []*treenode.BlockTree
+	for _, bt := range pending {
+		content := getBlocksContent([]string{bt.ID})
+		if embedDocMaxRuneCount < utf8.RuneCountInString(content) {
+			content = string([]rune(content)[:embedDocMaxRuneCount])
+		}
+		inputs = append(inputs, bt.HPath+"\n\n"+content)
+		embedded = append(embedded, bt)
+	}
+
+	if 0 < len(inputs) {
+		vectors, embedErr := feature.embed(inputs)
+		if nil != embedErr {
+			logging.LogErrorf("embed docs failed: %s", embedErr)
+		} else {
+			docEmbeddingsLock.Lock()
+			for i, bt := range embedded {
+				docEmbeddings[bt.ID] = &docEmbedding{Updated: bt.Updated, Model: feature.model, Vector: vectors[i]}
+			}
+			docEmbeddingsLock.Unlock()
+			changed = true
+		}
+	}
+
+	if changed {
+		saveDocEmbeddings()
+	}
+}
+
+// GetRelatedBlocks 根据向量相似度获取与指定块所在文档语义相关的文档。
+func GetRelatedBlocks(id string, threshold float64, limit int) (ret []*RelatedBlock) {
+	ret = []*RelatedBlock{}
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return
+	}
+	if 0 >= threshold {
+		threshold = 0.75
+	}
+	if 1 > limit {
+		limit = 10
+	}
+
+	loadDocEmbeddings()
+
+	docEmbeddingsLock.RLock()
+	defer docEmbeddingsLock.RUnlock()
+	current := docEmbeddings[bt.RootID]
+	if nil == current {
+		return
+	}
+	for rootID, embedding := range docEmbeddings {
+		if rootID == bt.RootID || embedding.Model != current.Model {
+			continue
+		}
+
+		score := cosineSimilarity(current.Vector, embedding.Vector)
+		if score < threshold {
+			continue
+		}
+		root := treenode.GetBlockTree(rootID)
+		if nil == root {
+			continue
+		}
+		ret = append(ret, &RelatedBlock{ID: rootID, Box: root.BoxID, HPath: root.HPath, Score: score})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Score > ret[j].Score })
+	if limit < len(ret) {
+		ret = ret[:limit]
+	}
+	return
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || 1 > len(a) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if 0 == normA || 0 == normB {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func loadDocEmbeddings() {
+	docEmbeddingsLock.Lock()
+	defer docEmbeddingsLock.Unlock()
+
+	if docEmbeddingsLoaded {
+		return
+	}
+	docEmbeddingsLoaded = true
+	docEmbeddings = map[string]*docEmbedding{}
+
+	p := filepath.Join(util.TempDir, "embeddings.gob")
+	file, err := os.Open(p)
+	if nil != err {
+		return
+	}
+	defer file.Close()
+	if err = gob.NewDecoder(file).Decode(&docEmbeddings); nil != err {
+		logging.LogWarnf("decode doc embeddings failed: %s", err)
+		docEmbeddings = map[string]*docEmbedding{}
+	}
+}
+
+func saveDocEmbeddings() {
+	docEmbeddingsLock.RLock()
+	defer docEmbeddingsLock.RUnlock()
+
+	p := filepath.Join(util.TempDir, "embeddings.gob")
+	tmp := p + ".tmp"
+	file, err := os.Create(tmp)
+	if nil != err {
+		logging.LogErrorf("create doc embeddings file failed: %s", err)
+		return
+	}
+	if err = gob.NewEncoder(file).Encode(docEmbeddings); nil != err {
+		file.Close()
+		logging.LogErrorf("encode doc embeddings failed: %s", err)
+		return
+	}
+	file.Close()
+	if err = os.Rename(tmp, p); nil != err {
+		logging.LogErrorf("rename doc embeddings file failed: %s", err)
+	}
+}
@@ -0,0 +1,187 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AIStreamEvent 是通过 WebSocket 推送的流式回复事件。
+type AIStreamEvent struct {
+	ID       string `json:"id"`
+	Delta    string `json:"delta"`
+	Done     bool   `json:"done"`
+	Canceled bool   `json:"canceled"`
+	Err      string `json:"err"`
+}
+
+const (
+	aiStreamMaxDuration   = 10 * time.Minute
+	aiStreamWriteInterval = 500 * time.Millisecond
+)
+
+var (
+	aiStreams     = map[string]context.CancelFunc{}
+	aiStreamsLock = sync.Mutex{}
+)
+
+// StartAIStream 开始一次流式对话，返回流 ID。回复内容通过 WebSocket 的 aiStream 事件推送。
+//
+// ids 不为空时使用这些块的内容和 action 作为提问；targetID 不为空时会把已收到的内容增量写入该块，完成后按 Markdown 解析为块。
+func StartAIStream(msg string, ids []string, action, targetID string) (ret string, err error) {
+	if !isAIEnabled() {
+		err = errors.New(Conf.Language(193))
+		return
+	}
+	if "" != targetID {
+		if bt := treenode.GetBlockTree(targetID); nil == bt || "d" == bt.Type {
+			err = ErrBlockNotFound
+			return
+		}
+	}
+
+	var contextMsgs []string
+	if 0 < len(ids) {
+		msg = getBlocksContent(ids)
+		if action = strings.TrimSpace(action); "" != action {
+			msg = action + ":\n\n" + msg
+		}
+	} else {
+		contextMsgs = cachedContextMsg
+		if Conf.AI.OpenAI.APIMaxContexts < len(contextMsgs) {
+			contextMsgs = contextMsgs[len(contextMsgs)-Conf.AI.OpenAI.APIMaxContexts:]
+		}
+	}
+
+	feature, err := getAIFeature(AIFeatureChat)
+	if nil != err {
+		return
+	}
+
+	ret = ast.NewNodeID()
+	ctx, cancel := context.WithTimeout(context.Background(), aiStreamMaxDuration)
+	aiStreamsLock.Lock()
+	aiStreams[ret] = cancel
+	aiStreamsLock.Unlock()
+
+	go runAIStream(ctx, ret, feature, msg, contextMsgs, targetID, 0 == len(ids))
+	return
+}
+
+// CancelAIStream 取消流式对话，已收到的内容会保留。
+func CancelAIStream(id string) bool {
+	aiStreamsLock.Lock()
+	defer aiStreamsLock.Unlock()
+
+	cancel := aiStreams[id]
+	if nil == cancel {
+		return false
+	}
+	cancel()
+	return true
+}
+
+func runAIStream(ctx context.Context, id string, feature *aiFeature, msg string, contextMsgs []string, targetID string, keepContext bool) {
+	defer logging.Recover()
+	defer func() {
+		aiStreamsLock.Lock()
+		if cancel := aiStreams[id]; nil != cancel {
+			cancel()
+		}
+		delete(aiStreams, id)
+		aiStreamsLock.Unlock()
+	}()
+
+	req := &AIChatRequest{Model: feature.model, MaxTokens: feature.maxTokens, Temperature: feature.temperature}
+	for _, ctxMsg := range contextMsgs {
+		req.Messages = append(req.Messages, &AIMessage{Role: "user", Content: ctxMsg})
+	}
+	req.Messages = append(req.Messages, &AIMessage{Role: "user", Content: msg})
+
+	buf := &strings.Builder{}
+	lastWrite := time.Now()
+	_, err := feature.provider.chatStream(ctx, req, func(delta string) {
+		buf.WriteString(delta)
+		util.BroadcastByType("main", "aiStream", 0, "", &AIStreamEvent{ID: id, Delta: delta})
+
+		if "" != targetID && aiStreamWriteInterval < time.Since(lastWrite) {
+			writeAIStreamPartial(targetID, buf.String())
+			lastWrite = time.Now()
+		}
+	})
+
+	evt := &AIStreamEvent{ID: id, Done: true}
+	if nil != err {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			evt.Canceled = true
+		} else {
+			logging.LogErrorf("AI chat stream failed: %s", err)
+			evt.Err = err.Error()
+		}
+	}
+
+	content := strings.TrimSpace(buf.String())
+	if "" != targetID && "" != content {
+		writeAIStreamFinal(targetID, content)
+	}
+	if keepContext && nil == err && "" != content {
+		cachedContextMsg = append(cachedContextMsg, msg, content)
+	}
+	util.BroadcastByType("main", "aiStream", 0, "", evt)
+}
+
+// writeAIStreamPartial 将已收到的内容作为纯文本写入目标块。
+func writeAIStreamPartial(targetID, content string) {
+	p := treenode.NewParagraph()
+	p.ID = targetID
+	p.SetIALAttr("id", targetID)
+	p.SetIALAttr("updated", util.CurrentTimeSecondsStr())
+	p.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(content)})
+
+	luteEngine := util.NewLute()
+	dom := luteEngine.RenderNodeBlockDOM(p)
+	performAIStreamTx([]*Operation{{Action: "update", ID: targetID, Data: dom}})
+}
+
+// writeAIStreamFinal 将完整的回复按 Markdown 解析为块，替换目标块。
+func writeAIStreamFinal(targetID, content string) {
+	luteEngine := util.NewLute()
+	dom := luteEngine.Md2BlockDOM(content, true)
+	performAIStreamTx([]*Operation{
+		{Action: "insert", Data: dom, PreviousID: targetID},
+		{Action: "delete", ID: targetID},
+	})
+}
+
+func performAIStreamTx(ops []*Operation) {
+	transactions := []*Transaction{{DoOperations: ops}}
+	PerformTransactions(&transactions)
+	WaitForWritingFiles()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+}
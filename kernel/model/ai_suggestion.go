@@ -0,0 +1,406 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 标签和属性建议方式
+const (
+	AISuggestOff   = ""      // 关闭
+	AISuggestAI    = "ai"    // 使用配置的 AI 提供方
+	AISuggestLocal = "local" // 使用本地分类器：匹配工作空间中已有的标签
+)
+
+// AISuggestion 描述了针对一个文档的标签和属性建议，用户确认后才会写入文档。
+type AISuggestion struct {
+	ID      string            `json:"id"`
+	DocID   string            `json:"docID"`
+	Box     string            `json:"box"`
+	HPath   string            `json:"hPath"`
+	Tags    []string          `json:"tags"`
+	Attrs   map[string]string `json:"attrs"`  // 仅包含 custom- 前缀的属性
+	Source  string            `json:"source"` // ai/local
+	Created int64             `json:"created"`
+}
+
+const (
+	suggestDocsBatchSize   = 8
+	suggestDocMaxRuneCount = 4000
+	maxAISuggestions       = 512
+)
+
+var aiSuggestionLock = sync.Mutex{}
+
+// SuggestTagsJob 为新建或修改过的文档生成标签和属性建议，需要在 AI 设置中开启。
+//
+// 首次开启时仅记录当前所有文档的状态，之后只处理新建或修改过的文档。
+func SuggestTagsJob() {
+	mode := Conf.AI.SuggestTags
+	if !util.IsBooted() || util.IsExiting.Load() || util.ReadOnly || AISuggestOff == mode {
+		return
+	}
+
+	aiSuggestionLock.Lock()
+	defer aiSuggestionLock.Unlock()
+
+	state, baseline := loadAISuggestionState()
+	var pending []*treenode.BlockTree
+	for _, bt := range treenode.GetBlockTreesByType("d") {
+		if state[bt.ID] == bt.Updated {
+			continue
+		}
+		if baseline {
+			state[bt.ID] = bt.Updated
+			continue
+		}
+		pending = append(pending, bt)
+	}
+	if baseline {
+		saveAISuggestionState(state)
+		return
+	}
+	if 1 > len(pending) {
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Updated > pending[j].Updated })
+	if suggestDocsBatchSize < len(pending) {
+		pending = pending[:suggestDocsBatchSize]
+	}
+
+	labels := map[string]bool{}
+	for label := range labelTags() {
+		labels[label] = true
+	}
+
+	suggestions := loadAISuggestions()
+	for _, bt := range pending {
+		state[bt.ID] = bt.Updated
+
+		content := getBlocksContent([]string{bt.ID})
+		if "" == strings.TrimSpace(content) {
+			continue
+		}
+		ial := getDocIAL(bt.ID)
+		existingTags := splitDocTags(ial["tags"])
+
+		var tags []string
+		var attrs map[string]string
+		var err error
+		if AISuggestAI == mode {
+			tags, attrs, err = suggestTagsByAI(bt.HPath, content, labels)
+		} else {
+			tags = suggestTagsByLabels(content, labels)
+		}
+		if nil != err {
+			logging.LogWarnf("suggest tags for doc [%s] failed: %s", bt.ID, err)
+			delete(state, bt.ID) // 下次重试
+			continue
+		}
+
+		var newTags []string
+		for _, tag := range tags {
+			if !gulu.Str.Contains(tag, existingTags) {
+				newTags = append(newTags, tag)
+			}
+		}
+		for name, value := range attrs {
+			if ial[name] == value {
+				delete(attrs, name)
+			}
+		}
+		if 1 > len(newTags) && 1 > len(attrs) {
+			continue
+		}
+
+		var tmp []*AISuggestion
+		for _, s := range suggestions {
+			if s.DocID != bt.ID { // 同一文档只保留最新的建议
+				tmp = append(tmp, s)
+			}
+		}
+		suggestions = append(tmp, &AISuggestion{
+			ID:      ast.NewNodeID(),
+			DocID:   bt.ID,
+			Box:     bt.BoxID,
+			HPath:   bt.HPath,
+			Tags:    newTags,
+			Attrs:   attrs,
+			Source:  mode,
+			Created: util.CurrentTimeMillis(),
+		})
+	}
+	if maxAISuggestions < len(suggestions) {
+		suggestions = suggestions[len(suggestions)-maxAISuggestions:]
+	}
+	saveAISuggestions(suggestions)
+	saveAISuggestionState(state)
+}
+
+// GetAISuggestions 获取待确认的建议。
+func GetAISuggestions() (ret []*AISuggestion) {
+	aiSuggestionLock.Lock()
+	defer aiSuggestionLock.Unlock()
+
+	ret = loadAISuggestions()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created > ret[j].Created })
+	return
+}
+
+// AcceptAISuggestion 确认建议并写入文档，tags 和 attrs 为用户最终选择的内容，为 nil 时使用建议的全部内容。
+func AcceptAISuggestion(id string, tags []string, attrs map[string]string) (err error) {
+	aiSuggestionLock.Lock()
+	defer aiSuggestionLock.Unlock()
+
+	suggestions := loadAISuggestions()
+	var suggestion *AISuggestion
+	var tmp []*AISuggestion
+	for _, s := range suggestions {
+		if s.ID == id {
+			suggestion = s
+			continue
+		}
+		tmp = append(tmp, s)
+	}
+	if nil == suggestion {
+		return fmt.Errorf("suggestion [%s] not found", id)
+	}
+	if nil == tags {
+		tags = suggestion.Tags
+	}
+	if nil == attrs {
+		attrs = suggestion.Attrs
+	}
+
+	nameValues := map[string]string{}
+	for name, value := range attrs {
+		if !strings.HasPrefix(name, "custom-") {
+			return errors.New("only custom attributes are allowed")
+		}
+		nameValues[name] = value
+	}
+	if 0 < len(tags) {
+		docTags := splitDocTags(getDocIAL(suggestion.DocID)["tags"])
+		for _, tag := range tags {
+			if tag = strings.TrimSpace(tag); "" != tag && !gulu.Str.Contains(tag, docTags) {
+				docTags = append(docTags, tag)
+			}
+		}
+		nameValues["tags"] = strings.Join(docTags, ",")
+	}
+
+	if 0 < len(nameValues) {
+		if err = SetBlockAttrs(suggestion.DocID, nameValues); nil != err {
+			return
+		}
+
+		// 写入属性会修改文档更新时间，避免再次为该文档生成建议
+		if bt := treenode.GetBlockTree(suggestion.DocID); nil != bt {
+			if state, baseline := loadAISuggestionState(); !baseline {
+				state[bt.ID] = bt.Updated
+				saveAISuggestionState(state)
+			}
+		}
+	}
+	saveAISuggestions(tmp)
+	return
+}
+
+// RejectAISuggestion 忽略建议。
+func RejectAISuggestion(id string) {
+	aiSuggestionLock.Lock()
+	defer aiSuggestionLock.Unlock()
+
+	var tmp []*AISuggestion
+	for _, s := range loadAISuggestions() {
+		if s.ID != id {
+			tmp = append(tmp, s)
+		}
+	}
+	saveAISuggestions(tmp)
+}
+
+func getDocIAL(id string) (ret map[string]string) {
+	ret = map[string]string{}
+	tree, err := LoadTreeByBlockID(id)
+	if nil != err {
+		return
+	}
+	return parse.IAL2Map(tree.Root.KramdownIAL)
+}
+
+func splitDocTags(tags string) (ret []string) {
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); "" != tag {
+			ret = append(ret, tag)
+		}
+	}
+	return
+}
+
+// suggestTagsByLabels 是本地分类器：文档内容中出现已有标签名时建议该标签。
+func suggestTagsByLabels(content string, labels map[string]bool) (ret []string) {
+	content = strings.ToLower(content)
+	for label := range labels {
+		name := label
+		if i := strings.LastIndex(label, "/"); 0 <= i {
+			name = label[i+1:]
+		}
+		if 2 > utf8.RuneCountInString(name) {
+			continue
+		}
+		if strings.Contains(content, strings.ToLower(name)) {
+			ret = append(ret, label)
+		}
+	}
+	sort.Strings(ret)
+	if 5 < len(ret) {
+		ret = ret[:5]
+	}
+	return
+}
+
+func suggestTagsByAI(hPath, content string, labels map[string]bool) (tags []string, attrs map[string]string, err error) {
+	feature, err := getAIFeature(AIFeatureChat)
+	if nil != err {
+		return
+	}
+
+	if suggestDocMaxRuneCount < utf8.RuneCountInString(content) {
+		content = string([]rune(content)[:suggestDocMaxRuneCount])
+	}
+	var existing []string
+	for label := range labels {
+		existing = append(existing, label)
+	}
+	sort.Strings(existing)
+	if 200 < len(existing) {
+		existing = existing[:200]
+	}
+
+	prompt := "Suggest at most 5 tags for the following note. Prefer existing tags: " + strings.Join(existing, ", ") + ".\n" +
+		"You may also suggest custom attributes whose names start with \"custom-\".\n" +
+		"Reply with JSON only, for example {\"tags\": [\"tag1\"], \"attrs\": {\"custom-status\": \"draft\"}}.\n\n" +
+		"Title: " + hPath + "\n\n" + content
+	reply, _, err := feature.chat(prompt, nil)
+	if nil != err {
+		return
+	}
+
+	// 回复中可能包含代码块标记
+	if start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}"); 0 <= start && start < end {
+		reply = reply[start : end+1]
+	}
+	result := &struct {
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+	}{}
+	if err = gulu.JSON.UnmarshalJSON([]byte(reply), result); nil != err {
+		return
+	}
+	for _, tag := range result.Tags {
+		if tag = strings.Trim(strings.TrimSpace(tag), "#"); "" != tag && !gulu.Str.Contains(tag, tags) {
+			tags = append(tags, tag)
+		}
+	}
+	if 5 < len(tags) {
+		tags = tags[:5]
+	}
+	for name, value := range result.Attrs {
+		if strings.HasPrefix(name, "custom-") && "" != strings.TrimSpace(value) {
+			if nil == attrs {
+				attrs = map[string]string{}
+			}
+			attrs[name] = value
+		}
+	}
+	return
+}
+
+func loadAISuggestions() (ret []*AISuggestion) {
+	ret = []*AISuggestion{}
+	p := filepath.Join(util.DataDir, "storage", "ai-suggestions.json")
+	if !filelock.IsExist(p) {
+		return
+	}
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read AI suggestions failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal AI suggestions failed: %s", err)
+	}
+	return
+}
+
+func saveAISuggestions(suggestions []*AISuggestion) {
+	if nil == suggestions {
+		suggestions = []*AISuggestion{}
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(suggestions, "", "\t")
+	if nil != err {
+		logging.LogErrorf("marshal AI suggestions failed: %s", err)
+		return
+	}
+	p := filepath.Join(util.DataDir, "storage", "ai-suggestions.json")
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write AI suggestions failed: %s", err)
+	}
+}
+
+// loadAISuggestionState 加载当前设备上已处理文档的更新时间，文件不存在时 baseline 为 true。
+func loadAISuggestionState() (ret map[string]string, baseline bool) {
+	ret = map[string]string{}
+	p := filepath.Join(util.TempDir, "ai-suggestion-state.json")
+	data, err := os.ReadFile(p)
+	if nil != err {
+		baseline = true
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal AI suggestion state failed: %s", err)
+	}
+	return
+}
+
+func saveAISuggestionState(state map[string]string) {
+	data, err := gulu.JSON.MarshalJSON(state)
+	if nil != err {
+		logging.LogErrorf("marshal AI suggestion state failed: %s", err)
+		return
+	}
+	if err = gulu.File.WriteFileSafer(filepath.Join(util.TempDir, "ai-suggestion-state.json"), data, 0644); nil != err {
+		logging.LogErrorf("write AI suggestion state failed: %s", err)
+	}
+}
@@ -0,0 +1,337 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AISummaryResult 描述了子树摘要的结果。
+type AISummaryResult struct {
+	Content      string         `json:"content"` // Markdown
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// aiSummarySection 是按标题层级划分的摘要小节。
+type aiSummarySection struct {
+	title    string
+	level    int
+	content  bytes.Buffer
+	children []*aiSummarySection
+	summary  string
+}
+
+const (
+	aiSummaryDefaultChunkSize = 8000 // 默认每次请求的内容字符数
+	aiSummaryMinChunkSize     = 1000
+	aiSummaryMaxReduceRounds  = 8
+)
+
+// SummarizeSubtree 对块及其子树生成分层摘要。
+//
+// 内容按照标题划分小节，嵌入块会被展开，折叠的块默认跳过。超过 chunkSize 的内容会被切分后分别摘要再合并。
+// targetID 不为空时将摘要插入到该块之后（目标是文档时追加到文档末尾），否则仅返回 Markdown。
+func SummarizeSubtree(id, targetID string, chunkSize int, includeFolded bool) (ret *AISummaryResult, err error) {
+	if aiSummaryMinChunkSize > chunkSize {
+		if 0 < chunkSize {
+			chunkSize = aiSummaryMinChunkSize
+		} else {
+			chunkSize = aiSummaryDefaultChunkSize
+		}
+	}
+
+	tree, err := LoadTreeByBlockID(id)
+	if nil != err {
+		return
+	}
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node {
+		err = ErrBlockNotFound
+		return
+	}
+	if "" != targetID && nil == treenode.GetBlockTree(targetID) {
+		err = ErrBlockNotFound
+		return
+	}
+
+	feature, err := getAIFeature(AIFeatureSummarize)
+	if nil != err {
+		return
+	}
+
+	root := &aiSummarySection{}
+	var nodes []*ast.Node
+	switch node.Type {
+	case ast.NodeDocument:
+		root.title = tree.Root.IALAttr("title")
+		for c := node.FirstChild; nil != c; c = c.Next {
+			nodes = append(nodes, c)
+		}
+	case ast.NodeHeading:
+		root.title = node.Text()
+		root.level = node.HeadingLevel
+		nodes = treenode.HeadingChildren(node)
+	default:
+		nodes = append(nodes, node)
+	}
+
+	luteEngine := util.NewLute()
+	stack := []*aiSummarySection{root}
+	for _, n := range nodes {
+		if !includeFolded && "1" == n.IALAttr("heading-fold") {
+			continue
+		}
+
+		if ast.NodeHeading == n.Type {
+			for 1 < len(stack) && stack[len(stack)-1].level >= n.HeadingLevel {
+				stack = stack[:len(stack)-1]
+			}
+			section := &aiSummarySection{title: n.Text(), level: n.HeadingLevel}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, section)
+			stack = append(stack, section)
+			continue
+		}
+
+		current := stack[len(stack)-1]
+		current.content.WriteString(summaryNodeMarkdown(n, luteEngine, includeFolded))
+	}
+
+	summarizer := &aiSummarizer{feature: feature, chunkSize: chunkSize}
+	if err = summarizer.summarize(root); nil != err {
+		return
+	}
+	if "" == root.summary && 1 > len(root.children) {
+		err = errors.New("nothing to summarize")
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if "" != root.summary {
+		buf.WriteString(root.summary)
+		buf.WriteString("\n\n")
+	}
+	writeSummaryList(buf, root.children, 0)
+
+	ret = &AISummaryResult{Content: strings.TrimSpace(buf.String())}
+	if "" != targetID {
+		ret.Transactions, err = writeAIActionOutput(conf.AIActionOutputAppend, []string{targetID}, ret.Content)
+	}
+	return
+}
+
+// summaryNodeMarkdown 导出块的 Markdown，展开嵌入块并跳过折叠的块。
+func summaryNodeMarkdown(n *ast.Node, luteEngine *lute.Lute, includeFolded bool) string {
+	if !includeFolded && ast.NodeHeading != n.Type && "1" == n.IALAttr("fold") {
+		return ""
+	}
+
+	if ast.NodeBlockQueryEmbed == n.Type {
+		script := n.ChildByType(ast.NodeBlockQueryEmbedScript)
+		if nil == script {
+			return ""
+		}
+		stmt := html.UnescapeString(script.TokensStr())
+		stmt = strings.ReplaceAll(stmt, editor.IALValEscNewLine, "\n")
+		buf := bytes.Buffer{}
+		for _, embed := range searchEmbedBlock(n.ID, stmt, nil, 0, false) {
+			buf.WriteString(renderBlockMarkdownR(embed.Block.ID))
+			buf.WriteString("\n\n")
+		}
+		return buf.String()
+	}
+
+	// 容器块中包含嵌入块或者折叠的块时逐个处理子块
+	special := false
+	ast.Walk(n, func(c *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || c == n || !c.IsBlock() {
+			return ast.WalkContinue
+		}
+		if ast.NodeBlockQueryEmbed == c.Type || (!includeFolded && "1" == c.IALAttr("fold")) {
+			special = true
+			return ast.WalkStop
+		}
+		return ast.WalkContinue
+	})
+	if !special {
+		return treenode.ExportNodeStdMd(n, luteEngine) + "\n\n"
+	}
+
+	buf := bytes.Buffer{}
+	for c := n.FirstChild; nil != c; c = c.Next {
+		if c.IsBlock() {
+			buf.WriteString(summaryNodeMarkdown(c, luteEngine, includeFolded))
+		}
+	}
+	return buf.String()
+}
+
+type aiSummarizer struct {
+	feature   *aiFeature
+	chunkSize int
+	requested int
+}
+
+// summarize 自底向上生成小节摘要：先摘要子小节，再将本小节内容的分块摘要和子小节摘要合并。
+func (summarizer *aiSummarizer) summarize(section *aiSummarySection) (err error) {
+	for _, child := range section.children {
+		if err = summarizer.summarize(child); nil != err {
+			return
+		}
+	}
+
+	var parts []string
+	for _, chunk := range splitSummaryChunks(section.content.String(), summarizer.chunkSize) {
+		var part string
+		if part, err = summarizer.request(section.title, chunk, false); nil != err {
+			return
+		}
+		if "" != part {
+			parts = append(parts, part)
+		}
+	}
+
+	var childSummaries []string
+	for _, child := range section.children {
+		if "" != child.summary {
+			childSummaries = append(childSummaries, "## "+child.title+"\n\n"+child.summary)
+		}
+	}
+
+	if 1 > len(childSummaries) && 2 > len(parts) {
+		if 1 == len(parts) {
+			section.summary = parts[0]
+		}
+		return
+	}
+	if 1 > len(parts) && 2 > len(childSummaries) && 1 > section.level {
+		// 根节点只有一个子小节时不重复摘要
+		return
+	}
+
+	inputs := append(parts, childSummaries...)
+	for i := 0; i < aiSummaryMaxReduceRounds; i++ {
+		combined := strings.Join(inputs, "\n\n")
+		chunks := splitSummaryChunks(combined, summarizer.chunkSize)
+		if 2 > len(chunks) {
+			section.summary, err = summarizer.request(section.title, combined, true)
+			return
+		}
+
+		inputs = nil
+		for _, chunk := range chunks {
+			var part string
+			if part, err = summarizer.request(section.title, chunk, true); nil != err {
+				return
+			}
+			if "" != part {
+				inputs = append(inputs, part)
+			}
+		}
+	}
+	section.summary = strings.Join(inputs, "\n\n")
+	return
+}
+
+func (summarizer *aiSummarizer) request(title, content string, combine bool) (ret string, err error) {
+	summarizer.requested++
+	util.PushEndlessProgress(fmt.Sprintf("Summarizing [%d]...", summarizer.requested))
+	defer util.ClearPushProgress(100)
+
+	prompt := "Summarize the following content concisely in the same language as the content."
+	if combine {
+		prompt = "The following are summaries of parts of a section. Merge them into one concise summary in the same language."
+	}
+	prompt += " Reply with Markdown paragraphs or lists only, without headings."
+	if "" != title {
+		prompt += "\n\nSection: " + title
+	}
+	prompt += "\n\n" + content
+	ret, _, err = summarizer.feature.chat(prompt, nil)
+	return
+}
+
+// splitSummaryChunks 按段落切分内容，每块不超过 size 个字符，超长的段落会被强制切分。
+func splitSummaryChunks(content string, size int) (ret []string) {
+	content = strings.TrimSpace(content)
+	if "" == content {
+		return
+	}
+
+	buf := bytes.Buffer{}
+	count := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(buf.String()); "" != chunk {
+			ret = append(ret, chunk)
+		}
+		buf.Reset()
+		count = 0
+	}
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		runes := []rune(paragraph)
+		for size < len(runes) {
+			flush()
+			ret = append(ret, string(runes[:size]))
+			runes = runes[size:]
+		}
+		paragraph = string(runes)
+
+		n := utf8.RuneCountInString(paragraph) + 2
+		if size < count+n {
+			flush()
+		}
+		buf.WriteString(paragraph)
+		buf.WriteString("\n\n")
+		count += n
+	}
+	flush()
+	return
+}
+
+func writeSummaryList(buf *bytes.Buffer, sections []*aiSummarySection, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, section := range sections {
+		if "" == section.summary && 1 > len(section.children) {
+			continue
+		}
+
+		buf.WriteString(indent + "* **" + strings.TrimSpace(section.title) + "**\n")
+		if "" != section.summary {
+			buf.WriteString("\n")
+			for _, line := range strings.Split(section.summary, "\n") {
+				if "" != strings.TrimSpace(line) {
+					buf.WriteString(indent + "  " + line)
+				}
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\n")
+		}
+		writeSummaryList(buf, section.children, depth+1)
+	}
+}
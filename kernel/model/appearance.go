@@ -33,6 +33,8 @@ import (
 )
 
 func InitAppearance() {
+	defer util.StartBootStage("appearance", false)()
+
 	util.SetBootDetails("Initializing appearance...")
 	if err := os.Mkdir(util.AppearancePath, 0755); nil != err && !os.IsExist(err) {
 		logging.LogErrorf("create appearance folder [%s] failed: %s", util.AppearancePath, err)
@@ -47,14 +47,17 @@ import (
 )
 
 type AssetContent struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Ext     string `json:"ext"`
-	Path    string `json:"path"`
-	Size    int64  `json:"size"`
-	HSize   string `json:"hSize"`
-	Updated int64  `json:"updated"`
-	Content string `json:"content"`
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	Ext     string     `json:"ext"`
+	Path    string     `json:"path"`
+	Size    int64      `json:"size"`
+	HSize   string     `json:"hSize"`
+	Updated int64      `json:"updated"`
+	Content string     `json:"content"`
+	Page    int        `json:"page"`  // 命中的页码，从 1 开始，为 0 时表示资源文件不分页
+	Thumb   string     `json:"thumb"` // 缩略图地址，无法生成缩略图时和 Path 相同
+	Meta    *AssetMeta `json:"meta"`  // 元数据，没有元数据时为空
 }
 
 func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
@@ -71,7 +74,7 @@ func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
 	}
 
 	projections := "id, name, ext, path, size, updated, " +
-		"highlight(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "') AS content"
+		"highlight(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "') AS content, page"
 	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + filter
 	assetContents := sql.SelectAssetContentsRawStmt(stmt, 1, 1)
 	results := fromSQLAssetContents(&assetContents, 36)
@@ -87,21 +90,30 @@ func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
 //
 // method：0：关键字，1：查询语法，2：SQL，3：正则表达式
 // orderBy: 0：按相关度降序，1：按相关度升序，2：按更新时间升序，3：按更新时间降序
-func FullTextSearchAssetContent(query string, types map[string]bool, method, orderBy, page, pageSize int) (ret []*AssetContent, matchedAssetCount, pageCount int) {
+// metaFilter：元数据过滤条件，SQL 搜索时忽略
+func FullTextSearchAssetContent(query string, types map[string]bool, metaFilter *AssetMetaFilter, method, orderBy, page, pageSize int) (ret []*AssetContent, matchedAssetCount, pageCount int) {
 	query = strings.TrimSpace(query)
 	beforeLen := 36
 	orderByClause := buildAssetContentOrderBy(orderBy)
+	// 元数据过滤条件以 AND 连接在类型过滤条件 ext IN (...) 之后
+	metaFilterClause := buildAssetMetaFilter(metaFilter)
+	if "" == query && "" != metaFilterClause && 2 != method {
+		// 仅按元数据过滤，比如搜索某个月拍摄的照片
+		method = -1
+	}
 	switch method {
+	case -1: // 仅元数据
+		ret, matchedAssetCount = searchAssetContentByMeta(buildAssetContentTypeFilter(types)+metaFilterClause, orderBy, beforeLen, page, pageSize)
 	case 1: // 查询语法
-		filter := buildAssetContentTypeFilter(types)
+		filter := buildAssetContentTypeFilter(types) + metaFilterClause
 		ret, matchedAssetCount = fullTextSearchAssetContentByQuerySyntax(query, filter, orderByClause, beforeLen, page, pageSize)
 	case 2: // SQL
 		ret, matchedAssetCount = searchAssetContentBySQL(query, beforeLen, page, pageSize)
 	case 3: // 正则表达式
-		typeFilter := buildAssetContentTypeFilter(types)
+		typeFilter := buildAssetContentTypeFilter(types) + metaFilterClause
 		ret, matchedAssetCount = fullTextSearchAssetContentByRegexp(query, typeFilter, orderByClause, beforeLen, page, pageSize)
 	default: // 关键字
-		filter := buildAssetContentTypeFilter(types)
+		filter := buildAssetContentTypeFilter(types) + metaFilterClause
 		ret, matchedAssetCount = fullTextSearchAssetContentByKeyword(query, filter, orderByClause, beforeLen, page, pageSize)
 	}
 	pageCount = (matchedAssetCount + pageSize - 1) / pageSize
@@ -109,6 +121,28 @@ func FullTextSearchAssetContent(query string, types map[string]bool, method, ord
 	if 1 > len(ret) {
 		ret = []*AssetContent{}
 	}
+	fillAssetContentsMeta(ret)
+	return
+}
+
+func searchAssetContentByMeta(filter string, orderBy, beforeLen, page, pageSize int) (ret []*AssetContent, matchedAssetCount int) {
+	orderByClause := "ORDER BY updated DESC"
+	if 2 == orderBy {
+		orderByClause = "ORDER BY updated ASC"
+	}
+
+	table := "asset_contents_fts_case_insensitive"
+	stmt := "SELECT id, name, ext, path, size, updated, content, page FROM " + table + " WHERE ext IN " + filter
+	stmt += " " + orderByClause
+	stmt += " LIMIT " + strconv.Itoa(pageSize) + " OFFSET " + strconv.Itoa((page-1)*pageSize)
+	assetContents := sql.SelectAssetContentsRawStmtNoParse(stmt, pageSize)
+	ret = fromSQLAssetContents(&assetContents, beforeLen)
+
+	result, _ := sql.QueryAssetContentNoLimit("SELECT COUNT(path) AS `assets` FROM `" + table + "` WHERE ext IN " + filter)
+	if 1 > len(result) {
+		return
+	}
+	matchedAssetCount = int(result[0]["assets"].(int64))
 	return
 }
 
@@ -164,7 +198,7 @@ func fullTextSearchAssetContentCountByRegexp(exp, typeFilter string) (matchedAss
 func fullTextSearchAssetContentByFTS(query, typeFilter, orderBy string, beforeLen, page, pageSize int) (ret []*AssetContent, matchedAssetCount int) {
 	table := "asset_contents_fts_case_insensitive"
 	projections := "id, name, ext, path, size, updated, " +
-		"snippet(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS content"
+		"snippet(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS content, page"
 	stmt := "SELECT " + projections + " FROM " + table + " WHERE (`" + table + "` MATCH '" + buildAssetContentColumnFilter() + ":(" + query + ")'"
 	stmt += ") AND ext IN " + typeFilter
 	stmt += " " + orderBy
@@ -239,6 +273,8 @@ func fromSQLAssetContent(assetContent *sql.AssetContent, beforeLen int) *AssetCo
 		HSize:   humanize.BytesCustomCeil(uint64(assetContent.Size), 2),
 		Updated: assetContent.Updated,
 		Content: content,
+		Page:    assetContent.Page,
+		Thumb:   util.AssetThumbPath(assetContent.Path),
 	}
 }
 
@@ -319,21 +355,17 @@ func IndexAssetContent(absPath string) {
 
 	assetsDir := util.GetDataAssetsAbsPath()
 	p := "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir))
-
-	assetContents := []*sql.AssetContent{
-		{
-			ID:      ast.NewNodeID(),
-			Name:    util.RemoveID(filepath.Base(p)),
-			Ext:     ext,
-			Path:    p,
-			Size:    info.Size(),
-			Updated: info.ModTime().Unix(),
-			Content: result.Content,
-		},
-	}
+	result.Path = p
+	result.Size = info.Size()
+	result.Updated = info.ModTime().Unix()
+	assetContents := newAssetContents(result)
 
 	sql.DeleteAssetContentsByPathQueue(p)
 	sql.IndexAssetContentsQueue(assetContents)
+	if nil != result.Meta {
+		result.Meta.Path = p
+		sql.IndexAssetMetasQueue([]*sql.AssetMeta{result.Meta})
+	}
 }
 
 func ReindexAssetContent() {
@@ -415,23 +447,22 @@ func (searcher *AssetsSearcher) FullIndex() {
 	})
 
 	var assetContents []*sql.AssetContent
+	var assetMetas []*sql.AssetMeta
 	for _, result := range results {
-		assetContents = append(assetContents, &sql.AssetContent{
-			ID:      ast.NewNodeID(),
-			Name:    util.RemoveID(filepath.Base(result.Path)),
-			Ext:     strings.ToLower(filepath.Ext(result.Path)),
-			Path:    result.Path,
-			Size:    result.Size,
-			Updated: result.Updated,
-			Content: result.Content,
-		})
+		assetContents = append(assetContents, newAssetContents(result)...)
+		if nil != result.Meta {
+			result.Meta.Path = result.Path
+			assetMetas = append(assetMetas, result.Meta)
+		}
 	}
 
 	sql.IndexAssetContentsQueue(assetContents)
+	sql.IndexAssetMetasQueue(assetMetas)
 }
 
 func NewAssetsSearcher() *AssetsSearcher {
 	txtAssetParser := &TxtAssetParser{}
+	imageAssetParser := &ImageAssetParser{}
 	return &AssetsSearcher{
 		parsers: map[string]AssetParser{
 			".txt":      txtAssetParser,
@@ -470,7 +501,12 @@ func NewAssetsSearcher() *AssetsSearcher {
 			".pptx":     &PptxAssetParser{},
 			".xlsx":     &XlsxAssetParser{},
 			".pdf":      &PdfAssetParser{},
+			".jpg":      imageAssetParser,
+			".jpeg":     imageAssetParser,
+			".tif":      imageAssetParser,
+			".tiff":     imageAssetParser,
 			".epub":     &EpubAssetParser{},
+			".zip":      &ZipAssetParser{},
 		},
 
 		lock: &sync.Mutex{},
@@ -491,6 +527,40 @@ type AssetParseResult struct {
 	Size    int64
 	Updated int64
 	Content string
+	Pages   []string       // 分页内容，不为空时按页索引，页码从 1 开始
+	Meta    *sql.AssetMeta // 元数据，没有元数据时为 nil
+}
+
+// newAssetContents 根据解析结果生成索引记录，有分页内容时每页一条记录。
+func newAssetContents(result *AssetParseResult) (ret []*sql.AssetContent) {
+	name := util.RemoveID(filepath.Base(result.Path))
+	ext := strings.ToLower(filepath.Ext(result.Path))
+	if 1 > len(result.Pages) {
+		ret = append(ret, &sql.AssetContent{
+			ID:      ast.NewNodeID(),
+			Name:    name,
+			Ext:     ext,
+			Path:    result.Path,
+			Size:    result.Size,
+			Updated: result.Updated,
+			Content: result.Content,
+		})
+		return
+	}
+
+	for i, page := range result.Pages {
+		ret = append(ret, &sql.AssetContent{
+			ID:      ast.NewNodeID(),
+			Name:    name,
+			Ext:     ext,
+			Path:    result.Path,
+			Size:    result.Size,
+			Updated: result.Updated,
+			Content: page,
+			Page:    i + 1,
+		})
+	}
+	return
 }
 
 type AssetParser interface {
@@ -578,6 +648,10 @@ func (parser *DocxAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 		return
 	}
 
+	if isOfficeAssetTooLarge(absPath) {
+		return
+	}
+
 	tmp := copyTempAsset(absPath)
 	if "" == tmp {
 		return
@@ -616,6 +690,10 @@ func (parser *PptxAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 		return
 	}
 
+	if isOfficeAssetTooLarge(absPath) {
+		return
+	}
+
 	tmp := copyTempAsset(absPath)
 	if "" == tmp {
 		return
@@ -654,6 +732,10 @@ func (parser *XlsxAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 		return
 	}
 
+	if isOfficeAssetTooLarge(absPath) {
+		return
+	}
+
 	tmp := copyTempAsset(absPath)
 	if "" == tmp {
 		return
@@ -724,15 +806,40 @@ func (parser *PdfAssetParser) getTextPageWorker(id int, instance pdfium.Pdfium,
 			continue
 		}
 
-		req := &requests.GetPageText{
-			Page: requests.Page{
-				ByIndex: &requests.PageByIndex{
-					Document: doc.Document,
-					Index:    pd.pageNo,
-				},
+		page := requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc.Document,
+				Index:    pd.pageNo,
 			},
 		}
-		res, err := instance.GetPageText(req)
+
+		// 优先使用带位置信息的文本还原多栏和表格的阅读顺序
+		structured, err := instance.GetPageTextStructured(&requests.GetPageTextStructured{
+			Page: page,
+			Mode: requests.GetPageTextStructuredModeRects,
+		})
+		if nil == err {
+			var rects []*pdfTextRect
+			for _, rect := range structured.Rects {
+				rects = append(rects, &pdfTextRect{
+					text:   rect.Text,
+					left:   rect.PointPosition.Left,
+					top:    rect.PointPosition.Top,
+					right:  rect.PointPosition.Right,
+					bottom: rect.PointPosition.Bottom,
+				})
+			}
+			instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{
+				Document: doc.Document,
+			})
+			result <- &pdfTextResult{
+				pageNo: pd.pageNo,
+				text:   layoutPDFPageText(rects),
+			}
+			continue
+		}
+
+		res, err := instance.GetPageText(&requests.GetPageText{Page: page})
 		if nil != err {
 			instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{
 				Document: doc.Document,
@@ -824,6 +931,7 @@ func (parser *PdfAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 		logging.LogErrorf("convert [%s] failed: [%s]", tmp, err)
 		return
 	}
+	meta := getPDFMeta(instance, doc.Document)
 	instance.Close()
 
 	if PDFAssetContentMaxPage < pc.PageCount {
@@ -890,12 +998,18 @@ func (parser *PdfAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 	}
 
 	// loop through ordered PDF text pages and join content for asset parse DB result
+	// each page is also kept separately so that search hits can be anchored to the page
 	contentBuilder := bytes.Buffer{}
+	var pages []string
 	for _, pt := range pageText {
-		contentBuilder.WriteString(" " + normalizeNonTxtAssetContent(pt))
+		pt = normalizeNonTxtAssetContent(pt)
+		contentBuilder.WriteString(" " + pt)
+		pages = append(pages, pt)
 	}
 	ret = &AssetParseResult{
 		Content: contentBuilder.String(),
+		Pages:   pages,
+		Meta:    meta,
 	}
 	return
 }
@@ -912,6 +1026,10 @@ func (parser *EpubAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 		return
 	}
 
+	if isOfficeAssetTooLarge(absPath) {
+		return
+	}
+
 	tmp := copyTempAsset(absPath)
 	if "" == tmp {
 		return
@@ -0,0 +1,243 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getContentIndexConf() *conf.ContentIndex {
+	if nil == Conf || nil == Conf.Asset || nil == Conf.Asset.ContentIndex {
+		return conf.NewContentIndex()
+	}
+	return Conf.Asset.ContentIndex
+}
+
+// isOfficeAssetTooLarge 判断 docx、pptx、xlsx 和 epub 文件是否超过索引大小上限。
+func isOfficeAssetTooLarge(absPath string) bool {
+	maxSize := getContentIndexConf().OfficeMaxSize
+	if 1 > maxSize {
+		return false
+	}
+
+	info, err := os.Stat(absPath)
+	if nil != err {
+		logging.LogErrorf("stat file [%s] failed: %s", absPath, err)
+		return true
+	}
+
+	if maxSize*1024*1024 < info.Size() {
+		logging.LogWarnf("asset [%s] is too large [%s]", absPath, humanize.BytesCustomCeil(uint64(info.Size()), 2))
+		return true
+	}
+	return false
+}
+
+// ZipAssetParser 解压 zip 压缩包并使用对应的解析器索引其中的文件内容，不处理嵌套的压缩包。
+type ZipAssetParser struct {
+}
+
+func (parser *ZipAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	if !strings.HasSuffix(strings.ToLower(absPath), ".zip") {
+		return
+	}
+
+	if !gulu.File.IsExist(absPath) {
+		return
+	}
+
+	indexConf := getContentIndexConf()
+	if 1 > indexConf.ArchiveMaxSize {
+		return
+	}
+
+	info, err := os.Stat(absPath)
+	if nil != err {
+		logging.LogErrorf("stat file [%s] failed: %s", absPath, err)
+		return
+	}
+
+	if indexConf.ArchiveMaxSize*1024*1024 < info.Size() {
+		logging.LogWarnf("archive asset [%s] is too large [%s]", absPath, humanize.BytesCustomCeil(uint64(info.Size()), 2))
+		return
+	}
+
+	tmp := copyTempAsset(absPath)
+	if "" == tmp {
+		return
+	}
+	defer os.RemoveAll(tmp)
+
+	reader, err := zip.OpenReader(tmp)
+	if nil != err {
+		logging.LogErrorf("open zip [%s] failed: %s", absPath, err)
+		return
+	}
+	defer reader.Close()
+
+	files := reader.File
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	entryMaxSize := uint64(indexConf.ArchiveEntryMaxSize) * 1024 * 1024
+	totalMaxSize := uint64(indexConf.ArchiveTotalMaxSize) * 1024 * 1024
+	var totalSize uint64
+	var count int
+	buf := bytes.Buffer{}
+	for _, file := range files {
+		if file.FileInfo().IsDir() || isIgnoredArchiveEntry(file.Name) {
+			continue
+		}
+
+		ext := strings.ToLower(path.Ext(file.Name))
+		if ".zip" == ext {
+			continue
+		}
+
+		entryParser := assetContentSearcher.GetParser(ext)
+		if nil == entryParser {
+			continue
+		}
+		if _, ok := entryParser.(*ImageAssetParser); ok {
+			continue
+		}
+
+		if 0 < indexConf.ArchiveMaxEntries && indexConf.ArchiveMaxEntries <= count {
+			logging.LogWarnf("archive asset [%s] has too many entries, only the first [%d] are indexed", absPath, count)
+			break
+		}
+
+		if 0 < entryMaxSize && entryMaxSize < file.UncompressedSize64 {
+			logging.LogWarnf("archive entry [%s/%s] is too large [%s]", absPath, file.Name, humanize.BytesCustomCeil(file.UncompressedSize64, 2))
+			continue
+		}
+
+		if 0 < totalMaxSize && totalMaxSize < totalSize+file.UncompressedSize64 {
+			logging.LogWarnf("archive asset [%s] exceeds the total uncompressed size limit, remaining entries are skipped", absPath)
+			break
+		}
+
+		content, size := parseArchiveEntry(file, entryParser, entryMaxSize)
+		totalSize += size
+		count++
+		if "" == content {
+			continue
+		}
+
+		buf.WriteString(file.Name)
+		buf.WriteString(" ")
+		buf.WriteString(content)
+		buf.WriteString(" ")
+	}
+
+	ret = &AssetParseResult{
+		Content: normalizeNonTxtAssetContent(buf.String()),
+	}
+	return
+}
+
+// parseArchiveEntry 解压压缩包中的单个文件并解析内容，返回解析得到的文本和实际解压的大小。
+func parseArchiveEntry(file *zip.File, parser AssetParser, maxSize uint64) (ret string, size uint64) {
+	rc, err := file.Open()
+	if nil != err {
+		logging.LogErrorf("open archive entry [%s] failed: %s", file.Name, err)
+		return
+	}
+	defer rc.Close()
+
+	// 按照实际读取的字节数限制大小，避免压缩包头部声明的大小不可信
+	var reader io.Reader = rc
+	if 0 < maxSize {
+		reader = io.LimitReader(rc, int64(maxSize)+1)
+	}
+
+	if _, ok := parser.(*TxtAssetParser); ok {
+		data, readErr := io.ReadAll(reader)
+		size = uint64(len(data))
+		if nil != readErr {
+			logging.LogErrorf("read archive entry [%s] failed: %s", file.Name, readErr)
+			return
+		}
+		if (0 < maxSize && maxSize < size) || TxtAssetContentMaxSize < len(data) || !utf8.Valid(data) {
+			return
+		}
+		ret = string(data)
+		return
+	}
+
+	dir := filepath.Join(util.TempDir, "convert", "asset_content")
+	if err = os.MkdirAll(dir, 0755); nil != err {
+		logging.LogErrorf("mkdir [%s] failed: [%s]", dir, err)
+		return
+	}
+
+	tmp := filepath.Join(dir, gulu.Rand.String(7)+path.Ext(file.Name))
+	f, err := os.Create(tmp)
+	if nil != err {
+		logging.LogErrorf("create [%s] failed: %s", tmp, err)
+		return
+	}
+	defer os.RemoveAll(tmp)
+
+	written, err := io.Copy(f, reader)
+	f.Close()
+	size = uint64(written)
+	if nil != err {
+		logging.LogErrorf("extract archive entry [%s] failed: %s", file.Name, err)
+		return
+	}
+	if 0 < maxSize && maxSize < size {
+		return
+	}
+
+	result := parser.Parse(tmp)
+	if nil == result {
+		return
+	}
+
+	ret = result.Content
+	if 0 < len(result.Pages) {
+		ret = strings.Join(result.Pages, " ")
+	}
+	return
+}
+
+func isIgnoredArchiveEntry(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
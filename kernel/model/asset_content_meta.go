@@ -0,0 +1,423 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+// AssetMeta 描述了资源文件的元数据。
+type AssetMeta struct {
+	Captured  string  `json:"captured"`  // 拍摄或者创建时间，格式为 yyyyMMddHHmmss
+	Latitude  float64 `json:"latitude"`  // 纬度
+	Longitude float64 `json:"longitude"` // 经度
+	Make      string  `json:"make"`      // 相机厂商
+	Model     string  `json:"model"`     // 相机型号
+	Author    string  `json:"author"`    // 文档作者
+	Title     string  `json:"title"`     // 文档标题
+}
+
+// AssetMetaFilter 描述了资源文件搜索时的元数据过滤条件，字段为空时不过滤。
+type AssetMetaFilter struct {
+	Captured     string `json:"captured"`     // 拍摄时间前缀，比如 2023、2023-05、2023-05-01
+	CapturedFrom string `json:"capturedFrom"` // 拍摄时间起始（包含），格式同 Captured
+	CapturedTo   string `json:"capturedTo"`   // 拍摄时间截止（包含），格式同 Captured
+	Camera       string `json:"camera"`       // 相机厂商或者型号包含的文本
+	Author       string `json:"author"`       // 文档作者包含的文本
+	Title        string `json:"title"`        // 文档标题包含的文本
+	HasGPS       bool   `json:"hasGPS"`       // 是否仅搜索带有 GPS 信息的资源文件
+}
+
+func (filter *AssetMetaFilter) IsEmpty() bool {
+	return nil == filter || ("" == filter.Captured && "" == filter.CapturedFrom && "" == filter.CapturedTo &&
+		"" == filter.Camera && "" == filter.Author && "" == filter.Title && !filter.HasGPS)
+}
+
+// buildAssetMetaFilter 根据元数据过滤条件生成资源文件内容表的过滤语句，返回值以 " AND " 开头。
+func buildAssetMetaFilter(filter *AssetMetaFilter) string {
+	if filter.IsEmpty() {
+		return ""
+	}
+
+	var conds []string
+	if captured := normalizeCapturedPrefix(filter.Captured); "" != captured {
+		conds = append(conds, "captured LIKE '"+captured+"%'")
+	}
+	if from := normalizeCapturedPrefix(filter.CapturedFrom); "" != from {
+		conds = append(conds, "captured >= '"+from+"'")
+	}
+	if to := normalizeCapturedPrefix(filter.CapturedTo); "" != to {
+		conds = append(conds, "'' != captured AND substr(captured, 1, "+strconv.Itoa(len(to))+") <= '"+to+"'")
+	}
+	if camera := escapeAssetMetaLike(filter.Camera); "" != camera {
+		conds = append(conds, "(make LIKE '%"+camera+"%' ESCAPE '\\' OR model LIKE '%"+camera+"%' ESCAPE '\\')")
+	}
+	if author := escapeAssetMetaLike(filter.Author); "" != author {
+		conds = append(conds, "author LIKE '%"+author+"%' ESCAPE '\\'")
+	}
+	if title := escapeAssetMetaLike(filter.Title); "" != title {
+		conds = append(conds, "title LIKE '%"+title+"%' ESCAPE '\\'")
+	}
+	if filter.HasGPS {
+		conds = append(conds, "(0 != latitude OR 0 != longitude)")
+	}
+	return " AND path IN (SELECT path FROM asset_metas WHERE " + strings.Join(conds, " AND ") + ")"
+}
+
+// normalizeCapturedPrefix 仅保留时间中的数字，比如 2023-05 规整为 202305。
+func normalizeCapturedPrefix(captured string) string {
+	buf := bytes.Buffer{}
+	for _, r := range captured {
+		if '0' <= r && '9' >= r {
+			buf.WriteRune(r)
+		}
+	}
+	ret := buf.String()
+	if 14 < len(ret) {
+		ret = ret[:14]
+	}
+	return ret
+}
+
+func escapeAssetMetaLike(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "'", "''")
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "%", "\\%")
+	s = strings.ReplaceAll(s, "_", "\\_")
+	return s
+}
+
+func fillAssetContentsMeta(assetContents []*AssetContent) {
+	if 1 > len(assetContents) {
+		return
+	}
+
+	var paths []string
+	for _, assetContent := range assetContents {
+		paths = append(paths, assetContent.Path)
+	}
+
+	metas := sql.GetAssetMetas(paths)
+	for _, assetContent := range assetContents {
+		if m := metas[assetContent.Path]; nil != m {
+			assetContent.Meta = &AssetMeta{
+				Captured:  m.Captured,
+				Latitude:  m.Latitude,
+				Longitude: m.Longitude,
+				Make:      m.Make,
+				Model:     m.Model,
+				Author:    m.Author,
+				Title:     m.Title,
+			}
+		}
+	}
+}
+
+// assetMetaText 将元数据转换为可被全文搜索的文本。
+func assetMetaText(meta *sql.AssetMeta) string {
+	var parts []string
+	if "" != meta.Captured {
+		if t, err := time.Parse("20060102150405", meta.Captured); nil == err {
+			parts = append(parts, t.Format("2006-01-02 15:04:05"))
+		}
+	}
+	for _, s := range []string{meta.Make, meta.Model, meta.Author, meta.Title} {
+		if s = strings.TrimSpace(s); "" != s {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+type ImageAssetParser struct {
+}
+
+func (parser *ImageAssetParser) Parse(absPath string) (ret *AssetParseResult) {
+	f, err := os.Open(absPath)
+	if nil != err {
+		logging.LogErrorf("open [%s] failed: [%s]", absPath, err)
+		return
+	}
+	defer f.Close()
+
+	// EXIF 位于文件头部，只读取前 256KB
+	data := make([]byte, 256*1024)
+	n, _ := f.Read(data)
+	data = data[:n]
+
+	var meta *sql.AssetMeta
+	switch strings.ToLower(filepath.Ext(absPath)) {
+	case ".tif", ".tiff":
+		meta = parseTIFFExif(data)
+	default:
+		meta = parseJPEGExif(data)
+	}
+	if nil == meta {
+		return
+	}
+
+	ret = &AssetParseResult{
+		Content: assetMetaText(meta),
+		Meta:    meta,
+	}
+	return
+}
+
+// parseJPEGExif 解析 JPEG 文件 APP1 段中的 EXIF 信息。
+func parseJPEGExif(data []byte) *sql.AssetMeta {
+	if 4 > len(data) || 0xFF != data[0] || 0xD8 != data[1] {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if 0xFF != data[i] {
+			return nil
+		}
+
+		marker := data[i+1]
+		if 0xD8 <= marker && 0xD9 >= marker || 0xD0 <= marker && 0xD7 >= marker || 0x01 == marker {
+			i += 2
+			continue
+		}
+		if 0xDA == marker { // 图像数据开始
+			return nil
+		}
+
+		segLen := int(binary.BigEndian.Uint16(data[i+2:]))
+		if 2 > segLen || i+2+segLen > len(data) {
+			return nil
+		}
+
+		seg := data[i+4 : i+2+segLen]
+		if 0xE1 == marker && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return parseTIFFExif(seg[6:])
+		}
+		i += 2 + segLen
+	}
+	return nil
+}
+
+type exifEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // 原始的 4 字节值或者偏移
+}
+
+type exifReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseTIFFExif 解析 TIFF 结构的 EXIF 数据，没有可用的元数据时返回 nil。
+func parseTIFFExif(data []byte) (ret *sql.AssetMeta) {
+	if 8 > len(data) {
+		return
+	}
+
+	r := &exifReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return
+	}
+	if 42 != r.order.Uint16(data[2:]) {
+		return
+	}
+
+	ifd0 := r.readIFD(r.order.Uint32(data[4:]))
+	if nil == ifd0 {
+		return
+	}
+
+	ret = &sql.AssetMeta{}
+	ret.Make = r.str(ifd0[0x010F])
+	ret.Model = r.str(ifd0[0x0110])
+	ret.Author = r.str(ifd0[0x013B])
+	ret.Title = r.str(ifd0[0x010E])
+	captured := r.str(ifd0[0x0132])
+	if e := ifd0[0x8769]; nil != e {
+		if exifIFD := r.readIFD(r.order.Uint32(e.value)); nil != exifIFD {
+			if original := r.str(exifIFD[0x9003]); "" != original {
+				captured = original
+			}
+		}
+	}
+	ret.Captured = parseExifTime(captured)
+
+	if e := ifd0[0x8825]; nil != e {
+		if gps := r.readIFD(r.order.Uint32(e.value)); nil != gps {
+			ret.Latitude = r.coordinate(gps[2], r.str(gps[1]), "S")
+			ret.Longitude = r.coordinate(gps[4], r.str(gps[3]), "W")
+		}
+	}
+
+	if "" == ret.Captured && "" == ret.Make && "" == ret.Model && "" == ret.Author && "" == ret.Title && 0 == ret.Latitude && 0 == ret.Longitude {
+		ret = nil
+	}
+	return
+}
+
+func (r *exifReader) readIFD(offset uint32) (ret map[uint16]*exifEntry) {
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return
+	}
+
+	count := int(r.order.Uint16(r.data[offset:]))
+	ret = map[uint16]*exifEntry{}
+	for i := 0; i < count; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(r.data) {
+			break
+		}
+
+		entry := r.data[start : start+12]
+		ret[r.order.Uint16(entry)] = &exifEntry{
+			typ:   r.order.Uint16(entry[2:]),
+			count: r.order.Uint32(entry[4:]),
+			value: entry[8:12],
+		}
+	}
+	return
+}
+
+// bytes 返回条目的值数据，值长度不超过 4 字节时直接存放在条目中。
+func (r *exifReader) bytes(e *exifEntry, size int) []byte {
+	if nil == e {
+		return nil
+	}
+
+	total := uint64(e.count) * uint64(size)
+	if 4 >= total {
+		return e.value[:total]
+	}
+
+	offset := uint64(r.order.Uint32(e.value))
+	if offset+total > uint64(len(r.data)) {
+		return nil
+	}
+	return r.data[offset : offset+total]
+}
+
+func (r *exifReader) str(e *exifEntry) string {
+	if nil == e || 2 != e.typ {
+		return ""
+	}
+
+	b := r.bytes(e, 1)
+	if i := bytes.IndexByte(b, 0); 0 <= i {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// coordinate 将度、分、秒三个有理数转换为十进制坐标，ref 为 negRef 时取负值。
+func (r *exifReader) coordinate(e *exifEntry, ref, negRef string) (ret float64) {
+	if nil == e || 5 != e.typ || 3 > e.count {
+		return
+	}
+
+	b := r.bytes(e, 8)
+	if 24 > len(b) {
+		return
+	}
+
+	for i, div := range []float64{1, 60, 3600} {
+		num, den := r.order.Uint32(b[i*8:]), r.order.Uint32(b[i*8+4:])
+		if 0 == den {
+			continue
+		}
+		ret += float64(num) / float64(den) / div
+	}
+	if negRef == strings.ToUpper(ref) {
+		ret = -ret
+	}
+	return
+}
+
+// parseExifTime 将 EXIF 时间 2006:01:02 15:04:05 转换为 20060102150405。
+func parseExifTime(s string) string {
+	t, err := time.Parse("2006:01:02 15:04:05", s)
+	if nil != err {
+		return ""
+	}
+	return t.Format("20060102150405")
+}
+
+// getPDFMeta 读取 PDF 文档信息字典中的标题、作者和创建时间。
+func getPDFMeta(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT) (ret *sql.AssetMeta) {
+	getMetaText := func(tag string) string {
+		res, err := instance.FPDF_GetMetaText(&requests.FPDF_GetMetaText{Document: doc, Tag: tag})
+		if nil != err {
+			return ""
+		}
+		return strings.TrimSpace(res.Value)
+	}
+
+	ret = &sql.AssetMeta{
+		Title:    getMetaText("Title"),
+		Author:   getMetaText("Author"),
+		Captured: parsePDFTime(getMetaText("CreationDate")),
+	}
+	if "" == ret.Title && "" == ret.Author && "" == ret.Captured {
+		ret = nil
+	}
+	return
+}
+
+// parsePDFTime 将 PDF 时间 D:20230512143000+08'00' 转换为 20230512143000，不完整的时间用 0 补齐。
+func parsePDFTime(s string) string {
+	s = strings.TrimPrefix(s, "D:")
+	digits := bytes.Buffer{}
+	for _, r := range s {
+		if '0' > r || '9' < r {
+			break
+		}
+		digits.WriteRune(r)
+	}
+	if 4 > digits.Len() {
+		return ""
+	}
+
+	ret := digits.String()
+	if 14 < len(ret) {
+		ret = ret[:14]
+	}
+	// 月、日补齐为 01，时分秒补齐为 00
+	padding := "0101000000"
+	ret += padding[len(ret)-4:]
+	if _, err := time.Parse("20060102150405", ret); nil != err {
+		return fmt.Sprintf("%s0101000000", ret[:4])
+	}
+	return ret
+}
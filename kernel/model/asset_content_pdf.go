@@ -0,0 +1,193 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// pdfTextRect 描述了 PDF 页面中的一段文本及其位置，坐标原点在页面左下角。
+type pdfTextRect struct {
+	text                     string
+	left, top, right, bottom float64
+}
+
+func (rect *pdfTextRect) height() float64 {
+	return math.Abs(rect.top - rect.bottom)
+}
+
+// pdfTextLine 描述了 PDF 页面中基线相近的一行文本。
+type pdfTextLine struct {
+	rects       []*pdfTextRect
+	top, bottom float64
+	left, right float64
+}
+
+// layoutPDFPageText 按照阅读顺序拼接 PDF 页面中的文本。
+//
+// 先按照纵坐标将文本段合并为行，再检测双栏排版：跨越页面中线的行作为分隔，分隔之间先输出左栏再输出右栏。
+// 同一行中间距较大的文本段视为表格单元格，使用制表符分隔。
+func layoutPDFPageText(rects []*pdfTextRect) string {
+	if 1 > len(rects) {
+		return ""
+	}
+
+	lines := groupPDFTextLines(rects)
+	minLeft, maxRight := math.MaxFloat64, -math.MaxFloat64
+	for _, line := range lines {
+		minLeft = math.Min(minLeft, line.left)
+		maxRight = math.Max(maxRight, line.right)
+	}
+	center := (minLeft + maxRight) / 2
+
+	// 将每行在中线处拆分为左右两部分，无法拆分的行跨越两栏
+	type columnLine struct {
+		left, right []*pdfTextRect
+	}
+	var columnLines []*columnLine
+	var splitLines int
+	for _, line := range lines {
+		cl := &columnLine{}
+		for i, rect := range line.rects {
+			if rect.right < center && (i == len(line.rects)-1 || line.rects[i+1].left > center) {
+				cl.left, cl.right = line.rects[:i+1], line.rects[i+1:]
+				break
+			}
+			if 0 == i && rect.left > center {
+				cl.right = line.rects
+				break
+			}
+		}
+		// 栏中的文本通常接近栏宽，表格单元格较短，据此区分双栏和表格
+		if pdfTextRectsWidth(cl.left) >= (center-minLeft)*0.6 || pdfTextRectsWidth(cl.right) >= (maxRight-center)*0.6 {
+			splitLines++
+		}
+		columnLines = append(columnLines, cl)
+	}
+
+	buf := strings.Builder{}
+	writeLine := func(rects []*pdfTextRect) {
+		if 1 > len(rects) {
+			return
+		}
+		buf.WriteString(joinPDFTextLine(rects))
+		buf.WriteString("\n")
+	}
+
+	// 超过一半的行可以在中线处拆分时认为是双栏排版
+	if 3 > len(lines) || splitLines*2 <= len(lines) {
+		for _, line := range lines {
+			writeLine(line.rects)
+		}
+		return buf.String()
+	}
+
+	var leftLines, rightLines [][]*pdfTextRect
+	flush := func() {
+		for _, rects := range leftLines {
+			writeLine(rects)
+		}
+		for _, rects := range rightLines {
+			writeLine(rects)
+		}
+		leftLines, rightLines = nil, nil
+	}
+	for i, cl := range columnLines {
+		if nil == cl.left && nil == cl.right {
+			flush()
+			writeLine(lines[i].rects)
+			continue
+		}
+		leftLines = append(leftLines, cl.left)
+		rightLines = append(rightLines, cl.right)
+	}
+	flush()
+	return buf.String()
+}
+
+// groupPDFTextLines 将纵坐标重叠的文本段合并为行，行按照从上到下排序，行内文本段按照从左到右排序。
+func groupPDFTextLines(rects []*pdfTextRect) (ret []*pdfTextLine) {
+	sorted := make([]*pdfTextRect, len(rects))
+	copy(sorted, rects)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].top != sorted[j].top {
+			return sorted[i].top > sorted[j].top
+		}
+		return sorted[i].left < sorted[j].left
+	})
+
+	for _, rect := range sorted {
+		if "" == strings.TrimSpace(rect.text) {
+			continue
+		}
+
+		var line *pdfTextLine
+		if 0 < len(ret) {
+			last := ret[len(ret)-1]
+			// 纵向重叠超过较小高度的一半视为同一行
+			overlap := math.Min(last.top, rect.top) - math.Max(last.bottom, rect.bottom)
+			if overlap > math.Min(last.top-last.bottom, rect.height())/2 {
+				line = last
+			}
+		}
+		if nil == line {
+			line = &pdfTextLine{top: rect.top, bottom: rect.bottom, left: rect.left, right: rect.right}
+			ret = append(ret, line)
+		}
+
+		line.rects = append(line.rects, rect)
+		line.top = math.Max(line.top, rect.top)
+		line.bottom = math.Min(line.bottom, rect.bottom)
+		line.left = math.Min(line.left, rect.left)
+		line.right = math.Max(line.right, rect.right)
+	}
+
+	for _, line := range ret {
+		sort.SliceStable(line.rects, func(i, j int) bool { return line.rects[i].left < line.rects[j].left })
+	}
+	return
+}
+
+func pdfTextRectsWidth(rects []*pdfTextRect) float64 {
+	if 1 > len(rects) {
+		return 0
+	}
+	return rects[len(rects)-1].right - rects[0].left
+}
+
+// joinPDFTextLine 拼接一行中的文本段，间距超过两倍行高时视为表格单元格。
+func joinPDFTextLine(rects []*pdfTextRect) string {
+	buf := strings.Builder{}
+	var lineHeight float64
+	for _, rect := range rects {
+		lineHeight = math.Max(lineHeight, rect.height())
+	}
+	for i, rect := range rects {
+		if 0 < i {
+			gap := rect.left - rects[i-1].right
+			if gap > lineHeight*2 {
+				buf.WriteString("\t")
+			} else if 0 < gap {
+				buf.WriteString(" ")
+			}
+		}
+		buf.WriteString(strings.TrimSpace(rect.text))
+	}
+	return buf.String()
+}
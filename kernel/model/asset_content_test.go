@@ -17,6 +17,7 @@
 package model
 
 import (
+	"encoding/binary"
 	"testing"
 )
 
@@ -27,3 +28,111 @@ func TestPDFParser(t *testing.T) {
 		t.Fatalf("empty or nil PDF content result")
 	}
 }
+
+func TestLayoutPDFPageText(t *testing.T) {
+	// 标题跨栏，下方左右两栏各两行
+	rects := []*pdfTextRect{
+		{text: "Title", left: 10, top: 100, right: 190, bottom: 90},
+		{text: "L1", left: 10, top: 80, right: 90, bottom: 70},
+		{text: "R1", left: 110, top: 80, right: 190, bottom: 70},
+		{text: "L2", left: 10, top: 60, right: 90, bottom: 50},
+		{text: "R2", left: 110, top: 60, right: 190, bottom: 50},
+	}
+	if expected, got := "Title\nL1\nL2\nR1\nR2\n", layoutPDFPageText(rects); expected != got {
+		t.Fatalf("expected [%q], got [%q]", expected, got)
+	}
+
+	// 单栏表格
+	rects = []*pdfTextRect{
+		{text: "Name", left: 10, top: 100, right: 40, bottom: 90},
+		{text: "Age", left: 150, top: 100, right: 170, bottom: 90},
+		{text: "Foo", left: 10, top: 80, right: 30, bottom: 70},
+		{text: "bar", left: 32, top: 80, right: 50, bottom: 70},
+		{text: "18", left: 150, top: 80, right: 165, bottom: 70},
+		{text: "Baz", left: 10, top: 60, right: 30, bottom: 50},
+		{text: "20", left: 150, top: 60, right: 165, bottom: 50},
+	}
+	if expected, got := "Name\tAge\nFoo bar\t18\nBaz\t20\n", layoutPDFPageText(rects); expected != got {
+		t.Fatalf("expected [%q], got [%q]", expected, got)
+	}
+}
+
+func TestParseJPEGExif(t *testing.T) {
+	// 构造大端序 TIFF：IFD0（Make、EXIF 指针、GPS 指针）、EXIF IFD（DateTimeOriginal）、GPS IFD（北纬、西经）
+	order := binary.BigEndian
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	entry := func(tag, typ uint16, count, value uint32) []byte {
+		b := make([]byte, 12)
+		order.PutUint16(b, tag)
+		order.PutUint16(b[2:], typ)
+		order.PutUint32(b[4:], count)
+		order.PutUint32(b[8:], value)
+		return b
+	}
+	ifd := func(entries ...[]byte) []byte {
+		b := make([]byte, 2)
+		order.PutUint16(b, uint16(len(entries)))
+		for _, e := range entries {
+			b = append(b, e...)
+		}
+		return append(b, 0, 0, 0, 0)
+	}
+	rational := func(nums ...uint32) []byte {
+		b := make([]byte, 0, len(nums)*8)
+		for _, n := range nums {
+			b = order.AppendUint32(b, n)
+			b = order.AppendUint32(b, 1)
+		}
+		return b
+	}
+
+	ifd0Len := 2 + 3*12 + 4
+	exifOffset := uint32(8 + ifd0Len)
+	exifLen := 2 + 1*12 + 4
+	gpsOffset := exifOffset + uint32(exifLen)
+	gpsLen := 2 + 4*12 + 4
+	dataOffset := gpsOffset + uint32(gpsLen)
+	makeStr := []byte("Canon\x00")
+	dateStr := []byte("2023:05:12 14:30:00\x00")
+	latOffset := dataOffset + uint32(len(makeStr)+len(dateStr))
+	lngOffset := latOffset + 24
+
+	tiff = append(tiff, ifd(entry(0x010F, 2, uint32(len(makeStr)), dataOffset), entry(0x8769, 4, 1, exifOffset), entry(0x8825, 4, 1, gpsOffset))...)
+	tiff = append(tiff, ifd(entry(0x9003, 2, uint32(len(dateStr)), dataOffset+uint32(len(makeStr))))...)
+	tiff = append(tiff, ifd(entry(1, 2, 2, 'N'<<24), entry(2, 5, 3, latOffset), entry(3, 2, 2, 'W'<<24), entry(4, 5, 3, lngOffset))...)
+	tiff = append(tiff, makeStr...)
+	tiff = append(tiff, dateStr...)
+	tiff = append(tiff, rational(30, 15, 0)...)
+	tiff = append(tiff, rational(120, 30, 0)...)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	jpeg = order.AppendUint16(jpeg, uint16(len(app1)+2))
+	jpeg = append(jpeg, app1...)
+	jpeg = append(jpeg, 0xFF, 0xDA)
+
+	meta := parseJPEGExif(jpeg)
+	if nil == meta {
+		t.Fatalf("parse exif failed")
+	}
+	if "Canon" != meta.Make || "20230512143000" != meta.Captured {
+		t.Fatalf("unexpected exif [make=%s, captured=%s]", meta.Make, meta.Captured)
+	}
+	if 30.25 != meta.Latitude || -120.5 != meta.Longitude {
+		t.Fatalf("unexpected gps [%f, %f]", meta.Latitude, meta.Longitude)
+	}
+}
+
+func TestParsePDFTime(t *testing.T) {
+	cases := map[string]string{
+		"D:20230512143000+08'00'": "20230512143000",
+		"D:202305":                "20230501000000",
+		"D:2023":                  "20230101000000",
+		"":                        "",
+	}
+	for s, expected := range cases {
+		if got := parsePDFTime(s); expected != got {
+			t.Fatalf("parse PDF time [%s] expected [%s], got [%s]", s, expected, got)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/webassembly"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// AssetThumbSizes 为支持的缩略图最长边尺寸，请求的尺寸会被向上取到最近的一档，以便复用缓存。
+var AssetThumbSizes = []int{160, 320, 640, 1280}
+
+const assetThumbDefaultSize = 320
+
+var (
+	ErrAssetThumbUnsupported = errors.New("unsupported asset thumbnail")
+
+	assetThumbLock = sync.Mutex{}
+)
+
+// ThumbSize 将请求的缩略图尺寸规整为 AssetThumbSizes 中的一档。
+func ThumbSize(size string) int {
+	s, err := strconv.Atoi(size)
+	if nil != err || 1 > s {
+		return assetThumbDefaultSize
+	}
+
+	for _, thumbSize := range AssetThumbSizes {
+		if s <= thumbSize {
+			return thumbSize
+		}
+	}
+	return AssetThumbSizes[len(AssetThumbSizes)-1]
+}
+
+// GetAssetThumb 返回资源文件缩略图的绝对路径，缩略图不存在或者已经过期时会重新生成。
+//
+// relPath 为 assets/ 开头的资源文件路径，缩略图统一编码为 JPEG 并缓存在 temp/thumb/ 下。
+func GetAssetThumb(relPath string, size int) (ret string, err error) {
+	if !util.IsThumbnailableAsset(relPath) {
+		err = ErrAssetThumbUnsupported
+		return
+	}
+
+	absPath, err := GetAssetAbsPath(relPath)
+	if nil != err {
+		return
+	}
+
+	info, err := os.Stat(absPath)
+	if nil != err {
+		return
+	}
+
+	thumbDir := filepath.Join(util.TempDir, "thumb", strconv.Itoa(size))
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(relPath)))[:16]
+	ret = filepath.Join(thumbDir, fmt.Sprintf("%s-%d.jpg", key, info.ModTime().UnixMilli()))
+	if gulu.File.IsExist(ret) {
+		return
+	}
+
+	assetThumbLock.Lock()
+	defer assetThumbLock.Unlock()
+	if gulu.File.IsExist(ret) {
+		return
+	}
+
+	var img image.Image
+	ext := strings.ToLower(filepath.Ext(absPath))
+	switch {
+	case ".pdf" == ext:
+		img, err = renderPDFThumb(absPath, size)
+	case gulu.Str.Contains(ext, util.SiYuanAssetsVideo):
+		img, err = renderVideoThumb(absPath, size)
+	default:
+		img, err = decodeImageThumb(absPath)
+	}
+	if nil != err {
+		logging.LogWarnf("generate thumbnail for asset [%s] failed: %s", relPath, err)
+		return
+	}
+
+	data, err := encodeThumb(img, size)
+	if nil != err {
+		logging.LogErrorf("encode thumbnail for asset [%s] failed: %s", relPath, err)
+		return
+	}
+
+	if err = os.MkdirAll(thumbDir, 0755); nil != err {
+		logging.LogErrorf("create thumbnail dir [%s] failed: %s", thumbDir, err)
+		return
+	}
+
+	// 移除该资源文件修改前生成的缩略图
+	if stales, _ := filepath.Glob(filepath.Join(thumbDir, key+"-*.jpg")); 0 < len(stales) {
+		for _, stale := range stales {
+			os.Remove(stale)
+		}
+	}
+
+	if err = gulu.File.WriteFileSafer(ret, data, 0644); nil != err {
+		logging.LogErrorf("write thumbnail [%s] failed: %s", ret, err)
+	}
+	return
+}
+
+func decodeImageThumb(absPath string) (ret image.Image, err error) {
+	f, err := os.Open(absPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+
+	ret, _, err = image.Decode(f)
+	return
+}
+
+func renderPDFThumb(absPath string, size int) (ret image.Image, err error) {
+	pdfData, err := os.ReadFile(absPath)
+	if nil != err {
+		return
+	}
+
+	pool, err := webassembly.Init(webassembly.Config{MinIdle: 1, MaxIdle: 1, MaxTotal: 1})
+	if nil != err {
+		return
+	}
+	defer pool.Close()
+
+	instance, err := pool.GetInstance(time.Second * 30)
+	if nil != err {
+		return
+	}
+	defer instance.Close()
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{File: &pdfData})
+	if nil != err {
+		return
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
+
+	res, err := instance.RenderPageInPixels(&requests.RenderPageInPixels{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc.Document,
+				Index:    0,
+			},
+		},
+		Width: size,
+	})
+	if nil != err {
+		return
+	}
+	ret = res.Result.Image
+	return
+}
+
+func renderVideoThumb(absPath string, size int) (ret image.Image, err error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if nil != err {
+		err = ErrAssetThumbUnsupported
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpeg, "-ss", "1", "-i", absPath, "-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", size), "-f", "image2pipe", "-vcodec", "mjpeg", "-")
+	gulu.CmdAttr(cmd)
+	output, err := cmd.Output()
+	if nil != err {
+		return
+	}
+
+	ret, _, err = image.Decode(bytes.NewReader(output))
+	return
+}
+
+// encodeThumb 将图片按最长边等比缩放到 size 以内并编码为 JPEG，透明背景填充为白色。
+func encodeThumb(img image.Image, size int) (ret []byte, err error) {
+	if 1 > img.Bounds().Dx() || 1 > img.Bounds().Dy() {
+		err = ErrAssetThumbUnsupported
+		return
+	}
+
+	dst := scaleImage(img, size, color.White)
+	buf := bytes.Buffer{}
+	if err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); nil != err {
+		return
+	}
+	ret = buf.Bytes()
+	return
+}
+
+// scaleImage 将图片按最长边等比缩放到 maxSize 以内，maxSize 小于 1 时不缩放；background 不为空时先填充背景色，用于编码为不支持透明的格式。
+func scaleImage(img image.Image, maxSize int, background color.Color) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if 0 < maxSize && (width > maxSize || height > maxSize) {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	op := draw.Src
+	if nil != background {
+		draw.Draw(dst, dst.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, op, nil)
+	return dst
+}
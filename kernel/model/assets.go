@@ -531,6 +531,12 @@ func GetAssetAbsPath(relativePath string) (ret string, err error) {
 		}
 		return
 	}
+
+	// 在转存到对象存储的资源文件中搜索
+	if p, fetchErr := getOffloadedAssetAbsPath(relativePath); nil == fetchErr {
+		ret = p
+		return
+	}
 	return "", errors.New(fmt.Sprintf(Conf.Language(12), relativePath))
 }
 
@@ -678,6 +684,12 @@ func RemoveUnusedAssets() (ret []string) {
 	ret = []string{}
 	unusedAssets := UnusedAssets()
 
+	if 0 < Conf.Asset.QuarantineDays {
+		ret = quarantineAssets(unusedAssets)
+		cache.LoadAssets()
+		return
+	}
+
 	historyDir, err := GetHistoryDir(HistoryOpClean)
 	if nil != err {
 		logging.LogErrorf("get history dir failed: %s", err)
@@ -722,6 +734,13 @@ func RemoveUnusedAsset(p string) (ret string) {
 		return absPath
 	}
 
+	if 0 < Conf.Asset.QuarantineDays {
+		quarantineAssets([]string{p})
+		ret = absPath
+		cache.RemoveAsset(p)
+		return
+	}
+
 	historyDir, err := GetHistoryDir(HistoryOpClean)
 	if nil != err {
 		logging.LogErrorf("get history dir failed: %s", err)
@@ -796,7 +815,7 @@ func RenameAsset(oldPath, newName string) (err error) {
 
 		for _, paths := range pages {
 			for _, treeAbsPath := range paths {
-				data, readErr := filelock.ReadFile(treeAbsPath)
+				data, readErr := filesys.ReadSyFile(treeAbsPath)
 				if nil != readErr {
 					logging.LogErrorf("get data [path=%s] failed: %s", treeAbsPath, readErr)
 					err = readErr
@@ -808,7 +827,7 @@ func RenameAsset(oldPath, newName string) (err error) {
 				}
 
 				data = bytes.Replace(data, []byte(oldName), []byte(newName), -1)
-				if writeErr := filelock.WriteFile(treeAbsPath, data); nil != writeErr {
+				if writeErr := filesys.WriteSyFile(treeAbsPath, data); nil != writeErr {
 					logging.LogErrorf("write data [path=%s] failed: %s", treeAbsPath, writeErr)
 					err = writeErr
 					return
@@ -1048,6 +1067,10 @@ func MissingAssets() (ret []string) {
 			}
 
 			if "" == assetsPathMap[dest] {
+				if IsOffloadedAsset(dest) {
+					continue
+				}
+
 				if strings.HasPrefix(dest, "assets/.") {
 					// Assets starting with `.` should not be considered missing assets https://github.com/siyuan-note/siyuan/issues/8821
 					if !filelock.IsExist(filepath.Join(util.DataDir, dest)) {
@@ -0,0 +1,280 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	_ "golang.org/x/image/tiff"
+)
+
+// AssetConversion 记录了上传时被转换格式或者缩小的图片，用于追溯原始文件。
+type AssetConversion struct {
+	Path         string `json:"path"`         // 转换后的资源文件路径
+	OriginalName string `json:"originalName"` // 原始文件名
+	OriginalHash string `json:"originalHash"` // 原始文件 Etag
+	OriginalSize int64  `json:"originalSize"` // 原始文件大小
+	Width        int    `json:"width"`        // 转换后的宽度
+	Height       int    `json:"height"`       // 转换后的高度
+	Converted    int64  `json:"converted"`    // 转换时间
+}
+
+var assetConversionsLock = sync.Mutex{}
+
+func getAssetConversionsPath() string {
+	return filepath.Join(util.DataDir, "storage", "asset_conversions.json")
+}
+
+func loadAssetConversions() (ret []*AssetConversion) {
+	ret = []*AssetConversion{}
+	p := getAssetConversionsPath()
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read asset conversions [%s] failed: %s", p, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal asset conversions [%s] failed: %s", p, err)
+	}
+	return
+}
+
+// GetAssetConversions 返回所有上传时转换过的图片记录。
+func GetAssetConversions() []*AssetConversion {
+	assetConversionsLock.Lock()
+	defer assetConversionsLock.Unlock()
+	return loadAssetConversions()
+}
+
+func recordAssetConversion(conversion *AssetConversion) {
+	assetConversionsLock.Lock()
+	defer assetConversionsLock.Unlock()
+
+	conversions := loadAssetConversions()
+	conversions = append(conversions, conversion)
+	data, err := gulu.JSON.MarshalIndentJSON(conversions, "", "\t")
+	if nil != err {
+		return
+	}
+
+	p := getAssetConversionsPath()
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		return
+	}
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write asset conversions [%s] failed: %s", p, err)
+	}
+}
+
+// getConvertedAssetByHash 查找由相同原始文件转换得到的资源文件，避免重复转换。
+func getConvertedAssetByHash(hash string) string {
+	assetConversionsLock.Lock()
+	defer assetConversionsLock.Unlock()
+
+	for _, conversion := range loadAssetConversions() {
+		if hash == conversion.OriginalHash && strings.HasPrefix(conversion.Path, "assets/") && filelock.IsExist(filepath.Join(util.DataDir, conversion.Path)) {
+			return conversion.Path
+		}
+	}
+	return ""
+}
+
+// convertUploadedImage 按配置转换上传图片的格式或者缩小尺寸，返回转换后文件的绝对路径，未转换时返回空字符串。
+//
+// 需要转换格式的图片编码为配置的目标格式；JPEG 和 PNG 图片仅在超出最长边上限时缩小，保持原格式。
+func convertUploadedImage(absPath, originalName, hash string) (ret string) {
+	convertConf := Conf.Asset.ImageConvert
+	if nil == convertConf || !convertConf.Enabled {
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(absPath))
+	needConvert := gulu.Str.Contains(ext, convertConf.Exts)
+	needScale := 0 < convertConf.MaxDimension && gulu.Str.Contains(ext, []string{".jpg", ".jpeg", ".png"})
+	if !needConvert && !needScale {
+		return
+	}
+
+	img, err := decodeUploadedImage(absPath, ext)
+	if nil != err {
+		logging.LogWarnf("decode uploaded image [%s] failed: %s", absPath, err)
+		return
+	}
+
+	bounds := img.Bounds()
+	if !needConvert && bounds.Dx() <= convertConf.MaxDimension && bounds.Dy() <= convertConf.MaxDimension {
+		return
+	}
+
+	targetExt := ext
+	if needConvert {
+		targetExt = ".jpg"
+		if "webp" == convertConf.Format {
+			targetExt = ".webp"
+		}
+	}
+
+	var background color.Color
+	if ".png" != targetExt && ".webp" != targetExt {
+		background = color.White // JPEG 不支持透明
+	}
+	dst := scaleImage(img, convertConf.MaxDimension, background)
+
+	data, err := encodeConvertedImage(dst, targetExt, convertConf.Quality)
+	if nil != err && ".webp" == targetExt {
+		logging.LogWarnf("encode image to webp failed, fallback to jpeg: %s", err)
+		targetExt = ".jpg"
+		data, err = encodeConvertedImage(scaleImage(img, convertConf.MaxDimension, color.White), targetExt, convertConf.Quality)
+	}
+	if nil != err {
+		logging.LogErrorf("encode converted image [%s] failed: %s", absPath, err)
+		return
+	}
+
+	ret = strings.TrimSuffix(absPath, filepath.Ext(absPath)) + targetExt
+	if ret != absPath && filelock.IsExist(ret) {
+		ret = filepath.Join(filepath.Dir(absPath), util.AssetName(strings.TrimSuffix(filepath.Base(absPath), filepath.Ext(absPath))+targetExt))
+	}
+	if err = filelock.WriteFile(ret, data); nil != err {
+		logging.LogErrorf("write converted image [%s] failed: %s", ret, err)
+		return ""
+	}
+
+	info, _ := os.Stat(absPath)
+	if ret != absPath {
+		if err = filelock.Remove(absPath); nil != err {
+			logging.LogWarnf("remove original image [%s] failed: %s", absPath, err)
+		}
+	}
+
+	conversion := &AssetConversion{
+		Path:         "assets/" + filepath.Base(ret),
+		OriginalName: originalName,
+		OriginalHash: hash,
+		Width:        dst.Bounds().Dx(),
+		Height:       dst.Bounds().Dy(),
+		Converted:    time.Now().UnixMilli(),
+	}
+	if rel, relErr := filepath.Rel(util.DataDir, ret); nil == relErr {
+		conversion.Path = filepath.ToSlash(rel)
+	}
+	if nil != info {
+		conversion.OriginalSize = info.Size()
+	}
+	recordAssetConversion(conversion)
+	logging.LogInfof("converted uploaded image [%s] to [%s]", originalName, conversion.Path)
+	return
+}
+
+func decodeUploadedImage(absPath, ext string) (ret image.Image, err error) {
+	if ".heic" != ext && ".heif" != ext {
+		return decodeImageThumb(absPath)
+	}
+
+	// Go 标准库不支持 HEIC，通过 libheif 或者 ImageMagick 转换为 PNG 后解码
+	tmp := filepath.Join(util.TempDir, "convert", "heic", gulu.Rand.String(7)+".png")
+	if err = os.MkdirAll(filepath.Dir(tmp), 0755); nil != err {
+		return
+	}
+	defer os.Remove(tmp)
+
+	var cmd *exec.Cmd
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if bin, lookErr := exec.LookPath("heif-convert"); nil == lookErr {
+		cmd = exec.CommandContext(ctx, bin, absPath, tmp)
+	} else if bin, lookErr = exec.LookPath("magick"); nil == lookErr {
+		cmd = exec.CommandContext(ctx, bin, absPath, tmp)
+	} else {
+		err = errors.New("neither heif-convert nor magick is found")
+		return
+	}
+	gulu.CmdAttr(cmd)
+	if output, cmdErr := cmd.CombinedOutput(); nil != cmdErr {
+		err = errors.New(cmdErr.Error() + ": " + string(output))
+		return
+	}
+	return decodeImageThumb(tmp)
+}
+
+func encodeConvertedImage(img image.Image, ext string, quality int) (ret []byte, err error) {
+	buf := bytes.Buffer{}
+	switch ext {
+	case ".png":
+		err = png.Encode(&buf, img)
+	case ".webp":
+		return encodeWebP(img, quality)
+	default:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	ret = buf.Bytes()
+	return
+}
+
+// encodeWebP 通过 cwebp 编码 WebP，Go 扩展库仅支持解码 WebP。
+func encodeWebP(img image.Image, quality int) (ret []byte, err error) {
+	bin, err := exec.LookPath("cwebp")
+	if nil != err {
+		return
+	}
+
+	dir := filepath.Join(util.TempDir, "convert", "webp", gulu.Rand.String(7))
+	if err = os.MkdirAll(dir, 0755); nil != err {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "src.png"), filepath.Join(dir, "dst.webp")
+	buf := bytes.Buffer{}
+	if err = png.Encode(&buf, img); nil != err {
+		return
+	}
+	if err = os.WriteFile(src, buf.Bytes(), 0644); nil != err {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, "-quiet", "-q", strconv.Itoa(quality), src, "-o", dst)
+	gulu.CmdAttr(cmd)
+	if output, cmdErr := cmd.CombinedOutput(); nil != cmdErr {
+		err = errors.New(cmdErr.Error() + ": " + string(output))
+		return
+	}
+	return os.ReadFile(dst)
+}
@@ -0,0 +1,263 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const quarantineBatchLayout = "2006-01-02-150405"
+
+type UnusedAsset struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	HSize string `json:"hSize"`
+}
+
+type UnusedAssetsImpact struct {
+	Count          int            `json:"count"`
+	Size           int64          `json:"size"`
+	HSize          string         `json:"hSize"`
+	QuarantineDays int            `json:"quarantineDays"` // 清理后在隔离区保留的天数，0 表示不使用隔离区
+	Assets         []*UnusedAsset `json:"assets"`
+}
+
+// GetUnusedAssetsImpact 预演清理未引用资源文件，返回将被清理的资源文件及其占用空间，不做任何修改。
+func GetUnusedAssetsImpact() (ret *UnusedAssetsImpact) {
+	ret = &UnusedAssetsImpact{QuarantineDays: Conf.Asset.QuarantineDays, Assets: []*UnusedAsset{}}
+	for _, p := range UnusedAssets() {
+		info, err := os.Stat(filepath.Join(util.DataDir, p))
+		if nil != err {
+			continue
+		}
+
+		size := info.Size()
+		ret.Assets = append(ret.Assets, &UnusedAsset{Path: p, Size: size, HSize: humanize.BytesCustomCeil(uint64(size), 2)})
+		ret.Size += size
+	}
+	ret.Count = len(ret.Assets)
+	ret.HSize = humanize.BytesCustomCeil(uint64(ret.Size), 2)
+	return
+}
+
+type QuarantinedAsset struct {
+	Batch   string `json:"batch"`   // 隔离批次，即清理时间
+	Path    string `json:"path"`    // 相对于 data 目录的原始路径
+	Size    int64  `json:"size"`    // 文件大小
+	HSize   string `json:"hSize"`   // 格式化后的文件大小
+	Expired int64  `json:"expired"` // 过期时间，过期后将被彻底删除
+}
+
+// ListQuarantinedAssets 列出隔离区中的资源文件。
+func ListQuarantinedAssets() (ret []*QuarantinedAsset) {
+	ret = []*QuarantinedAsset{}
+	batches, err := os.ReadDir(util.QuarantineDir)
+	if nil != err {
+		return
+	}
+
+	for _, batch := range batches {
+		if !batch.IsDir() {
+			continue
+		}
+
+		created, parseErr := time.ParseInLocation(quarantineBatchLayout, batch.Name(), time.Local)
+		if nil != parseErr {
+			continue
+		}
+
+		expired := created.AddDate(0, 0, Conf.Asset.QuarantineDays).UnixMilli()
+		batchDir := filepath.Join(util.QuarantineDir, batch.Name())
+		filepath.Walk(batchDir, func(path string, info os.FileInfo, walkErr error) error {
+			if nil != walkErr || info.IsDir() {
+				return nil
+			}
+
+			p, relErr := filepath.Rel(batchDir, path)
+			if nil != relErr {
+				return nil
+			}
+
+			ret = append(ret, &QuarantinedAsset{
+				Batch:   batch.Name(),
+				Path:    filepath.ToSlash(p),
+				Size:    info.Size(),
+				HSize:   humanize.BytesCustomCeil(uint64(info.Size()), 2),
+				Expired: expired,
+			})
+			return nil
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Batch == ret[j].Batch {
+			return ret[i].Path < ret[j].Path
+		}
+		return ret[i].Batch > ret[j].Batch
+	})
+	return
+}
+
+// RestoreQuarantinedAsset 将隔离区中的资源文件恢复到原始路径。
+func RestoreQuarantinedAsset(batch, p string) (err error) {
+	if !gulu.File.IsValidFilename(batch) || !strings.HasPrefix(p, "assets/") || strings.Contains(p, "..") {
+		err = errors.New("invalid quarantined asset path")
+		return
+	}
+
+	quarantinePath := filepath.Join(util.QuarantineDir, batch, p)
+	if !gulu.File.IsExist(quarantinePath) {
+		err = errors.New("quarantined asset not found")
+		return
+	}
+
+	absPath := filepath.Join(util.DataDir, p)
+	if filelock.IsExist(absPath) {
+		absPath = filepath.Join(filepath.Dir(absPath), util.AssetName(filepath.Base(absPath)))
+	}
+	if err = filelock.Copy(quarantinePath, absPath); nil != err {
+		logging.LogErrorf("restore quarantined asset [%s] failed: %s", quarantinePath, err)
+		return
+	}
+
+	if err = os.Remove(quarantinePath); nil != err {
+		logging.LogWarnf("remove quarantined asset [%s] failed: %s", quarantinePath, err)
+		err = nil
+	}
+	removeEmptyQuarantineDirs(filepath.Join(util.QuarantineDir, batch))
+
+	IncSync()
+	cache.LoadAssets()
+	return
+}
+
+// PurgeQuarantinedAssets 彻底删除隔离区中过期的资源文件，force 为 true 时删除所有隔离的资源文件。
+func PurgeQuarantinedAssets(force bool) {
+	batches, err := os.ReadDir(util.QuarantineDir)
+	if nil != err {
+		return
+	}
+
+	now := time.Now()
+	for _, batch := range batches {
+		if !batch.IsDir() {
+			continue
+		}
+
+		if !force {
+			created, parseErr := time.ParseInLocation(quarantineBatchLayout, batch.Name(), time.Local)
+			if nil != parseErr || now.Before(created.AddDate(0, 0, Conf.Asset.QuarantineDays)) {
+				continue
+			}
+		}
+
+		batchDir := filepath.Join(util.QuarantineDir, batch.Name())
+		if err = os.RemoveAll(batchDir); nil != err {
+			logging.LogErrorf("purge quarantined assets [%s] failed: %s", batchDir, err)
+			continue
+		}
+		logging.LogInfof("purged quarantined assets [%s]", batchDir)
+	}
+}
+
+// quarantineAssets 将资源文件移动到隔离区，assets 为相对于 data 目录的路径。
+func quarantineAssets(assets []string) (ret []string) {
+	ret = []string{}
+	batchDir := filepath.Join(util.QuarantineDir, time.Now().Format(quarantineBatchLayout))
+	var hashes []string
+	for _, p := range assets {
+		absPath := filepath.Join(util.DataDir, p)
+		if !filelock.IsExist(absPath) {
+			ret = append(ret, absPath)
+			continue
+		}
+
+		hash, _ := util.GetEtag(absPath)
+		if err := filelock.Copy(absPath, filepath.Join(batchDir, p)); nil != err {
+			logging.LogErrorf("quarantine unused asset [%s] failed: %s", absPath, err)
+			continue
+		}
+		if err := filelock.Remove(absPath); nil != err {
+			logging.LogErrorf("remove unused asset [%s] failed: %s", absPath, err)
+			continue
+		}
+
+		hashes = append(hashes, hash)
+		ret = append(ret, absPath)
+	}
+
+	sql.BatchRemoveAssetsQueue(hashes)
+	if 0 < len(hashes) {
+		IncSync()
+	}
+	return
+}
+
+func removeEmptyQuarantineDirs(batchDir string) {
+	var dirs []string
+	filepath.Walk(batchDir, func(path string, info os.FileInfo, err error) error {
+		if nil == err && info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+
+	// 从最深的目录开始删除
+	for i := len(dirs) - 1; 0 <= i; i-- {
+		if entries, err := os.ReadDir(dirs[i]); nil == err && 1 > len(entries) {
+			os.Remove(dirs[i])
+		}
+	}
+}
+
+// AutoCleanUnusedAssetsJob 按配置的间隔自动清理未引用资源文件，并彻底删除隔离区中过期的资源文件。
+func AutoCleanUnusedAssetsJob() {
+	if !util.IsBooted() || util.IsExiting.Load() || util.ReadOnly {
+		return
+	}
+
+	PurgeQuarantinedAssets(false)
+
+	days := Conf.Asset.AutoCleanUnusedDays
+	if 1 > days {
+		return
+	}
+
+	now := time.Now()
+	if now.Before(time.UnixMilli(Conf.Asset.LastAutoCleanUnused).AddDate(0, 0, days)) {
+		return
+	}
+
+	Conf.Asset.LastAutoCleanUnused = now.UnixMilli()
+	Conf.Save()
+
+	removed := RemoveUnusedAssets()
+	logging.LogInfof("auto cleaned [%d] unused assets", len(removed))
+}
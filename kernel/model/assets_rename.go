@@ -0,0 +1,301 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type RenameAssetResult struct {
+	NewPath        string   `json:"newPath"`        // 重命名后的资源文件路径
+	RootIDs        []string `json:"rootIDs"`        // 改写了引用的文档 ID
+	AttributeViews []string `json:"attributeViews"` // 改写了资源列的属性视图 ID
+}
+
+// RenameAssetWithRefs 重命名资源文件并改写所有引用，包括图片、链接、音视频、块属性以及属性视图资源列。
+//
+// 所有改写在同一个事务中完成：改写前为涉及的文档生成历史，任一步骤失败时恢复已经写入的文档和属性视图并删除新文件。
+func RenameAssetWithRefs(oldPath, newName string) (ret *RenameAssetResult, err error) {
+	newName = strings.TrimSpace(newName)
+	newName = gulu.Str.RemoveInvisible(newName)
+	if "" == newName || path.Base(oldPath) == newName {
+		err = errors.New(Conf.Language(151))
+		return
+	}
+	if !gulu.File.IsValidFilename(newName) {
+		err = errors.New(Conf.Language(151))
+		return
+	}
+	if !strings.HasPrefix(oldPath, "assets/") || strings.Contains(oldPath, "..") {
+		err = fmt.Errorf("invalid asset path [%s]", oldPath)
+		return
+	}
+
+	oldAbsPath := filepath.Join(util.DataDir, oldPath)
+	if !filelock.IsExist(oldAbsPath) {
+		err = errors.New(fmt.Sprintf(Conf.Language(12), oldPath))
+		return
+	}
+
+	util.PushEndlessProgress(Conf.Language(110))
+	defer util.PushClearProgress()
+
+	WaitForWritingFiles()
+	flushLock.Lock()
+	defer flushLock.Unlock()
+
+	newName = util.AssetName(newName + filepath.Ext(oldPath))
+	newPath := path.Join(path.Dir(oldPath), newName)
+	newAbsPath := filepath.Join(util.DataDir, newPath)
+	ret = &RenameAssetResult{NewPath: newPath, RootIDs: []string{}, AttributeViews: []string{}}
+
+	renamedFiles := map[string]string{oldAbsPath: newAbsPath}
+	if filelock.IsExist(oldAbsPath + ".sya") {
+		renamedFiles[oldAbsPath+".sya"] = newAbsPath + ".sya"
+	}
+	for from, to := range renamedFiles {
+		if err = filelock.Copy(from, to); nil != err {
+			logging.LogErrorf("copy asset [%s] failed: %s", from, err)
+			removeRenamedAssetFiles(renamedFiles)
+			return
+		}
+	}
+
+	// 改写前的原始数据，用于失败时回滚
+	backups := map[string][]byte{}
+	rollback := func() {
+		for absPath, data := range backups {
+			if writeErr := filelock.WriteFile(absPath, data); nil != writeErr {
+				logging.LogErrorf("rollback [%s] failed: %s", absPath, writeErr)
+			}
+		}
+		removeRenamedAssetFiles(renamedFiles)
+		for _, rootID := range ret.RootIDs {
+			if tree, loadErr := LoadTreeByBlockID(rootID); nil == loadErr {
+				treenode.IndexBlockTree(tree)
+				sql.UpsertTreeQueue(tree)
+			}
+		}
+	}
+
+	trees, err := loadAssetRefTrees(oldPath)
+	if nil != err {
+		removeRenamedAssetFiles(renamedFiles)
+		return
+	}
+	for _, tree := range trees {
+		if !rewriteAssetRefs(tree, oldPath, newPath) {
+			continue
+		}
+
+		absPath := filepath.Join(util.DataDir, tree.Box, tree.Path)
+		data, readErr := filelock.ReadFile(absPath)
+		if nil != readErr {
+			err = readErr
+			rollback()
+			return
+		}
+		backups[absPath] = data
+
+		generateOpTypeHistory(tree, HistoryOpUpdate)
+		if err = indexWriteTreeUpsertQueue(tree); nil != err {
+			logging.LogErrorf("rewrite asset refs in tree [%s] failed: %s", tree.ID, err)
+			rollback()
+			return
+		}
+		ret.RootIDs = append(ret.RootIDs, tree.ID)
+		util.PushEndlessProgress(fmt.Sprintf(Conf.Language(111), util.EscapeHTML(tree.Root.IALAttr("title"))))
+	}
+
+	avDir := filepath.Join(util.DataDir, "storage", "av")
+	entries, _ := os.ReadDir(avDir)
+	for _, entry := range entries {
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+
+		avPath := av.GetAttributeViewDataPath(avID)
+		data, readErr := filelock.ReadFile(avPath)
+		if nil != readErr || !bytes.Contains(data, []byte(oldPath)) {
+			continue
+		}
+
+		attrView, parseErr := av.ParseAttributeView(avID)
+		if nil != parseErr {
+			continue
+		}
+		if !rewriteAttributeViewAssetRefs(attrView, oldPath, newPath) {
+			continue
+		}
+
+		backups[avPath] = data
+		if err = av.SaveAttributeView(attrView); nil != err {
+			logging.LogErrorf("rewrite asset refs in attribute view [%s] failed: %s", avID, err)
+			rollback()
+			return
+		}
+		ret.AttributeViews = append(ret.AttributeViews, avID)
+	}
+
+	for from := range renamedFiles {
+		if removeErr := filelock.Remove(from); nil != removeErr {
+			logging.LogWarnf("remove renamed asset [%s] failed: %s", from, removeErr)
+		}
+	}
+
+	cache.RemoveAsset(oldPath)
+	IncSync()
+	for _, avID := range ret.AttributeViews {
+		util.PushReloadAttrView(avID)
+	}
+	if 0 < len(ret.RootIDs) {
+		util.ReloadUI()
+	}
+	return
+}
+
+func removeRenamedAssetFiles(renamedFiles map[string]string) {
+	for _, to := range renamedFiles {
+		if err := filelock.Remove(to); nil != err {
+			logging.LogWarnf("remove renamed asset [%s] failed: %s", to, err)
+		}
+	}
+}
+
+// loadAssetRefTrees 加载数据文件中包含资源文件路径的文档树。
+func loadAssetRefTrees(assetPath string) (ret []*parse.Tree, err error) {
+	notebooks, err := ListNotebooks()
+	if nil != err {
+		return
+	}
+
+	luteEngine := util.NewLute()
+	for _, notebook := range notebooks {
+		if notebook.Closed {
+			continue
+		}
+
+		boxDir := filepath.Join(util.DataDir, notebook.ID)
+		for _, paths := range pagedPaths(boxDir, 32) {
+			for _, treeAbsPath := range paths {
+				data, readErr := filesys.ReadSyFile(treeAbsPath)
+				if nil != readErr {
+					logging.LogErrorf("get data [path=%s] failed: %s", treeAbsPath, readErr)
+					err = readErr
+					return
+				}
+				if !bytes.Contains(data, []byte(assetPath)) {
+					continue
+				}
+
+				p := filepath.ToSlash(strings.TrimPrefix(treeAbsPath, boxDir))
+				tree, parseErr := filesys.LoadTreeByData(data, notebook.ID, p, luteEngine)
+				if nil != parseErr {
+					logging.LogWarnf("parse json to tree [%s] failed: %s", treeAbsPath, parseErr)
+					continue
+				}
+				ret = append(ret, tree)
+			}
+		}
+	}
+	return
+}
+
+// rewriteAssetRefs 将文档树中对 oldPath 的引用改写为 newPath，返回是否有改写。
+func rewriteAssetRefs(tree *parse.Tree, oldPath, newPath string) (changed bool) {
+	replaceDest := func(dest string) string {
+		p, query := dest, ""
+		if idx := strings.Index(dest, "?"); 0 < idx {
+			p, query = dest[:idx], dest[idx:]
+		}
+		if oldPath != p {
+			return dest
+		}
+		changed = true
+		return newPath + query
+	}
+
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		for _, kv := range n.KramdownIAL {
+			if strings.Contains(kv[1], oldPath) {
+				n.SetIALAttr(kv[0], strings.ReplaceAll(kv[1], oldPath, newPath))
+				changed = true
+			}
+		}
+
+		switch n.Type {
+		case ast.NodeLinkDest:
+			n.Tokens = []byte(replaceDest(string(n.Tokens)))
+		case ast.NodeTextMark:
+			if n.IsTextMarkType("a") {
+				n.TextMarkAHref = replaceDest(n.TextMarkAHref)
+			}
+		case ast.NodeAudio, ast.NodeVideo, ast.NodeIFrame, ast.NodeHTMLBlock, ast.NodeInlineHTML:
+			if bytes.Contains(n.Tokens, []byte(oldPath)) {
+				n.Tokens = bytes.ReplaceAll(n.Tokens, []byte(oldPath), []byte(newPath))
+				changed = true
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// rewriteAttributeViewAssetRefs 将属性视图资源列中对 oldPath 的引用改写为 newPath，返回是否有改写。
+func rewriteAttributeViewAssetRefs(attrView *av.AttributeView, oldPath, newPath string) (changed bool) {
+	for _, keyValues := range attrView.KeyValues {
+		if av.KeyTypeMAsset != keyValues.Key.Type {
+			continue
+		}
+
+		for _, value := range keyValues.Values {
+			for _, asset := range value.MAsset {
+				if oldPath == asset.Content {
+					asset.Content = newPath
+					if path.Base(oldPath) == asset.Name {
+						asset.Name = path.Base(newPath)
+					}
+					changed = true
+				}
+			}
+		}
+	}
+	return
+}
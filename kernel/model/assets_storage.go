@@ -0,0 +1,477 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// OffloadedAsset 描述了资源文件清单中已经转存到对象存储的资源文件。
+type OffloadedAsset struct {
+	Path    string `json:"path"`    // 相对于 data 目录的路径，比如 assets/foo-20240101000000-abcdefg.png
+	Key     string `json:"key"`     // 对象键
+	Size    int64  `json:"size"`    // 文件大小
+	Hash    string `json:"hash"`    // 文件 Etag
+	Updated int64  `json:"updated"` // 转存时间
+}
+
+var (
+	ErrAssetStorageDisabled = errors.New("asset storage is disabled")
+
+	offloadedAssets     map[string]*OffloadedAsset
+	offloadedAssetsLock = sync.Mutex{}
+	assetStorageLock    = sync.Mutex{}
+)
+
+// 资源文件清单存放在 data/storage/ 下，随数据同步，以便其他设备读取转存的资源文件。
+func getAssetManifestPath() string {
+	return filepath.Join(util.DataDir, "storage", "assets_manifest.json")
+}
+
+func getAssetHotCacheDir() string {
+	return filepath.Join(util.TempDir, "assets")
+}
+
+func isAssetStorageEnabled() bool {
+	storage := Conf.Asset.Storage
+	return conf.AssetStorageProviderS3 == storage.Provider && nil != storage.S3 && "" != storage.S3.Bucket
+}
+
+func loadOffloadedAssets() map[string]*OffloadedAsset {
+	if nil != offloadedAssets {
+		return offloadedAssets
+	}
+
+	offloadedAssets = map[string]*OffloadedAsset{}
+	manifestPath := getAssetManifestPath()
+	if !filelock.IsExist(manifestPath) {
+		return offloadedAssets
+	}
+
+	data, err := filelock.ReadFile(manifestPath)
+	if nil != err {
+		logging.LogErrorf("read asset manifest [%s] failed: %s", manifestPath, err)
+		return offloadedAssets
+	}
+
+	var assets []*OffloadedAsset
+	if err = gulu.JSON.UnmarshalJSON(data, &assets); nil != err {
+		logging.LogErrorf("unmarshal asset manifest [%s] failed: %s", manifestPath, err)
+		return offloadedAssets
+	}
+	for _, asset := range assets {
+		offloadedAssets[asset.Path] = asset
+	}
+	return offloadedAssets
+}
+
+func saveOffloadedAssets() (err error) {
+	assets := []*OffloadedAsset{}
+	for _, asset := range offloadedAssets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Path < assets[j].Path })
+
+	data, err := gulu.JSON.MarshalIndentJSON(assets, "", "\t")
+	if nil != err {
+		return
+	}
+
+	manifestPath := getAssetManifestPath()
+	if err = os.MkdirAll(filepath.Dir(manifestPath), 0755); nil != err {
+		return
+	}
+	if err = filelock.WriteFile(manifestPath, data); nil != err {
+		logging.LogErrorf("write asset manifest [%s] failed: %s", manifestPath, err)
+		return
+	}
+	IncSync()
+	return
+}
+
+func getOffloadedAsset(relPath string) *OffloadedAsset {
+	offloadedAssetsLock.Lock()
+	defer offloadedAssetsLock.Unlock()
+	return loadOffloadedAssets()[relPath]
+}
+
+// IsOffloadedAsset 判断资源文件是否已经转存到对象存储。
+func IsOffloadedAsset(relPath string) bool {
+	return nil != getOffloadedAsset(relPath)
+}
+
+// ListOffloadedAssets 返回资源文件清单。
+func ListOffloadedAssets() (ret []*OffloadedAsset) {
+	offloadedAssetsLock.Lock()
+	defer offloadedAssetsLock.Unlock()
+
+	ret = []*OffloadedAsset{}
+	for _, asset := range loadOffloadedAssets() {
+		ret = append(ret, asset)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+	return
+}
+
+func newAssetStorageClient() (ret *s3.S3, err error) {
+	s3Conf := Conf.Asset.Storage.S3
+	httpClient := &http.Client{Transport: httpclient.NewTransport(s3Conf.SkipTlsVerify)}
+	httpClient.Timeout = time.Duration(s3Conf.Timeout) * time.Second
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(s3Conf.AccessKey, s3Conf.SecretKey, ""),
+		Endpoint:         aws.String(s3Conf.Endpoint),
+		Region:           aws.String(s3Conf.Region),
+		S3ForcePathStyle: aws.Bool(s3Conf.PathStyle),
+		HTTPClient:       httpClient,
+	})
+	if nil != err {
+		return
+	}
+	ret = s3.New(sess)
+	return
+}
+
+func assetStorageKey(relPath string) string {
+	return path.Join(strings.Trim(Conf.Asset.Storage.Prefix, "/"), relPath)
+}
+
+// OffloadAsset 将 data/assets/ 下的资源文件上传到对象存储，记入资源文件清单后移动到本地热缓存。
+func OffloadAsset(relPath string) (err error) {
+	if !isAssetStorageEnabled() {
+		return ErrAssetStorageDisabled
+	}
+	if !strings.HasPrefix(relPath, "assets/") || strings.Contains(relPath, "..") {
+		return errors.New("invalid asset path")
+	}
+
+	absPath := filepath.Join(util.DataDir, relPath)
+	info, err := os.Stat(absPath)
+	if nil != err {
+		return
+	}
+	if info.IsDir() {
+		return
+	}
+
+	client, err := newAssetStorageClient()
+	if nil != err {
+		return
+	}
+
+	f, err := os.Open(absPath)
+	if nil != err {
+		return
+	}
+
+	key := assetStorageKey(relPath)
+	// 使用分片上传，上传时直接读取文件流
+	_, err = s3manager.NewUploaderWithClient(client).Upload(&s3manager.UploadInput{
+		Bucket: aws.String(Conf.Asset.Storage.S3.Bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	})
+	f.Close()
+	if nil != err {
+		logging.LogErrorf("upload asset [%s] to storage failed: %s", relPath, err)
+		return
+	}
+
+	hash, _ := util.GetEtag(absPath)
+	offloadedAssetsLock.Lock()
+	loadOffloadedAssets()[relPath] = &OffloadedAsset{Path: relPath, Key: key, Size: info.Size(), Hash: hash, Updated: time.Now().UnixMilli()}
+	err = saveOffloadedAssets()
+	offloadedAssetsLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	cachePath := filepath.Join(getAssetHotCacheDir(), relPath)
+	if copyErr := gulu.File.Copy(absPath, cachePath); nil != copyErr {
+		logging.LogWarnf("copy asset [%s] to hot cache failed: %s", relPath, copyErr)
+	}
+	if err = filelock.Remove(absPath); nil != err {
+		logging.LogErrorf("remove offloaded asset [%s] failed: %s", absPath, err)
+		return
+	}
+	logging.LogInfof("offloaded asset [%s] to storage [%s]", relPath, key)
+	return
+}
+
+// OffloadAssets 将 data/assets/ 下的所有资源文件转存到对象存储，返回转存成功的资源文件数量。
+func OffloadAssets() (count int, err error) {
+	if !isAssetStorageEnabled() {
+		return 0, ErrAssetStorageDisabled
+	}
+
+	assetStorageLock.Lock()
+	defer assetStorageLock.Unlock()
+
+	assetsDir := filepath.Join(util.DataDir, "assets")
+	var relPaths []string
+	filelock.Walk(assetsDir, func(absPath string, info fs.FileInfo, walkErr error) error {
+		if nil != walkErr {
+			return walkErr
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), ".sya") || strings.HasPrefix(info.Name(), ".") {
+			// PDF 标注和隐藏文件需要频繁读写，保留在本地
+			return nil
+		}
+
+		relPaths = append(relPaths, "assets"+filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir)))
+		return nil
+	})
+
+	msgId := util.PushMsg(Conf.Language(116), 1000*7)
+	defer util.PushClearMsg(msgId)
+	for _, relPath := range relPaths {
+		if err = OffloadAsset(relPath); nil != err {
+			return
+		}
+		count++
+	}
+	return
+}
+
+// RestoreOffloadedAssets 将对象存储中的资源文件全部下载回 data/assets/，并清空资源文件清单，用于停用对象存储。
+func RestoreOffloadedAssets() (count int, err error) {
+	if !isAssetStorageEnabled() {
+		return 0, ErrAssetStorageDisabled
+	}
+
+	assetStorageLock.Lock()
+	defer assetStorageLock.Unlock()
+
+	for _, asset := range ListOffloadedAssets() {
+		absPath := filepath.Join(util.DataDir, asset.Path)
+		if !filelock.IsExist(absPath) {
+			cachePath, fetchErr := fetchOffloadedAsset(asset)
+			if nil != fetchErr {
+				err = fetchErr
+				return
+			}
+			if err = filelock.Copy(cachePath, absPath); nil != err {
+				return
+			}
+		}
+
+		offloadedAssetsLock.Lock()
+		delete(loadOffloadedAssets(), asset.Path)
+		err = saveOffloadedAssets()
+		offloadedAssetsLock.Unlock()
+		if nil != err {
+			return
+		}
+		count++
+	}
+	return
+}
+
+// getOffloadedAssetAbsPath 返回转存资源文件的本地热缓存路径，缓存中没有时从对象存储下载。
+func getOffloadedAssetAbsPath(relPath string) (ret string, err error) {
+	asset := getOffloadedAsset(relPath)
+	if nil == asset {
+		err = os.ErrNotExist
+		return
+	}
+	return fetchOffloadedAsset(asset)
+}
+
+func fetchOffloadedAsset(asset *OffloadedAsset) (ret string, err error) {
+	ret = filepath.Join(getAssetHotCacheDir(), asset.Path)
+	if !util.IsSubPath(getAssetHotCacheDir(), ret) {
+		err = errors.New("invalid asset path")
+		return
+	}
+	if gulu.File.IsExist(ret) {
+		now := time.Now()
+		os.Chtimes(ret, now, now) // 更新访问时间，用于淘汰最久未使用的缓存
+		return
+	}
+	if !isAssetStorageEnabled() {
+		err = ErrAssetStorageDisabled
+		return
+	}
+
+	client, err := newAssetStorageClient()
+	if nil != err {
+		return
+	}
+
+	output, err := client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(Conf.Asset.Storage.S3.Bucket),
+		Key:    aws.String(asset.Key),
+	})
+	if nil != err {
+		logging.LogErrorf("download asset [%s] from storage failed: %s", asset.Path, err)
+		return
+	}
+	defer output.Body.Close()
+
+	if err = os.MkdirAll(filepath.Dir(ret), 0755); nil != err {
+		return
+	}
+	tmp := ret + ".tmp"
+	f, err := os.Create(tmp)
+	if nil != err {
+		return
+	}
+	if _, err = io.Copy(f, output.Body); nil != err {
+		f.Close()
+		os.Remove(tmp)
+		return
+	}
+	f.Close()
+	if err = os.Rename(tmp, ret); nil != err {
+		return
+	}
+
+	go evictAssetHotCache()
+	return
+}
+
+// GetOffloadedAssetSignedURL 在读取方式为重定向时返回转存资源文件的签名地址，否则返回空字符串。
+func GetOffloadedAssetSignedURL(relPath string) string {
+	storage := Conf.Asset.Storage
+	if !isAssetStorageEnabled() || conf.AssetStorageServeSigned != storage.ServeMode {
+		return ""
+	}
+
+	asset := getOffloadedAsset(relPath)
+	if nil == asset || filelock.IsExist(filepath.Join(util.DataDir, relPath)) {
+		return ""
+	}
+
+	client, err := newAssetStorageClient()
+	if nil != err {
+		return ""
+	}
+
+	req, _ := client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(storage.S3.Bucket),
+		Key:    aws.String(asset.Key),
+	})
+	ret, err := req.Presign(time.Duration(storage.SignedURLExpire) * time.Second)
+	if nil != err {
+		logging.LogErrorf("presign asset [%s] failed: %s", relPath, err)
+		return ""
+	}
+	return ret
+}
+
+// offloadUploadedAssets 在启用对象存储时将上传到 data/assets/ 的资源文件转存到对象存储。
+func offloadUploadedAssets(succMap map[string]interface{}) {
+	if !isAssetStorageEnabled() {
+		return
+	}
+
+	var relPaths []string
+	for _, p := range succMap {
+		if relPath, ok := p.(string); ok && strings.HasPrefix(relPath, "assets/") {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+	if 1 > len(relPaths) {
+		return
+	}
+
+	go func() {
+		defer logging.Recover()
+
+		assetStorageLock.Lock()
+		defer assetStorageLock.Unlock()
+		for _, relPath := range relPaths {
+			if err := OffloadAsset(relPath); nil != err {
+				logging.LogErrorf("offload uploaded asset [%s] failed: %s", relPath, err)
+			}
+		}
+	}()
+}
+
+var evictAssetHotCacheLock = sync.Mutex{}
+
+// evictAssetHotCache 淘汰最久未使用的热缓存，直到缓存大小不超过配置的上限。
+func evictAssetHotCache() {
+	defer logging.Recover()
+
+	evictAssetHotCacheLock.Lock()
+	defer evictAssetHotCacheLock.Unlock()
+
+	maxSize := Conf.Asset.Storage.HotCacheSize * 1024 * 1024
+	if 1 > maxSize {
+		return
+	}
+
+	type cached struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []*cached
+	var total int64
+	filepath.Walk(getAssetHotCacheDir(), func(p string, info fs.FileInfo, err error) error {
+		if nil != err || info.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		files = append(files, &cached{path: p, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if total <= maxSize {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= maxSize {
+			break
+		}
+		if err := os.Remove(f.path); nil != err {
+			logging.LogWarnf("remove asset hot cache [%s] failed: %s", f.path, err)
+			continue
+		}
+		total -= f.size
+	}
+}
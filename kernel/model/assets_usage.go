@@ -0,0 +1,196 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AssetRef 描述了资源文件的一处引用，来自块或者属性视图资源列。
+type AssetRef struct {
+	BlockID string `json:"blockID"` // 引用块 ID，属性视图中为行绑定的块 ID，未绑定块时为空
+	RootID  string `json:"rootID"`  // 引用块所在文档 ID
+	Box     string `json:"box"`     // 引用块所在笔记本 ID
+	HPath   string `json:"hPath"`   // 引用块所在文档的可读路径
+	Content string `json:"content"` // 引用块内容
+	AvID    string `json:"avID"`    // 属性视图 ID，块引用时为空
+	AvName  string `json:"avName"`  // 属性视图名称
+	KeyID   string `json:"keyID"`   // 资源列 ID
+	ItemID  string `json:"itemID"`  // 属性视图行 ID
+}
+
+type AssetUsage struct {
+	Path     string      `json:"path"`
+	Size     int64       `json:"size"`
+	HSize    string      `json:"hSize"`
+	RefCount int         `json:"refCount"` // 引用数，块引用按块计数，属性视图引用按行计数
+	Refs     []*AssetRef `json:"refs"`
+}
+
+// GetAssetUsage 返回资源文件的所有引用。
+func GetAssetUsage(p string) (ret *AssetUsage) {
+	ret = &AssetUsage{Path: p, Refs: []*AssetRef{}}
+	if info, err := os.Stat(filepath.Join(util.DataDir, p)); nil == err {
+		ret.Size = info.Size()
+		ret.HSize = humanize.BytesCustomCeil(uint64(ret.Size), 2)
+	}
+
+	var blockIDs []string
+	blockIDSet := map[string]bool{}
+	for _, asset := range sql.QueryAssetsByPath(p) {
+		if blockIDSet[asset.BlockID] {
+			continue
+		}
+		blockIDSet[asset.BlockID] = true
+		blockIDs = append(blockIDs, asset.BlockID)
+	}
+
+	for _, block := range sql.GetBlocks(blockIDs) {
+		if nil == block {
+			continue
+		}
+		ret.Refs = append(ret.Refs, &AssetRef{
+			BlockID: block.ID,
+			RootID:  block.RootID,
+			Box:     block.Box,
+			HPath:   block.HPath,
+			Content: block.Content,
+		})
+	}
+
+	ret.Refs = append(ret.Refs, getAttributeViewAssetRefs(true)[p]...)
+	ret.RefCount = len(ret.Refs)
+	return
+}
+
+// GetLargestAssets 返回占用空间最大的 limit 个资源文件及其引用数，不包含引用详情。
+func GetLargestAssets(limit int) (ret []*AssetUsage) {
+	ret = []*AssetUsage{}
+	assetsDir := filepath.Join(util.DataDir, "assets")
+	filelock.Walk(assetsDir, func(absPath string, info fs.FileInfo, err error) error {
+		if nil != err || nil == info {
+			return nil
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), ".sya") || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		ret = append(ret, &AssetUsage{
+			Path: "assets" + filepath.ToSlash(strings.TrimPrefix(absPath, assetsDir)),
+			Size: info.Size(),
+		})
+		return nil
+	})
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Size > ret[j].Size })
+	if 0 < limit && limit < len(ret) {
+		ret = ret[:limit]
+	}
+
+	refCounts := sql.QueryAssetRefCounts()
+	avRefs := getAttributeViewAssetRefs(false)
+	for _, usage := range ret {
+		usage.HSize = humanize.BytesCustomCeil(uint64(usage.Size), 2)
+		usage.RefCount = refCounts[usage.Path] + len(avRefs[usage.Path])
+		usage.Refs = []*AssetRef{}
+	}
+	return
+}
+
+// getAttributeViewAssetRefs 返回所有属性视图资源列中的资源文件引用，键为资源文件路径，withBlocks 为 false 时不查询绑定块的内容。
+func getAttributeViewAssetRefs(withBlocks bool) (ret map[string][]*AssetRef) {
+	ret = map[string][]*AssetRef{}
+	avDir := filepath.Join(util.DataDir, "storage", "av")
+	entries, err := os.ReadDir(avDir)
+	if nil != err {
+		return
+	}
+
+	for _, entry := range entries {
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+
+		// 先按文本过滤，避免解析不包含资源文件的属性视图
+		data, readErr := filelock.ReadFile(filepath.Join(avDir, entry.Name()))
+		if nil != readErr || !bytes.Contains(data, []byte("assets/")) {
+			continue
+		}
+
+		attrView, parseErr := av.ParseAttributeView(avID)
+		if nil != parseErr {
+			continue
+		}
+
+		boundBlockIDs := map[string]string{}
+		if blockKeyValues := attrView.GetBlockKeyValues(); nil != blockKeyValues {
+			for _, v := range blockKeyValues.Values {
+				if !v.IsDetached && nil != v.Block {
+					boundBlockIDs[v.BlockID] = v.Block.ID
+				}
+			}
+		}
+
+		for _, keyValues := range attrView.KeyValues {
+			if av.KeyTypeMAsset != keyValues.Key.Type {
+				continue
+			}
+
+			for _, value := range keyValues.Values {
+				for _, asset := range value.MAsset {
+					if !strings.HasPrefix(asset.Content, "assets/") {
+						continue
+					}
+
+					ref := &AssetRef{
+						BlockID: boundBlockIDs[value.BlockID],
+						AvID:    avID,
+						AvName:  attrView.Name,
+						KeyID:   keyValues.Key.ID,
+						ItemID:  value.BlockID,
+					}
+					if withBlocks && "" != ref.BlockID {
+						if block := sql.GetBlock(ref.BlockID); nil != block {
+							ref.RootID, ref.Box, ref.HPath, ref.Content = block.RootID, block.Box, block.HPath, block.Content
+						}
+					}
+					ret[asset.Content] = append(ret[asset.Content], ref)
+				}
+			}
+		}
+	}
+	return
+}
@@ -18,13 +18,16 @@ package model
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/88250/gulu"
@@ -32,6 +35,7 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/siyuan-note/dejavu/entity"
 	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/cache"
@@ -42,7 +46,8 @@ import (
 	"github.com/xrash/smetrics"
 )
 
-func DuplicateDatabaseBlock(avID string) (newAvID, newBlockID string, err error) {
+// DuplicateDatabaseBlock 复制属性视图，withData 为 false 时仅复制列和视图设置，不复制行。
+func DuplicateDatabaseBlock(avID string, withData bool) (newAvID, newBlockID string, err error) {
 	storageAvDir := filepath.Join(util.DataDir, "storage", "av")
 	oldAvPath := filepath.Join(storageAvDir, avID+".json")
 	newAvID, newBlockID = ast.NewNodeID(), ast.NewNodeID()
@@ -75,6 +80,17 @@ func DuplicateDatabaseBlock(avID string) (newAvID, newBlockID string, err error)
 			keyValues.Key.Relation.IsTwoWay = false
 			keyValues.Key.Relation.BackKeyID = ""
 		}
+
+		if !withData {
+			keyValues.Values = nil
+		}
+	}
+	if !withData {
+		for _, view := range newAv.Views {
+			if nil != view.Table {
+				view.Table.RowIDs = nil
+			}
+		}
 	}
 
 	data, err = gulu.JSON.MarshalJSON(newAv)
@@ -143,7 +159,7 @@ func GetAttributeViewPrimaryKeyValues(avID, keyword string, page, pageSize int)
 	for _, kv := range keyValues.Values {
 		for _, view := range attrView.Views {
 			switch view.LayoutType {
-			case av.LayoutTypeTable:
+			case av.LayoutTypeTable, av.LayoutTypeGallery:
 				if !kv.IsDetached {
 					if nil == treenode.GetBlockTree(kv.BlockID) {
 						break
@@ -198,7 +214,7 @@ func GetAttributeViewFilterSort(avID, blockID string) (filters []*av.ViewFilter,
 	filters = []*av.ViewFilter{}
 	sorts = []*av.ViewSort{}
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		filters = view.Table.Filters
 		sorts = view.Table.Sorts
 	}
@@ -252,6 +268,34 @@ func GetAttributeView(avID string) (ret *av.AttributeView) {
 	return
 }
 
+// CheckAttributeViewAccess 检查该角色是否可以写入属性视图中指定视图的指定列，viewID 和 keyID 都可以为空。
+func CheckAttributeViewAccess(avID, viewID, keyID, role string) (err error) {
+	if "" == role {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+	return attrView.CheckWritable(viewID, keyID, role)
+}
+
+// CheckAttributeViewRowsAccess 检查该角色是否可以删除或者覆盖属性视图中的行。
+func CheckAttributeViewRowsAccess(avID, role string) (err error) {
+	if "" == role {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+	return attrView.CheckRowsWritable(role)
+}
+
 type SearchAttributeViewResult struct {
 	AvID    string `json:"avID"`
 	AvName  string `json:"avName"`
@@ -855,7 +899,7 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 
 	// 补全过滤器 Value
 	if nil != view.Table {
-		for _, f := range view.Table.Filters {
+		for _, f := range view.Table.GetAllFilters() {
 			if nil != f.Value {
 				continue
 			}
@@ -867,7 +911,7 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		// 列删除以后需要删除设置的过滤和排序
 		tmpFilters := []*av.ViewFilter{}
 		for _, f := range view.Table.Filters {
@@ -876,6 +920,10 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 			}
 		}
 		view.Table.Filters = tmpFilters
+		view.Table.FilterGroup.RemoveFilters(func(f *av.ViewFilter) bool {
+			k, _ := attrView.GetKey(f.Column)
+			return nil == k
+		})
 
 		tmpSorts := []*av.ViewSort{}
 		for _, s := range view.Table.Sorts {
@@ -885,7 +933,7 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 		}
 		view.Table.Sorts = tmpSorts
 
-		viewable, err = sql.RenderAttributeViewTable(attrView, view, query, GetBlockAttrsWithoutWaitWriting)
+		viewable, err = sql.RenderAttributeViewTable(prefilterAttributeView(attrView, view), view, query, GetBlockAttrsWithoutWaitWriting)
 	}
 
 	viewable.FilterRows(attrView)
@@ -905,16 +953,87 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 			pageSize = table.PageSize
 		}
 
-		start := (page - 1) * pageSize
-		end := start + pageSize
-		if len(table.Rows) < end {
-			end = len(table.Rows)
-		}
-		table.Rows = table.Rows[start:end]
+		table.Rows, _ = table.PageRows("", page, pageSize)
+	}
+	return
+}
+
+// GetAttributeViewRows 按页或者游标获取属性视图的行，过滤和排序在内核中完成，仅返回请求的部分。
+//
+// cursor 为上一次返回的最后一行 ID，不为空时忽略 page，从该行之后开始返回。
+func GetAttributeViewRows(avID, viewID, query, cursor string, page, pageSize int, role string) (rows []*av.TableRow, total int, nextCursor string, err error) {
+	waitForSyncingStorages()
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+	if attrView.IsViewHidden(viewID, role) {
+		err = av.ErrAccessDenied
+		return
+	}
+
+	viewable, err := renderAttributeView(attrView, viewID, query, 1, math.MaxInt32)
+	if nil != err {
+		return
+	}
+
+	table, ok := viewable.(*av.Table)
+	if !ok {
+		err = av.ErrViewNotFound
+		return
+	}
+
+	if 1 > pageSize {
+		pageSize = table.PageSize
 	}
+	total = table.RowCount
+	rows, nextCursor = table.PageRows(cursor, page, pageSize)
+	rows = av.RemoveHiddenCells(rows, attrView, role)
 	return
 }
 
+// attributeViewIndexThreshold 行数超过该值时使用 av_cells 索引预先过滤行，避免为所有行生成单元格。
+const attributeViewIndexThreshold = 1024
+
+// prefilterAttributeView 使用索引排除确定不满足视图过滤条件的行，返回的属性视图仅用于渲染，不能保存。
+func prefilterAttributeView(attrView *av.AttributeView, view *av.View) *av.AttributeView {
+	blockValues := attrView.GetBlockKeyValues()
+	if nil == blockValues || attributeViewIndexThreshold > len(blockValues.Values) || 1 > len(view.Table.Filters) {
+		return attrView
+	}
+
+	// FilterRows 只使用视图中存在的列的过滤条件
+	var filters []*av.ViewFilter
+	for _, f := range view.Table.Filters {
+		for _, col := range view.Table.Columns {
+			if col.ID == f.Column {
+				filters = append(filters, f)
+				break
+			}
+		}
+	}
+
+	rowIDs, ok := sql.QueryAttributeViewRowIDs(attrView.ID, filters)
+	if !ok {
+		return attrView
+	}
+
+	ret := *attrView
+	ret.KeyValues = nil
+	for _, kv := range attrView.KeyValues {
+		keyValues := &av.KeyValues{Key: kv.Key}
+		for _, v := range kv.Values {
+			if rowIDs[v.BlockID] {
+				keyValues.Values = append(keyValues.Values, v)
+			}
+		}
+		ret.KeyValues = append(ret.KeyValues, keyValues)
+	}
+	return &ret
+}
+
 func getRowBlockValue(keyValues []*av.KeyValues) (ret *av.Value) {
 	for _, kv := range keyValues {
 		if av.KeyTypeBlock == kv.Key.Type && 0 < len(kv.Values) {
@@ -966,7 +1085,7 @@ func unbindAttributeViewBlock(operation *Operation, tx *Transaction) (err error)
 	replacedRowID := false
 	for _, v := range attrView.Views {
 		switch v.LayoutType {
-		case av.LayoutTypeTable:
+		case av.LayoutTypeTable, av.LayoutTypeGallery:
 			for i, rowID := range v.Table.RowIDs {
 				if rowID == operation.ID {
 					v.Table.RowIDs[i] = operation.NextID
@@ -1015,6 +1134,194 @@ func setAttributeViewColDate(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doSetAttrViewColRating(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColRating(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColRating(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyID := operation.ID
+	key, _ := attrView.GetKey(keyID)
+	if nil == key || av.KeyTypeRating != key.Type {
+		return
+	}
+
+	maxRatingVal, ok := operation.Data.(float64)
+	if !ok {
+		err = fmt.Errorf("invalid max rating [%v]", operation.Data)
+		return
+	}
+	maxRating := int(maxRatingVal)
+	if 1 > maxRating || 10 < maxRating {
+		err = fmt.Errorf("invalid max rating [%d]", maxRating)
+		return
+	}
+
+	if nil == key.Rating {
+		key.Rating = &av.Rating{}
+	}
+	key.Rating.Max = maxRating
+
+	// 降低最大评分后截断已有的评分
+	for _, kv := range attrView.KeyValues {
+		if kv.Key.ID != keyID {
+			continue
+		}
+
+		for _, v := range kv.Values {
+			v.FormatMeasure(key)
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColCurrency(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColCurrency(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColCurrency(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyID := operation.ID
+	key, _ := attrView.GetKey(keyID)
+	if nil == key || av.KeyTypeCurrency != key.Type {
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(operation.Format))
+	if 3 != len(code) {
+		err = fmt.Errorf("invalid currency code [%s]", operation.Format)
+		return
+	}
+
+	oldCode := key.Currency.GetCode()
+	if nil == key.Currency {
+		key.Currency = &av.Currency{}
+	}
+	key.Currency.Code = code
+
+	// 跟随列默认货币的值一起切换货币
+	for _, kv := range attrView.KeyValues {
+		if kv.Key.ID != keyID {
+			continue
+		}
+
+		for _, v := range kv.Values {
+			if nil != v.Currency && ("" == v.Currency.Code || oldCode == v.Currency.Code) {
+				v.Currency.Code = code
+				v.FormatMeasure(key)
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColDuration(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColDuration(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColDuration(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyID := operation.ID
+	key, _ := attrView.GetKey(keyID)
+	if nil == key || av.KeyTypeDuration != key.Type {
+		return
+	}
+
+	if nil == key.Duration {
+		key.Duration = &av.Duration{}
+	}
+	key.Duration.Format = av.DurationFormat(operation.Format)
+
+	for _, kv := range attrView.KeyValues {
+		if kv.Key.ID != keyID {
+			continue
+		}
+
+		for _, v := range kv.Values {
+			v.FormatMeasure(key)
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColValidation(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColValidation(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColValidation(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, _ := attrView.GetKey(operation.ID)
+	if nil == key {
+		return
+	}
+
+	switch key.Type {
+	case av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		// 自动生成的列值不支持校验
+		return
+	}
+
+	if nil == operation.Data {
+		key.Validation = nil
+		err = av.SaveAttributeView(attrView)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+	validation := &av.Validation{}
+	if err = gulu.JSON.UnmarshalJSON(data, validation); nil != err {
+		return
+	}
+	if err = validation.CheckValidation(); nil != err {
+		return
+	}
+
+	key.Validation = validation
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func (tx *Transaction) doHideAttrViewName(operation *Operation) (ret *TxErr) {
 	err := hideAttrViewName(operation)
 	if nil != err {
@@ -1211,7 +1518,7 @@ func updateAttributeViewColRelation(operation *Operation) (err error) {
 
 		for _, v := range destAv.Views {
 			switch v.LayoutType {
-			case av.LayoutTypeTable:
+			case av.LayoutTypeTable, av.LayoutTypeGallery:
 				v.Table.Columns = append(v.Table.Columns, &av.ViewTableColumn{ID: operation.BackRelationKeyID})
 			}
 		}
@@ -1447,6 +1754,11 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 	view.Name = attrView.GetDuplicateViewName(masterView.Name)
 	view.LayoutType = masterView.LayoutType
 	view.HideAttrViewName = masterView.HideAttrViewName
+	if nil != masterView.Gallery {
+		gallery := *masterView.Gallery
+		gallery.FieldIDs = append([]string{}, masterView.Gallery.FieldIDs...)
+		view.Gallery = &gallery
+	}
 
 	for _, col := range masterView.Table.Columns {
 		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{
@@ -1469,6 +1781,14 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		})
 	}
 
+	if nil != masterView.Table.FilterGroup {
+		data, _ := gulu.JSON.MarshalJSON(masterView.Table.FilterGroup)
+		view.Table.FilterGroup = &av.ViewFilterGroup{}
+		if err := gulu.JSON.UnmarshalJSON(data, view.Table.FilterGroup); nil != err {
+			view.Table.FilterGroup = nil
+		}
+	}
+
 	for _, s := range masterView.Table.Sorts {
 		view.Table.Sorts = append(view.Table.Sorts, &av.ViewSort{
 			Column: s.Column,
@@ -1521,12 +1841,20 @@ func (tx *Transaction) doAddAttrViewView(operation *Operation) (ret *TxErr) {
 	}
 
 	view := av.NewTableView()
+	if av.LayoutTypeGallery == av.LayoutType(operation.Typ) {
+		view = av.NewGalleryView()
+	}
 	view.ID = operation.ID
 	attrView.Views = append(attrView.Views, view)
 	attrView.ViewID = view.ID
 
 	for _, col := range firstView.Table.Columns {
 		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: col.ID})
+		if nil != view.Gallery && !col.Hidden {
+			if key, _ := attrView.GetKey(col.ID); nil != key && av.KeyTypeBlock != key.Type {
+				view.Gallery.FieldIDs = append(view.Gallery.FieldIDs, col.ID)
+			}
+		}
 	}
 
 	view.Table.RowIDs = firstView.Table.RowIDs
@@ -1697,7 +2025,7 @@ func setAttributeViewFilters(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		if err = gulu.JSON.UnmarshalJSON(data, &view.Table.Filters); nil != err {
 			return
 		}
@@ -1707,6 +2035,48 @@ func setAttributeViewFilters(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doSetAttrViewFilterGroup(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewFilterGroup(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewFilterGroup(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
+		if nil == operation.Data {
+			view.Table.FilterGroup = nil
+			break
+		}
+
+		data, marshalErr := gulu.JSON.MarshalJSON(operation.Data)
+		if nil != marshalErr {
+			return marshalErr
+		}
+
+		group := &av.ViewFilterGroup{}
+		if err = gulu.JSON.UnmarshalJSON(data, group); nil != err {
+			return
+		}
+		view.Table.FilterGroup = group
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func (tx *Transaction) doSetAttrViewSorts(operation *Operation) (ret *TxErr) {
 	err := setAttributeViewSorts(operation)
 	if nil != err {
@@ -1733,7 +2103,7 @@ func setAttributeViewSorts(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		if err = gulu.JSON.UnmarshalJSON(data, &view.Table.Sorts); nil != err {
 			return
 		}
@@ -1763,7 +2133,7 @@ func setAttributeViewPageSize(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		view.Table.PageSize = int(operation.Data.(float64))
 	}
 
@@ -1798,7 +2168,7 @@ func setAttributeViewColumnCalc(operation *Operation) (err error) {
 
 	calc := &av.ColumnCalc{}
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		if err = gulu.JSON.UnmarshalJSON(data, calc); nil != err {
 			return
 		}
@@ -1906,8 +2276,9 @@ func addAttributeViewBlock(now int64, avID, blockID, previousBlockID, addingBloc
 		Block:      &av.ValueBlock{ID: addingBlockID, Content: addingBlockContent, Created: now, Updated: now}}
 	blockValues.Values = append(blockValues.Values, blockValue)
 
-	// 如果存在过滤条件，则将过滤条件应用到新添加的块上
 	view, _ := getAttrViewViewByBlockID(attrView, blockID)
+
+	// 如果存在过滤条件，则将过滤条件应用到新添加的块上
 	if nil != view && 0 < len(view.Table.Filters) && !ignoreFillFilter {
 		viewable, _ := sql.RenderAttributeViewTable(attrView, view, "", GetBlockAttrsWithoutWaitWriting)
 		viewable.FilterRows(attrView)
@@ -1992,13 +2363,18 @@ func addAttributeViewBlock(now int64, avID, blockID, previousBlockID, addingBloc
 		}
 	}
 
+	// 应用视图的新建行模板
+	if nil != view && nil != view.RowTemplate {
+		applyAttributeViewRowTemplate(attrView, view.RowTemplate, addingBlockID, isDetached, now, node, tree)
+	}
+
 	if !isDetached {
 		bindBlockAv0(tx, avID, node, tree)
 	}
 
 	for _, v := range attrView.Views {
 		switch v.LayoutType {
-		case av.LayoutTypeTable:
+		case av.LayoutTypeTable, av.LayoutTypeGallery:
 			if "" != previousBlockID {
 				changed := false
 				for i, id := range v.Table.RowIDs {
@@ -2143,7 +2519,7 @@ func setAttributeViewColWidth(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		for _, column := range view.Table.Columns {
 			if column.ID == operation.ID {
 				column.Width = operation.Data.(string)
@@ -2176,7 +2552,7 @@ func setAttributeViewColWrap(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		for _, column := range view.Table.Columns {
 			if column.ID == operation.ID {
 				column.Wrap = operation.Data.(bool)
@@ -2209,7 +2585,7 @@ func setAttributeViewColHidden(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		for _, column := range view.Table.Columns {
 			if column.ID == operation.ID {
 				column.Hidden = operation.Data.(bool)
@@ -2242,7 +2618,7 @@ func setAttributeViewColPin(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		for _, column := range view.Table.Columns {
 			if column.ID == operation.ID {
 				column.Pin = operation.Data.(bool)
@@ -2320,7 +2696,7 @@ func sortAttributeViewRow(operation *Operation) (err error) {
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		view.Table.RowIDs = append(view.Table.RowIDs[:idx], view.Table.RowIDs[idx+1:]...)
 		for i, r := range view.Table.RowIDs {
 			if r == operation.PreviousID {
@@ -2360,7 +2736,7 @@ func SortAttributeViewViewKey(avID, blockID, keyID, previousKeyID string) (err e
 	}
 
 	switch view.LayoutType {
-	case av.LayoutTypeTable:
+	case av.LayoutTypeTable, av.LayoutTypeGallery:
 		var col *av.ViewTableColumn
 		var index, previousIndex int
 		for i, column := range view.Table.Columns {
@@ -2462,18 +2838,26 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 	switch keyTyp {
 	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeRating, av.KeyTypeProgress, av.KeyTypeCurrency,
+		av.KeyTypeDuration:
 
 		key := av.NewKey(keyID, keyName, keyIcon, keyTyp)
-		if av.KeyTypeRollup == keyTyp {
+		switch keyTyp {
+		case av.KeyTypeRollup:
 			key.Rollup = &av.Rollup{Calc: &av.RollupCalc{Operator: av.CalcOperatorNone}}
+		case av.KeyTypeRating:
+			key.Rating = &av.Rating{Max: av.DefaultRatingMax}
+		case av.KeyTypeCurrency:
+			key.Currency = &av.Currency{Code: av.DefaultCurrencyCode}
+		case av.KeyTypeDuration:
+			key.Duration = &av.Duration{Format: av.DurationFormatNone}
 		}
 
 		attrView.KeyValues = append(attrView.KeyValues, &av.KeyValues{Key: key})
 
 		for _, view := range attrView.Views {
 			switch view.LayoutType {
-			case av.LayoutTypeTable:
+			case av.LayoutTypeTable, av.LayoutTypeGallery:
 				if "" == previousKeyID {
 					view.Table.Columns = append([]*av.ViewTableColumn{{ID: key.ID}}, view.Table.Columns...)
 					break
@@ -2574,7 +2958,8 @@ func updateAttributeViewColumn(operation *Operation) (err error) {
 	switch colType {
 	case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeRating, av.KeyTypeProgress, av.KeyTypeCurrency,
+		av.KeyTypeDuration:
 		for _, keyValues := range attrView.KeyValues {
 			if keyValues.Key.ID == operation.ID {
 				keyValues.Key.Name = strings.TrimSpace(operation.Name)
@@ -2642,7 +3027,7 @@ func RemoveAttributeViewKey(avID, keyID string) (err error) {
 
 				for _, view := range destAv.Views {
 					switch view.LayoutType {
-					case av.LayoutTypeTable:
+					case av.LayoutTypeTable, av.LayoutTypeGallery:
 						for i, column := range view.Table.Columns {
 							if column.ID == removedKey.Relation.BackKeyID {
 								view.Table.Columns = append(view.Table.Columns[:i], view.Table.Columns[i+1:]...)
@@ -2676,7 +3061,7 @@ func RemoveAttributeViewKey(avID, keyID string) (err error) {
 
 	for _, view := range attrView.Views {
 		switch view.LayoutType {
-		case av.LayoutTypeTable:
+		case av.LayoutTypeTable, av.LayoutTypeGallery:
 			for i, column := range view.Table.Columns {
 				if column.ID == keyID {
 					view.Table.Columns = append(view.Table.Columns[:i], view.Table.Columns[i+1:]...)
@@ -2684,6 +3069,14 @@ func RemoveAttributeViewKey(avID, keyID string) (err error) {
 				}
 			}
 		}
+
+		if nil != view.Gallery {
+			view.Gallery.FieldIDs = gulu.Str.RemoveElem(view.Gallery.FieldIDs, keyID)
+			if view.Gallery.CoverFromAssetKeyID == keyID {
+				view.Gallery.CoverFromAssetKeyID = ""
+				view.Gallery.CoverFrom = av.CoverFromContentImage
+			}
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -2751,7 +3144,7 @@ func replaceAttributeViewBlock(operation *Operation, tx *Transaction) (err error
 	replacedRowID := false
 	for _, v := range attrView.Views {
 		switch v.LayoutType {
-		case av.LayoutTypeTable:
+		case av.LayoutTypeTable, av.LayoutTypeGallery:
 			for i, rowID := range v.Table.RowIDs {
 				if rowID == operation.PreviousID {
 					v.Table.RowIDs[i] = operation.NextID
@@ -2773,6 +3166,10 @@ func replaceAttributeViewBlock(operation *Operation, tx *Transaction) (err error
 func (tx *Transaction) doUpdateAttrViewCell(operation *Operation) (ret *TxErr) {
 	err := updateAttributeViewCell(operation, tx)
 	if nil != err {
+		var validationErr *av.ValidationError
+		if errors.As(err, &validationErr) {
+			return &TxErr{code: TxErrInvalidAttrViewVal, id: operation.AvID, msg: err.Error(), data: validationErr}
+		}
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
@@ -2838,6 +3235,7 @@ func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID, cellID string,
 			}
 		}
 	}
+	oldVal := val.Clone()
 	data, err := gulu.JSON.MarshalJSON(valueData)
 	if nil != err {
 		return
@@ -2847,31 +3245,10 @@ func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID, cellID string,
 	}
 
 	key, _ := attrView.GetKey(keyID)
+	normalizeAttributeViewValue(key, val)
 
-	if av.KeyTypeNumber == val.Type {
-		if nil != val.Number && !val.Number.IsNotEmpty {
-			val.Number.Content = 0
-			val.Number.FormattedContent = ""
-		}
-	} else if av.KeyTypeDate == val.Type {
-		if nil != val.Date && !val.Date.IsNotEmpty {
-			val.Date.Content = 0
-			val.Date.FormattedContent = ""
-		}
-	} else if av.KeyTypeSelect == val.Type || av.KeyTypeMSelect == val.Type {
-		if nil != key && 0 < len(val.MSelect) {
-			// The selection options are inconsistent after pasting data into the database https://github.com/siyuan-note/siyuan/issues/11409
-			for _, valOpt := range val.MSelect {
-				if opt := key.GetOption(valOpt.Content); nil == opt {
-					// 不存在的选项新建保存
-					opt = &av.SelectOption{Name: valOpt.Content, Color: valOpt.Color}
-					key.Options = append(key.Options, opt)
-				} else {
-					// 已经存在的选项颜色需要保持不变
-					valOpt.Color = opt.Color
-				}
-			}
-		}
+	if err = attrView.ValidateValue(key, val); nil != err {
+		return
 	}
 
 	relationChangeMode := 0 // 0：不变（仅排序），1：增加，2：减少
@@ -3007,12 +3384,57 @@ func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID, cellID string,
 		util.PushReloadAttrView(relatedAvID)
 	}
 
+	if nil != attrView.DataSource && attrView.DataSource.IsMapped(keyID) {
+		// 标记本地编辑过的值，同步外部数据源时不覆盖
+		attrView.DataSource.MarkEdited(val.ID)
+	}
+
+	automations := attrView.GetTriggeredAutomations(keyID, rowID, oldVal, val)
+	for _, automation := range automations {
+		applyAttributeViewAutomationValues(attrView, automation, rowID, now)
+	}
+
 	if err = av.SaveAttributeView(attrView); nil != err {
 		return
 	}
+
+	if 0 < len(automations) {
+		go runAttributeViewAutomations(attrView, automations, rowID, val)
+	}
 	return
 }
 
+// normalizeAttributeViewValue 订正写入的列值，比如清空空值的内容、格式化数值和补全选项。
+func normalizeAttributeViewValue(key *av.Key, val *av.Value) {
+	if av.KeyTypeNumber == val.Type {
+		if nil != val.Number && !val.Number.IsNotEmpty {
+			val.Number.Content = 0
+			val.Number.FormattedContent = ""
+		}
+	} else if av.KeyTypeDate == val.Type {
+		if nil != val.Date && !val.Date.IsNotEmpty {
+			val.Date.Content = 0
+			val.Date.FormattedContent = ""
+		}
+	} else if av.IsMeasureKeyType(val.Type) {
+		val.FormatMeasure(key)
+	} else if av.KeyTypeSelect == val.Type || av.KeyTypeMSelect == val.Type {
+		if nil != key && 0 < len(val.MSelect) {
+			// The selection options are inconsistent after pasting data into the database https://github.com/siyuan-note/siyuan/issues/11409
+			for _, valOpt := range val.MSelect {
+				if opt := key.GetOption(valOpt.Content); nil == opt {
+					// 不存在的选项新建保存
+					opt = &av.SelectOption{Name: valOpt.Content, Color: valOpt.Color}
+					key.Options = append(key.Options, opt)
+				} else {
+					// 已经存在的选项颜色需要保持不变
+					valOpt.Color = opt.Color
+				}
+			}
+		}
+	}
+}
+
 func unbindBlockAv(tx *Transaction, avID, blockID string) {
 	node, tree, err := getNodeByBlockID(tx, blockID)
 	if nil != err {
@@ -3250,9 +3672,9 @@ func updateAttributeViewColumnOption(operation *Operation) (err error) {
 	// Database select field filters follow option editing changes https://github.com/siyuan-note/siyuan/issues/10881
 	for _, view := range attrView.Views {
 		switch view.LayoutType {
-		case av.LayoutTypeTable:
+		case av.LayoutTypeTable, av.LayoutTypeGallery:
 			table := view.Table
-			for _, filter := range table.Filters {
+			for _, filter := range table.GetAllFilters() {
 				if filter.Column != key.ID {
 					continue
 				}
@@ -3400,3 +3822,752 @@ func updateBoundBlockAvsAttribute(avIDs []string) {
 		av.BatchUpsertBlockRel(avNodes)
 	}
 }
+
+func RenderAttributeViewCalendar(avID, viewID, keyID, endKeyID string, rangeType av.CalendarRange, anchor int64, weekStart int, role string) (ret *av.Calendar, err error) {
+	waitForSyncingStorages()
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+	if attrView.IsViewHidden(viewID, role) || attrView.IsKeyHidden(keyID, role) || attrView.IsKeyHidden(endKeyID, role) {
+		err = av.ErrAccessDenied
+		return
+	}
+
+	key, _ := attrView.GetKey(keyID)
+	if nil == key || av.KeyTypeDate != key.Type {
+		err = av.ErrKeyNotFound
+		return
+	}
+	if "" != endKeyID {
+		if endKey, _ := attrView.GetKey(endKeyID); nil == endKey || av.KeyTypeDate != endKey.Type {
+			err = av.ErrKeyNotFound
+			return
+		}
+	}
+
+	// 日历需要按范围展示所有行，这里不分页
+	viewable, err := renderAttributeView(attrView, viewID, "", 1, math.MaxInt32)
+	if nil != err {
+		return
+	}
+
+	table, ok := viewable.(*av.Table)
+	if !ok {
+		err = av.ErrViewNotFound
+		return
+	}
+
+	anchorTime := time.Now()
+	if 0 < anchor {
+		anchorTime = time.UnixMilli(anchor)
+	}
+	start, end := av.GetCalendarRange(anchorTime, rangeType, weekStart)
+	ret = table.BuildCalendar(keyID, endKeyID, start, end)
+	return
+}
+
+func (tx *Transaction) doRescheduleAttrViewRow(operation *Operation) (ret *TxErr) {
+	err := rescheduleAttributeViewRow(operation, tx)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// rescheduleAttributeViewRow 用于日历视图中拖拽调整行的日期。
+//
+// operation.Data 包含 start 和可选的 end、endKeyID：endKeyID 为空时 end 写入日期列自身的结束时间，否则写入 endKeyID 对应的日期列。
+func rescheduleAttributeViewRow(operation *Operation, tx *Transaction) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	data, ok := operation.Data.(map[string]interface{})
+	if !ok {
+		err = errors.New("invalid reschedule data")
+		return
+	}
+
+	var start, end int64
+	if v, ok := data["start"].(float64); ok {
+		start = int64(v)
+	}
+	if v, ok := data["end"].(float64); ok {
+		end = int64(v)
+	}
+	endKeyID, _ := data["endKeyID"].(string)
+	if 0 == start {
+		err = errors.New("invalid reschedule start")
+		return
+	}
+
+	dateVal := attrView.GetValue(operation.KeyID, operation.RowID)
+	isNotTime := false
+	valueID := ast.NewNodeID()
+	if nil != dateVal {
+		valueID = dateVal.ID
+		if nil != dateVal.Date {
+			isNotTime = dateVal.Date.IsNotTime
+		}
+	}
+
+	date := &av.ValueDate{Content: start, IsNotEmpty: true, IsNotTime: isNotTime}
+	if "" == endKeyID && 0 < end {
+		date.HasEndDate = true
+		date.Content2 = end
+		date.IsNotEmpty2 = true
+	}
+	if err = UpdateAttributeViewCell(tx, operation.AvID, operation.KeyID, operation.RowID, valueID, map[string]interface{}{"date": date}); nil != err {
+		return
+	}
+
+	if "" != endKeyID && 0 < end {
+		endValueID := ast.NewNodeID()
+		if endVal := attrView.GetValue(endKeyID, operation.RowID); nil != endVal {
+			endValueID = endVal.ID
+		}
+		endDate := &av.ValueDate{Content: end, IsNotEmpty: true, IsNotTime: isNotTime}
+		if err = UpdateAttributeViewCell(tx, operation.AvID, endKeyID, operation.RowID, endValueID, map[string]interface{}{"date": endDate}); nil != err {
+			return
+		}
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewRowTemplate(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewRowTemplate(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewRowTemplate(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if nil != err {
+		return
+	}
+
+	if nil == operation.Data {
+		view.RowTemplate = nil
+	} else {
+		data, marshalErr := gulu.JSON.MarshalJSON(operation.Data)
+		if nil != marshalErr {
+			return marshalErr
+		}
+
+		rowTemplate := &av.RowTemplate{}
+		if err = gulu.JSON.UnmarshalJSON(data, rowTemplate); nil != err {
+			return
+		}
+
+		var values []*av.Value
+		for _, v := range rowTemplate.Values {
+			key, _ := attrView.GetKey(v.KeyID)
+			if nil == key || !isRowTemplateKeyType(key.Type) {
+				continue
+			}
+			v.Type = key.Type
+			values = append(values, v)
+		}
+		rowTemplate.Values = values
+		view.RowTemplate = rowTemplate
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// isRowTemplateKeyType 判断列类型是否支持通过行模板预填，自动计算的列不支持。
+func isRowTemplateKeyType(keyType av.KeyType) bool {
+	switch keyType {
+	case av.KeyTypeBlock, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		return false
+	}
+	return true
+}
+
+// applyAttributeViewRowTemplate 将行模板应用到新添加的行上。
+//
+// 已经有值的列（比如通过过滤条件填充的列）不会被覆盖；内容模板仅应用于内容为空的绑定文档块。
+func applyAttributeViewRowTemplate(attrView *av.AttributeView, rowTemplate *av.RowTemplate, blockID string, isDetached bool, now int64, node *ast.Node, tree *parse.Tree) {
+	for _, tplVal := range rowTemplate.Values {
+		keyValues, _ := attrView.GetKeyValues(tplVal.KeyID)
+		if nil == keyValues || !isRowTemplateKeyType(keyValues.Key.Type) || nil != keyValues.GetValue(blockID) {
+			continue
+		}
+
+		val := tplVal.Clone()
+		val.ID = ast.NewNodeID()
+		val.KeyID = keyValues.Key.ID
+		val.BlockID = blockID
+		val.Type = keyValues.Key.Type
+		val.IsDetached = isDetached
+		val.CreatedAt = now
+		val.UpdatedAt = now + 1000
+		keyValues.Values = append(keyValues.Values, val)
+	}
+
+	if isDetached || nil == node || ast.NodeDocument != node.Type || "" == strings.TrimSpace(rowTemplate.Content) {
+		return
+	}
+	if nil != node.FirstChild && (nil != node.FirstChild.Next || "" != strings.TrimSpace(node.FirstChild.Text())) {
+		// 文档已经有内容
+		return
+	}
+
+	goTpl := template.New("").Delims(".action{", "}")
+	tplFuncMap := util.BuiltInTemplateFuncs()
+	sql.SQLTemplateFuncs(&tplFuncMap)
+	tpl, err := goTpl.Funcs(tplFuncMap).Parse(rowTemplate.Content)
+	if nil != err {
+		logging.LogWarnf("parse attribute view [%s] row template failed: %s", attrView.ID, err)
+		return
+	}
+
+	dataModel := map[string]string{
+		"id":       node.ID,
+		"title":    node.IALAttr("title"),
+		"avID":     attrView.ID,
+		"avName":   attrView.Name,
+		"datetime": time.UnixMilli(now).Format("2006-01-02 15:04:05"),
+	}
+	buf := &bytes.Buffer{}
+	if err = tpl.Execute(buf, dataModel); nil != err {
+		logging.LogWarnf("render attribute view [%s] row template failed: %s", attrView.ID, err)
+		return
+	}
+
+	contentTree := parseKTree(buf.Bytes())
+	if nil == contentTree || nil == contentTree.Root.FirstChild {
+		return
+	}
+
+	for c := node.FirstChild; nil != c; {
+		next := c.Next
+		c.Unlink()
+		c = next
+	}
+	for c := contentTree.Root.FirstChild; nil != c; {
+		next := c.Next
+		node.AppendChild(c)
+		c = next
+	}
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && "" != n.ID {
+			n.Box, n.Path = tree.Box, tree.Path
+		}
+		return ast.WalkContinue
+	})
+}
+
+func (tx *Transaction) doSetAttrViewAutomations(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewAutomations(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewAutomations(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+
+	var automations []*av.Automation
+	if err = gulu.JSON.UnmarshalJSON(data, &automations); nil != err {
+		return
+	}
+
+	for _, automation := range automations {
+		if "" == automation.ID {
+			automation.ID = ast.NewNodeID()
+		}
+		if nil == automation.Trigger {
+			continue
+		}
+
+		key, _ := attrView.GetKey(automation.Trigger.KeyID)
+		if nil == key {
+			err = av.ErrKeyNotFound
+			return
+		}
+		if nil == automation.Trigger.Value {
+			automation.Trigger.Value = &av.Value{Type: key.Type}
+		}
+		automation.Trigger.Value.Type = key.Type
+	}
+	attrView.Automations = automations
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// applyAttributeViewAutomationValues 执行自动化规则中设置列值的动作，在保存属性视图前同步执行。
+func applyAttributeViewAutomationValues(attrView *av.AttributeView, automation *av.Automation, rowID string, now int64) {
+	for _, action := range automation.Actions {
+		if av.AutomationActionSetValue != action.Type || nil == action.Value {
+			continue
+		}
+
+		keyValues, _ := attrView.GetKeyValues(action.KeyID)
+		if nil == keyValues || !isRowTemplateKeyType(keyValues.Key.Type) {
+			continue
+		}
+
+		newVal := action.Value.Clone()
+		newVal.KeyID = keyValues.Key.ID
+		newVal.BlockID = rowID
+		newVal.Type = keyValues.Key.Type
+		if val := keyValues.GetValue(rowID); nil != val {
+			newVal.ID = val.ID
+			newVal.IsDetached = val.IsDetached
+			newVal.CreatedAt = val.CreatedAt
+			*val = *newVal
+			val.SetUpdatedAt(now)
+		} else {
+			newVal.ID = ast.NewNodeID()
+			newVal.CreatedAt = now
+			newVal.UpdatedAt = now
+			keyValues.Values = append(keyValues.Values, newVal)
+		}
+	}
+}
+
+// runAttributeViewAutomations 执行自动化规则中作用于绑定块或者外部的动作。
+func runAttributeViewAutomations(attrView *av.AttributeView, automations []*av.Automation, rowID string, val *av.Value) {
+	blockVal := attrView.GetValue(attrView.GetBlockKey().ID, rowID)
+	isDetached := nil == blockVal || blockVal.IsDetached
+
+	for _, automation := range automations {
+		for _, action := range automation.Actions {
+			var err error
+			switch action.Type {
+			case av.AutomationActionAddTag:
+				if !isDetached {
+					err = addAutomationTag(rowID, action.Tag)
+				}
+			case av.AutomationActionMoveDoc:
+				if !isDetached {
+					err = moveAutomationDoc(rowID, action.Notebook, action.ParentID)
+				}
+			case av.AutomationActionWebhook:
+				err = postAutomationWebhook(action.URL, attrView, automation, rowID, val)
+			}
+			if nil != err {
+				logging.LogErrorf("run attribute view [%s] automation [%s] action [%s] failed: %s", attrView.ID, automation.ID, action.Type, err)
+			}
+		}
+	}
+}
+
+// addAutomationTag 为绑定块添加标签，修改通过事务队列写入，避免和编辑器的事务并发写入同一棵树。
+func addAutomationTag(blockID, tag string) (err error) {
+	tag = strings.TrimSpace(strings.Trim(tag, "#"))
+	if "" == tag {
+		return
+	}
+
+	tree, err := LoadTreeByBlockID(blockID)
+	if nil != err {
+		return
+	}
+
+	node := treenode.GetNodeInTree(tree, blockID)
+	if nil == node {
+		return ErrBlockNotFound
+	}
+
+	var doOp *Operation
+	if ast.NodeDocument == node.Type {
+		tags := strings.Split(node.IALAttr("tags"), ",")
+		tags = append(tags, tag)
+		tags = gulu.Str.RemoveDuplicatedElem(gulu.Str.ExcludeElem(tags, []string{""}))
+		data, _ := gulu.JSON.MarshalJSON(map[string]string{"tags": strings.Join(tags, ",")})
+		doOp = &Operation{Action: "setAttrs", ID: blockID, Data: string(data)}
+		return performAutomationTransaction(doOp)
+	}
+
+	// 非文档块在第一个段落或者标题末尾追加行级标签
+	var target *ast.Node
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && (ast.NodeParagraph == n.Type || ast.NodeHeading == n.Type) {
+			target = n
+			return ast.WalkStop
+		}
+		return ast.WalkContinue
+	})
+	if nil == target {
+		return
+	}
+
+	target.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(" ")})
+	target.AppendChild(&ast.Node{Type: ast.NodeTextMark, TextMarkType: "tag", TextMarkTextContent: tag})
+	refreshUpdated(target)
+	doOp = &Operation{Action: "update", ID: node.ID, Data: util.NewLute().RenderNodeBlockDOM(node)}
+	return performAutomationTransaction(doOp)
+}
+
+func performAutomationTransaction(doOp *Operation) (err error) {
+	transaction := &Transaction{DoOperations: []*Operation{doOp}}
+	PerformTransactions(&[]*Transaction{transaction})
+	transaction.WaitForFlush()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = []*Transaction{transaction}
+	util.PushEvent(evt)
+	return
+}
+
+func moveAutomationDoc(blockID, notebook, parentID string) (err error) {
+	bt := treenode.GetBlockTree(blockID)
+	if nil == bt {
+		return ErrBlockNotFound
+	}
+
+	toPath := "/"
+	if "" != parentID {
+		parent := treenode.GetBlockTree(parentID)
+		if nil == parent {
+			return ErrBlockNotFound
+		}
+		notebook = parent.BoxID
+		toPath = parent.Path
+	}
+	if "" == notebook {
+		notebook = bt.BoxID
+	}
+	return MoveDocs([]string{bt.Path}, notebook, toPath, nil)
+}
+
+func postAutomationWebhook(url string, attrView *av.AttributeView, automation *av.Automation, rowID string, val *av.Value) (err error) {
+	if "" == strings.TrimSpace(url) {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"avID":         attrView.ID,
+		"avName":       attrView.Name,
+		"automationID": automation.ID,
+		"automation":   automation.Name,
+		"rowID":        rowID,
+		"keyID":        automation.Trigger.KeyID,
+		"value":        val,
+	}
+	resp, err := httpclient.NewBrowserRequest().SetBody(payload).Post(url)
+	if nil != err {
+		return
+	}
+	if 200 > resp.StatusCode || 300 <= resp.StatusCode {
+		err = fmt.Errorf("webhook [%s] responded with status code [%d]", url, resp.StatusCode)
+	}
+	return
+}
+
+// BulkUpdateAttributeViewCells 将视图过滤后的所有行的列值设置为 value，或者使用 formula 模板按行计算列值。
+//
+// dryRun 为 true 时仅返回会被修改的行，不写入数据。所有行的列值校验通过后才会在同一个事务中写入。
+func BulkUpdateAttributeViewCells(avID, viewID, keyID string, value interface{}, formula string, dryRun bool) (rowIDs []string, err error) {
+	waitForSyncingStorages()
+
+	renderAttrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	key, err := renderAttrView.GetKey(keyID)
+	if nil != err {
+		return
+	}
+
+	switch key.Type {
+	case av.KeyTypeBlock, av.KeyTypeRelation, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		err = fmt.Errorf("column type [%s] does not support bulk update", key.Type)
+		return
+	}
+
+	if nil == value && "" == strings.TrimSpace(formula) {
+		err = errors.New("value and formula are both empty")
+		return
+	}
+
+	viewable, err := renderAttributeView(renderAttrView, viewID, "", 1, math.MaxInt32)
+	if nil != err {
+		return
+	}
+	table, ok := viewable.(*av.Table)
+	if !ok {
+		err = av.ErrViewNotFound
+		return
+	}
+
+	rowIDs = []string{}
+	for _, row := range table.Rows {
+		rowIDs = append(rowIDs, row.ID)
+	}
+	if dryRun || 1 > len(rowIDs) {
+		return
+	}
+
+	var valueData []byte
+	if nil != value {
+		if valueData, err = gulu.JSON.MarshalJSON(value); nil != err {
+			return
+		}
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+	keyValues, err := attrView.GetKeyValues(keyID)
+	if nil != err {
+		return
+	}
+	key = keyValues.Key
+
+	type changedValue struct {
+		rowID  string
+		oldVal *av.Value
+		val    *av.Value
+	}
+	var changes []*changedValue
+	for _, row := range table.Rows {
+		var val *av.Value
+		for _, v := range keyValues.Values {
+			if v.BlockID == row.ID {
+				val = v
+				break
+			}
+		}
+
+		isNew := nil == val
+		if isNew {
+			val = av.GetAttributeViewDefaultValue(ast.NewNodeID(), keyID, row.ID, key.Type)
+			if blockVal := row.GetBlockValue(); nil != blockVal {
+				val.IsDetached = blockVal.IsDetached
+			}
+		}
+		oldVal := val.Clone()
+
+		if "" != strings.TrimSpace(formula) {
+			var rowValues []*av.KeyValues
+			for i, cell := range row.Cells {
+				if colKey, _ := renderAttrView.GetKey(table.Columns[i].ID); nil != colKey && nil != cell.Value {
+					rowValues = append(rowValues, &av.KeyValues{Key: colKey, Values: []*av.Value{cell.Value}})
+				}
+			}
+			ial := map[string]string{}
+			if blockVal := row.GetBlockValue(); nil != blockVal && !blockVal.IsDetached {
+				ial = GetBlockAttrsWithoutWaitWriting(row.ID)
+			}
+
+			content, renderErr := sql.RenderTemplateCol(ial, rowValues, formula)
+			if nil != renderErr {
+				err = fmt.Errorf("render formula for row [%s] failed: %s", row.ID, renderErr)
+				return
+			}
+			if !val.SetValueByString(key, content) {
+				err = fmt.Errorf("column type [%s] does not support formula", key.Type)
+				return
+			}
+		} else {
+			// 使用克隆值反序列化，避免多行共享同一个值对象
+			newVal := val.Clone()
+			if err = gulu.JSON.UnmarshalJSON(valueData, newVal); nil != err {
+				return
+			}
+			newVal.ID, newVal.KeyID, newVal.BlockID, newVal.Type, newVal.IsDetached = val.ID, keyID, row.ID, key.Type, val.IsDetached
+			newVal.CreatedAt, newVal.UpdatedAt = val.CreatedAt, val.UpdatedAt
+			*val = *newVal
+		}
+
+		normalizeAttributeViewValue(key, val)
+		if isNew {
+			keyValues.Values = append(keyValues.Values, val)
+		}
+		changes = append(changes, &changedValue{rowID: row.ID, oldVal: oldVal, val: val})
+	}
+
+	// 全部写入内存后再校验，唯一约束可以检查到本次批量修改的其他行
+	for _, change := range changes {
+		if err = attrView.ValidateValue(key, change.val); nil != err {
+			return
+		}
+	}
+
+	// 校验通过后将所有行的修改放到同一个事务中写入，列值的写入和自动化规则的执行由 updateAttrViewCell 完成
+	transaction := &Transaction{}
+	blockKey := attrView.GetBlockKey()
+	for _, change := range changes {
+		// 非主键的值不会改变行的绑定状态，这里和行的绑定状态保持一致
+		if blockVal := attrView.GetValue(blockKey.ID, change.rowID); nil != blockVal {
+			change.val.IsDetached, change.oldVal.IsDetached = blockVal.IsDetached, blockVal.IsDetached
+		}
+		transaction.DoOperations = append(transaction.DoOperations, &Operation{Action: "updateAttrViewCell", AvID: avID, KeyID: keyID, RowID: change.rowID, ID: change.val.ID, Data: change.val})
+		transaction.UndoOperations = append(transaction.UndoOperations, &Operation{Action: "updateAttrViewCell", AvID: avID, KeyID: keyID, RowID: change.rowID, ID: change.val.ID, Data: change.oldVal})
+	}
+	PerformTransactions(&[]*Transaction{transaction})
+	if err = transaction.WaitForFlush(); nil != err {
+		// 事务执行失败（已经回滚）或者等待超时时返回错误，不推送重新加载
+		return
+	}
+	util.PushReloadAttrView(avID)
+	return
+}
+
+func RenderAttributeViewGallery(avID, viewID, query string, page, pageSize int, role string) (ret *av.Gallery, err error) {
+	waitForSyncingStorages()
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	view := attrView.GetView(viewID)
+	if "" == viewID {
+		view = attrView.GetView(attrView.ViewID)
+	}
+	if nil == view || av.LayoutTypeGallery != view.LayoutType {
+		err = av.ErrViewNotFound
+		return
+	}
+	if view.Access.IsHidden(role) {
+		err = av.ErrAccessDenied
+		return
+	}
+	if nil == view.Gallery {
+		view.Gallery = av.NewLayoutGallery()
+	}
+
+	// 先过滤、排序和分页，只为当前页的卡片查找封面
+	viewable, err := renderAttributeView(attrView, view.ID, query, page, pageSize)
+	if nil != err {
+		return
+	}
+
+	table, ok := viewable.(*av.Table)
+	if !ok {
+		err = av.ErrViewNotFound
+		return
+	}
+
+	table.RemoveHiddenColumns(attrView, role)
+	ret = table.BuildGallery(view.Gallery, getBlocksContentCover)
+	return
+}
+
+// getBlocksContentCover 返回每个块内容中第一张图片的地址，同一文档中的块只加载一次文档树。
+func getBlocksContentCover(blockIDs []string) (ret map[string]string) {
+	ret = map[string]string{}
+	trees := map[string]*parse.Tree{}
+	for _, blockID := range blockIDs {
+		bt := treenode.GetBlockTree(blockID)
+		if nil == bt {
+			continue
+		}
+
+		tree := trees[bt.RootID]
+		if nil == tree {
+			var loadErr error
+			tree, loadErr = LoadTreeByBlockID(bt.RootID)
+			if nil != loadErr {
+				continue
+			}
+			trees[bt.RootID] = tree
+		}
+
+		node := treenode.GetNodeInTree(tree, blockID)
+		if nil == node {
+			continue
+		}
+
+		ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || ast.NodeImage != n.Type {
+				return ast.WalkContinue
+			}
+
+			if dest := n.ChildByType(ast.NodeLinkDest); nil != dest && 0 < len(dest.Tokens) {
+				ret[blockID] = string(dest.Tokens)
+				return ast.WalkStop
+			}
+			return ast.WalkContinue
+		})
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewGallery(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewGallery(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// setAttributeViewGallery 用于设置卡片视图的封面、卡片尺寸和显示字段，operation.Data 为 av.LayoutGallery。
+func setAttributeViewGallery(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if nil != err {
+		return
+	}
+	if av.LayoutTypeGallery != view.LayoutType {
+		err = av.ErrViewNotFound
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+	gallery := av.NewLayoutGallery()
+	if err = gulu.JSON.UnmarshalJSON(data, gallery); nil != err {
+		return
+	}
+
+	if av.CoverFromAssetField == gallery.CoverFrom {
+		if key, _ := attrView.GetKey(gallery.CoverFromAssetKeyID); nil == key || av.KeyTypeMAsset != key.Type {
+			err = av.ErrKeyNotFound
+			return
+		}
+	} else {
+		gallery.CoverFromAssetKeyID = ""
+	}
+
+	var fieldIDs []string
+	for _, fieldID := range gallery.FieldIDs {
+		if key, _ := attrView.GetKey(fieldID); nil != key && !gulu.Str.Contains(fieldID, fieldIDs) {
+			fieldIDs = append(fieldIDs, fieldID)
+		}
+	}
+	gallery.FieldIDs = fieldIDs
+	if nil == gallery.FieldIDs {
+		gallery.FieldIDs = []string{}
+	}
+	gallery.CardSize = gallery.GetCardSize()
+	view.Gallery = gallery
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
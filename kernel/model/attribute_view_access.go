@@ -0,0 +1,129 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// CheckTransactionsAccess 检查该角色是否可以执行事务中的属性视图操作，其他操作不涉及列和视图的访问限制。
+func CheckTransactionsAccess(transactions []*Transaction, role string) (err error) {
+	if "" == role {
+		return
+	}
+
+	for _, transaction := range transactions {
+		for _, operation := range transaction.DoOperations {
+			if err = checkOperationAccess(operation, role); nil != err {
+				return
+			}
+		}
+	}
+	return
+}
+
+func checkOperationAccess(operation *Operation, role string) (err error) {
+	if !strings.Contains(operation.Action, "AttrView") {
+		return
+	}
+
+	avID := operation.AvID
+	if "" == avID {
+		avID = operation.ID // setAttrViewName 等操作的 ID 是属性视图 ID
+	}
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		if errors.Is(err, av.ErrViewNotFound) {
+			return nil // 新建的属性视图还没有设置访问限制
+		}
+		return av.ErrAccessDenied
+	}
+
+	if "" != operation.BlockID {
+		if view, _ := getAttrViewViewByBlockID(attrView, operation.BlockID); nil != view {
+			if err = attrView.CheckWritable(view.ID, "", role); nil != err {
+				return
+			}
+		}
+	}
+
+	// 操作的 ID 可能是列 ID 也可能是视图 ID，CheckWritable 会分别检查
+	for _, id := range []string{operation.ID, operation.KeyID, operation.BackRelationKeyID} {
+		if "" == id {
+			continue
+		}
+		if err = attrView.CheckWritable(id, id, role); nil != err {
+			return
+		}
+	}
+
+	switch operation.Action {
+	case "removeAttrViewBlock", "replaceAttrViewBlock", "unbindAttrViewBlock", "syncAttrViewDataSource":
+		if err = attrView.CheckRowsWritable(role); nil != err {
+			return
+		}
+	}
+
+	// 操作数据中引用了隐藏列时拒绝，避免通过筛选、排序、汇总等方式推断隐藏列的值
+	data, _ := gulu.JSON.MarshalJSON(operation.Data)
+	for _, kv := range attrView.KeyValues {
+		if nil != kv.Key && kv.Key.Access.IsHidden(role) && bytes.Contains(data, []byte(kv.Key.ID)) {
+			return av.ErrAccessDenied
+		}
+	}
+	return
+}
+
+// RoleHiddenKeyIDs 返回所有属性视图中对该角色隐藏的列 ID，带角色的调用方通过 /api/query/sql 查询不到这些列的单元格，见 sql.QueryRole。
+func RoleHiddenKeyIDs(role string) (ret []string) {
+	if "" == role {
+		return
+	}
+
+	avDir := filepath.Join(util.DataDir, "storage", "av")
+	entries, err := os.ReadDir(avDir)
+	if nil != err && !os.IsNotExist(err) {
+		logging.LogErrorf("read directory [%s] failed: %s", avDir, err)
+	}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ast.IsNodeIDPattern(id) {
+			continue
+		}
+
+		attrView, parseErr := av.ParseAttributeView(id)
+		if nil != parseErr {
+			continue
+		}
+		for _, kv := range attrView.KeyValues {
+			if nil != kv.Key && kv.Key.Access.IsHidden(role) {
+				ret = append(ret, kv.Key.ID)
+			}
+		}
+	}
+	return
+}
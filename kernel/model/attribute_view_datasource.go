@@ -0,0 +1,558 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var (
+	attrViewDataSourceLock        = sync.Mutex{}
+	attrViewDataSourceSecretsLock = sync.Mutex{}
+)
+
+// SyncAttributeViewDataSourcesJob 刷新到期的属性视图外部数据源。
+func SyncAttributeViewDataSourcesJob() {
+	if !util.IsBooted() {
+		return
+	}
+
+	avDir := filepath.Join(util.DataDir, "storage", "av")
+	entries, err := os.ReadDir(avDir)
+	if nil != err {
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+
+		data, readErr := filelock.ReadFile(filepath.Join(avDir, entry.Name()))
+		if nil != readErr || !bytes.Contains(data, []byte("\"dataSource\"")) {
+			continue
+		}
+
+		attrView, parseErr := av.ParseAttributeView(avID)
+		if nil != parseErr || nil == attrView.DataSource || !attrView.DataSource.IsDue(now) {
+			continue
+		}
+
+		if syncErr := SyncAttributeViewDataSource(avID); nil != syncErr {
+			logging.LogWarnf("sync attribute view [%s] data source failed: %s", avID, syncErr)
+		}
+	}
+}
+
+func (tx *Transaction) doSetAttrViewDataSource(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewDataSource(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewDataSource(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	if nil == operation.Data {
+		// 解除绑定，保留已经同步的行
+		attrView.DataSource = nil
+		removeAttributeViewDataSourceSecret(operation.AvID)
+		err = av.SaveAttributeView(attrView)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+	dataSource := &av.DataSource{}
+	if err = gulu.JSON.UnmarshalJSON(data, dataSource); nil != err {
+		return
+	}
+
+	switch dataSource.Type {
+	case av.DataSourceTypeCSV, av.DataSourceTypeJSON:
+		if "" == strings.TrimSpace(dataSource.URL) {
+			return errors.New("data source url is empty")
+		}
+	case av.DataSourceTypeAirtable:
+		if "" == dataSource.AirtableBaseID || "" == dataSource.AirtableTable {
+			return errors.New("airtable base or table is empty")
+		}
+	default:
+		return fmt.Errorf("invalid data source type [%s]", dataSource.Type)
+	}
+	if "" == dataSource.KeyField {
+		return errors.New("data source key field is empty")
+	}
+
+	var mappings []*av.DataSourceMapping
+	for _, mapping := range dataSource.Mappings {
+		if key, _ := attrView.GetKey(mapping.KeyID); nil != key && "" != mapping.Field {
+			mappings = append(mappings, mapping)
+		}
+	}
+	dataSource.Mappings = mappings
+
+	if old := attrView.DataSource; nil != old {
+		// 保留已经同步的行和本地编辑标记
+		dataSource.Rows = old.Rows
+		dataSource.EditedValues = old.EditedValues
+		dataSource.LastSynced = old.LastSynced
+	}
+	dataSource.LastError = ""
+	moveAttributeViewDataSourceSecret(operation.AvID, dataSource)
+	attrView.DataSource = dataSource
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// moveAttributeViewDataSourceSecret 将数据源中的访问令牌和请求头移到配置中，属性视图 JSON 中不保存凭据。
+//
+// 访问令牌为空或者为掩码时保留已有的令牌，请求头为 nil 时保留已有的请求头，请求头的值为掩码时保留该请求头已有的值。
+func moveAttributeViewDataSourceSecret(avID string, dataSource *av.DataSource) {
+	token, headers := strings.TrimSpace(dataSource.AirtableToken), dataSource.Headers
+	dataSource.AirtableToken = ""
+	dataSource.Headers = nil
+	if ("" == token || MaskedAccessAuthCode == token) && nil == headers {
+		return
+	}
+
+	attrViewDataSourceSecretsLock.Lock()
+	defer attrViewDataSourceSecretsLock.Unlock()
+
+	secret := Conf.DataSource.Secrets[avID]
+	if nil == secret {
+		secret = &conf.DataSourceSecret{}
+	}
+	if "" != token && MaskedAccessAuthCode != token {
+		secret.AirtableToken = token
+	}
+	if nil != headers {
+		newHeaders := map[string]string{}
+		for k, v := range headers {
+			if MaskedAccessAuthCode == v {
+				v = secret.Headers[k]
+			}
+			newHeaders[k] = v
+		}
+		secret.Headers = newHeaders
+	}
+
+	if "" == secret.AirtableToken && 1 > len(secret.Headers) {
+		delete(Conf.DataSource.Secrets, avID)
+	} else {
+		Conf.DataSource.Secrets[avID] = secret
+	}
+	Conf.Save()
+}
+
+func removeAttributeViewDataSourceSecret(avID string) {
+	attrViewDataSourceSecretsLock.Lock()
+	defer attrViewDataSourceSecretsLock.Unlock()
+
+	if _, ok := Conf.DataSource.Secrets[avID]; ok {
+		delete(Conf.DataSource.Secrets, avID)
+		Conf.Save()
+	}
+}
+
+// getAttributeViewDataSourceSecret 返回数据源访问凭据的副本，旧版本保存在属性视图 JSON 中的凭据在同步时迁移到配置中。
+func getAttributeViewDataSourceSecret(avID string, dataSource *av.DataSource) (ret *conf.DataSourceSecret) {
+	attrViewDataSourceSecretsLock.Lock()
+	defer attrViewDataSourceSecretsLock.Unlock()
+
+	ret = &conf.DataSourceSecret{AirtableToken: dataSource.AirtableToken, Headers: map[string]string{}}
+	for k, v := range dataSource.Headers {
+		ret.Headers[k] = v
+	}
+	if secret := Conf.DataSource.Secrets[avID]; nil != secret {
+		if "" != secret.AirtableToken {
+			ret.AirtableToken = secret.AirtableToken
+		}
+		for k, v := range secret.Headers {
+			ret.Headers[k] = v
+		}
+	}
+	return
+}
+
+// SyncAttributeViewDataSource 从外部数据源拉取记录并按键字段更新属性视图的行。
+//
+// 拉取记录在事务队列外进行，拉取结果通过事务写入属性视图，避免和并发的事务操作互相覆盖。
+func SyncAttributeViewDataSource(avID string) (err error) {
+	attrViewDataSourceLock.Lock()
+	defer attrViewDataSourceLock.Unlock()
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	dataSource := attrView.DataSource
+	if nil == dataSource {
+		return errors.New("attribute view has no data source")
+	}
+
+	result := &dataSourceSyncResult{}
+	result.Records, err = fetchAttributeViewDataSourceRecords(dataSource, getAttributeViewDataSourceSecret(avID, dataSource))
+	if nil != err {
+		result.Err = err.Error()
+	}
+
+	transaction := &Transaction{DoOperations: []*Operation{{Action: "syncAttrViewDataSource", AvID: avID, Data: result}}}
+	PerformTransactions(&[]*Transaction{transaction})
+	transaction.WaitForFlush()
+	if nil != err {
+		return
+	}
+
+	util.PushReloadAttrView(avID)
+	return
+}
+
+// dataSourceSyncResult 是外部数据源的拉取结果，通过 syncAttrViewDataSource 操作写入属性视图。
+type dataSourceSyncResult struct {
+	Records []map[string]string `json:"records"`
+	Err     string              `json:"err"` // 拉取失败时的错误
+}
+
+func (tx *Transaction) doSyncAttrViewDataSource(operation *Operation) (ret *TxErr) {
+	err := syncAttributeViewDataSource(operation)
+	if nil != err {
+		return &TxErr{code: TxErrInvalidAttrViewOp, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func syncAttributeViewDataSource(operation *Operation) (err error) {
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+	result := &dataSourceSyncResult{}
+	if err = gulu.JSON.UnmarshalJSON(data, result); nil != err {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+	dataSource := attrView.DataSource
+	if nil == dataSource {
+		// 拉取期间解除了绑定
+		return
+	}
+
+	moveAttributeViewDataSourceSecret(operation.AvID, dataSource)
+	dataSource.LastSynced = time.Now().UnixMilli()
+	dataSource.LastError = result.Err
+	if "" == result.Err {
+		upsertAttributeViewDataSourceRecords(attrView, result.Records)
+	}
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func upsertAttributeViewDataSourceRecords(attrView *av.AttributeView, records []map[string]string) {
+	dataSource := attrView.DataSource
+	if nil == dataSource.Rows {
+		dataSource.Rows = map[string]string{}
+	}
+
+	now := util.CurrentTimeMillis()
+	blockValues := attrView.GetBlockKeyValues()
+	for _, record := range records {
+		recordKey := strings.TrimSpace(record[dataSource.KeyField])
+		if "" == recordKey {
+			continue
+		}
+
+		rowID := dataSource.Rows[recordKey]
+		if "" == rowID || !attrView.ExistBlock(rowID) {
+			// 外部新增的记录作为游离行添加
+			rowID = ast.NewNodeID()
+			dataSource.Rows[recordKey] = rowID
+			blockValues.Values = append(blockValues.Values, &av.Value{
+				ID:         ast.NewNodeID(),
+				KeyID:      blockValues.Key.ID,
+				BlockID:    rowID,
+				Type:       av.KeyTypeBlock,
+				IsDetached: true,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+				Block:      &av.ValueBlock{ID: rowID, Content: recordKey, Created: now, Updated: now},
+			})
+			for _, view := range attrView.Views {
+				switch view.LayoutType {
+				case av.LayoutTypeTable, av.LayoutTypeGallery:
+					view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+				}
+			}
+		}
+
+		for _, mapping := range dataSource.Mappings {
+			content, ok := record[mapping.Field]
+			if !ok {
+				continue
+			}
+
+			keyValues, _ := attrView.GetKeyValues(mapping.KeyID)
+			if nil == keyValues {
+				continue
+			}
+
+			var val *av.Value
+			for _, v := range keyValues.Values {
+				if v.BlockID == rowID {
+					val = v
+					break
+				}
+			}
+			if nil != val && dataSource.IsEdited(val.ID) {
+				continue
+			}
+
+			if av.KeyTypeBlock == keyValues.Key.Type {
+				if nil != val && nil != val.Block && val.IsDetached && val.Block.Content != content {
+					val.Block.Content = strings.TrimSpace(content)
+					val.Block.Updated = now
+					val.SetUpdatedAt(now)
+				}
+				continue
+			}
+
+			isNew := nil == val
+			if isNew {
+				val = av.GetAttributeViewDefaultValue(ast.NewNodeID(), keyValues.Key.ID, rowID, keyValues.Key.Type)
+				val.IsDetached = true
+			}
+
+			oldContent := val.String(false)
+			if !val.SetValueByString(keyValues.Key, content) {
+				continue
+			}
+			if isNew {
+				keyValues.Values = append(keyValues.Values, val)
+			}
+			if isNew || oldContent != val.String(false) {
+				val.SetUpdatedAt(now)
+			}
+		}
+	}
+}
+
+func fetchAttributeViewDataSourceRecords(dataSource *av.DataSource, secret *conf.DataSourceSecret) (ret []map[string]string, err error) {
+	switch dataSource.Type {
+	case av.DataSourceTypeCSV:
+		return fetchAttributeViewDataSourceCSV(dataSource, secret)
+	case av.DataSourceTypeJSON:
+		return fetchAttributeViewDataSourceJSON(dataSource, secret)
+	case av.DataSourceTypeAirtable:
+		return fetchAttributeViewDataSourceAirtable(dataSource, secret)
+	}
+	err = fmt.Errorf("invalid data source type [%s]", dataSource.Type)
+	return
+}
+
+func getAttributeViewDataSource(u string, headers map[string]string) (ret []byte, err error) {
+	resp, err := httpclient.NewBrowserRequest().SetHeaders(headers).Get(u)
+	if nil != err {
+		return
+	}
+	if 200 != resp.StatusCode {
+		err = fmt.Errorf("request [%s] responded with status code [%d]", u, resp.StatusCode)
+		return
+	}
+	ret, err = resp.ToBytes()
+	return
+}
+
+func fetchAttributeViewDataSourceCSV(dataSource *av.DataSource, secret *conf.DataSourceSecret) (ret []map[string]string, err error) {
+	data, err := getAttributeViewDataSource(dataSource.URL, secret.Headers)
+	if nil != err {
+		return
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if nil != err {
+		return
+	}
+	if 1 > len(rows) {
+		return
+	}
+
+	header := rows[0]
+	for _, row := range rows[1:] {
+		record := map[string]string{}
+		for i, field := range header {
+			if i < len(row) {
+				record[strings.TrimSpace(field)] = row[i]
+			}
+		}
+		ret = append(ret, record)
+	}
+	return
+}
+
+func fetchAttributeViewDataSourceJSON(dataSource *av.DataSource, secret *conf.DataSourceSecret) (ret []map[string]string, err error) {
+	data, err := getAttributeViewDataSource(dataSource.URL, secret.Headers)
+	if nil != err {
+		return
+	}
+
+	var root interface{}
+	if err = gulu.JSON.UnmarshalJSON(data, &root); nil != err {
+		return
+	}
+
+	if "" != dataSource.Path {
+		for _, part := range strings.Split(dataSource.Path, ".") {
+			m, ok := root.(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("path [%s] not found", dataSource.Path)
+				return
+			}
+			root = m[part]
+		}
+	}
+
+	items, ok := root.([]interface{})
+	if !ok {
+		err = errors.New("records is not an array")
+		return
+	}
+
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ret = append(ret, dataSourceFields2Record(fields))
+	}
+	return
+}
+
+func fetchAttributeViewDataSourceAirtable(dataSource *av.DataSource, secret *conf.DataSourceSecret) (ret []map[string]string, err error) {
+	headers := map[string]string{"Authorization": "Bearer " + secret.AirtableToken}
+	for k, v := range secret.Headers {
+		headers[k] = v
+	}
+
+	offset := ""
+	for i := 0; i < 100; i++ { // 最多拉取 100 页
+		u := "https://api.airtable.com/v0/" + url.PathEscape(dataSource.AirtableBaseID) + "/" + url.PathEscape(dataSource.AirtableTable)
+		if "" != offset {
+			u += "?offset=" + url.QueryEscape(offset)
+		}
+
+		data, getErr := getAttributeViewDataSource(u, headers)
+		if nil != getErr {
+			err = getErr
+			return
+		}
+
+		result := struct {
+			Records []struct {
+				ID     string                 `json:"id"`
+				Fields map[string]interface{} `json:"fields"`
+			} `json:"records"`
+			Offset string `json:"offset"`
+		}{}
+		if err = gulu.JSON.UnmarshalJSON(data, &result); nil != err {
+			return
+		}
+
+		for _, r := range result.Records {
+			record := dataSourceFields2Record(r.Fields)
+			if _, ok := record["id"]; !ok {
+				record["id"] = r.ID
+			}
+			ret = append(ret, record)
+		}
+
+		offset = result.Offset
+		if "" == offset {
+			break
+		}
+	}
+	return
+}
+
+func dataSourceFields2Record(fields map[string]interface{}) (ret map[string]string) {
+	ret = map[string]string{}
+	for k, v := range fields {
+		ret[k] = dataSourceField2String(v)
+	}
+	return
+}
+
+func dataSourceField2String(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case []interface{}:
+		var items []string
+		for _, item := range val {
+			items = append(items, dataSourceField2String(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		data, _ := gulu.JSON.MarshalJSON(val)
+		return string(data)
+	}
+}
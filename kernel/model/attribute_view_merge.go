@@ -0,0 +1,226 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// MergeConflict 描述了合并属性视图时源和目标中绑定了同一个块的行的处理方式。
+type MergeConflict string
+
+const (
+	MergeConflictSkip      MergeConflict = "skip"      // 保留目标行，忽略源行
+	MergeConflictOverwrite MergeConflict = "overwrite" // 使用源行的值覆盖目标行
+	MergeConflictFillEmpty MergeConflict = "fillEmpty" // 仅使用源行的值填充目标行中的空值
+)
+
+// MergeAttributeViewsResult 描述了合并属性视图的结果。
+type MergeAttributeViewsResult struct {
+	Added     int      `json:"added"`     // 新增的行数
+	Merged    int      `json:"merged"`    // 合并到已有行的行数
+	Skipped   int      `json:"skipped"`   // 跳过的行数
+	Conflicts []string `json:"conflicts"` // 源和目标中绑定了同一个块的行
+	KeyIDs    []string `json:"keyIDs"`    // 在目标中新建的列
+}
+
+// MergeAttributeViews 将源属性视图的行合并到目标属性视图中。
+//
+// keyMapping 指定源列到目标列的映射，目标列 ID 为空时在目标中新建列，未映射的源列被忽略，主键列总是合并到目标主键列。
+func MergeAttributeViews(srcAvID, destAvID string, keyMapping map[string]string, conflict MergeConflict) (ret *MergeAttributeViewsResult, err error) {
+	if srcAvID == destAvID {
+		err = errors.New("can not merge attribute view into itself")
+		return
+	}
+	switch conflict {
+	case "":
+		conflict = MergeConflictSkip
+	case MergeConflictSkip, MergeConflictOverwrite, MergeConflictFillEmpty:
+	default:
+		err = fmt.Errorf("invalid conflict [%s]", conflict)
+		return
+	}
+
+	srcAv, err := av.ParseAttributeView(srcAvID)
+	if nil != err {
+		return
+	}
+	destAv, err := av.ParseAttributeView(destAvID)
+	if nil != err {
+		return
+	}
+
+	ret = &MergeAttributeViewsResult{Conflicts: []string{}, KeyIDs: []string{}}
+
+	// 解析列映射
+	srcBlockKey, destBlockKey := srcAv.GetBlockKey(), destAv.GetBlockKey()
+	if nil == srcBlockKey || nil == destBlockKey {
+		err = av.ErrKeyNotFound
+		return
+	}
+	destKeys := map[string]*av.Key{srcBlockKey.ID: destBlockKey}
+	for srcKeyID, destKeyID := range keyMapping {
+		srcKey, _ := srcAv.GetKey(srcKeyID)
+		if nil == srcKey {
+			err = fmt.Errorf("source key [%s] not found", srcKeyID)
+			return
+		}
+		if av.KeyTypeBlock == srcKey.Type {
+			continue
+		}
+
+		if "" == destKeyID {
+			destKey := mergeAttributeViewNewKey(srcKey)
+			if nil == destKey {
+				continue
+			}
+			destAv.KeyValues = append(destAv.KeyValues, &av.KeyValues{Key: destKey})
+			for _, view := range destAv.Views {
+				if nil != view.Table {
+					view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: destKey.ID})
+				}
+			}
+			destKeys[srcKeyID] = destKey
+			ret.KeyIDs = append(ret.KeyIDs, destKey.ID)
+			continue
+		}
+
+		destKey, _ := destAv.GetKey(destKeyID)
+		if nil == destKey {
+			err = fmt.Errorf("destination key [%s] not found", destKeyID)
+			return
+		}
+		if srcKey.Type != destKey.Type {
+			err = fmt.Errorf("key [%s] type [%s] does not match key [%s] type [%s]", srcKey.Name, srcKey.Type, destKey.Name, destKey.Type)
+			return
+		}
+		destKeys[srcKeyID] = destKey
+	}
+
+	// 合并行
+	now := util.CurrentTimeMillis()
+	destBlockValues := destAv.GetBlockKeyValues()
+	srcBlockValues := srcAv.GetBlockKeyValues()
+	for _, srcBlockValue := range srcBlockValues.Values {
+		rowID := srcBlockValue.BlockID
+		exists := nil != destBlockValues.GetValue(rowID)
+		if exists {
+			ret.Conflicts = append(ret.Conflicts, rowID)
+			if MergeConflictSkip == conflict {
+				ret.Skipped++
+				continue
+			}
+			ret.Merged++
+		} else {
+			ret.Added++
+		}
+
+		for srcKeyID, destKey := range destKeys {
+			if mergeAttributeViewComputedKey(destKey.Type) {
+				continue
+			}
+
+			srcKeyValues, _ := srcAv.GetKeyValues(srcKeyID)
+			destKeyValues, _ := destAv.GetKeyValues(destKey.ID)
+			if nil == srcKeyValues || nil == destKeyValues {
+				continue
+			}
+
+			srcValue := srcKeyValues.GetValue(rowID)
+			if nil == srcValue {
+				continue
+			}
+
+			destValue := destKeyValues.GetValue(rowID)
+			if nil != destValue {
+				if av.KeyTypeBlock == destKey.Type {
+					continue
+				}
+				if MergeConflictFillEmpty == conflict && !destValue.IsEmpty() {
+					continue
+				}
+			}
+
+			newValue := srcValue.Clone()
+			if nil == newValue {
+				continue
+			}
+			newValue.KeyID = destKey.ID
+			newValue.UpdatedAt = now
+			if nil != destValue {
+				newValue.ID = destValue.ID
+				newValue.CreatedAt = destValue.CreatedAt
+				*destValue = *newValue
+				continue
+			}
+
+			newValue.ID = ast.NewNodeID()
+			destKeyValues.Values = append(destKeyValues.Values, newValue)
+		}
+
+		if !exists {
+			for _, view := range destAv.Views {
+				if nil != view.Table && 0 < len(view.Table.RowIDs) {
+					view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+				}
+			}
+		}
+	}
+
+	if err = av.SaveAttributeView(destAv); nil != err {
+		return
+	}
+
+	updateBoundBlockAvsAttribute([]string{destAvID})
+	util.PushReloadAttrView(destAvID)
+	return
+}
+
+// mergeAttributeViewNewKey 根据源列生成目标中的新列，关联列断开双向关联，汇总列不支持新建。
+func mergeAttributeViewNewKey(srcKey *av.Key) (ret *av.Key) {
+	ret = &av.Key{}
+	*ret = *srcKey
+	ret.ID = ast.NewNodeID()
+
+	switch srcKey.Type {
+	case av.KeyTypeRelation:
+		if nil != srcKey.Relation {
+			relation := *srcKey.Relation
+			relation.IsTwoWay = false
+			relation.BackKeyID = ""
+			ret.Relation = &relation
+		}
+	case av.KeyTypeRollup:
+		// 汇总列依赖源中的关联列，无法在目标中复用
+		return nil
+	}
+	return
+}
+
+// mergeAttributeViewComputedKey 判断列值是否由其他数据计算得到，这些列不合并值。
+func mergeAttributeViewComputedKey(keyType av.KeyType) bool {
+	switch keyType {
+	case av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		return true
+	}
+	return false
+}
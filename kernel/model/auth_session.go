@@ -0,0 +1,318 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/mssola/useragent"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// AuthSession 描述了一个已登录的浏览器会话或者一个 API token 的使用情况。
+type AuthSession struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`     // ui：浏览器会话，token：API token
+	Name     string `json:"name"`     // OIDC 用户名或者 token 备注
+	Device   string `json:"device"`   // 根据 User-Agent 解析的设备描述
+	IP       string `json:"ip"`       // 最后访问的客户端 IP
+	Created  int64  `json:"created"`  // 创建时间（毫秒）
+	LastSeen int64  `json:"lastSeen"` // 最后访问时间（毫秒），0 表示尚未使用
+	Current  bool   `json:"current"`  // 是否为当前请求所在的会话，不持久化
+}
+
+const (
+	AuthSessionKindUI    = "ui"
+	AuthSessionKindToken = "token"
+
+	authSessionSaveInterval = time.Minute
+)
+
+var (
+	authSessions         map[string]*AuthSession
+	authSessionsLock     = sync.Mutex{}
+	authSessionsSaveTime time.Time
+
+	ErrAuthSessionNotFound = errors.New("session not found")
+)
+
+// newUIAuthSession 登录成功后登记浏览器会话。
+func newUIAuthSession(c *gin.Context, name string) string {
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+
+	loadAuthSessions()
+	now := time.Now().UnixMilli()
+	s := &AuthSession{
+		ID:       gulu.Rand.String(24),
+		Kind:     AuthSessionKindUI,
+		Name:     name,
+		Device:   authSessionDevice(c),
+		IP:       util.GetRemoteAddr(c.Request),
+		Created:  now,
+		LastSeen: now,
+	}
+	authSessions[s.ID] = s
+	saveAuthSessions()
+	return s.ID
+}
+
+// checkUIAuthSession 校验浏览器会话是否已经被吊销，未登记的旧会话会被自动登记。
+func checkUIAuthSession(c *gin.Context, session *util.SessionData, workspaceSession *util.WorkspaceSession) bool {
+	if "" == workspaceSession.SessionID {
+		workspaceSession.SessionID = newUIAuthSession(c, workspaceSession.OIDCName)
+		if err := session.Save(c); nil != err {
+			logging.LogErrorf("save session failed: " + err.Error())
+		}
+		return true
+	}
+
+	if touchAuthSession(c, workspaceSession.SessionID) {
+		return true
+	}
+
+	// 会话已经被吊销，清除 Cookie 中的登录状态
+	workspaceSession.AccessAuthCode = ""
+	workspaceSession.OIDCSubject = ""
+	workspaceSession.SessionID = ""
+	if err := session.Save(c); nil != err {
+		logging.LogErrorf("save session failed: " + err.Error())
+	}
+	return false
+}
+
+// IsAuthSessionValid 判断浏览器会话是否有效，用于 WebSocket 连接鉴权。
+func IsAuthSessionValid(id string) bool {
+	if "" == id {
+		return false
+	}
+
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+	loadAuthSessions()
+	return nil != authSessions[id]
+}
+
+// removeUIAuthSession 退出登录时移除浏览器会话。
+func removeUIAuthSession(id string) {
+	if "" == id {
+		return
+	}
+
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+	loadAuthSessions()
+	if nil != authSessions[id] {
+		delete(authSessions, id)
+		saveAuthSessions()
+	}
+}
+
+// touchTokenAuthSession 记录 API token 的使用情况。
+func touchTokenAuthSession(c *gin.Context, token, name string) {
+	id := tokenAuthSessionID(token)
+
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+	loadAuthSessions()
+	if nil == authSessions[id] {
+		authSessions[id] = &AuthSession{ID: id, Kind: AuthSessionKindToken, Name: name, Created: time.Now().UnixMilli()}
+	}
+	touchAuthSession0(c, authSessions[id])
+}
+
+func touchAuthSession(c *gin.Context, id string) bool {
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+	loadAuthSessions()
+	s := authSessions[id]
+	if nil == s {
+		return false
+	}
+	touchAuthSession0(c, s)
+	return true
+}
+
+func touchAuthSession0(c *gin.Context, s *AuthSession) {
+	s.LastSeen = time.Now().UnixMilli()
+	s.IP = util.GetRemoteAddr(c.Request)
+	s.Device = authSessionDevice(c)
+	if authSessionSaveInterval < time.Since(authSessionsSaveTime) {
+		saveAuthSessions()
+	}
+}
+
+// GetAuthSessions 返回所有浏览器会话和 API token 的使用情况。
+func GetAuthSessions(c *gin.Context) (ret []*AuthSession) {
+	currentID := util.GetWorkspaceSession(util.GetSession(c)).SessionID
+	if token := getRequestToken(c); "" != token {
+		currentID = tokenAuthSessionID(token)
+	}
+
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+	loadAuthSessions()
+
+	// 未使用过的 token 也需要列出，已经删除的 token 不再列出
+	tokens := map[string]string{}
+	if "" != Conf.Api.Token {
+		tokens[tokenAuthSessionID(Conf.Api.Token)] = "API token"
+	}
+	for _, scopedToken := range Conf.Api.ScopedTokens {
+		if "" != scopedToken.Token {
+			tokens[tokenAuthSessionID(scopedToken.Token)] = scopedToken.Memo
+		}
+	}
+	changed := false
+	for id, s := range authSessions {
+		if AuthSessionKindToken == s.Kind && "" == tokens[id] {
+			delete(authSessions, id)
+			changed = true
+		}
+	}
+	for id, name := range tokens {
+		if nil == authSessions[id] {
+			authSessions[id] = &AuthSession{ID: id, Kind: AuthSessionKindToken, Created: time.Now().UnixMilli()}
+			changed = true
+		}
+		authSessions[id].Name = name
+	}
+	if changed {
+		saveAuthSessions()
+	}
+
+	ret = []*AuthSession{}
+	for _, s := range authSessions {
+		session := *s
+		session.Current = currentID == s.ID
+		ret = append(ret, &session)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].LastSeen > ret[j].LastSeen })
+	return
+}
+
+// RevokeAuthSession 吊销浏览器会话或者 API token，立即生效。
+//
+// 吊销主 API token 时会重新生成 token，吊销带角色的 API token 时会将其删除。
+func RevokeAuthSession(id string) (err error) {
+	authSessionsLock.Lock()
+	defer authSessionsLock.Unlock()
+	loadAuthSessions()
+
+	s := authSessions[id]
+	if nil == s {
+		return ErrAuthSessionNotFound
+	}
+	delete(authSessions, id)
+	saveAuthSessions()
+
+	switch s.Kind {
+	case AuthSessionKindUI:
+		util.ClosePushChansByAuthSession(id)
+	case AuthSessionKindToken:
+		if "" != Conf.Api.Token && tokenAuthSessionID(Conf.Api.Token) == id {
+			Conf.Api.Token = gulu.Rand.String(16)
+		}
+		var scopedTokens []*conf.ScopedToken
+		for _, scopedToken := range Conf.Api.ScopedTokens {
+			if tokenAuthSessionID(scopedToken.Token) != id {
+				scopedTokens = append(scopedTokens, scopedToken)
+			}
+		}
+		Conf.Api.ScopedTokens = scopedTokens
+		Conf.Save()
+	}
+	logging.LogInfof("revoked %s session [%s]", s.Kind, s.Name)
+	return
+}
+
+func tokenAuthSessionID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return "token-" + hex.EncodeToString(hash[:])[:16]
+}
+
+func authSessionDevice(c *gin.Context) string {
+	userAgentHeader := c.GetHeader("User-Agent")
+	if "" == userAgentHeader {
+		return ""
+	}
+	if strings.HasPrefix(userAgentHeader, "SiYuan/") {
+		return strings.Split(userAgentHeader, " ")[0]
+	}
+
+	ua := useragent.New(userAgentHeader)
+	name, version := ua.Browser()
+	if "" != version {
+		name += " " + strings.Split(version, ".")[0]
+	}
+	if osName := ua.OS(); "" != osName {
+		name += " / " + osName
+	}
+	return name
+}
+
+func loadAuthSessions() {
+	if nil != authSessions {
+		return
+	}
+
+	authSessions = map[string]*AuthSession{}
+	dataPath := filepath.Join(util.ConfDir, "sessions.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if nil != err {
+		logging.LogErrorf("read sessions failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &authSessions); nil != err {
+		logging.LogErrorf("unmarshal sessions failed: %s", err)
+		authSessions = map[string]*AuthSession{}
+	}
+}
+
+func saveAuthSessions() {
+	authSessionsSaveTime = time.Now()
+	data, err := gulu.JSON.MarshalIndentJSON(authSessions, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal sessions failed: %s", err)
+		return
+	}
+
+	if err = os.MkdirAll(util.ConfDir, 0755); nil != err {
+		logging.LogErrorf("create conf dir failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(filepath.Join(util.ConfDir, "sessions.json"), data); nil != err {
+		logging.LogErrorf("write sessions failed: %s", err)
+	}
+}
@@ -29,7 +29,6 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 	"github.com/emirpasic/gods/sets/hashset"
-	"github.com/facette/natsort"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/sql"
@@ -241,7 +240,7 @@ func getBacklinkRenderNodes(n *ast.Node) (ret []*ast.Node, expand bool) {
 	return
 }
 
-func GetBacklink2(id, keyword, mentionKeyword string, sortMode, mentionSortMode int) (boxID string, backlinks, backmentions []*Path, linkRefsCount, mentionsCount int) {
+func GetBacklink2(id, keyword, mentionKeyword string, sortMode, mentionSortMode int, filter *BacklinkFilter) (boxID string, backlinks, backmentions []*Path, linkRefsCount, mentionsCount int) {
 	keyword = strings.TrimSpace(keyword)
 	mentionKeyword = strings.TrimSpace(mentionKeyword)
 	backlinks, backmentions = []*Path{}, []*Path{}
@@ -257,34 +256,19 @@ func GetBacklink2(id, keyword, mentionKeyword string, sortMode, mentionSortMode
 	refs = removeDuplicatedRefs(refs) // 同一个块中引用多个相同块时反链去重 https://github.com/siyuan-note/siyuan/issues/3317
 
 	linkRefs, linkRefsCount, excludeBacklinkIDs := buildLinkRefs(rootID, refs, keyword)
-	tmpBacklinks := toFlatTree(linkRefs, 0, "backlink", nil)
+	// 过滤后的引用块仍然需要用于排除提及，所以只在构建反链路径时过滤
+	filteredLinkRefs := filterBacklinkBlocks(linkRefs, filter)
+	if len(filteredLinkRefs) != len(linkRefs) {
+		linkRefsCount = len(filteredLinkRefs)
+	}
+	tmpBacklinks := toFlatTree(filteredLinkRefs, 0, "backlink", nil)
 
 	for _, l := range tmpBacklinks {
 		l.Blocks = nil
 		backlinks = append(backlinks, l)
 	}
 
-	sort.Slice(backlinks, func(i, j int) bool {
-		switch sortMode {
-		case util.SortModeUpdatedDESC:
-			return backlinks[i].Updated > backlinks[j].Updated
-		case util.SortModeUpdatedASC:
-			return backlinks[i].Updated < backlinks[j].Updated
-		case util.SortModeCreatedDESC:
-			return backlinks[i].Created > backlinks[j].Created
-		case util.SortModeCreatedASC:
-			return backlinks[i].Created < backlinks[j].Created
-		case util.SortModeNameDESC:
-			return util.PinYinCompare(util.RemoveEmojiInvisible(backlinks[j].Name), util.RemoveEmojiInvisible(backlinks[i].Name))
-		case util.SortModeNameASC:
-			return util.PinYinCompare(util.RemoveEmojiInvisible(backlinks[i].Name), util.RemoveEmojiInvisible(backlinks[j].Name))
-		case util.SortModeAlphanumDESC:
-			return natsort.Compare(util.RemoveEmojiInvisible(backlinks[j].Name), util.RemoveEmojiInvisible(backlinks[i].Name))
-		case util.SortModeAlphanumASC:
-			return natsort.Compare(util.RemoveEmojiInvisible(backlinks[i].Name), util.RemoveEmojiInvisible(backlinks[j].Name))
-		}
-		return backlinks[i].ID > backlinks[j].ID
-	})
+	sortBacklinkPaths(backlinks, sortMode)
 
 	mentionRefs, _ := buildTreeBackmention(sqlBlock, linkRefs, mentionKeyword, excludeBacklinkIDs, 12)
 	tmpBackmentions := toFlatTree(mentionRefs, 0, "backlink", nil)
@@ -293,27 +277,8 @@ func GetBacklink2(id, keyword, mentionKeyword string, sortMode, mentionSortMode
 		backmentions = append(backmentions, l)
 	}
 
-	sort.Slice(backmentions, func(i, j int) bool {
-		switch mentionSortMode {
-		case util.SortModeUpdatedDESC:
-			return backmentions[i].Updated > backmentions[j].Updated
-		case util.SortModeUpdatedASC:
-			return backmentions[i].Updated < backmentions[j].Updated
-		case util.SortModeCreatedDESC:
-			return backmentions[i].Created > backmentions[j].Created
-		case util.SortModeCreatedASC:
-			return backmentions[i].Created < backmentions[j].Created
-		case util.SortModeNameDESC:
-			return util.PinYinCompare(util.RemoveEmojiInvisible(backmentions[j].Name), util.RemoveEmojiInvisible(backmentions[i].Name))
-		case util.SortModeNameASC:
-			return util.PinYinCompare(util.RemoveEmojiInvisible(backmentions[i].Name), util.RemoveEmojiInvisible(backmentions[j].Name))
-		case util.SortModeAlphanumDESC:
-			return natsort.Compare(util.RemoveEmojiInvisible(backmentions[j].Name), util.RemoveEmojiInvisible(backmentions[i].Name))
-		case util.SortModeAlphanumASC:
-			return natsort.Compare(util.RemoveEmojiInvisible(backmentions[i].Name), util.RemoveEmojiInvisible(backmentions[j].Name))
-		}
-		return backmentions[i].ID > backmentions[j].ID
-	})
+	backmentions = filterBacklinkPaths(backmentions, filter)
+	sortBacklinkPaths(backmentions, mentionSortMode)
 
 	for _, backmention := range backmentions {
 		mentionsCount += backmention.Count
@@ -0,0 +1,242 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/facette/natsort"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// BacklinkFilter 描述了反链面板的过滤条件，为空的条件不生效。
+type BacklinkFilter struct {
+	Boxes []string `json:"boxes"` // 笔记本 ID
+	Types []string `json:"types"` // 引用块类型缩写，仅用于反链，例如 p、h、i
+	Tags  []string `json:"tags"`  // 引用块或者其所在文档包含的标签，包含子标签
+}
+
+func (filter *BacklinkFilter) isEmpty() bool {
+	return nil == filter || (1 > len(filter.Boxes) && 1 > len(filter.Types) && 1 > len(filter.Tags))
+}
+
+// BacklinkConf 描述了文档反链面板的排序和过滤配置，保存在 storage/backlink.json 中以便在多端同步。
+type BacklinkConf struct {
+	Sort        int             `json:"sort"`        // 反链排序方式，按照相关度排序时使用 util.SortModeRefCountDESC
+	MentionSort int             `json:"mentionSort"` // 提及排序方式
+	Filter      *BacklinkFilter `json:"filter"`
+}
+
+var backlinkConfLock = sync.Mutex{}
+
+// GetBacklinkConf 获取文档的反链面板配置，没有保存过时返回默认配置。
+func GetBacklinkConf(rootID string) (ret *BacklinkConf) {
+	backlinkConfLock.Lock()
+	defer backlinkConfLock.Unlock()
+
+	if ret = getBacklinkConfs()[rootID]; nil == ret {
+		ret = &BacklinkConf{Sort: util.SortModeUpdatedDESC, MentionSort: util.SortModeUpdatedDESC}
+	}
+	if nil == ret.Filter {
+		ret.Filter = &BacklinkFilter{}
+	}
+	return
+}
+
+// SetBacklinkConf 保存文档的反链面板配置，conf 为空时删除配置。
+func SetBacklinkConf(rootID string, conf *BacklinkConf) (err error) {
+	backlinkConfLock.Lock()
+	defer backlinkConfLock.Unlock()
+
+	confs := getBacklinkConfs()
+	if nil == conf {
+		delete(confs, rootID)
+	} else {
+		confs[rootID] = conf
+	}
+	return setBacklinkConfs(confs)
+}
+
+// RemoveBacklinkConfs 删除文档的反链面板配置。
+func RemoveBacklinkConfs(rootIDs []string) {
+	backlinkConfLock.Lock()
+	defer backlinkConfLock.Unlock()
+
+	confs := getBacklinkConfs()
+	size := len(confs)
+	for _, rootID := range rootIDs {
+		delete(confs, rootID)
+	}
+	if size != len(confs) {
+		setBacklinkConfs(confs)
+	}
+}
+
+func setBacklinkConfs(confs map[string]*BacklinkConf) (err error) {
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); nil != err {
+		logging.LogErrorf("create storage [backlink] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(confs, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal storage [backlink] failed: %s", err)
+		return
+	}
+
+	lsPath := filepath.Join(dirPath, "backlink.json")
+	if err = filelock.WriteFile(lsPath, data); nil != err {
+		logging.LogErrorf("write storage [backlink] failed: %s", err)
+		return
+	}
+	return
+}
+
+func getBacklinkConfs() (ret map[string]*BacklinkConf) {
+	ret = map[string]*BacklinkConf{}
+	dataPath := filepath.Join(util.DataDir, "storage/backlink.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if nil != err {
+		logging.LogErrorf("read storage [backlink] failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal storage [backlink] failed: %s", err)
+		ret = map[string]*BacklinkConf{}
+	}
+	return
+}
+
+func filterBacklinkBlocks(blocks []*Block, filter *BacklinkFilter) (ret []*Block) {
+	if filter.isEmpty() {
+		return blocks
+	}
+
+	var rootIDs []string
+	for _, b := range blocks {
+		rootIDs = append(rootIDs, b.RootID)
+	}
+	rootTags := backlinkRootTags(filter, rootIDs)
+	for _, b := range blocks {
+		if 0 < len(filter.Boxes) && !gulu.Str.Contains(b.Box, filter.Boxes) {
+			continue
+		}
+		if 0 < len(filter.Types) && !gulu.Str.Contains(treenode.TypeAbbr(b.Type), filter.Types) {
+			continue
+		}
+		if 0 < len(filter.Tags) && !backlinkTagMatched(b.Tag, filter.Tags) && !backlinkTagMatched(rootTags[b.RootID], filter.Tags) {
+			continue
+		}
+		ret = append(ret, b)
+	}
+	return
+}
+
+// filterBacklinkPaths 按照笔记本和文档标签过滤提及。
+func filterBacklinkPaths(paths []*Path, filter *BacklinkFilter) (ret []*Path) {
+	if filter.isEmpty() {
+		return paths
+	}
+
+	ret = []*Path{}
+	var rootIDs []string
+	for _, p := range paths {
+		rootIDs = append(rootIDs, p.ID)
+	}
+	rootTags := backlinkRootTags(filter, rootIDs)
+	for _, p := range paths {
+		if 0 < len(filter.Boxes) && !gulu.Str.Contains(p.Box, filter.Boxes) {
+			continue
+		}
+		if 0 < len(filter.Tags) && !backlinkTagMatched(rootTags[p.ID], filter.Tags) {
+			continue
+		}
+		ret = append(ret, p)
+	}
+	return
+}
+
+func backlinkRootTags(filter *BacklinkFilter, rootIDs []string) (ret map[string]string) {
+	ret = map[string]string{}
+	if 1 > len(filter.Tags) {
+		return
+	}
+
+	for _, root := range sql.GetBlocks(gulu.Str.RemoveDuplicatedElem(rootIDs)) {
+		if nil != root {
+			ret[root.ID] = root.Tag
+		}
+	}
+	return
+}
+
+func backlinkTagMatched(blockTags string, tags []string) bool {
+	for _, tag := range tags {
+		if strings.Contains(blockTags, "#"+tag+"#") || strings.Contains(blockTags, "#"+tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortBacklinkPaths(paths []*Path, sortMode int) {
+	sort.Slice(paths, func(i, j int) bool {
+		switch sortMode {
+		case util.SortModeUpdatedDESC:
+			return paths[i].Updated > paths[j].Updated
+		case util.SortModeUpdatedASC:
+			return paths[i].Updated < paths[j].Updated
+		case util.SortModeCreatedDESC:
+			return paths[i].Created > paths[j].Created
+		case util.SortModeCreatedASC:
+			return paths[i].Created < paths[j].Created
+		case util.SortModeNameDESC:
+			return util.PinYinCompare(util.RemoveEmojiInvisible(paths[j].Name), util.RemoveEmojiInvisible(paths[i].Name))
+		case util.SortModeNameASC:
+			return util.PinYinCompare(util.RemoveEmojiInvisible(paths[i].Name), util.RemoveEmojiInvisible(paths[j].Name))
+		case util.SortModeAlphanumDESC:
+			return natsort.Compare(util.RemoveEmojiInvisible(paths[j].Name), util.RemoveEmojiInvisible(paths[i].Name))
+		case util.SortModeAlphanumASC:
+			return natsort.Compare(util.RemoveEmojiInvisible(paths[i].Name), util.RemoveEmojiInvisible(paths[j].Name))
+		case util.SortModeRefCountDESC: // 相关度：引用或者提及越多越靠前
+			if paths[i].Count != paths[j].Count {
+				return paths[i].Count > paths[j].Count
+			}
+			return paths[i].Updated > paths[j].Updated
+		case util.SortModeRefCountASC:
+			if paths[i].Count != paths[j].Count {
+				return paths[i].Count < paths[j].Count
+			}
+			return paths[i].Updated > paths[j].Updated
+		}
+		return paths[i].ID > paths[j].ID
+	})
+}
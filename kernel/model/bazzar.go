@@ -19,8 +19,10 @@ package model
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -240,11 +242,22 @@ func InstallBazaarPlugin(repoURL, repoHash, pluginName string) error {
 	if nil != err {
 		return errors.New(fmt.Sprintf(Conf.Language(46), pluginName, err))
 	}
+
+	// 更新插件后重新加载后端
+	go func() {
+		if reloadErr := ReloadPluginBackend(pluginName); nil != reloadErr {
+			logging.LogErrorf("reload plugin [%s] backend failed: %s", pluginName, reloadErr)
+		}
+	}()
 	return nil
 }
 
 func UninstallBazaarPlugin(pluginName, frontend string) error {
 	installPath := filepath.Join(util.DataDir, "plugins", pluginName)
+	unloadPluginBackend(pluginName)
+	UnsubscribePluginEvents(pluginName, "")
+	RemovePluginJob(pluginName, "")
+	removePluginPermissions(pluginName)
 	err := bazaar.UninstallPlugin(installPath)
 	if nil != err {
 		return errors.New(fmt.Sprintf(Conf.Language(47), err.Error()))
@@ -265,6 +278,18 @@ func UninstallBazaarPlugin(pluginName, frontend string) error {
 	return nil
 }
 
+// ApplyBazaarConf 应用集市镜像和签名校验配置。
+func ApplyBazaarConf() {
+	bazaar.SetMirror(Conf.Bazaar.Mirror, Conf.Bazaar.MirrorPublicKey)
+
+	requireSignature := Conf.Bazaar.RequireSignature
+	if envRequire, _ := strconv.ParseBool(os.Getenv("SIYUAN_BAZAAR_REQUIRE_SIGNATURE")); envRequire {
+		// 托管部署时通过环境变量强制要求签名，用户无法在设置中关闭
+		requireSignature = true
+	}
+	bazaar.SetRequireSignature(requireSignature)
+}
+
 func BazaarWidgets(keyword string) (widgets []*bazaar.Widget) {
 	widgets = bazaar.Widgets()
 	widgets = filterWidgets(widgets, keyword)
@@ -73,6 +73,8 @@ type RiffCard struct {
 	Lapses     uint64     `json:"lapses"`
 	State      fsrs.State `json:"state"`
 	LastReview time.Time  `json:"lastReview"`
+	Difficulty float64    `json:"difficulty"`
+	Stability  float64    `json:"stability"`
 }
 
 func getRiffCard(card *fsrs.Card) *RiffCard {
@@ -87,6 +89,8 @@ func getRiffCard(card *fsrs.Card) *RiffCard {
 		Lapses:     card.Lapses,
 		State:      card.State,
 		LastReview: card.LastReview,
+		Difficulty: card.Difficulty,
+		Stability:  card.Stability,
 	}
 }
 
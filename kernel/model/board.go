@@ -0,0 +1,279 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 文档看板将父文档的子文档作为卡片，按卡片文档上的属性值分列，列内按文档树自定义排序排列。
+//
+// 移动卡片时修改卡片文档的属性并更新文档树排序，看板本身不单独保存数据，插件可以在此基础上实现看板视图。
+
+const defaultBoardAttr = "custom-status"
+
+// BoardCard 描述了看板上的一张卡片，即一篇子文档。
+type BoardCard struct {
+	ID           string `json:"id"`
+	Box          string `json:"box"`
+	Path         string `json:"path"`
+	Title        string `json:"title"`
+	Icon         string `json:"icon"`
+	Value        string `json:"value"` // 分组属性值
+	Sort         int    `json:"sort"`  // 在兄弟文档中的位置，从 0 开始
+	SubFileCount int    `json:"subFileCount"`
+	Updated      int64  `json:"updated"`
+}
+
+// BoardColumn 描述了看板上的一列，Value 为空的列收纳未设置分组属性的卡片。
+type BoardColumn struct {
+	Value string       `json:"value"`
+	Cards []*BoardCard `json:"cards"`
+}
+
+type Board struct {
+	ParentID string         `json:"parentID"`
+	Attr     string         `json:"attr"`
+	Columns  []*BoardColumn `json:"columns"`
+}
+
+// GetBoard 返回父文档的看板，columns 指定列的顺序，其他出现的属性值按名称排在后面。
+func GetBoard(parentID, attr string, columns []string) (ret *Board, err error) {
+	if attr, err = boardAttr(attr); nil != err {
+		return
+	}
+
+	cards, err := boardCards(parentID, attr)
+	if nil != err {
+		return
+	}
+
+	ret = &Board{ParentID: parentID, Attr: attr, Columns: []*BoardColumn{}}
+	columnsByValue := map[string]*BoardColumn{}
+	addColumn := func(value string) *BoardColumn {
+		if column := columnsByValue[value]; nil != column {
+			return column
+		}
+		column := &BoardColumn{Value: value, Cards: []*BoardCard{}}
+		columnsByValue[value] = column
+		ret.Columns = append(ret.Columns, column)
+		return column
+	}
+
+	addColumn("")
+	for _, value := range columns {
+		addColumn(strings.TrimSpace(value))
+	}
+
+	var extraValues []string
+	for _, card := range cards {
+		if nil == columnsByValue[card.Value] && !gulu.Str.Contains(card.Value, extraValues) {
+			extraValues = append(extraValues, card.Value)
+		}
+	}
+	sort.Strings(extraValues)
+	for _, value := range extraValues {
+		addColumn(value)
+	}
+
+	for _, card := range cards {
+		columnsByValue[card.Value].Cards = append(columnsByValue[card.Value].Cards, card)
+	}
+	return
+}
+
+// MoveBoardCard 将卡片移动到 value 列中 previousID 卡片之后，previousID 为空时移动到该列最前。
+//
+// value 为空时移除卡片的分组属性。卡片顺序通过文档树自定义排序保存，因此在文档树中也会同步调整。
+func MoveBoardCard(parentID, attr, cardID, value, previousID string) (ret *Board, err error) {
+	if attr, err = boardAttr(attr); nil != err {
+		return
+	}
+	if cardID == previousID {
+		err = errors.New("card can not be placed after itself")
+		return
+	}
+
+	cards, err := boardCards(parentID, attr)
+	if nil != err {
+		return
+	}
+
+	var card *BoardCard
+	var others []*BoardCard
+	for _, c := range cards {
+		if c.ID == cardID {
+			card = c
+			continue
+		}
+		others = append(others, c)
+	}
+	if nil == card {
+		err = fmt.Errorf(Conf.Language(15), cardID)
+		return
+	}
+
+	value = strings.TrimSpace(value)
+	index := -1
+	if "" != previousID {
+		for i, c := range others {
+			if c.ID == previousID {
+				if c.Value != value {
+					err = fmt.Errorf("card [%s] is not in column [%s]", previousID, value)
+					return
+				}
+				index = i + 1
+				break
+			}
+		}
+		if -1 == index {
+			err = fmt.Errorf(Conf.Language(15), previousID)
+			return
+		}
+	} else {
+		// 移动到列首时放在该列第一张卡片之前，空列时放在最后
+		index = len(others)
+		for i, c := range others {
+			if c.Value == value {
+				index = i
+				break
+			}
+		}
+	}
+
+	if card.Value != value {
+		if err = SetBlockAttrs(card.ID, map[string]string{attr: value}); nil != err {
+			return
+		}
+	}
+
+	ordered := make([]*BoardCard, 0, len(cards))
+	ordered = append(ordered, others[:index]...)
+	ordered = append(ordered, card)
+	ordered = append(ordered, others[index:]...)
+	var paths []string
+	for _, c := range ordered {
+		paths = append(paths, c.Path)
+	}
+	ChangeFileTreeSort(card.Box, paths)
+	util.PushReloadFiletree()
+
+	ret, err = GetBoard(parentID, attr, nil)
+	return
+}
+
+// ReorderBoardCards 按 ids 的顺序重新排列卡片，未列出的卡片保持原有相对顺序排在后面。
+func ReorderBoardCards(parentID string, ids []string) (err error) {
+	cards, err := boardCards(parentID, defaultBoardAttr)
+	if nil != err {
+		return
+	}
+	if 1 > len(cards) {
+		return
+	}
+
+	positions := map[string]int{}
+	for i, id := range ids {
+		if _, ok := positions[id]; !ok {
+			positions[id] = i
+		}
+	}
+	sort.SliceStable(cards, func(i, j int) bool {
+		pi, ok := positions[cards[i].ID]
+		if !ok {
+			pi = math.MaxInt
+		}
+		pj, ok := positions[cards[j].ID]
+		if !ok {
+			pj = math.MaxInt
+		}
+		return pi < pj
+	})
+
+	var paths []string
+	for _, c := range cards {
+		paths = append(paths, c.Path)
+	}
+	ChangeFileTreeSort(cards[0].Box, paths)
+	util.PushReloadFiletree()
+	return
+}
+
+func boardAttr(attr string) (ret string, err error) {
+	ret = strings.TrimSpace(attr)
+	if "" == ret {
+		ret = defaultBoardAttr
+		return
+	}
+	if !strings.HasPrefix(ret, "custom-") || "custom-" == ret {
+		err = fmt.Errorf("board attribute [%s] must start with custom-", attr)
+	}
+	return
+}
+
+// boardCards 按文档树自定义排序返回父文档下的子文档卡片，不包含隐藏文档。
+func boardCards(parentID, attr string) (ret []*BoardCard, err error) {
+	ret = []*BoardCard{}
+	bt := treenode.GetBlockTree(parentID)
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+	if "d" != bt.Type {
+		err = fmt.Errorf("block [%s] is not a document", parentID)
+		return
+	}
+
+	box := Conf.Box(bt.BoxID)
+	if nil == box {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+
+	WaitForWritingFiles()
+	files, _, err := ListDocTree(bt.BoxID, bt.Path, util.SortModeCustom, false, false, math.MaxInt32)
+	if nil != err {
+		return
+	}
+
+	for i, file := range files {
+		ial := box.docIAL(file.Path)
+		card := &BoardCard{
+			ID:           file.ID,
+			Box:          bt.BoxID,
+			Path:         file.Path,
+			Title:        strings.TrimSuffix(file.Name, ".sy"),
+			Icon:         file.Icon,
+			Sort:         i,
+			SubFileCount: file.SubFileCount,
+			Updated:      file.Mtime,
+		}
+		if nil != ial {
+			card.Value = strings.TrimSpace(ial[attr])
+		}
+		ret = append(ret, card)
+	}
+	return
+}
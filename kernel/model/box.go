@@ -58,6 +58,8 @@ type Box struct {
 	DueFlashcardCount int `json:"dueFlashcardCount"`
 	FlashcardCount    int `json:"flashcardCount"`
 
+	Snapshot string `json:"snapshot,omitempty"` // 挂载的快照 ID，不为空时是只读的快照笔记本
+
 	historyGenerated int64 // 最近一次历史生成时间
 }
 
@@ -327,6 +329,7 @@ func (box *Box) MkdirAll(path string) error {
 }
 
 func (box *Box) Move(oldPath, newPath string) error {
+	filesys.FlushTreeSaves() // 移动前写入等待防抖的文档，否则移动后无法再写入
 	boxLocalPath := filepath.Join(util.DataDir, box.ID)
 	fromPath := filepath.Join(boxLocalPath, oldPath)
 	toPath := filepath.Join(boxLocalPath, newPath)
@@ -336,6 +339,7 @@ func (box *Box) Move(oldPath, newPath string) error {
 		logging.LogErrorf("move [path=%s] in box [%s] failed: %s", fromPath, box.Name, err)
 		return errors.New(msg)
 	}
+	filesys.RecordRenamedFile(fromPath, toPath)
 
 	if oldDir := path.Dir(oldPath); ast.IsNodeIDPattern(path.Base(oldDir)) {
 		fromDir := filepath.Join(boxLocalPath, oldDir)
@@ -348,6 +352,7 @@ func (box *Box) Move(oldPath, newPath string) error {
 }
 
 func (box *Box) Remove(path string) error {
+	filesys.FlushTreeSaves()
 	boxLocalPath := filepath.Join(util.DataDir, box.ID)
 	filePath := filepath.Join(boxLocalPath, path)
 	if err := filelock.Remove(filePath); nil != err {
@@ -529,6 +534,7 @@ func fullReindex() {
 	for _, openedBox := range openedBoxes {
 		index(openedBox.ID)
 	}
+	indexAttributeViews()
 	treenode.SaveBlockTree(true)
 	LoadFlashcards()
 	debug.FreeOSMemory()
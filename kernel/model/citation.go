@@ -0,0 +1,594 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// CitationItem 描述了一条文献，字段为 CSL JSON 的常用子集。
+type CitationItem struct {
+	ID             string          `json:"id"`
+	CitationKey    string          `json:"citation-key,omitempty"` // Better BibTeX 导出的引用键
+	CiteKey        string          `json:"citekey,omitempty"`      // Better BibTeX 搜索结果中的引用键
+	Type           string          `json:"type"`
+	Title          string          `json:"title"`
+	Author         []*CitationName `json:"author,omitempty"`
+	Editor         []*CitationName `json:"editor,omitempty"`
+	Issued         *CitationDate   `json:"issued,omitempty"`
+	ContainerTitle citationString  `json:"container-title,omitempty"`
+	Publisher      citationString  `json:"publisher,omitempty"`
+	PublisherPlace citationString  `json:"publisher-place,omitempty"`
+	Volume         citationString  `json:"volume,omitempty"`
+	Issue          citationString  `json:"issue,omitempty"`
+	Page           citationString  `json:"page,omitempty"`
+	DOI            citationString  `json:"DOI,omitempty"`
+	URL            citationString  `json:"URL,omitempty"`
+}
+
+type CitationName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type CitationDate struct {
+	DateParts [][]citationString `json:"date-parts,omitempty"`
+	Literal   string             `json:"literal,omitempty"`
+	Raw       string             `json:"raw,omitempty"`
+}
+
+// citationString 兼容 CSL JSON 中以数字形式出现的字符串字段，比如卷号和页码。
+type citationString string
+
+func (s *citationString) UnmarshalJSON(data []byte) (err error) {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return
+	}
+	if 0 < len(data) && '"' == data[0] {
+		var str string
+		if err = gulu.JSON.UnmarshalJSON(data, &str); nil != err {
+			return
+		}
+		*s = citationString(str)
+		return
+	}
+	*s = citationString(strings.TrimSpace(string(data)))
+	return
+}
+
+func (name *CitationName) FamilyOrLiteral() string {
+	if "" != name.Literal {
+		return name.Literal
+	}
+	return name.Family
+}
+
+// Key 返回文献的引用键。
+func (item *CitationItem) Key() string {
+	if "" != item.CitationKey {
+		return item.CitationKey
+	}
+	if "" != item.CiteKey {
+		return item.CiteKey
+	}
+	return item.ID
+}
+
+var citationYearRegexp = regexp.MustCompile(`\d{4}`)
+
+// Year 返回文献的出版年份，没有时返回空字符串。
+func (item *CitationItem) Year() string {
+	if nil == item.Issued {
+		return ""
+	}
+	if 0 < len(item.Issued.DateParts) && 0 < len(item.Issued.DateParts[0]) {
+		return string(item.Issued.DateParts[0][0])
+	}
+	if year := citationYearRegexp.FindString(item.Issued.Literal); "" != year {
+		return year
+	}
+	return citationYearRegexp.FindString(item.Issued.Raw)
+}
+
+func (item *CitationItem) sortKey() string {
+	var names []string
+	for _, name := range item.Author {
+		names = append(names, strings.ToLower(name.FamilyOrLiteral()+" "+name.Given))
+	}
+	return strings.Join(names, ",") + "\x00" + item.Year() + "\x00" + strings.ToLower(item.Title)
+}
+
+// CitationBlockAttr 为文献引用块上记录引用键的属性，多个引用键使用逗号分隔。
+const CitationBlockAttr = "custom-citation-keys"
+
+func errCitationNotConfigured() error {
+	return errors.New(Conf.Language(256))
+}
+
+// citationInlineRegexp 匹配正文中 Pandoc 风格的引用，比如 [@smith2020; @doe2021]。
+var citationInlineRegexp = regexp.MustCompile(`\[(@[\w:.#$%&+?<>~/-]+(?:\s*;\s*@[\w:.#$%&+?<>~/-]+)*)\]`)
+
+func SetCitation(citation *conf.Citation) (ret *conf.Citation, err error) {
+	citation.Source = strings.TrimSpace(citation.Source)
+	citation.ZoteroURL = strings.TrimSpace(citation.ZoteroURL)
+	citation.LibraryPath = strings.TrimSpace(citation.LibraryPath)
+	citation.Style = strings.TrimSpace(citation.Style)
+	citation.BibliographyTitle = strings.TrimSpace(citation.BibliographyTitle)
+
+	switch citation.Source {
+	case "", conf.CitationSourceCSL:
+	case conf.CitationSourceZotero:
+		if !strings.HasPrefix(citation.ZoteroURL, "http://") && !strings.HasPrefix(citation.ZoteroURL, "https://") {
+			err = fmt.Errorf("invalid Zotero URL [%s]", citation.ZoteroURL)
+			return
+		}
+	default:
+		err = fmt.Errorf("invalid citation source [%s]", citation.Source)
+		return
+	}
+	if conf.CitationSourceCSL == citation.Source && "" == citation.LibraryPath {
+		err = errors.New("CSL JSON library path is empty")
+		return
+	}
+	if "" == citation.ZoteroURL {
+		citation.ZoteroURL = conf.NewCitation().ZoteroURL
+	}
+	if !isValidCitationStyle(citation.Style) {
+		err = fmt.Errorf("invalid citation style [%s]", citation.Style)
+		return
+	}
+
+	Conf.Citation = citation
+	Conf.Save()
+	ret = citation
+	return
+}
+
+// SearchCitations 在文献库中按关键字搜索文献，关键字为空时返回 CSL JSON 文献库中的所有文献。
+func SearchCitations(keyword string) (ret []*CitationItem, err error) {
+	ret = []*CitationItem{}
+	keyword = strings.TrimSpace(keyword)
+	switch Conf.Citation.Source {
+	case conf.CitationSourceZotero:
+		if "" == keyword {
+			return
+		}
+		var items []*CitationItem
+		if items, err = zoteroSearch(keyword); nil != err {
+			return
+		}
+		ret = append(ret, items...)
+	case conf.CitationSourceCSL:
+		var library []*CitationItem
+		if library, err = loadCSLLibrary(); nil != err {
+			return
+		}
+		lowerKeyword := strings.ToLower(keyword)
+		for _, item := range library {
+			if "" == keyword || strings.Contains(strings.ToLower(item.Key()), lowerKeyword) || strings.Contains(strings.ToLower(item.Title), lowerKeyword) ||
+				strings.Contains(strings.ToLower(joinCitationNames(item.Author, citationFamilyInitials, ", ", ", ", ", ")), lowerKeyword) {
+				ret = append(ret, item)
+			}
+		}
+	default:
+		err = errCitationNotConfigured()
+	}
+	return
+}
+
+// GetCitationItems 按引用键获取文献，未找到的引用键返回在 missing 中。
+func GetCitationItems(keys []string) (ret map[string]*CitationItem, missing []string, err error) {
+	ret = map[string]*CitationItem{}
+	keys = gulu.Str.RemoveDuplicatedElem(keys)
+	if 1 > len(keys) {
+		return
+	}
+
+	var items []*CitationItem
+	switch Conf.Citation.Source {
+	case conf.CitationSourceZotero:
+		items, err = zoteroExport(keys)
+	case conf.CitationSourceCSL:
+		items, err = loadCSLLibrary()
+	default:
+		err = errCitationNotConfigured()
+	}
+	if nil != err {
+		return
+	}
+
+	for _, item := range items {
+		ret[item.Key()] = item
+	}
+	for _, key := range keys {
+		if nil == ret[key] {
+			missing = append(missing, key)
+		}
+	}
+	for key := range ret {
+		if !gulu.Str.Contains(key, keys) {
+			delete(ret, key)
+		}
+	}
+	return
+}
+
+// InsertCitation 在 previousID 之后（previousID 为空时在 parentID 末尾）插入文献引用块。
+func InsertCitation(keys []string, parentID, previousID string) (id string, err error) {
+	var cleanKeys []string
+	for _, key := range keys {
+		key = strings.TrimPrefix(strings.TrimSpace(key), "@")
+		if "" != key && !strings.Contains(key, ",") {
+			cleanKeys = append(cleanKeys, key)
+		}
+	}
+	keys = gulu.Str.RemoveDuplicatedElem(cleanKeys)
+	if 1 > len(keys) {
+		err = errors.New("citation keys are empty")
+		return
+	}
+
+	refID := previousID
+	if "" == refID {
+		refID = parentID
+	}
+	tree, err := LoadTreeByBlockID(refID)
+	if nil != err {
+		return
+	}
+
+	items, missing, err := GetCitationItems(keys)
+	if nil != err {
+		return
+	}
+	if 0 < len(missing) {
+		err = fmt.Errorf(Conf.Language(254), strings.Join(missing, ", "))
+		return
+	}
+
+	// 编号样式下按文档中已有的引用顺序编号，导出时会重新编号
+	numbers := citationNumbers(append(collectCitationKeys(tree), keys...))
+	var citeItems []*CitationItem
+	for _, key := range keys {
+		citeItems = append(citeItems, items[key])
+	}
+
+	p := treenode.NewParagraph()
+	p.SetIALAttr(CitationBlockAttr, strings.Join(keys, ","))
+	p.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(formatInTextCitation(Conf.Citation.Style, citeItems, numbers))})
+	id = p.ID
+
+	luteEngine := util.NewLute()
+	op := &Operation{Action: "appendInsert", ParentID: parentID, Data: luteEngine.RenderNodeBlockDOM(p)}
+	if "" != previousID {
+		op = &Operation{Action: "insert", PreviousID: previousID, Data: op.Data}
+	}
+	performStreamTx([]*Operation{op})
+	return
+}
+
+// GetBibliography 返回块所在文档的参考文献，每条为一段 Markdown。
+func GetBibliography(id string) (ret []string, missing []string, err error) {
+	ret = []string{}
+	tree, err := LoadTreeByBlockID(id)
+	if nil != err {
+		return
+	}
+
+	keys := collectCitationKeys(tree)
+	items, missing, err := GetCitationItems(keys)
+	if nil != err {
+		return
+	}
+	ret = formatBibliography(Conf.Citation.Style, keys, items)
+	return
+}
+
+// exportCitations 在导出时按当前样式重新渲染文档中的引用，并在文末追加参考文献。
+func exportCitations(tree *parse.Tree) {
+	if "" == Conf.Citation.Source {
+		return
+	}
+
+	keys := collectCitationKeys(tree)
+	if 1 > len(keys) {
+		return
+	}
+
+	items, missing, err := GetCitationItems(keys)
+	if nil != err {
+		logging.LogWarnf("get citation items for exporting [%s] failed: %s", tree.ID, err)
+		return
+	}
+	if 0 < len(missing) {
+		logging.LogWarnf("citation keys [%s] not found when exporting [%s]", strings.Join(missing, ", "), tree.ID)
+	}
+
+	style := Conf.Citation.Style
+	var resolved []string
+	for _, key := range keys {
+		if nil != items[key] {
+			resolved = append(resolved, key)
+		}
+	}
+	numbers := citationNumbers(resolved)
+	lookup := func(keys []string) (ret []*CitationItem) {
+		for _, key := range keys {
+			if item := items[key]; nil != item {
+				ret = append(ret, item)
+			}
+		}
+		return
+	}
+
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if n.IsBlock() {
+			if attr := n.IALAttr(CitationBlockAttr); "" != attr {
+				if citeItems := lookup(strings.Split(attr, ",")); 0 < len(citeItems) {
+					for c := n.FirstChild; nil != c; {
+						next := c.Next
+						c.Unlink()
+						c = next
+					}
+					n.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(formatInTextCitation(style, citeItems, numbers))})
+				}
+				return ast.WalkSkipChildren
+			}
+			return ast.WalkContinue
+		}
+
+		if ast.NodeText == n.Type {
+			n.Tokens = citationInlineRegexp.ReplaceAllFunc(n.Tokens, func(match []byte) []byte {
+				citeKeys := parseInlineCitationKeys(string(match))
+				citeItems := lookup(citeKeys)
+				if len(citeItems) != len(citeKeys) {
+					return match
+				}
+				return []byte(formatInTextCitation(style, citeItems, numbers))
+			})
+		}
+		return ast.WalkContinue
+	})
+
+	entries := formatBibliography(style, resolved, items)
+	if 1 > len(entries) {
+		return
+	}
+
+	title := Conf.Citation.BibliographyTitle
+	if "" == title {
+		title = Conf.Language(253)
+	}
+	heading := &ast.Node{Type: ast.NodeHeading, HeadingLevel: 2, ID: ast.NewNodeID()}
+	heading.SetIALAttr("id", heading.ID)
+	heading.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(title)})
+	tree.Root.AppendChild(heading)
+
+	luteEngine := util.NewLute()
+	for _, entry := range entries {
+		entryTree := parse.Parse("", []byte(entry), luteEngine.ParseOptions)
+		if nil == entryTree || nil == entryTree.Root.FirstChild {
+			continue
+		}
+		for c := entryTree.Root.FirstChild; nil != c; {
+			next := c.Next
+			if ast.NodeKramdownBlockIAL != c.Type {
+				tree.Root.AppendChild(c)
+			}
+			c = next
+		}
+	}
+}
+
+// collectCitationKeys 按首次出现的顺序收集文档中引用块和正文引用的引用键。
+func collectCitationKeys(tree *parse.Tree) (ret []string) {
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if n.IsBlock() {
+			if attr := n.IALAttr(CitationBlockAttr); "" != attr {
+				ret = append(ret, strings.Split(attr, ",")...)
+				return ast.WalkSkipChildren
+			}
+			return ast.WalkContinue
+		}
+
+		if ast.NodeText == n.Type {
+			for _, match := range citationInlineRegexp.FindAll(n.Tokens, -1) {
+				ret = append(ret, parseInlineCitationKeys(string(match))...)
+			}
+		}
+		return ast.WalkContinue
+	})
+	ret = gulu.Str.RemoveDuplicatedElem(ret)
+	return
+}
+
+func parseInlineCitationKeys(citation string) (ret []string) {
+	citation = strings.TrimSuffix(strings.TrimPrefix(citation, "["), "]")
+	for _, key := range strings.Split(citation, ";") {
+		if key = strings.TrimPrefix(strings.TrimSpace(key), "@"); "" != key {
+			ret = append(ret, key)
+		}
+	}
+	return
+}
+
+func citationNumbers(keys []string) (ret map[string]int) {
+	ret = map[string]int{}
+	for _, key := range keys {
+		if _, ok := ret[key]; !ok {
+			ret[key] = len(ret) + 1
+		}
+	}
+	return
+}
+
+func formatBibliography(style string, keys []string, items map[string]*CitationItem) (ret []string) {
+	ret = []string{}
+	var sorted []*CitationItem
+	for _, key := range keys {
+		if item := items[key]; nil != item {
+			sorted = append(sorted, item)
+		}
+	}
+	if !isNumericCitationStyle(style) {
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].sortKey() < sorted[j].sortKey() })
+	}
+	for i, item := range sorted {
+		ret = append(ret, formatBibliographyEntry(style, item, i+1))
+	}
+	return
+}
+
+var (
+	cslLibrary        []*CitationItem
+	cslLibraryPath    string
+	cslLibraryModTime time.Time
+	cslLibraryLock    = sync.Mutex{}
+)
+
+// loadCSLLibrary 加载 CSL JSON 文献库，文件未修改时使用缓存。
+func loadCSLLibrary() (ret []*CitationItem, err error) {
+	cslLibraryLock.Lock()
+	defer cslLibraryLock.Unlock()
+
+	p := Conf.Citation.LibraryPath
+	if "" == p {
+		err = errCitationNotConfigured()
+		return
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(util.DataDir, p)
+	}
+
+	info, err := os.Stat(p)
+	if nil != err {
+		logging.LogErrorf("stat CSL JSON library [%s] failed: %s", p, err)
+		return
+	}
+	if p == cslLibraryPath && info.ModTime().Equal(cslLibraryModTime) {
+		ret = cslLibrary
+		return
+	}
+
+	data, err := os.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read CSL JSON library [%s] failed: %s", p, err)
+		return
+	}
+	ret = []*CitationItem{}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal CSL JSON library [%s] failed: %s", p, err)
+		return
+	}
+
+	cslLibrary, cslLibraryPath, cslLibraryModTime = ret, p, info.ModTime()
+	return
+}
+
+// zoteroSearch 通过 Better BibTeX 的 JSON-RPC 接口搜索 Zotero 文献库。
+func zoteroSearch(keyword string) (ret []*CitationItem, err error) {
+	result, err := zoteroCall("item.search", []interface{}{keyword})
+	if nil != err {
+		return
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(result, &ret); nil != err {
+		logging.LogErrorf("unmarshal Zotero search result failed: %s", err)
+	}
+	return
+}
+
+// zoteroExport 通过 Better BibTeX 按引用键导出 CSL JSON。
+func zoteroExport(keys []string) (ret []*CitationItem, err error) {
+	result, err := zoteroCall("item.export", []interface{}{keys, "Better CSL JSON"})
+	if nil != err {
+		return
+	}
+
+	// 旧版 Better BibTeX 返回 [状态码, 类型, 内容]，新版直接返回内容
+	var exported string
+	if err = gulu.JSON.UnmarshalJSON(result, &exported); nil != err {
+		var tuple []interface{}
+		if err = gulu.JSON.UnmarshalJSON(result, &tuple); nil != err || 3 > len(tuple) {
+			err = fmt.Errorf("unexpected Zotero export result [%s]", result)
+			return
+		}
+		exported, _ = tuple[2].(string)
+	}
+
+	ret = []*CitationItem{}
+	if err = gulu.JSON.UnmarshalJSON([]byte(exported), &ret); nil != err {
+		logging.LogErrorf("unmarshal Zotero export result failed: %s", err)
+	}
+	return
+}
+
+func zoteroCall(method string, params []interface{}) (ret []byte, err error) {
+	var result struct {
+		Result interface{} `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	payload := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params, "id": strconv.FormatInt(time.Now().UnixMilli(), 10)}
+	resp, err := httpclient.NewBrowserRequest().SetSuccessResult(&result).SetBody(payload).Post(Conf.Citation.ZoteroURL)
+	if nil != err {
+		logging.LogErrorf("call Zotero [%s] failed: %s", method, err)
+		err = fmt.Errorf(Conf.Language(255), err)
+		return
+	}
+	if 200 != resp.StatusCode {
+		err = fmt.Errorf(Conf.Language(255), "status code "+strconv.Itoa(resp.StatusCode))
+		return
+	}
+	if nil != result.Error {
+		err = fmt.Errorf(Conf.Language(255), result.Error.Message)
+		return
+	}
+	ret, err = gulu.JSON.MarshalJSON(result.Result)
+	return
+}
@@ -0,0 +1,322 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 内置的引用样式，按 CSL 样式 ID 命名。这里只实现了各样式的常用规则，不解析 CSL 样式文件。
+const (
+	CitationStyleAPA     = "apa"
+	CitationStyleChicago = "chicago-author-date"
+	CitationStyleMLA     = "mla"
+	CitationStyleIEEE    = "ieee"
+	CitationStyleHarvard = "harvard"
+)
+
+var citationStyles = []string{CitationStyleAPA, CitationStyleChicago, CitationStyleMLA, CitationStyleIEEE, CitationStyleHarvard}
+
+func isValidCitationStyle(style string) bool {
+	for _, s := range citationStyles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumericCitationStyle 判断样式是否按引用顺序编号，编号样式的参考文献按首次引用顺序排列，其他样式按作者排序。
+func isNumericCitationStyle(style string) bool {
+	return CitationStyleIEEE == style
+}
+
+// formatInTextCitation 渲染正文中的引用，numbers 为编号样式下各文献的编号。
+func formatInTextCitation(style string, items []*CitationItem, numbers map[string]int) string {
+	if isNumericCitationStyle(style) {
+		var parts []string
+		for _, item := range items {
+			parts = append(parts, "["+strconv.Itoa(numbers[item.ID])+"]")
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	var parts []string
+	for _, item := range items {
+		names := citationShortNames(item.Author, style)
+		if "" == names {
+			names = item.Title
+		}
+		year := item.Year()
+		switch style {
+		case CitationStyleMLA:
+			parts = append(parts, names)
+		case CitationStyleChicago, CitationStyleHarvard:
+			parts = append(parts, strings.TrimSpace(names+" "+year))
+		default:
+			parts = append(parts, names+", "+year)
+		}
+	}
+	return "(" + strings.Join(parts, "; ") + ")"
+}
+
+// citationShortNames 渲染正文引用中的作者，超过两位（APA 为两位以上）时使用 et al.。
+func citationShortNames(names []*CitationName, style string) string {
+	if 1 > len(names) {
+		return ""
+	}
+
+	and := " and "
+	if CitationStyleAPA == style {
+		and = " & "
+	}
+	switch len(names) {
+	case 1:
+		return names[0].FamilyOrLiteral()
+	case 2:
+		return names[0].FamilyOrLiteral() + and + names[1].FamilyOrLiteral()
+	}
+	return names[0].FamilyOrLiteral() + " et al."
+}
+
+// formatBibliographyEntry 渲染一条参考文献，返回 Markdown。
+func formatBibliographyEntry(style string, item *CitationItem, number int) string {
+	title := escapeCitationMarkdown(item.Title)
+	container := escapeCitationMarkdown(string(item.ContainerTitle))
+	publisher := escapeCitationMarkdown(string(item.Publisher))
+	volume, issue, page := string(item.Volume), string(item.Issue), string(item.Page)
+	year := item.Year()
+	isBook := "book" == item.Type || ("" == container && "" != publisher)
+
+	buf := &bytes.Buffer{}
+	switch style {
+	case CitationStyleIEEE:
+		buf.WriteString(fmt.Sprintf("[%d] ", number))
+		buf.WriteString(joinCitationNames(item.Author, citationInitialsFirst, ", ", " and ", ", and "))
+		if isBook {
+			buf.WriteString(", *" + title + "*")
+			if "" != publisher {
+				buf.WriteString(". " + publisher)
+			}
+		} else {
+			buf.WriteString(", “" + title + ",”")
+			if "" != container {
+				buf.WriteString(" *" + container + "*")
+			}
+			if "" != volume {
+				buf.WriteString(", vol. " + volume)
+			}
+			if "" != issue {
+				buf.WriteString(", no. " + issue)
+			}
+			if "" != page {
+				buf.WriteString(", pp. " + page)
+			}
+		}
+		if "" != year {
+			buf.WriteString(", " + year)
+		}
+		buf.WriteString(".")
+	case CitationStyleMLA:
+		buf.WriteString(mlaNames(item.Author))
+		if isBook {
+			buf.WriteString("*" + title + "*.")
+			if "" != publisher {
+				buf.WriteString(" " + publisher + ",")
+			}
+			if "" != year {
+				buf.WriteString(" " + year)
+			}
+			buf.WriteString(".")
+			break
+		}
+		buf.WriteString("“" + title + ".”")
+		if "" != container {
+			buf.WriteString(" *" + container + "*,")
+		}
+		if "" != volume {
+			buf.WriteString(" vol. " + volume + ",")
+		}
+		if "" != issue {
+			buf.WriteString(" no. " + issue + ",")
+		}
+		if "" != year {
+			buf.WriteString(" " + year + ",")
+		}
+		if "" != page {
+			buf.WriteString(" pp. " + page + ",")
+		}
+		buf.Truncate(len(strings.TrimSuffix(buf.String(), ",")))
+		buf.WriteString(".")
+	case CitationStyleChicago:
+		buf.WriteString(joinCitationNames(item.Author, citationFirstFamilyRestGiven, ", ", ", and ", ", and "))
+		buf.WriteString(". " + citationYearOrND(year) + ". ")
+		if isBook {
+			buf.WriteString("*" + title + "*.")
+			if "" != publisher {
+				buf.WriteString(" " + publisher + ".")
+			}
+		} else {
+			buf.WriteString("“" + title + ".”")
+			if "" != container {
+				buf.WriteString(" *" + container + "*")
+			}
+			if "" != volume {
+				buf.WriteString(" " + volume)
+			}
+			if "" != issue {
+				buf.WriteString(" (" + issue + ")")
+			}
+			if "" != page {
+				buf.WriteString(": " + page)
+			}
+			buf.WriteString(".")
+		}
+	case CitationStyleHarvard:
+		buf.WriteString(joinCitationNames(item.Author, citationFamilyInitials, ", ", " and ", " and "))
+		buf.WriteString(" (" + citationYearOrND(year) + ") ")
+		if isBook {
+			buf.WriteString("*" + title + "*.")
+			if "" != publisher {
+				buf.WriteString(" " + publisher + ".")
+			}
+		} else {
+			buf.WriteString("‘" + title + "’")
+			if "" != container {
+				buf.WriteString(", *" + container + "*")
+			}
+			if "" != volume {
+				buf.WriteString(", " + volume)
+				if "" != issue {
+					buf.WriteString("(" + issue + ")")
+				}
+			}
+			if "" != page {
+				buf.WriteString(", pp. " + page)
+			}
+			buf.WriteString(".")
+		}
+	default: // APA
+		buf.WriteString(joinCitationNames(item.Author, citationFamilyInitials, ", ", ", & ", ", & "))
+		buf.WriteString(" (" + citationYearOrND(year) + "). ")
+		if isBook {
+			buf.WriteString("*" + title + "*.")
+			if "" != publisher {
+				buf.WriteString(" " + publisher + ".")
+			}
+		} else {
+			buf.WriteString(title + ".")
+			if "" != container {
+				buf.WriteString(" *" + container + "*")
+				if "" != volume {
+					buf.WriteString(", *" + volume + "*")
+					if "" != issue {
+						buf.WriteString("(" + issue + ")")
+					}
+				}
+				if "" != page {
+					buf.WriteString(", " + page)
+				}
+				buf.WriteString(".")
+			}
+		}
+	}
+
+	if doi := string(item.DOI); "" != doi {
+		buf.WriteString(" https://doi.org/" + strings.TrimPrefix(doi, "https://doi.org/"))
+	} else if url := string(item.URL); "" != url {
+		buf.WriteString(" " + url)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+const (
+	citationFamilyInitials       = iota // Smith, J. A.
+	citationInitialsFirst               // J. A. Smith
+	citationFirstFamilyRestGiven        // Smith, John, and Alice Doe
+)
+
+func joinCitationNames(names []*CitationName, format int, sep, lastSep, serialLastSep string) string {
+	var parts []string
+	for i, name := range names {
+		if "" != name.Literal {
+			parts = append(parts, name.Literal)
+			continue
+		}
+
+		switch format {
+		case citationInitialsFirst:
+			parts = append(parts, strings.TrimSpace(citationInitials(name.Given)+" "+name.Family))
+		case citationFirstFamilyRestGiven:
+			if 0 == i {
+				parts = append(parts, strings.TrimSuffix(name.Family+", "+name.Given, ", "))
+			} else {
+				parts = append(parts, strings.TrimSpace(name.Given+" "+name.Family))
+			}
+		default:
+			parts = append(parts, strings.TrimSuffix(name.Family+", "+citationInitials(name.Given), ", "))
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return parts[0]
+	case 2:
+		return parts[0] + lastSep + parts[1]
+	}
+	return strings.Join(parts[:len(parts)-1], sep) + serialLastSep + parts[len(parts)-1]
+}
+
+func mlaNames(names []*CitationName) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return joinCitationNames(names, citationFirstFamilyRestGiven, "", "", "") + ". "
+	case 2:
+		return joinCitationNames(names, citationFirstFamilyRestGiven, ", ", ", and ", ", and ") + ". "
+	}
+	return joinCitationNames(names[:1], citationFirstFamilyRestGiven, "", "", "") + ", et al. "
+}
+
+func citationInitials(given string) string {
+	var initials []string
+	for _, part := range strings.Fields(given) {
+		if r := []rune(part); 0 < len(r) {
+			initials = append(initials, string(r[0])+".")
+		}
+	}
+	return strings.Join(initials, " ")
+}
+
+func citationYearOrND(year string) string {
+	if "" == year {
+		return "n.d."
+	}
+	return year
+}
+
+var citationMarkdownReplacer = strings.NewReplacer("\\", "\\\\", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]", "<", "\\<", ">", "\\>", "`", "\\`", "#", "\\#")
+
+func escapeCitationMarkdown(s string) string {
+	return citationMarkdownReplacer.Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,427 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 协同编辑：
+//
+//  1. 每个文档维护一个修订号，每次事务提交后递增，并记录最近提交的变更；
+//  2. 客户端提交事务时携带所基于的修订号，内核在事务队列中按提交顺序将事务变换到最新修订上：
+//     - 并发删除的块上的修改、移动和删除操作会被丢弃（删除优先）
+//     - 插入和移动的锚点块被并发删除时，锚点重定位到被删除块原来的前一个兄弟块或父块
+//     - 对同一个块的并发更新按服务端顺序后写者胜出
+//  3. 变换后的事务按原有方式广播，所有客户端收敛到服务端顺序上的同一状态；
+//  4. 数据同步导致文档变化时修订历史失效，在线编辑该文档的客户端会被通知重新加载。
+
+const (
+	collabPresenceTTL = 30 * time.Second // 在线状态过期时间
+	collabLogTTL      = 10 * time.Minute // 变更记录保留时间
+	collabLogMaxLen   = 256              // 每个文档最多保留的变更记录数
+)
+
+// CollabPresence 描述了协同编辑时某个客户端的在线状态。
+type CollabPresence struct {
+	App     string `json:"app"`
+	Session string `json:"session"`
+	User    string `json:"user"`
+	RootID  string `json:"rootID"`
+	BlockID string `json:"blockID"` // 光标所在块
+	Updated int64  `json:"updated"`
+}
+
+// CollabConflict 描述了事务变换时遇到的一次冲突。
+type CollabConflict struct {
+	ID         string `json:"id"`
+	Action     string `json:"action"`
+	Resolution string `json:"resolution"` // dropped：操作被丢弃，overwritten：覆盖了并发更新，rebased：锚点被重定位
+}
+
+type collabAnchor struct {
+	PreviousID string
+	ParentID   string
+}
+
+type collabChange struct {
+	Rev     int64
+	Session string
+	Created time.Time
+	Deleted map[string]*collabAnchor
+	Updated map[string]bool
+}
+
+type collabDoc struct {
+	Rev      int64
+	Baseline int64 // 早于该修订号的变更记录已经不可用
+	Changes  []*collabChange
+}
+
+var (
+	collabDocs      = map[string]*collabDoc{}
+	collabDocsLock  = sync.Mutex{}
+	collabPresences = map[string]*CollabPresence{}
+	collabPresLock  = sync.Mutex{}
+)
+
+func init() {
+	eventbus.Subscribe(util.EvtSyncFinished, func(evt *SyncFinishedEvent) {
+		if evt.DataChanged {
+			resetCollab()
+		}
+	})
+
+	go func() {
+		for range time.Tick(10 * time.Second) {
+			sweepCollab()
+		}
+	}()
+}
+
+// GetCollabDocRev 返回文档当前的修订号。
+func GetCollabDocRev(rootID string) int64 {
+	collabDocsLock.Lock()
+	defer collabDocsLock.Unlock()
+
+	if doc := collabDocs[rootID]; nil != doc {
+		return doc.Rev
+	}
+	return 0
+}
+
+// collabTransform 将事务变换到文档的最新修订上，需要在事务队列中调用。
+func collabTransform(tx *Transaction) {
+	if "" == tx.RootID {
+		return
+	}
+
+	collabDocsLock.Lock()
+	doc := collabDocs[tx.RootID]
+	var changes []*collabChange
+	stale := 0 < tx.BaseRev // 内核重启后客户端持有的修订号已经失效
+	if nil != doc {
+		stale = tx.BaseRev < doc.Baseline || tx.BaseRev > doc.Rev
+		for _, change := range doc.Changes {
+			if change.Rev > tx.BaseRev && change.Session != tx.session {
+				changes = append(changes, change)
+			}
+		}
+	}
+	collabDocsLock.Unlock()
+
+	if 1 > len(changes) && !stale {
+		return
+	}
+
+	t := &collabTransformer{tx: tx, deleted: map[string]*collabAnchor{}, updated: map[string]bool{}, created: map[string]bool{}}
+	for _, change := range changes {
+		for id, anchor := range change.Deleted {
+			t.deleted[id] = anchor
+		}
+		for id := range change.Updated {
+			t.updated[id] = true
+		}
+	}
+
+	var ops []*Operation
+	for _, op := range tx.DoOperations {
+		if t.transform(op) {
+			ops = append(ops, op)
+		}
+	}
+	tx.DoOperations = ops
+	if stale {
+		// 修订历史已经不可用，只能校验后应用，客户端需要重新加载文档
+		util.PushReloadDoc(tx.RootID)
+	}
+}
+
+type collabTransformer struct {
+	tx      *Transaction
+	deleted map[string]*collabAnchor // 并发删除的块
+	updated map[string]bool          // 并发更新的块
+	created map[string]bool          // 本事务中已经插入的块
+}
+
+func (t *collabTransformer) transform(op *Operation) bool {
+	switch op.Action {
+	case "update", "delete", "setAttrs", "foldHeading", "unfoldHeading", "doUpdateUpdated":
+		if !t.exists(op.ID) {
+			t.tx.addConflict(op, "dropped")
+			return false
+		}
+		if "update" == op.Action && t.updated[op.ID] {
+			t.tx.addConflict(op, "overwritten")
+		}
+	case "move":
+		if !t.exists(op.ID) || !t.rebase(op) {
+			t.tx.addConflict(op, "dropped")
+			return false
+		}
+	case "insert", "appendInsert", "prependInsert":
+		if !t.rebase(op) {
+			t.tx.addConflict(op, "dropped")
+			return false
+		}
+		t.created[op.ID] = true
+	}
+	return true
+}
+
+// rebase 在锚点块不存在时重定位插入或移动位置，无法重定位时返回 false。
+func (t *collabTransformer) rebase(op *Operation) bool {
+	rebased := false
+	if "" != op.PreviousID && !t.exists(op.PreviousID) {
+		previousID, parentID := t.resolve(op.PreviousID)
+		op.PreviousID = previousID
+		if "" == previousID && "" != parentID {
+			op.ParentID = parentID
+		}
+		rebased = true
+	}
+	if "" != op.NextID && !t.exists(op.NextID) {
+		op.NextID = ""
+		rebased = true
+	}
+	if "" != op.ParentID && !t.exists(op.ParentID) {
+		_, op.ParentID = t.resolve(op.ParentID)
+		rebased = true
+	}
+
+	if "" == op.PreviousID && "" == op.NextID && "" == op.ParentID {
+		if !t.exists(t.tx.RootID) {
+			return false
+		}
+		// 找不到任何锚点时插入到文档开头，避免丢失内容
+		op.ParentID = t.tx.RootID
+		rebased = true
+	}
+	if rebased {
+		t.tx.addConflict(op, "rebased")
+	}
+	return true
+}
+
+// resolve 沿着被删除块原来的位置向前查找仍然存在的兄弟块或父块。
+func (t *collabTransformer) resolve(id string) (previousID, parentID string) {
+	visited := map[string]bool{}
+	for "" != id && !visited[id] {
+		visited[id] = true
+		anchor := t.deleted[id]
+		if nil == anchor {
+			return
+		}
+
+		if "" != anchor.PreviousID {
+			if t.exists(anchor.PreviousID) {
+				previousID = anchor.PreviousID
+				return
+			}
+			id = anchor.PreviousID
+			continue
+		}
+		if t.exists(anchor.ParentID) {
+			parentID = anchor.ParentID
+			return
+		}
+		id = anchor.ParentID
+	}
+	return
+}
+
+func (t *collabTransformer) exists(id string) bool {
+	if "" == id || nil != t.deleted[id] {
+		return false
+	}
+	return t.created[id] || nil != treenode.GetBlockTree(id)
+}
+
+func (tx *Transaction) addConflict(op *Operation, resolution string) {
+	tx.Conflicts = append(tx.Conflicts, &CollabConflict{ID: op.ID, Action: op.Action, Resolution: resolution})
+}
+
+// collabCommit 在事务提交后递增涉及文档的修订号并记录变更，需要在事务队列中调用。
+func collabCommit(tx *Transaction) {
+	if 1 > len(tx.trees) {
+		return
+	}
+
+	deleted := map[string]*collabAnchor{}
+	updated := map[string]bool{}
+	for _, op := range tx.DoOperations {
+		switch op.Action {
+		case "delete":
+			deleted[op.ID] = &collabAnchor{}
+		case "update", "setAttrs":
+			updated[op.ID] = true
+		}
+	}
+	for _, op := range tx.UndoOperations {
+		if anchor := deleted[op.ID]; nil != anchor && "insert" == op.Action {
+			anchor.PreviousID, anchor.ParentID = op.PreviousID, op.ParentID
+		}
+	}
+
+	now := time.Now()
+	collabDocsLock.Lock()
+	defer collabDocsLock.Unlock()
+	for rootID := range tx.trees {
+		doc := collabDocs[rootID]
+		if nil == doc {
+			doc = &collabDoc{}
+			collabDocs[rootID] = doc
+		}
+		doc.Rev++
+		doc.Changes = append(doc.Changes, &collabChange{Rev: doc.Rev, Session: tx.session, Created: now, Deleted: deleted, Updated: updated})
+		if collabLogMaxLen < len(doc.Changes) {
+			doc.Changes = doc.Changes[len(doc.Changes)-collabLogMaxLen:]
+			doc.Baseline = doc.Changes[0].Rev - 1
+		}
+		if rootID == tx.RootID {
+			tx.Rev = doc.Rev
+		}
+	}
+}
+
+func resetCollab() {
+	collabDocsLock.Lock()
+	for _, doc := range collabDocs {
+		doc.Rev++
+		doc.Baseline = doc.Rev
+		doc.Changes = nil
+	}
+	collabDocsLock.Unlock()
+
+	for rootID := range collabPresenceRoots() {
+		util.PushReloadDoc(rootID)
+	}
+}
+
+// resetCollabDoc 在事务队列之外修改文档后使文档的修订历史失效。
+func resetCollabDoc(rootID string) {
+	collabDocsLock.Lock()
+	defer collabDocsLock.Unlock()
+
+	if doc := collabDocs[rootID]; nil != doc {
+		doc.Rev++
+		doc.Baseline = doc.Rev
+		doc.Changes = nil
+	}
+}
+
+func sweepCollab() {
+	now := time.Now()
+	collabDocsLock.Lock()
+	for _, doc := range collabDocs {
+		i := 0
+		for ; i < len(doc.Changes) && now.Sub(doc.Changes[i].Created) > collabLogTTL; i++ {
+		}
+		if 0 < i {
+			doc.Baseline = doc.Changes[i-1].Rev
+			doc.Changes = doc.Changes[i:]
+		}
+	}
+	collabDocsLock.Unlock()
+
+	expired := map[string]bool{}
+	collabPresLock.Lock()
+	for session, presence := range collabPresences {
+		if now.UnixMilli()-presence.Updated > collabPresenceTTL.Milliseconds() {
+			delete(collabPresences, session)
+			expired[presence.RootID] = true
+		}
+	}
+	collabPresLock.Unlock()
+
+	for rootID := range expired {
+		pushCollabPresences(rootID, "")
+	}
+}
+
+// SetCollabPresence 更新客户端的在线状态并广播给其他客户端。
+func SetCollabPresence(presence *CollabPresence) (rev int64, ret []*CollabPresence) {
+	presence.Updated = time.Now().UnixMilli()
+
+	collabPresLock.Lock()
+	old := collabPresences[presence.Session]
+	collabPresences[presence.Session] = presence
+	collabPresLock.Unlock()
+
+	if nil != old && old.RootID != presence.RootID {
+		pushCollabPresences(old.RootID, presence.Session)
+	}
+	if nil == old || old.RootID != presence.RootID || old.BlockID != presence.BlockID {
+		pushCollabPresences(presence.RootID, presence.Session)
+	}
+	return GetCollabDocRev(presence.RootID), GetCollabPresences(presence.RootID)
+}
+
+// RemoveCollabPresence 移除客户端的在线状态，客户端关闭文档或者断开连接时调用。
+func RemoveCollabPresence(session string) {
+	collabPresLock.Lock()
+	old := collabPresences[session]
+	delete(collabPresences, session)
+	collabPresLock.Unlock()
+
+	if nil != old {
+		pushCollabPresences(old.RootID, session)
+	}
+}
+
+// GetCollabPresences 返回正在编辑文档的客户端在线状态。
+func GetCollabPresences(rootID string) (ret []*CollabPresence) {
+	ret = []*CollabPresence{}
+	collabPresLock.Lock()
+	for _, presence := range collabPresences {
+		if presence.RootID == rootID {
+			p := *presence
+			ret = append(ret, &p)
+		}
+	}
+	collabPresLock.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Session < ret[j].Session })
+	return
+}
+
+func collabPresenceRoots() (ret map[string]bool) {
+	ret = map[string]bool{}
+	collabPresLock.Lock()
+	defer collabPresLock.Unlock()
+	for _, presence := range collabPresences {
+		ret[presence.RootID] = true
+	}
+	return
+}
+
+func pushCollabPresences(rootID, excludeSession string) {
+	evt := util.NewCmdResult("collabPresence", 0, util.PushModeBroadcastExcludeSelf)
+	evt.SessionId = excludeSession
+	evt.Data = map[string]interface{}{
+		"rootID":    rootID,
+		"rev":       GetCollabDocRev(rootID),
+		"presences": GetCollabPresences(rootID),
+	}
+	util.PushEvent(evt)
+}
@@ -39,6 +39,7 @@ import (
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -50,37 +51,47 @@ var Conf *AppConf
 
 // AppConf 维护应用元数据，保存在 ~/.siyuan/conf.json。
 type AppConf struct {
-	LogLevel       string           `json:"logLevel"`       // 日志级别：Off, Trace, Debug, Info, Warn, Error, Fatal
-	Appearance     *conf.Appearance `json:"appearance"`     // 外观
-	Langs          []*conf.Lang     `json:"langs"`          // 界面语言列表
-	Lang           string           `json:"lang"`           // 选择的界面语言，同 Appearance.Lang
-	FileTree       *conf.FileTree   `json:"fileTree"`       // 文档面板
-	Tag            *conf.Tag        `json:"tag"`            // 标签面板
-	Editor         *conf.Editor     `json:"editor"`         // 编辑器配置
-	Export         *conf.Export     `json:"export"`         // 导出配置
-	Graph          *conf.Graph      `json:"graph"`          // 关系图配置
-	UILayout       *conf.UILayout   `json:"uiLayout"`       // 界面布局。不要直接使用，使用 GetUILayout() 和 SetUILayout() 方法
-	UserData       string           `json:"userData"`       // 社区用户信息，对 User 加密存储
-	User           *conf.User       `json:"-"`              // 社区用户内存结构，不持久化。不要直接使用，使用 GetUser() 和 SetUser() 方法
-	Account        *conf.Account    `json:"account"`        // 帐号配置
-	ReadOnly       bool             `json:"readonly"`       // 是否是以只读模式运行
-	LocalIPs       []string         `json:"localIPs"`       // 本地 IP 列表
-	AccessAuthCode string           `json:"accessAuthCode"` // 访问授权码
-	System         *conf.System     `json:"system"`         // 系统配置
-	Keymap         *conf.Keymap     `json:"keymap"`         // 快捷键配置
-	Sync           *conf.Sync       `json:"sync"`           // 同步配置
-	Search         *conf.Search     `json:"search"`         // 搜索配置
-	Flashcard      *conf.Flashcard  `json:"flashcard"`      // 闪卡配置
-	AI             *conf.AI         `json:"ai"`             // 人工智能配置
-	Bazaar         *conf.Bazaar     `json:"bazaar"`         // 集市配置
-	Stat           *conf.Stat       `json:"stat"`           // 统计
-	Api            *conf.API        `json:"api"`            // API
-	Repo           *conf.Repo       `json:"repo"`           // 数据仓库
-	OpenHelp       bool             `json:"openHelp"`       // 启动后是否需要打开用户指南
-	ShowChangelog  bool             `json:"showChangelog"`  // 是否显示版本更新日志
-	CloudRegion    int              `json:"cloudRegion"`    // 云端区域，0：中国大陆，1：北美
-	Snippet        *conf.Snpt       `json:"snippet"`        // 代码片段
-	State          int              `json:"state"`          // 运行状态，0：已经正常退出，1：运行中
+	LogLevel       string            `json:"logLevel"`       // 日志级别：Off, Trace, Debug, Info, Warn, Error, Fatal
+	Appearance     *conf.Appearance  `json:"appearance"`     // 外观
+	Langs          []*conf.Lang      `json:"langs"`          // 界面语言列表
+	Lang           string            `json:"lang"`           // 选择的界面语言，同 Appearance.Lang
+	FileTree       *conf.FileTree    `json:"fileTree"`       // 文档面板
+	Tag            *conf.Tag         `json:"tag"`            // 标签面板
+	Editor         *conf.Editor      `json:"editor"`         // 编辑器配置
+	Export         *conf.Export      `json:"export"`         // 导出配置
+	Graph          *conf.Graph       `json:"graph"`          // 关系图配置
+	UILayout       *conf.UILayout    `json:"uiLayout"`       // 界面布局。不要直接使用，使用 GetUILayout() 和 SetUILayout() 方法
+	UserData       string            `json:"userData"`       // 社区用户信息，对 User 加密存储
+	User           *conf.User        `json:"-"`              // 社区用户内存结构，不持久化。不要直接使用，使用 GetUser() 和 SetUser() 方法
+	Account        *conf.Account     `json:"account"`        // 帐号配置
+	ReadOnly       bool              `json:"readonly"`       // 是否是以只读模式运行
+	LocalIPs       []string          `json:"localIPs"`       // 本地 IP 列表
+	AccessAuthCode string            `json:"accessAuthCode"` // 访问授权码
+	System         *conf.System      `json:"system"`         // 系统配置
+	Keymap         *conf.Keymap      `json:"keymap"`         // 快捷键配置
+	Sync           *conf.Sync        `json:"sync"`           // 同步配置
+	Search         *conf.Search      `json:"search"`         // 搜索配置
+	Flashcard      *conf.Flashcard   `json:"flashcard"`      // 闪卡配置
+	AI             *conf.AI          `json:"ai"`             // 人工智能配置
+	Bazaar         *conf.Bazaar      `json:"bazaar"`         // 集市配置
+	Stat           *conf.Stat        `json:"stat"`           // 统计
+	Api            *conf.API         `json:"api"`            // API
+	OIDC           *conf.OIDC        `json:"oidc"`           // OpenID Connect 单点登录
+	TOTP           *conf.TOTP        `json:"totp"`           // TOTP 两步验证
+	Performance    *conf.Performance `json:"performance"`    // 性能配置
+	Monitor        *conf.Monitor     `json:"monitor"`        // 运行监控配置
+	Quota          *conf.Quota       `json:"quota"`          // 资源配额
+	Reminder       *conf.Reminder    `json:"reminder"`       // 块提醒
+	Citation       *conf.Citation    `json:"citation"`       // 文献引用
+	DataSource     *conf.DataSource  `json:"dataSource"`     // 属性视图外部数据源
+	Repo           *conf.Repo        `json:"repo"`           // 数据仓库
+	Template       *conf.Template    `json:"template"`       // 模板配置
+	OpenHelp       bool              `json:"openHelp"`       // 启动后是否需要打开用户指南
+	ShowChangelog  bool              `json:"showChangelog"`  // 是否显示版本更新日志
+	CloudRegion    int               `json:"cloudRegion"`    // 云端区域，0：中国大陆，1：北美
+	Snippet        *conf.Snpt        `json:"snippet"`        // 代码片段
+	Asset          *conf.Asset       `json:"asset"`          // 资源文件
+	State          int               `json:"state"`          // 运行状态，0：已经正常退出，1：运行中
 
 	m *sync.Mutex
 }
@@ -110,6 +121,8 @@ func (conf *AppConf) SetUser(user *conf.User) {
 }
 
 func InitConf() {
+	defer util.StartBootStage("conf", false)()
+
 	initLang()
 
 	Conf = &AppConf{LogLevel: "debug", m: &sync.Mutex{}}
@@ -212,6 +225,10 @@ func InitConf() {
 	}
 	Conf.FileTree.DocCreateSavePath = strings.TrimSpace(Conf.FileTree.DocCreateSavePath)
 	util.UseSingleLineSave = Conf.FileTree.UseSingleLineSave
+	if !filesys.IsValidSyCompression(Conf.FileTree.SyCompression) {
+		Conf.FileTree.SyCompression = filesys.SyCompressionNone
+	}
+	util.SyCompression = Conf.FileTree.SyCompression
 
 	util.CurrentCloudRegion = Conf.CloudRegion
 
@@ -225,6 +242,9 @@ func InitConf() {
 	if 1 > len(Conf.Editor.Emoji) {
 		Conf.Editor.Emoji = []string{}
 	}
+	if nil == Conf.Editor.VirtualBlockRefDicts {
+		Conf.Editor.VirtualBlockRefDicts = []*conf.VirtualBlockRefDict{}
+	}
 	if 9 > Conf.Editor.FontSize || 72 < Conf.Editor.FontSize {
 		Conf.Editor.FontSize = 16
 	}
@@ -270,6 +290,9 @@ func InitConf() {
 	if "" == Conf.Export.PandocBin {
 		Conf.Export.PandocBin = util.PandocBinPath
 	}
+	if "svg" != Conf.Export.DiagramFormat && "png" != Conf.Export.DiagramFormat {
+		Conf.Export.DiagramFormat = "svg"
+	}
 
 	if nil == Conf.Graph || nil == Conf.Graph.Local || nil == Conf.Graph.Global {
 		Conf.Graph = conf.NewGraph()
@@ -294,6 +317,27 @@ func InitConf() {
 	if nil == Conf.System.NetworkProxy {
 		Conf.System.NetworkProxy = &conf.NetworkProxy{}
 	}
+	if nil == Conf.System.NetworkTLS {
+		Conf.System.NetworkTLS = conf.NewNetworkTLS()
+	}
+	if nil == Conf.System.NetworkTLS.Domains {
+		Conf.System.NetworkTLS.Domains = []string{}
+	}
+	if nil == Conf.System.NetworkACL {
+		Conf.System.NetworkACL = conf.NewNetworkACL()
+	}
+	if nil == Conf.System.NetworkACL.Allow {
+		Conf.System.NetworkACL.Allow = []string{}
+	}
+	if nil == Conf.System.NetworkACL.Deny {
+		Conf.System.NetworkACL.Deny = []string{}
+	}
+	if nil == Conf.System.NetworkACL.TrustedProxies {
+		Conf.System.NetworkACL.TrustedProxies = []string{}
+	}
+	if err := LoadNetworkACL(Conf.System.NetworkACL); nil != err {
+		logging.LogErrorf("load network ACL failed: %s", err)
+	}
 	if "" == Conf.System.ID {
 		Conf.System.ID = util.GetDeviceID()
 	}
@@ -353,13 +397,131 @@ func InitConf() {
 		Conf.Api = conf.NewAPI()
 	}
 
+	if nil == Conf.OIDC {
+		Conf.OIDC = conf.NewOIDC()
+	}
+	if nil == Conf.TOTP {
+		Conf.TOTP = conf.NewTOTP()
+	}
+	if nil == Conf.Performance {
+		Conf.Performance = conf.NewPerformance()
+	}
+	if 0 > Conf.Performance.IndexWorkers || conf.MaxIndexWorkers < Conf.Performance.IndexWorkers {
+		Conf.Performance.IndexWorkers = 0
+	}
+	if 1 > Conf.Performance.IndexBatchSize || conf.MaxIndexBatchSize < Conf.Performance.IndexBatchSize {
+		Conf.Performance.IndexBatchSize = 64
+	}
+	if conf.MinSQLFlushInterval > Conf.Performance.SQLFlushInterval || conf.MaxSQLFlushInterval < Conf.Performance.SQLFlushInterval {
+		Conf.Performance.SQLFlushInterval = 3000
+	}
+	if 0 > Conf.Performance.SQLFlushBatchSize || conf.MaxSQLFlushBatchSize < Conf.Performance.SQLFlushBatchSize {
+		Conf.Performance.SQLFlushBatchSize = 0
+	}
+	sql.SetFlushPolicy(time.Duration(Conf.Performance.SQLFlushInterval)*time.Millisecond, Conf.Performance.SQLFlushBatchSize)
+	if 1 > Conf.Performance.BlockCacheSize || conf.MaxBlockCacheSize < Conf.Performance.BlockCacheSize {
+		Conf.Performance.BlockCacheSize = 64
+	}
+	sql.SetBlockCacheBudget(int64(Conf.Performance.BlockCacheSize) * 1024 * 1024)
+	if 0 > Conf.Performance.TreeSaveDebounce || conf.MaxTreeSaveDebounce < Conf.Performance.TreeSaveDebounce {
+		Conf.Performance.TreeSaveDebounce = 0
+	}
+	filesys.ReplayTreeJournal()
+	filesys.SetTreeSaveDebounce(time.Duration(Conf.Performance.TreeSaveDebounce) * time.Millisecond)
+	if nil == Conf.Monitor {
+		Conf.Monitor = conf.NewMonitor()
+	}
+	util.MetricsEnabled.Store(Conf.Monitor.Metrics)
+	if nil == Conf.Quota {
+		Conf.Quota = conf.NewQuota()
+	}
+	clampQuota(Conf.Quota)
+	if nil == Conf.Reminder {
+		Conf.Reminder = conf.NewReminder()
+	}
+	if nil == Conf.Reminder.Webhooks {
+		Conf.Reminder.Webhooks = []string{}
+	}
+	if nil == Conf.Citation {
+		Conf.Citation = conf.NewCitation()
+	}
+	if "" == Conf.Citation.ZoteroURL {
+		Conf.Citation.ZoteroURL = conf.NewCitation().ZoteroURL
+	}
+	if !isValidCitationStyle(Conf.Citation.Style) {
+		Conf.Citation.Style = conf.NewCitation().Style
+	}
+	if nil == Conf.DataSource {
+		Conf.DataSource = conf.NewDataSource()
+	}
+	if nil == Conf.DataSource.Secrets {
+		Conf.DataSource.Secrets = map[string]*conf.DataSourceSecret{}
+	}
+	if nil == Conf.TOTP.RecoveryCodes {
+		Conf.TOTP.RecoveryCodes = []string{}
+	}
+	if conf.TOTPEnforceAll != Conf.TOTP.Enforce {
+		Conf.TOTP.Enforce = conf.TOTPEnforceRemote
+	}
+	if nil == Conf.OIDC.RoleMappings {
+		Conf.OIDC.RoleMappings = []*conf.OIDCRoleMapping{}
+	}
+	if 1 > Conf.OIDC.SessionTTL {
+		Conf.OIDC.SessionTTL = 24 * 7
+	}
+	if 1 > Conf.OIDC.TokenTTL {
+		Conf.OIDC.TokenTTL = 24 * 30
+	}
+
+	if nil == Conf.Asset {
+		Conf.Asset = conf.NewAsset()
+	}
+	if 0 > Conf.Asset.QuarantineDays {
+		Conf.Asset.QuarantineDays = 0
+	}
+	if nil == Conf.Asset.Storage {
+		Conf.Asset.Storage = conf.NewAssetStorage()
+	}
+	if nil == Conf.Asset.Storage.S3 {
+		Conf.Asset.Storage.S3 = conf.NewAssetStorage().S3
+	}
+	if 1 > Conf.Asset.Storage.SignedURLExpire {
+		Conf.Asset.Storage.SignedURLExpire = 3600
+	}
+	if nil == Conf.Asset.ImageConvert {
+		Conf.Asset.ImageConvert = conf.NewImageConvert()
+	}
+	if 1 > Conf.Asset.ImageConvert.Quality || 100 < Conf.Asset.ImageConvert.Quality {
+		Conf.Asset.ImageConvert.Quality = 85
+	}
+	if nil == Conf.Asset.ContentIndex {
+		Conf.Asset.ContentIndex = conf.NewContentIndex()
+	}
+
 	if nil == Conf.Bazaar {
 		Conf.Bazaar = conf.NewBazaar()
 	}
+	if 1 > Conf.Bazaar.PetalStorageQuota {
+		Conf.Bazaar.PetalStorageQuota = conf.NewBazaar().PetalStorageQuota
+	}
+	if 1 > Conf.Bazaar.PetalJobConcurrency {
+		Conf.Bazaar.PetalJobConcurrency = conf.NewBazaar().PetalJobConcurrency
+	}
+	ApplyBazaarConf()
 
 	if nil == Conf.Repo {
 		Conf.Repo = conf.NewRepo()
 	}
+
+	if nil == Conf.Template {
+		Conf.Template = conf.NewTemplate()
+	}
+	if nil == Conf.Template.HTTPAllowDomains {
+		Conf.Template.HTTPAllowDomains = []string{}
+	}
+	if 1 > Conf.Template.HTTPTimeout || 60 < Conf.Template.HTTPTimeout {
+		Conf.Template.HTTPTimeout = conf.NewTemplate().HTTPTimeout
+	}
 	if timingEnv := os.Getenv("SIYUAN_SYNC_INDEX_TIMING"); "" != timingEnv {
 		val, err := strconv.Atoi(timingEnv)
 		if nil == err {
@@ -369,6 +531,9 @@ func InitConf() {
 	if 12000 > Conf.Repo.SyncIndexTiming {
 		Conf.Repo.SyncIndexTiming = 12 * 1000
 	}
+	if nil == Conf.Repo.EventWebhooks {
+		Conf.Repo.EventWebhooks = []string{}
+	}
 
 	if nil == Conf.Search {
 		Conf.Search = conf.NewSearch()
@@ -409,6 +574,7 @@ func InitConf() {
 	if nil == Conf.AI {
 		Conf.AI = conf.NewAI()
 	}
+	FixAIConf(Conf.AI)
 	if "" == Conf.AI.OpenAI.APIModel {
 		Conf.AI.OpenAI.APIModel = openai.GPT3Dot5Turbo
 	}
@@ -587,6 +753,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int) {
 	logging.LogInfof("exiting kernel [force=%v, setCurrentWorkspace=%v, execInstallPkg=%d]", force, setCurrentWorkspace, execInstallPkg)
 	util.PushMsg(Conf.Language(95), 10000*60)
 	WaitForWritingFiles()
+	filesys.FlushTreeSaves()
 
 	if !force {
 		if Conf.Sync.Enabled && 3 != Conf.Sync.Mode &&
@@ -623,6 +790,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int) {
 	Conf.Close()
 	sql.CloseDatabase()
 	treenode.SaveBlockTree(false)
+	treenode.CloseBlockTree()
 	util.SaveAssetsTexts()
 	clearWorkspaceTemp()
 	clearCorruptedNotebooks()
@@ -813,6 +981,8 @@ func (conf *AppConf) language(num int) (ret string) {
 }
 
 func InitBoxes() {
+	defer util.StartBootStage("boxes", false)()
+
 	initialized := false
 	if 1 > treenode.CountBlocks() {
 		if gulu.File.IsExist(util.BlockTreePath) {
@@ -824,13 +994,16 @@ func InitBoxes() {
 				}
 			}()
 
+			endStage := util.StartBootStage("blocktree", false)
 			treenode.InitBlockTree(false)
+			endStage()
 			initialized = true
 		}
 	} else { // 大于 1 的话说明在同步阶段已经加载过了
 		initialized = true
 	}
 
+	endStage := util.StartBootStage("index", false)
 	for _, box := range Conf.GetOpenedBoxes() {
 		box.UpdateHistoryGenerated() // 初始化历史生成时间为当前时间
 
@@ -838,8 +1011,10 @@ func InitBoxes() {
 			index(box.ID)
 		}
 	}
+	endStage()
 
 	if !initialized {
+		indexAttributeViews()
 		treenode.SaveBlockTree(true)
 	}
 
@@ -891,6 +1066,32 @@ func GetMaskedConf() (ret *AppConf, err error) {
 	if "" != ret.AccessAuthCode {
 		ret.AccessAuthCode = MaskedAccessAuthCode
 	}
+	if nil != ret.OIDC && "" != ret.OIDC.ClientSecret {
+		ret.OIDC.ClientSecret = MaskedAccessAuthCode
+	}
+	if nil != ret.TOTP {
+		ret.TOTP.Secret = ""
+		ret.TOTP.RecoveryCodes = make([]string, len(ret.TOTP.RecoveryCodes)) // 仅保留剩余数量
+	}
+	if nil != ret.Api {
+		// API token 通过 /api/system/getAPIToken 单独获取
+		if "" != ret.Api.Token {
+			ret.Api.Token = MaskedAccessAuthCode
+		}
+		for _, scopedToken := range ret.Api.ScopedTokens {
+			scopedToken.Token = MaskedAccessAuthCode
+		}
+	}
+	if nil != ret.DataSource {
+		for _, secret := range ret.DataSource.Secrets {
+			if "" != secret.AirtableToken {
+				secret.AirtableToken = MaskedAccessAuthCode
+			}
+			for k := range secret.Headers {
+				secret.Headers[k] = MaskedAccessAuthCode
+			}
+		}
+	}
 	return
 }
 
@@ -0,0 +1,157 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// DailyNote 描述了某个笔记本中某一天的日记。
+type DailyNote struct {
+	Date    string `json:"date"` // 日期，格式为 20060102
+	ID      string `json:"id"`
+	Box     string `json:"box"`
+	Path    string `json:"path"`
+	HPath   string `json:"hPath"`
+	Existed bool   `json:"existed"` // 创建前是否已经存在
+}
+
+// 一次补建日记的最大天数
+const maxBackfillDailyNoteDays = 366 * 3
+
+// ParseDailyNoteDate 解析日记日期，支持 20060102 和 2006-01-02 两种格式，为空时返回今天。
+func ParseDailyNoteDate(date string) (ret time.Time, err error) {
+	if "" == date {
+		ret = time.Now()
+		return
+	}
+
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		if ret, err = time.ParseInLocation(layout, date, time.Local); nil == err {
+			// 使用当前时刻，这样模板中 now 的时分秒和直接新建日记时一致
+			now := time.Now()
+			ret = time.Date(ret.Year(), ret.Month(), ret.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.Local)
+			return
+		}
+	}
+	err = fmt.Errorf("invalid date [%s]", date)
+	return
+}
+
+// GetDailyNote 获取指定日期的日记，不存在时返回 nil。
+func GetDailyNote(boxID string, date time.Time) (ret *DailyNote, err error) {
+	box := Conf.Box(boxID)
+	if nil == box {
+		err = ErrBoxNotFound
+		return
+	}
+
+	boxConf := box.GetConf()
+	if "" == boxConf.DailyNoteSavePath || "/" == boxConf.DailyNoteSavePath {
+		err = errors.New(Conf.Language(49))
+		return
+	}
+
+	hPath, err := renderGoTemplate(boxConf.DailyNoteSavePath, dailyNoteTemplateFuncs(date))
+	if nil != err {
+		return
+	}
+
+	WaitForWritingFiles()
+	bt := treenode.GetBlockTreeRootByHPath(box.ID, hPath)
+	if nil == bt {
+		return
+	}
+	ret = &DailyNote{Date: date.Format("20060102"), ID: bt.RootID, Box: bt.BoxID, Path: bt.Path, HPath: bt.HPath, Existed: true}
+	return
+}
+
+// GetOrCreateDailyNote 获取指定日期的日记，不存在时使用笔记本配置的日记模板创建。
+func GetOrCreateDailyNote(boxID string, date time.Time) (ret *DailyNote, err error) {
+	p, existed, err := CreateDailyNoteByDate(boxID, date)
+	if nil != err {
+		return
+	}
+
+	WaitForWritingFiles()
+	bt := treenode.GetBlockTreeRootByPath(boxID, p)
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+	ret = &DailyNote{Date: date.Format("20060102"), ID: bt.RootID, Box: bt.BoxID, Path: bt.Path, HPath: bt.HPath, Existed: existed}
+	return
+}
+
+// BackfillDailyNotes 补建日期范围 [from, to] 内缺失的日记，已经存在的日记不会修改内容。
+func BackfillDailyNotes(boxID string, from, to time.Time) (ret []*DailyNote, err error) {
+	box := Conf.Box(boxID)
+	if nil == box {
+		err = ErrBoxNotFound
+		return
+	}
+	if boxConf := box.GetConf(); "" == boxConf.DailyNoteSavePath || "/" == boxConf.DailyNoteSavePath {
+		err = errors.New(Conf.Language(49))
+		return
+	}
+
+	to = time.Date(to.Year(), to.Month(), to.Day(), from.Hour(), from.Minute(), from.Second(), 0, time.Local)
+	if to.Before(from) {
+		err = errors.New("the end date must not be earlier than the start date")
+		return
+	}
+	days := int(to.Sub(from).Hours()/24+0.5) + 1
+	if maxBackfillDailyNoteDays < days {
+		err = fmt.Errorf("the date range must not exceed %d days", maxBackfillDailyNoteDays)
+		return
+	}
+
+	ret = []*DailyNote{}
+	created := 0
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		note, createErr := GetOrCreateDailyNote(boxID, date)
+		if nil != createErr {
+			logging.LogWarnf("backfill daily note [%s] failed: %s", date.Format("2006-01-02"), createErr)
+			continue
+		}
+		ret = append(ret, note)
+		if !note.Existed {
+			created++
+			util.PushEndlessProgress(fmt.Sprintf(Conf.Language(252), created, date.Format("2006-01-02")))
+		}
+	}
+
+	if 0 < created {
+		util.PushClearProgress()
+		util.PushReloadFiletree()
+	}
+	return
+}
+
+// dailyNoteTemplateFuncs 返回将 now 固定为指定日期的模板函数，用于渲染非当天的日记路径和模板。
+func dailyNoteTemplateFuncs(date time.Time) (ret template.FuncMap) {
+	ret = templateFuncs()
+	ret["now"] = func() time.Time { return date }
+	return
+}
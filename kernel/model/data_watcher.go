@@ -0,0 +1,156 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !darwin
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/fsnotify/fsnotify"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var dataWatcher *fsnotify.Watcher
+
+// WatchData 监听笔记本文件夹和监听文件夹，数据文件在内核之外被修改后增量重建索引。
+func WatchData() {
+	if util.ContainerAndroid == util.Container || util.ContainerIOS == util.Container {
+		return
+	}
+
+	if Conf.System.DisableDataWatcher {
+		logging.LogInfof("data watcher is disabled")
+		return
+	}
+
+	go func() {
+		watchData()
+	}()
+}
+
+func watchData() {
+	if nil != dataWatcher {
+		dataWatcher.Close()
+	}
+
+	var err error
+	if dataWatcher, err = fsnotify.NewWatcher(); nil != err {
+		logging.LogErrorf("add data watcher for folder [%s] failed: %s", util.DataDir, err)
+		return
+	}
+	watcher := dataWatcher
+
+	go func() {
+		defer logging.Recover()
+
+		changed := map[string]bool{}
+		timer := time.NewTimer(time.Second)
+		<-timer.C // timer should be expired at first
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				changed[event.Name] = true
+				if event.Op&fsnotify.Create == fsnotify.Create {
+					if info, statErr := os.Stat(event.Name); nil == statErr && info.IsDir() {
+						// 移入的文件夹下可能已经有文档
+						for _, p := range addDataWatchDirs(watcher, event.Name) {
+							changed[p] = true
+						}
+					}
+				}
+				timer.Reset(time.Second)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.LogErrorf("watch data failed: %s", err)
+			case <-timer.C:
+				var paths []string
+				for p := range changed {
+					paths = append(paths, p)
+				}
+				if reindexExternalChanges(paths) {
+					timer.Reset(5 * time.Second)
+					continue
+				}
+				changed = map[string]bool{}
+			}
+		}
+	}()
+
+	entries, err := os.ReadDir(util.DataDir)
+	if nil != err {
+		logging.LogErrorf("read data dir [%s] failed: %s", util.DataDir, err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && ast.IsNodeIDPattern(entry.Name()) {
+			addDataWatchDirs(watcher, filepath.Join(util.DataDir, entry.Name()))
+		}
+	}
+
+	for _, folder := range Conf.Asset.WatchFolders {
+		if !folder.Enabled || "" == folder.Path {
+			continue
+		}
+		if err = watcher.Add(folder.Path); nil != err {
+			logging.LogWarnf("add data watcher for watch folder [%s] failed: %s", folder.Path, err)
+		}
+	}
+}
+
+// addDataWatchDirs 监听文件夹及其下的所有子文件夹（fsnotify 不支持递归监听），返回其中的 .sy 文件。
+func addDataWatchDirs(watcher *fsnotify.Watcher, dir string) (syFiles []string) {
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if nil != err {
+			return nil
+		}
+		if !info.IsDir() {
+			if strings.HasSuffix(p, ".sy") {
+				syFiles = append(syFiles, p)
+			}
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") && p != dir {
+			return filepath.SkipDir // 跳过 .siyuan 等配置文件夹
+		}
+
+		if addErr := watcher.Add(p); nil != addErr {
+			logging.LogWarnf("add data watcher for folder [%s] failed: %s", p, addErr)
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return
+}
+
+func CloseWatchData() {
+	if nil != dataWatcher {
+		dataWatcher.Close()
+	}
+}
@@ -0,0 +1,101 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build darwin
+
+package model
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/radovskyb/watcher"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var dataWatcher *watcher.Watcher
+
+// WatchData 轮询笔记本文件夹和监听文件夹，数据文件在内核之外被修改后增量重建索引。
+func WatchData() {
+	if Conf.System.DisableDataWatcher {
+		logging.LogInfof("data watcher is disabled")
+		return
+	}
+
+	go func() {
+		watchData()
+	}()
+}
+
+func watchData() {
+	if nil != dataWatcher {
+		dataWatcher.Close()
+	}
+	dataWatcher = watcher.New()
+	dataWatcher.AddFilterHook(watcher.RegexFilterHook(regexp.MustCompile(`(?i)\.(sy|md|markdown)$`), false))
+	w := dataWatcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-w.Event:
+				if !ok {
+					return
+				}
+
+				paths := []string{event.Path}
+				if "" != event.OldPath {
+					paths = append(paths, event.OldPath)
+				}
+				for reindexExternalChanges(paths) {
+					time.Sleep(5 * time.Second)
+				}
+			case err, ok := <-w.Error:
+				if !ok {
+					return
+				}
+				logging.LogErrorf("watch data failed: %s", err)
+			case <-w.Closed:
+				return
+			}
+		}
+	}()
+
+	if err := w.AddRecursive(util.DataDir); nil != err {
+		logging.LogErrorf("add data watcher for folder [%s] failed: %s", util.DataDir, err)
+		return
+	}
+	for _, folder := range Conf.Asset.WatchFolders {
+		if !folder.Enabled || "" == folder.Path {
+			continue
+		}
+		if err := w.Add(folder.Path); nil != err {
+			logging.LogWarnf("add data watcher for watch folder [%s] failed: %s", folder.Path, err)
+		}
+	}
+
+	if err := w.Start(10 * time.Second); nil != err {
+		logging.LogErrorf("start data watcher for folder [%s] failed: %s", util.DataDir, err)
+		return
+	}
+}
+
+func CloseWatchData() {
+	if nil != dataWatcher {
+		dataWatcher.Close()
+	}
+}
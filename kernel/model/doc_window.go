@@ -0,0 +1,319 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 超大文档分段加载：
+//
+//  1. 流式扫描文档文件，只解析顶层块的 ID 和类型，按标题、块范围或者滚动锚点定位窗口；
+//  2. 只为窗口内的顶层块构建语法树并渲染；
+//  3. 保存时将窗口内的顶层块替换为新的内容后写回文件，块树即时增量更新，数据库索引在后台合并更新。
+
+// DocWindow 描述了文档中连续的一段顶层块。
+type DocWindow struct {
+	RootID  string `json:"rootID"`
+	Start   int    `json:"start"`   // 窗口第一个顶层块的序号
+	End     int    `json:"end"`     // 窗口结束序号（不包含）
+	Total   int    `json:"total"`   // 文档顶层块总数
+	StartID string `json:"startID"` // 窗口第一个顶层块 ID
+	EndID   string `json:"endID"`   // 窗口最后一个顶层块 ID
+	Version string `json:"version"` // 文档更新时间，保存窗口时用于检测并发修改
+	Content string `json:"content"` // 窗口内块的 DOM
+}
+
+const (
+	DocWindowByHeading = "heading" // 从标题到下一个同级或者更高级标题之前
+	DocWindowByRange   = "range"   // 从起始块到结束块
+	DocWindowByAnchor  = "anchor"  // 锚点块前后若干个顶层块
+
+	docWindowMaxBlocks = 1024 // 窗口最多包含的顶层块数
+)
+
+var (
+	ErrDocWindowConflict = errors.New("the document has been modified, please reload the window")
+	ErrDocWindowInvalid  = errors.New("invalid document window")
+)
+
+// LoadDocWindow 加载文档的一段顶层块，不构建整个文档的语法树。
+func LoadDocWindow(rootID, by, anchorID, startID, endID string, before, after int) (ret *DocWindow, err error) {
+	bt, scanned, err := scanDocWindowTree(rootID)
+	if nil != err {
+		return
+	}
+
+	start, end := 0, 0
+	total := len(scanned.Children)
+	switch by {
+	case DocWindowByHeading:
+		i := docWindowIndex(scanned, rootID, anchorID)
+		if 0 > i || "NodeHeading" != scanned.Children[i].Type {
+			return nil, ErrDocWindowInvalid
+		}
+		start, end = i, i+1
+		level := scanned.Children[i].HeadingLevel
+		for ; end < total; end++ {
+			if child := scanned.Children[end]; "NodeHeading" == child.Type && child.HeadingLevel <= level {
+				break
+			}
+		}
+	case DocWindowByRange:
+		start, end = docWindowIndex(scanned, rootID, startID), docWindowIndex(scanned, rootID, endID)
+		if 0 > start || 0 > end {
+			return nil, ErrDocWindowInvalid
+		}
+		if end < start {
+			start, end = end, start
+		}
+		end++
+	case DocWindowByAnchor:
+		i := 0
+		if "" != anchorID {
+			if i = docWindowIndex(scanned, rootID, anchorID); 0 > i {
+				return nil, ErrDocWindowInvalid
+			}
+		}
+		if 0 > before {
+			before = 0
+		}
+		if 1 > after {
+			after = 32
+		}
+		start, end = i-before, i+after+1
+	default:
+		return nil, ErrDocWindowInvalid
+	}
+	if 0 > start {
+		start = 0
+	}
+	if end > total {
+		end = total
+	}
+	if docWindowMaxBlocks < end-start {
+		end = start + docWindowMaxBlocks
+	}
+	return renderDocWindow(bt, scanned, start, end)
+}
+
+// SaveDocWindow 使用 DOM 替换文档中 [start, end) 范围内的顶层块，startID、endID 和 version 需要和加载窗口时一致。
+func SaveDocWindow(rootID string, start, end int, startID, endID, version, dom string) (ret *DocWindow, err error) {
+	WaitForWritingFiles()
+	flushLock.Lock()
+	defer flushLock.Unlock()
+
+	bt, scanned, err := scanDocWindowTree(rootID)
+	if nil != err {
+		return
+	}
+	if 0 > start || start >= end || end > len(scanned.Children) ||
+		scanned.Children[start].ID != startID || scanned.Children[end-1].ID != endID {
+		return nil, ErrDocWindowConflict
+	}
+	props := map[string]string{}
+	if err = json.Unmarshal(scanned.Root["Properties"], &props); nil != err {
+		return
+	}
+	if version != props["updated"] {
+		return nil, ErrDocWindowConflict
+	}
+
+	luteEngine := util.NewLute()
+	oldTree, err := filesys.LoadTreeWindow(scanned, bt.BoxID, bt.Path, start, end, luteEngine)
+	if nil != err {
+		return
+	}
+	oldIDs := map[string]bool{}
+	ast.Walk(oldTree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && "" != n.ID && ast.NodeDocument != n.Type {
+			oldIDs[n.ID] = true
+		}
+		return ast.WalkContinue
+	})
+
+	newTree := luteEngine.BlockDOM2Tree(strings.ReplaceAll(dom, editor.FrontEndCaret, ""))
+	newTree.ID, newTree.Box, newTree.Path, newTree.HPath = rootID, bt.BoxID, bt.Path, bt.HPath
+	newTree.Root.ID, newTree.Root.Box, newTree.Root.Path = rootID, bt.BoxID, bt.Path
+	newIDs := map[string]bool{}
+	var walkErr error
+	ast.Walk(newTree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || ast.NodeDocument == n.Type {
+			return ast.WalkContinue
+		}
+		if "" == n.ID {
+			n.ID = ast.NewNodeID()
+			n.SetIALAttr("id", n.ID)
+		}
+		// 窗口外已经存在的块不能出现在窗口中，否则会产生重复的块
+		if existing := treenode.GetBlockTree(n.ID); (nil != existing && !oldIDs[n.ID]) || newIDs[n.ID] {
+			walkErr = ErrDocWindowInvalid
+			return ast.WalkStop
+		}
+		newIDs[n.ID] = true
+		return ast.WalkContinue
+	})
+	if nil != walkErr {
+		return nil, walkErr
+	}
+	if nil == newTree.Root.FirstChild {
+		newTree.Root.AppendChild(treenode.NewParagraph())
+	}
+
+	children, err := filesys.RenderTreeChildren(newTree)
+	if nil != err {
+		return
+	}
+	var tmp []*filesys.TreeChild
+	tmp = append(tmp, scanned.Children[:start]...)
+	tmp = append(tmp, children...)
+	tmp = append(tmp, scanned.Children[end:]...)
+	scanned.Children = tmp
+
+	props["updated"] = time.Now().Format("20060102150405")
+	if scanned.Root["Properties"], err = json.Marshal(props); nil != err {
+		return
+	}
+	if err = filesys.WriteScannedTree(scanned, bt.BoxID, bt.Path); nil != err {
+		return
+	}
+	cache.PutDocIAL(bt.Path, props)
+
+	// 块树增量更新，移除窗口中被删除的块
+	treenode.IndexBlockTree(newTree)
+	for id := range oldIDs {
+		if !newIDs[id] {
+			treenode.RemoveBlockTree(id)
+		}
+	}
+	resetCollabDoc(rootID)
+	reindexDocWindowTree(rootID)
+	util.PushReloadDoc(rootID)
+	return renderDocWindow(bt, scanned, start, start+len(children))
+}
+
+func scanDocWindowTree(rootID string) (bt *treenode.BlockTree, scanned *filesys.ScannedTree, err error) {
+	bt = treenode.GetBlockTree(rootID)
+	if nil == bt || bt.ID != bt.RootID {
+		err = ErrBlockNotFound
+		return
+	}
+
+	data, err := filesys.ReadSyFile(filepath.Join(util.DataDir, bt.BoxID, bt.Path))
+	if nil != err {
+		return
+	}
+	if scanned, err = filesys.ScanTree(data); nil != err {
+		logging.LogErrorf("scan tree [%s] failed: %s", bt.Path, err)
+	}
+	return
+}
+
+// docWindowIndex 返回块所在的顶层块序号，块不在文档中时返回 -1。
+func docWindowIndex(scanned *filesys.ScannedTree, rootID, id string) int {
+	for i := 0; "" != id && i < 64; i++ { // 限制向上查找的层级
+		for j, child := range scanned.Children {
+			if child.ID == id {
+				return j
+			}
+		}
+
+		bt := treenode.GetBlockTree(id)
+		if nil == bt || bt.RootID != rootID || bt.ParentID == rootID {
+			return -1
+		}
+		id = bt.ParentID
+	}
+	return -1
+}
+
+func renderDocWindow(bt *treenode.BlockTree, scanned *filesys.ScannedTree, start, end int) (ret *DocWindow, err error) {
+	ret = &DocWindow{RootID: bt.ID, Start: start, End: end, Total: len(scanned.Children)}
+	props := map[string]string{}
+	if data := scanned.Root["Properties"]; nil != data {
+		json.Unmarshal(data, &props)
+	}
+	ret.Version = props["updated"]
+	if start >= end {
+		return
+	}
+	ret.StartID, ret.EndID = scanned.Children[start].ID, scanned.Children[end-1].ID
+
+	luteEngine := NewLute()
+	tree, err := filesys.LoadTreeWindow(scanned, bt.BoxID, bt.Path, start, end, luteEngine)
+	if nil != err {
+		return
+	}
+	tree.HPath = bt.HPath
+
+	refCount := sql.QueryRootChildrenRefCount(bt.ID)
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeDocument == n.Type || "" == n.ID {
+			return ast.WalkContinue
+		}
+		if cnt := refCount[n.ID]; 0 < cnt {
+			n.SetIALAttr("refcount", strconv.Itoa(cnt))
+		}
+		return ast.WalkContinue
+	})
+
+	luteEngine.RenderOptions.NodeIndexStart = start + 1
+	ret.Content = luteEngine.Tree2BlockDOM(tree, luteEngine.RenderOptions)
+	return
+}
+
+var (
+	docWindowReindexTimers = map[string]*time.Timer{}
+	docWindowReindexLock   = sync.Mutex{}
+)
+
+// reindexDocWindowTree 合并短时间内的多次窗口保存，在后台重建文档的数据库索引。
+func reindexDocWindowTree(rootID string) {
+	docWindowReindexLock.Lock()
+	defer docWindowReindexLock.Unlock()
+
+	if timer := docWindowReindexTimers[rootID]; nil != timer {
+		timer.Stop()
+	}
+	docWindowReindexTimers[rootID] = time.AfterFunc(3*time.Second, func() {
+		docWindowReindexLock.Lock()
+		delete(docWindowReindexTimers, rootID)
+		docWindowReindexLock.Unlock()
+
+		tree, err := LoadTreeByBlockID(rootID)
+		if nil != err {
+			logging.LogErrorf("load tree [%s] failed: %s", rootID, err)
+			return
+		}
+		sql.UpsertTreeQueue(tree)
+	})
+}
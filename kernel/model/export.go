@@ -1526,7 +1526,7 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 	// 按文件夹结构复制选择的树
 	for _, tree := range trees {
 		readPath := filepath.Join(util.DataDir, tree.Box, tree.Path)
-		data, readErr := filelock.ReadFile(readPath)
+		data, readErr := filesys.ReadSyFile(readPath)
 		if nil != readErr {
 			logging.LogErrorf("read file [%s] failed: %s", readPath, readErr)
 			continue
@@ -1548,7 +1548,7 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 	// 引用树放在导出文件夹根路径下
 	for treeID, tree := range refTrees {
 		readPath := filepath.Join(util.DataDir, tree.Box, tree.Path)
-		data, readErr := filelock.ReadFile(readPath)
+		data, readErr := filesys.ReadSyFile(readPath)
 		if nil != readErr {
 			logging.LogErrorf("read file [%s] failed: %s", readPath, readErr)
 			continue
@@ -2326,6 +2326,14 @@ func exportTree(tree *parse.Tree, wysiwyg, keepFold bool,
 	for _, n := range unlinks {
 		n.Unlink()
 	}
+
+	if wysiwyg {
+		// PDF、HTML、Word 和分享页面中将图表代码块渲染为图片
+		renderExportDiagrams(ret)
+	}
+
+	// 按引用样式渲染文献引用并追加参考文献
+	exportCitations(ret)
 	return ret
 }
 
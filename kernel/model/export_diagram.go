@@ -0,0 +1,331 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 导出时在内核中渲染的图表类型，其他图表（ECharts、脑图等）仍然由前端渲染。
+const (
+	DiagramMermaid  = "mermaid"
+	DiagramPlantUML = "plantuml"
+	DiagramGraphviz = "graphviz"
+)
+
+var ErrDiagramRendererNotFound = errors.New("diagram renderer not found")
+
+// RenderDiagram 将图表源码渲染为 SVG 或 PNG，渲染结果按内容缓存在临时目录中。
+func RenderDiagram(lang, code, format string) (ret []byte, err error) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if "png" != format {
+		format = "svg"
+	}
+	code = strings.TrimSpace(code)
+	if "" == code {
+		err = errors.New("diagram code is empty")
+		return
+	}
+
+	hash := sha256.Sum256([]byte(lang + "\x00" + format + "\x00" + code))
+	cachePath := filepath.Join(util.TempDir, "export", "diagrams", hex.EncodeToString(hash[:])+"."+format)
+	if data, readErr := os.ReadFile(cachePath); nil == readErr && 0 < len(data) {
+		ret = data
+		return
+	}
+
+	switch lang {
+	case DiagramMermaid:
+		ret, err = renderMermaid(code, format)
+	case DiagramPlantUML:
+		ret, err = renderPlantUML(code, format)
+	case DiagramGraphviz:
+		ret, err = renderGraphviz(code, format)
+	default:
+		err = fmt.Errorf("unsupported diagram [%s]", lang)
+	}
+	if nil != err {
+		return
+	}
+	if 1 > len(ret) {
+		err = fmt.Errorf("render diagram [%s] got empty result", lang)
+		return
+	}
+
+	if mkdirErr := os.MkdirAll(filepath.Dir(cachePath), 0755); nil != mkdirErr {
+		logging.LogWarnf("mkdir [%s] failed: %s", filepath.Dir(cachePath), mkdirErr)
+		return
+	}
+	if writeErr := gulu.File.WriteFileSafer(cachePath, ret, 0644); nil != writeErr {
+		logging.LogWarnf("write diagram cache [%s] failed: %s", cachePath, writeErr)
+	}
+	return
+}
+
+// DiagramDataURI 返回渲染结果的 Data URI，导出和分享页面中直接内联，不需要额外复制资源文件。
+func DiagramDataURI(data []byte, format string) string {
+	mime := "image/svg+xml"
+	if "png" == format {
+		mime = "image/png"
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// renderExportDiagrams 将 Mermaid、PlantUML 和 Graphviz 代码块渲染为图片，渲染失败时保留代码块。
+func renderExportDiagrams(tree *parse.Tree) {
+	if 1 == Conf.Export.DiagramExportMode {
+		return
+	}
+
+	format := Conf.Export.DiagramFormat
+	if "png" != format {
+		format = "svg"
+	}
+
+	var unlinks []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeCodeBlock != n.Type {
+			return ast.WalkContinue
+		}
+
+		infoMarker := n.ChildByType(ast.NodeCodeBlockFenceInfoMarker)
+		code := n.ChildByType(ast.NodeCodeBlockCode)
+		if nil == infoMarker || nil == code {
+			return ast.WalkSkipChildren
+		}
+		lang := strings.Fields(string(infoMarker.CodeBlockInfo))
+		if 1 > len(lang) {
+			return ast.WalkSkipChildren
+		}
+		switch strings.ToLower(lang[0]) {
+		case DiagramMermaid, DiagramPlantUML, DiagramGraphviz:
+		default:
+			return ast.WalkSkipChildren
+		}
+
+		data, err := RenderDiagram(lang[0], string(code.Tokens), format)
+		if nil != err {
+			logging.LogWarnf("render diagram [%s] in [%s] failed: %s", n.ID, tree.ID, err)
+			return ast.WalkSkipChildren
+		}
+
+		p := &ast.Node{Type: ast.NodeParagraph, ID: n.ID, KramdownIAL: n.KramdownIAL}
+		img := &ast.Node{Type: ast.NodeImage}
+		img.AppendChild(&ast.Node{Type: ast.NodeBang})
+		img.AppendChild(&ast.Node{Type: ast.NodeOpenBracket})
+		img.AppendChild(&ast.Node{Type: ast.NodeLinkText, Tokens: []byte(strings.ToLower(lang[0]))})
+		img.AppendChild(&ast.Node{Type: ast.NodeCloseBracket})
+		img.AppendChild(&ast.Node{Type: ast.NodeOpenParen})
+		img.AppendChild(&ast.Node{Type: ast.NodeLinkDest, Tokens: []byte(DiagramDataURI(data, format))})
+		img.AppendChild(&ast.Node{Type: ast.NodeCloseParen})
+		p.AppendChild(img)
+		n.InsertBefore(p)
+		unlinks = append(unlinks, n)
+		return ast.WalkSkipChildren
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+}
+
+func renderMermaid(code, format string) (ret []byte, err error) {
+	bin, err := lookDiagramBin(Conf.Export.MermaidBin, "mmdc")
+	if nil != err {
+		return
+	}
+
+	// Mermaid CLI 只支持文件输入输出
+	dir := filepath.Join(util.TempDir, "export", "diagrams", "mermaid-"+gulu.Rand.String(7))
+	if err = os.MkdirAll(dir, 0755); nil != err {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	input, output := filepath.Join(dir, "input.mmd"), filepath.Join(dir, "output."+format)
+	if err = os.WriteFile(input, []byte(code), 0644); nil != err {
+		return
+	}
+	if _, err = runDiagramCmd(nil, bin, "-q", "-b", "white", "-i", input, "-o", output); nil != err {
+		return
+	}
+	ret, err = os.ReadFile(output)
+	return
+}
+
+func renderGraphviz(code, format string) (ret []byte, err error) {
+	bin, err := lookDiagramBin(Conf.Export.GraphvizBin, "dot")
+	if nil != err {
+		return
+	}
+	return runDiagramCmd([]byte(code), bin, "-T"+format)
+}
+
+func renderPlantUML(code, format string) (ret []byte, err error) {
+	servePath := strings.TrimSpace(Conf.Editor.PlantUMLServePath)
+	if "" == servePath {
+		err = ErrDiagramRendererNotFound
+		return
+	}
+	if "png" == format {
+		servePath = strings.Replace(servePath, "/svg/", "/png/", 1)
+	}
+
+	encoded, err := encodePlantUML(code)
+	if nil != err {
+		return
+	}
+	u := servePath + encoded
+	resp, err := httpclient.NewBrowserRequest().Get(u)
+	if nil != err {
+		return
+	}
+	if 200 != resp.StatusCode {
+		err = fmt.Errorf("request [%s] responded with status code [%d]", servePath, resp.StatusCode)
+		return
+	}
+	ret, err = resp.ToBytes()
+	return
+}
+
+var plantUMLEncoding = base64.NewEncoding("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_").WithPadding(base64.NoPadding)
+
+// encodePlantUML 按 PlantUML 文本编码规则压缩编码源码，和前端使用的 plantuml-encoder 一致。
+func encodePlantUML(code string) (ret string, err error) {
+	buf := &bytes.Buffer{}
+	writer, err := flate.NewWriter(buf, flate.BestCompression)
+	if nil != err {
+		return
+	}
+	if _, err = writer.Write([]byte(code)); nil != err {
+		return
+	}
+	if err = writer.Close(); nil != err {
+		return
+	}
+
+	// PlantUML 编码末尾不足 3 字节时补零，每组固定输出 4 个字符
+	data := buf.Bytes()
+	for 0 != len(data)%3 {
+		data = append(data, 0)
+	}
+	ret = plantUMLEncoding.EncodeToString(data)
+	return
+}
+
+func lookDiagramBin(configured, name string) (ret string, err error) {
+	if configured = strings.TrimSpace(configured); "" != configured {
+		if err = CheckDiagramBin(configured, name); nil != err {
+			err = fmt.Errorf("%w: %s", ErrDiagramRendererNotFound, err)
+			return
+		}
+		ret = configured
+		return
+	}
+
+	if ret, err = exec.LookPath(name); nil != err {
+		err = fmt.Errorf("%w: [%s]", ErrDiagramRendererNotFound, name)
+	}
+	return
+}
+
+var (
+	mermaidVersionRegexp = regexp.MustCompile("^v?\\d+\\.\\d+\\.\\d+")
+	checkedDiagramBins   = sync.Map{} // 校验通过的可执行文件，键为路径和修改时间，避免每次渲染都探测版本
+)
+
+// CheckDiagramBin 检查配置的 Mermaid CLI（mmdc）或者 Graphviz（dot）是否是已经存在的可执行文件的绝对路径，并且版本输出符合预期。
+func CheckDiagramBin(bin, name string) error {
+	if !filepath.IsAbs(bin) {
+		return fmt.Errorf("[%s] must be an absolute path", bin)
+	}
+
+	info, err := os.Stat(bin)
+	if nil != err {
+		return fmt.Errorf("[%s] is not found: %s", bin, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("[%s] is not a regular file", bin)
+	}
+	if !gulu.OS.IsWindows() && 0 == info.Mode().Perm()&0111 {
+		return fmt.Errorf("[%s] is not executable", bin)
+	}
+
+	key := bin + "@" + info.ModTime().String()
+	if _, ok := checkedDiagramBins.Load(key); ok {
+		return nil
+	}
+
+	var versionArg string
+	switch name {
+	case "mmdc":
+		versionArg = "--version"
+	case "dot":
+		versionArg = "-V"
+	default:
+		return fmt.Errorf("unknown diagram renderer [%s]", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, versionArg)
+	gulu.CmdAttr(cmd)
+	data, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(data))
+	if nil != err || ("mmdc" == name && !mermaidVersionRegexp.MatchString(output)) || ("dot" == name && !strings.Contains(output, "graphviz version")) {
+		return fmt.Errorf("[%s] is not a valid %s executable", bin, name)
+	}
+	checkedDiagramBins.Store(key, true)
+	return nil
+}
+
+func runDiagramCmd(stdin []byte, bin string, args ...string) (ret []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	gulu.CmdAttr(cmd)
+	if nil != stdin {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if ret, err = cmd.Output(); nil != err {
+		err = errors.New(err.Error() + ": " + strings.TrimSpace(stderr.String()))
+	}
+	return
+}
@@ -27,6 +27,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 	"unicode/utf8"
 
@@ -38,6 +39,7 @@ import (
 	"github.com/88250/lute/parse"
 	util2 "github.com/88250/lute/util"
 	"github.com/facette/natsort"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/riff"
@@ -117,7 +119,7 @@ func (box *Box) docIAL(p string) (ret map[string]string) {
 
 	filePath := filepath.Join(util.DataDir, box.ID, p)
 
-	data, err := filelock.ReadFile(filePath)
+	data, err := filesys.ReadSyFile(filePath)
 	if util.IsCorruptedSYData(data) {
 		box.moveCorruptedData(filePath)
 		return nil
@@ -1072,6 +1074,15 @@ func writeTreeUpsertQueue(tree *parse.Tree) (err error) {
 	return
 }
 
+// writeTreeUpsertQueueDebounced 用于事务提交，开启保存防抖时短时间内的多次写入会被合并。
+func writeTreeUpsertQueueDebounced(tree *parse.Tree) (err error) {
+	if err = filesys.WriteTreeDebounced(tree); nil != err {
+		return
+	}
+	sql.UpsertTreeQueue(tree)
+	return
+}
+
 func writeTreeIndexQueue(tree *parse.Tree) (err error) {
 	if err = filesys.WriteTree(tree); nil != err {
 		return
@@ -1160,6 +1171,11 @@ func CreateWithMarkdown(boxID, hPath, md, parentID, id string, withMath bool) (r
 }
 
 func CreateDailyNote(boxID string) (p string, existed bool, err error) {
+	return CreateDailyNoteByDate(boxID, time.Now())
+}
+
+// CreateDailyNoteByDate 创建指定日期的日记，已经存在时直接返回。日记路径和模板中的 now 均按指定日期渲染。
+func CreateDailyNoteByDate(boxID string, date time.Time) (p string, existed bool, err error) {
 	createDocLock.Lock()
 	defer createDocLock.Unlock()
 
@@ -1175,7 +1191,8 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 		return
 	}
 
-	hPath, err := RenderGoTemplate(boxConf.DailyNoteSavePath)
+	tplFuncMap := dailyNoteTemplateFuncs(date)
+	hPath, err := renderGoTemplate(boxConf.DailyNoteSavePath, tplFuncMap)
 	if nil != err {
 		return
 	}
@@ -1193,9 +1210,9 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 			return
 		}
 		p = tree.Path
-		date := time.Now().Format("20060102")
-		if tree.Root.IALAttr("custom-dailynote-"+date) == "" {
-			tree.Root.SetIALAttr("custom-dailynote-"+date, date)
+		dateStr := date.Format("20060102")
+		if tree.Root.IALAttr("custom-dailynote-"+dateStr) == "" {
+			tree.Root.SetIALAttr("custom-dailynote-"+dateStr, dateStr)
 			if err = indexWriteTreeUpsertQueue(tree); nil != err {
 				return
 			}
@@ -1208,48 +1225,12 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 		return
 	}
 
-	var templateTree *parse.Tree
-	var templateDom string
 	if "" != boxConf.DailyNoteTemplatePath {
 		tplPath := filepath.Join(util.DataDir, "templates", boxConf.DailyNoteTemplatePath)
 		if !filelock.IsExist(tplPath) {
 			logging.LogWarnf("not found daily note template [%s]", tplPath)
-		} else {
-			var renderErr error
-			templateTree, templateDom, renderErr = RenderTemplate(tplPath, id, false)
-			if nil != renderErr {
-				logging.LogWarnf("render daily note template [%s] failed: %s", boxConf.DailyNoteTemplatePath, err)
-			}
-		}
-	}
-	if "" != templateDom {
-		var tree *parse.Tree
-		tree, err = LoadTreeByBlockID(id)
-		if nil == err {
-			tree.Root.FirstChild.Unlink()
-
-			luteEngine := util.NewLute()
-			newTree := luteEngine.BlockDOM2Tree(templateDom)
-			var children []*ast.Node
-			for c := newTree.Root.FirstChild; nil != c; c = c.Next {
-				children = append(children, c)
-			}
-			for _, c := range children {
-				tree.Root.AppendChild(c)
-			}
-
-			// Creating a dailynote template supports doc attributes https://github.com/siyuan-note/siyuan/issues/10698
-			templateIALs := parse.IAL2Map(templateTree.Root.KramdownIAL)
-			for k, v := range templateIALs {
-				if "name" == k || "alias" == k || "bookmark" == k || "memo" == k || strings.HasPrefix(k, "custom-") {
-					tree.Root.SetIALAttr(k, v)
-				}
-			}
-
-			tree.Root.SetIALAttr("updated", util.CurrentTimeSecondsStr())
-			if err = indexWriteTreeUpsertQueue(tree); nil != err {
-				return
-			}
+		} else if renderErr := fillDocWithTemplateFuncs(id, tplPath, tplFuncMap); nil != renderErr {
+			logging.LogWarnf("render daily note template [%s] failed: %s", boxConf.DailyNoteTemplatePath, renderErr)
 		}
 	}
 	IncSync()
@@ -1262,8 +1243,8 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 		return
 	}
 	p = tree.Path
-	date := time.Now().Format("20060102")
-	tree.Root.SetIALAttr("custom-dailynote-"+date, date)
+	dateStr := date.Format("20060102")
+	tree.Root.SetIALAttr("custom-dailynote-"+dateStr, dateStr)
 	if err = indexWriteTreeUpsertQueue(tree); nil != err {
 		return
 	}
@@ -1271,6 +1252,52 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 	return
 }
 
+// fillDocWithTemplate 使用模板渲染结果替换新建文档的内容。
+func fillDocWithTemplate(id, tplPath string) (err error) {
+	return fillDocWithTemplateFuncs(id, tplPath, templateFuncs())
+}
+
+func fillDocWithTemplateFuncs(id, tplPath string, tplFuncMap template.FuncMap) (err error) {
+	createPreOperationSnapshot(RiskyOpTemplate, filepath.Base(tplPath))
+	md, err := os.ReadFile(tplPath)
+	if nil != err {
+		return
+	}
+
+	templateTree, templateDom, err := renderTemplate(tplPath, md, id, false, nil, tplFuncMap)
+	if nil != err || "" == templateDom {
+		return
+	}
+
+	tree, err := LoadTreeByBlockID(id)
+	if nil != err {
+		return
+	}
+	tree.Root.FirstChild.Unlink()
+
+	luteEngine := util.NewLute()
+	newTree := luteEngine.BlockDOM2Tree(templateDom)
+	var children []*ast.Node
+	for c := newTree.Root.FirstChild; nil != c; c = c.Next {
+		children = append(children, c)
+	}
+	for _, c := range children {
+		tree.Root.AppendChild(c)
+	}
+
+	// Creating a dailynote template supports doc attributes https://github.com/siyuan-note/siyuan/issues/10698
+	templateIALs := parse.IAL2Map(templateTree.Root.KramdownIAL)
+	for k, v := range templateIALs {
+		if "name" == k || "alias" == k || "bookmark" == k || "memo" == k || strings.HasPrefix(k, "custom-") {
+			tree.Root.SetIALAttr(k, v)
+		}
+	}
+
+	tree.Root.SetIALAttr("updated", util.CurrentTimeSecondsStr())
+	err = indexWriteTreeUpsertQueue(tree)
+	return
+}
+
 func GetHPathByPath(boxID, p string) (hPath string, err error) {
 	if "/" == p {
 		hPath = "/"
@@ -1497,6 +1524,7 @@ func moveDoc(fromBox *Box, fromPath string, toBox *Box, toPath string, luteEngin
 				err = errors.New(msg)
 				return
 			}
+			filesys.RecordRenamedFile(absFromPath, absToPath)
 		}
 	}
 
@@ -1522,6 +1550,7 @@ func moveDoc(fromBox *Box, fromPath string, toBox *Box, toPath string, luteEngin
 			err = errors.New(msg)
 			return
 		}
+		filesys.RecordRenamedFile(absFromPath, absToPath)
 
 		tree, err = filesys.LoadTree(toBox.ID, newPath, luteEngine)
 		if nil != err {
@@ -1632,6 +1661,8 @@ func removeDoc(box *Box, p string, luteEngine *lute.Lute) {
 
 	box.removeSort(removeIDs)
 	RemoveRecentDoc(removeIDs)
+	RemoveBacklinkConfs(removeIDs)
+	RemoveDocShares(removeIDs)
 	if "/" != dir {
 		others, err := os.ReadDir(filepath.Join(util.DataDir, box.ID, dir))
 		if nil == err && 1 > len(others) {
@@ -1819,6 +1850,7 @@ func createDoc(boxID, p, title, dom string) (tree *parse.Tree, err error) {
 	transaction := &Transaction{DoOperations: []*Operation{{Action: "create", Data: tree}}}
 	PerformTransactions(&[]*Transaction{transaction})
 	WaitForWritingFiles()
+	eventbus.Publish(util.EvtDocCreated, &DocCreatedEvent{Event: util.EvtDocCreated, ID: id, Box: boxID, Path: p, HPath: hPath})
 	return
 }
 
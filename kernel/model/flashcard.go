@@ -29,6 +29,8 @@ import (
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
+	"github.com/open-spaced-repetition/go-fsrs"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/riff"
@@ -388,7 +390,11 @@ func getCardsBlocks(cards []riff.Card, page, pageSize int) (blocks []*Block, tot
 		due2 := cards[j].(*riff.FSRSCard).C.Due
 		return due1.Before(due2)
 	})
+	return pageCardsBlocks(cards, page, pageSize)
+}
 
+// pageCardsBlocks 按照卡片当前的顺序分页，并获取卡片对应的块。
+func pageCardsBlocks(cards []riff.Card, page, pageSize int) (blocks []*Block, total, pageCount int) {
 	total = len(cards)
 	pageCount = int(math.Ceil(float64(total) / float64(pageSize)))
 	start := (page - 1) * pageSize
@@ -466,6 +472,12 @@ func ReviewFlashcard(deckID, cardID string, rating riff.Rating, reviewedCardIDs
 		reviewCardCache[cardID] = card.Clone()
 	}
 
+	state := deck.GetCard(cardID).GetState()
+	var before *fsrs.Card
+	if fsrsCard, ok := deck.GetCard(cardID).(*riff.FSRSCard); ok {
+		c := *fsrsCard.C
+		before = &c
+	}
 	log := deck.Review(cardID, rating)
 	if err = deck.Save(); nil != err {
 		logging.LogErrorf("save deck [%s] failed: %s", deckID, err)
@@ -476,12 +488,15 @@ func ReviewFlashcard(deckID, cardID string, rating riff.Rating, reviewedCardIDs
 		logging.LogErrorf("save review log [%s] failed: %s", deckID, err)
 		return
 	}
+	appendReviewLog(deckID, card, rating, state, before, time.Now())
+	eventbus.Publish(util.EvtFlashcardReviewed, &FlashcardReviewedEvent{Event: util.EvtFlashcardReviewed, DeckID: deckID, CardID: cardID, BlockID: card.BlockID(), Rating: int(rating)})
 
 	_, unreviewedCount, _, _ := getDueFlashcards(deckID, reviewedCardIDs)
 	if 1 > unreviewedCount {
 		// 该卡包中没有待复习的卡片了，说明最后一张卡片已经复习完了，清空撤销缓存和跳过缓存
 		reviewCardCache = map[string]riff.Card{}
 		skipCardCache = map[string]riff.Card{}
+		reviewLogCache = map[string]string{}
 	}
 	return
 }
@@ -511,6 +526,7 @@ type Flashcard struct {
 	State      riff.State             `json:"state"`
 	LastReview int64                  `json:"lastReview"`
 	NextDues   map[riff.Rating]string `json:"nextDues"`
+	Cloze      int                    `json:"cloze,omitempty"` // 挖空闪卡的序号，复习时仅遮挡该序号对应的标记
 }
 
 func newFlashcard(card riff.Card, deckID string, now time.Time) *Flashcard {
@@ -528,6 +544,7 @@ func newFlashcard(card riff.Card, deckID string, now time.Time) *Flashcard {
 		State:      card.GetState(),
 		LastReview: card.GetLastReview().UnixMilli(),
 		NextDues:   nextDues,
+		Cloze:      getFlashcardMeta(card.ID()).Cloze,
 	}
 }
 
@@ -685,10 +702,11 @@ func getDueFlashcards(deckID string, reviewedCardIDs []string) (ret []*Flashcard
 		return
 	}
 
-	cards, unreviewedCnt, unreviewedNewCardCnt, unreviewedOldCardCnt := getDeckDueCards(deck, reviewedCardIDs, nil, Conf.Flashcard.NewCardLimit, Conf.Flashcard.ReviewCardLimit, Conf.Flashcard.ReviewMode)
+	// 复习父卡包时包含子孙卡包中的闪卡
+	cards, cardDecks, unreviewedCnt, unreviewedNewCardCnt, unreviewedOldCardCnt := getHierarchicalDeckDueCards(deck.ID, reviewedCardIDs)
 	now := time.Now()
 	for _, card := range cards {
-		ret = append(ret, newFlashcard(card, deckID, now))
+		ret = append(ret, newFlashcard(card, cardDecks[card.ID()], now))
 	}
 	if 1 > len(ret) {
 		ret = []*Flashcard{}
@@ -799,6 +817,7 @@ func (tx *Transaction) removeBlocksDeckAttr(blockIDs []string, deckID string) (e
 		val = strings.TrimSuffix(val, ",")
 		if "" == val {
 			node.RemoveIALAttr("custom-riff-decks")
+			node.RemoveIALAttr(clozeAttrName)
 		} else {
 			node.SetIALAttr("custom-riff-decks", val)
 		}
@@ -827,11 +846,13 @@ func removeFlashcardsByBlockIDs(blockIDs []string, deck *riff.Deck) {
 
 	for _, card := range cards {
 		deck.RemoveCard(card.ID())
+		delete(flashcardMetas, card.ID())
 	}
 	err := deck.Save()
 	if nil != err {
 		logging.LogErrorf("save deck [%s] failed: %s", deck.ID, err)
 	}
+	saveFlashcardMetas()
 }
 
 func (tx *Transaction) doAddFlashcards(operation *Operation) (ret *TxErr) {
@@ -932,6 +953,8 @@ func (tx *Transaction) doAddFlashcards(operation *Operation) (ret *TxErr) {
 }
 
 func LoadFlashcards() {
+	defer util.StartBootStage("flashcards", false)()
+
 	riffSavePath := getRiffDir()
 	if err := os.MkdirAll(riffSavePath, 0755); nil != err {
 		logging.LogErrorf("create riff dir [%s] failed: %s", riffSavePath, err)
@@ -939,6 +962,7 @@ func LoadFlashcards() {
 	}
 
 	Decks = map[string]*riff.Deck{}
+	loadDeckConfs()
 
 	entries, err := os.ReadDir(riffSavePath)
 	if nil != err {
@@ -949,7 +973,7 @@ func LoadFlashcards() {
 		name := entry.Name()
 		if strings.HasSuffix(name, ".deck") {
 			deckID := strings.TrimSuffix(name, ".deck")
-			deck, loadErr := riff.LoadDeck(riffSavePath, deckID, Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights)
+			deck, loadErr := loadDeck(deckID)
 			if nil != loadErr {
 				logging.LogErrorf("load deck [%s] failed: %s", name, loadErr)
 				continue
@@ -965,6 +989,8 @@ func LoadFlashcards() {
 			Decks[deckID] = deck
 		}
 	}
+
+	loadFlashcardMetas()
 }
 
 const builtinDeckID = "20230218211946-2kw8jgx"
@@ -1006,6 +1032,7 @@ func RemoveDeck(deckID string) (err error) {
 		}
 	}
 
+	removeDeckConf(deckID)
 	LoadFlashcards()
 	return
 }
@@ -1025,8 +1052,7 @@ func createDeck(name string) (deck *riff.Deck, err error) {
 }
 
 func createDeck0(name string, deckID string) (deck *riff.Deck, err error) {
-	riffSavePath := getRiffDir()
-	deck, err = riff.LoadDeck(riffSavePath, deckID, Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights)
+	deck, err = loadDeck(deckID)
 	if nil != err {
 		logging.LogErrorf("load deck [%s] failed: %s", deckID, err)
 		return
@@ -1079,12 +1105,18 @@ func getDeckDueCards(deck *riff.Deck, reviewedCardIDs, blockIDs []string, newCar
 
 	dues := deck.Dues()
 
+	now := time.Now()
 	var tmp []riff.Card
 	for _, c := range dues {
 		if 0 < len(blockIDs) && !gulu.Str.Contains(c.BlockID(), blockIDs) {
 			continue
 		}
 
+		if isFlashcardUnavailable(c.ID(), now) {
+			// 暂停和搁置的闪卡不参与复习
+			continue
+		}
+
 		if nil == treenode.GetBlockTree(c.BlockID()) {
 			continue
 		}
@@ -1098,6 +1130,7 @@ func getDeckDueCards(deck *riff.Deck, reviewedCardIDs, blockIDs []string, newCar
 		// 未传入已复习的卡片 ID，说明是开始新的复习，需要清空缓存
 		reviewCardCache = map[string]riff.Card{}
 		skipCardCache = map[string]riff.Card{}
+		reviewLogCache = map[string]string{}
 	}
 
 	newCount := 0
@@ -0,0 +1,192 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// FlashcardQuery 描述了闪卡浏览器的过滤、排序和分页条件。
+type FlashcardQuery struct {
+	DeckID        string  // 卡包 ID，为空时查询所有卡包
+	DueFrom       int64   // 到期时间下限，Unix 时间戳（毫秒），0 表示不限制
+	DueTo         int64   // 到期时间上限，Unix 时间戳（毫秒），0 表示不限制
+	MinLapses     int     // 遗忘次数下限
+	MaxLapses     int     // 遗忘次数上限，小于 0 表示不限制
+	States        []int   // 卡片状态，参考 riff.State，为空表示不限制
+	Suspended     int     // 0：不限制，1：仅暂停的卡片，2：仅未暂停的卡片
+	Notebook      string  // 卡片所在笔记本
+	Tag           string  // 卡片所在块的标签
+	MinDifficulty float64 // FSRS 难度下限（对应 Anki 的 ease），0 表示不限制
+	MaxDifficulty float64 // FSRS 难度上限，0 表示不限制
+	SortBy        string  // 排序字段：due/lapses/reps/difficulty/stability/lastReview/created，默认按到期时间
+	SortDesc      bool    // 是否降序
+	Page          int
+	PageSize      int
+}
+
+// BrowseFlashcards 按照条件过滤、排序并分页获取闪卡。
+func BrowseFlashcards(query *FlashcardQuery) (blocks []*Block, total, pageCount int) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	blocks = []*Block{}
+	var cards []riff.Card
+	for _, deck := range Decks {
+		if "" != query.DeckID && deck.ID != query.DeckID {
+			continue
+		}
+		cards = append(cards, deck.GetCardsByBlockIDs(deck.GetBlockIDs())...)
+	}
+
+	cards = filterFlashcards(cards, query)
+	sortFlashcards(cards, query.SortBy, query.SortDesc)
+
+	if 1 > query.Page {
+		query.Page = 1
+	}
+	if 1 > query.PageSize {
+		query.PageSize = 20
+	}
+	blocks, total, pageCount = pageCardsBlocks(cards, query.Page, query.PageSize)
+	return
+}
+
+func filterFlashcards(cards []riff.Card, query *FlashcardQuery) (ret []riff.Card) {
+	var dueFrom, dueTo time.Time
+	if 0 < query.DueFrom {
+		dueFrom = time.UnixMilli(query.DueFrom)
+	}
+	if 0 < query.DueTo {
+		dueTo = time.UnixMilli(query.DueTo)
+	}
+
+	for _, card := range cards {
+		c := card.(*riff.FSRSCard).C
+		if !dueFrom.IsZero() && c.Due.Before(dueFrom) {
+			continue
+		}
+		if !dueTo.IsZero() && c.Due.After(dueTo) {
+			continue
+		}
+		if lapses := int(c.Lapses); lapses < query.MinLapses || (0 <= query.MaxLapses && lapses > query.MaxLapses) {
+			continue
+		}
+		if 0 < len(query.States) && !containsFlashcardState(query.States, int(card.GetState())) {
+			continue
+		}
+		if 0 < query.MinDifficulty && c.Difficulty < query.MinDifficulty {
+			continue
+		}
+		if 0 < query.MaxDifficulty && c.Difficulty > query.MaxDifficulty {
+			continue
+		}
+		switch query.Suspended {
+		case 1:
+			if !isFlashcardSuspended(card.ID()) {
+				continue
+			}
+		case 2:
+			if isFlashcardSuspended(card.ID()) {
+				continue
+			}
+		}
+		if "" != query.Notebook {
+			bt := treenode.GetBlockTree(card.BlockID())
+			if nil == bt || bt.BoxID != query.Notebook {
+				continue
+			}
+		}
+		ret = append(ret, card)
+	}
+
+	if tag := strings.Trim(strings.TrimSpace(query.Tag), "#"); "" != tag && 0 < len(ret) {
+		ret = filterFlashcardsByTag(ret, tag)
+	}
+	return
+}
+
+func filterFlashcardsByTag(cards []riff.Card, tag string) (ret []riff.Card) {
+	var blockIDs []string
+	for _, card := range cards {
+		blockIDs = append(blockIDs, card.BlockID())
+	}
+
+	tagged := map[string]bool{}
+	for _, b := range sql.GetBlocks(blockIDs) {
+		if nil == b {
+			continue
+		}
+		// 标签字段格式为 #tag1# #tag2#，子标签也需要命中，比如过滤 a 时 a/b 也要命中
+		if strings.Contains(b.Tag, "#"+tag+"#") || strings.Contains(b.Tag, "#"+tag+"/") {
+			tagged[b.ID] = true
+		}
+	}
+
+	for _, card := range cards {
+		if tagged[card.BlockID()] {
+			ret = append(ret, card)
+		}
+	}
+	return
+}
+
+func containsFlashcardState(states []int, state int) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func sortFlashcards(cards []riff.Card, sortBy string, desc bool) {
+	less := func(i, j int) bool {
+		c1, c2 := cards[i].(*riff.FSRSCard).C, cards[j].(*riff.FSRSCard).C
+		switch sortBy {
+		case "lapses":
+			return c1.Lapses < c2.Lapses
+		case "reps":
+			return c1.Reps < c2.Reps
+		case "difficulty":
+			return c1.Difficulty < c2.Difficulty
+		case "stability":
+			return c1.Stability < c2.Stability
+		case "lastReview":
+			return c1.LastReview.Before(c2.LastReview)
+		case "created":
+			return cards[i].ID() < cards[j].ID()
+		default:
+			return c1.Due.Before(c2.Due)
+		}
+	}
+
+	sort.SliceStable(cards, func(i, j int) bool {
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
@@ -0,0 +1,200 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// clozeAttrName 标识挖空闪卡块，块中每一处标记（mark）都会生成一张挖空闪卡。
+const clozeAttrName = "custom-riff-cloze"
+
+// AddClozeFlashcards 将块添加为挖空闪卡，块中的每一处标记都会生成一张闪卡。
+func AddClozeFlashcards(deckID string, blockIDs []string) (err error) {
+	waitForSyncingStorages()
+
+	deckLock.Lock()
+	if nil == Decks[deckID] {
+		if builtinDeckID != deckID {
+			deckLock.Unlock()
+			return errors.New(fmt.Sprintf("deck [%s] not found", deckID))
+		}
+		if _, err = createDeck0("Built-in Deck", builtinDeckID); nil != err {
+			deckLock.Unlock()
+			return
+		}
+	}
+	deckLock.Unlock()
+
+	for _, blockID := range blockIDs {
+		tree, loadErr := LoadTreeByBlockID(blockID)
+		if nil != loadErr {
+			logging.LogWarnf("load tree by block [%s] failed: %s", blockID, loadErr)
+			continue
+		}
+
+		node := treenode.GetNodeInTree(tree, blockID)
+		if nil == node {
+			continue
+		}
+
+		deckIDs := strings.Split(node.IALAttr("custom-riff-decks"), ",")
+		deckIDs = append(deckIDs, deckID)
+		deckIDs = gulu.Str.RemoveDuplicatedElem(deckIDs)
+		val := strings.Trim(strings.Join(deckIDs, ","), ",")
+		if err = setNodeAttrs(node, tree, map[string]string{"custom-riff-decks": val, clozeAttrName: "true"}); nil != err {
+			return
+		}
+
+		syncClozeFlashcards(node)
+	}
+	return
+}
+
+// syncClozeFlashcards 在挖空闪卡块变更后同步闪卡，新增的标记生成闪卡，移除的标记删除闪卡，未变更的标记保留复习进度。
+func syncClozeFlashcards(node *ast.Node) {
+	var clozeNodes []*ast.Node
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() {
+			return ast.WalkContinue
+		}
+		if "true" == n.IALAttr(clozeAttrName) {
+			clozeNodes = append(clozeNodes, n)
+		}
+		return ast.WalkContinue
+	})
+	if 1 > len(clozeNodes) {
+		return
+	}
+
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	if isSyncingStorages() {
+		return
+	}
+
+	metasChanged := false
+	for _, clozeNode := range clozeNodes {
+		clozes := getClozeTexts(clozeNode)
+		for _, deckID := range strings.Split(clozeNode.IALAttr("custom-riff-decks"), ",") {
+			deck := Decks[deckID]
+			if nil == deck {
+				continue
+			}
+
+			if changed := syncDeckClozeFlashcards(deck, clozeNode.ID, clozes); changed {
+				metasChanged = true
+				if err := deck.Save(); nil != err {
+					logging.LogErrorf("save deck [%s] failed: %s", deckID, err)
+				}
+			}
+		}
+	}
+
+	if metasChanged {
+		saveFlashcardMetas()
+	}
+}
+
+// getClozeTexts 获取块中所有标记的文本，按照出现顺序排列。
+func getClozeTexts(node *ast.Node) (ret []string) {
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		if n != node && n.IsBlock() && "" != n.IALAttr(clozeAttrName) {
+			// 嵌套的挖空闪卡块单独生成闪卡
+			return ast.WalkSkipChildren
+		}
+		if ast.NodeTextMark == n.Type && n.IsTextMarkType("mark") {
+			if text := strings.TrimSpace(n.TextMarkTextContent); "" != text {
+				ret = append(ret, text)
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+func syncDeckClozeFlashcards(deck *riff.Deck, blockID string, clozes []string) (changed bool) {
+	cards := deck.GetCardsByBlockID(blockID)
+	matched := make([]riff.Card, len(clozes))
+	used := map[string]bool{}
+
+	// 先按照文本匹配，文本相同的标记沿用原有闪卡
+	for i, text := range clozes {
+		for _, card := range cards {
+			if !used[card.ID()] && getFlashcardMeta(card.ID()).ClozeText == text {
+				matched[i] = card
+				used[card.ID()] = true
+				break
+			}
+		}
+	}
+
+	// 再按照顺序匹配，修改了标记文本的闪卡也保留复习进度
+	for i := range clozes {
+		if nil != matched[i] {
+			continue
+		}
+		for _, card := range cards {
+			if !used[card.ID()] {
+				matched[i] = card
+				used[card.ID()] = true
+				break
+			}
+		}
+	}
+
+	for i, text := range clozes {
+		var cardID string
+		if nil == matched[i] {
+			cardID = ast.NewNodeID()
+			deck.AddCard(cardID, blockID)
+			changed = true
+		} else {
+			cardID = matched[i].ID()
+		}
+
+		meta := getFlashcardMeta(cardID)
+		if meta.Cloze != i+1 || meta.ClozeText != text {
+			meta.Cloze = i + 1
+			meta.ClozeText = text
+			flashcardMetas[cardID] = meta
+			changed = true
+		}
+	}
+
+	for _, card := range cards {
+		if used[card.ID()] {
+			continue
+		}
+		deck.RemoveCard(card.ID())
+		delete(flashcardMetas, card.ID())
+		changed = true
+	}
+	return
+}
@@ -0,0 +1,265 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+)
+
+// DeckConf 描述了卡包的层级关系和复习设置，为零值的设置项使用全局闪卡设置。
+type DeckConf struct {
+	Parent           string  `json:"parent"`           // 父卡包 ID，为空表示顶层卡包
+	NewCardLimit     int     `json:"newCardLimit"`     // 新卡上限
+	ReviewCardLimit  int     `json:"reviewCardLimit"`  // 复习卡上限
+	RequestRetention float64 `json:"requestRetention"` // FSRS 期望保留率
+	MaximumInterval  int     `json:"maximumInterval"`  // FSRS 最大间隔天数
+	Weights          string  `json:"weights"`          // FSRS 权重
+}
+
+// deckConfs <deckID, conf> 在加载卡包前加载，读写时需要持有 deckLock。
+var deckConfs = map[string]*DeckConf{}
+
+func getDeckConfPath() string {
+	return filepath.Join(getRiffDir(), "decks-conf.json")
+}
+
+func loadDeckConfs() {
+	deckConfs = map[string]*DeckConf{}
+
+	p := getDeckConfPath()
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read deck confs [%s] failed: %s", p, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &deckConfs); nil != err {
+		logging.LogErrorf("unmarshal deck confs [%s] failed: %s", p, err)
+		deckConfs = map[string]*DeckConf{}
+	}
+}
+
+func saveDeckConfs() (err error) {
+	for deckID, deckConf := range deckConfs {
+		if nil == deckConf || (DeckConf{}) == *deckConf {
+			delete(deckConfs, deckID)
+		}
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(deckConfs, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal deck confs failed: %s", err)
+		return
+	}
+
+	p := getDeckConfPath()
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write deck confs [%s] failed: %s", p, err)
+	}
+	return
+}
+
+// GetDeckConf 获取卡包设置。
+func GetDeckConf(deckID string) (ret *DeckConf) {
+	ret = &DeckConf{}
+	if deckConf := deckConfs[deckID]; nil != deckConf {
+		*ret = *deckConf
+	}
+	return
+}
+
+// SetDeckConf 设置卡包的父卡包和复习设置，修改 FSRS 参数后会重新加载卡包。
+func SetDeckConf(deckID string, deckConf *DeckConf) (err error) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	if nil == Decks[deckID] {
+		return errors.New(fmt.Sprintf("deck [%s] not found", deckID))
+	}
+	if "" != deckConf.Parent {
+		if nil == Decks[deckConf.Parent] {
+			return errors.New(fmt.Sprintf("parent deck [%s] not found", deckConf.Parent))
+		}
+		for p := deckConf.Parent; "" != p; p = deckConfs[p].getParent() {
+			if p == deckID {
+				return errors.New("can not move a deck into itself or its descendants")
+			}
+		}
+	}
+	if 0 > deckConf.NewCardLimit || 0 > deckConf.ReviewCardLimit || 0 > deckConf.MaximumInterval {
+		return errors.New("invalid deck conf")
+	}
+	if 0 != deckConf.RequestRetention && (0 >= deckConf.RequestRetention || 1 <= deckConf.RequestRetention) {
+		return errors.New("invalid request retention")
+	}
+
+	old := GetDeckConf(deckID)
+	deckConfs[deckID] = deckConf
+	if err = saveDeckConfs(); nil != err {
+		return
+	}
+
+	if old.RequestRetention != deckConf.RequestRetention || old.MaximumInterval != deckConf.MaximumInterval || old.Weights != deckConf.Weights {
+		deck, loadErr := loadDeck(deckID)
+		if nil != loadErr {
+			return loadErr
+		}
+		Decks[deckID] = deck
+	}
+	return
+}
+
+func (deckConf *DeckConf) getParent() string {
+	if nil == deckConf {
+		return ""
+	}
+	return deckConf.Parent
+}
+
+// loadDeck 使用卡包覆盖的 FSRS 参数加载卡包。
+func loadDeck(deckID string) (deck *riff.Deck, err error) {
+	requestRetention, maximumInterval, weights := getDeckFSRSParams(deckID)
+	deck, err = riff.LoadDeck(getRiffDir(), deckID, requestRetention, maximumInterval, weights)
+	return
+}
+
+func getDeckFSRSParams(deckID string) (requestRetention float64, maximumInterval int, weights string) {
+	requestRetention, maximumInterval, weights = Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights
+	deckConf := deckConfs[deckID]
+	if nil == deckConf {
+		return
+	}
+	if 0 < deckConf.RequestRetention {
+		requestRetention = deckConf.RequestRetention
+	}
+	if 0 < deckConf.MaximumInterval {
+		maximumInterval = deckConf.MaximumInterval
+	}
+	if "" != deckConf.Weights {
+		weights = deckConf.Weights
+	}
+	return
+}
+
+func getDeckCardLimits(deckID string) (newCardLimit, reviewCardLimit int) {
+	newCardLimit, reviewCardLimit = Conf.Flashcard.NewCardLimit, Conf.Flashcard.ReviewCardLimit
+	deckConf := deckConfs[deckID]
+	if nil == deckConf {
+		return
+	}
+	if 0 < deckConf.NewCardLimit {
+		newCardLimit = deckConf.NewCardLimit
+	}
+	if 0 < deckConf.ReviewCardLimit {
+		reviewCardLimit = deckConf.ReviewCardLimit
+	}
+	return
+}
+
+// getDeckWithDescendants 获取卡包及其所有子孙卡包，父卡包在前。
+func getDeckWithDescendants(deckID string) (ret []*riff.Deck) {
+	deck := Decks[deckID]
+	if nil == deck {
+		return
+	}
+
+	ret = append(ret, deck)
+	visited := map[string]bool{deckID: true}
+	for i := 0; i < len(ret); i++ {
+		for id, child := range Decks {
+			if !visited[id] && deckConfs[id].getParent() == ret[i].ID {
+				visited[id] = true
+				ret = append(ret, child)
+			}
+		}
+	}
+	return
+}
+
+// removeDeckConf 移除卡包设置，子卡包挂到被移除卡包的父卡包下。
+func removeDeckConf(deckID string) {
+	parent := deckConfs[deckID].getParent()
+	for _, deckConf := range deckConfs {
+		if nil != deckConf && deckID == deckConf.Parent {
+			deckConf.Parent = parent
+		}
+	}
+	delete(deckConfs, deckID)
+	saveDeckConfs()
+}
+
+// getHierarchicalDeckDueCards 获取卡包及其子孙卡包中到期的闪卡，每个卡包使用自己的上限，合并后再使用父卡包的上限。
+//
+// cardDecks 记录了每张闪卡所属的卡包 ID。
+func getHierarchicalDeckDueCards(deckID string, reviewedCardIDs []string) (ret []riff.Card, cardDecks map[string]string, unreviewedCount, unreviewedNewCardCount, unreviewedOldCardCount int) {
+	ret = []riff.Card{}
+	cardDecks = map[string]string{}
+	decks := getDeckWithDescendants(deckID)
+	if 1 > len(decks) {
+		return
+	}
+
+	rootNewCardLimit, rootReviewCardLimit := getDeckCardLimits(deckID)
+	var retNew, retOld []riff.Card
+	for _, deck := range decks {
+		newCardLimit, reviewCardLimit := getDeckCardLimits(deck.ID)
+		cards, unreviewedCnt, unreviewedNewCardCnt, unreviewedOldCardCnt := getDeckDueCards(deck, reviewedCardIDs, nil, newCardLimit, reviewCardLimit, Conf.Flashcard.ReviewMode)
+		unreviewedCount += unreviewedCnt
+		unreviewedNewCardCount += unreviewedNewCardCnt
+		unreviewedOldCardCount += unreviewedOldCardCnt
+		for _, card := range cards {
+			cardDecks[card.ID()] = deck.ID
+			if riff.New == card.GetState() {
+				if len(retNew) < rootNewCardLimit {
+					retNew = append(retNew, card)
+					ret = append(ret, card)
+				}
+			} else {
+				if len(retOld) < rootReviewCardLimit {
+					retOld = append(retOld, card)
+					ret = append(ret, card)
+				}
+			}
+		}
+	}
+	if unreviewedNewCardCount > rootNewCardLimit {
+		unreviewedNewCardCount = rootNewCardLimit
+	}
+	if unreviewedOldCardCount > rootReviewCardLimit {
+		unreviewedOldCardCount = rootReviewCardLimit
+	}
+
+	switch Conf.Flashcard.ReviewMode {
+	case 1: // 优先复习新卡
+		ret = append(retNew, retOld...)
+	case 2: // 优先复习旧卡
+		ret = append(retOld, retNew...)
+	}
+	return
+}
@@ -0,0 +1,269 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+// FilteredDeckQuery 描述了筛选卡包的查询条件。
+type FilteredDeckQuery struct {
+	DeckID      string `json:"deckID"`      // 仅从该卡包中筛选，为空时从所有卡包中筛选
+	Tag         string `json:"tag"`         // 块标签
+	Notebook    string `json:"notebook"`    // 笔记本 ID
+	AttrName    string `json:"attrName"`    // 块属性名
+	AttrValue   string `json:"attrValue"`   // 块属性值，为空时仅要求存在该属性
+	OverdueOnly bool   `json:"overdueOnly"` // 仅包含今天之前就已经到期的闪卡
+	Limit       int    `json:"limit"`       // 最多包含的闪卡数
+}
+
+// FilteredDeckStat 描述了筛选卡包的复习统计。
+type FilteredDeckStat struct {
+	Total    int `json:"total"`
+	Reviewed int `json:"reviewed"`
+	Again    int `json:"again"`
+	Hard     int `json:"hard"`
+	Good     int `json:"good"`
+	Easy     int `json:"easy"`
+}
+
+// FilteredDeck 描述了按照查询条件在服务端临时组装的卡包，类似于 Anki 的筛选卡组。
+//
+// 筛选卡包不会持久化，闪卡仍然属于原来的卡包，复习进度也保存在原来的卡包中。
+type FilteredDeck struct {
+	ID      string             `json:"id"`
+	Name    string             `json:"name"`
+	Query   *FilteredDeckQuery `json:"query"`
+	Created int64              `json:"created"`
+	Stat    *FilteredDeckStat  `json:"stat"`
+
+	cards     []string          // 闪卡 ID，按照到期时间升序排列
+	cardDecks map[string]string // <cardID, deckID>
+}
+
+var (
+	filteredDecks    = map[string]*FilteredDeck{}
+	filteredDeckLock = sync.Mutex{}
+)
+
+// CreateFilteredDeck 按照查询条件组装筛选卡包。
+func CreateFilteredDeck(name string, query *FilteredDeckQuery) (ret *FilteredDeck, err error) {
+	name = strings.TrimSpace(name)
+	if "" == name {
+		name = time.Now().Format("2006-01-02 15:04")
+	}
+	if 1 > query.Limit {
+		query.Limit = 100
+	}
+
+	deckLock.Lock()
+	waitForSyncingStorages()
+	if "" != query.DeckID && nil == Decks[query.DeckID] {
+		deckLock.Unlock()
+		err = errors.New(fmt.Sprintf("deck [%s] not found", query.DeckID))
+		return
+	}
+	cards, cardDecks := queryFilteredDeckCards(query)
+	deckLock.Unlock()
+
+	ret = &FilteredDeck{
+		ID:        ast.NewNodeID(),
+		Name:      name,
+		Query:     query,
+		Created:   time.Now().UnixMilli(),
+		Stat:      &FilteredDeckStat{Total: len(cards)},
+		cardDecks: cardDecks,
+	}
+	for _, card := range cards {
+		ret.cards = append(ret.cards, card.ID())
+	}
+
+	filteredDeckLock.Lock()
+	filteredDecks[ret.ID] = ret
+	filteredDeckLock.Unlock()
+	return
+}
+
+func queryFilteredDeckCards(query *FilteredDeckQuery) (ret []riff.Card, cardDecks map[string]string) {
+	cardDecks = map[string]string{}
+	now := time.Now()
+	dueTo := now
+	if query.OverdueOnly {
+		dueTo = beginningOfDay(now)
+	}
+
+	browseQuery := &FlashcardQuery{
+		Notebook:  query.Notebook,
+		Tag:       query.Tag,
+		Suspended: 2,
+		MaxLapses: -1,
+		DueTo:     dueTo.UnixMilli(),
+	}
+	var attrBlockIDs map[string]bool
+	if attrName := strings.TrimSpace(query.AttrName); "" != attrName {
+		attrBlockIDs = queryBlockIDsByAttr(attrName, query.AttrValue)
+	}
+
+	for _, deck := range Decks {
+		if "" != query.DeckID && deck.ID != query.DeckID {
+			continue
+		}
+
+		cards := filterFlashcards(deck.Dues(), browseQuery)
+		for _, card := range cards {
+			if isFlashcardUnavailable(card.ID(), now) {
+				continue
+			}
+			if nil != attrBlockIDs && !attrBlockIDs[card.BlockID()] {
+				continue
+			}
+			if _, ok := cardDecks[card.ID()]; ok {
+				continue
+			}
+
+			cardDecks[card.ID()] = deck.ID
+			ret = append(ret, card)
+		}
+	}
+
+	sortFlashcards(ret, "due", false)
+	if len(ret) > query.Limit {
+		ret = ret[:query.Limit]
+	}
+	return
+}
+
+func queryBlockIDsByAttr(name, value string) (ret map[string]bool) {
+	ret = map[string]bool{}
+	stmt := "SELECT block_id FROM attributes WHERE name = '" + strings.ReplaceAll(name, "'", "''") + "'"
+	if "" != value {
+		stmt += " AND value = '" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	result, err := sql.QueryNoLimit(stmt)
+	if nil != err {
+		return
+	}
+	for _, row := range result {
+		ret[row["block_id"].(string)] = true
+	}
+	return
+}
+
+// GetFilteredDecks 获取所有筛选卡包，按照创建时间倒序排列。
+func GetFilteredDecks() (ret []*FilteredDeck) {
+	filteredDeckLock.Lock()
+	defer filteredDeckLock.Unlock()
+
+	ret = []*FilteredDeck{}
+	for _, filteredDeck := range filteredDecks {
+		ret = append(ret, filteredDeck)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created > ret[j].Created
+	})
+	return
+}
+
+// RemoveFilteredDeck 移除筛选卡包，闪卡仍然保留在原来的卡包中。
+func RemoveFilteredDeck(id string) {
+	filteredDeckLock.Lock()
+	defer filteredDeckLock.Unlock()
+
+	delete(filteredDecks, id)
+}
+
+// GetFilteredDeckDueCards 获取筛选卡包中还需要复习的闪卡。
+func GetFilteredDeckDueCards(id string, reviewedCardIDs []string) (ret []*Flashcard, stat *FilteredDeckStat, err error) {
+	filteredDeckLock.Lock()
+	defer filteredDeckLock.Unlock()
+
+	filteredDeck := filteredDecks[id]
+	if nil == filteredDeck {
+		err = errors.New(fmt.Sprintf("filtered deck [%s] not found", id))
+		return
+	}
+
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	ret = []*Flashcard{}
+	now := time.Now()
+	for _, cardID := range filteredDeck.cards {
+		if gulu.Str.Contains(cardID, reviewedCardIDs) || isFlashcardUnavailable(cardID, now) {
+			continue
+		}
+
+		deckID := filteredDeck.cardDecks[cardID]
+		deck := Decks[deckID]
+		if nil == deck {
+			continue
+		}
+		card := deck.GetCard(cardID)
+		if nil == card || card.(*riff.FSRSCard).C.Due.After(now) {
+			continue
+		}
+		ret = append(ret, newFlashcard(card, deckID, now))
+	}
+	stat = filteredDeck.Stat
+	return
+}
+
+// ReviewFilteredDeckCard 复习筛选卡包中的闪卡，复习进度保存在闪卡原来的卡包中。
+func ReviewFilteredDeckCard(id, cardID string, rating riff.Rating, reviewedCardIDs []string) (err error) {
+	filteredDeckLock.Lock()
+	filteredDeck := filteredDecks[id]
+	filteredDeckLock.Unlock()
+	if nil == filteredDeck {
+		return errors.New(fmt.Sprintf("filtered deck [%s] not found", id))
+	}
+
+	deckID := filteredDeck.cardDecks[cardID]
+	if "" == deckID {
+		return errors.New(fmt.Sprintf("card [%s] not found in filtered deck [%s]", cardID, id))
+	}
+
+	if err = ReviewFlashcard(deckID, cardID, rating, reviewedCardIDs); nil != err {
+		return
+	}
+
+	filteredDeckLock.Lock()
+	defer filteredDeckLock.Unlock()
+
+	stat := filteredDeck.Stat
+	stat.Reviewed++
+	switch rating {
+	case riff.Again:
+		stat.Again++
+	case riff.Hard:
+		stat.Hard++
+	case riff.Good:
+		stat.Good++
+	case riff.Easy:
+		stat.Easy++
+	}
+	return
+}
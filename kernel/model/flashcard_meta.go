@@ -0,0 +1,97 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+)
+
+// FlashcardMeta 描述了 riff 之外由内核维护的卡片状态。
+type FlashcardMeta struct {
+	Suspended   bool   `json:"suspended,omitempty"`   // 是否已暂停，暂停的卡片不参与复习
+	BuriedUntil int64  `json:"buriedUntil,omitempty"` // 搁置截止时间，Unix 时间戳（毫秒），在此之前不参与复习
+	Cloze       int    `json:"cloze,omitempty"`       // 挖空闪卡的序号，从 1 开始，对应块中第几处标记
+	ClozeText   string `json:"clozeText,omitempty"`   // 挖空闪卡对应的标记文本
+}
+
+// flashcardMetas <cardID, meta> 在加载卡包时一起加载，读写时需要持有 deckLock。
+var flashcardMetas = map[string]*FlashcardMeta{}
+
+func getFlashcardMetaPath() string {
+	return filepath.Join(getRiffDir(), "cards-meta.json")
+}
+
+func loadFlashcardMetas() {
+	flashcardMetas = map[string]*FlashcardMeta{}
+
+	p := getFlashcardMetaPath()
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read flashcard metas [%s] failed: %s", p, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &flashcardMetas); nil != err {
+		logging.LogErrorf("unmarshal flashcard metas [%s] failed: %s", p, err)
+		flashcardMetas = map[string]*FlashcardMeta{}
+	}
+}
+
+func saveFlashcardMetas() (err error) {
+	for cardID, meta := range flashcardMetas {
+		if nil == meta || (FlashcardMeta{}) == *meta {
+			delete(flashcardMetas, cardID)
+		}
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(flashcardMetas, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal flashcard metas failed: %s", err)
+		return
+	}
+
+	p := getFlashcardMetaPath()
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write flashcard metas [%s] failed: %s", p, err)
+	}
+	return
+}
+
+func getFlashcardMeta(cardID string) *FlashcardMeta {
+	if meta := flashcardMetas[cardID]; nil != meta {
+		return meta
+	}
+	return &FlashcardMeta{}
+}
+
+func isFlashcardSuspended(cardID string) bool {
+	return getFlashcardMeta(cardID).Suspended
+}
+
+// isFlashcardUnavailable 判断卡片是否因为暂停或者搁置而不参与复习。
+func isFlashcardUnavailable(cardID string, now time.Time) bool {
+	meta := getFlashcardMeta(cardID)
+	return meta.Suspended || now.UnixMilli() < meta.BuriedUntil
+}
@@ -0,0 +1,147 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+)
+
+// reviewTimeTolerance 复习记录时间和卡片上次复习时间之间允许的误差（毫秒）。
+const reviewTimeTolerance = 5 * 1000
+
+// reconcileFlashcardReviews 在同步后合并多设备的复习记录。
+//
+// 同一张闪卡在两台设备上同步前分别复习后，同步时只会保留其中一台设备的卡片数据，另一台设备的复习会丢失。
+// 复习记录按设备分文件保存，同步后是所有设备复习记录的并集，这里按照复习时间顺序重放复习记录，确定性地重新计算卡片调度数据。
+func reconcileFlashcardReviews() {
+	defer logging.Recover()
+
+	cardLogs := map[string][]*ReviewLog{}
+	for _, log := range loadReviewLogs(time.Time{}) {
+		if nil == log.Before {
+			continue
+		}
+		cardLogs[log.CardID] = append(cardLogs[log.CardID], log)
+	}
+	if 1 > len(cardLogs) {
+		return
+	}
+
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	changedDecks := map[string]*riff.Deck{}
+	for cardID, logs := range cardLogs {
+		deck, card := getFlashcardByID(cardID)
+		if nil == card {
+			continue
+		}
+		fsrsCard, ok := card.(*riff.FSRSCard)
+		if !ok {
+			continue
+		}
+
+		logs = currentReviewChain(logs)
+		if !isReviewChainDiverged(logs, fsrsCard.C) {
+			continue
+		}
+
+		replayed := replayReviewLogs(logs, newFSRSParameters(deck.ID))
+		*fsrsCard.C = replayed
+		changedDecks[deck.ID] = deck
+		logging.LogInfof("reconciled flashcard [%s] with [%d] review logs", cardID, len(logs))
+	}
+
+	for deckID, deck := range changedDecks {
+		if err := deck.Save(); nil != err {
+			logging.LogErrorf("save deck [%s] failed: %s", deckID, err)
+		}
+	}
+}
+
+// currentReviewChain 返回最近一次从新卡开始（比如重置学习进度后）的复习记录。
+func currentReviewChain(logs []*ReviewLog) []*ReviewLog {
+	start := 0
+	for i, log := range logs {
+		if log.Before.LastReview.IsZero() {
+			start = i
+		}
+	}
+	return logs[start:]
+}
+
+// isReviewChainDiverged 判断复习记录是否发生了分叉（某次复习基于过期的卡片数据），或者卡片数据缺失了最近的复习。
+func isReviewChainDiverged(logs []*ReviewLog, card *fsrs.Card) bool {
+	if 1 > len(logs) {
+		return false
+	}
+
+	if card.LastReview.UnixMilli() < logs[len(logs)-1].Reviewed-reviewTimeTolerance {
+		return true
+	}
+
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Before.LastReview.UnixMilli() < logs[i-1].Reviewed-reviewTimeTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// replayReviewLogs 从第一条复习记录的复习前数据开始，按照复习时间依次重放所有复习记录。
+func replayReviewLogs(logs []*ReviewLog, params fsrs.Parameters) (ret fsrs.Card) {
+	ret = *logs[0].Before
+	for _, log := range logs {
+		now := time.UnixMilli(log.Reviewed)
+		schedulingCards := params.Repeat(ret, now)
+		ret = schedulingCards[fsrs.Rating(log.Rating)].Card
+	}
+	return
+}
+
+func newFSRSParameters(deckID string) (ret fsrs.Parameters) {
+	ret = fsrs.DefaultParam()
+	requestRetention, maximumInterval, weights := getDeckFSRSParams(deckID)
+	if 0 < requestRetention {
+		ret.RequestRetention = requestRetention
+	}
+	if 0 < maximumInterval {
+		ret.MaximumInterval = float64(maximumInterval)
+	}
+
+	var ws []float64
+	for _, w := range strings.Split(weights, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		if nil != err {
+			return
+		}
+		ws = append(ws, f)
+	}
+	if len(ws) != len(ret.W) {
+		return
+	}
+	for i := range ret.W {
+		ret.W[i] = ws[i]
+	}
+	return
+}
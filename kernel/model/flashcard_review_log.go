@@ -0,0 +1,162 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/open-spaced-repetition/go-fsrs"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+)
+
+// ReviewLog 描述了一次闪卡复习记录。
+//
+// 复习记录按照设备和月份分文件追加保存，不同设备之间不会修改同一个文件，同步时不会产生冲突。
+type ReviewLog struct {
+	ID       string      `json:"id"`
+	DeckID   string      `json:"deckID"`
+	CardID   string      `json:"cardID"`
+	BlockID  string      `json:"blockID"`
+	Rating   riff.Rating `json:"rating"`
+	State    riff.State  `json:"state"`              // 复习前的卡片状态
+	Reviewed int64       `json:"reviewed"`           // 复习时间，Unix 时间戳（毫秒）
+	Replaces string      `json:"replaces,omitempty"` // 撤销后再次复习时，被替换的复习记录 ID
+	Before   *fsrs.Card  `json:"before,omitempty"`   // 复习前的卡片调度数据，用于多设备复习冲突后重新计算
+}
+
+// reviewLogCache <cardID, logID> 用于撤销后再次复习时替换上一次的复习记录。
+var reviewLogCache = map[string]string{}
+
+func getReviewLogDir() string {
+	return filepath.Join(getRiffDir(), "reviews")
+}
+
+func appendReviewLog(deckID string, card riff.Card, rating riff.Rating, state riff.State, before *fsrs.Card, reviewed time.Time) {
+	log := &ReviewLog{
+		ID:       ast.NewNodeID(),
+		DeckID:   deckID,
+		CardID:   card.ID(),
+		BlockID:  card.BlockID(),
+		Rating:   rating,
+		State:    state,
+		Reviewed: reviewed.UnixMilli(),
+		Replaces: reviewLogCache[card.ID()],
+		Before:   before,
+	}
+
+	data, err := gulu.JSON.MarshalJSON(log)
+	if nil != err {
+		logging.LogErrorf("marshal review log failed: %s", err)
+		return
+	}
+
+	p := filepath.Join(getReviewLogDir(), reviewed.Format("200601")+"-"+Conf.System.ID+".jsonl")
+	var content []byte
+	if filelock.IsExist(p) {
+		if content, err = filelock.ReadFile(p); nil != err {
+			logging.LogErrorf("read review log [%s] failed: %s", p, err)
+			return
+		}
+	}
+	content = append(content, data...)
+	content = append(content, '\n')
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		logging.LogErrorf("create review log dir failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(p, content); nil != err {
+		logging.LogErrorf("write review log [%s] failed: %s", p, err)
+		return
+	}
+	reviewLogCache[card.ID()] = log.ID
+}
+
+// loadReviewLogs 加载 from 之后（含）的所有设备的复习记录，被撤销替换的记录会被过滤掉，结果按照复习时间升序排列。
+func loadReviewLogs(from time.Time) (ret []*ReviewLog) {
+	dir := getReviewLogDir()
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			logging.LogErrorf("read review log dir [%s] failed: %s", dir, err)
+		}
+		return
+	}
+
+	fromMonth := from.Format("200601")
+	seen := map[string]bool{}
+	replaced := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".jsonl") || 6 > len(name) || name[:6] < fromMonth {
+			continue
+		}
+
+		data, readErr := filelock.ReadFile(filepath.Join(dir, name))
+		if nil != readErr {
+			logging.LogErrorf("read review log [%s] failed: %s", name, readErr)
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if 1 > len(line) {
+				continue
+			}
+
+			log := &ReviewLog{}
+			if err = gulu.JSON.UnmarshalJSON(line, log); nil != err {
+				logging.LogWarnf("unmarshal review log [%s] failed: %s", name, err)
+				continue
+			}
+			if seen[log.ID] || log.Reviewed < from.UnixMilli() {
+				continue
+			}
+			seen[log.ID] = true
+			if "" != log.Replaces {
+				replaced[log.Replaces] = true
+			}
+			ret = append(ret, log)
+		}
+	}
+
+	tmp := ret[:0]
+	for _, log := range ret {
+		if !replaced[log.ID] {
+			tmp = append(tmp, log)
+		}
+	}
+	ret = tmp
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Reviewed == ret[j].Reviewed {
+			return ret[i].ID < ret[j].ID
+		}
+		return ret[i].Reviewed < ret[j].Reviewed
+	})
+	return
+}
@@ -0,0 +1,151 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+)
+
+// SuspendFlashcards 暂停或者恢复闪卡，暂停的闪卡不参与复习。
+func SuspendFlashcards(cardIDs []string, suspend bool) (err error) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	for _, cardID := range cardIDs {
+		if _, card := getFlashcardByID(cardID); nil == card {
+			continue
+		}
+
+		meta := getFlashcardMeta(cardID)
+		meta.Suspended = suspend
+		flashcardMetas[cardID] = meta
+	}
+	return saveFlashcardMetas()
+}
+
+// BuryFlashcards 将闪卡搁置到明天。siblings 为 true 时搁置与这些闪卡属于同一个块的其他闪卡（比如同一个块中的挖空闪卡）。
+func BuryFlashcards(cardIDs []string, siblings bool) (err error) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	tomorrow := beginningOfDay(time.Now()).AddDate(0, 0, 1).UnixMilli()
+	var buryCardIDs []string
+	for _, cardID := range cardIDs {
+		deck, card := getFlashcardByID(cardID)
+		if nil == card {
+			continue
+		}
+
+		if !siblings {
+			buryCardIDs = append(buryCardIDs, cardID)
+			continue
+		}
+
+		for _, sibling := range deck.GetCardsByBlockID(card.BlockID()) {
+			if sibling.ID() != cardID {
+				buryCardIDs = append(buryCardIDs, sibling.ID())
+			}
+		}
+	}
+
+	for _, cardID := range buryCardIDs {
+		meta := getFlashcardMeta(cardID)
+		meta.BuriedUntil = tomorrow
+		flashcardMetas[cardID] = meta
+	}
+	return saveFlashcardMetas()
+}
+
+// UnburyFlashcards 取消闪卡的搁置。
+func UnburyFlashcards(cardIDs []string) (err error) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	for _, cardID := range cardIDs {
+		if meta := flashcardMetas[cardID]; nil != meta {
+			meta.BuriedUntil = 0
+		}
+	}
+	return saveFlashcardMetas()
+}
+
+// RescheduleFlashcards 手动设置闪卡的下次复习时间。due 不为零值时使用 due，否则使用 interval 天后作为下次复习时间。
+func RescheduleFlashcards(cardIDs []string, due time.Time, interval int) (err error) {
+	if due.IsZero() && 0 > interval {
+		return errors.New("invalid due or interval")
+	}
+
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	now := time.Now()
+	if due.IsZero() {
+		due = now.AddDate(0, 0, interval)
+	}
+	scheduledDays := int(beginningOfDay(due).Sub(beginningOfDay(now)).Hours() / 24)
+	if 0 > scheduledDays {
+		scheduledDays = 0
+	}
+
+	changedDecks := map[string]*riff.Deck{}
+	for _, cardID := range cardIDs {
+		deck, card := getFlashcardByID(cardID)
+		if nil == card {
+			continue
+		}
+
+		card.SetDue(due)
+		if fsrsCard, ok := card.(*riff.FSRSCard); ok {
+			fsrsCard.C.ScheduledDays = uint64(scheduledDays)
+		}
+		changedDecks[deck.ID] = deck
+
+		// 手动设置复习时间后不再搁置
+		if meta := flashcardMetas[cardID]; nil != meta {
+			meta.BuriedUntil = 0
+		}
+	}
+
+	for deckID, deck := range changedDecks {
+		if err = deck.Save(); nil != err {
+			logging.LogErrorf("save deck [%s] failed: %s", deckID, err)
+			return
+		}
+	}
+	return saveFlashcardMetas()
+}
+
+func getFlashcardByID(cardID string) (deck *riff.Deck, card riff.Card) {
+	for _, d := range Decks {
+		if c := d.GetCard(cardID); nil != c {
+			return d, c
+		}
+	}
+	return
+}
@@ -0,0 +1,171 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"math"
+	"time"
+
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// ReviewDayStat 描述了某一天的复习统计。
+type ReviewDayStat struct {
+	Date       string `json:"date"` // 日期，格式为 2006-01-02
+	Count      int    `json:"count"`
+	New        int    `json:"new"`        // 复习前为新卡的次数
+	Learning   int    `json:"learning"`   // 复习前为学习中的次数
+	Review     int    `json:"review"`     // 复习前为复习中的次数
+	Relearning int    `json:"relearning"` // 复习前为重新学习的次数
+	Again      int    `json:"again"`
+	Hard       int    `json:"hard"`
+	Good       int    `json:"good"`
+	Easy       int    `json:"easy"`
+}
+
+// ReviewStat 描述了一段时间内的复习统计。
+type ReviewStat struct {
+	Days      []*ReviewDayStat `json:"days"`
+	Total     int              `json:"total"`
+	Retention float64          `json:"retention"` // 记忆保留率，非新卡复习中没有选择“重来”的比例，没有复习时为 0
+}
+
+// DayCount 描述了某一天的计数，比如复习次数或者到期的闪卡数。
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// GetReviewHeatmap 获取最近 days 天每天的复习次数，用于绘制复习热力图。
+func GetReviewHeatmap(deckID string, days int) (ret []*DayCount) {
+	ret = []*DayCount{}
+	for _, day := range GetReviewStat(deckID, days).Days {
+		ret = append(ret, &DayCount{Date: day.Date, Count: day.Count})
+	}
+	return
+}
+
+// GetReviewStat 获取最近 days 天的复习统计，deckID 为空时统计所有卡包。
+func GetReviewStat(deckID string, days int) (ret *ReviewStat) {
+	if 1 > days {
+		days = 30
+	}
+
+	now := time.Now()
+	from := beginningOfDay(now).AddDate(0, 0, -(days - 1))
+	ret = &ReviewStat{Days: make([]*ReviewDayStat, days)}
+	dayIndex := map[string]*ReviewDayStat{}
+	for i := 0; i < days; i++ {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		ret.Days[i] = &ReviewDayStat{Date: date}
+		dayIndex[date] = ret.Days[i]
+	}
+
+	oldCount, retained := 0, 0
+	for _, log := range loadReviewLogs(from) {
+		if "" != deckID && log.DeckID != deckID {
+			continue
+		}
+
+		day := dayIndex[time.UnixMilli(log.Reviewed).Format("2006-01-02")]
+		if nil == day {
+			continue
+		}
+
+		day.Count++
+		ret.Total++
+		switch log.State {
+		case riff.New:
+			day.New++
+		case riff.Learning:
+			day.Learning++
+		case riff.Review:
+			day.Review++
+		case riff.Relearning:
+			day.Relearning++
+		}
+		switch log.Rating {
+		case riff.Again:
+			day.Again++
+		case riff.Hard:
+			day.Hard++
+		case riff.Good:
+			day.Good++
+		case riff.Easy:
+			day.Easy++
+		}
+
+		if riff.New != log.State {
+			oldCount++
+			if riff.Again != log.Rating {
+				retained++
+			}
+		}
+	}
+	if 0 < oldCount {
+		ret.Retention = float64(retained) / float64(oldCount)
+	}
+	return
+}
+
+// GetDueForecast 预测未来 days 天每天到期的闪卡数，已经过期的闪卡计入今天，新卡和暂停的闪卡不计入。
+func GetDueForecast(deckID string, days int) (ret []*DayCount, newCount int) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	if 1 > days {
+		days = 30
+	}
+
+	today := beginningOfDay(time.Now())
+	ret = make([]*DayCount, days)
+	for i := 0; i < days; i++ {
+		ret[i] = &DayCount{Date: today.AddDate(0, 0, i).Format("2006-01-02")}
+	}
+
+	for _, deck := range Decks {
+		if "" != deckID && deck.ID != deckID {
+			continue
+		}
+
+		for _, card := range deck.GetCardsByBlockIDs(deck.GetBlockIDs()) {
+			if isFlashcardSuspended(card.ID()) || nil == treenode.GetBlockTree(card.BlockID()) {
+				continue
+			}
+			if riff.New == card.GetState() {
+				newCount++
+				continue
+			}
+
+			due := card.(*riff.FSRSCard).C.Due
+			i := int(math.Round(beginningOfDay(due).Sub(today).Hours() / 24))
+			if 0 > i {
+				i = 0
+			}
+			if i < days {
+				ret[i].Count++
+			}
+		}
+	}
+	return
+}
+
+func beginningOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -42,6 +42,9 @@ type GraphNode struct {
 	Type  string  `json:"type"`
 	Refs  int     `json:"refs"`
 	Defs  int     `json:"defs"`
+
+	Degree    int `json:"degree"`    // 去重后的相邻节点数
+	Community int `json:"community"` // 社区编号，0 为最大的社区
 }
 
 type GraphLink struct {
@@ -160,22 +163,30 @@ func BuildTreeGraph(id, query string) (boxID string, nodes []*GraphNode, links [
 	}
 	markLinkedNodes(&nodes, &links, true)
 	nodes = removeDuplicatedUnescape(nodes)
+	annotateGraph(nodes, links)
 	return
 }
 
-func BuildGraph(query string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
+func BuildGraph(query string, filter *GraphFilter) (boxID string, nodes []*GraphNode, links []*GraphLink) {
 	nodes = []*GraphNode{}
 	links = []*GraphLink{}
+	if nil == filter {
+		filter = &GraphFilter{}
+	}
 
 	stmt := query2Stmt(query)
 	stmt = strings.TrimPrefix(stmt, "select * from blocks where")
-	stmt += graphTypeFilter(false)
+	if 0 < len(filter.Types) {
+		stmt += graphTypesFilter(filter.Types)
+	} else {
+		stmt += graphTypeFilter(false)
+	}
 	stmt += graphDailyNoteFilter(false)
 	stmt = strings.ReplaceAll(stmt, "content", "ref.content")
 	forwardlinks, backlinks := buildFullLinks(stmt)
 
 	var blocks []*Block
-	roots := sql.GetAllRootBlocks()
+	roots := filterGraphRoots(sql.GetAllRootBlocks(), filter)
 	if 0 < len(roots) {
 		boxID = roots[0].Box
 	}
@@ -210,8 +221,20 @@ func BuildGraph(query string) (boxID string, nodes []*GraphNode, links []*GraphL
 		linkTagBlocks(&blocks, &nodes, &links, "")
 	}
 	markLinkedNodes(&nodes, &links, false)
-	pruneUnref(&nodes, &links)
+	if filter.isRootFiltered() {
+		// 引用关系会带入过滤范围外的文档，需要再次过滤
+		filteredRootIDs := map[string]bool{}
+		for _, rootID := range rootIDs {
+			filteredRootIDs[rootID] = true
+		}
+		filterGraphNodes(&nodes, &links, filteredRootIDs)
+	}
 	nodes = removeDuplicatedUnescape(nodes)
+	if "" != filter.Focus {
+		expandGraphNeighborhood(&nodes, &links, filter.Focus, filter.Depth)
+	}
+	pruneUnref(&nodes, &links, filter.MinRefs, filter.MaxNodes)
+	annotateGraph(nodes, links)
 	return
 }
 
@@ -426,27 +449,25 @@ func markLinkedNodes(nodes *[]*GraphNode, links *[]*GraphLink, local bool) {
 		nodeSize = Conf.Graph.Global.NodeSize
 	}
 
+	// 大型工作空间中节点和链接数量很多，使用索引避免逐个遍历节点
+	nodeIndex := map[string]*GraphNode{}
+	for _, node := range *nodes {
+		if nil == nodeIndex[node.ID] {
+			nodeIndex[node.ID] = node
+		}
+	}
+
 	tmpLinks := (*links)[:0]
 	for _, link := range *links {
-		var sourceFound, targetFound bool
-		for _, node := range *nodes {
-			if link.To == node.ID {
-				if link.Ref {
-					size := nodeSize
-					node.Defs++
-					size = math.Log2(float64(node.Defs))*nodeSize + nodeSize
-					node.Size = size
-				}
-				targetFound = true
-			} else if link.From == node.ID {
-				node.Refs++
-				sourceFound = true
-			}
-			if targetFound && sourceFound {
-				break
-			}
+		target, source := nodeIndex[link.To], nodeIndex[link.From]
+		if nil != target && link.Ref {
+			target.Defs++
+			target.Size = math.Log2(float64(target.Defs))*nodeSize + nodeSize
+		}
+		if nil != source && link.From != link.To {
+			source.Refs++
 		}
-		if sourceFound && targetFound {
+		if nil != source && nil != target && link.From != link.To {
 			tmpLinks = append(tmpLinks, link)
 		}
 	}
@@ -466,19 +487,24 @@ func removeDuplicatedUnescape(nodes []*GraphNode) (ret []*GraphNode) {
 	return ret
 }
 
-func pruneUnref(nodes *[]*GraphNode, links *[]*GraphLink) {
-	maxBlocks := Conf.Graph.MaxBlocks
+func pruneUnref(nodes *[]*GraphNode, links *[]*GraphLink, minRefs, maxBlocks int) {
+	if 1 > minRefs {
+		minRefs = Conf.Graph.Global.MinRefs
+	}
+	if 1 > maxBlocks {
+		maxBlocks = Conf.Graph.MaxBlocks
+	}
 	tmpNodes := (*nodes)[:0]
 	for _, node := range *nodes {
-		if 0 == Conf.Graph.Global.MinRefs {
+		if 0 == minRefs {
 			tmpNodes = append(tmpNodes, node)
 		} else {
-			if Conf.Graph.Global.MinRefs <= node.Refs {
+			if minRefs <= node.Refs {
 				tmpNodes = append(tmpNodes, node)
 				continue
 			}
 
-			if Conf.Graph.Global.MinRefs <= node.Defs {
+			if minRefs <= node.Defs {
 				tmpNodes = append(tmpNodes, node)
 				continue
 			}
@@ -490,22 +516,7 @@ func pruneUnref(nodes *[]*GraphNode, links *[]*GraphLink) {
 		}
 	}
 	*nodes = tmpNodes
-
-	tmpLinks := (*links)[:0]
-	for _, link := range *links {
-		var sourceFound, targetFound bool
-		for _, node := range *nodes {
-			if link.To == node.ID {
-				targetFound = true
-			} else if link.From == node.ID {
-				sourceFound = true
-			}
-		}
-		if sourceFound && targetFound {
-			tmpLinks = append(tmpLinks, link)
-		}
-	}
-	*links = tmpLinks
+	pruneDanglingLinks(nodes, links)
 }
 
 func nodeContentByBlock(block *Block) (ret string) {
@@ -0,0 +1,145 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// BlockGraphNode 描述了块级关系图中的节点。
+type BlockGraphNode struct {
+	ID         string       `json:"id"`
+	RootID     string       `json:"rootID"`
+	Box        string       `json:"box"`
+	Type       string       `json:"type"`
+	SubType    string       `json:"subType"`
+	Content    string       `json:"content"`
+	Hop        int          `json:"hop"`        // 距离中心块的跳数
+	Breadcrumb []*BlockPath `json:"breadcrumb"` // 所在文档和标题
+}
+
+// BlockGraphLink 描述了块级关系图中的引用关系，From 为引用块，To 为定义块。
+type BlockGraphLink struct {
+	ID        string `json:"id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Type      string `json:"type"`      // ref 或者 embed
+	Direction string `json:"direction"` // 展开时经过的方向：forward 为从引用块到定义块，backward 为从定义块到引用块
+	Text      string `json:"text"`      // 引用锚文本
+}
+
+const (
+	blockGraphMaxDepth    = 4
+	blockGraphDefaultSize = 256
+)
+
+// BuildBlockGraph 返回块 id 周围 depth 跳以内通过引用或者嵌入关联的块。
+func BuildBlockGraph(id string, depth, maxNodes int) (nodes []*BlockGraphNode, links []*BlockGraphLink, err error) {
+	nodes = []*BlockGraphNode{}
+	links = []*BlockGraphLink{}
+	if nil == treenode.GetBlockTree(id) {
+		err = ErrBlockNotFound
+		return
+	}
+	if 1 > depth {
+		depth = 1
+	} else if blockGraphMaxDepth < depth {
+		depth = blockGraphMaxDepth
+	}
+	if 1 > maxNodes {
+		maxNodes = blockGraphDefaultSize
+	}
+
+	hops := map[string]int{id: 0}
+	ids := []string{id}
+	linkIDs := map[string]bool{}
+	frontier := []string{id}
+	for hop := 1; hop <= depth && 0 < len(frontier) && len(ids) < maxNodes; hop++ {
+		inFrontier := map[string]bool{}
+		for _, frontierID := range frontier {
+			inFrontier[frontierID] = true
+		}
+
+		var next []string
+		for _, ref := range sql.QueryRefsByBlockIDs(frontier) {
+			if "" == ref.DefBlockID || ref.BlockID == ref.DefBlockID || linkIDs[ref.ID] {
+				continue
+			}
+
+			neighbor, direction := ref.BlockID, "backward"
+			if inFrontier[ref.BlockID] {
+				neighbor, direction = ref.DefBlockID, "forward"
+			}
+			if _, ok := hops[neighbor]; !ok {
+				if maxNodes <= len(ids) {
+					continue
+				}
+				hops[neighbor] = hop
+				ids = append(ids, neighbor)
+				next = append(next, neighbor)
+			}
+
+			typ := "ref"
+			if "query_embed" == ref.Type {
+				typ = "embed"
+			}
+			linkIDs[ref.ID] = true
+			links = append(links, &BlockGraphLink{ID: ref.ID, From: ref.BlockID, To: ref.DefBlockID, Type: typ, Direction: direction, Text: ref.Content})
+		}
+		frontier = next
+	}
+
+	trees := map[string]*parse.Tree{}
+	for _, b := range sql.GetBlocks(ids) {
+		if nil == b {
+			continue
+		}
+
+		node := &BlockGraphNode{ID: b.ID, RootID: b.RootID, Box: b.Box, Type: treenode.FromAbbrType(b.Type), SubType: b.SubType, Content: b.Content, Hop: hops[b.ID]}
+		tree := trees[b.RootID]
+		if nil == tree {
+			tree, _ = LoadTreeByBlockID(b.RootID)
+			trees[b.RootID] = tree
+		}
+		if nil != tree {
+			node.Breadcrumb = blockGraphBreadcrumb(treenode.GetNodeInTree(tree, b.ID))
+		}
+		nodes = append(nodes, node)
+	}
+	return
+}
+
+// blockGraphBreadcrumb 返回块所在的文档和标题，不包括块本身。
+func blockGraphBreadcrumb(node *ast.Node) (ret []*BlockPath) {
+	ret = []*BlockPath{}
+	if nil == node {
+		return
+	}
+
+	for _, p := range buildBlockBreadcrumb(node, nil) {
+		if p.ID == node.ID {
+			continue
+		}
+		if ast.NodeDocument.String() == p.Type || ast.NodeHeading.String() == p.Type {
+			ret = append(ret, p)
+		}
+	}
+	return
+}
@@ -0,0 +1,256 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+// GraphFilter 描述了全局关系图的服务端过滤条件，为空的条件不生效。
+type GraphFilter struct {
+	Boxes       []string `json:"boxes"`       // 笔记本 ID
+	Tags        []string `json:"tags"`        // 文档标签或者文档中包含的标签，包含子标签
+	CreatedFrom string   `json:"createdFrom"` // 文档创建时间范围，格式为 20060102150405，可以只写前缀
+	CreatedTo   string   `json:"createdTo"`
+	UpdatedFrom string   `json:"updatedFrom"` // 文档更新时间范围
+	UpdatedTo   string   `json:"updatedTo"`
+	MinRefs     int      `json:"minRefs"`  // 大于 0 时覆盖全局关系图配置中的最少引用数
+	Types       []string `json:"types"`    // 块类型缩写，例如 p、h、l，不为空时覆盖全局关系图配置中的类型过滤
+	Focus       string   `json:"focus"`    // 焦点节点 ID，不为空时只返回焦点节点的邻域
+	Depth       int      `json:"depth"`    // 邻域展开的跳数，默认为 1
+	MaxNodes    int      `json:"maxNodes"` // 大于 0 时覆盖全局关系图配置中的最大节点数
+}
+
+func (filter *GraphFilter) isRootFiltered() bool {
+	return 0 < len(filter.Boxes) || 0 < len(filter.Tags) ||
+		"" != filter.CreatedFrom || "" != filter.CreatedTo || "" != filter.UpdatedFrom || "" != filter.UpdatedTo
+}
+
+// filterGraphRoots 按照笔记本、标签和时间范围过滤文档。
+func filterGraphRoots(roots []*sql.Block, filter *GraphFilter) (ret []*sql.Block) {
+	if !filter.isRootFiltered() {
+		return roots
+	}
+
+	var tagRootIDs map[string]bool
+	if 0 < len(filter.Tags) {
+		tagRootIDs = map[string]bool{}
+		for _, span := range sql.QueryTagSpans("") {
+			if graphTagMatched(span.Content, filter.Tags) {
+				tagRootIDs[span.RootID] = true
+			}
+		}
+	}
+
+	for _, root := range roots {
+		if 0 < len(filter.Boxes) && !gulu.Str.Contains(root.Box, filter.Boxes) {
+			continue
+		}
+		if !graphTimeInRange(root.Created, filter.CreatedFrom, filter.CreatedTo) || !graphTimeInRange(root.Updated, filter.UpdatedFrom, filter.UpdatedTo) {
+			continue
+		}
+		if nil != tagRootIDs && !tagRootIDs[root.ID] {
+			matched := false
+			for _, tag := range strings.Split(root.Tag, " ") {
+				if tag = strings.Trim(tag, "#"); "" != tag && graphTagMatched(tag, filter.Tags) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		ret = append(ret, root)
+	}
+	return
+}
+
+// filterGraphNodes 移除不在过滤后文档中的块节点，标签节点保留到 pruneUnref 时处理。
+func filterGraphNodes(nodes *[]*GraphNode, links *[]*GraphLink, rootIDs map[string]bool) {
+	tmpNodes := (*nodes)[:0]
+	for _, node := range *nodes {
+		if "" == node.Path || rootIDs[strings.TrimSuffix(path.Base(node.Path), ".sy")] {
+			tmpNodes = append(tmpNodes, node)
+		}
+	}
+	*nodes = tmpNodes
+	pruneDanglingLinks(nodes, links)
+}
+
+// expandGraphNeighborhood 从焦点节点开始按照链接（不区分方向）展开 depth 跳，只保留展开到的节点。
+func expandGraphNeighborhood(nodes *[]*GraphNode, links *[]*GraphLink, focus string, depth int) {
+	if 1 > depth {
+		depth = 1
+	}
+
+	adjacency := graphAdjacency(*links)
+	visited := map[string]bool{focus: true}
+	frontier := []string{focus}
+	for i := 0; i < depth && 0 < len(frontier); i++ {
+		var next []string
+		for _, id := range frontier {
+			for _, neighbor := range adjacency[id] {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	tmpNodes := (*nodes)[:0]
+	for _, node := range *nodes {
+		if visited[node.ID] {
+			tmpNodes = append(tmpNodes, node)
+		}
+	}
+	*nodes = tmpNodes
+	pruneDanglingLinks(nodes, links)
+}
+
+// annotateGraph 计算节点的度数，并使用标签传播算法划分社区，社区编号按照社区大小降序排列。
+func annotateGraph(nodes []*GraphNode, links []*GraphLink) {
+	adjacency := graphAdjacency(links)
+	ids := make([]string, 0, len(nodes))
+	labels := map[string]string{}
+	for _, node := range nodes {
+		node.Degree = len(adjacency[node.ID])
+		ids = append(ids, node.ID)
+		labels[node.ID] = node.ID
+	}
+	sort.Strings(ids)
+
+	for i := 0; i < 16; i++ {
+		changed := false
+		for _, id := range ids {
+			counts := map[string]int{}
+			for _, neighbor := range adjacency[id] {
+				if label, ok := labels[neighbor]; ok {
+					counts[label]++
+				}
+			}
+
+			best, bestCount := labels[id], counts[labels[id]]
+			for label, count := range counts {
+				if count > bestCount || (count == bestCount && label < best) {
+					best, bestCount = label, count
+				}
+			}
+			if best != labels[id] {
+				labels[id] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	sizes := map[string]int{}
+	for _, label := range labels {
+		sizes[label]++
+	}
+	var communities []string
+	for label := range sizes {
+		communities = append(communities, label)
+	}
+	sort.Slice(communities, func(i, j int) bool {
+		if sizes[communities[i]] != sizes[communities[j]] {
+			return sizes[communities[i]] > sizes[communities[j]]
+		}
+		return communities[i] < communities[j]
+	})
+	indexes := map[string]int{}
+	for i, label := range communities {
+		indexes[label] = i
+	}
+	for _, node := range nodes {
+		node.Community = indexes[labels[node.ID]]
+	}
+}
+
+func graphAdjacency(links []*GraphLink) (ret map[string][]string) {
+	ret = map[string][]string{}
+	seen := map[string]bool{}
+	for _, link := range links {
+		if link.From == link.To || "" == link.From || "" == link.To {
+			continue
+		}
+		key := link.From + "\n" + link.To
+		if link.To < link.From {
+			key = link.To + "\n" + link.From
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ret[link.From] = append(ret[link.From], link.To)
+		ret[link.To] = append(ret[link.To], link.From)
+	}
+	return
+}
+
+func pruneDanglingLinks(nodes *[]*GraphNode, links *[]*GraphLink) {
+	ids := map[string]bool{}
+	for _, node := range *nodes {
+		ids[node.ID] = true
+	}
+	tmpLinks := (*links)[:0]
+	for _, link := range *links {
+		if ids[link.From] && ids[link.To] {
+			tmpLinks = append(tmpLinks, link)
+		}
+	}
+	*links = tmpLinks
+}
+
+func graphTypesFilter(types []string) string {
+	inList := []string{"'d'"}
+	for _, typ := range types {
+		switch typ {
+		case "p", "h", "m", "c", "t", "l", "i", "b", "s":
+			inList = append(inList, "'"+typ+"'")
+		}
+	}
+	return " AND ref.type IN (" + strings.Join(inList, ",") + ")"
+}
+
+func graphTagMatched(tag string, tags []string) bool {
+	for _, t := range tags {
+		if tag == t || strings.HasPrefix(tag, t+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func graphTimeInRange(t, from, to string) bool {
+	if "" != from && t < from {
+		return false
+	}
+	if "" != to && t[:min(len(t), len(to))] > to {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"math"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// DocMetrics 描述了文档在引用关系图中的指标。
+type DocMetrics struct {
+	ID          string  `json:"id"`
+	Box         string  `json:"box"`
+	Path        string  `json:"path"`
+	HPath       string  `json:"hPath"`
+	Title       string  `json:"title"`
+	InDegree    int     `json:"inDegree"`    // 引用该文档的文档数
+	OutDegree   int     `json:"outDegree"`   // 该文档引用的文档数
+	PageRank    float64 `json:"pageRank"`    // 中心度，所有文档之和为 1
+	Orphan      bool    `json:"orphan"`      // 没有引用其他文档也没有被其他文档引用
+	Cluster     int     `json:"cluster"`     // 聚类编号，0 为最大的聚类
+	ClusterSize int     `json:"clusterSize"` // 所在聚类的文档数
+}
+
+const (
+	docMetricsPageRankDamping    = 0.85
+	docMetricsPageRankIterations = 64
+	docMetricsPageRankEpsilon    = 1e-9
+	docMetricsMaxDirty           = 4096 // 脏文档超过该数量时全量重新加载
+)
+
+// docMetricsGraph 缓存文档级引用边，引用变化时只重新加载涉及的文档，查询时按需重新计算指标。
+type docMetricsGraph struct {
+	lock    sync.Mutex
+	loaded  bool
+	docs    map[string]*sql.Block
+	outs    map[string]map[string]bool
+	dirty   map[string]bool
+	metrics map[string]*DocMetrics
+}
+
+var docMetricsCache = &docMetricsGraph{dirty: map[string]bool{}}
+
+func init() {
+	eventbus.Subscribe(util.EvtSQLRefsChanged, func(rootIDs []string, all bool) {
+		docMetricsCache.invalidate(rootIDs, all)
+	})
+}
+
+func (g *docMetricsGraph) invalidate(rootIDs []string, all bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.metrics = nil
+	if !g.loaded {
+		return
+	}
+	if all || docMetricsMaxDirty < len(g.dirty)+len(rootIDs) {
+		g.loaded = false
+		g.dirty = map[string]bool{}
+		return
+	}
+	for _, rootID := range rootIDs {
+		g.dirty[rootID] = true
+	}
+}
+
+// GetDocMetrics 返回文档引用关系指标。
+//
+// sortBy 支持 pageRank（默认）、inDegree、outDegree 和 title，orphan 为 true 时仅返回孤立文档，limit 小于 1 时返回全部。
+func GetDocMetrics(boxes []string, orphan bool, sortBy string, limit int) (ret []*DocMetrics, total int) {
+	ret = []*DocMetrics{}
+
+	docMetricsCache.lock.Lock()
+	docMetricsCache.refresh()
+	for _, metrics := range docMetricsCache.metrics {
+		if 0 < len(boxes) && !gulu.Str.Contains(metrics.Box, boxes) {
+			continue
+		}
+		if orphan && !metrics.Orphan {
+			continue
+		}
+		m := *metrics
+		ret = append(ret, &m)
+	}
+	docMetricsCache.lock.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		switch sortBy {
+		case "inDegree":
+			if ret[i].InDegree != ret[j].InDegree {
+				return ret[i].InDegree > ret[j].InDegree
+			}
+		case "outDegree":
+			if ret[i].OutDegree != ret[j].OutDegree {
+				return ret[i].OutDegree > ret[j].OutDegree
+			}
+		case "title":
+		default:
+			if ret[i].PageRank != ret[j].PageRank {
+				return ret[i].PageRank > ret[j].PageRank
+			}
+		}
+		if ret[i].HPath != ret[j].HPath {
+			return ret[i].HPath < ret[j].HPath
+		}
+		return ret[i].ID < ret[j].ID
+	})
+
+	total = len(ret)
+	if 0 < limit && limit < len(ret) {
+		ret = ret[:limit]
+	}
+	return
+}
+
+// GetDocMetricsByID 返回指定文档的引用关系指标。
+func GetDocMetricsByID(id string) (ret *DocMetrics) {
+	docMetricsCache.lock.Lock()
+	defer docMetricsCache.lock.Unlock()
+
+	docMetricsCache.refresh()
+	if metrics := docMetricsCache.metrics[id]; nil != metrics {
+		m := *metrics
+		ret = &m
+	}
+	return
+}
+
+func (g *docMetricsGraph) refresh() {
+	if !g.loaded {
+		g.docs = map[string]*sql.Block{}
+		for _, doc := range sql.GetAllRootBlocks() {
+			g.docs[doc.ID] = doc
+		}
+		g.outs = map[string]map[string]bool{}
+		for rootID, defRootIDs := range sql.QueryDocRefEdges(nil) {
+			g.setOuts(rootID, defRootIDs)
+		}
+		g.loaded = true
+		g.dirty = map[string]bool{}
+		g.metrics = nil
+	} else if 0 < len(g.dirty) {
+		var rootIDs []string
+		for rootID := range g.dirty {
+			rootIDs = append(rootIDs, rootID)
+			delete(g.docs, rootID)
+			delete(g.outs, rootID)
+		}
+		for _, doc := range sql.GetBlocks(rootIDs) {
+			if nil != doc && doc.ID == doc.RootID {
+				g.docs[doc.ID] = doc
+			}
+		}
+		for rootID, defRootIDs := range sql.QueryDocRefEdges(rootIDs) {
+			g.setOuts(rootID, defRootIDs)
+		}
+		g.dirty = map[string]bool{}
+		g.metrics = nil
+	}
+
+	if nil == g.metrics {
+		g.compute()
+	}
+}
+
+func (g *docMetricsGraph) setOuts(rootID string, defRootIDs []string) {
+	outs := map[string]bool{}
+	for _, defRootID := range defRootIDs {
+		outs[defRootID] = true
+	}
+	g.outs[rootID] = outs
+}
+
+func (g *docMetricsGraph) compute() {
+	g.metrics = map[string]*DocMetrics{}
+	ids := make([]string, 0, len(g.docs))
+	for id := range g.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// 被删除的文档可能还残留在其他文档的出边中，这里只统计两端都存在的边
+	outs := map[string][]string{}
+	ins := map[string][]string{}
+	var nodes []*GraphNode
+	var links []*GraphLink
+	for _, id := range ids {
+		doc := g.docs[id]
+		g.metrics[id] = &DocMetrics{ID: id, Box: doc.Box, Path: doc.Path, HPath: doc.HPath, Title: path.Base(doc.HPath)}
+		nodes = append(nodes, &GraphNode{ID: id})
+		for defRootID := range g.outs[id] {
+			if nil == g.docs[defRootID] {
+				continue
+			}
+			outs[id] = append(outs[id], defRootID)
+			ins[defRootID] = append(ins[defRootID], id)
+			links = append(links, &GraphLink{From: id, To: defRootID})
+		}
+	}
+
+	annotateGraph(nodes, links)
+	clusterSizes := map[int]int{}
+	for _, node := range nodes {
+		clusterSizes[node.Community]++
+	}
+	for _, node := range nodes {
+		metrics := g.metrics[node.ID]
+		metrics.InDegree = len(ins[node.ID])
+		metrics.OutDegree = len(outs[node.ID])
+		metrics.Orphan = 0 == metrics.InDegree && 0 == metrics.OutDegree
+		metrics.Cluster = node.Community
+		metrics.ClusterSize = clusterSizes[node.Community]
+	}
+
+	for id, rank := range docPageRank(ids, outs, ins) {
+		g.metrics[id].PageRank = rank
+	}
+}
+
+// docPageRank 使用幂迭代计算 PageRank，没有出边的文档将其权重平均分配给所有文档。
+func docPageRank(ids []string, outs, ins map[string][]string) (ret map[string]float64) {
+	ret = map[string]float64{}
+	n := float64(len(ids))
+	if 1 > n {
+		return
+	}
+
+	for _, id := range ids {
+		ret[id] = 1 / n
+	}
+	for i := 0; i < docMetricsPageRankIterations; i++ {
+		dangling := 0.0
+		for _, id := range ids {
+			if 1 > len(outs[id]) {
+				dangling += ret[id]
+			}
+		}
+
+		next := map[string]float64{}
+		delta := 0.0
+		for _, id := range ids {
+			rank := (1-docMetricsPageRankDamping)/n + docMetricsPageRankDamping*dangling/n
+			for _, from := range ins[id] {
+				rank += docMetricsPageRankDamping * ret[from] / float64(len(outs[from]))
+			}
+			next[id] = rank
+			delta += math.Abs(rank - ret[id])
+		}
+		ret = next
+		if delta < docMetricsPageRankEpsilon {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,163 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 以下为命令行模式使用的操作，执行完成后才返回，失败时返回错误而不是推送消息。
+
+// HeadlessExport 将文档或笔记本导出到 out，format 可选 md、sy 和 docx，返回导出文件的路径。
+//
+// id 为笔记本 ID 时导出整个笔记本；out 为已存在的目录时导出文件保存在该目录下。
+func HeadlessExport(id, format, out string) (ret string, err error) {
+	if "" == out {
+		err = errors.New("output path is empty")
+		return
+	}
+
+	isBox := nil != Conf.Box(id)
+	if !isBox && nil == treenode.GetBlockTree(id) {
+		err = fmt.Errorf(Conf.Language(15), id)
+		return
+	}
+
+	var zipPath string
+	switch format {
+	case "md":
+		if isBox {
+			zipPath = BatchExportMarkdown(id, "/")
+		} else {
+			_, zipPath = ExportPandocConvertZip(id, "", ".md")
+		}
+	case "sy":
+		if isBox {
+			zipPath = ExportNotebookSY(id)
+		} else {
+			_, zipPath = ExportSY(id)
+		}
+	case "docx":
+		if isBox {
+			err = errors.New("exporting a notebook to docx is not supported")
+			return
+		}
+		if err = os.MkdirAll(out, 0755); nil != err {
+			return
+		}
+		return ExportDocx(id, out, false, false)
+	default:
+		err = fmt.Errorf("unsupported export format [%s]", format)
+		return
+	}
+	if "" == zipPath {
+		err = fmt.Errorf("export [%s] failed, please check the log [%s] for details", id, util.LogPath)
+		return
+	}
+
+	// 导出的压缩包位于临时目录下，退出时会被清理，需要复制到输出路径
+	name, err := url.PathUnescape(path.Base(zipPath))
+	if nil != err {
+		return
+	}
+	src := filepath.Join(util.TempDir, "export", name)
+	ret = out
+	if gulu.File.IsDir(out) {
+		ret = filepath.Join(out, name)
+	}
+	if err = os.MkdirAll(filepath.Dir(ret), 0755); nil != err {
+		return
+	}
+	if err = filelock.Copy(src, ret); nil != err {
+		return
+	}
+	os.Remove(src)
+	return
+}
+
+// HeadlessSync 按照工作空间的同步配置执行一次数据同步。
+func HeadlessSync() (err error) {
+	var finished *SyncFinishedEvent
+	eventbus.Subscribe(util.EvtSyncFinished, func(evt *SyncFinishedEvent) {
+		finished = evt
+	})
+
+	syncData(false, true)
+	if nil == finished {
+		err = errors.New("sync did not run, please check the sync settings and the network")
+		return
+	}
+	if 1 != finished.Code {
+		err = errors.New(finished.Msg)
+	}
+	return
+}
+
+// HeadlessReindex 重建数据库索引。
+func HeadlessReindex() {
+	FullReindex()
+	task.WaitForQueue()
+	sql.WaitForWritingDatabase()
+}
+
+// HeadlessCheckIndex 校验并修复数据库索引，和启动后同步完成时执行的校验相同。
+func HeadlessCheckIndex() {
+	removeDuplicateDatabaseIndex()
+	sql.WaitForWritingDatabase()
+
+	resetDuplicateBlocksOnFileSys()
+
+	fixBlockTreeByFileSys()
+	sql.WaitForWritingDatabase()
+
+	fixDatabaseIndexByBlockTree()
+	sql.WaitForWritingDatabase()
+
+	removeDuplicateDatabaseRefs()
+	task.WaitForQueue()
+	sql.WaitForWritingDatabase()
+}
+
+// HeadlessClose 保存数据并释放工作空间，不执行退出前的数据同步。
+func HeadlessClose() {
+	task.WaitForQueue()
+	WaitForWritingFiles()
+	filesys.FlushTreeSaves()
+	sql.WaitForWritingDatabase()
+
+	Conf.Close()
+	sql.CloseDatabase()
+	treenode.SaveBlockTree(false)
+	treenode.CloseBlockTree()
+	util.SaveAssetsTexts()
+	clearWorkspaceTemp()
+	util.UnlockWorkspace()
+}
@@ -49,7 +49,7 @@ import (
 var historyTicker = time.NewTicker(time.Minute * 10)
 
 func AutoGenerateFileHistory() {
-	ChangeHistoryTick(Conf.Editor.GenerateHistoryInterval)
+	ResetHistoryTick()
 	for {
 		<-historyTicker.C
 		task.AppendTask(task.HistoryGenerateFile, generateFileHistory)
@@ -59,19 +59,25 @@ func AutoGenerateFileHistory() {
 func generateFileHistory() {
 	defer logging.Recover()
 
-	if 1 > Conf.Editor.GenerateHistoryInterval {
+	policy := getHistoryPolicy()
+	if 1 > policy.minInterval() {
 		return
 	}
 
 	WaitForWritingFiles()
 
-	// 生成文档历史
+	// 生成文档历史，笔记本可以单独设置生成间隔
 	for _, box := range Conf.GetOpenedBoxes() {
+		if !isHistoryDue(boxLatestHistoryTime[box.ID], policy.intervalOf(box.ID)) {
+			continue
+		}
 		box.generateDocHistory0()
 	}
 
 	// 生成资源文件历史
-	generateAssetsHistory()
+	if isHistoryDue(time.Unix(assetsLatestHistoryTime, 0), policy.interval) {
+		generateAssetsHistory()
+	}
 
 	historyDir := util.HistoryDir
 	clearOutdatedHistoryDir(historyDir)
@@ -152,7 +158,7 @@ func GetDocHistoryContent(historyPath, keyword string) (id, rootID, content stri
 		return
 	}
 
-	data, err := filelock.ReadFile(historyPath)
+	data, err := readHistoryFile(historyPath)
 	if nil != err {
 		logging.LogErrorf("read file [%s] failed: %s", historyPath, err)
 		return
@@ -244,11 +250,15 @@ func RollbackDocHistory(boxID, historyPath string) (err error) {
 		return
 	}
 
-	if err = filelock.CopyNewtimes(srcPath, destPath); nil != err {
+	data, err := readHistoryFile(srcPath)
+	if nil != err {
+		return
+	}
+	if err = filelock.WriteFile(destPath, data); nil != err {
 		return
 	}
 
-	tree, _ := loadTree(srcPath, util.NewLute())
+	tree, _ := loadHistoryTree(srcPath, util.NewLute())
 	if nil != tree {
 		historyDir := strings.TrimPrefix(historyPath, util.HistoryDir+string(os.PathSeparator))
 		if strings.Contains(historyDir, string(os.PathSeparator)) {
@@ -325,6 +335,7 @@ func RollbackNotebookHistory(historyPath string) (err error) {
 		logging.LogErrorf("copy file [%s] to [%s] failed: %s", from, to, err)
 		return
 	}
+	decompressHistoryDir(to)
 
 	FullReindex()
 	IncSync()
@@ -345,7 +356,14 @@ type HistoryItem struct {
 
 const fileHistoryPageSize = 32
 
-func FullTextSearchHistory(query, box, op string, typ, page int) (ret []string, pageCount, totalCount int) {
+// HistorySearchFilter 描述了历史搜索的过滤条件。
+type HistorySearchFilter struct {
+	PathPrefix string // 笔记本内的路径前缀，比如 /20240101000000-abcdefg 或者 /assets/
+	From       int64  // 起始时间（Unix 时间戳，单位秒），0 表示不限制
+	To         int64  // 截止时间（Unix 时间戳，单位秒），0 表示不限制
+}
+
+func FullTextSearchHistory(query, box, op string, typ, page int, filter *HistorySearchFilter) (ret []string, pageCount, totalCount int) {
 	query = gulu.Str.RemoveInvisible(query)
 	if "" != query && HistoryTypeDocID != typ {
 		query = stringQuery(query)
@@ -353,10 +371,9 @@ func FullTextSearchHistory(query, box, op string, typ, page int) (ret []string,
 
 	offset := (page - 1) * fileHistoryPageSize
 
-	table := "histories_fts_case_insensitive"
-	stmt := "SELECT DISTINCT created FROM " + table + " WHERE "
-	stmt += buildSearchHistoryQueryFilter(query, op, box, table, typ)
-	countStmt := strings.ReplaceAll(stmt, "SELECT DISTINCT created", "SELECT COUNT(DISTINCT created) AS total")
+	table, where := buildSearchHistoryQueryFilter(query, op, box, typ, filter)
+	stmt := "SELECT DISTINCT created FROM " + table + " WHERE " + where
+	countStmt := "SELECT COUNT(DISTINCT created) AS total FROM " + table + " WHERE " + where
 	stmt += " ORDER BY created DESC LIMIT " + strconv.Itoa(fileHistoryPageSize) + " OFFSET " + strconv.Itoa(offset)
 	result, err := sql.QueryHistory(stmt)
 	if nil != err {
@@ -380,53 +397,106 @@ func FullTextSearchHistory(query, box, op string, typ, page int) (ret []string,
 	return
 }
 
-func FullTextSearchHistoryItems(created, query, box, op string, typ int) (ret []*HistoryItem) {
+func FullTextSearchHistoryItems(created, query, box, op string, typ int, filter *HistorySearchFilter) (ret []*HistoryItem) {
 	query = gulu.Str.RemoveInvisible(query)
 	if "" != query && HistoryTypeDocID != typ {
 		query = stringQuery(query)
 	}
 
-	table := "histories_fts_case_insensitive"
-	stmt := "SELECT * FROM " + table + " WHERE "
-	stmt += buildSearchHistoryQueryFilter(query, op, box, table, typ)
-	stmt += " AND created = '" + created + "' ORDER BY created DESC LIMIT " + fmt.Sprintf("%d", fileHistoryPageSize)
+	table, where := buildSearchHistoryQueryFilter(query, op, box, typ, filter)
+	columns := "*"
+	if "histories" == table {
+		columns = "id, type, op, title, '' AS content, path, created"
+	}
+	stmt := "SELECT " + columns + " FROM " + table + " WHERE " + where
+	stmt += " AND created = '" + escapeHistorySQLStr(created) + "' ORDER BY created DESC LIMIT " + fmt.Sprintf("%d", fileHistoryPageSize)
 	sqlHistories := sql.SelectHistoriesRawStmt(stmt)
 	ret = fromSQLHistories(sqlHistories)
 	return
 }
 
-func buildSearchHistoryQueryFilter(query, op, box, table string, typ int) (stmt string) {
-	if "" != query {
-		switch typ {
-		case HistoryTypeDocName:
-			stmt += table + " MATCH '{title}:(" + query + ")'"
-		case HistoryTypeDoc:
-			stmt += table + " MATCH '{title content}:(" + query + ")'"
-		case HistoryTypeDocID:
-			stmt += " id = '" + query + "'"
-		case HistoryTypeAsset:
-			stmt += table + " MATCH '{title content}:(" + query + ")'"
+// buildSearchHistoryQueryFilter 构建历史搜索的查询表和过滤条件。
+//
+// 操作类型、笔记本、路径和时间这些过滤条件通过带索引的 histories 表完成，只有存在关键字时才需要使用全文索引表。
+func buildSearchHistoryQueryFilter(query, op, box string, typ int, filter *HistorySearchFilter) (table, stmt string) {
+	meta := buildSearchHistoryMetaFilter(op, box, typ, filter)
+	if "" == query || HistoryTypeDocID == typ {
+		table = "histories"
+		stmt = meta
+		if "" != query {
+			stmt += " AND id = '" + escapeHistorySQLStr(query) + "'"
 		}
-	} else {
-		stmt += "1=1"
+		return
+	}
+
+	table = "histories_fts_case_insensitive"
+	switch typ {
+	case HistoryTypeDocName:
+		stmt += table + " MATCH '{title}:(" + query + ")'"
+	case HistoryTypeDoc, HistoryTypeAsset:
+		stmt += table + " MATCH '{title content}:(" + query + ")'"
 	}
-	if "all" != op {
-		stmt += " AND op = '" + op + "'"
+	stmt += " AND path IN (SELECT path FROM histories WHERE " + meta + ")"
+	return
+}
+
+func buildSearchHistoryMetaFilter(op, box string, typ int, filter *HistorySearchFilter) (stmt string) {
+	ago := time.Now().Add(-24 * time.Hour * time.Duration(getHistoryPolicy().maxRetentionDays()))
+	stmt = "created > '" + fmt.Sprintf("%d", ago.Unix()) + "'"
+
+	if "all" != op && "" != op {
+		stmt += " AND op = '" + escapeHistorySQLStr(op) + "'"
 	}
 
-	if HistoryTypeDocName == typ || HistoryTypeDoc == typ || HistoryTypeDocID == typ {
-		if HistoryTypeDocName == typ || HistoryTypeDoc == typ {
-			stmt += " AND path LIKE '%/" + box + "/%' AND path LIKE '%.sy'"
+	switch typ {
+	case HistoryTypeDocName, HistoryTypeDoc:
+		stmt += " AND type = " + strconv.Itoa(HistoryTypeDoc)
+		if "" != box {
+			stmt += " AND box = '" + escapeHistorySQLStr(box) + "'"
 		}
-	} else if HistoryTypeAsset == typ {
-		stmt += " AND path LIKE '%/assets/%'"
+	case HistoryTypeDocID:
+		stmt += " AND type = " + strconv.Itoa(HistoryTypeDoc)
+	case HistoryTypeAsset:
+		stmt += " AND type = " + strconv.Itoa(HistoryTypeAsset)
 	}
 
-	ago := time.Now().Add(-24 * time.Hour * time.Duration(Conf.Editor.HistoryRetentionDays))
-	stmt += " AND created > '" + fmt.Sprintf("%d", ago.Unix()) + "'"
+	if nil == filter {
+		return
+	}
+	if pathPrefix := strings.TrimSpace(filter.PathPrefix); "" != pathPrefix {
+		if !strings.HasPrefix(pathPrefix, "/") {
+			pathPrefix = "/" + pathPrefix
+		}
+		stmt += " AND doc_path GLOB '" + escapeHistorySQLStr(escapeHistoryGlob(pathPrefix)) + "*'"
+	}
+	if 0 < filter.From {
+		stmt += " AND created >= '" + strconv.FormatInt(filter.From, 10) + "'"
+	}
+	if 0 < filter.To {
+		stmt += " AND created <= '" + strconv.FormatInt(filter.To, 10) + "'"
+	}
 	return
 }
 
+func escapeHistorySQLStr(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+func escapeHistoryGlob(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[':
+			buf.WriteRune('[')
+			buf.WriteRune(r)
+			buf.WriteRune(']')
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
 func GetNotebookHistory() (ret []*History, err error) {
 	ret = []*History{}
 
@@ -522,15 +592,28 @@ func (box *Box) generateDocHistory0() {
 	}
 
 	luteEngine := util.NewLute()
+	generated := 0
 	for _, file := range files {
 		historyPath := filepath.Join(historyDir, box.ID, strings.TrimPrefix(file, filepath.Join(util.DataDir, box.ID)))
-		if err = os.MkdirAll(filepath.Dir(historyPath), 0755); nil != err {
+
+		var data []byte
+		if data, err = filesys.ReadSyFile(file); err != nil {
 			logging.LogErrorf("generate history failed: %s", err)
 			return
 		}
 
-		var data []byte
-		if data, err = filelock.ReadFile(file); err != nil {
+		var tree *parse.Tree
+		if strings.HasSuffix(file, ".sy") {
+			var parseErr error
+			if tree, parseErr = filesys.ParseJSONWithoutFix(data, luteEngine.ParseOptions); nil != parseErr {
+				logging.LogErrorf("parse tree [%s] failed: %s", file, parseErr)
+			} else if docHistoryHash(tree, luteEngine) == sql.GetLatestHistoryHash(tree.Root.ID) {
+				// 内容和最近一次历史相同时不再生成历史
+				continue
+			}
+		}
+
+		if err = os.MkdirAll(filepath.Dir(historyPath), 0755); nil != err {
 			logging.LogErrorf("generate history failed: %s", err)
 			return
 		}
@@ -539,25 +622,27 @@ func (box *Box) generateDocHistory0() {
 			logging.LogErrorf("generate history failed: %s", err)
 			return
 		}
+		generated++
 
-		if strings.HasSuffix(file, ".sy") {
-			tree, loadErr := loadTree(file, luteEngine)
-			if nil != loadErr {
-				logging.LogErrorf("load tree [%s] failed: %s", file, loadErr)
-			} else {
-				// 关联的属性视图也要复制到历史中 https://github.com/siyuan-note/siyuan/issues/9567
-				avNodes := tree.Root.ChildrenByType(ast.NodeAttributeView)
-				for _, avNode := range avNodes {
-					srcAvPath := filepath.Join(util.DataDir, "storage", "av", avNode.AttributeViewID+".json")
-					destAvPath := filepath.Join(historyDir, "storage", "av", avNode.AttributeViewID+".json")
-					if copyErr := filelock.Copy(srcAvPath, destAvPath); nil != copyErr {
-						logging.LogErrorf("copy av [%s] failed: %s", srcAvPath, copyErr)
-					}
+		if nil != tree {
+			// 关联的属性视图也要复制到历史中 https://github.com/siyuan-note/siyuan/issues/9567
+			avNodes := tree.Root.ChildrenByType(ast.NodeAttributeView)
+			for _, avNode := range avNodes {
+				srcAvPath := filepath.Join(util.DataDir, "storage", "av", avNode.AttributeViewID+".json")
+				destAvPath := filepath.Join(historyDir, "storage", "av", avNode.AttributeViewID+".json")
+				if copyErr := filelock.Copy(srcAvPath, destAvPath); nil != copyErr {
+					logging.LogErrorf("copy av [%s] failed: %s", srcAvPath, copyErr)
 				}
 			}
 		}
 	}
 
+	if 1 > generated {
+		// 同一秒内其他笔记本可能已经写入了该历史目录，所以仅移除空目录
+		os.Remove(historyDir)
+		return
+	}
+
 	indexHistoryDir(filepath.Base(historyDir), util.NewLute())
 	return
 }
@@ -574,8 +659,10 @@ func clearOutdatedHistoryDir(historyDir string) {
 		return
 	}
 
+	// 超过所有保留天数的历史直接删除，其余的按照笔记本的保留天数清理
+	policy := getHistoryPolicy()
 	now := time.Now()
-	ago := now.Add(-24 * time.Hour * time.Duration(Conf.Editor.HistoryRetentionDays)).Unix()
+	maxAgo := now.Add(-24 * time.Hour * time.Duration(policy.maxRetentionDays())).Unix()
 	var removes []string
 	for _, dir := range dirs {
 		dirInfo, err := dir.Info()
@@ -583,8 +670,12 @@ func clearOutdatedHistoryDir(historyDir string) {
 			logging.LogErrorf("read history dir [%s] failed: %s", dir.Name(), err)
 			continue
 		}
-		if dirInfo.ModTime().Unix() < ago {
+		if dirInfo.ModTime().Unix() < maxAgo {
 			removes = append(removes, filepath.Join(historyDir, dir.Name()))
+			continue
+		}
+		if dirInfo.IsDir() && 0 < len(policy.boxRetentionDays) {
+			clearOutdatedHistoryEntries(filepath.Join(historyDir, dir.Name()), dirInfo.ModTime(), policy)
 		}
 	}
 	for _, dir := range removes {
@@ -596,7 +687,14 @@ func clearOutdatedHistoryDir(historyDir string) {
 	}
 
 	// 清理历史库
-	sql.DeleteOutdatedHistories(fmt.Sprintf("%d", ago))
+	var overrideBoxIDs []string
+	for boxID, days := range policy.boxRetentionDays {
+		overrideBoxIDs = append(overrideBoxIDs, boxID)
+		ago := now.Add(-24 * time.Hour * time.Duration(days)).Unix()
+		sql.DeleteOutdatedBoxHistories(boxID, fmt.Sprintf("%d", ago))
+	}
+	ago := now.Add(-24 * time.Hour * time.Duration(policy.retentionDays)).Unix()
+	sql.DeleteOutdatedHistories(fmt.Sprintf("%d", ago), overrideBoxIDs)
 }
 
 var boxLatestHistoryTime = map[string]time.Time{}
@@ -668,7 +766,7 @@ func generateOpTypeHistory(tree *parse.Tree, opType string) {
 	}
 
 	var data []byte
-	if data, err = filelock.ReadFile(filepath.Join(util.DataDir, tree.Box, tree.Path)); err != nil {
+	if data, err = filesys.ReadSyFile(filepath.Join(util.DataDir, tree.Box, tree.Path)); err != nil {
 		logging.LogErrorf("generate history failed: %s", err)
 		return
 	}
@@ -715,7 +813,7 @@ func fullReindexHistory() {
 		}
 
 		name := historyDir.Name()
-		indexHistoryDir(name, lutEngine)
+		indexHistoryDir0(name, lutEngine)
 	}
 	return
 }
@@ -730,6 +828,11 @@ const (
 )
 
 func indexHistoryDir(name string, luteEngine *lute.Lute) {
+	histories := indexHistoryDir0(name, luteEngine)
+	publishHistoryCreated(name, histories)
+}
+
+func indexHistoryDir0(name string, luteEngine *lute.Lute) (histories []*sql.History) {
 	defer logging.Recover()
 
 	op := name[strings.LastIndex(name, "-")+1:]
@@ -756,9 +859,8 @@ func indexHistoryDir(name string, luteEngine *lute.Lute) {
 		return nil
 	})
 
-	var histories []*sql.History
 	for _, doc := range docs {
-		tree, loadErr := loadTree(doc, luteEngine)
+		tree, loadErr := loadHistoryTree(doc, luteEngine)
 		if nil != loadErr {
 			logging.LogErrorf("load tree [%s] failed: %s", doc, loadErr)
 			continue
@@ -779,6 +881,7 @@ func indexHistoryDir(name string, luteEngine *lute.Lute) {
 			Content: content,
 			Path:    p,
 			Created: created,
+			Hash:    docHistoryHash(tree, luteEngine),
 		})
 	}
 
@@ -800,6 +903,10 @@ func indexHistoryDir(name string, luteEngine *lute.Lute) {
 	}
 
 	sql.IndexHistoriesQueue(histories)
+
+	if Conf.Editor.HistoryCompression {
+		compressHistoryFiles(docs, true)
+	}
 	return
 }
 
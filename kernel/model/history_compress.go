@@ -0,0 +1,112 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 数据仓库中的分块已经由 dejavu 使用 zstd 压缩存储，这里只处理历史目录下的文档。
+
+// 历史文档压缩后文件名不变，读取时根据文件头判断是否需要解压。开启 .sy 压缩后从工作空间复制过来的历史文档可能已经是 gzip 压缩的，同样可以透明解压。
+
+var historyEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+func isHistoryCompressed(data []byte) bool {
+	return filesys.IsSyCompressed(data)
+}
+
+// readHistoryFile 读取历史文件，压缩过的文件会被透明解压。
+func readHistoryFile(p string) (ret []byte, err error) {
+	return filesys.ReadSyFile(p)
+}
+
+// compressHistoryFiles 按照设置压缩或者解压历史文档，保留文件的修改时间。
+func compressHistoryFiles(paths []string, compress bool) (count int) {
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if nil != err {
+			continue
+		}
+
+		data, err := filelock.ReadFile(p)
+		if nil != err {
+			logging.LogErrorf("read history [%s] failed: %s", p, err)
+			continue
+		}
+
+		if compress == isHistoryCompressed(data) {
+			continue
+		}
+
+		if compress {
+			data = historyEncoder.EncodeAll(data, make([]byte, 0, len(data)/4))
+		} else if data, err = filesys.DecodeSyData(data); nil != err {
+			logging.LogErrorf("decompress history [%s] failed: %s", p, err)
+			continue
+		}
+
+		if err = filelock.WriteFile(p, data); nil != err {
+			logging.LogErrorf("write history [%s] failed: %s", p, err)
+			continue
+		}
+		os.Chtimes(p, info.ModTime(), info.ModTime())
+		count++
+	}
+	return
+}
+
+// decompressHistoryDir 解压目录下的所有文档，用于将历史目录复制回工作空间后恢复为明文。
+func decompressHistoryDir(dir string) {
+	compressHistoryFiles(listHistoryDocs(dir), false)
+}
+
+func listHistoryDocs(dir string) (ret []string) {
+	filelock.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if nil != err || nil == info {
+			return nil
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".sy") {
+			ret = append(ret, path)
+		}
+		return nil
+	})
+	return
+}
+
+// MigrateHistoryCompression 将已有的历史文档按照当前设置压缩或者解压。
+func MigrateHistoryCompression() {
+	task.AppendTask(task.HistoryCompress, migrateHistoryCompression)
+}
+
+func migrateHistoryCompression() {
+	defer logging.Recover()
+
+	compress := Conf.Editor.HistoryCompression
+	docs := listHistoryDocs(util.HistoryDir)
+	count := compressHistoryFiles(docs, compress)
+	logging.LogInfof("migrated history compression [compress=%v, count=%d/%d]", compress, count, len(docs))
+}
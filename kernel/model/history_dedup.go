@@ -0,0 +1,99 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// docHistoryHash 计算文档内容哈希。
+//
+// 文档根节点的 updated 属性在每次保存时都会变化，计算时需要忽略，这样只保存而没有修改内容时哈希不变。
+func docHistoryHash(tree *parse.Tree, luteEngine *lute.Lute) string {
+	updated := tree.Root.IALAttr("updated")
+	tree.Root.RemoveIALAttr("updated")
+	renderer := render.NewJSONRenderer(tree, luteEngine.RenderOptions)
+	data := renderer.Render()
+	if "" != updated {
+		tree.Root.SetIALAttr("updated", updated)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// CompactHistory 移除内容和上一条历史相同的文档更新历史。
+func CompactHistory() {
+	task.AppendTask(task.HistoryCompact, compactHistory)
+}
+
+func compactHistory() {
+	defer logging.Recover()
+
+	histories := sql.SelectDocHistoryHashes()
+	var removePaths []string
+	timeDirs := map[string]bool{}
+	var prev *sql.History
+	for _, history := range histories {
+		// 仅移除定时生成的更新历史，删除、替换等操作生成的历史需要保留
+		if nil != prev && prev.ID == history.ID && prev.Hash == history.Hash && HistoryOpUpdate == history.Op {
+			absPath := filepath.Join(util.HistoryDir, history.Path)
+			if err := os.RemoveAll(absPath); nil != err {
+				logging.LogErrorf("remove duplicated history [%s] failed: %s", absPath, err)
+				continue
+			}
+			removePaths = append(removePaths, history.Path)
+			timeDirs[strings.Split(history.Path, "/")[0]] = true
+			continue
+		}
+		prev = history
+	}
+
+	if 1 > len(removePaths) {
+		return
+	}
+	sql.DeleteHistoriesByPathsQueue(removePaths)
+
+	// 历史目录中已经没有文档和资源文件时移除整个目录
+	for timeDir := range timeDirs {
+		absTimeDir := filepath.Join(util.HistoryDir, timeDir)
+		if "" == timeDir || !gulu.File.IsDir(absTimeDir) {
+			continue
+		}
+		if docs := listHistoryDocs(absTimeDir); 0 < len(docs) {
+			continue
+		}
+		if assets, _ := filepath.Glob(filepath.Join(absTimeDir, "*", "assets")); 0 < len(assets) || gulu.File.IsDir(filepath.Join(absTimeDir, "assets")) {
+			continue
+		}
+		if err := os.RemoveAll(absTimeDir); nil != err {
+			logging.LogErrorf("remove history dir [%s] failed: %s", absTimeDir, err)
+		}
+	}
+	logging.LogInfof("removed [%d] duplicated histories", len(removePaths))
+}
@@ -0,0 +1,334 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	BlockDiffAdded    = "added"
+	BlockDiffRemoved  = "removed"
+	BlockDiffModified = "modified"
+	BlockDiffMoved    = "moved" // 块位置发生变化，内容也可能发生了变化
+)
+
+const (
+	TextDiffEqual  = "equal"
+	TextDiffInsert = "insert"
+	TextDiffDelete = "delete"
+)
+
+// DocDiff 描述了同一文档两个版本之间的块级差异。
+type DocDiff struct {
+	RootID   string       `json:"rootID"`
+	Left     string       `json:"left"`  // 旧版本的历史文件路径
+	Right    string       `json:"right"` // 新版本的历史文件路径，为空表示当前文档
+	Blocks   []*BlockDiff `json:"blocks"`
+	Added    int          `json:"added"`
+	Removed  int          `json:"removed"`
+	Modified int          `json:"modified"`
+	Moved    int          `json:"moved"`
+}
+
+// BlockDiff 描述了一个块的差异，按新版本中的顺序排列，删除的块排在其原位置。
+type BlockDiff struct {
+	Op           string      `json:"op"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	Old          string      `json:"old"`          // 旧版本的 Markdown
+	New          string      `json:"new"`          // 新版本的 Markdown
+	AttrsChanged bool        `json:"attrsChanged"` // 块属性是否发生变化
+	Texts        []*TextDiff `json:"texts"`        // 字符级文本差异，仅在内容发生变化时提供
+}
+
+type TextDiff struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffDocHistory 比较文档的两个历史版本，rightPath 为空时和当前文档比较。
+func DiffDocHistory(leftPath, rightPath string) (ret *DocDiff, err error) {
+	luteEngine := NewLute()
+	leftTree, err := loadHistoryTree(leftPath, luteEngine)
+	if nil != err {
+		return
+	}
+
+	var rightTree *parse.Tree
+	if "" == rightPath {
+		rightTree, err = LoadTreeByBlockID(leftTree.Root.ID)
+	} else {
+		rightTree, err = loadHistoryTree(rightPath, luteEngine)
+	}
+	if nil != err {
+		return
+	}
+
+	if leftTree.Root.ID != rightTree.Root.ID {
+		err = errors.New("can't diff histories of different documents")
+		return
+	}
+
+	ret = diffTrees(leftTree, rightTree, luteEngine)
+	ret.Left = leftPath
+	ret.Right = rightPath
+	return
+}
+
+func loadHistoryTree(historyPath string, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
+	absPath := filepath.Clean(historyPath)
+	if !util.IsSubPath(util.HistoryDir, absPath) || !strings.HasSuffix(absPath, ".sy") {
+		err = fmt.Errorf("invalid history path [%s]", historyPath)
+		return
+	}
+
+	if !gulu.File.IsExist(absPath) {
+		err = fmt.Errorf("history [%s] not found", historyPath)
+		return
+	}
+
+	data, err := readHistoryFile(absPath)
+	if nil != err {
+		return
+	}
+	ret, err = filesys.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
+	return
+}
+
+type diffBlock struct {
+	node  *ast.Node
+	md    string
+	attrs string
+}
+
+func collectDiffBlocks(tree *parse.Tree, luteEngine *lute.Lute) (ids []string, blocks map[string]*diffBlock) {
+	blocks = map[string]*diffBlock{}
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || ast.NodeDocument == n.Type || "" == n.ID {
+			return ast.WalkContinue
+		}
+		if n.IsContainerBlock() {
+			return ast.WalkContinue
+		}
+
+		ids = append(ids, n.ID)
+		blocks[n.ID] = &diffBlock{
+			node:  n,
+			md:    strings.TrimSpace(treenode.ExportNodeStdMd(n, luteEngine)),
+			attrs: diffBlockAttrs(n),
+		}
+		return ast.WalkSkipChildren
+	})
+	return
+}
+
+// diffBlockAttrs 返回用于比较的块属性，忽略每次保存都会变化的更新时间。
+func diffBlockAttrs(n *ast.Node) string {
+	buf := strings.Builder{}
+	for _, kv := range n.KramdownIAL {
+		if "updated" == kv[0] {
+			continue
+		}
+		buf.WriteString(kv[0] + "=" + kv[1] + "\n")
+	}
+	return buf.String()
+}
+
+func diffTrees(left, right *parse.Tree, luteEngine *lute.Lute) (ret *DocDiff) {
+	ret = &DocDiff{RootID: right.Root.ID, Blocks: []*BlockDiff{}}
+	leftIDs, leftBlocks := collectDiffBlocks(left, luteEngine)
+	rightIDs, rightBlocks := collectDiffBlocks(right, luteEngine)
+
+	for _, op := range diffSeq(leftIDs, rightIDs) {
+		switch op.kind {
+		case diffEqual:
+			id := leftIDs[op.a]
+			if d := diffBlockPair(BlockDiffModified, leftBlocks[id], rightBlocks[id]); nil != d {
+				ret.Blocks = append(ret.Blocks, d)
+				ret.Modified++
+			}
+		case diffDelete:
+			id := leftIDs[op.a]
+			if _, moved := rightBlocks[id]; moved {
+				continue // 移动的块在新位置输出
+			}
+			b := leftBlocks[id]
+			ret.Blocks = append(ret.Blocks, &BlockDiff{Op: BlockDiffRemoved, ID: id, Type: b.node.Type.String(), Old: b.md})
+			ret.Removed++
+		case diffInsert:
+			id := rightIDs[op.b]
+			b := rightBlocks[id]
+			if old := leftBlocks[id]; nil != old {
+				d := diffBlockPair(BlockDiffMoved, old, b)
+				if nil == d {
+					d = &BlockDiff{Op: BlockDiffMoved, ID: id, Type: b.node.Type.String(), Old: old.md, New: b.md}
+				}
+				ret.Blocks = append(ret.Blocks, d)
+				ret.Moved++
+				continue
+			}
+			ret.Blocks = append(ret.Blocks, &BlockDiff{Op: BlockDiffAdded, ID: id, Type: b.node.Type.String(), New: b.md})
+			ret.Added++
+		}
+	}
+	return
+}
+
+// diffBlockPair 比较同一个块的两个版本，没有变化时返回 nil。
+func diffBlockPair(op string, old, cur *diffBlock) (ret *BlockDiff) {
+	attrsChanged := old.attrs != cur.attrs
+	if old.md == cur.md && !attrsChanged && old.node.Type == cur.node.Type {
+		return
+	}
+
+	ret = &BlockDiff{Op: op, ID: cur.node.ID, Type: cur.node.Type.String(), Old: old.md, New: cur.md, AttrsChanged: attrsChanged}
+	if old.md != cur.md {
+		ret.Texts = diffText(old.md, cur.md)
+	}
+	return
+}
+
+// diffText 按字符比较文本，编辑距离过大时退化为整体删除和插入。
+func diffText(old, cur string) (ret []*TextDiff) {
+	a, b := []rune(old), []rune(cur)
+	for _, op := range diffSeq(a, b) {
+		var kind, text string
+		switch op.kind {
+		case diffEqual:
+			kind, text = TextDiffEqual, string(a[op.a])
+		case diffDelete:
+			kind, text = TextDiffDelete, string(a[op.a])
+		case diffInsert:
+			kind, text = TextDiffInsert, string(b[op.b])
+		}
+
+		if last := len(ret) - 1; 0 <= last && ret[last].Op == kind {
+			ret[last].Text += text
+			continue
+		}
+		ret = append(ret, &TextDiff{Op: kind, Text: text})
+	}
+	return
+}
+
+const (
+	diffEqual  = 0
+	diffDelete = -1
+	diffInsert = 1
+
+	diffMaxEdits = 1024 // 最大编辑距离，超过后不再继续计算
+)
+
+type diffOp struct {
+	kind int
+	a, b int // 在两个序列中的下标，插入时 a 无意义，删除时 b 无意义
+}
+
+// diffSeq 使用 Myers 算法计算两个序列的最短编辑脚本。
+func diffSeq[T comparable](a, b []T) (ret []diffOp) {
+	n, m := len(a), len(b)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if diffMaxEdits < d {
+			for i := range a {
+				ret = append(ret, diffOp{kind: diffDelete, a: i})
+			}
+			for j := range b {
+				ret = append(ret, diffOp{kind: diffInsert, b: j})
+			}
+			return
+		}
+
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if 0 == d {
+				x = 0
+			} else if k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]) {
+				x = trace[d-1][k+1+d-1]
+			} else {
+				x = trace[d-1][k-1+d-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				trace = append(trace, v)
+				return backtrackDiff(trace, n, m)
+			}
+		}
+		trace = append(trace, v)
+	}
+	return
+}
+
+func backtrackDiff(trace [][]int, n, m int) (ret []diffOp) {
+	x, y := n, m
+	for d := len(trace) - 1; 0 < d; d-- {
+		prev := trace[d-1]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+
+		startX := prevX + 1
+		if prevK == k+1 {
+			startX = prevX
+		}
+		for x > startX {
+			x--
+			y--
+			ret = append(ret, diffOp{kind: diffEqual, a: x, b: y})
+		}
+		if prevK == k+1 {
+			ret = append(ret, diffOp{kind: diffInsert, b: prevY})
+		} else {
+			ret = append(ret, diffOp{kind: diffDelete, a: prevX})
+		}
+		x, y = prevX, prevY
+	}
+	for 0 < x && 0 < y {
+		x--
+		y--
+		ret = append(ret, diffOp{kind: diffEqual, a: x, b: y})
+	}
+
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return
+}
@@ -0,0 +1,61 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import "testing"
+
+func TestDiffText(t *testing.T) {
+	diffs := diffText("hello world", "hallo brave world")
+	var old, cur string
+	for _, d := range diffs {
+		if TextDiffInsert != d.Op {
+			old += d.Text
+		}
+		if TextDiffDelete != d.Op {
+			cur += d.Text
+		}
+	}
+	if "hello world" != old || "hallo brave world" != cur {
+		t.Fatalf("unexpected diff result [%q, %q]", old, cur)
+	}
+
+	expected := []TextDiff{{TextDiffEqual, "h"}, {TextDiffDelete, "e"}, {TextDiffInsert, "a"}, {TextDiffEqual, "llo "}, {TextDiffInsert, "brave "}, {TextDiffEqual, "world"}}
+	if len(expected) != len(diffs) {
+		t.Fatalf("expected [%d] diffs, got [%d]", len(expected), len(diffs))
+	}
+	for i, d := range diffs {
+		if expected[i] != *d {
+			t.Fatalf("expected [%v], got [%v]", expected[i], *d)
+		}
+	}
+}
+
+func TestDiffSeqMoved(t *testing.T) {
+	ops := diffSeq([]string{"a", "b", "c"}, []string{"b", "c", "a"})
+	deletes, inserts := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case diffDelete:
+			deletes++
+		case diffInsert:
+			inserts++
+		}
+	}
+	if 1 != deletes || 1 != inserts {
+		t.Fatalf("expected one delete and one insert, got [%d, %d]", deletes, inserts)
+	}
+}
@@ -0,0 +1,161 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// HistoryCreatedEvent 描述了一次文件历史生成事件。
+type HistoryCreatedEvent struct {
+	Event   string              `json:"event"`
+	Name    string              `json:"name"`    // 历史目录名，比如 2006-01-02-150405-update
+	Op      string              `json:"op"`      // 历史操作类型
+	Created int64               `json:"created"` // 生成时间，Unix 时间戳（秒）
+	Size    int64               `json:"size"`    // 所有文件大小之和
+	Files   []*HistoryEventFile `json:"files"`
+}
+
+// HistoryEventFile 描述了历史事件中的文件。
+type HistoryEventFile struct {
+	Path string `json:"path"` // 相对于历史文件夹的路径
+	Type int    `json:"type"` // 参考 HistoryTypeDoc 和 HistoryTypeAsset
+	Size int64  `json:"size"`
+}
+
+// SnapshotCreatedEvent 描述了一次数据快照生成事件。
+type SnapshotCreatedEvent struct {
+	Event   string `json:"event"`
+	ID      string `json:"id"`
+	Memo    string `json:"memo"`
+	Created int64  `json:"created"` // 生成时间，Unix 时间戳（毫秒）
+	Count   int    `json:"count"`   // 文件数
+	Size    int64  `json:"size"`    // 文件总大小
+	Tag     string `json:"tag,omitempty"`
+}
+
+func init() {
+	subscribeHistoryEvents()
+}
+
+func subscribeHistoryEvents() {
+	eventbus.Subscribe(util.EvtHistoryCreated, func(evt *HistoryCreatedEvent) {
+		util.BroadcastByType("main", "historyCreated", 0, "", evt)
+		go postHistoryEventWebhooks(evt)
+	})
+	eventbus.Subscribe(util.EvtSnapshotCreated, func(evt *SnapshotCreatedEvent) {
+		util.BroadcastByType("main", "snapshotCreated", 0, "", evt)
+		go postHistoryEventWebhooks(evt)
+	})
+}
+
+func publishHistoryCreated(name string, histories []*sql.History) {
+	if 1 > len(histories) {
+		return
+	}
+
+	evt := &HistoryCreatedEvent{Event: util.EvtHistoryCreated, Name: name, Op: histories[0].Op}
+	if t := strings.LastIndex(name, "-"); 0 < t {
+		if tt, parseErr := time.ParseInLocation("2006-01-02-150405", name[:t], time.Local); nil == parseErr {
+			evt.Created = tt.Unix()
+		}
+	}
+	for _, history := range histories {
+		// 需要在压缩后再获取文件大小，这样才和磁盘上的文件一致
+		info, statErr := os.Stat(filepath.Join(util.HistoryDir, history.Path))
+		if nil != statErr {
+			continue
+		}
+		evt.Files = append(evt.Files, &HistoryEventFile{Path: history.Path, Type: history.Type, Size: info.Size()})
+		evt.Size += info.Size()
+	}
+	if 1 > len(evt.Files) {
+		return
+	}
+	eventbus.Publish(util.EvtHistoryCreated, evt)
+}
+
+func publishSnapshotCreated(index *entity.Index, tag string) {
+	if nil == index {
+		return
+	}
+
+	eventbus.Publish(util.EvtSnapshotCreated, &SnapshotCreatedEvent{
+		Event:   util.EvtSnapshotCreated,
+		ID:      index.ID,
+		Memo:    index.Memo,
+		Created: index.Created,
+		Count:   index.Count,
+		Size:    index.Size,
+		Tag:     tag,
+	})
+}
+
+func postHistoryEventWebhooks(evt interface{}) {
+	defer logging.Recover()
+
+	for _, url := range Conf.Repo.EventWebhooks {
+		if "" == strings.TrimSpace(url) {
+			continue
+		}
+
+		resp, err := httpclient.NewBrowserRequest().SetBody(evt).Post(url)
+		if nil != err {
+			logging.LogWarnf("post history event to webhook [%s] failed: %s", url, err)
+			continue
+		}
+		if 200 > resp.StatusCode || 300 <= resp.StatusCode {
+			logging.LogWarnf("post history event to webhook [%s] failed, status code [%d]", url, resp.StatusCode)
+		}
+	}
+}
+
+// SetRepoEventWebhooks 设置历史和快照事件回调地址。
+func SetRepoEventWebhooks(urls []string) (err error) {
+	var webhooks []string
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if "" == url {
+			continue
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			err = fmt.Errorf("invalid webhook [%s]", url)
+			return
+		}
+		webhooks = append(webhooks, url)
+	}
+	webhooks = gulu.Str.RemoveDuplicatedElem(webhooks)
+	if nil == webhooks {
+		webhooks = []string{}
+	}
+
+	Conf.Repo.EventWebhooks = webhooks
+	Conf.Save()
+	return
+}
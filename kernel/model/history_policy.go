@@ -0,0 +1,155 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+)
+
+// historyPolicy 描述了历史生成和清理策略，笔记本可以覆盖全局设置。
+type historyPolicy struct {
+	retentionDays    int            // 全局历史保留天数
+	interval         int            // 全局生成历史时间间隔，单位：分钟
+	boxRetentionDays map[string]int // 覆盖了全局设置的笔记本历史保留天数
+	boxIntervals     map[string]int // 覆盖了全局设置的笔记本生成历史时间间隔
+}
+
+func getHistoryPolicy() (ret *historyPolicy) {
+	ret = &historyPolicy{
+		retentionDays:    Conf.Editor.HistoryRetentionDays,
+		interval:         Conf.Editor.GenerateHistoryInterval,
+		boxRetentionDays: map[string]int{},
+		boxIntervals:     map[string]int{},
+	}
+
+	for _, box := range Conf.GetBoxes() {
+		boxConf := box.GetConf()
+		if 0 < boxConf.HistoryRetentionDays {
+			ret.boxRetentionDays[box.ID] = boxConf.HistoryRetentionDays
+		}
+		if 0 != boxConf.GenerateHistoryInterval {
+			ret.boxIntervals[box.ID] = boxConf.GenerateHistoryInterval
+		}
+	}
+	return
+}
+
+func (policy *historyPolicy) retentionDaysOf(boxID string) int {
+	if days, ok := policy.boxRetentionDays[boxID]; ok {
+		return days
+	}
+	return policy.retentionDays
+}
+
+func (policy *historyPolicy) maxRetentionDays() (ret int) {
+	ret = policy.retentionDays
+	for _, days := range policy.boxRetentionDays {
+		if ret < days {
+			ret = days
+		}
+	}
+	return
+}
+
+func (policy *historyPolicy) intervalOf(boxID string) int {
+	if interval, ok := policy.boxIntervals[boxID]; ok {
+		return interval
+	}
+	return policy.interval
+}
+
+// minInterval 返回所有生效的生成历史时间间隔中的最小值，都不生成历史时返回 0。
+func (policy *historyPolicy) minInterval() (ret int) {
+	if 0 < policy.interval {
+		ret = policy.interval
+	}
+	for _, interval := range policy.boxIntervals {
+		if 0 < interval && (1 > ret || interval < ret) {
+			ret = interval
+		}
+	}
+	return
+}
+
+// isHistoryDue 判断距离上次生成历史是否已经达到生成间隔，留出半分钟的余量避免定时器抖动导致错过一轮。
+func isHistoryDue(last time.Time, interval int) bool {
+	if 1 > interval {
+		return false
+	}
+	return time.Since(last) >= time.Duration(interval)*time.Minute-30*time.Second
+}
+
+// ResetHistoryTick 根据全局设置和笔记本设置中最短的生成历史时间间隔重置定时器。
+func ResetHistoryTick() {
+	ChangeHistoryTick(getHistoryPolicy().minInterval())
+}
+
+// clearOutdatedHistoryEntries 按照笔记本的保留天数清理历史目录下的笔记本子目录，全部清理后删除历史目录。
+func clearOutdatedHistoryEntries(dir string, modTime time.Time, policy *historyPolicy) {
+	entries, err := os.ReadDir(dir)
+	if nil != err {
+		logging.LogErrorf("read history dir [%s] failed: %s", dir, err)
+		return
+	}
+
+	now := time.Now()
+	remains := 0
+	var storage string
+	for _, entry := range entries {
+		name := entry.Name()
+		days := policy.retentionDays
+		if ast.IsNodeIDPattern(name) {
+			days = policy.retentionDaysOf(name)
+		}
+		if modTime.After(now.Add(-24 * time.Hour * time.Duration(days))) {
+			remains++
+			continue
+		}
+
+		if "storage" == name {
+			// 历史中关联的属性视图随笔记本一起保留
+			storage = filepath.Join(dir, name)
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		if err = os.RemoveAll(p); nil != err {
+			logging.LogWarnf("remove history [%s] failed: %s", p, err)
+		}
+	}
+
+	if 0 < remains {
+		return
+	}
+
+	if "" != storage {
+		if err = os.RemoveAll(storage); nil != err {
+			logging.LogWarnf("remove history [%s] failed: %s", storage, err)
+		}
+	}
+
+	if entries, err = os.ReadDir(dir); nil == err && 1 > len(entries) {
+		if err = os.RemoveAll(dir); nil != err {
+			logging.LogWarnf("remove history dir [%s] failed: %s", dir, err)
+		}
+	}
+}
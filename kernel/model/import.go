@@ -91,6 +91,8 @@ func HTML2Markdown(htmlStr string) (markdown string, withMath bool, err error) {
 }
 
 func ImportSY(zipPath, boxID, toPath string) (err error) {
+	createPreImportSnapshot(zipPath)
+
 	util.PushEndlessProgress(Conf.Language(73))
 	defer util.ClearPushProgress(100)
 
@@ -576,6 +578,8 @@ func ImportSY(zipPath, boxID, toPath string) (err error) {
 }
 
 func ImportData(zipPath string) (err error) {
+	createPreImportSnapshot(zipPath)
+
 	util.PushEndlessProgress(Conf.Language(73))
 	defer util.ClearPushProgress(100)
 
@@ -622,6 +626,8 @@ func ImportData(zipPath string) (err error) {
 }
 
 func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
+	createPreImportSnapshot(localPath)
+
 	util.PushEndlessProgress(Conf.Language(73))
 	defer func() {
 		util.PushClearProgress()
@@ -825,6 +831,11 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 		targetPath := strings.TrimSuffix(toPath, ".sy")
 		id := ast.NewNodeID()
 		targetPath = path.Join(targetPath, id+".sy")
+		if info, statErr := os.Stat(localPath); nil == statErr && streamImportThreshold < info.Size() {
+			// 大文件分块解析并通过事务队列写入，避免在内存中一次性构建整棵树
+			return importStdMdStream(boxID, localPath, targetPath, title, info.Size())
+		}
+
 		var data []byte
 		data, err = os.ReadFile(localPath)
 		if nil != err {
@@ -848,60 +859,7 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 
 		docDirLocalPath := filepath.Dir(filepath.Join(boxLocalPath, targetPath))
 		assetDirPath := getAssetsDir(boxLocalPath, docDirLocalPath)
-		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
-			if !entering || (ast.NodeLinkDest != n.Type && !n.IsTextMarkType("a")) {
-				return ast.WalkContinue
-			}
-
-			var dest string
-			if ast.NodeLinkDest == n.Type {
-				dest = n.TokensStr()
-			} else {
-				dest = n.TextMarkAHref
-			}
-
-			if strings.HasPrefix(dest, "data:image") && strings.Contains(dest, ";base64,") {
-				processBase64Img(n, dest, assetDirPath, err)
-				return ast.WalkContinue
-			}
-
-			dest = strings.ReplaceAll(dest, "%20", " ")
-			dest = strings.ReplaceAll(dest, "%5C", "/")
-			if ast.NodeLinkDest == n.Type {
-				n.Tokens = []byte(dest)
-			} else {
-				n.TextMarkAHref = dest
-			}
-			if !util.IsRelativePath(dest) {
-				return ast.WalkContinue
-			}
-			dest = filepath.ToSlash(dest)
-			if "" == dest {
-				return ast.WalkContinue
-			}
-
-			absolutePath := filepath.Join(filepath.Dir(localPath), dest)
-			exist := gulu.File.IsExist(absolutePath)
-			if !exist {
-				absolutePath = filepath.Join(filepath.Dir(localPath), string(html.DecodeDestination([]byte(dest))))
-				exist = gulu.File.IsExist(absolutePath)
-			}
-			if exist {
-				name := filepath.Base(absolutePath)
-				name = util.AssetName(name)
-				assetTargetPath := filepath.Join(assetDirPath, name)
-				if err = filelock.Copy(absolutePath, assetTargetPath); nil != err {
-					logging.LogErrorf("copy asset from [%s] to [%s] failed: %s", absolutePath, assetTargetPath, err)
-					return ast.WalkContinue
-				}
-				if ast.NodeLinkDest == n.Type {
-					n.Tokens = []byte("assets/" + name)
-				} else {
-					n.TextMarkAHref = "assets/" + name
-				}
-			}
-			return ast.WalkContinue
-		})
+		err = importStdMdAssets(tree, localPath, assetDirPath)
 
 		reassignIDUpdated(tree)
 		importTrees = append(importTrees, tree)
@@ -929,6 +887,65 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 	return
 }
 
+// importStdMdAssets 将 Markdown 中引用的本地资源文件复制到 assetDirPath 下并改写链接。
+func importStdMdAssets(tree *parse.Tree, localPath, assetDirPath string) (err error) {
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || (ast.NodeLinkDest != n.Type && !n.IsTextMarkType("a")) {
+			return ast.WalkContinue
+		}
+
+		var dest string
+		if ast.NodeLinkDest == n.Type {
+			dest = n.TokensStr()
+		} else {
+			dest = n.TextMarkAHref
+		}
+
+		if strings.HasPrefix(dest, "data:image") && strings.Contains(dest, ";base64,") {
+			processBase64Img(n, dest, assetDirPath, err)
+			return ast.WalkContinue
+		}
+
+		dest = strings.ReplaceAll(dest, "%20", " ")
+		dest = strings.ReplaceAll(dest, "%5C", "/")
+		if ast.NodeLinkDest == n.Type {
+			n.Tokens = []byte(dest)
+		} else {
+			n.TextMarkAHref = dest
+		}
+		if !util.IsRelativePath(dest) {
+			return ast.WalkContinue
+		}
+		dest = filepath.ToSlash(dest)
+		if "" == dest {
+			return ast.WalkContinue
+		}
+
+		absolutePath := filepath.Join(filepath.Dir(localPath), dest)
+		exist := gulu.File.IsExist(absolutePath)
+		if !exist {
+			absolutePath = filepath.Join(filepath.Dir(localPath), string(html.DecodeDestination([]byte(dest))))
+			exist = gulu.File.IsExist(absolutePath)
+		}
+		if exist {
+			name := filepath.Base(absolutePath)
+			name = util.AssetName(name)
+			assetTargetPath := filepath.Join(assetDirPath, name)
+			if err = filelock.Copy(absolutePath, assetTargetPath); nil != err {
+				logging.LogErrorf("copy asset from [%s] to [%s] failed: %s", absolutePath, assetTargetPath, err)
+				return ast.WalkContinue
+			}
+			if ast.NodeLinkDest == n.Type {
+				n.Tokens = []byte("assets/" + name)
+			} else {
+				n.TextMarkAHref = "assets/" + name
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
 func parseStdMd(markdown []byte) (ret *parse.Tree) {
 	luteEngine := util.NewStdLute()
 	ret = parse.Parse("", markdown, luteEngine.ParseOptions)
@@ -0,0 +1,628 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/html/atom"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ImportAppleNotes 导入 Apple Notes 数据。
+//
+// localPath 可以是以下两种形式之一：
+//   - 导出的文件夹：子文件夹对应 Notes 文件夹，.html/.htm 文件对应笔记，附件放在笔记旁边通过相对路径引用
+//   - NoteStore.sqlite 备份文件：直接解析数据库中的笔记正文，保留文件夹层级、标题、列表和清单
+func ImportAppleNotes(boxID, localPath, toPath string) (err error) {
+	util.PushEndlessProgress(Conf.Language(73))
+	defer func() {
+		util.PushClearProgress()
+
+		if e := recover(); nil != e {
+			stack := debug.Stack()
+			msg := fmt.Sprintf("PANIC RECOVERED: %v\n\t%s\n", e, stack)
+			logging.LogErrorf("import apple notes failed: %s", msg)
+			err = errors.New("import apple notes failed, please check kernel log for details")
+		}
+	}()
+
+	if !gulu.File.IsExist(localPath) {
+		return errors.New("apple notes export not found")
+	}
+
+	lockSync()
+	defer unlockSync()
+
+	WaitForWritingFiles()
+
+	var baseHPath, baseTargetPath string
+	if "/" == toPath {
+		baseHPath = "/"
+		baseTargetPath = "/"
+	} else {
+		block := treenode.GetBlockTreeRootByPath(boxID, toPath)
+		if nil == block {
+			logging.LogErrorf("not found block by path [%s]", toPath)
+			return nil
+		}
+		baseHPath = block.HPath
+		baseTargetPath = strings.TrimSuffix(block.Path, ".sy")
+	}
+
+	importer := &appleNotesImporter{
+		boxID:        boxID,
+		boxLocalPath: filepath.Join(util.DataDir, boxID),
+		folders:      map[string]*appleNotesFolder{},
+	}
+	importer.folders[""] = &appleNotesFolder{targetPath: baseTargetPath, hPath: baseHPath}
+
+	if gulu.File.IsDir(localPath) {
+		err = importer.importExportDir(localPath)
+	} else if strings.HasSuffix(strings.ToLower(localPath), ".sqlite") {
+		err = importer.importNoteStore(localPath)
+	} else {
+		err = errors.New(Conf.Language(79))
+	}
+	if nil != err {
+		importTrees = []*parse.Tree{}
+		return
+	}
+
+	if 0 < len(importTrees) {
+		initSearchLinks()
+		convertWikiLinksAndTags()
+		buildBlockRefInText()
+
+		for i, tree := range importTrees {
+			indexWriteTreeIndexQueue(tree)
+			if 0 == i%4 {
+				util.PushEndlessProgress(fmt.Sprintf(Conf.Language(66), fmt.Sprintf("%d/%d ", i, len(importTrees))+tree.HPath))
+			}
+		}
+		util.PushClearProgress()
+
+		importTrees = []*parse.Tree{}
+		searchLinks = map[string]string{}
+	}
+
+	IncSync()
+	debug.FreeOSMemory()
+	return
+}
+
+type appleNotesFolder struct {
+	targetPath string // 不带 .sy 后缀的目标路径
+	hPath      string
+}
+
+type appleNotesImporter struct {
+	boxID        string
+	boxLocalPath string
+	exportDir    string                       // 导出文件夹，笔记引用的附件必须位于该文件夹下
+	folders      map[string]*appleNotesFolder // 文件夹相对路径 -> 文件夹对应的文档
+}
+
+// folder 返回 Notes 文件夹对应的文档，不存在时逐级创建。
+func (importer *appleNotesImporter) folder(relPath string) *appleNotesFolder {
+	relPath = strings.Trim(relPath, "/")
+	if ret := importer.folders[relPath]; nil != ret {
+		return ret
+	}
+
+	parentRelPath := path.Dir(relPath)
+	if "." == parentRelPath {
+		parentRelPath = ""
+	}
+	parent := importer.folder(parentRelPath)
+	title := path.Base(relPath)
+	id := ast.NewNodeID()
+	targetPath := path.Join(parent.targetPath, id)
+	hPath := path.Join(parent.hPath, title)
+	tree := treenode.NewTree(importer.boxID, targetPath+".sy", hPath, title)
+	importTrees = append(importTrees, tree)
+
+	ret := &appleNotesFolder{targetPath: targetPath, hPath: hPath}
+	importer.folders[relPath] = ret
+	return ret
+}
+
+func (importer *appleNotesImporter) importExportDir(localPath string) (err error) {
+	if importer.exportDir, err = filepath.EvalSymlinks(localPath); nil != err {
+		return
+	}
+
+	return filelock.Walk(localPath, func(currentPath string, info os.FileInfo, walkErr error) error {
+		if nil != walkErr {
+			return walkErr
+		}
+		if localPath == currentPath {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath := filepath.ToSlash(strings.TrimPrefix(currentPath, localPath))
+		if info.IsDir() {
+			importer.folder(relPath)
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		if ".html" != ext && ".htm" != ext {
+			// 其他文件作为附件由笔记引用时再复制
+			return nil
+		}
+
+		data, readErr := os.ReadFile(currentPath)
+		if nil != readErr {
+			logging.LogErrorf("read apple note [%s] failed: %s", currentPath, readErr)
+			return nil
+		}
+
+		markdown, convertErr := appleNoteHTML2Markdown(string(data))
+		if nil != convertErr {
+			logging.LogErrorf("convert apple note [%s] failed: %s", currentPath, convertErr)
+			return nil
+		}
+
+		title := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		importer.addNote(path.Dir(relPath), title, markdown, filepath.Dir(currentPath))
+		return nil
+	})
+}
+
+func (importer *appleNotesImporter) addNote(folderRelPath, title, markdown, attachmentDir string) {
+	tree := parseStdMd([]byte(markdown))
+	if nil == tree {
+		logging.LogErrorf("parse apple note [%s] failed", title)
+		return
+	}
+
+	folder := importer.folders[""]
+	if "." != folderRelPath && "/" != folderRelPath && "" != folderRelPath {
+		folder = importer.folder(folderRelPath)
+	}
+
+	id := ast.NewNodeID()
+	tree.ID = id
+	tree.Root.ID = id
+	tree.Root.SetIALAttr("id", tree.Root.ID)
+	tree.Root.SetIALAttr("title", title)
+	tree.Box = importer.boxID
+	tree.Path = path.Join(folder.targetPath, id+".sy")
+	tree.HPath = path.Join(folder.hPath, title)
+	tree.Root.Spec = "1"
+
+	if "" != attachmentDir {
+		docDirLocalPath := filepath.Dir(filepath.Join(importer.boxLocalPath, tree.Path))
+		assetDirPath := getAssetsDir(importer.boxLocalPath, docDirLocalPath)
+		importAppleNoteAttachments(tree, importer.exportDir, attachmentDir, assetDirPath)
+	}
+
+	reassignIDUpdated(tree)
+	importTrees = append(importTrees, tree)
+}
+
+// importAppleNoteAttachments 将笔记中通过相对路径引用的附件复制到资源文件夹下。
+//
+// 附件必须是位于导出文件夹 exportDir 下的普通文件，避免笔记通过 ../ 等相对路径引用导出文件夹外的本地文件。
+func importAppleNoteAttachments(tree *parse.Tree, exportDir, attachmentDir, assetDirPath string) {
+	assetsDone := map[string]string{}
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || (ast.NodeLinkDest != n.Type && !n.IsTextMarkType("a")) {
+			return ast.WalkContinue
+		}
+
+		var dest string
+		if ast.NodeLinkDest == n.Type {
+			dest = n.TokensStr()
+		} else {
+			dest = n.TextMarkAHref
+		}
+
+		if strings.HasPrefix(dest, "data:image") && strings.Contains(dest, ";base64,") {
+			processBase64Img(n, dest, assetDirPath, nil)
+			return ast.WalkContinue
+		}
+
+		dest = strings.ReplaceAll(dest, "%20", " ")
+		if !util.IsRelativePath(dest) || "" == dest {
+			return ast.WalkContinue
+		}
+
+		absolutePath := filepath.Join(attachmentDir, filepath.FromSlash(dest))
+		if !gulu.File.IsExist(absolutePath) {
+			absolutePath = filepath.Join(attachmentDir, string(html.DecodeDestination([]byte(dest))))
+			if !gulu.File.IsExist(absolutePath) {
+				return ast.WalkContinue
+			}
+		}
+
+		absolutePath, err := filepath.EvalSymlinks(absolutePath)
+		if nil != err || !util.IsSubPath(exportDir, absolutePath) || gulu.File.IsDir(absolutePath) {
+			logging.LogWarnf("skip apple note attachment [%s] outside the export folder", dest)
+			return ast.WalkContinue
+		}
+
+		name := assetsDone[absolutePath]
+		if "" == name {
+			name = util.AssetName(util.FilterFileName(filepath.Base(absolutePath)))
+			assetTargetPath := filepath.Join(assetDirPath, name)
+			if !util.IsSubPath(assetDirPath, assetTargetPath) {
+				logging.LogWarnf("skip apple note attachment [%s] with invalid name", dest)
+				return ast.WalkContinue
+			}
+			if err := filelock.Copy(absolutePath, assetTargetPath); nil != err {
+				logging.LogErrorf("copy asset from [%s] to [%s] failed: %s", absolutePath, assetTargetPath, err)
+				return ast.WalkContinue
+			}
+			assetsDone[absolutePath] = name
+		}
+
+		if ast.NodeLinkDest == n.Type {
+			n.Tokens = []byte("assets/" + name)
+		} else {
+			n.TextMarkAHref = "assets/" + name
+		}
+		return ast.WalkContinue
+	})
+}
+
+// appleNoteHTML2Markdown 将 Apple Notes 导出的 HTML 转换为 Markdown。
+//
+// Notes 的清单在导出时使用 <ul class="checklist"> 以及 <li class="checked"> 标记，这里将其转换为任务列表。
+func appleNoteHTML2Markdown(htmlStr string) (markdown string, err error) {
+	nodes, err := html.ParseFragment(strings.NewReader(htmlStr), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if nil != err {
+		return
+	}
+
+	var walk func(n *html.Node, inChecklist bool)
+	walk = func(n *html.Node, inChecklist bool) {
+		if atom.Ul == n.DataAtom && strings.Contains(domAttrValue(n, "class"), "checklist") {
+			inChecklist = true
+		}
+
+		if atom.Li == n.DataAtom {
+			class := domAttrValue(n, "class")
+			checked := strings.Contains(class, "checked") && !strings.Contains(class, "unchecked")
+			checked = checked || "true" == domAttrValue(n, "data-checked")
+			if inChecklist || checked || "" != domAttrValue(n, "data-checked") {
+				checkbox := &html.Node{Type: html.ElementNode, Data: "input", DataAtom: atom.Input}
+				checkbox.Attr = append(checkbox.Attr, &html.Attribute{Key: "type", Val: "checkbox"})
+				if checked {
+					checkbox.Attr = append(checkbox.Attr, &html.Attribute{Key: "checked", Val: ""})
+				}
+				n.InsertChildBefore(checkbox, n.FirstChild)
+			}
+		}
+
+		if atom.Img == n.DataAtom || atom.A == n.DataAtom {
+			// 附件文件名中可能包含空格
+			for _, attr := range n.Attr {
+				if "src" == attr.Key || "href" == attr.Key {
+					attr.Val = strings.ReplaceAll(attr.Val, " ", "%20")
+				}
+			}
+		}
+
+		for c := n.FirstChild; nil != c; c = c.NextSibling {
+			walk(c, inChecklist)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	for _, n := range nodes {
+		walk(n, false)
+		if err = html.Render(&buf, n); nil != err {
+			return
+		}
+	}
+
+	luteEngine := util.NewLute()
+	markdown, err = luteEngine.HTML2Markdown(buf.String())
+	return
+}
+
+func (importer *appleNotesImporter) importNoteStore(sqlitePath string) (err error) {
+	db, err := gosql.Open("sqlite3_extended", "file:"+sqlitePath+"?mode=ro")
+	if nil != err {
+		logging.LogErrorf("open apple notes database [%s] failed: %s", sqlitePath, err)
+		return
+	}
+	defer db.Close()
+
+	// 文件夹
+	folderTitles, folderParents := map[int64]string{}, map[int64]int64{}
+	rows, err := db.Query("SELECT Z_PK, ZTITLE2, IFNULL(ZPARENT, 0) FROM ZICCLOUDSYNCINGOBJECT WHERE ZTITLE2 IS NOT NULL")
+	if nil != err {
+		logging.LogErrorf("query apple notes folders failed: %s", err)
+		return
+	}
+	for rows.Next() {
+		var id, parent int64
+		var title string
+		if err = rows.Scan(&id, &title, &parent); nil != err {
+			rows.Close()
+			logging.LogErrorf("scan apple notes folder failed: %s", err)
+			return
+		}
+		folderTitles[id] = title
+		folderParents[id] = parent
+	}
+	rows.Close()
+
+	folderPath := func(id int64) string {
+		var segments []string
+		for visited := map[int64]bool{}; 0 != id && !visited[id]; id = folderParents[id] {
+			visited[id] = true
+			if title, ok := folderTitles[id]; ok {
+				segments = append([]string{util.FilterFileName(title)}, segments...)
+			}
+		}
+		return strings.Join(segments, "/")
+	}
+
+	// 笔记
+	rows, err = db.Query("SELECT n.ZTITLE1, IFNULL(n.ZFOLDER, 0), d.ZDATA FROM ZICCLOUDSYNCINGOBJECT n JOIN ZICNOTEDATA d ON d.ZNOTE = n.Z_PK " +
+		"WHERE n.ZTITLE1 IS NOT NULL AND d.ZDATA IS NOT NULL AND IFNULL(n.ZMARKEDFORDELETION, 0) = 0")
+	if nil != err {
+		logging.LogErrorf("query apple notes failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var title string
+		var folderID int64
+		var data []byte
+		if err = rows.Scan(&title, &folderID, &data); nil != err {
+			logging.LogErrorf("scan apple note failed: %s", err)
+			return
+		}
+
+		markdown, parseErr := appleNoteData2Markdown(data)
+		if nil != parseErr {
+			logging.LogWarnf("parse apple note [%s] failed: %s", title, parseErr)
+			continue
+		}
+		importer.addNote(folderPath(folderID), util.FilterFileName(title), markdown, "")
+	}
+	err = rows.Err()
+	return
+}
+
+// Apple Notes 段落样式 https://ciofecaforensics.com/2020/09/18/apple-notes-revisited-protobuf/
+const (
+	appleNoteStyleTitle      = 0
+	appleNoteStyleHeading    = 1
+	appleNoteStyleSubheading = 2
+	appleNoteStyleMonospaced = 4
+	appleNoteStyleDotted     = 100
+	appleNoteStyleDashed     = 101
+	appleNoteStyleNumbered   = 102
+	appleNoteStyleChecklist  = 103
+)
+
+type appleNoteParagraphStyle struct {
+	styleType int64
+	indent    int64
+	checked   bool
+}
+
+// appleNoteData2Markdown 将 ZICNOTEDATA.ZDATA（gzip 压缩的 protobuf）转换为 Markdown。
+//
+// 附件在正文中以 U+FFFC 占位，数据库模式下附件内容位于 Media 目录中，这里不做导入。
+func appleNoteData2Markdown(data []byte) (markdown string, err error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if nil != err {
+		return
+	}
+	data, err = io.ReadAll(reader)
+	if nil != err {
+		return
+	}
+
+	// NoteStoreProto.document(2).note(3)
+	document := protoField(data, 2)
+	note := protoField(document, 3)
+	if nil == note {
+		err = errors.New("note body not found")
+		return
+	}
+
+	text := []rune(string(protoField(note, 2)))
+	styles := make([]*appleNoteParagraphStyle, len(text))
+	offset := 0
+	for _, run := range protoFields(note, 5) {
+		length := int(protoVarint(run, 1))
+		style := &appleNoteParagraphStyle{styleType: -1}
+		if paragraph := protoField(run, 2); nil != paragraph {
+			if protoHas(paragraph, 1) {
+				style.styleType = protoVarint(paragraph, 1)
+			}
+			style.indent = protoVarint(paragraph, 4)
+			if checklist := protoField(paragraph, 5); nil != checklist {
+				style.checked = 1 == protoVarint(checklist, 2)
+			}
+		}
+		for i := offset; i < offset+length && i < len(styles); i++ {
+			styles[i] = style
+		}
+		offset += length
+	}
+
+	buf := bytes.Buffer{}
+	lineStart := 0
+	inCode := false
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && '\n' != text[i] {
+			continue
+		}
+
+		line := strings.ReplaceAll(string(text[lineStart:i]), "\ufffc", "")
+		style := &appleNoteParagraphStyle{styleType: -1}
+		if lineStart < len(styles) && nil != styles[lineStart] {
+			style = styles[lineStart]
+		}
+		lineStart = i + 1
+
+		if appleNoteStyleMonospaced == style.styleType {
+			if !inCode {
+				buf.WriteString("```\n")
+				inCode = true
+			}
+			buf.WriteString(line + "\n")
+			continue
+		}
+		if inCode {
+			buf.WriteString("```\n\n")
+			inCode = false
+		}
+
+		indent := strings.Repeat("  ", int(style.indent))
+		switch style.styleType {
+		case appleNoteStyleTitle:
+			buf.WriteString("\n# " + line + "\n\n")
+		case appleNoteStyleHeading:
+			buf.WriteString("\n## " + line + "\n\n")
+		case appleNoteStyleSubheading:
+			buf.WriteString("\n### " + line + "\n\n")
+		case appleNoteStyleDotted, appleNoteStyleDashed:
+			buf.WriteString(indent + "* " + line + "\n")
+		case appleNoteStyleNumbered:
+			buf.WriteString(indent + "1. " + line + "\n")
+		case appleNoteStyleChecklist:
+			if style.checked {
+				buf.WriteString(indent + "* [X] " + line + "\n")
+			} else {
+				buf.WriteString(indent + "* [ ] " + line + "\n")
+			}
+		default:
+			buf.WriteString("\n" + line + "\n\n")
+		}
+	}
+	if inCode {
+		buf.WriteString("```\n")
+	}
+	markdown = buf.String()
+	return
+}
+
+// protoFields 返回 protobuf 消息中指定字段编号的所有长度分隔（wire type 2）字段值。
+func protoFields(msg []byte, fieldNum int) (ret [][]byte) {
+	protoWalk(msg, func(num, wireType int, varint uint64, data []byte) {
+		if num == fieldNum && 2 == wireType {
+			ret = append(ret, data)
+		}
+	})
+	return
+}
+
+func protoField(msg []byte, fieldNum int) []byte {
+	fields := protoFields(msg, fieldNum)
+	if 1 > len(fields) {
+		return nil
+	}
+	return fields[0]
+}
+
+func protoVarint(msg []byte, fieldNum int) (ret int64) {
+	protoWalk(msg, func(num, wireType int, varint uint64, data []byte) {
+		if num == fieldNum && 0 == wireType {
+			ret = int64(varint)
+		}
+	})
+	return
+}
+
+func protoHas(msg []byte, fieldNum int) (ret bool) {
+	protoWalk(msg, func(num, wireType int, varint uint64, data []byte) {
+		if num == fieldNum {
+			ret = true
+		}
+	})
+	return
+}
+
+// protoWalk 遍历 protobuf 消息的顶层字段，遇到无法解析的数据时停止。
+func protoWalk(msg []byte, fn func(num, wireType int, varint uint64, data []byte)) {
+	readVarint := func(i int) (uint64, int) {
+		var ret uint64
+		for shift := uint(0); i < len(msg) && shift < 64; shift += 7 {
+			b := msg[i]
+			i++
+			ret |= uint64(b&0x7F) << shift
+			if 0x80 > b {
+				return ret, i
+			}
+		}
+		return 0, -1
+	}
+
+	for i := 0; i < len(msg); {
+		key, next := readVarint(i)
+		if 0 > next {
+			return
+		}
+		i = next
+		num, wireType := int(key>>3), int(key&0x7)
+		switch wireType {
+		case 0:
+			v, next := readVarint(i)
+			if 0 > next {
+				return
+			}
+			i = next
+			fn(num, wireType, v, nil)
+		case 1:
+			i += 8
+		case 2:
+			length, next := readVarint(i)
+			if 0 > next || next+int(length) > len(msg) || 0 > int(length) {
+				return
+			}
+			fn(num, wireType, 0, msg[next:next+int(length)])
+			i = next + int(length)
+		case 5:
+			i += 4
+		default:
+			return
+		}
+	}
+}
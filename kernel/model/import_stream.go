@@ -0,0 +1,239 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	streamImportThreshold = 4 * 1024 * 1024 // 超过该大小的 Markdown 文件走分块导入
+	streamImportChunkSize = 512 * 1024      // 每块的最小字节数
+	streamImportMaxChunks = 64              // 分块数上限，避免大文档被反复整体重写
+)
+
+// importStdMdStream 分块导入大 Markdown 文件：先创建空文档，然后每解析一块就通过事务队列追加到文档末尾，
+// 处理完一块再读取下一块，峰值内存只与文档本身和单块大小有关。
+func importStdMdStream(boxID, localPath, targetPath, title string, size int64) (err error) {
+	file, err := os.Open(localPath)
+	if nil != err {
+		return
+	}
+	defer file.Close()
+
+	tree, err := createDoc(boxID, targetPath, title, "")
+	if nil != err {
+		return
+	}
+
+	boxLocalPath := filepath.Join(util.DataDir, boxID)
+	docDirLocalPath := filepath.Dir(filepath.Join(boxLocalPath, targetPath))
+	assetDirPath := getAssetsDir(boxLocalPath, docDirLocalPath)
+
+	// createDoc 会放置一个空段落，写入第一块时一并删除
+	var placeholderID string
+	if nil != tree.Root.FirstChild {
+		placeholderID = tree.Root.FirstChild.ID
+	}
+	rootID, hPath := tree.ID, tree.HPath
+	tree = nil
+
+	var read int64
+	chunkSize := max(int64(streamImportChunkSize), size/streamImportMaxChunks)
+	err = splitMarkdownChunks(file, int(chunkSize), func(chunk []byte) error {
+		read += int64(len(chunk))
+		chunkTree := parseStdMd(chunk)
+		if nil == chunkTree {
+			return fmt.Errorf("parse tree [%s] failed", localPath)
+		}
+		if assetErr := importStdMdAssets(chunkTree, localPath, assetDirPath); nil != assetErr {
+			logging.LogWarnf("import assets of [%s] failed: %s", localPath, assetErr)
+		}
+
+		ops := []*Operation{{Action: "appendInsert", ParentID: rootID, Data: renderStreamChunk(chunkTree)}}
+		if "" != placeholderID {
+			ops = append(ops, &Operation{Action: "delete", ID: placeholderID})
+			placeholderID = ""
+		}
+		performStreamTx(ops)
+		util.PushEndlessProgress(fmt.Sprintf(Conf.Language(66), fmt.Sprintf("%d%% ", read*100/max(size, 1))+hPath))
+		return nil
+	})
+	if nil != err {
+		logging.LogErrorf("stream import [%s] failed: %s", localPath, err)
+		return
+	}
+
+	IncSync()
+	return
+}
+
+// ImportStream 将大段 HTML 或 Markdown 分块解析后插入到 previousID 之后，previousID 为空时追加到 parentID 下。
+//
+// 用于粘贴超大剪贴板内容，避免前端和内核一次性构建整棵树。
+func ImportStream(dataType, data, parentID, previousID string) (err error) {
+	if "" == previousID && "" == parentID {
+		return errors.New("parentID or previousID is required")
+	}
+	anchorID := previousID
+	if "" == anchorID {
+		anchorID = parentID
+	}
+	if nil == treenode.GetBlockTree(anchorID) {
+		return ErrBlockNotFound
+	}
+
+	// HTML 转换后的 Markdown 按思源方言解析，与 /api/lute/html2BlockDOM 一致
+	parseChunk := parseStdMd
+	if "html" == dataType {
+		var withMath bool
+		if data, withMath, err = HTML2Markdown(data); nil != err {
+			return
+		}
+
+		luteEngine := util.NewLute()
+		if withMath {
+			luteEngine.SetInlineMath(true)
+		}
+		parseChunk = func(chunk []byte) *parse.Tree {
+			return parse.Parse("", chunk, luteEngine.ParseOptions)
+		}
+	}
+
+	WaitForWritingFiles()
+	return splitMarkdownChunks(strings.NewReader(data), streamImportChunkSize, func(chunk []byte) error {
+		chunkTree := parseChunk(chunk)
+		if nil == chunkTree || nil == chunkTree.Root.FirstChild {
+			return nil
+		}
+
+		var lastID string
+		for c := chunkTree.Root.FirstChild; nil != c; c = c.Next {
+			if ast.NodeKramdownBlockIAL == c.Type {
+				continue
+			}
+			if "" == c.ID {
+				c.ID = ast.NewNodeID()
+				c.SetIALAttr("id", c.ID)
+			}
+			lastID = c.ID
+		}
+
+		op := &Operation{Action: "appendInsert", ParentID: parentID, Data: renderStreamChunk(chunkTree)}
+		if "" != previousID {
+			op = &Operation{Action: "insert", PreviousID: previousID, Data: op.Data}
+		}
+		performStreamTx([]*Operation{op})
+		if "" != previousID {
+			previousID = lastID
+		}
+		return nil
+	})
+}
+
+func renderStreamChunk(tree *parse.Tree) string {
+	luteEngine := util.NewLute()
+	buf := &bytes.Buffer{}
+	for c := tree.Root.FirstChild; nil != c; c = c.Next {
+		if ast.NodeKramdownBlockIAL == c.Type {
+			continue
+		}
+		buf.WriteString(luteEngine.RenderNodeBlockDOM(c))
+	}
+	return buf.String()
+}
+
+func performStreamTx(ops []*Operation) {
+	transactions := []*Transaction{{DoOperations: ops}}
+	PerformTransactions(&transactions)
+	WaitForWritingFiles()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+}
+
+// splitMarkdownChunks 按顶层块边界将 Markdown 切分为不小于 chunkSize 的块并依次回调。
+//
+// 只在空行之后、下一行不缩进且不处于代码块或公式块中时切分，这样缩进的列表项内容不会被拆开；
+// 跨块的链接引用定义和脚注无法解析，这是分块导入的已知限制。
+func splitMarkdownChunks(r io.Reader, chunkSize int, f func(chunk []byte) error) (err error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	buf := &bytes.Buffer{}
+	var fence string
+	var afterBlank bool
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if 0 < len(line) {
+			trimmed := bytes.TrimSpace(line)
+			if "" == fence && afterBlank && chunkSize <= buf.Len() && 0 < len(trimmed) && ' ' != line[0] && '\t' != line[0] {
+				if err = f(buf.Bytes()); nil != err {
+					return
+				}
+				buf = &bytes.Buffer{}
+			}
+			buf.Write(line)
+
+			afterBlank = 0 == len(trimmed)
+			if "" == fence {
+				fence = openingFence(trimmed)
+			} else if bytes.HasPrefix(trimmed, []byte(fence)) && 0 == len(bytes.Trim(trimmed, fence[:1])) {
+				fence = ""
+			}
+		}
+		if nil != readErr {
+			if io.EOF != readErr {
+				return readErr
+			}
+			break
+		}
+	}
+	if 0 < buf.Len() {
+		err = f(buf.Bytes())
+	}
+	return
+}
+
+// openingFence 返回代码块或公式块的起始标记，不是起始行时返回空字符串。
+func openingFence(trimmed []byte) string {
+	if bytes.Equal(trimmed, []byte("$$")) {
+		return "$$"
+	}
+	for _, marker := range []byte{'`', '~'} {
+		n := 0
+		for n < len(trimmed) && marker == trimmed[n] {
+			n++
+		}
+		if 3 <= n {
+			return strings.Repeat(string(marker), n)
+		}
+	}
+	return ""
+}
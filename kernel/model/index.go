@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
@@ -153,10 +154,26 @@ func index(boxID string) {
 	lock := sync.Mutex{}
 	util.PushStatusBar(fmt.Sprintf("["+html.EscapeString(box.Name)+"] "+Conf.Language(64), len(files)))
 
-	poolSize := runtime.NumCPU()
-	if 4 < poolSize {
-		poolSize = 4
-	}
+	// 批量写入不经过数据库队列，需要先提交队列中已有的操作（比如删除笔记本引用），避免这些操作在批量写入之后执行
+	sql.FlushQueue()
+
+	// 解析文档和构建块在协程池中并发进行，构建好的数据库行由单个协程按批次写入
+	poolSize, batchSize := indexWorkers(), Conf.Performance.IndexBatchSize
+	indexedTrees := make(chan *sql.IndexedTree, batchSize*2)
+	writeDone := make(chan bool)
+	go func() {
+		defer close(writeDone)
+		var batch []*sql.IndexedTree
+		for indexedTree := range indexedTrees {
+			batch = append(batch, indexedTree)
+			if batchSize <= len(batch) {
+				sql.BatchIndexTrees(batch)
+				batch = nil
+			}
+		}
+		sql.BatchIndexTrees(batch)
+	}()
+
 	waitGroup := &sync.WaitGroup{}
 	var avNodes []*ast.Node
 	p, _ := ants.NewPoolWithFunc(poolSize, func(arg interface{}) {
@@ -190,7 +207,7 @@ func index(boxID string) {
 
 		cache.PutDocIAL(file.path, docIAL)
 		treenode.IndexBlockTree(tree)
-		sql.IndexTreeQueue(tree)
+		indexedTrees <- sql.BuildIndexedTree(tree)
 		util.IncBootProgress(bootProgressPart, fmt.Sprintf(Conf.Language(92), util.ShortPathForBootingDisplay(tree.Path)))
 		if 1 < i && 0 == i%64 {
 			util.PushStatusBar(fmt.Sprintf(Conf.Language(88), i, (len(files))-i))
@@ -210,6 +227,8 @@ func index(boxID string) {
 	}
 	waitGroup.Wait()
 	p.Release()
+	close(indexedTrees)
+	<-writeDone
 
 	// 关联数据库和块
 	av.BatchUpsertBlockRel(avNodes)
@@ -217,11 +236,25 @@ func index(boxID string) {
 	box.UpdateHistoryGenerated() // 初始化历史生成时间为当前时间
 	end := time.Now()
 	elapsed := end.Sub(start).Seconds()
-	logging.LogInfof("rebuilt database for notebook [%s] in [%.2fs], tree [count=%d, size=%s]", box.ID, elapsed, treeCount, humanize.BytesCustomCeil(uint64(treeSize), 2))
+	logging.LogInfof("rebuilt database for notebook [%s] in [%.2fs], tree [count=%d, size=%s], workers [%d], batch [%d]", box.ID, elapsed, treeCount, humanize.BytesCustomCeil(uint64(treeSize), 2), poolSize, batchSize)
 	debug.FreeOSMemory()
 	return
 }
 
+// indexWorkers 返回重建索引时的并发数，未配置时按 CPU 核数确定。
+func indexWorkers() (ret int) {
+	ret = Conf.Performance.IndexWorkers
+	if 0 < ret {
+		return
+	}
+
+	ret = runtime.NumCPU()
+	if 8 < ret {
+		ret = 8
+	}
+	return
+}
+
 func IndexRefs() {
 	start := time.Now()
 	util.SetBootDetails("Resolving refs...")
@@ -235,7 +268,7 @@ func IndexRefs() {
 		pages := pagedPaths(filepath.Join(util.DataDir, box.ID), 32)
 		for _, paths := range pages {
 			for _, treeAbsPath := range paths {
-				data, readErr := filelock.ReadFile(treeAbsPath)
+				data, readErr := filesys.ReadSyFile(treeAbsPath)
 				if nil != readErr {
 					logging.LogWarnf("get data [path=%s] failed: %s", treeAbsPath, readErr)
 					continue
@@ -429,4 +462,32 @@ func subscribeSQLEvents() {
 		util.SetBootDetails(msg)
 		util.ContextPushMsg(context, msg)
 	})
+
+	eventbus.Subscribe(util.EvtAttributeViewSaved, func(avID string) {
+		sql.IndexAttributeViewQueue(avID)
+	})
+}
+
+// indexAttributeViews 将所有属性视图数据写入 av_rows 和 av_cells 表，用于 SQL 查询关联数据库列值。
+func indexAttributeViews() {
+	avDir := filepath.Join(util.DataDir, "storage", "av")
+	entries, err := os.ReadDir(avDir)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			logging.LogErrorf("read dir [%s] failed: %s", avDir, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+		sql.IndexAttributeViewQueue(avID)
+	}
 }
@@ -47,6 +47,7 @@ var (
 // checkIndex 自动校验数据库索引，仅在数据同步执行完成后执行一次。
 func checkIndex() {
 	checkIndexOnce.Do(func() {
+		defer util.StartBootStage("indexVerify", true)()
 		logging.LogInfof("start checking index...")
 
 		task.AppendTask(task.DatabaseIndexFix, removeDuplicateDatabaseIndex)
@@ -282,6 +283,7 @@ func recreateTree(tree *parse.Tree, absPath string) {
 			logging.LogWarnf("rename [%s] failed: %s", from, renameErr)
 			return
 		}
+		filesys.RecordRenamedFile(from, to)
 	}
 
 	if err := filelock.Remove(absPath); nil != err {
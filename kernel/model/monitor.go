@@ -0,0 +1,148 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func init() {
+	util.DescribeMetric("siyuan_http_request_duration_seconds", "histogram", "Kernel HTTP request latency by route.")
+	util.DescribeMetric("siyuan_sql_query_duration_seconds", "histogram", "SQLite query latency.")
+	util.DescribeMetric("siyuan_sql_queue_flush_duration_seconds", "histogram", "Duration of flushing the database operation queue.")
+	util.DescribeMetric("siyuan_sync_duration_seconds", "histogram", "Data sync duration by result.")
+}
+
+// SetMonitor 设置运行监控配置。
+func SetMonitor(monitor *conf.Monitor) {
+	Conf.Monitor = monitor
+	Conf.Save()
+	util.MetricsEnabled.Store(monitor.Metrics)
+}
+
+// CheckMetrics 检查 /metrics 指标端点是否可以访问，未开启时返回 404。
+func CheckMetrics(c *gin.Context) {
+	checkMonitor(c, Conf.Monitor.Metrics)
+}
+
+// CheckPprof 检查生产环境下 /debug/pprof/ 性能剖析端点是否可以访问，未开启时返回 404。
+func CheckPprof(c *gin.Context) {
+	checkMonitor(c, Conf.Monitor.Pprof)
+}
+
+func checkMonitor(c *gin.Context, enabled bool) {
+	if !enabled {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	// 监控端点会暴露内核运行细节，仅允许管理员访问，插件和受限角色的 token 不可访问
+	if "" != GetRole(c) || "" != c.GetString(PluginContextKey) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	c.Next()
+}
+
+// ObserveRequest 记录 HTTP 请求耗时，按路由模板区分以避免标签基数膨胀。
+func ObserveRequest(c *gin.Context) {
+	if !util.MetricsEnabled.Load() {
+		c.Next()
+		return
+	}
+
+	start := time.Now()
+	c.Next()
+	route := c.FullPath()
+	if "" == route {
+		route = "unmatched"
+	}
+	util.ObserveMetric("siyuan_http_request_duration_seconds", start, "route", route, "method", c.Request.Method)
+}
+
+// ServeMetrics 以 Prometheus 文本格式输出内核运行指标。
+func ServeMetrics(c *gin.Context) {
+	buf := &bytes.Buffer{}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	writeMetric(buf, "siyuan_goroutines", "gauge", "Number of goroutines.", float64(runtime.NumGoroutine()))
+	writeMetric(buf, "siyuan_heap_alloc_bytes", "gauge", "Bytes of allocated heap objects.", float64(memStats.HeapAlloc))
+	writeMetric(buf, "siyuan_heap_sys_bytes", "gauge", "Bytes of heap memory obtained from the OS.", float64(memStats.HeapSys))
+	writeMetric(buf, "siyuan_gc_cycles_total", "counter", "Number of completed GC cycles.", float64(memStats.NumGC))
+
+	util.WriteMetricHeader(buf, "siyuan_queue_depth", "gauge", "Number of pending items in kernel queues.")
+	util.WriteMetricSample(buf, "siyuan_queue_depth", util.MetricLabels("queue", "database"), float64(sql.QueueLength()))
+	util.WriteMetricSample(buf, "siyuan_queue_depth", util.MetricLabels("queue", "transaction"), float64(len(txQueue)))
+	util.WriteMetricSample(buf, "siyuan_queue_depth", util.MetricLabels("queue", "task"), float64(task.QueueLength()))
+	util.WriteMetricSample(buf, "siyuan_queue_depth", util.MetricLabels("queue", "treeSave"), float64(filesys.PendingTreeCount()))
+
+	writeStats, readStats := sql.ConnectionStats()
+	util.WriteMetricHeader(buf, "siyuan_database_connections", "gauge", "Number of main database connections.")
+	util.WriteMetricSample(buf, "siyuan_database_connections", util.MetricLabels("pool", "write", "state", "inUse"), float64(writeStats.InUse))
+	util.WriteMetricSample(buf, "siyuan_database_connections", util.MetricLabels("pool", "read", "state", "inUse"), float64(readStats.InUse))
+	util.WriteMetricSample(buf, "siyuan_database_connections", util.MetricLabels("pool", "read", "state", "idle"), float64(readStats.Idle))
+	util.WriteMetricHeader(buf, "siyuan_database_connection_waits_total", "counter", "Number of times a query waited for a free connection.")
+	util.WriteMetricSample(buf, "siyuan_database_connection_waits_total", util.MetricLabels("pool", "write"), float64(writeStats.WaitCount))
+	util.WriteMetricSample(buf, "siyuan_database_connection_waits_total", util.MetricLabels("pool", "read"), float64(readStats.WaitCount))
+
+	stats := cache.IALStats()
+	hits, misses := sql.CacheStat()
+	stats = append(stats, &cache.Stat{Name: "block", Hits: hits, Misses: misses})
+	stats = append(stats, &cache.Stat{Name: "virtual_ref", Hits: virtualBlockRefCache.Metrics.Hits(), Misses: virtualBlockRefCache.Metrics.Misses()})
+	util.WriteMetricHeader(buf, "siyuan_cache_hits_total", "counter", "Number of cache hits.")
+	for _, stat := range stats {
+		util.WriteMetricSample(buf, "siyuan_cache_hits_total", util.MetricLabels("cache", stat.Name), float64(stat.Hits))
+	}
+	util.WriteMetricHeader(buf, "siyuan_cache_misses_total", "counter", "Number of cache misses.")
+	for _, stat := range stats {
+		util.WriteMetricSample(buf, "siyuan_cache_misses_total", util.MetricLabels("cache", stat.Name), float64(stat.Misses))
+	}
+
+	util.WriteMetricHeader(buf, "siyuan_database_size_bytes", "gauge", "Size of SQLite database files including WAL.")
+	databases := [][]string{{"main", util.DBPath}, {"history", util.HistoryDBPath}, {"asset_content", util.AssetContentDBPath}, {"blocktree", util.BlockTreeDBPath}}
+	for _, database := range databases {
+		name, p := database[0], database[1]
+		var size int64
+		for _, f := range []string{p, p + "-wal"} {
+			if info, err := os.Stat(f); nil == err {
+				size += info.Size()
+			}
+		}
+		util.WriteMetricSample(buf, "siyuan_database_size_bytes", util.MetricLabels("database", name), float64(size))
+	}
+
+	util.WriteMetrics(buf)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
+
+func writeMetric(buf *bytes.Buffer, name, typ, help string, value float64) {
+	util.WriteMetricHeader(buf, name, typ, help)
+	util.WriteMetricSample(buf, name, "", value)
+}
@@ -120,6 +120,8 @@ func RemoveBox(boxID string) (err error) {
 	}
 
 	if !isUserGuide {
+		createPreOperationSnapshot(RiskyOpRemoveNotebook, boxID)
+
 		var historyDir string
 		historyDir, err = GetHistoryDir(HistoryOpDelete)
 		if nil != err {
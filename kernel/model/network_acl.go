@@ -0,0 +1,138 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type networkACL struct {
+	enabled bool
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+}
+
+var (
+	currentNetworkACL = &networkACL{}
+	networkACLLock    = sync.RWMutex{}
+)
+
+// LoadNetworkACL 解析并启用网络访问控制列表。
+func LoadNetworkACL(acl *conf.NetworkACL) (err error) {
+	compiled, proxies, err := compileNetworkACL(acl)
+	if nil != err {
+		return
+	}
+
+	networkACLLock.Lock()
+	currentNetworkACL = compiled
+	networkACLLock.Unlock()
+	util.SetTrustedProxies(proxies)
+	return
+}
+
+// SetNetworkACL 校验并保存网络访问控制列表，如果当前请求的客户端会被拒绝访问则返回错误，避免把自己锁在外面。
+func SetNetworkACL(c *gin.Context, acl *conf.NetworkACL) (err error) {
+	compiled, proxies, err := compileNetworkACL(acl)
+	if nil != err {
+		return
+	}
+
+	// 使用新的受信任代理重新识别客户端地址
+	util.SetTrustedProxies(proxies)
+	clientIP := util.GetClientIP(c.Request)
+	if !compiled.allowed(net.ParseIP(clientIP)) {
+		LoadNetworkACL(Conf.System.NetworkACL) // 恢复原来的受信任代理
+		return errors.New("the current client [" + clientIP + "] would be denied by the network ACL")
+	}
+
+	networkACLLock.Lock()
+	currentNetworkACL = compiled
+	networkACLLock.Unlock()
+
+	Conf.System.NetworkACL = acl
+	Conf.Save()
+	return
+}
+
+func compileNetworkACL(acl *conf.NetworkACL) (ret *networkACL, proxies []*net.IPNet, err error) {
+	ret = &networkACL{enabled: acl.Enabled}
+	if ret.allow, err = util.ParseIPNets(acl.Allow); nil != err {
+		return
+	}
+	if ret.deny, err = util.ParseIPNets(acl.Deny); nil != err {
+		return
+	}
+	proxies, err = util.ParseIPNets(acl.TrustedProxies)
+	return
+}
+
+func (acl *networkACL) allowed(ip net.IP) bool {
+	if !acl.enabled {
+		return true
+	}
+	if nil == ip {
+		return false
+	}
+	if ip.IsLoopback() {
+		// 回环地址总是允许访问，避免桌面端和本机反向代理被拒绝
+		return true
+	}
+
+	for _, deny := range acl.deny {
+		if deny.Contains(ip) {
+			return false
+		}
+	}
+	if 1 > len(acl.allow) {
+		return true
+	}
+	for _, allow := range acl.allow {
+		if allow.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNetworkACL 按照网络访问控制列表检查客户端地址，作用于 API、资源文件和 WebSocket 等所有请求。
+func CheckNetworkACL(c *gin.Context) {
+	networkACLLock.RLock()
+	acl := currentNetworkACL
+	networkACLLock.RUnlock()
+
+	if !acl.enabled {
+		c.Next()
+		return
+	}
+
+	clientIP := util.GetClientIP(c.Request)
+	if !acl.allowed(net.ParseIP(clientIP)) {
+		logging.LogWarnf("network ACL denied [ip=%s, uri=%s]", clientIP, c.Request.RequestURI)
+		c.AbortWithStatusJSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Access denied by network ACL"})
+		return
+	}
+	c.Next()
+}
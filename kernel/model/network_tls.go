@@ -0,0 +1,406 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"golang.org/x/crypto/acme"
+)
+
+// NetworkTLSStatus 描述了 ACME 证书的当前状态。
+type NetworkTLSStatus struct {
+	Domains   []string `json:"domains"`
+	NotBefore int64    `json:"notBefore"` // 证书生效时间（毫秒）
+	NotAfter  int64    `json:"notAfter"`  // 证书过期时间（毫秒）
+	Issuer    string   `json:"issuer"`
+	Obtaining bool     `json:"obtaining"` // 是否正在申请证书
+	LastError string   `json:"lastError"` // 最近一次申请失败的原因
+}
+
+const networkTLSRenewBefore = 30 * 24 * time.Hour
+
+var (
+	networkTLSCert       *tls.Certificate
+	networkTLSCertLock   = sync.RWMutex{}
+	networkTLSObtainLock = sync.Mutex{}
+	networkTLSStatus     = &NetworkTLSStatus{Domains: []string{}}
+	networkTLSRenewOnce  = sync.Once{}
+
+	acmeHTTP01Responses = sync.Map{} // <path, keyAuthorization>
+)
+
+// NetworkTLSEnabled 判断是否启用了 ACME 证书和 HTTPS 服务。
+func NetworkTLSEnabled() bool {
+	tlsConf := Conf.System.NetworkTLS
+	return nil != tlsConf && tlsConf.Enabled && 0 < len(tlsConf.Domains)
+}
+
+// StartNetworkTLS 加载已有证书，证书不存在或者即将过期时申请新证书，并启动定时续期。
+func StartNetworkTLS() {
+	if err := loadNetworkTLSCert(); nil != err && !os.IsNotExist(err) {
+		logging.LogWarnf("load TLS certificate failed: %s", err)
+	}
+	if needRenewNetworkTLSCert() {
+		if err := ObtainNetworkTLSCert(); nil != err {
+			logging.LogErrorf("obtain TLS certificate failed: %s", err)
+		}
+	}
+
+	networkTLSRenewOnce.Do(func() {
+		go func() {
+			for range time.Tick(12 * time.Hour) {
+				if !NetworkTLSEnabled() || !needRenewNetworkTLSCert() {
+					continue
+				}
+				if err := ObtainNetworkTLSCert(); nil != err {
+					logging.LogErrorf("renew TLS certificate failed: %s", err)
+				}
+			}
+		}()
+	})
+}
+
+// GetNetworkTLSCertificate 用于 tls.Config.GetCertificate，证书续期后无需重启服务。
+func GetNetworkTLSCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	networkTLSCertLock.RLock()
+	defer networkTLSCertLock.RUnlock()
+	if nil == networkTLSCert {
+		return nil, errors.New("TLS certificate is not ready")
+	}
+	return networkTLSCert, nil
+}
+
+// GetNetworkTLSStatus 返回证书状态。
+func GetNetworkTLSStatus() (ret *NetworkTLSStatus) {
+	networkTLSCertLock.RLock()
+	defer networkTLSCertLock.RUnlock()
+	status := *networkTLSStatus
+	return &status
+}
+
+// ACMEHTTPHandler 处理 http-01 验证请求，其他请求跳转到 HTTPS。
+func ACMEHTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+			if keyAuth, ok := acmeHTTP01Responses.Load(r.URL.Path); ok {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte(keyAuth.(string)))
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); nil == err {
+			host = h
+		}
+		if port := Conf.System.NetworkTLS.HTTPSPort; 443 != port {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// ObtainNetworkTLSCert 通过 ACME 申请证书。
+func ObtainNetworkTLSCert() (err error) {
+	if !NetworkTLSEnabled() {
+		return errors.New("TLS is not enabled")
+	}
+
+	networkTLSObtainLock.Lock()
+	defer networkTLSObtainLock.Unlock()
+
+	setNetworkTLSObtaining(true, nil)
+	defer func() { setNetworkTLSObtaining(false, err) }()
+
+	tlsConf := Conf.System.NetworkTLS
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	accountKey, err := loadACMEAccountKey()
+	if nil != err {
+		return
+	}
+	client := &acme.Client{Key: accountKey, DirectoryURL: tlsConf.DirectoryURL}
+	if "" == client.DirectoryURL {
+		client.DirectoryURL = acme.LetsEncryptURL
+	}
+
+	account := &acme.Account{}
+	if "" != tlsConf.Email {
+		account.Contact = []string{"mailto:" + tlsConf.Email}
+	}
+	if _, err = client.Register(ctx, account, acme.AcceptTOS); nil != err && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(tlsConf.Domains...))
+	if nil != err {
+		return
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err = performACMEAuthorization(ctx, client, authzURL, tlsConf); nil != err {
+			return
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); nil != err {
+		return
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		return
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: tlsConf.Domains[0]},
+		DNSNames: tlsConf.Domains,
+	}, certKey)
+	if nil != err {
+		return
+	}
+	ders, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if nil != err {
+		return
+	}
+
+	if err = saveNetworkTLSCert(ders, certKey); nil != err {
+		return
+	}
+	if err = loadNetworkTLSCert(); nil != err {
+		return
+	}
+	logging.LogInfof("obtained TLS certificate for [%s]", strings.Join(tlsConf.Domains, ", "))
+	return
+}
+
+func performACMEAuthorization(ctx context.Context, client *acme.Client, authzURL string, tlsConf *conf.NetworkTLS) (err error) {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if nil != err {
+		return
+	}
+	if acme.StatusValid == authz.Status {
+		return
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if tlsConf.Challenge == c.Type {
+			challenge = c
+			break
+		}
+	}
+	if nil == challenge {
+		return fmt.Errorf("challenge [%s] is not offered for [%s]", tlsConf.Challenge, authz.Identifier.Value)
+	}
+
+	switch challenge.Type {
+	case conf.ACMEChallengeHTTP01:
+		keyAuth, keyAuthErr := client.HTTP01ChallengeResponse(challenge.Token)
+		if nil != keyAuthErr {
+			return keyAuthErr
+		}
+		challengePath := client.HTTP01ChallengePath(challenge.Token)
+		acmeHTTP01Responses.Store(challengePath, keyAuth)
+		defer acmeHTTP01Responses.Delete(challengePath)
+	case conf.ACMEChallengeDNS01:
+		record, recordErr := client.DNS01ChallengeRecord(challenge.Token)
+		if nil != recordErr {
+			return recordErr
+		}
+		name := "_acme-challenge." + strings.TrimPrefix(authz.Identifier.Value, "*.")
+		hook := GetACMEDNSHook()
+		if err = runACMEDNSHook(ctx, hook, "present", name, record); nil != err {
+			return
+		}
+		defer func() {
+			if cleanupErr := runACMEDNSHook(context.Background(), hook, "cleanup", name, record); nil != cleanupErr {
+				logging.LogWarnf("cleanup DNS record [%s] failed: %s", name, cleanupErr)
+			}
+		}()
+		time.Sleep(time.Duration(tlsConf.DNSWait) * time.Second)
+	}
+
+	if _, err = client.Accept(ctx, challenge); nil != err {
+		return
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return
+}
+
+// GetACMEDNSHook 返回 dns-01 验证使用的 DNS hook，环境变量 SIYUAN_ACME_DNS_HOOK 优先于配置文件。
+//
+// DNS hook 会被内核直接执行，所以只能通过配置文件或者环境变量设置，不能通过 API 设置。
+func GetACMEDNSHook() string {
+	if hook := strings.TrimSpace(os.Getenv("SIYUAN_ACME_DNS_HOOK")); "" != hook {
+		return hook
+	}
+	return Conf.System.NetworkTLS.DNSHook
+}
+
+// CheckACMEDNSHook 检查 DNS hook 是否是已经存在的可执行文件的绝对路径。
+func CheckACMEDNSHook(hook string) error {
+	if "" == hook {
+		return errors.New("DNS hook is not configured")
+	}
+	if !filepath.IsAbs(hook) {
+		return fmt.Errorf("DNS hook [%s] must be an absolute path", hook)
+	}
+
+	info, err := os.Stat(hook)
+	if nil != err {
+		return fmt.Errorf("DNS hook [%s] is not found: %s", hook, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("DNS hook [%s] is not a regular file", hook)
+	}
+	if !gulu.OS.IsWindows() && 0 == info.Mode().Perm()&0111 {
+		return fmt.Errorf("DNS hook [%s] is not executable", hook)
+	}
+	return nil
+}
+
+func runACMEDNSHook(ctx context.Context, hook, action, name, value string) error {
+	if err := CheckACMEDNSHook(hook); nil != err {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, hook, action, name, value)
+	gulu.CmdAttr(cmd)
+	if output, err := cmd.CombinedOutput(); nil != err {
+		return fmt.Errorf("run DNS hook [%s %s] failed: %s, %s", hook, action, err, output)
+	}
+	return nil
+}
+
+func needRenewNetworkTLSCert() bool {
+	networkTLSCertLock.RLock()
+	defer networkTLSCertLock.RUnlock()
+	if nil == networkTLSCert || nil == networkTLSCert.Leaf {
+		return true
+	}
+	for _, domain := range Conf.System.NetworkTLS.Domains {
+		if !gulu.Str.Contains(domain, networkTLSCert.Leaf.DNSNames) {
+			return true
+		}
+	}
+	return time.Until(networkTLSCert.Leaf.NotAfter) < networkTLSRenewBefore
+}
+
+func setNetworkTLSObtaining(obtaining bool, err error) {
+	networkTLSCertLock.Lock()
+	defer networkTLSCertLock.Unlock()
+	networkTLSStatus.Obtaining = obtaining
+	if !obtaining {
+		networkTLSStatus.LastError = ""
+		if nil != err {
+			networkTLSStatus.LastError = err.Error()
+		}
+	}
+}
+
+func acmeDir() string {
+	return filepath.Join(util.ConfDir, "acme")
+}
+
+func loadACMEAccountKey() (ret crypto.Signer, err error) {
+	keyPath := filepath.Join(acmeDir(), "account.key")
+	if data, readErr := os.ReadFile(keyPath); nil == readErr {
+		block, _ := pem.Decode(data)
+		if nil == block {
+			return nil, errors.New("invalid ACME account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if nil != err {
+		return
+	}
+	if err = os.MkdirAll(acmeDir(), 0700); nil != err {
+		return
+	}
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); nil != err {
+		return
+	}
+	return key, nil
+}
+
+func saveNetworkTLSCert(ders [][]byte, key *ecdsa.PrivateKey) (err error) {
+	if err = os.MkdirAll(acmeDir(), 0700); nil != err {
+		return
+	}
+
+	var certPEM []byte
+	for _, der := range ders {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if nil != err {
+		return
+	}
+	if err = os.WriteFile(filepath.Join(acmeDir(), "cert.pem"), certPEM, 0600); nil != err {
+		return
+	}
+	return os.WriteFile(filepath.Join(acmeDir(), "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func loadNetworkTLSCert() (err error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(acmeDir(), "cert.pem"), filepath.Join(acmeDir(), "key.pem"))
+	if nil != err {
+		return
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); nil != err {
+		return
+	}
+
+	networkTLSCertLock.Lock()
+	defer networkTLSCertLock.Unlock()
+	networkTLSCert = &cert
+	networkTLSStatus.Domains = cert.Leaf.DNSNames
+	networkTLSStatus.NotBefore = cert.Leaf.NotBefore.UnixMilli()
+	networkTLSStatus.NotAfter = cert.Leaf.NotAfter.UnixMilli()
+	networkTLSStatus.Issuer = cert.Leaf.Issuer.CommonName
+	return
+}
@@ -1,10 +1,14 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/sql"
@@ -15,7 +19,7 @@ import (
 func OCRAssetsJob() {
 	util.WaitForTesseractInit()
 
-	if !util.TesseractEnabled {
+	if !util.OCREnabled {
 		return
 	}
 
@@ -23,23 +27,30 @@ func OCRAssetsJob() {
 }
 
 func autoOCRAssets() {
-	if !util.TesseractEnabled {
+	if !util.OCREnabled {
 		return
 	}
 
 	defer logging.Recover()
 
-	assetsPath := util.GetDataAssetsAbsPath()
-	assets := getUnOCRAssetsAbsPaths()
-	if 0 < len(assets) {
-		for i, assetAbsPath := range assets {
-			text := util.Tesseract(assetAbsPath)
+	// 优先处理重新识别队列
+	const maxCount = 8 // 一次任务中最多处理 8 张图片，防止长时间占用系统资源
+	reQueue := util.PopOCRReQueue(maxCount)
+	for _, s := range reQueue {
+		failed := !ocrAsset(s.Path, s.Langs, true)
+		util.OCRReQueueDone(s.Path, failed)
+	}
+
+	if count := maxCount - len(reQueue); 0 < count {
+		assets := getUnOCRAssetsAbsPaths()
+		if count < len(assets) {
+			assets = assets[:count]
+		}
+		assetsPath := util.GetDataAssetsAbsPath()
+		for _, assetAbsPath := range assets {
 			p := strings.TrimPrefix(assetAbsPath, assetsPath)
 			p = "assets" + filepath.ToSlash(p)
-			util.SetAssetText(p, text)
-			if 7 <= i { // 一次任务中最多处理 7 张图片，防止长时间占用系统资源
-				break
-			}
+			ocrAsset(p, nil, false)
 		}
 	}
 
@@ -54,6 +65,115 @@ func autoOCRAssets() {
 	util.NodeOCRQueue = nil
 }
 
+// ocrAsset 识别资源文件并记录识别状态，force 为 true 时识别失败会保留原有的识别结果并重建引用该资源文件的块索引。
+func ocrAsset(p string, langs []string, force bool) (ok bool) {
+	util.SetOCRStatus(p, util.OCRStatusProcessing, "")
+	absPath := filepath.Join(util.GetDataAssetsAbsPath(), strings.TrimPrefix(p, "assets"))
+	text, err := util.OCRWithErr(absPath, langs)
+	if nil != err {
+		status := util.OCRStatusFailed
+		if errors.Is(err, util.ErrOCRSkipped) {
+			status = util.OCRStatusSkipped
+		}
+		util.SetOCRStatus(p, status, err.Error())
+		if !force {
+			util.SetAssetText(p, "") // 标记为已处理，避免反复识别
+		}
+		return
+	}
+
+	util.SetAssetText(p, text)
+	util.SetOCRStatus(p, util.OCRStatusDone, "")
+	if force {
+		util.NodeOCRQueueLock.Lock()
+		for _, asset := range sql.QueryAssetsByPath(p) {
+			util.NodeOCRQueue = append(util.NodeOCRQueue, asset.BlockID)
+		}
+		util.NodeOCRQueueLock.Unlock()
+	}
+	return true
+}
+
+// ReOCRAssets 将指定的资源文件加入重新识别队列，langs 为空时使用默认的语言模型。
+func ReOCRAssets(paths, langs []string) (count int, err error) {
+	if !util.OCREnabled {
+		err = errors.New("OCR is disabled")
+		return
+	}
+
+	var assets []string
+	for _, p := range paths {
+		if !util.IsTesseractExtractable(p) {
+			continue
+		}
+		if !strings.HasPrefix(p, "assets/") {
+			err = fmt.Errorf("invalid asset path [%s]", p)
+			return
+		}
+		absPath := filepath.Join(util.GetDataAssetsAbsPath(), strings.TrimPrefix(p, "assets"))
+		if !filelock.IsExist(absPath) {
+			err = fmt.Errorf("asset [%s] not found", p)
+			return
+		}
+		assets = append(assets, p)
+	}
+	util.PushOCRReQueue(assets, langs)
+	count = len(assets)
+	return
+}
+
+// ReOCRAllAssets 将工作空间中所有可识别的资源文件加入重新识别队列，用于更换语言模型后重新识别。
+func ReOCRAllAssets(langs []string) (count int, err error) {
+	if !util.OCREnabled {
+		err = errors.New("OCR is disabled")
+		return
+	}
+
+	var assets []string
+	for _, asset := range cache.GetAssets() {
+		if !util.IsTesseractExtractable(asset.Path) {
+			continue
+		}
+		assets = append(assets, asset.Path)
+	}
+	util.PushOCRReQueue(assets, langs)
+	count = len(assets)
+	return
+}
+
+type OCRQueueStatus struct {
+	Enabled      bool                   `json:"enabled"`      // 是否启用 OCR
+	Provider     string                 `json:"provider"`     // OCR 服务提供者
+	ReQueueLen   int                    `json:"reQueueLen"`   // 等待重新识别的资源文件数量
+	UnOCRLen     int                    `json:"unOCRLen"`     // 尚未识别的资源文件数量
+	NodeQueueLen int                    `json:"nodeQueueLen"` // 等待刷新到数据库的块数量
+	Progress     *util.OCRProgress      `json:"progress"`     // 重新识别进度
+	Assets       []*util.OCRAssetStatus `json:"assets"`       // 资源文件识别状态
+}
+
+// GetOCRQueueStatus 返回 OCR 队列状态，path 不为空时仅返回该资源文件的状态，status 不为空时按状态过滤。
+func GetOCRQueueStatus(path, status string) (ret *OCRQueueStatus) {
+	ret = &OCRQueueStatus{Enabled: util.OCREnabled, Provider: util.GetOCRProviderName()}
+	ret.ReQueueLen, ret.NodeQueueLen, ret.Progress = util.GetOCRQueueStat()
+	ret.UnOCRLen = len(getUnOCRAssetsAbsPaths())
+	if "" != path {
+		ret.Assets = []*util.OCRAssetStatus{util.GetOCRStatus(path)}
+		return
+	}
+	ret.Assets = util.GetOCRStatuses(status)
+	return
+}
+
+func init() {
+	subscribeOCREvents()
+}
+
+func subscribeOCREvents() {
+	eventbus.Subscribe(util.EvtOCRProgress, func(progress *util.OCRProgress) {
+		util.BroadcastByType("main", "ocrProgress", 0, "", progress)
+	})
+}
+
 func getUnOCRAssetsAbsPaths() (ret []string) {
 	var assetsPaths []string
 	assets := cache.GetAssets()
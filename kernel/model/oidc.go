@@ -0,0 +1,479 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+var (
+	oidcDiscoveryCache  *oidcDiscovery
+	oidcDiscoveryIssuer string
+	oidcDiscoveryLock   = sync.Mutex{}
+)
+
+// OIDCEnabled 判断是否启用了 OIDC 单点登录。
+func OIDCEnabled() bool {
+	return nil != Conf.OIDC && Conf.OIDC.Enabled && "" != Conf.OIDC.Issuer && "" != Conf.OIDC.ClientID
+}
+
+// IsOIDCSessionValid 判断会话是否为有效的 OIDC 登录会话。
+func IsOIDCSessionValid(workspaceSession *util.WorkspaceSession) bool {
+	return OIDCEnabled() && "" != workspaceSession.OIDCSubject && time.Now().UnixMilli() < workspaceSession.OIDCExpired
+}
+
+// oidcContextRole 将 OIDC 角色转换为请求上下文中的角色，管理员不受限制。
+func oidcContextRole(role string) string {
+	if conf.OIDCRoleAdmin == role {
+		return ""
+	}
+	return role
+}
+
+func OIDCLogin(c *gin.Context) {
+	if !OIDCEnabled() {
+		c.JSON(http.StatusNotFound, map[string]interface{}{"code": -1, "msg": "OIDC is not enabled"})
+		return
+	}
+
+	discovery, err := getOIDCDiscovery()
+	if nil != err {
+		logging.LogErrorf("get OIDC discovery failed: %s", err)
+		c.JSON(http.StatusBadGateway, map[string]interface{}{"code": -1, "msg": err.Error()})
+		return
+	}
+
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	workspaceSession.OIDCState = gulu.Rand.String(32)
+	workspaceSession.OIDCNonce = gulu.Rand.String(32)
+	workspaceSession.OIDCVerifier = gulu.Rand.String(64)
+	workspaceSession.OIDCTo = oidcRedirectTo(c.Query("to"))
+	if err = session.Save(c); nil != err {
+		logging.LogErrorf("save session failed: " + err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(workspaceSession.OIDCVerifier))
+	scopes := []string{"openid"}
+	for _, scope := range Conf.OIDC.Scopes {
+		if "" != scope && !gulu.Str.Contains(scope, scopes) {
+			scopes = append(scopes, scope)
+		}
+	}
+	queryParams := url.Values{}
+	queryParams.Set("response_type", "code")
+	queryParams.Set("client_id", Conf.OIDC.ClientID)
+	queryParams.Set("redirect_uri", oidcRedirectURL(c))
+	queryParams.Set("scope", strings.Join(scopes, " "))
+	queryParams.Set("state", workspaceSession.OIDCState)
+	queryParams.Set("nonce", workspaceSession.OIDCNonce)
+	queryParams.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	queryParams.Set("code_challenge_method", "S256")
+
+	location := discovery.AuthorizationEndpoint
+	if strings.Contains(location, "?") {
+		location += "&" + queryParams.Encode()
+	} else {
+		location += "?" + queryParams.Encode()
+	}
+	c.Redirect(http.StatusFound, location)
+}
+
+func OIDCCallback(c *gin.Context) {
+	if !OIDCEnabled() {
+		c.JSON(http.StatusNotFound, map[string]interface{}{"code": -1, "msg": "OIDC is not enabled"})
+		return
+	}
+
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	state, nonce, verifier, to := workspaceSession.OIDCState, workspaceSession.OIDCNonce, workspaceSession.OIDCVerifier, workspaceSession.OIDCTo
+	workspaceSession.OIDCState, workspaceSession.OIDCNonce, workspaceSession.OIDCVerifier, workspaceSession.OIDCTo = "", "", "", ""
+
+	fail := func(status int, msg string) {
+		logging.LogWarnf("OIDC login failed [ip=%s]: %s", util.GetRemoteAddr(c.Request), msg)
+		if err := session.Save(c); nil != err {
+			logging.LogErrorf("save session failed: " + err.Error())
+		}
+		c.JSON(status, map[string]interface{}{"code": -1, "msg": "OIDC login failed: " + msg})
+	}
+
+	if errMsg := c.Query("error"); "" != errMsg {
+		fail(http.StatusUnauthorized, errMsg+" "+c.Query("error_description"))
+		return
+	}
+	if "" == state || state != c.Query("state") {
+		fail(http.StatusBadRequest, "invalid state")
+		return
+	}
+	code := c.Query("code")
+	if "" == code {
+		fail(http.StatusBadRequest, "missing code")
+		return
+	}
+
+	discovery, err := getOIDCDiscovery()
+	if nil != err {
+		fail(http.StatusBadGateway, err.Error())
+		return
+	}
+
+	claims, accessToken, err := exchangeOIDCCode(c, discovery, code, verifier)
+	if nil != err {
+		fail(http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err = verifyOIDCClaims(claims, discovery.Issuer, nonce); nil != err {
+		fail(http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	groups := oidcClaimStrings(claims[Conf.OIDC.GroupsClaim])
+	if nil == claims[Conf.OIDC.GroupsClaim] && "" != discovery.UserinfoEndpoint && "" != accessToken {
+		if userinfo, userinfoErr := getOIDCUserinfo(discovery.UserinfoEndpoint, accessToken); nil != userinfoErr {
+			logging.LogWarnf("get OIDC userinfo failed: %s", userinfoErr)
+		} else if sub, _ := userinfo["sub"].(string); sub == claims["sub"] {
+			groups = oidcClaimStrings(userinfo[Conf.OIDC.GroupsClaim])
+		}
+	}
+
+	role, ok := mapOIDCRole(groups)
+	if !ok {
+		fail(http.StatusForbidden, "no role mapped for groups ["+strings.Join(groups, ", ")+"]")
+		return
+	}
+
+	workspaceSession.OIDCSubject, _ = claims["sub"].(string)
+	workspaceSession.OIDCName = oidcUserName(claims)
+	workspaceSession.OIDCRole = role
+	workspaceSession.OIDCExpired = time.Now().Add(time.Duration(Conf.OIDC.SessionTTL) * time.Hour).UnixMilli()
+	workspaceSession.SessionID = newUIAuthSession(c, workspaceSession.OIDCName)
+	util.WrongAuthCount = 0
+	if err = session.Save(c); nil != err {
+		logging.LogErrorf("save session failed: " + err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfof("OIDC auth success [user=%s, role=%s, ip=%s]", workspaceSession.OIDCName, role, util.GetRemoteAddr(c.Request))
+	if "" == to {
+		to = "/"
+	}
+	c.Redirect(http.StatusFound, to)
+}
+
+// GetOIDCSession 返回当前请求的 OIDC 登录会话，未通过 OIDC 登录时返回 nil。
+func GetOIDCSession(c *gin.Context) *util.WorkspaceSession {
+	workspaceSession := util.GetWorkspaceSession(util.GetSession(c))
+	if !IsOIDCSessionValid(workspaceSession) {
+		return nil
+	}
+	return workspaceSession
+}
+
+// MintOIDCToken 为 OIDC 会话签发带角色的 API token，角色和会话一致。
+func MintOIDCToken(workspaceSession *util.WorkspaceSession, memo string) (ret *conf.ScopedToken) {
+	if "" == memo {
+		memo = "OIDC " + workspaceSession.OIDCName
+	}
+	ret = &conf.ScopedToken{
+		Token:   gulu.Rand.String(32),
+		Role:    oidcContextRole(workspaceSession.OIDCRole),
+		Memo:    memo,
+		Subject: workspaceSession.OIDCSubject,
+		Expired: time.Now().Add(time.Duration(Conf.OIDC.TokenTTL) * time.Hour).UnixMilli(),
+	}
+
+	now := time.Now().UnixMilli()
+	var scopedTokens []*conf.ScopedToken
+	for _, scopedToken := range Conf.Api.ScopedTokens {
+		if 0 < scopedToken.Expired && scopedToken.Expired <= now {
+			continue
+		}
+		scopedTokens = append(scopedTokens, scopedToken)
+	}
+	Conf.Api.ScopedTokens = append(scopedTokens, ret)
+	Conf.Save()
+	logging.LogInfof("minted API token for OIDC user [%s]", workspaceSession.OIDCName)
+	return
+}
+
+// GetOIDCTokens 返回 OIDC 用户签发的 API token，token 值仅保留末尾 4 位。
+func GetOIDCTokens(subject string) (ret []*conf.ScopedToken) {
+	ret = []*conf.ScopedToken{}
+	now := time.Now().UnixMilli()
+	for _, scopedToken := range Conf.Api.ScopedTokens {
+		if subject != scopedToken.Subject || (0 < scopedToken.Expired && scopedToken.Expired <= now) {
+			continue
+		}
+		masked := *scopedToken
+		if 4 < len(masked.Token) {
+			masked.Token = strings.Repeat("*", len(masked.Token)-4) + masked.Token[len(masked.Token)-4:]
+		}
+		ret = append(ret, &masked)
+	}
+	return
+}
+
+// RevokeOIDCTokens 吊销 OIDC 用户签发的 API token，tokenSuffix 为空时吊销该用户的全部 token。
+func RevokeOIDCTokens(subject, tokenSuffix string) (count int) {
+	var scopedTokens []*conf.ScopedToken
+	for _, scopedToken := range Conf.Api.ScopedTokens {
+		if "" != subject && subject == scopedToken.Subject && ("" == tokenSuffix || strings.HasSuffix(scopedToken.Token, tokenSuffix)) {
+			count++
+			continue
+		}
+		scopedTokens = append(scopedTokens, scopedToken)
+	}
+	if 0 < count {
+		Conf.Api.ScopedTokens = scopedTokens
+		Conf.Save()
+	}
+	return
+}
+
+func mapOIDCRole(groups []string) (role string, ok bool) {
+	for _, mapping := range Conf.OIDC.RoleMappings {
+		if "" == mapping.Role {
+			continue
+		}
+		if "*" == mapping.Group || gulu.Str.Contains(mapping.Group, groups) {
+			return mapping.Role, true
+		}
+	}
+	if "" != Conf.OIDC.DefaultRole {
+		return Conf.OIDC.DefaultRole, true
+	}
+	return "", false
+}
+
+func getOIDCDiscovery() (ret *oidcDiscovery, err error) {
+	issuer := strings.TrimSuffix(Conf.OIDC.Issuer, "/")
+
+	oidcDiscoveryLock.Lock()
+	defer oidcDiscoveryLock.Unlock()
+	if nil != oidcDiscoveryCache && issuer == oidcDiscoveryIssuer {
+		return oidcDiscoveryCache, nil
+	}
+
+	resp, err := oidcHTTPClient().Get(issuer + "/.well-known/openid-configuration")
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		err = fmt.Errorf("get OIDC discovery failed [%d]", resp.StatusCode)
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	if nil != err {
+		return
+	}
+	ret = &oidcDiscovery{}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		return
+	}
+	if strings.TrimSuffix(ret.Issuer, "/") != issuer || "" == ret.AuthorizationEndpoint || "" == ret.TokenEndpoint {
+		err = errors.New("invalid OIDC discovery document")
+		return
+	}
+
+	oidcDiscoveryCache = ret
+	oidcDiscoveryIssuer = issuer
+	return
+}
+
+// exchangeOIDCCode 使用授权码换取 ID token。
+//
+// ID token 直接通过 TLS 从 token 端点获取，按照 OpenID Connect Core 3.1.3.7 可以用 TLS 服务端校验代替签名校验。
+func exchangeOIDCCode(c *gin.Context, discovery *oidcDiscovery, code, verifier string) (claims map[string]interface{}, accessToken string, err error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", oidcRedirectURL(c))
+	form.Set("client_id", Conf.OIDC.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if nil != err {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if "" != Conf.OIDC.ClientSecret {
+		req.SetBasicAuth(url.QueryEscape(Conf.OIDC.ClientID), url.QueryEscape(Conf.OIDC.ClientSecret))
+	}
+
+	resp, err := oidcHTTPClient().Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if nil != err {
+		return
+	}
+	if http.StatusOK != resp.StatusCode {
+		err = fmt.Errorf("exchange code failed [%d]: %s", resp.StatusCode, data)
+		return
+	}
+
+	tokenResp := map[string]interface{}{}
+	if err = gulu.JSON.UnmarshalJSON(data, &tokenResp); nil != err {
+		return
+	}
+	accessToken, _ = tokenResp["access_token"].(string)
+	idToken, _ := tokenResp["id_token"].(string)
+	parts := strings.Split(idToken, ".")
+	if 3 != len(parts) {
+		err = errors.New("invalid ID token")
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if nil != err {
+		return
+	}
+	claims = map[string]interface{}{}
+	err = gulu.JSON.UnmarshalJSON(payload, &claims)
+	return
+}
+
+func verifyOIDCClaims(claims map[string]interface{}, issuer, nonce string) error {
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(issuer, "/") {
+		return errors.New("issuer mismatch")
+	}
+	if !gulu.Str.Contains(Conf.OIDC.ClientID, oidcClaimStrings(claims["aud"])) {
+		return errors.New("audience mismatch")
+	}
+	if azp, _ := claims["azp"].(string); "" != azp && Conf.OIDC.ClientID != azp {
+		return errors.New("authorized party mismatch")
+	}
+	exp, _ := claims["exp"].(float64)
+	if int64(exp) <= time.Now().Unix() {
+		return errors.New("ID token expired")
+	}
+	if claimNonce, _ := claims["nonce"].(string); "" == nonce || claimNonce != nonce {
+		return errors.New("nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); "" == sub {
+		return errors.New("missing subject")
+	}
+	return nil
+}
+
+func getOIDCUserinfo(endpoint, accessToken string) (ret map[string]interface{}, err error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if nil != err {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := oidcHTTPClient().Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		err = fmt.Errorf("get userinfo failed [%d]", resp.StatusCode)
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	if nil != err {
+		return
+	}
+	ret = map[string]interface{}{}
+	err = gulu.JSON.UnmarshalJSON(data, &ret)
+	return
+}
+
+func oidcClaimStrings(claim interface{}) (ret []string) {
+	switch v := claim.(type) {
+	case string:
+		ret = strings.FieldsFunc(v, func(r rune) bool { return ',' == r || ' ' == r })
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return
+}
+
+func oidcUserName(claims map[string]interface{}) string {
+	for _, key := range []string{"preferred_username", "name", "email", "sub"} {
+		if name, _ := claims[key].(string); "" != name {
+			return name
+		}
+	}
+	return ""
+}
+
+func oidcRedirectURL(c *gin.Context) string {
+	if "" != Conf.OIDC.RedirectURL {
+		return Conf.OIDC.RedirectURL
+	}
+
+	scheme := "http"
+	if util.SSL || nil != c.Request.TLS || "https" == c.GetHeader("X-Forwarded-Proto") {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + util.BasePath + "/api/oidc/callback"
+}
+
+// oidcRedirectTo 仅允许跳转到站内地址，避免开放重定向。
+func oidcRedirectTo(to string) string {
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") || strings.Contains(to, "\\") {
+		return "/"
+	}
+	for _, r := range to {
+		// 浏览器会忽略地址中的制表符和换行符，比如 /\t/evil.com 会被当作 //evil.com
+		if 0x20 > r || 0x7f == r {
+			return "/"
+		}
+	}
+	return to
+}
+
+func oidcHTTPClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second, Transport: httpclient.NewTransport(false)}
+}
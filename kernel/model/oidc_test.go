@@ -0,0 +1,48 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import "testing"
+
+func TestOIDCRedirectTo(t *testing.T) {
+	cases := []struct {
+		to       string
+		expected string
+	}{
+		{"/", "/"},
+		{"/stage/build/desktop/", "/stage/build/desktop/"},
+		{"/?id=20240101000000-abcdefg", "/?id=20240101000000-abcdefg"},
+		{"", "/"},
+		{"stage/build/desktop/", "/"},
+		{"https://evil.com", "/"},
+		{"http://evil.com/path", "/"},
+		{"javascript:alert(1)", "/"},
+		{"//evil.com", "/"},
+		{"//evil.com/path", "/"},
+		{"/\\evil.com", "/"},
+		{"\\\\evil.com", "/"},
+		{"/\t/evil.com", "/"},
+		{"/\n/evil.com", "/"},
+		{"/\r\n/evil.com", "/"},
+		{"/path\\..\\..\\evil", "/"},
+	}
+	for _, c := range cases {
+		if ret := oidcRedirectTo(c.to); c.expected != ret {
+			t.Errorf("redirect [%q]: expected [%s], got [%s]", c.to, c.expected, ret)
+		}
+	}
+}
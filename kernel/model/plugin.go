@@ -36,9 +36,10 @@ type Petal struct {
 	Enabled      bool   `json:"enabled"`      // Whether enabled
 	Incompatible bool   `json:"incompatible"` // Whether incompatible
 
-	JS   string                 `json:"js"`   // JS code
-	CSS  string                 `json:"css"`  // CSS code
-	I18n map[string]interface{} `json:"i18n"` // i18n text
+	JS    string                 `json:"js"`    // JS code
+	CSS   string                 `json:"css"`   // CSS code
+	I18n  map[string]interface{} `json:"i18n"`  // i18n text
+	Token string                 `json:"token"` // Plugin-scoped API token, regenerated on every boot
 }
 
 func SetPetalEnabled(name string, enabled bool, frontend string) (ret *Petal, err error) {
@@ -69,6 +70,11 @@ func SetPetalEnabled(name string, enabled bool, frontend string) (ret *Petal, er
 
 	savePetals(petals)
 	loadCode(ret)
+	go func() {
+		if reloadErr := ReloadPluginBackend(name); nil != reloadErr {
+			logging.LogErrorf("reload plugin [%s] backend failed: %s", name, reloadErr)
+		}
+	}()
 	return
 }
 
@@ -118,6 +124,7 @@ func loadCode(petal *Petal) {
 		return
 	}
 	petal.JS = string(data)
+	petal.Token = getPluginToken(petal.Name)
 
 	cssPath := filepath.Join(pluginDir, "index.css")
 	if filelock.IsExist(cssPath) {
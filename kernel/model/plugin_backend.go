@@ -0,0 +1,522 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/bazaar"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// 插件后端可以申请的宿主能力
+const (
+	PluginCapBlockRead = "block.read" // 读取块
+	PluginCapSQLQuery  = "sql.query"  // 执行只读 SQL 查询
+	PluginCapScheduler = "scheduler"  // 注册定时任务
+	PluginCapEvent     = "event"      // 订阅内核事件
+	PluginCapRoute     = "route"      // 注册 /api/plugin/{name}/ 下的内核 API
+)
+
+const (
+	pluginBackendCallTimeout = 10 * time.Second
+	pluginBackendMemoryPages = 1024 // 64MB
+)
+
+// PluginBackend 描述了一个已加载的插件后端（WASM）实例。
+//
+// 插件后端是一个 WASI reactor 模块，需要导出 malloc(size) 用于宿主写入数据，可选导出：
+//
+//	on_load()                   加载完成后调用
+//	on_unload()                 卸载前调用
+//	on_event(ptr, len)          收到订阅的内核事件，参数为 JSON
+//	on_schedule(ptr, len)       定时任务触发，参数为 JSON
+//	on_request(ptr, len) -> u64 处理注册的 API 请求，参数和返回值（ptr << 32 | len）都是 JSON
+//
+// 宿主在 siyuan 模块中提供 log(ptr, len) 和 call(namePtr, nameLen, argPtr, argLen) -> (ptr << 32 | len)，
+// call 根据名称调用宿主 API，参数和返回值都是 JSON，返回值格式与内核 API 一致：{"code": 0, "msg": "", "data": ...}。
+type PluginBackend struct {
+	Name         string           `json:"name"`
+	Capabilities []string         `json:"capabilities"`
+	Schedulers   map[string]int64 `json:"schedulers"` // 定时任务名称 -> 间隔（秒）
+	Topics       []string         `json:"topics"`     // 订阅的事件
+	Routes       []*PluginRoute   `json:"routes"`     // 注册的 API
+	Loaded       int64            `json:"loaded"`
+	Err          string           `json:"err"`
+
+	runtime wazero.Runtime
+	module  api.Module
+	lock    sync.Mutex // WASM 实例不支持并发调用
+}
+
+// pluginHostAPI 是提供给插件后端的宿主 API。
+type pluginHostAPI struct {
+	capability string // 调用所需的能力，为空时不需要能力
+	fn         func(plugin *PluginBackend, arg map[string]interface{}) (interface{}, error)
+}
+
+var pluginHostAPIs = map[string]*pluginHostAPI{
+	"block.get":          {PluginCapBlockRead, pluginHostGetBlock},
+	"block.getChildren":  {PluginCapBlockRead, pluginHostGetChildBlocks},
+	"sql.query":          {PluginCapSQLQuery, pluginHostQuerySQL},
+	"scheduler.register": {PluginCapScheduler, pluginHostRegisterScheduler},
+	"scheduler.remove":   {PluginCapScheduler, pluginHostRemoveScheduler},
+	"event.subscribe":    {PluginCapEvent, pluginHostSubscribeEvents},
+	"route.register":     {PluginCapRoute, pluginHostRegisterRoute},
+	"route.remove":       {PluginCapRoute, pluginHostRemoveRoute},
+	"system.info":        {"", pluginHostSystemInfo},
+	"storage.get":        {"", pluginHostGetStorage},
+	"storage.list":       {"", pluginHostListStorage},
+	"storage.batch":      {"", pluginHostBatchStorage},
+}
+
+var (
+	pluginBackends     = map[string]*PluginBackend{}
+	pluginBackendsLock = sync.Mutex{}
+)
+
+// LoadPluginBackends 加载所有已启用插件的后端组件。
+func LoadPluginBackends() {
+	defer util.StartBootStage("pluginBackends", false)()
+
+	if Conf.Bazaar.PetalDisabled || util.ReadOnly {
+		return
+	}
+	if !Conf.Bazaar.Trust && (util.ContainerStd == util.Container || util.ContainerDocker == util.Container) {
+		return
+	}
+
+	for _, petal := range getPetals() {
+		if petal.Enabled && !petal.Incompatible {
+			if err := loadPluginBackend(petal.Name); nil != err {
+				logging.LogErrorf("load plugin [%s] backend failed: %s", petal.Name, err)
+			}
+		}
+	}
+}
+
+func GetPluginBackends() (ret []*PluginBackend) {
+	pluginBackendsLock.Lock()
+	defer pluginBackendsLock.Unlock()
+
+	ret = []*PluginBackend{}
+	for _, plugin := range pluginBackends {
+		ret = append(ret, plugin)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return
+}
+
+// ReloadPluginBackend 重新加载插件后端，插件未启用时仅卸载。
+func ReloadPluginBackend(name string) (err error) {
+	unloadPluginBackend(name)
+	petal := getPetalByName(name, getPetals())
+	if nil == petal || !petal.Enabled {
+		return
+	}
+	return loadPluginBackend(name)
+}
+
+func loadPluginBackend(name string) (err error) {
+	plugin, err := bazaar.PluginJSON(name)
+	if nil != err {
+		return
+	}
+	if nil == plugin.Backend || "" == plugin.Backend.Main {
+		return
+	}
+
+	pluginDir := filepath.Join(util.DataDir, "plugins", name)
+	wasmPath := filepath.Join(pluginDir, plugin.Backend.Main)
+	if !util.IsSubPath(pluginDir, wasmPath) {
+		return fmt.Errorf("plugin backend [%s] must be under the plugin folder", plugin.Backend.Main)
+	}
+	bin, err := os.ReadFile(wasmPath)
+	if nil != err {
+		return
+	}
+
+	unloadPluginBackend(name)
+
+	backend := &PluginBackend{
+		Name:         name,
+		Capabilities: plugin.Backend.Capabilities,
+		Schedulers:   map[string]int64{},
+		Topics:       []string{},
+		Routes:       []*PluginRoute{},
+	}
+	if nil == backend.Capabilities {
+		backend.Capabilities = []string{}
+	}
+
+	ctx := context.Background()
+	backend.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(pluginBackendMemoryPages).
+		WithCloseOnContextDone(true))
+	defer func() {
+		if nil != err {
+			backend.runtime.Close(ctx)
+		}
+	}()
+
+	// 不挂载文件系统、不继承环境变量和标准输入输出
+	if _, err = wasi_snapshot_preview1.Instantiate(ctx, backend.runtime); nil != err {
+		return
+	}
+	if _, err = backend.runtime.NewHostModuleBuilder("siyuan").
+		NewFunctionBuilder().WithFunc(backend.hostLog).Export("log").
+		NewFunctionBuilder().WithFunc(backend.hostCall).Export("call").
+		Instantiate(ctx); nil != err {
+		return
+	}
+
+	compiled, err := backend.runtime.CompileModule(ctx, bin)
+	if nil != err {
+		return
+	}
+	backend.module, err = backend.runtime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().
+		WithName(name).
+		WithStartFunctions("_initialize"))
+	if nil != err {
+		return
+	}
+	if nil == backend.module.ExportedFunction("malloc") {
+		err = errors.New("plugin backend must export malloc")
+		return
+	}
+
+	backend.Loaded = util.CurrentTimeMillis()
+	pluginBackendsLock.Lock()
+	pluginBackends[name] = backend
+	pluginBackendsLock.Unlock()
+
+	if err = backend.callExport("on_load", nil); nil != err {
+		backend.Err = err.Error()
+		err = nil
+	}
+	logging.LogInfof("loaded plugin [%s] backend with capabilities %v", name, backend.Capabilities)
+	return
+}
+
+func unloadPluginBackend(name string) {
+	pluginBackendsLock.Lock()
+	backend := pluginBackends[name]
+	delete(pluginBackends, name)
+	pluginBackendsLock.Unlock()
+	if nil == backend {
+		return
+	}
+
+	UnsubscribePluginEvents(name, PluginEventTargetBackend)
+	if err := backend.callExport("on_unload", nil); nil != err {
+		logging.LogWarnf("unload plugin [%s] backend failed: %s", name, err)
+	}
+	removeTransientPluginJobs(name)
+
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.runtime.Close(context.Background())
+	logging.LogInfof("unloaded plugin [%s] backend", name)
+}
+
+// callExport 调用插件后端导出的函数，插件未导出该函数时忽略。
+func (backend *PluginBackend) callExport(fnName string, payload interface{}) (err error) {
+	_, err = backend.callExportResult(fnName, payload)
+	return
+}
+
+// callExportResult 调用插件后端导出的函数，函数有返回值时按 ptr << 32 | len 读取返回的数据。
+func (backend *PluginBackend) callExportResult(fnName string, payload interface{}) (ret []byte, err error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+
+	if backend.module.IsClosed() {
+		err = errors.New("plugin backend is closed")
+		return
+	}
+	fn := backend.module.ExportedFunction(fnName)
+	if nil == fn {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginBackendCallTimeout)
+	defer cancel()
+
+	var params []uint64
+	if nil != payload {
+		data, marshalErr := gulu.JSON.MarshalJSON(payload)
+		if nil != marshalErr {
+			err = marshalErr
+			return
+		}
+		packed, writeErr := backend.writeGuest(ctx, backend.module, data)
+		if nil != writeErr {
+			err = writeErr
+			return
+		}
+		params = []uint64{packed >> 32, packed & 0xFFFFFFFF}
+	}
+
+	results, err := fn.Call(ctx, params...)
+	if nil != err {
+		backend.Err = err.Error()
+		if backend.module.IsClosed() {
+			// 超时或者异常退出，模块已经被关闭
+			logging.LogErrorf("plugin [%s] backend closed: %s", backend.Name, err)
+		}
+		return
+	}
+	if 1 == len(results) && 0 != results[0] {
+		data, ok := backend.readGuest(backend.module, uint32(results[0]>>32), uint32(results[0]))
+		if !ok {
+			err = errors.New("read plugin result out of range")
+			return
+		}
+		ret = data
+	}
+	return
+}
+
+// writeGuest 调用插件的 malloc 分配内存并写入数据，返回 ptr << 32 | len。
+func (backend *PluginBackend) writeGuest(ctx context.Context, m api.Module, data []byte) (ret uint64, err error) {
+	results, err := m.ExportedFunction("malloc").Call(ctx, uint64(len(data)))
+	if nil != err {
+		return
+	}
+	ptr := uint32(results[0])
+	if !m.Memory().Write(ptr, data) {
+		err = errors.New("write plugin memory out of range")
+		return
+	}
+	ret = uint64(ptr)<<32 | uint64(len(data))
+	return
+}
+
+func (backend *PluginBackend) readGuest(m api.Module, ptr, size uint32) (ret []byte, ok bool) {
+	data, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	ret = make([]byte, len(data))
+	copy(ret, data)
+	return
+}
+
+func (backend *PluginBackend) hostLog(ctx context.Context, m api.Module, ptr, size uint32) {
+	if data, ok := backend.readGuest(m, ptr, size); ok {
+		logging.LogInfof("plugin [%s] backend: %s", backend.Name, data)
+	}
+}
+
+func (backend *PluginBackend) hostCall(ctx context.Context, m api.Module, namePtr, nameLen, argPtr, argLen uint32) uint64 {
+	result := gulu.Ret.NewResult()
+	name, ok := backend.readGuest(m, namePtr, nameLen)
+	if !ok {
+		result.Code = -1
+		result.Msg = "read api name out of range"
+	} else {
+		arg := map[string]interface{}{}
+		if 0 < argLen {
+			if data, readOk := backend.readGuest(m, argPtr, argLen); !readOk {
+				result.Code = -1
+				result.Msg = "read api arg out of range"
+			} else if err := gulu.JSON.UnmarshalJSON(data, &arg); nil != err {
+				result.Code = -1
+				result.Msg = err.Error()
+			}
+		}
+
+		if 0 == result.Code {
+			data, err := backend.invokeHostAPI(string(name), arg)
+			if nil != err {
+				result.Code = -1
+				result.Msg = err.Error()
+			}
+			result.Data = data
+		}
+	}
+
+	data, err := gulu.JSON.MarshalJSON(result)
+	if nil != err {
+		return 0
+	}
+	ret, err := backend.writeGuest(ctx, m, data)
+	if nil != err {
+		logging.LogWarnf("plugin [%s] write result failed: %s", backend.Name, err)
+		return 0
+	}
+	return ret
+}
+
+func (backend *PluginBackend) invokeHostAPI(name string, arg map[string]interface{}) (ret interface{}, err error) {
+	hostAPI := pluginHostAPIs[name]
+	if nil == hostAPI {
+		err = fmt.Errorf("unknown api [%s]", name)
+		return
+	}
+	if "" != hostAPI.capability && !backend.hasCapability(hostAPI.capability) {
+		err = errors.New(RequestPluginPermission(backend.Name, pluginCapabilityPermission(hostAPI.capability), name))
+		return
+	}
+	return hostAPI.fn(backend, arg)
+}
+
+// hasCapability 判断插件后端是否可以使用该能力，能力需要在清单中声明并且由用户授权，授权结果和插件权限一起保存。
+func (backend *PluginBackend) hasCapability(capability string) bool {
+	return gulu.Str.Contains(capability, backend.Capabilities) && IsPluginPermissionGranted(backend.Name, pluginCapabilityPermission(capability))
+}
+
+// pluginCapabilityPermissionPrefix 是插件后端能力对应的权限名称前缀，用于和前端 API 权限区分。
+const pluginCapabilityPermissionPrefix = "backend."
+
+func pluginCapabilityPermission(capability string) string {
+	return pluginCapabilityPermissionPrefix + capability
+}
+
+func pluginHostGetBlock(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	id, _ := arg["id"].(string)
+	block := sql.GetBlock(id)
+	if nil == block {
+		err = ErrBlockNotFound
+		return
+	}
+	ret = block
+	return
+}
+
+func pluginHostGetChildBlocks(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	id, _ := arg["id"].(string)
+	ret = GetChildBlocks(id)
+	return
+}
+
+func pluginHostQuerySQL(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	stmt, _ := arg["stmt"].(string)
+	// 在只读连接池上执行，是否只读由 SQLite 执行时判断（WITH ... DELETE 这样的语句也会被拒绝）
+	ret, err = sql.QueryReadonly(stmt, Conf.Search.Limit)
+	return
+}
+
+func pluginHostRegisterScheduler(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	name, _ := arg["name"].(string)
+	interval, _ := arg["interval"].(float64) // 秒
+	cron, _ := arg["cron"].(string)
+	if "" == name {
+		err = errors.New("scheduler name is empty")
+		return
+	}
+
+	// 由内核调度器统一执行，受并发预算限制
+	job, err := SetPluginJob(&PluginJob{ID: name, Plugin: plugin.Name, Interval: int64(interval), Cron: cron, Enabled: true})
+	if nil != err {
+		return
+	}
+
+	pluginBackendsLock.Lock()
+	defer pluginBackendsLock.Unlock()
+	plugin.Schedulers[name] = job.Interval
+	return
+}
+
+func pluginHostRemoveScheduler(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	name, _ := arg["name"].(string)
+	RemovePluginJob(plugin.Name, name)
+
+	pluginBackendsLock.Lock()
+	defer pluginBackendsLock.Unlock()
+	delete(plugin.Schedulers, name)
+	return
+}
+
+func pluginHostSubscribeEvents(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	var topics []string
+	if topicsArg, ok := arg["topics"].([]interface{}); ok {
+		for _, t := range topicsArg {
+			topic, _ := t.(string)
+			topics = append(topics, topic)
+		}
+	}
+	filter := &PluginEventFilter{}
+	if filterArg, ok := arg["filter"].(map[string]interface{}); ok {
+		if data, marshalErr := gulu.JSON.MarshalJSON(filterArg); nil == marshalErr {
+			gulu.JSON.UnmarshalJSON(data, filter)
+		}
+	}
+
+	sub, err := SubscribePluginEvents(plugin.Name, PluginEventTargetBackend, topics, filter)
+	if nil != err {
+		return
+	}
+
+	pluginBackendsLock.Lock()
+	defer pluginBackendsLock.Unlock()
+	plugin.Topics = sub.Topics
+	ret = sub.Topics
+	return
+}
+
+func pluginHostSystemInfo(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	ret = map[string]interface{}{
+		"version":   util.Ver,
+		"os":        util.Container,
+		"workspace": filepath.Base(util.WorkspaceDir),
+	}
+	return
+}
+
+func pluginHostGetStorage(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	var keys []string
+	if keysArg, ok := arg["keys"].([]interface{}); ok {
+		for _, k := range keysArg {
+			if key, isStr := k.(string); isStr {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return GetPluginKV(plugin.Name, keys)
+}
+
+func pluginHostListStorage(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	prefix, _ := arg["prefix"].(string)
+	return ListPluginKVKeys(plugin.Name, prefix)
+}
+
+func pluginHostBatchStorage(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	data, err := gulu.JSON.MarshalJSON(arg["ops"])
+	if nil != err {
+		return
+	}
+	var ops []*PluginStorageOp
+	if err = gulu.JSON.UnmarshalJSON(data, &ops); nil != err {
+		return
+	}
+	err = BatchPluginKV(plugin.Name, ops)
+	return
+}
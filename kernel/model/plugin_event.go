@@ -0,0 +1,323 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// BlockUpdatedEvent 描述了一次块更新事件，由事务提交后发布。
+type BlockUpdatedEvent struct {
+	Event  string `json:"event"`
+	ID     string `json:"id"`
+	RootID string `json:"rootID"`
+	Box    string `json:"box"`
+	Type   string `json:"type"`
+	Action string `json:"action"` // 事务操作类型，比如 update、insert
+}
+
+// DocCreatedEvent 描述了一次文档创建事件。
+type DocCreatedEvent struct {
+	Event string `json:"event"`
+	ID    string `json:"id"`
+	Box   string `json:"box"`
+	Path  string `json:"path"`
+	HPath string `json:"hPath"`
+}
+
+// SyncFinishedEvent 描述了一次数据同步结束事件。
+type SyncFinishedEvent struct {
+	Event       string `json:"event"`
+	Code        int    `json:"code"` // 1：成功，2：失败
+	DataChanged bool   `json:"dataChanged"`
+	Msg         string `json:"msg"`
+}
+
+// FlashcardReviewedEvent 描述了一次闪卡复习事件。
+type FlashcardReviewedEvent struct {
+	Event   string `json:"event"`
+	DeckID  string `json:"deckID"`
+	CardID  string `json:"cardID"`
+	BlockID string `json:"blockID"`
+	Rating  int    `json:"rating"`
+}
+
+const (
+	PluginEventTargetWebSocket = "ws"      // 通过 WebSocket 推送给插件前端
+	PluginEventTargetBackend   = "backend" // 推送给插件后端（WASM）
+
+	pluginEventQueueSize = 256
+)
+
+// 插件可以订阅的内核事件
+var pluginEventTopics = []string{
+	util.EvtHistoryCreated, util.EvtSnapshotCreated,
+	util.EvtBlockUpdated, util.EvtDocCreated, util.EvtSyncFinished, util.EvtFlashcardReviewed,
+	util.EvtReminderFired,
+}
+
+// PluginEventFilter 描述了事件订阅的过滤条件，各字段为空时不过滤。
+type PluginEventFilter struct {
+	Boxes   []string `json:"boxes"`
+	RootIDs []string `json:"rootIDs"`
+	Types   []string `json:"types"`
+}
+
+// PluginEventSubscription 描述了插件的一个事件订阅。
+//
+// 每个订阅有一个有界队列，投递速度跟不上时丢弃队列中最早的事件并计数，避免阻塞内核。
+type PluginEventSubscription struct {
+	ID      string             `json:"id"`
+	Plugin  string             `json:"plugin"`
+	Target  string             `json:"target"`
+	Topics  []string           `json:"topics"`
+	Filter  *PluginEventFilter `json:"filter"`
+	Dropped int64              `json:"dropped"` // 因队列已满而丢弃的事件数
+
+	queue chan *pluginEvent
+	stop  chan struct{}
+}
+
+type pluginEvent struct {
+	Topic string      `json:"topic"`
+	Data  interface{} `json:"data"`
+}
+
+var (
+	pluginEventSubscriptions     = map[string]*PluginEventSubscription{}
+	pluginEventSubscriptionsLock = sync.RWMutex{}
+)
+
+func init() {
+	for _, topic := range pluginEventTopics {
+		topic := topic
+		eventbus.Subscribe(topic, func(evt interface{}) {
+			dispatchPluginEvent(topic, evt)
+		})
+	}
+}
+
+// SubscribePluginEvents 为插件订阅内核事件，同一插件的同一投递目标只保留一个订阅，重复订阅时替换主题和过滤条件。
+func SubscribePluginEvents(plugin, target string, topics []string, filter *PluginEventFilter) (ret *PluginEventSubscription, err error) {
+	if "" == plugin {
+		err = errors.New("plugin name is required")
+		return
+	}
+	if PluginEventTargetWebSocket != target && PluginEventTargetBackend != target {
+		err = fmt.Errorf("unsupported event target [%s]", target)
+		return
+	}
+	if 1 > len(topics) {
+		err = errors.New("topics is required")
+		return
+	}
+	for _, topic := range topics {
+		if !gulu.Str.Contains(topic, pluginEventTopics) {
+			err = fmt.Errorf("unsupported event [%s]", topic)
+			return
+		}
+	}
+	if nil == filter {
+		filter = &PluginEventFilter{}
+	}
+
+	id := target + ":" + plugin
+	pluginEventSubscriptionsLock.Lock()
+	defer pluginEventSubscriptionsLock.Unlock()
+	if old := pluginEventSubscriptions[id]; nil != old {
+		close(old.stop)
+	}
+	ret = &PluginEventSubscription{
+		ID:     id,
+		Plugin: plugin,
+		Target: target,
+		Topics: gulu.Str.RemoveDuplicatedElem(topics),
+		Filter: filter,
+		queue:  make(chan *pluginEvent, pluginEventQueueSize),
+		stop:   make(chan struct{}),
+	}
+	pluginEventSubscriptions[id] = ret
+	go ret.deliver()
+	return
+}
+
+// UnsubscribePluginEvents 取消插件的事件订阅，target 为空时取消该插件的所有订阅。
+func UnsubscribePluginEvents(plugin, target string) {
+	pluginEventSubscriptionsLock.Lock()
+	defer pluginEventSubscriptionsLock.Unlock()
+	for id, sub := range pluginEventSubscriptions {
+		if sub.Plugin == plugin && ("" == target || sub.Target == target) {
+			close(sub.stop)
+			delete(pluginEventSubscriptions, id)
+		}
+	}
+}
+
+// GetPluginEventSubscriptions 获取插件的事件订阅，plugin 为空时获取所有订阅。
+func GetPluginEventSubscriptions(plugin string) (ret []*PluginEventSubscription) {
+	ret = []*PluginEventSubscription{}
+	pluginEventSubscriptionsLock.RLock()
+	defer pluginEventSubscriptionsLock.RUnlock()
+	for _, sub := range pluginEventSubscriptions {
+		if "" != plugin && sub.Plugin != plugin {
+			continue
+		}
+		s := *sub
+		s.Dropped = atomic.LoadInt64(&sub.Dropped)
+		ret = append(ret, &s)
+	}
+	return
+}
+
+func hasPluginEventSubscriber(topic string) bool {
+	pluginEventSubscriptionsLock.RLock()
+	defer pluginEventSubscriptionsLock.RUnlock()
+	for _, sub := range pluginEventSubscriptions {
+		if gulu.Str.Contains(topic, sub.Topics) {
+			return true
+		}
+	}
+	return false
+}
+
+func dispatchPluginEvent(topic string, evt interface{}) {
+	box, rootID, typ := pluginEventScope(evt)
+
+	pluginEventSubscriptionsLock.RLock()
+	defer pluginEventSubscriptionsLock.RUnlock()
+	for _, sub := range pluginEventSubscriptions {
+		if !gulu.Str.Contains(topic, sub.Topics) || !sub.Filter.match(box, rootID, typ) {
+			continue
+		}
+		sub.enqueue(&pluginEvent{Topic: topic, Data: evt})
+	}
+}
+
+func (sub *PluginEventSubscription) enqueue(evt *pluginEvent) {
+	for {
+		select {
+		case sub.queue <- evt:
+			return
+		default:
+		}
+
+		// 队列已满，丢弃最早的事件
+		select {
+		case <-sub.queue:
+			atomic.AddInt64(&sub.Dropped, 1)
+		default:
+		}
+	}
+}
+
+func (sub *PluginEventSubscription) deliver() {
+	defer logging.Recover()
+
+	for {
+		select {
+		case <-sub.stop:
+			return
+		case evt := <-sub.queue:
+			switch sub.Target {
+			case PluginEventTargetWebSocket:
+				util.BroadcastByType("main", "pluginEvent", 0, "", map[string]interface{}{
+					"plugin":  sub.Plugin,
+					"topic":   evt.Topic,
+					"data":    evt.Data,
+					"dropped": atomic.LoadInt64(&sub.Dropped),
+				})
+			case PluginEventTargetBackend:
+				pluginBackendsLock.Lock()
+				backend := pluginBackends[sub.Plugin]
+				pluginBackendsLock.Unlock()
+				if nil == backend {
+					continue
+				}
+				if err := backend.callExport("on_event", evt); nil != err {
+					logging.LogWarnf("plugin [%s] handle event [%s] failed: %s", sub.Plugin, evt.Topic, err)
+				}
+			}
+		}
+	}
+}
+
+func (filter *PluginEventFilter) match(box, rootID, typ string) bool {
+	if 0 < len(filter.Boxes) && !gulu.Str.Contains(box, filter.Boxes) {
+		return false
+	}
+	if 0 < len(filter.RootIDs) && !gulu.Str.Contains(rootID, filter.RootIDs) {
+		return false
+	}
+	if 0 < len(filter.Types) && !gulu.Str.Contains(typ, filter.Types) {
+		return false
+	}
+	return true
+}
+
+// pluginEventScope 返回事件所属的笔记本、文档和块类型，用于过滤。
+func pluginEventScope(evt interface{}) (box, rootID, typ string) {
+	switch e := evt.(type) {
+	case *BlockUpdatedEvent:
+		return e.Box, e.RootID, e.Type
+	case *DocCreatedEvent:
+		return e.Box, e.ID, "d"
+	case *ReminderFiredEvent:
+		return e.Box, e.RootID, e.Type
+	}
+	return
+}
+
+func publishBlocksUpdated(tx *Transaction) {
+	if !hasPluginEventSubscriber(util.EvtBlockUpdated) {
+		return
+	}
+
+	published := map[string]bool{}
+	for _, op := range tx.DoOperations {
+		switch op.Action {
+		case "update", "insert", "appendInsert", "prependInsert", "move", "updateAttrs":
+		default:
+			continue
+		}
+		if "" == op.ID || published[op.ID] {
+			continue
+		}
+		published[op.ID] = true
+
+		bt := treenode.GetBlockTree(op.ID)
+		if nil == bt {
+			continue
+		}
+		eventbus.Publish(util.EvtBlockUpdated, &BlockUpdatedEvent{
+			Event:  util.EvtBlockUpdated,
+			ID:     op.ID,
+			RootID: bt.RootID,
+			Box:    bt.BoxID,
+			Type:   bt.Type,
+			Action: op.Action,
+		})
+	}
+}
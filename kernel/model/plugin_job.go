@@ -0,0 +1,411 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// PluginJob 描述了插件注册到内核调度器的定时任务，界面未打开时也会执行。
+//
+// 任务触发时如果配置了 API 则使用插件 token 调用该内核 API（权限检查与插件直接调用一致），否则调用插件后端的 on_schedule。
+type PluginJob struct {
+	ID         string                 `json:"id"` // 插件内唯一
+	Plugin     string                 `json:"plugin"`
+	Interval   int64                  `json:"interval"` // 间隔（秒），与 Cron 二选一
+	Cron       string                 `json:"cron"`     // cron 表达式，格式为 “分 时 日 月 周”
+	API        string                 `json:"api"`      // 触发时调用的内核 API，比如 /api/notebook/lsNotebooks
+	Payload    map[string]interface{} `json:"payload"`  // 调用内核 API 的参数
+	Enabled    bool                   `json:"enabled"`
+	Persistent bool                   `json:"persistent"` // 是否持久化，插件后端在 on_load 中注册的任务不持久化
+	Created    int64                  `json:"created"`
+
+	LastRun int64  `json:"lastRun"`
+	NextRun int64  `json:"nextRun"`
+	Running bool   `json:"running"`
+	LastErr string `json:"lastErr"`
+
+	cron *util.CronSchedule
+}
+
+const (
+	pluginJobMinInterval  = 10
+	pluginJobMaxPerPlugin = 16
+	pluginJobTimeout      = 5 * time.Minute
+)
+
+var (
+	pluginJobs       = map[string]*PluginJob{} // plugin/id -> job
+	pluginJobsLoaded bool
+	pluginJobsLock   = sync.Mutex{}
+
+	pluginJobBudget     chan struct{}
+	pluginJobBudgetOnce sync.Once
+)
+
+// GetPluginJobs 获取插件的定时任务，plugin 为空时获取所有任务。
+func GetPluginJobs(plugin string) (ret []*PluginJob) {
+	pluginJobsLock.Lock()
+	defer pluginJobsLock.Unlock()
+	loadPluginJobs()
+
+	ret = []*PluginJob{}
+	for _, job := range pluginJobs {
+		if "" == plugin || job.Plugin == plugin {
+			j := *job
+			ret = append(ret, &j)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Plugin != ret[j].Plugin {
+			return ret[i].Plugin < ret[j].Plugin
+		}
+		return ret[i].ID < ret[j].ID
+	})
+	return
+}
+
+// SetPluginJob 注册或更新插件的定时任务。
+func SetPluginJob(job *PluginJob) (ret *PluginJob, err error) {
+	if "" == job.Plugin || "" == job.ID {
+		err = errors.New("plugin and job id are required")
+		return
+	}
+	if "" != job.Cron {
+		if job.cron, err = util.ParseCron(job.Cron); nil != err {
+			return
+		}
+		job.Interval = 0
+	} else if pluginJobMinInterval > job.Interval {
+		job.Interval = pluginJobMinInterval
+	}
+	if "" != job.API {
+		if !strings.HasPrefix(job.API, "/api/") {
+			err = fmt.Errorf("invalid job api [%s]", job.API)
+			return
+		}
+		if nil == job.Payload {
+			job.Payload = map[string]interface{}{}
+		}
+	}
+
+	pluginJobsLock.Lock()
+	defer pluginJobsLock.Unlock()
+	loadPluginJobs()
+
+	key := job.Plugin + "/" + job.ID
+	if old := pluginJobs[key]; nil != old {
+		// 原地更新，避免正在执行的任务结束后写回旧对象
+		old.Interval, old.Cron, old.cron = job.Interval, job.Cron, job.cron
+		old.API, old.Payload = job.API, job.Payload
+		old.Enabled, old.Persistent = job.Enabled, job.Persistent
+		job = old
+	} else {
+		count := 0
+		for _, j := range pluginJobs {
+			if j.Plugin == job.Plugin {
+				count++
+			}
+		}
+		if pluginJobMaxPerPlugin <= count {
+			err = fmt.Errorf("too many jobs, max is %d", pluginJobMaxPerPlugin)
+			return
+		}
+		job.Created = util.CurrentTimeMillis()
+	}
+	job.NextRun = job.next(time.Now())
+	pluginJobs[key] = job
+	savePluginJobs()
+	j := *job
+	ret = &j
+	return
+}
+
+// RemovePluginJob 移除插件的定时任务，id 为空时移除该插件的所有任务。
+func RemovePluginJob(plugin, id string) {
+	removePluginJobs(plugin, func(job *PluginJob) bool { return "" == id || job.ID == id })
+}
+
+// removeTransientPluginJobs 在卸载插件后端时移除后端注册的非持久化任务。
+func removeTransientPluginJobs(plugin string) {
+	removePluginJobs(plugin, func(job *PluginJob) bool { return !job.Persistent })
+}
+
+func removePluginJobs(plugin string, match func(job *PluginJob) bool) {
+	pluginJobsLock.Lock()
+	defer pluginJobsLock.Unlock()
+	loadPluginJobs()
+
+	persistentChanged := false
+	for key, job := range pluginJobs {
+		if job.Plugin == plugin && match(job) {
+			delete(pluginJobs, key)
+			persistentChanged = persistentChanged || job.Persistent
+		}
+	}
+	if persistentChanged {
+		savePluginJobs()
+	}
+}
+
+// RunPluginJob 立即执行插件的定时任务。
+func RunPluginJob(plugin, id string) (err error) {
+	pluginJobsLock.Lock()
+	loadPluginJobs()
+	job := pluginJobs[plugin+"/"+id]
+	if nil == job {
+		pluginJobsLock.Unlock()
+		return fmt.Errorf("plugin job [%s] not found", id)
+	}
+	if job.Running {
+		pluginJobsLock.Unlock()
+		return fmt.Errorf("plugin job [%s] is running", id)
+	}
+	job.Running = true
+	pluginJobsLock.Unlock()
+
+	acquirePluginJobBudget()
+	err = runPluginJob(job)
+	return
+}
+
+// PluginJobSchedulerJob 执行到期的插件定时任务。
+//
+// 同时执行的任务数受 Conf.Bazaar.PetalJobConcurrency 限制，超出预算的任务顺延到下一次检查时执行；同一任务不会重叠执行。
+func PluginJobSchedulerJob() {
+	if !util.IsBooted() || util.IsExiting.Load() || util.ReadOnly || Conf.Bazaar.PetalDisabled {
+		return
+	}
+
+	now := time.Now()
+	pluginJobsLock.Lock()
+	loadPluginJobs()
+	var due []*PluginJob
+	for _, job := range pluginJobs {
+		if job.Enabled && !job.Running && job.NextRun <= now.UnixMilli() {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextRun < due[j].NextRun })
+
+	var runs []*PluginJob
+	for _, job := range due {
+		if !tryAcquirePluginJobBudget() {
+			break
+		}
+		job.Running = true
+		runs = append(runs, job)
+	}
+	pluginJobsLock.Unlock()
+
+	for _, job := range runs {
+		go runPluginJob(job)
+	}
+}
+
+func runPluginJob(job *PluginJob) (err error) {
+	defer logging.Recover()
+	defer releasePluginJobBudget()
+
+	if !isPluginEnabled(job.Plugin) {
+		err = fmt.Errorf("plugin [%s] is not enabled", job.Plugin)
+	} else if "" != job.API {
+		err = callPluginJobAPI(job)
+	} else {
+		pluginBackendsLock.Lock()
+		backend := pluginBackends[job.Plugin]
+		pluginBackendsLock.Unlock()
+		if nil == backend {
+			err = fmt.Errorf("plugin [%s] backend is not loaded", job.Plugin)
+		} else {
+			err = backend.callExport("on_schedule", map[string]interface{}{"name": job.ID})
+		}
+	}
+	if nil != err {
+		logging.LogWarnf("plugin [%s] job [%s] failed: %s", job.Plugin, job.ID, err)
+	}
+
+	pluginJobsLock.Lock()
+	defer pluginJobsLock.Unlock()
+	now := time.Now()
+	job.Running = false
+	job.LastRun = now.UnixMilli()
+	job.NextRun = job.next(now)
+	job.LastErr = ""
+	if nil != err {
+		job.LastErr = err.Error()
+	}
+	return
+}
+
+func callPluginJobAPI(job *PluginJob) (err error) {
+	body, err := gulu.JSON.MarshalJSON(job.Payload)
+	if nil != err {
+		return
+	}
+
+	u := "http://" + util.LocalHost + ":" + util.ServerPort + job.API
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if nil != err {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+getPluginToken(job.Plugin))
+
+	client := &http.Client{Timeout: pluginJobTimeout}
+	resp, err := client.Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if nil != err {
+		return
+	}
+	if http.StatusOK != resp.StatusCode {
+		return fmt.Errorf("call [%s] failed [%d]: %s", job.API, resp.StatusCode, data)
+	}
+	result := gulu.Ret.NewResult()
+	if err = gulu.JSON.UnmarshalJSON(data, result); nil != err {
+		return
+	}
+	if 0 != result.Code {
+		return fmt.Errorf("call [%s] failed: %s", job.API, result.Msg)
+	}
+	return
+}
+
+func (job *PluginJob) next(t time.Time) int64 {
+	if nil != job.cron {
+		return job.cron.Next(t).UnixMilli()
+	}
+	return t.Add(time.Duration(job.Interval) * time.Second).UnixMilli()
+}
+
+func isPluginEnabled(name string) bool {
+	for _, petal := range getPetals() {
+		if petal.Name == name {
+			return petal.Enabled
+		}
+	}
+	return false
+}
+
+func getPluginJobBudget() chan struct{} {
+	pluginJobBudgetOnce.Do(func() {
+		concurrency := Conf.Bazaar.PetalJobConcurrency
+		if 1 > concurrency {
+			concurrency = 1
+		}
+		pluginJobBudget = make(chan struct{}, concurrency)
+	})
+	return pluginJobBudget
+}
+
+func tryAcquirePluginJobBudget() bool {
+	select {
+	case getPluginJobBudget() <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func acquirePluginJobBudget() {
+	getPluginJobBudget() <- struct{}{}
+}
+
+func releasePluginJobBudget() {
+	<-getPluginJobBudget()
+}
+
+// loadPluginJobs 加载持久化的任务，运行状态不持久化，内核启动后重新计算下一次执行时间。
+func loadPluginJobs() {
+	if pluginJobsLoaded {
+		return
+	}
+	pluginJobsLoaded = true
+
+	p := filepath.Join(util.DataDir, "storage", "petal", "jobs.json")
+	if !filelock.IsExist(p) {
+		return
+	}
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read plugin jobs failed: %s", err)
+		return
+	}
+	var jobs []*PluginJob
+	if err = gulu.JSON.UnmarshalJSON(data, &jobs); nil != err {
+		logging.LogErrorf("unmarshal plugin jobs failed: %s", err)
+		return
+	}
+
+	now := time.Now()
+	for _, job := range jobs {
+		if "" != job.Cron {
+			if job.cron, err = util.ParseCron(job.Cron); nil != err {
+				logging.LogWarnf("parse plugin [%s] job [%s] cron failed: %s", job.Plugin, job.ID, err)
+				continue
+			}
+		}
+		job.Running = false
+		job.NextRun = job.next(now)
+		pluginJobs[job.Plugin+"/"+job.ID] = job
+	}
+}
+
+func savePluginJobs() {
+	jobs := []*PluginJob{}
+	for _, job := range pluginJobs {
+		if job.Persistent {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created < jobs[j].Created })
+
+	data, err := gulu.JSON.MarshalIndentJSON(jobs, "", "\t")
+	if nil != err {
+		logging.LogErrorf("marshal plugin jobs failed: %s", err)
+		return
+	}
+
+	petalDir := filepath.Join(util.DataDir, "storage", "petal")
+	if err = os.MkdirAll(petalDir, 0755); nil != err {
+		logging.LogErrorf("create petal dir [%s] failed: %s", petalDir, err)
+		return
+	}
+	if err = filelock.WriteFile(filepath.Join(petalDir, "jobs.json"), data); nil != err {
+		logging.LogErrorf("write plugin jobs failed: %s", err)
+	}
+}
@@ -0,0 +1,346 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/bazaar"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 插件可以在 plugin.json 的 permissions 中申请的内核 API 权限
+const (
+	PluginPermNetwork    = "network"    // 通过内核访问网络
+	PluginPermFilesystem = "filesystem" // 访问插件目录以外的文件
+	PluginPermSQLWrite   = "sql.write"  // 执行写入数据库的 SQL 语句
+	PluginPermExport     = "export"     // 导出
+)
+
+// PluginContextKey 是请求上下文中保存调用方插件名称的键，仅通过插件 token 访问时设置。
+const PluginContextKey = "plugin"
+
+// PluginPermissionRequest 描述了一次插件权限授权请求，通过事件总线推送给前端确认。
+type PluginPermissionRequest struct {
+	Plugin     string `json:"plugin"`
+	Permission string `json:"permission"`
+	API        string `json:"api"`
+}
+
+var (
+	pluginTokens     = map[string]string{} // 插件名称 -> token
+	pluginTokensLock = sync.Mutex{}
+
+	pluginGrantsLock = sync.Mutex{}
+)
+
+func init() {
+	eventbus.Subscribe(util.EvtPluginPermissionRequest, func(req *PluginPermissionRequest) {
+		util.BroadcastByType("main", "pluginPermissionRequest", 0, "", req)
+	})
+}
+
+// getPluginToken 获取插件的 API token，token 仅保存在内存中，每次启动重新生成。
+func getPluginToken(name string) string {
+	pluginTokensLock.Lock()
+	defer pluginTokensLock.Unlock()
+
+	token := pluginTokens[name]
+	if "" == token {
+		token = "plugin-" + gulu.Rand.String(32)
+		pluginTokens[name] = token
+	}
+	return token
+}
+
+func getPluginByToken(token string) string {
+	if !strings.HasPrefix(token, "plugin-") {
+		return ""
+	}
+
+	pluginTokensLock.Lock()
+	defer pluginTokensLock.Unlock()
+	for name, t := range pluginTokens {
+		if t == token {
+			return name
+		}
+	}
+	return ""
+}
+
+// GetPluginPermissions 返回插件申请的权限和已授予的权限。
+func GetPluginPermissions(name string) (requested, granted []string) {
+	requested = []string{}
+	if plugin, err := bazaar.PluginJSON(name); nil == err {
+		requested = append(requested, plugin.Permissions...)
+		if nil != plugin.Backend {
+			for _, capability := range plugin.Backend.Capabilities {
+				requested = append(requested, pluginCapabilityPermission(capability))
+			}
+		}
+	}
+
+	granted = loadPluginGrants()[name]
+	if nil == granted {
+		granted = []string{}
+	}
+	return
+}
+
+// removePluginPermissions 在卸载插件时移除插件的 token 和已授予的权限。
+func removePluginPermissions(name string) {
+	pluginTokensLock.Lock()
+	delete(pluginTokens, name)
+	pluginTokensLock.Unlock()
+
+	pluginGrantsLock.Lock()
+	defer pluginGrantsLock.Unlock()
+	grants := loadPluginGrants0()
+	if _, ok := grants[name]; ok {
+		delete(grants, name)
+		savePluginGrants(grants)
+	}
+}
+
+func SetPluginPermissionGranted(name, permission string, granted bool) {
+	pluginGrantsLock.Lock()
+	defer pluginGrantsLock.Unlock()
+
+	grants := loadPluginGrants0()
+	perms := grants[name]
+	var tmp []string
+	for _, p := range perms {
+		if p != permission {
+			tmp = append(tmp, p)
+		}
+	}
+	if granted {
+		tmp = append(tmp, permission)
+	}
+	if 1 > len(tmp) {
+		delete(grants, name)
+	} else {
+		grants[name] = tmp
+	}
+	savePluginGrants(grants)
+
+	if strings.HasPrefix(permission, pluginCapabilityPermissionPrefix) {
+		// 插件后端在加载时注册定时任务、事件订阅和路由，授权变更后重新加载
+		go func() {
+			if err := ReloadPluginBackend(name); nil != err {
+				logging.LogErrorf("reload plugin [%s] backend failed: %s", name, err)
+			}
+		}()
+	}
+}
+
+// pluginAllowedAPIs 是插件 token 可以调用的接口（按前缀匹配，先匹配先生效）及其所需的权限，权限为空时表示不需要额外权限。
+//
+// 不在列表中的接口一律拒绝，包括设置、系统、代码片段、插件管理、同步和数据仓库等接口。
+var pluginAllowedAPIs = []struct {
+	prefix     string
+	permission string
+}{
+	{"/api/system/version", ""},
+	{"/api/system/currentTime", ""},
+	{"/api/system/bootProgress", ""},
+	{"/api/transactions", ""},
+	{"/api/query/sql", ""}, // 未授予 sql.write 权限时只能执行只读语句，见 api.SQL
+	{"/api/sqlite/flushTransaction", ""},
+	{"/api/notebook/", ""},
+	{"/api/filetree/", ""},
+	{"/api/block/", ""},
+	{"/api/attr/", ""},
+	{"/api/av/", ""},
+	{"/api/board/", ""},
+	{"/api/bookmark/", ""},
+	{"/api/tag/", ""},
+	{"/api/ref/", ""},
+	{"/api/outline/", ""},
+	{"/api/graph/", ""},
+	{"/api/search/", ""},
+	{"/api/riff/", ""},
+	{"/api/tasks/", ""},
+	{"/api/history/", ""},
+	{"/api/storage/", ""},
+	{"/api/notification/", ""},
+	{"/api/broadcast/", ""},
+	{"/api/lute/", ""},
+	{"/api/format/autoSpace", ""},
+	{"/api/format/", PluginPermNetwork}, // 下载网络资源到本地
+	{"/api/template/renderSprig", ""},
+	{"/api/template/render", PluginPermFilesystem}, // 可以指定任意模板文件路径
+	{"/api/asset/upload", ""},
+	{"/api/asset/resolveAssetPath", ""},
+	{"/api/asset/statAsset", ""},
+	{"/api/asset/getDocImageAssets", ""},
+	{"/api/asset/getFileAnnotation", ""},
+	{"/api/asset/setFileAnnotation", ""},
+	{"/api/asset/getImageOCRText", ""},
+	{"/api/asset/insertLocalAssets", PluginPermFilesystem}, // 读取本地文件
+	{"/api/import/", PluginPermFilesystem},                 // 读取本地文件
+	{"/api/file/", ""},                                     // 访问插件目录以外的文件时需要 filesystem 权限，见 requiredPluginPermission
+	{"/api/export/", PluginPermExport},
+	{"/api/network/", PluginPermNetwork},
+	{"/api/petal/storage/", ""},
+	{"/api/petal/event/", ""},
+	{"/api/petal/job/", ""},
+	{"/api/plugin/", ""}, // 插件后端路由，只能调用自己的路由，见 api.servePluginRoute
+}
+
+// checkPluginPermission 检查插件是否可以调用当前请求的 API。
+//
+// 不在 pluginAllowedAPIs 中的 API 直接拒绝；插件未在清单中声明所需权限时直接拒绝；已声明但用户尚未授权时拒绝并通过事件总线请求用户授权。
+func checkPluginPermission(c *gin.Context, name string) bool {
+	api := c.Request.URL.Path
+	allowed := false
+	permission := ""
+	for _, allowedAPI := range pluginAllowedAPIs {
+		if strings.HasPrefix(api, allowedAPI.prefix) {
+			allowed = true
+			permission = allowedAPI.permission
+			break
+		}
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "plugin [" + name + "] is not allowed to access [" + api + "]"})
+		c.Abort()
+		return false
+	}
+
+	if "" == permission {
+		permission = requiredPluginPermission(c, name)
+	}
+	if "" == permission || IsPluginPermissionGranted(name, permission) {
+		return true
+	}
+
+	msg := RequestPluginPermission(name, permission, api)
+	c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": msg, "data": map[string]interface{}{"plugin": name, "permission": permission}})
+	c.Abort()
+	return false
+}
+
+// IsPluginPermissionGranted 判断插件是否在清单中声明了该权限并且已经由用户授权。
+func IsPluginPermissionGranted(name, permission string) bool {
+	requested, granted := GetPluginPermissions(name)
+	return gulu.Str.Contains(permission, granted) && gulu.Str.Contains(permission, requested)
+}
+
+// RequestPluginPermission 在插件声明了该权限但用户尚未授权时通过事件总线请求用户授权，返回拒绝访问的原因。
+func RequestPluginPermission(name, permission, api string) (msg string) {
+	requested, _ := GetPluginPermissions(name)
+	msg = "plugin [" + name + "] did not declare permission [" + permission + "]"
+	if gulu.Str.Contains(permission, requested) {
+		msg = "plugin [" + name + "] is waiting for user to grant permission [" + permission + "]"
+		eventbus.Publish(util.EvtPluginPermissionRequest, &PluginPermissionRequest{Plugin: name, Permission: permission, API: api})
+	}
+	logging.LogWarnf(msg)
+	return
+}
+
+// requiredPluginPermission 返回插件调用当前请求的文件 API 所需的权限，为空时表示不需要额外权限。
+func requiredPluginPermission(c *gin.Context, name string) string {
+	api := c.Request.URL.Path
+	switch {
+	case "/api/file/globalCopyFiles" == api:
+		return PluginPermFilesystem
+	case "/api/file/putFile" == api:
+		if !isPluginOwnPath(name, c.PostForm("path")) {
+			return PluginPermFilesystem
+		}
+	case strings.HasPrefix(api, "/api/file/"):
+		arg := peekPluginRequestArg(c)
+		for _, key := range []string{"path", "newPath", "src", "dest"} {
+			if p, ok := arg[key].(string); ok && !isPluginOwnPath(name, p) {
+				return PluginPermFilesystem
+			}
+		}
+	}
+	return ""
+}
+
+// isPluginOwnPath 判断工作空间下的路径是否位于插件自己的目录中。
+func isPluginOwnPath(name, p string) bool {
+	p = path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+	return strings.HasPrefix(p, "/data/plugins/"+name+"/") || strings.HasPrefix(p, "/data/storage/petal/"+name+"/")
+}
+
+// peekPluginRequestArg 读取请求的 JSON 参数，并恢复请求体以便后续处理器继续读取。
+func peekPluginRequestArg(c *gin.Context) (ret map[string]interface{}) {
+	ret = map[string]interface{}{}
+	data, err := io.ReadAll(c.Request.Body)
+	if nil != err {
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	gulu.JSON.UnmarshalJSON(data, &ret)
+	return
+}
+
+func loadPluginGrants() map[string][]string {
+	pluginGrantsLock.Lock()
+	defer pluginGrantsLock.Unlock()
+	return loadPluginGrants0()
+}
+
+func loadPluginGrants0() (ret map[string][]string) {
+	ret = map[string][]string{}
+	p := filepath.Join(util.DataDir, "storage", "petal", "permissions.json")
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read plugin permissions failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal plugin permissions failed: %s", err)
+	}
+	return
+}
+
+func savePluginGrants(grants map[string][]string) {
+	data, err := gulu.JSON.MarshalIndentJSON(grants, "", "\t")
+	if nil != err {
+		logging.LogErrorf("marshal plugin permissions failed: %s", err)
+		return
+	}
+
+	petalDir := filepath.Join(util.DataDir, "storage", "petal")
+	if err = os.MkdirAll(petalDir, 0755); nil != err {
+		logging.LogErrorf("create petal dir [%s] failed: %s", petalDir, err)
+		return
+	}
+	if err = filelock.WriteFile(filepath.Join(petalDir, "permissions.json"), data); nil != err {
+		logging.LogErrorf("write plugin permissions failed: %s", err)
+	}
+}
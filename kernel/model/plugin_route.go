@@ -0,0 +1,157 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/88250/gulu"
+)
+
+// PluginRoute 描述了插件后端注册的 API，完整路径为 /api/plugin/{name}{path}。
+type PluginRoute struct {
+	Method string `json:"method"` // 为空或者 * 时匹配所有方法
+	Path   string `json:"path"`   // 以 /* 结尾时按前缀匹配
+}
+
+// PluginRouteRequest 描述了转发给插件后端 on_request 的请求。
+type PluginRouteRequest struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Query  map[string][]string `json:"query"`
+	Header map[string]string   `json:"header"`
+	Body   string              `json:"body"`
+}
+
+// PluginRouteResponse 描述了插件后端 on_request 的返回值。
+type PluginRouteResponse struct {
+	Status     int               `json:"status"`
+	Header     map[string]string `json:"header"`
+	Body       string            `json:"body"`
+	BodyBase64 bool              `json:"bodyBase64"` // Body 是否为 Base64 编码的二进制数据
+}
+
+const pluginMaxRoutes = 32
+
+var ErrPluginRouteNotFound = errors.New("plugin route not found")
+
+// ServePluginRoute 将请求转发给注册了该 API 的插件后端处理。
+func ServePluginRoute(name string, req *PluginRouteRequest) (status int, header map[string]string, body []byte, err error) {
+	pluginBackendsLock.Lock()
+	backend := pluginBackends[name]
+	matched := false
+	if nil != backend {
+		for _, route := range backend.Routes {
+			if route.match(req.Method, req.Path) {
+				matched = true
+				break
+			}
+		}
+	}
+	pluginBackendsLock.Unlock()
+	if !matched {
+		err = ErrPluginRouteNotFound
+		return
+	}
+
+	data, err := backend.callExportResult("on_request", req)
+	if nil != err {
+		return
+	}
+	resp := &PluginRouteResponse{}
+	if 0 < len(data) {
+		if err = gulu.JSON.UnmarshalJSON(data, resp); nil != err {
+			err = fmt.Errorf("parse plugin [%s] response failed: %s", name, err)
+			return
+		}
+	}
+
+	status = resp.Status
+	if 0 == status {
+		status = http.StatusOK
+	}
+	header = resp.Header
+	body = []byte(resp.Body)
+	if resp.BodyBase64 {
+		if body, err = base64.StdEncoding.DecodeString(resp.Body); nil != err {
+			return
+		}
+	}
+	return
+}
+
+func (route *PluginRoute) match(method, p string) bool {
+	if "" != route.Method && "*" != route.Method && !strings.EqualFold(route.Method, method) {
+		return false
+	}
+	if strings.HasSuffix(route.Path, "/*") {
+		prefix := strings.TrimSuffix(route.Path, "*")
+		return strings.HasPrefix(p, prefix) || p == strings.TrimSuffix(prefix, "/")
+	}
+	return route.Path == p
+}
+
+func pluginHostRegisterRoute(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	method, _ := arg["method"].(string)
+	p, _ := arg["path"].(string)
+	if !strings.HasPrefix(p, "/") || strings.Contains(p, "..") {
+		err = fmt.Errorf("invalid route path [%s]", p)
+		return
+	}
+	method = strings.ToUpper(method)
+
+	pluginBackendsLock.Lock()
+	defer pluginBackendsLock.Unlock()
+	for _, route := range plugin.Routes {
+		if route.Method == method && route.Path == p {
+			ret = plugin.Routes
+			return
+		}
+	}
+	if pluginMaxRoutes <= len(plugin.Routes) {
+		err = fmt.Errorf("too many routes, max is %d", pluginMaxRoutes)
+		return
+	}
+	plugin.Routes = append(plugin.Routes, &PluginRoute{Method: method, Path: p})
+	ret = plugin.Routes
+	return
+}
+
+func pluginHostRemoveRoute(plugin *PluginBackend, arg map[string]interface{}) (ret interface{}, err error) {
+	method, _ := arg["method"].(string)
+	p, _ := arg["path"].(string)
+	method = strings.ToUpper(method)
+
+	pluginBackendsLock.Lock()
+	defer pluginBackendsLock.Unlock()
+	var routes []*PluginRoute
+	for _, route := range plugin.Routes {
+		if route.Method != method || route.Path != p {
+			routes = append(routes, route)
+		}
+	}
+	if nil == routes {
+		routes = []*PluginRoute{}
+	}
+	plugin.Routes = routes
+	ret = plugin.Routes
+	return
+}
@@ -0,0 +1,316 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 插件存储位于 data/storage/petal/{name}/ 下，随数据同步和快照，包括：
+//
+//	kv.json  键值存储
+//	blobs/   二进制存储，文件名为键的十六进制编码
+
+const (
+	PluginStorageOpSet    = "set"
+	PluginStorageOpDelete = "delete"
+
+	pluginStorageMaxKeyLen = 256
+)
+
+// PluginStorageOp 描述了批量写入中的一个操作。
+type PluginStorageOp struct {
+	Op    string      `json:"op"` // set/delete
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// PluginStorageUsage 描述了插件存储的使用情况。
+type PluginStorageUsage struct {
+	KV    int64 `json:"kv"`
+	Blobs int64 `json:"blobs"`
+	Quota int64 `json:"quota"`
+}
+
+var pluginStorageLocks = sync.Map{}
+
+func getPluginStorageLock(name string) *sync.Mutex {
+	lock, _ := pluginStorageLocks.LoadOrStore(name, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+func GetPluginKV(name string, keys []string) (ret map[string]interface{}, err error) {
+	if err = checkPluginStorageName(name); nil != err {
+		return
+	}
+
+	lock := getPluginStorageLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	kv, err := loadPluginKV(name)
+	if nil != err {
+		return
+	}
+	if 1 > len(keys) {
+		return kv, nil
+	}
+
+	ret = map[string]interface{}{}
+	for _, key := range keys {
+		if v, ok := kv[key]; ok {
+			ret[key] = v
+		}
+	}
+	return
+}
+
+func ListPluginKVKeys(name, prefix string) (ret []string, err error) {
+	kv, err := GetPluginKV(name, nil)
+	if nil != err {
+		return
+	}
+
+	ret = []string{}
+	for key := range kv {
+		if strings.HasPrefix(key, prefix) {
+			ret = append(ret, key)
+		}
+	}
+	sort.Strings(ret)
+	return
+}
+
+// BatchPluginKV 原子地执行一批写入操作：全部成功或者全部不生效。
+func BatchPluginKV(name string, ops []*PluginStorageOp) (err error) {
+	if err = checkPluginStorageName(name); nil != err {
+		return
+	}
+	for _, op := range ops {
+		if err = checkPluginStorageKey(op.Key); nil != err {
+			return
+		}
+		if PluginStorageOpSet != op.Op && PluginStorageOpDelete != op.Op {
+			return fmt.Errorf("invalid storage op [%s]", op.Op)
+		}
+	}
+
+	lock := getPluginStorageLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	kv, err := loadPluginKV(name)
+	if nil != err {
+		return
+	}
+	for _, op := range ops {
+		if PluginStorageOpSet == op.Op {
+			kv[op.Key] = op.Value
+		} else {
+			delete(kv, op.Key)
+		}
+	}
+
+	data, err := gulu.JSON.MarshalJSON(kv)
+	if nil != err {
+		return
+	}
+	usage := getPluginStorageUsage0(name)
+	if quota := pluginStorageQuota(); int64(len(data))+usage.Blobs > quota {
+		return fmt.Errorf("plugin [%s] storage exceeds quota [%s]", name, humanize.BytesCustomCeil(uint64(quota), 2))
+	}
+
+	if err = writePluginStorageFile(filepath.Join(pluginStorageDir(name), "kv.json"), data); nil != err {
+		return
+	}
+	IncSync()
+	return
+}
+
+func PutPluginBlob(name, key string, reader io.Reader) (err error) {
+	if err = checkPluginStorageName(name); nil != err {
+		return
+	}
+	if err = checkPluginStorageKey(key); nil != err {
+		return
+	}
+
+	lock := getPluginStorageLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	usage := getPluginStorageUsage0(name)
+	p := pluginBlobPath(name, key)
+	var old int64
+	if info, statErr := os.Stat(p); nil == statErr {
+		old = info.Size()
+	}
+
+	// 多读一个字节用于判断是否超出配额
+	remain := pluginStorageQuota() - usage.KV - usage.Blobs + old
+	data, err := io.ReadAll(io.LimitReader(reader, remain+1))
+	if nil != err {
+		return
+	}
+	if int64(len(data)) > remain {
+		return fmt.Errorf("plugin [%s] storage exceeds quota [%s]", name, humanize.BytesCustomCeil(uint64(pluginStorageQuota()), 2))
+	}
+
+	if err = writePluginStorageFile(p, data); nil != err {
+		return
+	}
+	IncSync()
+	return
+}
+
+func GetPluginBlobPath(name, key string) (ret string, err error) {
+	if err = checkPluginStorageName(name); nil != err {
+		return
+	}
+	if err = checkPluginStorageKey(key); nil != err {
+		return
+	}
+
+	ret = pluginBlobPath(name, key)
+	if !filelock.IsExist(ret) {
+		err = os.ErrNotExist
+	}
+	return
+}
+
+func RemovePluginBlob(name, key string) (err error) {
+	p, err := GetPluginBlobPath(name, key)
+	if nil != err {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	lock := getPluginStorageLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = filelock.Remove(p); nil != err {
+		return
+	}
+	IncSync()
+	return
+}
+
+func GetPluginStorageUsage(name string) (ret *PluginStorageUsage, err error) {
+	if err = checkPluginStorageName(name); nil != err {
+		return
+	}
+
+	lock := getPluginStorageLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+	ret = getPluginStorageUsage0(name)
+	return
+}
+
+func getPluginStorageUsage0(name string) (ret *PluginStorageUsage) {
+	ret = &PluginStorageUsage{Quota: pluginStorageQuota()}
+	if info, err := os.Stat(filepath.Join(pluginStorageDir(name), "kv.json")); nil == err {
+		ret.KV = info.Size()
+	}
+	blobs := filepath.Join(pluginStorageDir(name), "blobs")
+	if gulu.File.IsDir(blobs) {
+		ret.Blobs, _ = util.SizeOfDirectory(blobs)
+	}
+	return
+}
+
+func pluginStorageQuota() int64 {
+	return int64(Conf.Bazaar.PetalStorageQuota) * 1024 * 1024
+}
+
+func pluginStorageDir(name string) string {
+	return filepath.Join(util.DataDir, "storage", "petal", name)
+}
+
+func pluginBlobPath(name, key string) string {
+	return filepath.Join(pluginStorageDir(name), "blobs", fmt.Sprintf("%x", key))
+}
+
+func loadPluginKV(name string) (ret map[string]interface{}, err error) {
+	ret = map[string]interface{}{}
+	p := filepath.Join(pluginStorageDir(name), "kv.json")
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read plugin [%s] storage failed: %s", name, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal plugin [%s] storage failed: %s", name, err)
+	}
+	return
+}
+
+// writePluginStorageFile 先写入临时文件再重命名，避免写入中断导致数据损坏。
+func writePluginStorageFile(p string, data []byte) (err error) {
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		return
+	}
+
+	tmp := p + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); nil != err {
+		return
+	}
+	if err = filelock.Rename(tmp, p); nil != err {
+		os.Remove(tmp)
+	}
+	return
+}
+
+func checkPluginStorageName(name string) error {
+	if "" == name || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid plugin name [%s]", name)
+	}
+	if !util.IsPathRegularDirOrSymlinkDir(filepath.Join(util.DataDir, "plugins", name)) {
+		return fmt.Errorf("plugin [%s] not found", name)
+	}
+	return nil
+}
+
+func checkPluginStorageKey(key string) error {
+	if "" == key {
+		return errors.New("storage key is empty")
+	}
+	if pluginStorageMaxKeyLen < len(key) {
+		return fmt.Errorf("storage key is longer than %d", pluginStorageMaxKeyLen)
+	}
+	return nil
+}
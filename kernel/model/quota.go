@@ -0,0 +1,193 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+var quotaLock = sync.RWMutex{}
+
+func clampQuota(quota *conf.Quota) {
+	if 0 > quota.MaxDocBlocks || conf.MaxQuotaDocBlocks < quota.MaxDocBlocks {
+		quota.MaxDocBlocks = 100000
+	}
+	if 0 > quota.MaxEmbedResults || conf.MaxQuotaEmbedResults < quota.MaxEmbedResults {
+		quota.MaxEmbedResults = 1024
+	}
+	if 0 > quota.MaxAssetSize || conf.MaxQuotaAssetSize < quota.MaxAssetSize {
+		quota.MaxAssetSize = 0
+	}
+	if nil == quota.Overrides {
+		quota.Overrides = map[string]*conf.QuotaOverride{}
+	}
+	for id, override := range quota.Overrides {
+		if nil == override {
+			delete(quota.Overrides, id)
+			continue
+		}
+		clampQuotaOverride(override)
+	}
+}
+
+func clampQuotaOverride(override *conf.QuotaOverride) {
+	if -1 > override.MaxDocBlocks || conf.MaxQuotaDocBlocks < override.MaxDocBlocks {
+		override.MaxDocBlocks = -1
+	}
+	if -1 > override.MaxEmbedResults || conf.MaxQuotaEmbedResults < override.MaxEmbedResults {
+		override.MaxEmbedResults = -1
+	}
+	if -1 > override.MaxAssetSize || conf.MaxQuotaAssetSize < override.MaxAssetSize {
+		override.MaxAssetSize = -1
+	}
+}
+
+// SetQuota 设置工作空间配额，保留已有的文档配额覆盖。
+func SetQuota(quota *conf.Quota) *conf.Quota {
+	quotaLock.Lock()
+	defer quotaLock.Unlock()
+
+	if nil == quota.Overrides {
+		quota.Overrides = Conf.Quota.Overrides
+	}
+	clampQuota(quota)
+	Conf.Quota = quota
+	Conf.Save()
+	return quota
+}
+
+// GetEffectiveQuota 返回文档实际生效的配额，id 可以是文档中任意块的 ID，为空时返回工作空间配额。
+func GetEffectiveQuota(id string) (ret *conf.QuotaOverride) {
+	return effectiveQuota(quotaRootID(id))
+}
+
+// SetQuotaOverride 为块所在的文档设置配额覆盖。
+func SetQuotaOverride(id string, override *conf.QuotaOverride) (ret *conf.QuotaOverride, err error) {
+	rootID := quotaRootID(id)
+	if "" == rootID {
+		err = ErrBlockNotFound
+		return
+	}
+
+	clampQuotaOverride(override)
+	quotaLock.Lock()
+	Conf.Quota.Overrides[rootID] = override
+	quotaLock.Unlock()
+	Conf.Save()
+	ret = effectiveQuota(rootID)
+	return
+}
+
+// RemoveQuotaOverride 移除块所在文档的配额覆盖，恢复使用工作空间配额。
+func RemoveQuotaOverride(id string) {
+	rootID := quotaRootID(id)
+	if "" == rootID {
+		// 文档已经删除时仍然允许按 ID 清理残留的覆盖
+		rootID = id
+	}
+
+	quotaLock.Lock()
+	delete(Conf.Quota.Overrides, rootID)
+	quotaLock.Unlock()
+	Conf.Save()
+}
+
+func quotaRootID(id string) string {
+	if "" == id {
+		return ""
+	}
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return ""
+	}
+	return bt.RootID
+}
+
+func effectiveQuota(rootID string) (ret *conf.QuotaOverride) {
+	quotaLock.RLock()
+	defer quotaLock.RUnlock()
+
+	ret = &conf.QuotaOverride{
+		MaxDocBlocks:    Conf.Quota.MaxDocBlocks,
+		MaxEmbedResults: Conf.Quota.MaxEmbedResults,
+		MaxAssetSize:    Conf.Quota.MaxAssetSize,
+	}
+	override := Conf.Quota.Overrides[rootID]
+	if nil == override {
+		return
+	}
+	if -1 < override.MaxDocBlocks {
+		ret.MaxDocBlocks = override.MaxDocBlocks
+	}
+	if -1 < override.MaxEmbedResults {
+		ret.MaxEmbedResults = override.MaxEmbedResults
+	}
+	if -1 < override.MaxAssetSize {
+		ret.MaxAssetSize = override.MaxAssetSize
+	}
+	return
+}
+
+// embedResultsLimit 返回嵌入块查询结果数的上限，limit 为调用方原有的上限。
+func embedResultsLimit(rootID string, limit int) int {
+	quota := effectiveQuota(rootID).MaxEmbedResults
+	if 0 < quota && quota < limit {
+		return quota
+	}
+	return limit
+}
+
+// checkAssetSizeQuota 检查上传到块所在文档的资源文件大小是否超出配额。
+func checkAssetSizeQuota(id, name string, size int64) error {
+	quota := effectiveQuota(quotaRootID(id)).MaxAssetSize
+	if 1 > quota || size <= int64(quota)*1024*1024 {
+		return nil
+	}
+	return errors.New(fmt.Sprintf(Conf.language(251), name, quota))
+}
+
+// checkDocBlocksQuota 检查文档块数是否超出配额，originCount 为事务加载文档时的块数。
+// 原本就超出配额的文档仍然允许编辑，只要块数没有继续增加。
+func checkDocBlocksQuota(tree *parse.Tree, originCount int) error {
+	quota := effectiveQuota(tree.ID).MaxDocBlocks
+	if 1 > quota {
+		return nil
+	}
+
+	count := countTreeBlocks(tree)
+	if count <= quota || count <= originCount {
+		return nil
+	}
+	return errors.New(fmt.Sprintf(Conf.language(249), tree.Root.IALAttr("title"), count, quota))
+}
+
+func countTreeBlocks(tree *parse.Tree) (ret int) {
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && "" != n.ID {
+			ret++
+		}
+		return ast.WalkContinue
+	})
+	return
+}
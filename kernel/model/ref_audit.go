@@ -0,0 +1,521 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	RefAuditKindRef   = "ref"   // 引用的定义块不存在
+	RefAuditKindEmbed = "embed" // 嵌入块的查询语句无效
+	RefAuditKindAsset = "asset" // 资源文件不存在
+
+	RefRepairRetarget = "retarget" // 修改引用、查询语句或者资源文件路径
+	RefRepairText     = "text"     // 将引用转换为纯文本
+	RefRepairRestore  = "restore"  // 从快照中恢复定义块或者资源文件
+)
+
+// RefAuditIssue 描述了工作空间检查发现的问题。
+type RefAuditIssue struct {
+	Kind    string `json:"kind"`
+	BlockID string `json:"blockID"` // 问题所在的块
+	RootID  string `json:"rootID"`
+	Box     string `json:"box"`
+	HPath   string `json:"hPath"`
+	Target  string `json:"target"`  // 定义块 ID、查询语句或者资源文件路径
+	Content string `json:"content"` // 引用锚文本
+	Reason  string `json:"reason,omitempty"`
+}
+
+// RefRepair 描述了一个修复操作。
+type RefRepair struct {
+	Kind      string `json:"kind"`
+	Action    string `json:"action"`
+	BlockID   string `json:"blockID"`
+	Target    string `json:"target"`
+	NewTarget string `json:"newTarget"` // retarget 时使用
+	IndexID   string `json:"indexID"`   // restore 时使用的快照
+}
+
+// AuditWorkspace 检查已打开笔记本中失效的引用、无效的嵌入块查询语句和缺失的资源文件。
+func AuditWorkspace() (ret []*RefAuditIssue, err error) {
+	defer logging.Recover()
+	ret = []*RefAuditIssue{}
+
+	assetsPathMap, err := allAssetAbsPaths()
+	if nil != err {
+		return
+	}
+	notebooks, err := ListNotebooks()
+	if nil != err {
+		return
+	}
+
+	luteEngine := util.NewLute()
+	for _, notebook := range notebooks {
+		if notebook.Closed {
+			continue
+		}
+
+		pages := pagedPaths(filepath.Join(util.DataDir, notebook.ID), 32)
+		for _, paths := range pages {
+			for _, localPath := range paths {
+				tree, loadTreeErr := loadTree(localPath, luteEngine)
+				if nil != loadTreeErr {
+					continue
+				}
+				ret = append(ret, auditTree(tree, assetsPathMap)...)
+			}
+		}
+	}
+	return
+}
+
+func auditTree(tree *parse.Tree, assetsPathMap map[string]string) (ret []*RefAuditIssue) {
+	newIssue := func(kind string, block *ast.Node, target string) *RefAuditIssue {
+		issue := &RefAuditIssue{Kind: kind, RootID: tree.ID, Box: tree.Box, HPath: tree.HPath, Target: target}
+		if nil != block {
+			issue.BlockID = block.ID
+		}
+		ret = append(ret, issue)
+		return issue
+	}
+
+	if titleImgPath := treenode.GetDocTitleImgPath(tree.Root); "" != titleImgPath && isMissingAsset(titleImgPath, assetsPathMap) {
+		newIssue(RefAuditKindAsset, tree.Root, titleImgPath)
+	}
+
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if ast.NodeBlockQueryEmbed == n.Type {
+			stmt := getEmbedStmt(n)
+			if checkErr := checkEmbedStmt(stmt); nil != checkErr {
+				newIssue(RefAuditKindEmbed, n, stmt).Reason = checkErr.Error()
+			}
+			return ast.WalkSkipChildren
+		}
+
+		if n.IsBlock() && !n.IsContainerBlock() {
+			for _, dest := range assetsLinkDestsInNode(n) {
+				if isMissingAsset(dest, assetsPathMap) {
+					newIssue(RefAuditKindAsset, n, dest)
+				}
+			}
+			return ast.WalkContinue
+		}
+
+		if ast.NodeTextMark != n.Type {
+			return ast.WalkContinue
+		}
+		defID := textMarkDefID(n)
+		if "" == defID || nil != treenode.GetBlockTree(defID) {
+			return ast.WalkContinue
+		}
+		newIssue(RefAuditKindRef, treenode.ParentBlock(n), defID).Content = n.TextMarkTextContent
+		return ast.WalkContinue
+	})
+	return
+}
+
+// RepairRefIssues 在一个事务中应用修复操作，恢复快照中的资源文件和文档不在事务中。
+func RepairRefIssues(repairs []*RefRepair) (ret []*Transaction, err error) {
+	if 1 > len(repairs) {
+		return
+	}
+
+	luteEngine := util.NewLute()
+	trees := map[string]*parse.Tree{}
+	changed := map[string]*ast.Node{}
+	var changedIDs []string
+	tx := &Transaction{}
+	for _, repair := range repairs {
+		if RefRepairRestore == repair.Action {
+			if err = restoreRefTarget(repair, tx, luteEngine); nil != err {
+				return
+			}
+			continue
+		}
+
+		bt := treenode.GetBlockTree(repair.BlockID)
+		if nil == bt {
+			err = ErrBlockNotFound
+			return
+		}
+		tree := trees[bt.RootID]
+		if nil == tree {
+			if tree, err = LoadTreeByBlockID(bt.RootID); nil != err {
+				return
+			}
+			trees[bt.RootID] = tree
+		}
+		node := treenode.GetNodeInTree(tree, repair.BlockID)
+		if nil == node {
+			err = ErrBlockNotFound
+			return
+		}
+
+		if ast.NodeDocument == node.Type {
+			// 文档上只有题头图可能引用资源文件
+			if RefRepairRetarget != repair.Action || RefAuditKindAsset != repair.Kind || "" == strings.TrimSpace(repair.NewTarget) {
+				err = errors.New("can not repair a document")
+				return
+			}
+			titleImg := strings.ReplaceAll(node.IALAttr("title-img"), repair.Target, strings.TrimSpace(repair.NewTarget))
+			if err = SetBlockAttrs(node.ID, map[string]string{"title-img": titleImg}); nil != err {
+				return
+			}
+			continue
+		}
+
+		if nil == changed[node.ID] {
+			tx.UndoOperations = append(tx.UndoOperations, &Operation{Action: "update", ID: node.ID, Data: luteEngine.RenderNodeBlockDOM(node)})
+			changed[node.ID] = node
+			changedIDs = append(changedIDs, node.ID)
+		}
+
+		switch repair.Action {
+		case RefRepairRetarget:
+			err = retargetRef(node, repair)
+		case RefRepairText:
+			err = refToText(node, repair)
+		default:
+			err = fmt.Errorf("invalid repair action [%s]", repair.Action)
+		}
+		if nil != err {
+			return
+		}
+	}
+
+	for _, id := range changedIDs {
+		tx.DoOperations = append(tx.DoOperations, &Operation{Action: "update", ID: id, Data: luteEngine.RenderNodeBlockDOM(changed[id])})
+	}
+	if 1 > len(tx.DoOperations) {
+		return
+	}
+
+	ret = []*Transaction{tx}
+	PerformTransactions(&ret)
+	WaitForWritingFiles()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = ret
+	util.PushEvent(evt)
+	return
+}
+
+func retargetRef(node *ast.Node, repair *RefRepair) (err error) {
+	newTarget := strings.TrimSpace(repair.NewTarget)
+	if "" == newTarget {
+		err = errors.New("new target is empty")
+		return
+	}
+
+	switch repair.Kind {
+	case RefAuditKindRef:
+		defTree, _ := LoadTreeByBlockID(newTarget)
+		if nil == defTree {
+			err = ErrBlockNotFound
+			return
+		}
+		refText := getNodeRefText(treenode.GetNodeInTree(defTree, newTarget))
+		ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || ast.NodeTextMark != n.Type || repair.Target != textMarkDefID(n) {
+				return ast.WalkContinue
+			}
+			if n.IsTextMarkType("block-ref") {
+				n.TextMarkBlockRefID = newTarget
+				if "d" == n.TextMarkBlockRefSubtype {
+					n.TextMarkTextContent = refText
+				}
+			}
+			if n.IsTextMarkType("a") {
+				n.TextMarkAHref = strings.Replace(n.TextMarkAHref, repair.Target, newTarget, 1)
+			}
+			return ast.WalkContinue
+		})
+	case RefAuditKindEmbed:
+		if err = checkEmbedStmt(newTarget); nil != err {
+			return
+		}
+		if ast.NodeBlockQueryEmbed != node.Type {
+			err = errors.New("not a query embed block")
+			return
+		}
+		if script := node.ChildByType(ast.NodeBlockQueryEmbedScript); nil != script {
+			script.Tokens = []byte(html.EscapeString(strings.ReplaceAll(newTarget, "\n", editor.IALValEscNewLine)))
+		}
+	case RefAuditKindAsset:
+		ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering {
+				return ast.WalkContinue
+			}
+			switch n.Type {
+			case ast.NodeLinkDest:
+				if repair.Target == strings.TrimSpace(string(n.Tokens)) {
+					n.Tokens = []byte(newTarget)
+				}
+			case ast.NodeTextMark:
+				if n.IsTextMarkType("a") && repair.Target == strings.TrimSpace(n.TextMarkAHref) {
+					n.TextMarkAHref = newTarget
+				}
+			case ast.NodeHTMLBlock, ast.NodeInlineHTML, ast.NodeIFrame, ast.NodeAudio, ast.NodeVideo:
+				n.Tokens = bytes.ReplaceAll(n.Tokens, []byte(repair.Target), []byte(newTarget))
+			}
+			return ast.WalkContinue
+		})
+	default:
+		err = fmt.Errorf("can not retarget [%s]", repair.Kind)
+	}
+	return
+}
+
+func refToText(node *ast.Node, repair *RefRepair) (err error) {
+	if RefAuditKindRef != repair.Kind {
+		err = fmt.Errorf("can not convert [%s] to text", repair.Kind)
+		return
+	}
+
+	var refs []*ast.Node
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && ast.NodeTextMark == n.Type && repair.Target == textMarkDefID(n) {
+			refs = append(refs, n)
+		}
+		return ast.WalkContinue
+	})
+
+	for _, ref := range refs {
+		var types []string
+		for _, typ := range strings.Split(ref.TextMarkType, " ") {
+			if "block-ref" != typ && "a" != typ && "" != typ {
+				types = append(types, typ)
+			}
+		}
+		if 1 > len(types) {
+			ref.InsertBefore(&ast.Node{Type: ast.NodeText, Tokens: []byte(ref.TextMarkTextContent)})
+			ref.Unlink()
+			continue
+		}
+		// 保留其他行级元素类型，比如加粗
+		ref.TextMarkType = strings.Join(types, " ")
+		ref.TextMarkBlockRefID, ref.TextMarkBlockRefSubtype, ref.TextMarkAHref = "", "", ""
+	}
+	return
+}
+
+// restoreRefTarget 从快照中恢复资源文件或者被删除的定义块。
+//
+// 定义块所在的文档还存在时，将定义块插入到快照中的前一个兄弟块之后，找不到时追加到文档末尾；文档不存在时恢复整个文档。
+func restoreRefTarget(repair *RefRepair, tx *Transaction, luteEngine *lute.Lute) (err error) {
+	if "" == repair.IndexID {
+		err = errors.New("snapshot is not specified")
+		return
+	}
+
+	switch repair.Kind {
+	case RefAuditKindAsset:
+		dest := repair.Target
+		if idx := strings.Index(dest, "?"); 0 < idx {
+			dest = dest[:idx]
+		}
+		_, err = RestoreSnapshotAsset(repair.IndexID, dest)
+		return
+	case RefAuditKindRef:
+	default:
+		err = fmt.Errorf("can not restore [%s]", repair.Kind)
+		return
+	}
+
+	if nil != treenode.GetBlockTree(repair.Target) {
+		return
+	}
+
+	snapshotTree, boxID, err := findSnapshotTreeByBlockID(repair.IndexID, repair.Target, luteEngine)
+	if nil != err {
+		return
+	}
+	if nil == Conf.Box(boxID) {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+
+	if nil == treenode.GetBlockTree(snapshotTree.ID) {
+		// 文档不存在，恢复到原父文档下，父文档不存在时恢复到笔记本根路径下
+		snapshotTree.Box = boxID
+		parentID := path.Base(path.Dir(snapshotTree.Path))
+		if parent := treenode.GetBlockTree(parentID); nil != parent && parent.BoxID == boxID {
+			snapshotTree.Path = strings.TrimSuffix(parent.Path, ".sy") + "/" + snapshotTree.ID + ".sy"
+			snapshotTree.HPath = parent.HPath + "/" + snapshotTree.Root.IALAttr("title")
+		} else {
+			snapshotTree.Path = "/" + snapshotTree.ID + ".sy"
+			snapshotTree.HPath = "/" + snapshotTree.Root.IALAttr("title")
+		}
+		createTreeTx(snapshotTree)
+		WaitForWritingFiles()
+		return
+	}
+
+	node := treenode.GetNodeInTree(snapshotTree, repair.Target)
+	if nil == node {
+		err = ErrBlockNotFound
+		return
+	}
+	previousID := ""
+	for prev := node.Previous; nil != prev; prev = prev.Previous {
+		if "" != prev.ID && nil != treenode.GetBlockTree(prev.ID) {
+			previousID = prev.ID
+			break
+		}
+	}
+	if ast.NodeListItem == node.Type && "" == previousID {
+		// 前面没有列表项时需要包裹在列表中追加到文档末尾
+		list := &ast.Node{ID: ast.NewNodeID(), Type: ast.NodeList, ListData: &ast.ListData{Typ: node.Parent.ListData.Typ}}
+		list.SetIALAttr("id", list.ID)
+		list.SetIALAttr("updated", list.ID[:14])
+		list.AppendChild(node)
+		node = list
+	}
+
+	op := &Operation{Action: "appendInsert", ParentID: snapshotTree.ID, Data: luteEngine.RenderNodeBlockDOM(node)}
+	if "" != previousID {
+		op = &Operation{Action: "insert", PreviousID: previousID, Data: op.Data}
+	}
+	tx.DoOperations = append(tx.DoOperations, op)
+	tx.UndoOperations = append(tx.UndoOperations, &Operation{Action: "delete", ID: node.ID})
+	return
+}
+
+func findSnapshotTreeByBlockID(indexID, id string, luteEngine *lute.Lute) (ret *parse.Tree, boxID string, err error) {
+	repo, err := newSnapshotRepository()
+	if nil != err {
+		return
+	}
+	files, err := getSnapshotFiles(repo, indexID)
+	if nil != err {
+		return
+	}
+
+	// 先按文档 ID 查找，找不到时再查找文档内容
+	var candidates []string
+	for _, file := range files {
+		if path.Base(file.Path) == id+".sy" {
+			candidates = append([]string{file.ID}, candidates...)
+		} else if strings.HasSuffix(file.Path, ".sy") && 2 < strings.Count(file.Path, "/") {
+			candidates = append(candidates, file.ID)
+		}
+	}
+
+	idBytes := []byte("\"" + id + "\"")
+	for _, fileID := range candidates {
+		file, getErr := repo.GetFile(fileID)
+		if nil != getErr {
+			continue
+		}
+		data, openErr := repo.OpenFile(file)
+		if nil != openErr || !bytes.Contains(data, idBytes) {
+			continue
+		}
+		if _, ret, err = parseTreeInSnapshot(data, luteEngine); nil != err {
+			return
+		}
+		if nil == treenode.GetNodeInTree(ret, id) {
+			ret = nil
+			continue
+		}
+		boxID = strings.Split(strings.TrimPrefix(file.Path, "/"), "/")[0]
+		ret.Path = strings.TrimPrefix(file.Path, "/"+boxID)
+		return
+	}
+	err = fmt.Errorf("block [%s] not found in snapshot", id)
+	return
+}
+
+// textMarkDefID 返回块引用或者块超链接指向的块 ID。
+func textMarkDefID(n *ast.Node) string {
+	if n.IsTextMarkType("block-ref") {
+		return n.TextMarkBlockRefID
+	}
+	if n.IsTextMarkType("a") && strings.HasPrefix(n.TextMarkAHref, "siyuan://blocks/") {
+		defID := strings.TrimPrefix(n.TextMarkAHref, "siyuan://blocks/")
+		if idx := strings.Index(defID, "?"); 0 < idx {
+			defID = defID[:idx]
+		}
+		return defID
+	}
+	return ""
+}
+
+func getEmbedStmt(n *ast.Node) string {
+	script := n.ChildByType(ast.NodeBlockQueryEmbedScript)
+	if nil == script {
+		return ""
+	}
+	stmt := html.UnescapeString(script.TokensStr())
+	return strings.ReplaceAll(stmt, editor.IALValEscNewLine, "\n")
+}
+
+// checkEmbedStmt 检查嵌入块的查询语句，只执行 SELECT 语句，JavaScript 脚本不检查。
+func checkEmbedStmt(stmt string) (err error) {
+	stmt = strings.TrimSpace(stmt)
+	if "" == stmt {
+		return errors.New("query statement is empty")
+	}
+	if strings.HasPrefix(stmt, "//!js") {
+		return
+	}
+	lower := strings.ToLower(stmt)
+	if !strings.HasPrefix(lower, "select") && !strings.HasPrefix(lower, "with") {
+		return errors.New("query statement is not a SELECT statement")
+	}
+	_, err = sql.Query(stmt, 1)
+	return
+}
+
+func isMissingAsset(dest string, assetsPathMap map[string]string) bool {
+	if !strings.HasPrefix(dest, "assets/") {
+		return false
+	}
+	if idx := strings.Index(dest, "?"); 0 < idx {
+		dest = dest[:idx]
+	}
+	if strings.HasSuffix(dest, "/") || "" != assetsPathMap[dest] || IsOffloadedAsset(dest) {
+		return false
+	}
+	if strings.HasPrefix(dest, "assets/.") {
+		return !filelock.IsExist(filepath.Join(util.DataDir, dest))
+	}
+	return true
+}
@@ -0,0 +1,463 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Reminder 描述了块上的提醒，每个块最多一个提醒。
+//
+// 提醒定义随数据同步，触发状态只保存在当前设备上，避免多个设备互相覆盖。
+type Reminder struct {
+	ID         string `json:"id"`         // 块 ID
+	Time       int64  `json:"time"`       // 提醒时间，重复提醒时为第一次提醒的时间，Unix 时间戳（毫秒）
+	Recurrence string `json:"recurrence"` // 重复规则，比如 FREQ=WEEKLY;BYDAY=MO,FR，为空表示不重复
+	Snoozed    int64  `json:"snoozed"`    // 推迟到的时间，为 0 表示没有推迟
+	Done       bool   `json:"done"`       // 是否已完成
+	Created    int64  `json:"created"`
+	Updated    int64  `json:"updated"` // 最近一次设置、推迟或完成的时间
+}
+
+// ReminderInfo 描述了列出提醒时的结果。
+type ReminderInfo struct {
+	*Reminder
+	Next    int64  `json:"next"`  // 下次提醒时间，为 0 表示不会再提醒
+	Fired   int64  `json:"fired"` // 当前设备上最近一次提醒的时间
+	RootID  string `json:"rootID"`
+	Box     string `json:"box"`
+	HPath   string `json:"hPath"`
+	Content string `json:"content"`
+}
+
+// ReminderFiredEvent 描述了一次提醒触发事件。
+type ReminderFiredEvent struct {
+	Event      string `json:"event"`
+	ID         string `json:"id"`
+	RootID     string `json:"rootID"`
+	Box        string `json:"box"`
+	Type       string `json:"type"`
+	Content    string `json:"content"`
+	Time       int64  `json:"time"` // 本次应提醒的时间
+	Recurrence string `json:"recurrence"`
+	Snoozed    bool   `json:"snoozed"` // 是否是推迟后的提醒
+}
+
+// reminderState 保存当前设备上的提醒触发状态，不参与同步。
+type reminderState struct {
+	LastFired map[string]int64 `json:"lastFired"`
+}
+
+// 内核停止期间错过的提醒只补发该时长内的，更早的直接跳过
+const reminderCatchUpWindow = 24 * time.Hour
+
+var reminderLock = sync.Mutex{}
+
+func init() {
+	eventbus.Subscribe(util.EvtReminderFired, func(evt *ReminderFiredEvent) {
+		util.BroadcastByType("main", "reminder", 0, "", map[string]interface{}{
+			"reminder":           evt,
+			"systemNotification": Conf.Reminder.SystemNotification,
+		})
+		go postReminderWebhooks(evt)
+	})
+}
+
+func GetReminders(includeDone bool) (ret []*ReminderInfo) {
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	ret = []*ReminderInfo{}
+	state := loadReminderState()
+	for _, reminder := range loadReminders() {
+		if reminder.Done && !includeDone {
+			continue
+		}
+
+		info := &ReminderInfo{Reminder: reminder, Fired: state.LastFired[reminder.ID]}
+		info.Next = reminder.due(state.base(reminder))
+		if bt := treenode.GetBlockTree(reminder.ID); nil != bt {
+			info.RootID, info.Box, info.HPath = bt.RootID, bt.BoxID, bt.HPath
+		}
+		if b := sql.GetBlock(reminder.ID); nil != b {
+			info.Content = b.Content
+		}
+		ret = append(ret, info)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if 0 == ret[i].Next || 0 == ret[j].Next {
+			return 0 != ret[i].Next
+		}
+		return ret[i].Next < ret[j].Next
+	})
+	return
+}
+
+// SetReminder 设置块上的提醒，已有提醒时替换并重新开始计算。
+func SetReminder(id string, t int64, recurrence string) (ret *Reminder, err error) {
+	if nil == treenode.GetBlockTree(id) {
+		err = ErrBlockNotFound
+		return
+	}
+	if 1 > t {
+		err = errors.New("reminder time is required")
+		return
+	}
+	recurrence = strings.TrimSpace(recurrence)
+	if "" != recurrence {
+		if _, err = util.ParseRecurrence(recurrence); nil != err {
+			return
+		}
+	}
+
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	now := util.CurrentTimeMillis()
+	ret = &Reminder{ID: id, Time: t, Recurrence: recurrence, Created: now, Updated: now}
+	reminders := loadReminders()
+	found := false
+	for i, reminder := range reminders {
+		if reminder.ID == id {
+			ret.Created = reminder.Created
+			reminders[i] = ret
+			found = true
+			break
+		}
+	}
+	if !found {
+		reminders = append(reminders, ret)
+	}
+	if err = saveReminders(reminders); nil != err {
+		return
+	}
+
+	state := loadReminderState()
+	delete(state.LastFired, id)
+	saveReminderState(state)
+	return
+}
+
+func RemoveReminder(id string) (err error) {
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	var tmp []*Reminder
+	for _, reminder := range loadReminders() {
+		if reminder.ID != id {
+			tmp = append(tmp, reminder)
+		}
+	}
+	if err = saveReminders(tmp); nil != err {
+		return
+	}
+
+	state := loadReminderState()
+	delete(state.LastFired, id)
+	saveReminderState(state)
+	return
+}
+
+// SnoozeReminder 将提醒推迟 minutes 分钟。
+func SnoozeReminder(id string, minutes int) (ret *Reminder, err error) {
+	if 1 > minutes {
+		err = errors.New("snooze minutes must be greater than 0")
+		return
+	}
+
+	ret, err = updateReminder(id, func(reminder *Reminder, now int64) {
+		reminder.Snoozed = now + int64(minutes)*time.Minute.Milliseconds()
+		reminder.Done = false
+	})
+	return
+}
+
+// CompleteReminder 完成提醒。重复提醒只完成本次，没有后续重复时才标记为已完成。
+func CompleteReminder(id string) (ret *Reminder, err error) {
+	ret, err = updateReminder(id, func(reminder *Reminder, now int64) {
+		reminder.Snoozed = 0
+		reminder.Done = true
+		if "" == reminder.Recurrence {
+			return
+		}
+
+		recurrence, parseErr := util.ParseRecurrence(reminder.Recurrence)
+		if nil != parseErr {
+			return
+		}
+		reminder.Done = recurrence.Next(time.UnixMilli(reminder.Time), time.UnixMilli(now)).IsZero()
+	})
+	if nil != err {
+		return
+	}
+
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+	state := loadReminderState()
+	state.LastFired[id] = util.CurrentTimeMillis()
+	saveReminderState(state)
+	return
+}
+
+func updateReminder(id string, update func(reminder *Reminder, now int64)) (ret *Reminder, err error) {
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders := loadReminders()
+	for _, reminder := range reminders {
+		if reminder.ID == id {
+			now := util.CurrentTimeMillis()
+			update(reminder, now)
+			reminder.Updated = now
+			if err = saveReminders(reminders); nil != err {
+				return
+			}
+			ret = reminder
+			return
+		}
+	}
+	err = fmt.Errorf("reminder [%s] not found", id)
+	return
+}
+
+// SetReminderConf 设置提醒的通知方式。
+func SetReminderConf(systemNotification bool, urls []string) (err error) {
+	var webhooks []string
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if "" == url {
+			continue
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			err = fmt.Errorf("invalid webhook [%s]", url)
+			return
+		}
+		webhooks = append(webhooks, url)
+	}
+	webhooks = gulu.Str.RemoveDuplicatedElem(webhooks)
+	if nil == webhooks {
+		webhooks = []string{}
+	}
+
+	Conf.Reminder.SystemNotification = systemNotification
+	Conf.Reminder.Webhooks = webhooks
+	Conf.Save()
+	return
+}
+
+// ReminderJob 触发到期的提醒，界面未打开时也会执行以便回调 Webhook。
+func ReminderJob() {
+	if !util.IsBooted() || util.IsExiting.Load() {
+		return
+	}
+
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders := loadReminders()
+	if 1 > len(reminders) {
+		return
+	}
+
+	state := loadReminderState()
+	now := time.Now()
+	changed := false
+	for _, reminder := range reminders {
+		due := reminder.due(state.base(reminder))
+		if 1 > due || due > now.UnixMilli() {
+			continue
+		}
+
+		state.LastFired[reminder.ID] = now.UnixMilli()
+		changed = true
+		if time.UnixMilli(due).Before(now.Add(-reminderCatchUpWindow)) {
+			logging.LogInfof("skip missed reminder [%s] due at [%s]", reminder.ID, time.UnixMilli(due).Format("2006-01-02 15:04:05"))
+			continue
+		}
+
+		bt := treenode.GetBlockTree(reminder.ID)
+		if nil == bt {
+			// 块可能已经删除或者还未同步过来
+			continue
+		}
+
+		evt := &ReminderFiredEvent{
+			Event:      util.EvtReminderFired,
+			ID:         reminder.ID,
+			RootID:     bt.RootID,
+			Box:        bt.BoxID,
+			Type:       bt.Type,
+			Time:       due,
+			Recurrence: reminder.Recurrence,
+			Snoozed:    due == reminder.Snoozed,
+		}
+		if b := sql.GetBlock(reminder.ID); nil != b {
+			evt.Content = b.Content
+		}
+		eventbus.Publish(util.EvtReminderFired, evt)
+	}
+	if changed {
+		saveReminderState(state)
+	}
+}
+
+// due 返回 base 之后应提醒的时间，为 0 表示不会再提醒。
+func (reminder *Reminder) due(base int64) int64 {
+	if reminder.Done {
+		return 0
+	}
+	if base < reminder.Snoozed {
+		return reminder.Snoozed
+	}
+	if "" == reminder.Recurrence {
+		if base < reminder.Time {
+			return reminder.Time
+		}
+		return 0
+	}
+
+	recurrence, err := util.ParseRecurrence(reminder.Recurrence)
+	if nil != err {
+		return 0
+	}
+	next := recurrence.Next(time.UnixMilli(reminder.Time), time.UnixMilli(base))
+	if next.IsZero() {
+		return 0
+	}
+	return next.UnixMilli()
+}
+
+// base 返回计算下次提醒的起点。当前设备上还没有提醒过时，不重复的提醒从头开始计算，重复提醒从最近一次设置时开始计算。
+func (state *reminderState) base(reminder *Reminder) int64 {
+	if lastFired := state.LastFired[reminder.ID]; 0 < lastFired {
+		return lastFired
+	}
+	if "" == reminder.Recurrence {
+		return 0
+	}
+	return reminder.Updated
+}
+
+func postReminderWebhooks(evt *ReminderFiredEvent) {
+	defer logging.Recover()
+
+	for _, url := range Conf.Reminder.Webhooks {
+		if "" == strings.TrimSpace(url) {
+			continue
+		}
+
+		resp, err := httpclient.NewBrowserRequest().SetBody(evt).Post(url)
+		if nil != err {
+			logging.LogWarnf("post reminder to webhook [%s] failed: %s", url, err)
+			continue
+		}
+		if 200 > resp.StatusCode || 300 <= resp.StatusCode {
+			logging.LogWarnf("post reminder to webhook [%s] failed, status code [%d]", url, resp.StatusCode)
+		}
+	}
+}
+
+func loadReminders() (ret []*Reminder) {
+	ret = []*Reminder{}
+	p := filepath.Join(util.DataDir, "storage", "reminders.json")
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read reminders failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal reminders failed: %s", err)
+	}
+	return
+}
+
+func saveReminders(reminders []*Reminder) (err error) {
+	if nil == reminders {
+		reminders = []*Reminder{}
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(reminders, "", "  ")
+	if nil != err {
+		return
+	}
+
+	p := filepath.Join(util.DataDir, "storage", "reminders.json")
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		return
+	}
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write reminders failed: %s", err)
+		return
+	}
+	IncSync()
+	return
+}
+
+func loadReminderState() (ret *reminderState) {
+	ret = &reminderState{LastFired: map[string]int64{}}
+	p := filepath.Join(util.TempDir, "reminder-state.json")
+	if !gulu.File.IsExist(p) {
+		return
+	}
+
+	data, err := os.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read reminder state failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		logging.LogErrorf("unmarshal reminder state failed: %s", err)
+	}
+	if nil == ret.LastFired {
+		ret.LastFired = map[string]int64{}
+	}
+	return
+}
+
+func saveReminderState(state *reminderState) {
+	data, err := gulu.JSON.MarshalJSON(state)
+	if nil != err {
+		logging.LogErrorf("marshal reminder state failed: %s", err)
+		return
+	}
+
+	p := filepath.Join(util.TempDir, "reminder-state.json")
+	if err = gulu.File.WriteFileSafer(p, data, 0644); nil != err {
+		logging.LogErrorf("write reminder state failed: %s", err)
+	}
+}
@@ -632,8 +632,11 @@ func checkoutRepo(id string) {
 
 	util.PushEndlessProgress(Conf.Language(63))
 	WaitForWritingFiles()
+	filesys.FlushTreeSaves()
 	CloseWatchAssets()
 	defer WatchAssets()
+	CloseWatchData()
+	defer WatchData()
 
 	// 恢复快照时自动暂停同步，避免刚刚恢复后的数据又被同步覆盖
 	syncEnabled := Conf.Sync.Enabled
@@ -957,6 +960,7 @@ func IndexRepo(memo string) (err error) {
 	start := time.Now()
 	latest, _ := repo.Latest()
 	WaitForWritingFiles()
+	filesys.FlushTreeSaves()
 	index, err := repo.Index(memo, map[string]interface{}{
 		eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBarAndProgress,
 	})
@@ -970,6 +974,7 @@ func IndexRepo(memo string) (err error) {
 		msg := fmt.Sprintf(Conf.Language(147), elapsed.Seconds())
 		util.PushStatusBar(msg)
 		util.PushMsg(msg, 5000)
+		publishSnapshotCreated(index, "")
 	} else {
 		msg := fmt.Sprintf(Conf.Language(148), elapsed.Seconds())
 		util.PushStatusBar(msg)
@@ -1476,6 +1481,7 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 
 	if needReloadFlashcard {
 		LoadFlashcards()
+		reconcileFlashcardReviews()
 	}
 
 	if needReloadOcrTexts {
@@ -1584,6 +1590,7 @@ var promotedPurgeDataRepo bool
 
 func indexRepoBeforeCloudSync(repo *dejavu.Repo) (beforeIndex, afterIndex *entity.Index, err error) {
 	start := time.Now()
+	filesys.FlushTreeSaves()
 	beforeIndex, _ = repo.Latest()
 	afterIndex, err = repo.Index("[Sync] Cloud sync", map[string]interface{}{
 		eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar,
@@ -1603,6 +1610,7 @@ func indexRepoBeforeCloudSync(repo *dejavu.Repo) (beforeIndex, afterIndex *entit
 			return
 		}
 		util.PushStatusBar(fmt.Sprintf(Conf.Language(147), elapsed.Seconds()))
+		publishSnapshotCreated(afterIndex, "")
 	} else {
 		util.PushStatusBar(fmt.Sprintf(Conf.Language(148), elapsed.Seconds()))
 	}
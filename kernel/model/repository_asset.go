@@ -0,0 +1,236 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// SnapshotAsset 描述了快照中的资源文件或者文件夹。
+type SnapshotAsset struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"` // 相对于 data 的路径，比如 assets/foo/bar.png
+	IsDir   bool   `json:"isDir"`
+	FileID  string `json:"fileID"`
+	Size    int64  `json:"size"` // 文件夹时为其下所有文件的大小之和
+	HSize   string `json:"hSize"`
+	Updated int64  `json:"updated"`
+	Count   int    `json:"count"`   // 文件夹下的文件数量
+	Exists  bool   `json:"exists"`  // 工作空间中是否存在同路径的文件
+	Changed bool   `json:"changed"` // 工作空间中的文件是否和快照中的不同
+}
+
+// GetSnapshotAssets 列出快照中 dir 目录下的资源文件和文件夹，dir 为空时列出 assets 目录。
+func GetSnapshotAssets(indexID, dir string) (ret []*SnapshotAsset, err error) {
+	ret = []*SnapshotAsset{}
+	dir, err = normalizeSnapshotAssetPath(dir)
+	if nil != err {
+		return
+	}
+
+	repo, err := newSnapshotRepository()
+	if nil != err {
+		return
+	}
+
+	files, err := getSnapshotFiles(repo, indexID)
+	if nil != err {
+		return
+	}
+
+	prefix := "/" + dir + "/"
+	dirs := map[string]*SnapshotAsset{}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Path, prefix) {
+			continue
+		}
+
+		rel := strings.TrimPrefix(file.Path, prefix)
+		if idx := strings.Index(rel, "/"); 0 < idx {
+			name := rel[:idx]
+			d := dirs[name]
+			if nil == d {
+				d = &SnapshotAsset{Name: name, Path: path.Join(dir, name), IsDir: true}
+				dirs[name] = d
+				ret = append(ret, d)
+			}
+			d.Size += file.Size
+			d.Count++
+			if d.Updated < file.Updated {
+				d.Updated = file.Updated
+			}
+			continue
+		}
+
+		asset := &SnapshotAsset{
+			Name:    rel,
+			Path:    path.Join(dir, rel),
+			FileID:  file.ID,
+			Size:    file.Size,
+			Updated: file.Updated,
+			Count:   1,
+		}
+		if info, statErr := os.Stat(filepath.Join(util.DataDir, asset.Path)); nil == statErr && !info.IsDir() {
+			asset.Exists = true
+			delta := info.ModTime().UnixMilli() - file.Updated
+			asset.Changed = info.Size() != file.Size || 1000 < delta || -1000 > delta
+		}
+		ret = append(ret, asset)
+	}
+
+	for _, asset := range ret {
+		asset.HSize = humanize.BytesCustomCeil(uint64(asset.Size), 2)
+		if asset.IsDir {
+			asset.Exists = gulu.File.IsDir(filepath.Join(util.DataDir, asset.Path))
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].IsDir != ret[j].IsDir {
+			return ret[i].IsDir
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return
+}
+
+// RestoreSnapshotAsset 将快照中的资源文件或者文件夹恢复到工作空间，不回滚文档。
+// 被覆盖的资源文件会先生成历史，以便撤销恢复操作。
+func RestoreSnapshotAsset(indexID, p string) (count int, err error) {
+	p, err = normalizeSnapshotAssetPath(p)
+	if nil != err {
+		return
+	}
+	if "assets" == p {
+		err = errors.New("can't restore the whole assets folder, use checkout snapshot instead")
+		return
+	}
+
+	repo, err := newSnapshotRepository()
+	if nil != err {
+		return
+	}
+
+	files, err := getSnapshotFiles(repo, indexID)
+	if nil != err {
+		return
+	}
+
+	var restores []*entity.File
+	for _, file := range files {
+		if "/"+p == file.Path || strings.HasPrefix(file.Path, "/"+p+"/") {
+			restores = append(restores, file)
+		}
+	}
+	if 1 > len(restores) {
+		err = fmt.Errorf("asset [%s] not found in snapshot", p)
+		return
+	}
+
+	historyDir, err := GetHistoryDir(HistoryOpReplace)
+	if nil != err {
+		return
+	}
+
+	var overwritten bool
+	for _, file := range restores {
+		data, openErr := repo.OpenFile(file)
+		if nil != openErr {
+			logging.LogErrorf("open snapshot file [%s] failed: %s", file.Path, openErr)
+			err = openErr
+			break
+		}
+
+		absPath := filepath.Join(util.DataDir, file.Path)
+		if filelock.IsExist(absPath) {
+			historyPath := filepath.Join(historyDir, file.Path)
+			if copyErr := filelock.Copy(absPath, historyPath); nil != copyErr {
+				logging.LogErrorf("copy file [%s] to [%s] failed: %s", absPath, historyPath, copyErr)
+				err = copyErr
+				break
+			}
+			overwritten = true
+		}
+
+		if err = filelock.WriteFile(absPath, data); nil != err {
+			logging.LogErrorf("write file [%s] failed: %s", absPath, err)
+			break
+		}
+		updated := time.UnixMilli(file.Updated)
+		if chErr := os.Chtimes(absPath, updated, updated); nil != chErr {
+			logging.LogWarnf("change times of [%s] failed: %s", absPath, chErr)
+		}
+		IndexAssetContent(absPath)
+		count++
+	}
+
+	if overwritten {
+		indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+	} else {
+		os.RemoveAll(historyDir)
+	}
+
+	if 0 < count {
+		IncSync()
+		util.PushMsg(Conf.Language(102), 3000)
+	}
+	return
+}
+
+func newSnapshotRepository() (ret *dejavu.Repo, err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+	return newRepository()
+}
+
+func getSnapshotFiles(repo *dejavu.Repo, indexID string) (ret []*entity.File, err error) {
+	index, err := repo.GetIndex(indexID)
+	if nil != err {
+		return
+	}
+	ret, err = repo.GetFiles(index)
+	return
+}
+
+// normalizeSnapshotAssetPath 规范化资源文件路径，只允许访问 assets 目录。
+func normalizeSnapshotAssetPath(p string) (ret string, err error) {
+	ret = strings.Trim(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+	if "" == ret {
+		ret = "assets"
+	}
+	if "assets" != ret && !strings.HasPrefix(ret, "assets/") {
+		err = fmt.Errorf("invalid asset path [%s]", p)
+	}
+	return
+}
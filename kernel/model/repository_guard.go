@@ -0,0 +1,180 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 风险操作类型，在执行这些操作前会自动生成数据快照
+const (
+	RiskyOpRemoveNotebook = "removeNotebook"
+	RiskyOpReplace        = "replace"
+	RiskyOpImport         = "import"
+	RiskyOpTemplate       = "template"
+)
+
+const (
+	preOperationSnapshotTagPrefix = "auto-"
+	preOperationSnapshotMaxTags   = 16              // 最多保留的自动快照标记数，超过后移除最旧的标记
+	preOperationSnapshotInterval  = time.Minute     // 相同操作在该时间内复用上一个自动快照
+	preOperationImportMinSize     = 8 * 1024 * 1024 // 导入大于该大小的数据时才生成快照
+)
+
+var (
+	lastPreOperationSnapshots    = map[string]*preOperationSnapshot{}
+	lastPreOperationSnapshotLock = sync.Mutex{}
+)
+
+type preOperationSnapshot struct {
+	id      string
+	created time.Time
+}
+
+// createPreOperationSnapshot 在风险操作执行前生成一个带标记的数据快照，返回快照 ID。
+//
+// 未设置数据仓库密钥时不生成快照。生成失败不会阻止后续操作，仅记录日志。
+func createPreOperationSnapshot(op, desc string) (id string) {
+	if 1 > len(Conf.Repo.Key) {
+		return
+	}
+
+	lastPreOperationSnapshotLock.Lock()
+	defer lastPreOperationSnapshotLock.Unlock()
+
+	if last := lastPreOperationSnapshots[op]; nil != last && time.Since(last.created) < preOperationSnapshotInterval {
+		return last.id
+	}
+
+	repo, err := newRepository()
+	if nil != err {
+		logging.LogErrorf("new repository failed: %s", err)
+		return
+	}
+
+	WaitForWritingFiles()
+	filesys.FlushTreeSaves()
+	memo := "[Auto] Before " + op
+	if "" != desc {
+		memo += " " + desc
+	}
+	index, err := repo.Index(memo, map[string]interface{}{
+		eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar,
+	})
+	if nil != err {
+		logging.LogErrorf("index data repo before [%s] failed: %s", op, err)
+		return
+	}
+
+	now := time.Now()
+	tag := preOperationSnapshotTagPrefix + op + "-" + now.Format("20060102150405")
+	if err = repo.AddTag(index.ID, tag); nil != err {
+		logging.LogErrorf("add tag [%s] to data snapshot [%s] failed: %s", tag, index.ID, err)
+		return
+	}
+	id = index.ID
+	lastPreOperationSnapshots[op] = &preOperationSnapshot{id: id, created: now}
+	logging.LogInfof("created data snapshot [%s, %s] before [%s]", id, tag, op)
+
+	publishSnapshotCreated(index, tag)
+	util.BroadcastByType("main", "preOperationSnapshot", 0, "", map[string]interface{}{
+		"id":  id,
+		"tag": tag,
+		"op":  op,
+	})
+	go prunePreOperationSnapshotTags()
+	return
+}
+
+// createPreImportSnapshot 在导入较大的数据前生成快照。
+func createPreImportSnapshot(importPath string) (id string) {
+	info, err := os.Stat(importPath)
+	if nil != err {
+		return
+	}
+
+	size := info.Size()
+	if info.IsDir() {
+		if size, err = util.SizeOfDirectory(importPath); nil != err {
+			return
+		}
+	}
+	if preOperationImportMinSize > size {
+		return
+	}
+	return createPreOperationSnapshot(RiskyOpImport, info.Name())
+}
+
+func prunePreOperationSnapshotTags() {
+	snapshots, err := GetPreOperationSnapshots()
+	if nil != err || preOperationSnapshotMaxTags >= len(snapshots) {
+		return
+	}
+
+	for _, snapshot := range snapshots[preOperationSnapshotMaxTags:] {
+		if err = RemoveTagSnapshot(snapshot.Tag); nil != err {
+			logging.LogWarnf("remove pre-operation snapshot tag [%s] failed: %s", snapshot.Tag, err)
+		}
+	}
+}
+
+// GetPreOperationSnapshots 获取风险操作前自动生成的快照，按时间倒序排列。
+func GetPreOperationSnapshots() (ret []*Snapshot, err error) {
+	ret = []*Snapshot{}
+	snapshots, err := GetTagSnapshots()
+	if nil != err {
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.Tag, preOperationSnapshotTagPrefix) {
+			ret = append(ret, snapshot)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Created > ret[j].Created
+	})
+	return
+}
+
+// RollbackPreOperationSnapshot 回滚到风险操作前自动生成的快照。
+func RollbackPreOperationSnapshot(id string) (err error) {
+	snapshots, err := GetPreOperationSnapshots()
+	if nil != err {
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			CheckoutRepo(id)
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("pre-operation snapshot [%s] not found", id))
+	return
+}
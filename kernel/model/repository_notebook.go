@@ -0,0 +1,243 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/go-humanize"
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/facette/natsort"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// snapshotNotebook 描述了挂载为临时只读笔记本的快照，文档直接从数据仓库中读取，不会解压到 data 目录下。
+type snapshotNotebook struct {
+	box     *Box
+	indexID string
+	boxID   string                  // 快照中的笔记本 ID
+	files   map[string]*entity.File // 文档路径（相对于笔记本）-> 快照文件
+	docs    map[string]*File        // 快照文件 ID -> 已经解析过的文档信息
+}
+
+var (
+	snapshotNotebooks     = map[string]*snapshotNotebook{}
+	snapshotNotebooksLock = sync.Mutex{}
+)
+
+// MountSnapshotNotebook 将快照中的笔记本挂载为临时只读笔记本，重启后自动卸载。
+func MountSnapshotNotebook(indexID, boxID string) (ret *Box, err error) {
+	repo, err := newSnapshotRepository()
+	if nil != err {
+		return
+	}
+
+	index, err := repo.GetIndex(indexID)
+	if nil != err {
+		return
+	}
+
+	files, err := repo.GetFiles(index)
+	if nil != err {
+		return
+	}
+
+	notebook := &snapshotNotebook{indexID: indexID, boxID: boxID, files: map[string]*entity.File{}, docs: map[string]*File{}}
+	boxConf := conf.NewBoxConf()
+	prefix := "/" + boxID + "/"
+	for _, file := range files {
+		if !strings.HasPrefix(file.Path, prefix) {
+			continue
+		}
+
+		p := strings.TrimPrefix(file.Path, "/"+boxID)
+		if "/.siyuan/conf.json" == p {
+			if data, openErr := repo.OpenFile(file); nil == openErr {
+				gulu.JSON.UnmarshalJSON(data, boxConf)
+			}
+			continue
+		}
+		if strings.HasSuffix(p, ".sy") {
+			notebook.files[p] = file
+		}
+	}
+	if 1 > len(notebook.files) {
+		err = fmt.Errorf("notebook [%s] not found in snapshot [%s]", boxID, indexID)
+		return
+	}
+
+	created := time.UnixMilli(index.Created).Format("2006-01-02 15:04:05")
+	ret = &Box{
+		ID:       ast.NewNodeID(),
+		Name:     boxConf.Name + " @ " + created,
+		Icon:     boxConf.Icon,
+		Sort:     boxConf.Sort,
+		SortMode: util.SortModeNameASC,
+		Snapshot: indexID,
+	}
+	notebook.box = ret
+
+	snapshotNotebooksLock.Lock()
+	snapshotNotebooks[ret.ID] = notebook
+	snapshotNotebooksLock.Unlock()
+	logging.LogInfof("mounted snapshot [%s] notebook [%s] as [%s]", indexID, boxID, ret.ID)
+	return
+}
+
+func UnmountSnapshotNotebook(id string) {
+	snapshotNotebooksLock.Lock()
+	delete(snapshotNotebooks, id)
+	snapshotNotebooksLock.Unlock()
+}
+
+func IsSnapshotNotebook(id string) bool {
+	snapshotNotebooksLock.Lock()
+	defer snapshotNotebooksLock.Unlock()
+	_, ok := snapshotNotebooks[id]
+	return ok
+}
+
+func GetSnapshotNotebooks() (ret []*Box) {
+	snapshotNotebooksLock.Lock()
+	defer snapshotNotebooksLock.Unlock()
+
+	ret = []*Box{}
+	for _, notebook := range snapshotNotebooks {
+		ret = append(ret, notebook.box)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return
+}
+
+func getSnapshotNotebook(id string) (ret *snapshotNotebook, err error) {
+	snapshotNotebooksLock.Lock()
+	ret = snapshotNotebooks[id]
+	snapshotNotebooksLock.Unlock()
+	if nil == ret {
+		err = ErrBoxNotFound
+	}
+	return
+}
+
+// ListSnapshotDocTree 列出快照笔记本中 p 路径下的文档。
+func ListSnapshotDocTree(id, p string) (ret []*File, err error) {
+	ret = []*File{}
+	notebook, err := getSnapshotNotebook(id)
+	if nil != err {
+		return
+	}
+
+	repo, err := newSnapshotRepository()
+	if nil != err {
+		return
+	}
+
+	dir := strings.TrimSuffix(p, ".sy")
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	subFileCounts := map[string]int{}
+	var children []string
+	for filePath := range notebook.files {
+		if !strings.HasPrefix(filePath, dir) {
+			continue
+		}
+		rel := strings.TrimPrefix(filePath, dir)
+		if idx := strings.Index(rel, "/"); 0 < idx {
+			if !strings.Contains(rel[idx+1:], "/") {
+				subFileCounts[dir+rel[:idx]+".sy"]++
+			}
+			continue
+		}
+		children = append(children, filePath)
+	}
+
+	luteEngine := NewLute()
+	for _, filePath := range children {
+		file := notebook.files[filePath]
+		snapshotNotebooksLock.Lock()
+		doc := notebook.docs[file.ID]
+		snapshotNotebooksLock.Unlock()
+		if nil == doc {
+			data, openErr := repo.OpenFile(file)
+			if nil != openErr {
+				logging.LogErrorf("open snapshot file [%s] failed: %s", file.Path, openErr)
+				continue
+			}
+			_, tree, parseErr := parseTreeInSnapshot(data, luteEngine)
+			if nil != parseErr {
+				logging.LogErrorf("parse snapshot file [%s] failed: %s", file.Path, parseErr)
+				continue
+			}
+
+			updated := time.UnixMilli(file.Updated)
+			doc = &File{
+				Path:   filePath,
+				Name:   tree.Root.IALAttr("title") + ".sy",
+				Icon:   tree.Root.IALAttr("icon"),
+				Name1:  tree.Root.IALAttr("name"),
+				Alias:  tree.Root.IALAttr("alias"),
+				Memo:   tree.Root.IALAttr("memo"),
+				ID:     tree.Root.ID,
+				Size:   uint64(file.Size),
+				HSize:  humanize.BytesCustomCeil(uint64(file.Size), 2),
+				Mtime:  updated.Unix(),
+				HMtime: util.HumanizeTime(updated, Conf.Lang),
+				Hidden: "true" == tree.Root.IALAttr("custom-hidden"),
+			}
+			snapshotNotebooksLock.Lock()
+			notebook.docs[file.ID] = doc
+			snapshotNotebooksLock.Unlock()
+		}
+
+		c := *doc
+		c.SubFileCount = subFileCounts[filePath]
+		ret = append(ret, &c)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return natsort.Compare(util.RemoveEmojiInvisible(ret[i].Name), util.RemoveEmojiInvisible(ret[j].Name))
+	})
+	return
+}
+
+// GetSnapshotNotebookDoc 以只读方式渲染快照笔记本中的文档。
+func GetSnapshotNotebookDoc(id, docID string) (content string, isProtyleDoc bool, updated int64, err error) {
+	notebook, err := getSnapshotNotebook(id)
+	if nil != err {
+		return
+	}
+
+	for filePath, file := range notebook.files {
+		if docID+".sy" == path.Base(filePath) {
+			return OpenRepoSnapshotDoc(file.ID)
+		}
+	}
+	err = errors.New(fmt.Sprintf(Conf.Language(15), docID))
+	return
+}
@@ -40,9 +40,9 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/88250/vitess-sqlparser/sqlparser"
 	"github.com/jinzhu/copier"
-	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/task"
@@ -217,13 +217,14 @@ func UpdateEmbedBlock(id, content string) (err error) {
 	return
 }
 
-func GetEmbedBlock(embedBlockID string, includeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock) {
+func GetEmbedBlock(embedBlockID string, includeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock, truncated bool) {
 	return getEmbedBlock(embedBlockID, includeIDs, headingMode, breadcrumb)
 }
 
-func getEmbedBlock(embedBlockID string, includeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock) {
+func getEmbedBlock(embedBlockID string, includeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock, truncated bool) {
 	stmt := "SELECT * FROM `blocks` WHERE `id` IN ('" + strings.Join(includeIDs, "','") + "')"
 	sqlBlocks := sql.SelectBlocksRawStmtNoParse(stmt, 1024)
+	sqlBlocks, truncated = limitEmbedResults(embedBlockID, sqlBlocks)
 
 	// 根据 includeIDs 的顺序排序 Improve `//!js` query embed block result sorting https://github.com/siyuan-note/siyuan/issues/9977
 	m := map[string]int{}
@@ -238,16 +239,33 @@ func getEmbedBlock(embedBlockID string, includeIDs []string, headingMode int, br
 	return
 }
 
-func SearchEmbedBlock(embedBlockID, stmt string, excludeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock) {
-	return searchEmbedBlock(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
+func SearchEmbedBlock(embedBlockID, stmt string, excludeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock, truncated bool) {
+	return searchEmbedBlock0(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
 }
 
 func searchEmbedBlock(embedBlockID, stmt string, excludeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock) {
+	ret, _ = searchEmbedBlock0(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
+	return
+}
+
+func searchEmbedBlock0(embedBlockID, stmt string, excludeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock, truncated bool) {
 	sqlBlocks := sql.SelectBlocksRawStmtNoParse(stmt, Conf.Search.Limit)
+	sqlBlocks, truncated = limitEmbedResults(embedBlockID, sqlBlocks)
 	ret = buildEmbedBlock(embedBlockID, excludeIDs, headingMode, breadcrumb, sqlBlocks)
 	return
 }
 
+// limitEmbedResults 按配额截断嵌入块的查询结果，SQL 中显式指定的 LIMIT 也不能超过配额。
+func limitEmbedResults(embedBlockID string, sqlBlocks []*sql.Block) (ret []*sql.Block, truncated bool) {
+	ret = sqlBlocks
+	limit := embedResultsLimit(quotaRootID(embedBlockID), len(sqlBlocks))
+	if limit < len(sqlBlocks) {
+		logging.LogWarnf("embed block [%s] results [%d] exceed quota [%d]", embedBlockID, len(sqlBlocks), limit)
+		ret, truncated = sqlBlocks[:limit], true
+	}
+	return
+}
+
 func buildEmbedBlock(embedBlockID string, excludeIDs []string, headingMode int, breadcrumb bool, sqlBlocks []*sql.Block) (ret []*EmbedBlock) {
 	var tmp []*sql.Block
 	for _, b := range sqlBlocks {
@@ -440,6 +458,11 @@ func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids
 		return
 	}
 
+	if 1 != len(ids) {
+		// 仅替换单个块时无需生成快照
+		createPreOperationSnapshot(RiskyOpReplace, "")
+	}
+
 	r, _ := regexp.Compile(keyword)
 	escapedKey := util.EscapeHTML(keyword)
 	escapedR, _ := regexp.Compile(escapedKey)
@@ -485,7 +508,7 @@ func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids
 		}
 
 		var data []byte
-		if data, err = filelock.ReadFile(filepath.Join(util.DataDir, tree.Box, tree.Path)); err != nil {
+		if data, err = filesys.ReadSyFile(filepath.Join(util.DataDir, tree.Box, tree.Path)); err != nil {
 			logging.LogErrorf("generate history failed: %s", err)
 			return
 		}
@@ -30,6 +30,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/util"
 	"github.com/steambap/captcha"
 )
@@ -38,7 +39,7 @@ func LogoutAuth(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	if "" == Conf.AccessAuthCode {
+	if "" == Conf.AccessAuthCode && !OIDCEnabled() {
 		ret.Code = -1
 		ret.Msg = Conf.Language(86)
 		ret.Data = map[string]interface{}{"closeTimeout": 5000}
@@ -46,6 +47,7 @@ func LogoutAuth(c *gin.Context) {
 	}
 
 	session := util.GetSession(c)
+	removeUIAuthSession(util.GetWorkspaceSession(session).SessionID)
 	util.RemoveWorkspaceSession(session)
 	if err := session.Save(c); nil != err {
 		logging.LogErrorf("saves session failed: " + err.Error())
@@ -110,7 +112,34 @@ func LoginAuth(c *gin.Context) {
 		return
 	}
 
+	// 启用了 TOTP 两步验证时需要同时提交验证码或者恢复码
+	if TOTPRequired(c.Request) && !IsTOTPVerified(c.Request, workspaceSession) {
+		totpCode, _ := arg["totp"].(string)
+		if "" == totpCode {
+			ret.Code = 2 // 需要输入 TOTP 验证码
+			ret.Msg = "TOTP code required"
+			return
+		}
+		if !VerifyTOTP(workspaceSession, totpCode) {
+			ret.Code = 2
+			ret.Msg = ErrTOTPInvalidCode.Error()
+			logging.LogWarnf("invalid TOTP code [ip=%s]", util.GetRemoteAddr(c.Request))
+
+			util.WrongAuthCount++
+			workspaceSession.Captcha = gulu.Rand.String(7)
+			if util.NeedCaptcha() {
+				ret.Code = 1 // 需要渲染验证码
+			}
+			if err := session.Save(c); nil != err {
+				logging.LogErrorf("save session failed: " + err.Error())
+				c.Status(http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
 	workspaceSession.AccessAuthCode = authCode
+	workspaceSession.SessionID = newUIAuthSession(c, "")
 	util.WrongAuthCount = 0
 	workspaceSession.Captcha = gulu.Rand.String(7)
 	logging.LogInfof("auth success [ip=%s]", util.GetRemoteAddr(c.Request))
@@ -163,12 +192,42 @@ func CheckReadonly(c *gin.Context) {
 	}
 }
 
+// CheckAdminRole 仅允许管理员访问设置和系统相关的接口，带角色的 API token、非管理员的 OIDC 会话和插件 token 返回 403。
+func CheckAdminRole(c *gin.Context) {
+	if "" != GetRole(c) || "" != c.GetString(PluginContextKey) {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Access denied: only administrator can access this API"})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// CheckNoRole 拒绝非管理员的 OIDC 会话访问读写工作空间任意文件、安装集市包、回滚数据快照等接口，这些接口无法按照角色检查属性视图的访问限制。
+// 插件 token 按照插件权限清单检查，不受影响。
+func CheckNoRole(c *gin.Context) {
+	if role := GetRole(c); "" != role {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Access denied for role [" + role + "]"})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
 func CheckAuth(c *gin.Context) {
 	//logging.LogInfof("check auth for [%s]", c.Request.RequestURI)
 	localhost := util.IsLocalHost(c.Request.RemoteAddr)
 
-	// 未设置访问授权码
-	if "" == Conf.AccessAuthCode {
+	// 通过插件 token 访问时按照插件权限清单检查
+	if plugin := getPluginByToken(getRequestToken(c)); "" != plugin {
+		c.Set(PluginContextKey, plugin)
+		if checkPluginPermission(c, plugin) {
+			c.Next()
+		}
+		return
+	}
+
+	// 未设置访问授权码且未启用 OIDC
+	if "" == Conf.AccessAuthCode && !OIDCEnabled() {
 		// Skip the empty access authorization code check https://github.com/siyuan-note/siyuan/issues/9709
 		if util.SiyuanAccessAuthCodeBypass {
 			c.Next()
@@ -176,7 +235,7 @@ func CheckAuth(c *gin.Context) {
 		}
 
 		// Authenticate requests with the Origin header other than 127.0.0.1 https://github.com/siyuan-note/siyuan/issues/9180
-		clientIP := c.ClientIP()
+		clientIP := util.GetClientIP(c.Request)
 		host := c.GetHeader("Host")
 		origin := c.GetHeader("Origin")
 		forwardedHost := c.GetHeader("X-Forwarded-Host")
@@ -228,7 +287,15 @@ func CheckAuth(c *gin.Context) {
 	// 通过 Cookie
 	session := util.GetSession(c)
 	workspaceSession := util.GetWorkspaceSession(session)
-	if workspaceSession.AccessAuthCode == Conf.AccessAuthCode {
+	if "" != Conf.AccessAuthCode && workspaceSession.AccessAuthCode == Conf.AccessAuthCode &&
+		IsTOTPVerified(c.Request, workspaceSession) && checkUIAuthSession(c, session, workspaceSession) {
+		c.Next()
+		return
+	}
+
+	// 通过 OIDC 登录会话
+	if IsOIDCSessionValid(workspaceSession) && checkUIAuthSession(c, session, workspaceSession) {
+		c.Set(RoleContextKey, oidcContextRole(workspaceSession.OIDCRole))
 		c.Next()
 		return
 	}
@@ -248,6 +315,17 @@ func CheckAuth(c *gin.Context) {
 
 		if "" != token {
 			if Conf.Api.Token == token {
+				touchTokenAuthSession(c, token, "API token")
+				c.Next()
+				return
+			}
+
+			if scopedToken := getScopedToken(token); nil != scopedToken {
+				if !checkRoleAllowedAPI(c, scopedToken.Role) {
+					return
+				}
+				touchTokenAuthSession(c, token, scopedToken.Memo)
+				c.Set(RoleContextKey, scopedToken.Role)
 				c.Next()
 				return
 			}
@@ -261,6 +339,17 @@ func CheckAuth(c *gin.Context) {
 	// 通过 API token (query-params: token)
 	if token := c.Query("token"); "" != token {
 		if Conf.Api.Token == token {
+			touchTokenAuthSession(c, token, "API token")
+			c.Next()
+			return
+		}
+
+		if scopedToken := getScopedToken(token); nil != scopedToken {
+			if !checkRoleAllowedAPI(c, scopedToken.Role) {
+				return
+			}
+			touchTokenAuthSession(c, token, scopedToken.Memo)
+			c.Set(RoleContextKey, scopedToken.Role)
 			c.Next()
 			return
 		}
@@ -275,32 +364,95 @@ func CheckAuth(c *gin.Context) {
 		return
 	}
 
-	if workspaceSession.AccessAuthCode != Conf.AccessAuthCode {
-		userAgentHeader := c.GetHeader("User-Agent")
-		if strings.HasPrefix(userAgentHeader, "SiYuan/") || strings.HasPrefix(userAgentHeader, "Mozilla/") {
-			if "GET" != c.Request.Method || c.IsWebsocket() {
-				c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": Conf.Language(156)})
-				c.Abort()
-				return
-			}
-
-			location := url.URL{}
-			queryParams := url.Values{}
-			queryParams.Set("to", c.Request.URL.String())
-			location.RawQuery = queryParams.Encode()
-			location.Path = "/check-auth"
-
-			c.Redirect(http.StatusFound, location.String())
+	// 以上方式都未通过鉴权
+	userAgentHeader := c.GetHeader("User-Agent")
+	if strings.HasPrefix(userAgentHeader, "SiYuan/") || strings.HasPrefix(userAgentHeader, "Mozilla/") {
+		if "GET" != c.Request.Method || c.IsWebsocket() {
+			c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": Conf.Language(156)})
 			c.Abort()
 			return
 		}
 
-		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [session]"})
+		location := url.URL{}
+		queryParams := url.Values{}
+		queryParams.Set("to", c.Request.URL.String())
+		location.RawQuery = queryParams.Encode()
+		location.Path = "/check-auth"
+		if "" == Conf.AccessAuthCode { // 仅启用了 OIDC 时直接跳转到身份提供方登录
+			location.Path = "/api/oidc/login"
+		}
+
+		c.Redirect(http.StatusFound, location.String())
 		c.Abort()
 		return
 	}
 
-	c.Next()
+	c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [session]"})
+	c.Abort()
+}
+
+// RoleContextKey 是请求上下文中保存调用方角色的键，仅通过带角色的 API token 访问时设置。
+const RoleContextKey = "role"
+
+// GetRole 返回调用方的角色，为空时表示不受属性视图访问限制。
+func GetRole(c *gin.Context) string {
+	return c.GetString(RoleContextKey)
+}
+
+// getRequestToken 获取请求中携带的 API token（header: Authorization 或者 query-params: token）。
+func getRequestToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	for _, prefix := range []string{"Token ", "token ", "Bearer ", "bearer "} {
+		if strings.HasPrefix(authHeader, prefix) {
+			return strings.TrimPrefix(authHeader, prefix)
+		}
+	}
+	return c.Query("token")
+}
+
+func getScopedToken(token string) *conf.ScopedToken {
+	now := time.Now().UnixMilli()
+	for _, scopedToken := range Conf.Api.ScopedTokens {
+		if 0 < scopedToken.Expired && scopedToken.Expired <= now {
+			continue
+		}
+		if "" != scopedToken.Token && scopedToken.Token == token {
+			return scopedToken
+		}
+	}
+	return nil
+}
+
+// roleAllowedAPIs 是带角色的 API token 可以访问的接口，这些接口都会按照角色检查属性视图列和视图的访问限制，其他接口一律拒绝。
+var roleAllowedAPIs = map[string]bool{
+	"/api/system/version":                  true,
+	"/api/system/currentTime":              true,
+	"/api/transactions":                    true, // 仅检查属性视图相关的操作，见 CheckTransactionsAccess
+	"/api/query/sql":                       true, // 隐藏列的单元格不可查询，见 sql.QueryRole
+	"/api/av/renderAttributeView":          true,
+	"/api/av/renderAttributeViewCalendar":  true,
+	"/api/av/renderAttributeViewGallery":   true,
+	"/api/av/getAttributeViewRows":         true,
+	"/api/av/getAttributeView":             true,
+	"/api/av/getAttributeViewKeys":         true,
+	"/api/av/getAttributeViewKeysByAvID":   true,
+	"/api/av/setAttributeViewBlockAttr":    true,
+	"/api/av/bulkUpdateAttributeViewCells": true,
+	"/api/av/removeAttributeViewValues":    true,
+	"/api/av/removeAttributeViewKey":       true,
+	"/api/av/sortAttributeViewViewKey":     true,
+	"/api/av/syncAttributeViewDataSource":  true,
+}
+
+// checkRoleAllowedAPI 检查带角色的 API token 是否可以访问当前请求，不可访问时返回 403。资源文件可以访问，以便加载画廊视图的封面等。
+func checkRoleAllowedAPI(c *gin.Context, role string) bool {
+	if "" == role || roleAllowedAPIs[c.Request.URL.Path] || strings.HasPrefix(c.Request.URL.Path, "/assets/") {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Access denied for role [" + role + "]"})
+	c.Abort()
+	return false
 }
 
 var timingAPIs = map[string]int{
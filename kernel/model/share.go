@@ -0,0 +1,541 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Share 描述了文档的公开分享链接，通过 token 只读访问文档（或者包含子文档）及其引用的资源文件。
+type Share struct {
+	Token        string `json:"token"`
+	ID           string `json:"id"`                     // 分享的文档 ID
+	Box          string `json:"box"`                    // 文档所在笔记本
+	Path         string `json:"path"`                   // 创建分享时的文档路径
+	Subtree      bool   `json:"subtree"`                // 是否包含子文档
+	PasswordHash string `json:"passwordHash,omitempty"` // bcrypt 哈希，旧版本为加盐的 SHA-256，校验通过后升级
+	Salt         string `json:"salt,omitempty"`         // 旧版本 SHA-256 哈希的盐
+	HasPassword  bool   `json:"hasPassword"`
+	Expired      int64  `json:"expired"` // 过期时间（毫秒），0 表示不过期
+	Created      int64  `json:"created"`
+	Memo         string `json:"memo"`
+	Comment      bool   `json:"comment"` // 是否允许访客评论
+}
+
+// ShareDoc 描述了分享范围内的文档，用于渲染子文档导航。
+type ShareDoc struct {
+	ID    string
+	Title string
+	Depth int
+}
+
+const (
+	sharePasswordMaxAttempts = 5                // 同一分享链接在锁定时间内最多允许的密码错误次数
+	sharePasswordLockout     = 15 * time.Minute // 密码错误次数达到限制后锁定的时间
+)
+
+var (
+	ErrShareNotFound       = errors.New("share not found")
+	ErrShareNotDoc         = errors.New("only documents can be shared")
+	ErrSharePasswordWrong  = errors.New("wrong password")
+	ErrSharePasswordLocked = errors.New("too many wrong passwords, please try again later")
+
+	shareLock = sync.Mutex{}
+
+	sharePasswordFailuresLock = sync.Mutex{}
+	sharePasswordFailures     = map[string][]int64{} // 分享链接最近的密码错误时间，键为分享 token
+)
+
+// CreateShare 为文档创建分享链接，expired 为过期时间（毫秒），password 为空时不需要密码。
+func CreateShare(id string, subtree bool, password string, expired int64, memo string, comment bool) (ret *Share, err error) {
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+	if "d" != bt.Type {
+		err = ErrShareNotDoc
+		return
+	}
+
+	ret = &Share{
+		Token:   gulu.Rand.String(32),
+		ID:      bt.ID,
+		Box:     bt.BoxID,
+		Path:    bt.Path,
+		Subtree: subtree,
+		Expired: expired,
+		Created: time.Now().UnixMilli(),
+		Memo:    memo,
+		Comment: comment,
+	}
+	if "" != password {
+		if ret.PasswordHash, err = sharePasswordHash(password); nil != err {
+			return
+		}
+		ret.HasPassword = true
+	}
+
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	shares := getShares()
+	shares = append(shares, ret)
+	if err = setShares(shares); nil != err {
+		return
+	}
+	ret = maskShare(ret)
+	return
+}
+
+// GetShares 返回文档的分享链接，id 为空时返回全部分享链接。
+func GetShares(id string) (ret []*Share) {
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	ret = []*Share{}
+	for _, share := range getShares() {
+		if "" != id && id != share.ID {
+			continue
+		}
+		ret = append(ret, maskShare(share))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created > ret[j].Created })
+	return
+}
+
+// RevokeShares 吊销分享链接。
+func RevokeShares(tokens []string) (err error) {
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	shares := getShares()
+	var tmp []*Share
+	for _, share := range shares {
+		if !gulu.Str.Contains(share.Token, tokens) {
+			tmp = append(tmp, share)
+		}
+	}
+	if len(tmp) == len(shares) {
+		return ErrShareNotFound
+	}
+	return setShares(tmp)
+}
+
+// SetShareComment 设置分享链接是否允许访客评论。
+func SetShareComment(token string, enabled bool) (err error) {
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	shares := getShares()
+	for _, share := range shares {
+		if share.Token == token {
+			share.Comment = enabled
+			return setShares(shares)
+		}
+	}
+	return ErrShareNotFound
+}
+
+// RemoveDocShares 删除文档时吊销其分享链接。
+func RemoveDocShares(rootIDs []string) {
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	shares := getShares()
+	var tmp []*Share
+	for _, share := range shares {
+		if !gulu.Str.Contains(share.ID, rootIDs) {
+			tmp = append(tmp, share)
+		}
+	}
+	if len(tmp) != len(shares) {
+		setShares(tmp)
+	}
+}
+
+// GetValidShare 返回未过期的分享链接，文档已经被删除时返回 nil。
+func GetValidShare(token string) *Share {
+	if "" == token {
+		return nil
+	}
+
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	for _, share := range getShares() {
+		if 1 != subtle.ConstantTimeCompare([]byte(share.Token), []byte(token)) {
+			continue
+		}
+		if 0 < share.Expired && share.Expired <= time.Now().UnixMilli() {
+			return nil
+		}
+		if nil == treenode.GetBlockTree(share.ID) {
+			return nil
+		}
+		return share
+	}
+	return nil
+}
+
+// CheckSharePassword 校验分享密码，通过时返回用于后续访问的 Cookie 值。
+//
+// 同一分享链接在锁定时间内密码错误达到次数限制后返回 ErrSharePasswordLocked，锁定期间不再校验密码。
+func CheckSharePassword(share *Share, password string) (cookie string, err error) {
+	if !share.HasPassword {
+		return
+	}
+
+	sharePasswordFailuresLock.Lock()
+	defer sharePasswordFailuresLock.Unlock()
+
+	now := time.Now().UnixMilli()
+	for token, failures := range sharePasswordFailures {
+		var recent []int64
+		for _, failure := range failures {
+			if now-failure < sharePasswordLockout.Milliseconds() {
+				recent = append(recent, failure)
+			}
+		}
+		if 1 > len(recent) {
+			delete(sharePasswordFailures, token)
+			continue
+		}
+		sharePasswordFailures[token] = recent
+	}
+
+	if sharePasswordMaxAttempts <= len(sharePasswordFailures[share.Token]) {
+		err = ErrSharePasswordLocked
+		return
+	}
+	if !matchSharePassword(share, password) {
+		sharePasswordFailures[share.Token] = append(sharePasswordFailures[share.Token], now)
+		err = ErrSharePasswordWrong
+		return
+	}
+	delete(sharePasswordFailures, share.Token)
+	cookie = ShareAccessCookie(share)
+	return
+}
+
+// matchSharePassword 校验分享密码，旧版本的加盐 SHA-256 哈希校验通过后升级为 bcrypt。
+func matchSharePassword(share *Share, password string) bool {
+	if "" == share.Salt {
+		return nil == bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password))
+	}
+
+	hash := sha256.Sum256([]byte(share.Salt + ":" + password))
+	if 1 != subtle.ConstantTimeCompare([]byte(share.PasswordHash), []byte(hex.EncodeToString(hash[:]))) {
+		return false
+	}
+	upgradeSharePasswordHash(share, password)
+	return true
+}
+
+func upgradeSharePasswordHash(share *Share, password string) {
+	hash, err := sharePasswordHash(password)
+	if nil != err {
+		logging.LogErrorf("hash share password failed: %s", err)
+		return
+	}
+
+	shareLock.Lock()
+	defer shareLock.Unlock()
+
+	shares := getShares()
+	for _, s := range shares {
+		if s.Token == share.Token && s.PasswordHash == share.PasswordHash {
+			s.PasswordHash, s.Salt = hash, ""
+			if err = setShares(shares); nil != err {
+				logging.LogErrorf("upgrade share password hash failed: %s", err)
+				return
+			}
+			share.PasswordHash, share.Salt = hash, ""
+			return
+		}
+	}
+}
+
+// ShareAccessCookie 返回通过密码校验后的 Cookie 值，修改密码或者吊销后失效。
+func ShareAccessCookie(share *Share) string {
+	hash := sha256.Sum256([]byte(share.Token + ":" + share.PasswordHash))
+	return hex.EncodeToString(hash[:])
+}
+
+// IsShareAccessible 判断请求携带的 Cookie 值是否可以访问分享。
+func IsShareAccessible(share *Share, cookie string) bool {
+	if !share.HasPassword {
+		return true
+	}
+	return 1 == subtle.ConstantTimeCompare([]byte(cookie), []byte(ShareAccessCookie(share)))
+}
+
+// RenderShareDoc 渲染分享范围内的文档，id 为空时渲染分享的文档。
+func RenderShareDoc(share *Share, id string) (title, dom string, docs []*ShareDoc, err error) {
+	if "" == id {
+		id = share.ID
+	}
+	bt := treenode.GetBlockTree(id)
+	if nil == bt || !shareContains(share, bt) {
+		err = ErrBlockNotFound
+		return
+	}
+
+	tree, err := LoadTreeByBlockID(bt.RootID)
+	if nil != err {
+		return
+	}
+	title = path.Base(tree.HPath)
+
+	removeShareOutOfScopeNodes(share, tree)
+	// 分享页面中块引用仅保留锚文本，避免暴露分享范围外的块
+	tree = exportTree(tree, true, false,
+		3, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
+		Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
+		"", "",
+		true)
+
+	luteEngine := NewLute()
+	luteEngine.SetFootnotes(true)
+	luteEngine.RenderOptions.ProtyleContenteditable = false
+	luteEngine.SetProtyleMarkNetImg(false)
+	// 分享页面和内核同源，必须进行安全过滤
+	luteEngine.SetSanitize(true)
+	renderer := render.NewProtyleExportRenderer(tree, luteEngine.RenderOptions)
+	dom = gulu.Str.FromBytes(renderer.Render())
+
+	if share.Subtree {
+		docs = shareDocs(share)
+	}
+	return
+}
+
+// GetShareAssetAbsPath 返回分享范围内文档引用的资源文件绝对路径。
+func GetShareAssetAbsPath(share *Share, asset string) (ret string, err error) {
+	asset, ok := shareAssetPath(asset, func() []string { return shareAssets(share) })
+	if !ok {
+		err = os.ErrNotExist
+		return
+	}
+	return GetAssetAbsPath(asset)
+}
+
+// shareAssetPath 清理请求的资源文件路径，仅当清理后的路径位于 assets/ 下并且被分享的文档引用时才返回 true。
+func shareAssetPath(asset string, getAssets func() []string) (ret string, ok bool) {
+	ret = path.Clean("/" + asset)[1:]
+	if !strings.HasPrefix(ret, "assets/") || strings.Contains(ret, "\\") {
+		return
+	}
+
+	ok = gulu.Str.Contains(ret, getAssets())
+	return
+}
+
+type shareAssetsCacheItem struct {
+	assets []string
+	time   time.Time
+}
+
+var (
+	shareAssetsCache     = map[string]*shareAssetsCacheItem{}
+	shareAssetsCacheLock = sync.Mutex{}
+)
+
+func shareAssets(share *Share) (ret []string) {
+	shareAssetsCacheLock.Lock()
+	defer shareAssetsCacheLock.Unlock()
+
+	if item := shareAssetsCache[share.Token]; nil != item && time.Since(item.time) < time.Minute {
+		return item.assets
+	}
+
+	ids := []string{share.ID}
+	if share.Subtree {
+		for _, doc := range shareDocs(share) {
+			ids = append(ids, doc.ID)
+		}
+	}
+	for _, id := range ids {
+		tree, err := LoadTreeByBlockID(id)
+		if nil != err {
+			continue
+		}
+		removeShareOutOfScopeNodes(share, tree)
+		for _, asset := range assetsLinkDestsInTree(tree) {
+			if strings.Contains(asset, "?") {
+				asset = asset[:strings.LastIndex(asset, "?")]
+			}
+			ret = append(ret, asset)
+		}
+	}
+	ret = gulu.Str.RemoveDuplicatedElem(ret)
+	shareAssetsCache[share.Token] = &shareAssetsCacheItem{assets: ret, time: time.Now()}
+	return
+}
+
+// removeShareOutOfScopeNodes 移除会引入分享范围外内容的节点：结果包含范围外块的嵌入块和数据库。
+func removeShareOutOfScopeNodes(share *Share, tree *parse.Tree) {
+	var unlinks []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		switch n.Type {
+		case ast.NodeAttributeView:
+			unlinks = append(unlinks, n)
+			return ast.WalkSkipChildren
+		case ast.NodeBlockQueryEmbed:
+			stmt := getEmbedStmt(n)
+			for _, embedBlock := range searchEmbedBlock(n.ID, stmt, nil, 0, false) {
+				if bt := treenode.GetBlockTree(embedBlock.Block.ID); nil == bt || !shareContains(share, bt) {
+					unlinks = append(unlinks, n)
+					break
+				}
+			}
+			return ast.WalkSkipChildren
+		}
+		return ast.WalkContinue
+	})
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+}
+
+func shareContains(share *Share, bt *treenode.BlockTree) bool {
+	if bt.BoxID != share.Box {
+		return false
+	}
+	if bt.RootID == share.ID {
+		return true
+	}
+	root := treenode.GetBlockTree(share.ID)
+	if !share.Subtree || nil == root {
+		return false
+	}
+	return strings.HasPrefix(bt.Path, strings.TrimSuffix(root.Path, ".sy")+"/")
+}
+
+func shareDocs(share *Share) (ret []*ShareDoc) {
+	root := treenode.GetBlockTree(share.ID)
+	if nil == root {
+		return
+	}
+
+	var bts []*treenode.BlockTree
+	for _, bt := range treenode.GetBlockTreesByPathPrefix(strings.TrimSuffix(root.Path, ".sy") + "/") {
+		if "d" == bt.Type && bt.BoxID == root.BoxID {
+			bts = append(bts, bt)
+		}
+	}
+	sort.Slice(bts, func(i, j int) bool { return bts[i].HPath < bts[j].HPath })
+	rootDepth := strings.Count(root.HPath, "/")
+	for _, bt := range bts {
+		ret = append(ret, &ShareDoc{ID: bt.ID, Title: path.Base(bt.HPath), Depth: strings.Count(bt.HPath, "/") - rootDepth})
+	}
+	return
+}
+
+// sharePasswordHash 使用 bcrypt 计算分享密码的哈希，密码超过 72 字节时返回错误。
+func sharePasswordHash(password string) (ret string, err error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if nil != err {
+		return
+	}
+	ret = string(hash)
+	return
+}
+
+func maskShare(share *Share) *Share {
+	ret := *share
+	ret.HasPassword = "" != share.PasswordHash
+	ret.PasswordHash = ""
+	ret.Salt = ""
+	return &ret
+}
+
+func setShares(shares []*Share) (err error) {
+	shareAssetsCacheLock.Lock()
+	shareAssetsCache = map[string]*shareAssetsCacheItem{}
+	shareAssetsCacheLock.Unlock()
+
+	if nil == shares {
+		shares = []*Share{}
+	}
+
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); nil != err {
+		logging.LogErrorf("create storage [share] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(shares, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal storage [share] failed: %s", err)
+		return
+	}
+
+	lsPath := filepath.Join(dirPath, "share.json")
+	if err = filelock.WriteFile(lsPath, data); nil != err {
+		logging.LogErrorf("write storage [share] failed: %s", err)
+		return
+	}
+	return
+}
+
+func getShares() (ret []*Share) {
+	ret = []*Share{}
+	dataPath := filepath.Join(util.DataDir, "storage", "share.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if nil != err {
+		logging.LogErrorf("read storage [share] failed: %s", err)
+		return
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal storage [share] failed: %s", err)
+		return
+	}
+	for _, share := range ret {
+		share.HasPassword = "" != share.PasswordHash
+	}
+	return
+}
@@ -0,0 +1,259 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ShareComment 描述了访客通过分享链接提交的评论，需要审核后才会在分享页面上显示。
+type ShareComment struct {
+	ID      string `json:"id"`
+	Token   string `json:"token"`   // 分享链接 token
+	DocID   string `json:"docID"`   // 评论的文档
+	BlockID string `json:"blockID"` // 评论的块，为空时评论整个文档
+	Name    string `json:"name"`    // 访客填写的称呼
+	Content string `json:"content"`
+	IP      string `json:"ip"`
+	Status  string `json:"status"`
+	Created int64  `json:"created"`
+}
+
+const (
+	ShareCommentPending  = "pending"
+	ShareCommentApproved = "approved"
+	ShareCommentRejected = "rejected"
+
+	shareCommentMaxContent = 4096  // 评论内容最大字符数
+	shareCommentMaxName    = 64    // 称呼最大字符数
+	shareCommentMaxPending = 1000  // 每个分享链接最多待审核评论数
+	shareCommentMaxTotal   = 10000 // 最多保存的评论数
+	shareCommentRateLimit  = 5     // 同一 IP 每分钟最多提交的评论数
+)
+
+var (
+	ErrShareCommentDisabled = errors.New("comments are disabled for this share")
+	ErrShareCommentInvalid  = errors.New("invalid comment")
+	ErrShareCommentTooMany  = errors.New("too many comments, please try again later")
+
+	shareCommentLock  = sync.Mutex{}
+	shareCommentRates = map[string][]int64{}
+)
+
+// AddShareComment 添加访客评论，评论进入待审核收件箱。
+func AddShareComment(share *Share, docID, blockID, name, content, ip string) (err error) {
+	if !share.Comment {
+		return ErrShareCommentDisabled
+	}
+
+	name = strings.TrimSpace(name)
+	content = strings.TrimSpace(content)
+	if "" == content || shareCommentMaxContent < utf8.RuneCountInString(content) || shareCommentMaxName < utf8.RuneCountInString(name) {
+		return ErrShareCommentInvalid
+	}
+	if "" == docID {
+		docID = share.ID
+	}
+	bt := treenode.GetBlockTree(docID)
+	if nil == bt || bt.ID != bt.RootID || !shareContains(share, bt) {
+		return ErrShareCommentInvalid
+	}
+	if "" != blockID {
+		if blockBt := treenode.GetBlockTree(blockID); nil == blockBt || blockBt.RootID != docID {
+			return ErrShareCommentInvalid
+		}
+	}
+
+	shareCommentLock.Lock()
+	defer shareCommentLock.Unlock()
+
+	now := time.Now().UnixMilli()
+	var recent []int64
+	for _, t := range shareCommentRates[ip] {
+		if now-t < time.Minute.Milliseconds() {
+			recent = append(recent, t)
+		}
+	}
+	if shareCommentRateLimit <= len(recent) {
+		shareCommentRates[ip] = recent
+		return ErrShareCommentTooMany
+	}
+	shareCommentRates[ip] = append(recent, now)
+	for key, times := range shareCommentRates {
+		if 0 < len(times) && now-times[len(times)-1] > time.Minute.Milliseconds() {
+			delete(shareCommentRates, key)
+		}
+	}
+
+	comments := getShareComments()
+	pending := 0
+	for _, comment := range comments {
+		if comment.Token == share.Token && ShareCommentPending == comment.Status {
+			pending++
+		}
+	}
+	if shareCommentMaxPending <= pending || shareCommentMaxTotal <= len(comments) {
+		return ErrShareCommentTooMany
+	}
+
+	comment := &ShareComment{
+		ID:      ast.NewNodeID(),
+		Token:   share.Token,
+		DocID:   docID,
+		BlockID: blockID,
+		Name:    name,
+		Content: content,
+		IP:      ip,
+		Status:  ShareCommentPending,
+		Created: now,
+	}
+	comments = append(comments, comment)
+	if err = setShareComments(comments); nil != err {
+		return
+	}
+
+	evt := util.NewCmdResult("shareComment", 0, util.PushModeBroadcast)
+	evt.Data = comment
+	util.PushEvent(evt)
+	return
+}
+
+// GetShareComments 返回评论收件箱，token 和 status 为空时不过滤。
+func GetShareComments(token, status string) (ret []*ShareComment) {
+	shareCommentLock.Lock()
+	defer shareCommentLock.Unlock()
+
+	ret = []*ShareComment{}
+	for _, comment := range getShareComments() {
+		if ("" != token && token != comment.Token) || ("" != status && status != comment.Status) {
+			continue
+		}
+		ret = append(ret, comment)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created > ret[j].Created })
+	return
+}
+
+// GetApprovedShareComments 返回分享页面上显示的已审核评论。
+func GetApprovedShareComments(share *Share, docID string) (ret []*ShareComment) {
+	shareCommentLock.Lock()
+	defer shareCommentLock.Unlock()
+
+	for _, comment := range getShareComments() {
+		if comment.Token == share.Token && comment.DocID == docID && ShareCommentApproved == comment.Status {
+			c := *comment
+			c.IP = ""
+			ret = append(ret, &c)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created < ret[j].Created })
+	return
+}
+
+// ModerateShareComments 审核评论，通过的评论会在分享页面上显示。
+func ModerateShareComments(ids []string, status string) (err error) {
+	switch status {
+	case ShareCommentPending, ShareCommentApproved, ShareCommentRejected:
+	default:
+		return errors.New("invalid comment status [" + status + "]")
+	}
+
+	shareCommentLock.Lock()
+	defer shareCommentLock.Unlock()
+
+	comments := getShareComments()
+	for _, comment := range comments {
+		if gulu.Str.Contains(comment.ID, ids) {
+			comment.Status = status
+		}
+	}
+	return setShareComments(comments)
+}
+
+// RemoveShareComments 删除评论。
+func RemoveShareComments(ids []string) (err error) {
+	shareCommentLock.Lock()
+	defer shareCommentLock.Unlock()
+
+	comments := getShareComments()
+	var tmp []*ShareComment
+	for _, comment := range comments {
+		if !gulu.Str.Contains(comment.ID, ids) {
+			tmp = append(tmp, comment)
+		}
+	}
+	return setShareComments(tmp)
+}
+
+func setShareComments(comments []*ShareComment) (err error) {
+	if nil == comments {
+		comments = []*ShareComment{}
+	}
+
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); nil != err {
+		logging.LogErrorf("create storage [share_comment] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(comments, "", "  ")
+	if nil != err {
+		logging.LogErrorf("marshal storage [share_comment] failed: %s", err)
+		return
+	}
+
+	lsPath := filepath.Join(dirPath, "share_comment.json")
+	if err = filelock.WriteFile(lsPath, data); nil != err {
+		logging.LogErrorf("write storage [share_comment] failed: %s", err)
+		return
+	}
+	return
+}
+
+func getShareComments() (ret []*ShareComment) {
+	ret = []*ShareComment{}
+	dataPath := filepath.Join(util.DataDir, "storage", "share_comment.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if nil != err {
+		logging.LogErrorf("read storage [share_comment] failed: %s", err)
+		return
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal storage [share_comment] failed: %s", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,111 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestShareAssetPath(t *testing.T) {
+	assets := []string{"assets/image-20240101000000-abcdefg.png", "assets/sub/doc-20240101000000-hijklmn.pdf"}
+	getAssets := func() []string { return assets }
+
+	cases := []struct {
+		asset    string
+		expected string
+		ok       bool
+	}{
+		{"assets/image-20240101000000-abcdefg.png", "assets/image-20240101000000-abcdefg.png", true},
+		{"/assets/image-20240101000000-abcdefg.png", "assets/image-20240101000000-abcdefg.png", true},
+		{"assets/sub/../image-20240101000000-abcdefg.png", "assets/image-20240101000000-abcdefg.png", true},
+		{"assets/sub/doc-20240101000000-hijklmn.pdf", "assets/sub/doc-20240101000000-hijklmn.pdf", true},
+		{"assets/other-20240101000000-opqrstu.png", "", false},
+		{"assets/../conf/conf.json", "", false},
+		{"../conf/conf.json", "", false},
+		{"assets/../../../etc/passwd", "", false},
+		{"/etc/passwd", "", false},
+		{"//etc/passwd", "", false},
+		{"/data/assets/image-20240101000000-abcdefg.png", "", false},
+		{"assets\\..\\..\\conf\\conf.json", "", false},
+		{"assets/..\\..\\conf\\conf.json", "", false},
+		{"assets/", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		ret, ok := shareAssetPath(c.asset, getAssets)
+		if c.ok != ok || (ok && c.expected != ret) {
+			t.Errorf("asset [%s]: expected [%s, %v], got [%s, %v]", c.asset, c.expected, c.ok, ret, ok)
+		}
+	}
+}
+
+func TestCheckSharePassword(t *testing.T) {
+	util.DataDir = t.TempDir()
+
+	hash, err := sharePasswordHash("secret")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$2") {
+		t.Fatalf("expected bcrypt hash, got [%s]", hash)
+	}
+	share := &Share{Token: "share-token-locked", PasswordHash: hash, HasPassword: true}
+	if cookie, err := CheckSharePassword(share, "secret"); nil != err || "" == cookie {
+		t.Fatalf("expected password accepted, got [%s] with error [%v]", cookie, err)
+	}
+
+	for i := 0; i < sharePasswordMaxAttempts; i++ {
+		if _, err = CheckSharePassword(share, "wrong"); !errors.Is(err, ErrSharePasswordWrong) {
+			t.Fatalf("attempt [%d]: expected wrong password, got [%v]", i, err)
+		}
+	}
+	// 锁定期间正确的密码也不再校验，其他分享链接不受影响
+	if _, err = CheckSharePassword(share, "secret"); !errors.Is(err, ErrSharePasswordLocked) {
+		t.Fatalf("expected locked, got [%v]", err)
+	}
+	other := &Share{Token: "share-token-other", PasswordHash: hash, HasPassword: true}
+	if _, err = CheckSharePassword(other, "secret"); nil != err {
+		t.Fatalf("expected other share accepted, got [%v]", err)
+	}
+
+	// 旧版本的加盐 SHA-256 哈希校验通过后升级为 bcrypt
+	legacyHash := sha256.Sum256([]byte("salt:secret"))
+	legacy := &Share{Token: "share-token-legacy", PasswordHash: hex.EncodeToString(legacyHash[:]), Salt: "salt", HasPassword: true}
+	if err = setShares([]*Share{legacy}); nil != err {
+		t.Fatal(err)
+	}
+	legacy = getShares()[0]
+	if _, err = CheckSharePassword(legacy, "wrong"); !errors.Is(err, ErrSharePasswordWrong) {
+		t.Fatalf("expected wrong password, got [%v]", err)
+	}
+	if _, err = CheckSharePassword(legacy, "secret"); nil != err {
+		t.Fatalf("expected legacy password accepted, got [%v]", err)
+	}
+	upgraded := getShares()[0]
+	if "" != upgraded.Salt || !strings.HasPrefix(upgraded.PasswordHash, "$2") {
+		t.Fatalf("expected upgraded hash, got [%s] with salt [%s]", upgraded.PasswordHash, upgraded.Salt)
+	}
+	if _, err = CheckSharePassword(upgraded, "secret"); nil != err {
+		t.Fatalf("expected upgraded password accepted, got [%v]", err)
+	}
+}
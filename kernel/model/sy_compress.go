@@ -0,0 +1,68 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"path/filepath"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// MigrateSyCompression 将已有的 .sy 文件按照当前设置压缩、解压或者转换压缩算法。
+//
+// 读取时会根据文件头透明解压，所以迁移不是必须的，只用于统一已有数据的存储格式。
+func MigrateSyCompression() {
+	task.AppendTask(task.SyCompress, migrateSyCompression)
+}
+
+func migrateSyCompression() {
+	defer logging.Recover()
+
+	lockSync()
+	defer unlockSync()
+
+	notebooks, err := ListNotebooks()
+	if nil != err {
+		return
+	}
+
+	compression := util.SyCompression
+	var count, total int
+	for _, notebook := range notebooks {
+		for _, paths := range pagedPaths(filepath.Join(util.DataDir, notebook.ID), 32) {
+			// 按页持有事务锁，避免和正在写入的事务交错覆盖
+			flushLock.Lock()
+			for _, p := range paths {
+				total++
+				converted, convertErr := filesys.ConvertSyFile(p, compression)
+				if nil != convertErr {
+					logging.LogErrorf("convert [%s] to compression [%s] failed: %s", p, compression, convertErr)
+					continue
+				}
+				if converted {
+					count++
+				}
+			}
+			flushLock.Unlock()
+		}
+	}
+	logging.LogInfof("migrated sy compression [compression=%s, count=%d/%d]", compression, count, total)
+	IncSync()
+}
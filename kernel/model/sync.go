@@ -31,15 +31,18 @@ import (
 
 	"github.com/88250/go-humanize"
 	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
 	"github.com/88250/lute/html"
 	"github.com/gorilla/websocket"
 	"github.com/siyuan-note/dejavu"
 	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -124,6 +127,7 @@ func SyncDataJob() {
 
 func BootSyncData() {
 	defer logging.Recover()
+	defer util.StartBootStage("sync", false)()
 
 	if Conf.Sync.Perception {
 		connectSyncWebSocket()
@@ -200,12 +204,17 @@ func syncData(exit, byHand bool) {
 	now := util.CurrentTimeMillis()
 	Conf.Sync.Synced = now
 
+	start := time.Now()
 	dataChanged, err := syncRepo(exit, byHand)
 	code := 1
+	result := "success"
 	if nil != err {
 		code = 2
+		result = "failure"
 	}
+	util.ObserveMetric("siyuan_sync_duration_seconds", start, "result", result)
 	util.BroadcastByType("main", "syncing", code, Conf.Sync.Stat, nil)
+	eventbus.Publish(util.EvtSyncFinished, &SyncFinishedEvent{Event: util.EvtSyncFinished, Code: code, DataChanged: dataChanged, Msg: Conf.Sync.Stat})
 
 	if nil == webSocketConn && Conf.Sync.Perception {
 		// 如果 websocket 连接已经断开，则重新连接
@@ -282,6 +291,7 @@ func incReindex(upserts, removes []string) (upsertRootIDs, removeRootIDs []strin
 	util.IncBootProgress(3, "Sync reindexing...")
 	removeRootIDs = removeIndexes(removes) // 先执行 remove，否则移动文档时 upsert 会被忽略，导致未被索引
 	upsertRootIDs = upsertIndexes(upserts)
+	reindexAttributeViews(append(removes, upserts...))
 
 	if 1 > len(removeRootIDs) {
 		removeRootIDs = []string{}
@@ -292,6 +302,124 @@ func incReindex(upserts, removes []string) (upsertRootIDs, removeRootIDs []strin
 	return
 }
 
+// reindexExternalChanges 为在内核之外修改（比如 git checkout、Syncthing）的数据文件增量重建索引，absPaths 为发生变更的文件绝对路径。
+//
+// 正在同步或者重建索引时返回 retry，由调用方稍后重试。
+func reindexExternalChanges(absPaths []string) (retry bool) {
+	if isSyncing.Load() || task.ContainIndexTask() {
+		return true
+	}
+
+	var upserts, removes []string
+	watchFolderChanged := false
+	for _, absPath := range absPaths {
+		if isWatchFolderFile(absPath) {
+			watchFolderChanged = true
+			continue
+		}
+
+		rel, err := filepath.Rel(util.DataDir, absPath)
+		if nil != err || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = "/" + filepath.ToSlash(rel)
+		if !strings.HasSuffix(rel, ".sy") {
+			// 文件夹被移走或者删除时可能只有文件夹本身的事件，需要移除其下文档的索引
+			if !gulu.File.IsExist(absPath) {
+				removes = append(removes, getRemovedDirDocs(rel)...)
+			}
+			continue
+		}
+		id := strings.TrimSuffix(path.Base(rel), ".sy")
+
+		if gulu.File.IsExist(absPath) {
+			if filesys.IsWrittenByKernel(absPath) {
+				continue
+			}
+			upserts = append(upserts, rel)
+			continue
+		}
+
+		if filesys.IsWrittenByKernel(absPath) {
+			// 内核移走的文档
+			continue
+		}
+
+		// 仅当区块树中的文档仍然指向该路径时才移除索引，避免内核移动文档后误删
+		bt := treenode.GetBlockTree(id)
+		if nil == bt || "/"+bt.BoxID+bt.Path != rel {
+			continue
+		}
+		removes = append(removes, rel)
+	}
+
+	if watchFolderChanged {
+		go func() {
+			time.Sleep(4 * time.Second) // 监听文件夹会跳过 3 秒内仍在写入的文件
+			WatchFoldersJob()
+		}()
+	}
+
+	if 1 > len(upserts) && 1 > len(removes) {
+		return
+	}
+
+	logging.LogInfof("reindex external changed data files [upserts=%d, removes=%d]", len(upserts), len(removes))
+	upsertRootIDs, removeRootIDs := incReindex(upserts, removes)
+	for _, rootID := range append(upsertRootIDs, removeRootIDs...) {
+		resetCollabDoc(rootID)
+	}
+	IncSync()
+	util.PushReloadFiletree()
+	util.BroadcastByType("main", "syncMergeResult", 0, "", map[string]interface{}{"upsertRootIDs": upsertRootIDs, "removeRootIDs": removeRootIDs})
+	return
+}
+
+// getRemovedDirDocs 返回已经被删除的文件夹下仍然存在索引的文档路径。
+func getRemovedDirDocs(relDir string) (ret []string) {
+	parts := strings.SplitN(strings.TrimPrefix(relDir, "/"), "/", 2)
+	if 2 > len(parts) || !ast.IsNodeIDPattern(parts[0]) {
+		return
+	}
+
+	box := parts[0]
+	for _, bt := range treenode.GetBlockTreesByPathPrefix("/" + parts[1] + "/") {
+		if bt.BoxID != box || bt.ID != bt.RootID {
+			continue
+		}
+		if !gulu.File.IsExist(filepath.Join(util.DataDir, box, bt.Path)) {
+			ret = append(ret, "/"+box+bt.Path)
+		}
+	}
+	return
+}
+
+func isWatchFolderFile(absPath string) bool {
+	for _, folder := range Conf.Asset.WatchFolders {
+		if folder.Enabled && "" != folder.Path && filepath.Dir(absPath) == filepath.Clean(folder.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func reindexAttributeViews(filePaths []string) {
+	for _, p := range filePaths {
+		if !strings.HasPrefix(p, "/storage/av/") || !strings.HasSuffix(p, ".json") {
+			continue
+		}
+
+		avID := strings.TrimSuffix(path.Base(p), ".json")
+		if ast.IsNodeIDPattern(avID) {
+			sql.IndexAttributeViewQueue(avID)
+			// 同步下来的列访问限制可能发生了变化，需要重建属性视图块的内容索引
+			for _, blockID := range treenode.GetMirrorAttrViewBlockIDs(avID) {
+				sql.IndexNodeQueue(blockID)
+			}
+		}
+	}
+}
+
 func removeIndexes(removeFilePaths []string) (removeRootIDs []string) {
 	bootProgressPart := int32(10 / float64(len(removeFilePaths)))
 	for _, removeFile := range removeFilePaths {
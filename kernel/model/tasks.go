@@ -0,0 +1,198 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+// Task 描述了一个任务列表项。
+type Task struct {
+	ID      string   `json:"id"`
+	RootID  string   `json:"rootID"`
+	Box     string   `json:"box"`
+	Content string   `json:"content"` // 任务内容，不含截止日期标记
+	Checked bool     `json:"checked"`
+	Due     string   `json:"due"`     // 截止日期，格式为 2006-01-02，为空表示没有截止日期
+	Overdue bool     `json:"overdue"` // 未完成且已经过了截止日期
+	Tags    []string `json:"tags"`    // 任务所在行的标签，不含子任务的标签
+	Created string   `json:"created"`
+	Updated string   `json:"updated"`
+}
+
+// TaskGroup 描述了同一个文档中的任务。
+type TaskGroup struct {
+	RootID string  `json:"rootID"`
+	Box    string  `json:"box"`
+	HPath  string  `json:"hPath"`
+	Tasks  []*Task `json:"tasks"`
+}
+
+// TaskFilter 描述了列出任务时的过滤条件，各字段为空时不过滤。
+type TaskFilter struct {
+	Status  string   `json:"status"` // open：未完成，done：已完成
+	Boxes   []string `json:"boxes"`
+	Tag     string   `json:"tag"`
+	DueFrom string   `json:"dueFrom"` // 截止日期下限（含），指定后不再列出没有截止日期的任务
+	DueTo   string   `json:"dueTo"`   // 截止日期上限（含），指定后不再列出没有截止日期的任务
+	Limit   int      `json:"limit"`   // 最多查询的任务数
+}
+
+const (
+	TaskStatusOpen = "open"
+	TaskStatusDone = "done"
+
+	// 任务截止日期属性
+	TaskDueAttrName = "custom-due"
+
+	maxTaskListLimit = 10240
+)
+
+var (
+	taskMarkerRegexp = regexp.MustCompile(`^\s*(?:[*+-]|\d+[.)])\s+\[([ xX])\]`)
+	// 行内截止日期约定：📅 2006-01-02、@due(2006-01-02) 或 due:2006-01-02
+	taskDueRegexp = regexp.MustCompile(`(?:📅\s*|@due\(\s*|\bdue:\s*)(\d{4}-\d{2}-\d{2}|\d{8})\)?`)
+	taskTagRegexp = regexp.MustCompile(`#([^#\s][^#]*?)#`)
+)
+
+// ListTasks 列出工作空间中的任务列表项，按文档分组。
+func ListTasks(filter *TaskFilter) (ret []*TaskGroup, total int, err error) {
+	ret = []*TaskGroup{}
+	if TaskStatusOpen != filter.Status && TaskStatusDone != filter.Status && "" != filter.Status {
+		err = fmt.Errorf("invalid task status [%s]", filter.Status)
+		return
+	}
+
+	var dueFrom, dueTo string
+	if "" != filter.DueFrom {
+		if dueFrom = normalizeTaskDate(filter.DueFrom); "" == dueFrom {
+			err = fmt.Errorf("invalid date [%s]", filter.DueFrom)
+			return
+		}
+	}
+	if "" != filter.DueTo {
+		if dueTo = normalizeTaskDate(filter.DueTo); "" == dueTo {
+			err = fmt.Errorf("invalid date [%s]", filter.DueTo)
+			return
+		}
+	}
+
+	limit := filter.Limit
+	if 1 > limit {
+		limit = Conf.Search.Limit
+	}
+	if maxTaskListLimit < limit {
+		limit = maxTaskListLimit
+	}
+
+	tag := strings.TrimSpace(strings.Trim(filter.Tag, "#"))
+	today := time.Now().Format("2006-01-02")
+	groups := map[string]*TaskGroup{}
+	for _, b := range sql.QueryTaskItemBlocks(filter.Boxes, tag, limit) {
+		task := buildTask(b, today)
+		if nil == task {
+			continue
+		}
+		if TaskStatusOpen == filter.Status && task.Checked || TaskStatusDone == filter.Status && !task.Checked {
+			continue
+		}
+		if "" != tag && !gulu.Str.Contains(tag, task.Tags) {
+			continue
+		}
+		if ("" != dueFrom || "" != dueTo) && "" == task.Due {
+			continue
+		}
+		if "" != dueFrom && task.Due < dueFrom || "" != dueTo && task.Due > dueTo {
+			continue
+		}
+
+		group := groups[b.RootID]
+		if nil == group {
+			group = &TaskGroup{RootID: b.RootID, Box: b.Box, HPath: b.HPath}
+			groups[b.RootID] = group
+			ret = append(ret, group)
+		}
+		group.Tasks = append(group.Tasks, task)
+		total++
+	}
+	return
+}
+
+func buildTask(b *sql.Block, today string) (ret *Task) {
+	line := b.Markdown
+	if idx := strings.Index(line, "\n"); 0 <= idx {
+		line = line[:idx]
+	}
+	marker := taskMarkerRegexp.FindStringSubmatch(line)
+	if nil == marker {
+		return
+	}
+
+	ret = &Task{
+		ID:      b.ID,
+		RootID:  b.RootID,
+		Box:     b.Box,
+		Checked: " " != marker[1],
+		Tags:    []string{},
+		Created: b.Created,
+		Updated: b.Updated,
+	}
+
+	ret.Content = strings.TrimSpace(taskDueRegexp.ReplaceAllString(b.FContent, ""))
+	if due := taskDueRegexp.FindStringSubmatch(line); nil != due {
+		ret.Due = normalizeTaskDate(due[1])
+	}
+	if "" != b.IAL {
+		ialStr := strings.TrimPrefix(b.IAL, "{:")
+		ialStr = strings.TrimSuffix(ialStr, "}")
+		for _, kv := range parse.Tokens2IAL([]byte(ialStr)) {
+			if TaskDueAttrName == kv[0] {
+				// 属性优先于行内约定
+				if due := normalizeTaskDate(kv[1]); "" != due {
+					ret.Due = due
+				}
+			}
+		}
+	}
+	ret.Overdue = !ret.Checked && "" != ret.Due && ret.Due < today
+
+	for _, tag := range taskTagRegexp.FindAllStringSubmatch(line, -1) {
+		ret.Tags = append(ret.Tags, tag[1])
+	}
+	if 1 < len(ret.Tags) {
+		ret.Tags = gulu.Str.RemoveDuplicatedElem(ret.Tags)
+	}
+	return
+}
+
+// normalizeTaskDate 将 2006-01-02 或 20060102 格式的日期统一为 2006-01-02，无法解析时返回空。
+func normalizeTaskDate(date string) string {
+	date = strings.TrimSpace(date)
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, date); nil == err {
+			return t.Format("2006-01-02")
+		}
+	}
+	return ""
+}
@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
@@ -40,10 +41,29 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+// templateFuncs 返回文档模板可用的函数，包括内置函数（含 Sprig）、SQL 查询函数、受限的网络请求函数和日期计算函数。
+func templateFuncs() (ret template.FuncMap) {
+	ret = util.BuiltInTemplateFuncs()
+	sql.SQLTemplateFuncs(&ret)
+	tplConf := Conf.Template
+	for k, v := range util.TemplateHTTPFuncs(tplConf.HTTPAllowDomains, time.Duration(tplConf.HTTPTimeout)*time.Second) {
+		ret[k] = v
+	}
+	if tplConf.DateFuncs {
+		for k, v := range util.TemplateDateFuncs() {
+			ret[k] = v
+		}
+	}
+	return
+}
+
 func RenderGoTemplate(templateContent string) (ret string, err error) {
+	return renderGoTemplate(templateContent, templateFuncs())
+}
+
+func renderGoTemplate(templateContent string, funcs template.FuncMap) (ret string, err error) {
 	tmpl := template.New("")
-	tplFuncMap := util.BuiltInTemplateFuncs()
-	sql.SQLTemplateFuncs(&tplFuncMap)
+	tplFuncMap := withTemplateInclude(funcs, "{{", "}}", nil, "")
 	tmpl = tmpl.Funcs(tplFuncMap)
 	tpl, err := tmpl.Parse(templateContent)
 	if nil != err {
@@ -193,7 +213,19 @@ func DocSaveAsTemplate(id, name string, overwrite bool) (code int, err error) {
 	return
 }
 
-func RenderTemplate(p, id string, preview bool) (tree *parse.Tree, dom string, err error) {
+func RenderTemplate(p, id string, preview bool, vars map[string]interface{}) (tree *parse.Tree, dom string, err error) {
+	if !preview {
+		createPreOperationSnapshot(RiskyOpTemplate, filepath.Base(p))
+	}
+
+	md, err := os.ReadFile(p)
+	if nil != err {
+		return
+	}
+	return renderTemplate(p, md, id, preview, vars, templateFuncs())
+}
+
+func renderTemplate(p string, md []byte, id string, preview bool, vars map[string]interface{}, tplFuncMap template.FuncMap) (tree *parse.Tree, dom string, err error) {
 	tree, err = LoadTreeByBlockID(id)
 	if nil != err {
 		return
@@ -205,10 +237,6 @@ func RenderTemplate(p, id string, preview bool) (tree *parse.Tree, dom string, e
 		return
 	}
 	block := sql.BuildBlockFromNode(node, tree)
-	md, err := os.ReadFile(p)
-	if nil != err {
-		return
-	}
 
 	dataModel := map[string]string{}
 	var titleVar string
@@ -223,9 +251,26 @@ func RenderTemplate(p, id string, preview bool) (tree *parse.Tree, dom string, e
 		dataModel["alias"] = block.Alias
 	}
 
+	// 使用 front matter 中声明的模板变量
+	tplVars, md, err := parseTemplateFrontMatter(md)
+	if nil != err {
+		return
+	}
+	varValues, err := resolveTemplateVars(tplVars, vars)
+	if nil != err {
+		return
+	}
+	for k, v := range varValues {
+		dataModel[k] = v
+	}
+
+	includeData := map[string]interface{}{}
+	for k, v := range dataModel {
+		includeData[k] = v
+	}
+	tplFuncMap = withTemplateInclude(tplFuncMap, ".action{", "}", includeData, p)
+
 	goTpl := template.New("").Delims(".action{", "}")
-	tplFuncMap := util.BuiltInTemplateFuncs()
-	sql.SQLTemplateFuncs(&tplFuncMap)
 	goTpl = goTpl.Funcs(tplFuncMap)
 	tpl, err := goTpl.Funcs(tplFuncMap).Parse(gulu.Str.FromBytes(md))
 	if nil != err {
@@ -0,0 +1,152 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"text/template"
+
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TemplateFuncError 描述了模板函数调用时产生的错误。
+type TemplateFuncError struct {
+	Func  string   `json:"func"`  // 函数名，为空时表示模板解析或执行错误
+	Args  []string `json:"args"`  // 调用参数
+	Error string   `json:"error"` // 错误信息
+}
+
+// TemplateDryRunResult 描述了模板试渲染的结果。
+type TemplateDryRunResult struct {
+	Content string               `json:"content"` // 渲染得到的块 DOM
+	Tree    json.RawMessage      `json:"tree"`    // 渲染得到的块树
+	Errors  []*TemplateFuncError `json:"errors"`  // 渲染过程中的错误
+}
+
+// DryRunTemplate 在目标块所在文档的上下文中试渲染模板，但不插入文档、不持久化数据库，也不创建快照。
+//
+// 模板可以通过 p 指定模板文件路径，也可以通过 content 直接传入模板内容（优先使用 content）。
+// 模板函数出错时不会中断渲染，而是记录错误并使用零值继续渲染，以便模板作者一次性看到所有错误。
+func DryRunTemplate(p, content, id string, vars map[string]interface{}) (ret *TemplateDryRunResult, err error) {
+	if nil == treenode.GetBlockTree(id) {
+		err = ErrBlockNotFound
+		return
+	}
+
+	ret = &TemplateDryRunResult{Errors: []*TemplateFuncError{}}
+
+	md := []byte(content)
+	if "" == content {
+		if "" == p {
+			err = errors.New("template is empty")
+			return
+		}
+
+		absPath, _ := filepath.Abs(p)
+		templates := filepath.Join(util.DataDir, "templates")
+		if !util.IsSubPath(templates, absPath) {
+			err = errors.New("template path must be under data/templates")
+			return
+		}
+		if md, err = os.ReadFile(absPath); nil != err {
+			return
+		}
+	}
+
+	recorder := &templateFuncErrRecorder{}
+	tree, dom, renderErr := renderTemplate(p, md, id, true, vars, recorder.wrap(templateFuncs()))
+	ret.Errors = append(ret.Errors, recorder.errs...)
+	if nil != renderErr {
+		ret.Errors = append(ret.Errors, &TemplateFuncError{Error: renderErr.Error()})
+		return
+	}
+
+	ret.Content = dom
+	luteEngine := NewLute()
+	renderer := render.NewJSONRenderer(tree, luteEngine.RenderOptions)
+	ret.Tree = renderer.Render()
+	return
+}
+
+type templateFuncErrRecorder struct {
+	errs []*TemplateFuncError
+	m    sync.Mutex
+}
+
+// wrap 包装模板函数，函数返回错误或者 panic 时记录错误并返回零值。
+func (recorder *templateFuncErrRecorder) wrap(funcMap template.FuncMap) (ret template.FuncMap) {
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	ret = template.FuncMap{}
+	for name, f := range funcMap {
+		fn := reflect.ValueOf(f)
+		if reflect.Func != fn.Kind() {
+			ret[name] = f
+			continue
+		}
+
+		name, fnType := name, fn.Type()
+		hasErr := 0 < fnType.NumOut() && fnType.Out(fnType.NumOut()-1) == errType
+		ret[name] = reflect.MakeFunc(fnType, func(args []reflect.Value) (results []reflect.Value) {
+			defer func() {
+				if r := recover(); nil != r {
+					recorder.record(name, args, fmt.Sprint(r))
+					results = zeroTemplateFuncResults(fnType)
+				}
+			}()
+
+			if fnType.IsVariadic() {
+				results = fn.CallSlice(args)
+			} else {
+				results = fn.Call(args)
+			}
+			if hasErr {
+				if errVal := results[len(results)-1]; !errVal.IsNil() {
+					recorder.record(name, args, errVal.Interface().(error).Error())
+					results = zeroTemplateFuncResults(fnType)
+				}
+			}
+			return
+		}).Interface()
+	}
+	return
+}
+
+func (recorder *templateFuncErrRecorder) record(name string, args []reflect.Value, msg string) {
+	var argStrs []string
+	for _, arg := range args {
+		argStrs = append(argStrs, fmt.Sprintf("%v", arg.Interface()))
+	}
+
+	recorder.m.Lock()
+	defer recorder.m.Unlock()
+	recorder.errs = append(recorder.errs, &TemplateFuncError{Func: name, Args: argStrs, Error: msg})
+}
+
+func zeroTemplateFuncResults(fnType reflect.Type) (ret []reflect.Value) {
+	for i := 0; i < fnType.NumOut(); i++ {
+		ret = append(ret, reflect.Zero(fnType.Out(i)))
+	}
+	return
+}
@@ -0,0 +1,155 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const maxTemplateIncludeDepth = 16
+
+// templateIncluder 实现了模板中的 include 函数，用于引用 data/templates/ 下的其他模板：
+//
+//	.action{include "snippets/header" (dict "project" "SiYuan")}
+//
+// 被引用的模板可以继续引用其他模板，循环引用或者嵌套过深时返回错误。
+type templateIncluder struct {
+	leftDelim, rightDelim string
+	funcs                 template.FuncMap
+	data                  map[string]interface{}
+	stack                 []string // 引用链上的模板绝对路径
+}
+
+// withTemplateInclude 返回带有 include 函数的模板函数表，不会修改传入的 funcs。
+func withTemplateInclude(funcs template.FuncMap, leftDelim, rightDelim string, data map[string]interface{}, p string) template.FuncMap {
+	includer := &templateIncluder{leftDelim: leftDelim, rightDelim: rightDelim, data: data}
+	if "" != p {
+		absPath, _ := filepath.Abs(p)
+		includer.stack = []string{absPath}
+	}
+	return includer.bind(funcs)
+}
+
+func (includer *templateIncluder) bind(funcs template.FuncMap) (ret template.FuncMap) {
+	ret = template.FuncMap{}
+	for k, v := range funcs {
+		ret[k] = v
+	}
+	ret["include"] = includer.include
+	includer.funcs = ret
+	return
+}
+
+func (includer *templateIncluder) include(name string, params ...interface{}) (ret string, err error) {
+	templates := filepath.Join(util.DataDir, "templates")
+	if "" == filepath.Ext(name) {
+		name += ".md"
+	}
+	absPath := filepath.Join(templates, name)
+	if !util.IsSubPath(templates, absPath) {
+		err = fmt.Errorf("include template [%s] must be under data/templates", name)
+		return
+	}
+
+	for i, p := range includer.stack {
+		if p == absPath {
+			chain := append(append([]string{}, includer.stack[i:]...), absPath)
+			for j := range chain {
+				chain[j] = strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(chain[j], templates)), "/")
+			}
+			err = fmt.Errorf("include template cycle detected [%s]", strings.Join(chain, " -> "))
+			return
+		}
+	}
+	if maxTemplateIncludeDepth <= len(includer.stack) {
+		err = fmt.Errorf("include template [%s] nested too deeply", name)
+		return
+	}
+
+	md, err := os.ReadFile(absPath)
+	if nil != err {
+		err = fmt.Errorf("include template [%s] failed: %s", name, err)
+		return
+	}
+	_, md, err = parseTemplateFrontMatter(md)
+	if nil != err {
+		return
+	}
+
+	data := map[string]interface{}{}
+	for k, v := range includer.data {
+		data[k] = v
+	}
+	if err = mergeTemplateIncludeParams(data, params); nil != err {
+		return
+	}
+
+	child := &templateIncluder{
+		leftDelim:  includer.leftDelim,
+		rightDelim: includer.rightDelim,
+		data:       data,
+		stack:      append(append([]string{}, includer.stack...), absPath),
+	}
+	tpl, err := template.New(name).Delims(includer.leftDelim, includer.rightDelim).Funcs(child.bind(includer.funcs)).Parse(string(md))
+	if nil != err {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err = tpl.Execute(buf, data); nil != err {
+		return
+	}
+	ret = strings.TrimSuffix(buf.String(), "\n")
+	return
+}
+
+// mergeTemplateIncludeParams 合并 include 的参数，参数可以是一个 dict，也可以是键值对。
+func mergeTemplateIncludeParams(data map[string]interface{}, params []interface{}) error {
+	if 1 == len(params) {
+		switch p := params[0].(type) {
+		case map[string]interface{}:
+			for k, v := range p {
+				data[k] = v
+			}
+			return nil
+		case map[string]string:
+			for k, v := range p {
+				data[k] = v
+			}
+			return nil
+		}
+	}
+
+	if 0 != len(params)%2 {
+		return fmt.Errorf("include params must be a dict or key-value pairs")
+	}
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return fmt.Errorf("include param key [%v] is not a string", params[i])
+		}
+		data[key] = params[i+1]
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TemplateSchedule 描述了定时使用模板创建文档的计划，比如周记、月记和例会记录。
+type TemplateSchedule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`     // cron 表达式，格式为 “分 时 日 月 周”
+	Template string `json:"template"` // 模板路径，相对于 data/templates/
+	Box      string `json:"box"`      // 目标笔记本
+	HPath    string `json:"hPath"`    // 目标文档路径，支持模板语法，比如 /周记/{{now | date "2006-01"}}
+	Enabled  bool   `json:"enabled"`
+	Created  int64  `json:"created"`
+}
+
+const (
+	TemplateScheduleRunCreated = "created"
+	TemplateScheduleRunSkipped = "skipped"
+	TemplateScheduleRunFailed  = "failed"
+)
+
+// TemplateScheduleRun 描述了一次计划执行的记录。
+type TemplateScheduleRun struct {
+	ScheduleID string `json:"scheduleID"`
+	Time       int64  `json:"time"`
+	Status     string `json:"status"` // created/skipped/failed
+	HPath      string `json:"hPath"`
+	DocID      string `json:"docID"`
+	Msg        string `json:"msg"`
+}
+
+// templateScheduleState 保存当前设备上的执行状态，不参与同步，避免多个设备互相覆盖执行时间。
+type templateScheduleState struct {
+	LastRuns map[string]int64       `json:"lastRuns"`
+	Runs     []*TemplateScheduleRun `json:"runs"`
+}
+
+const maxTemplateScheduleRuns = 256
+
+var templateScheduleLock = sync.Mutex{}
+
+func GetTemplateSchedules() (ret []*TemplateSchedule) {
+	templateScheduleLock.Lock()
+	defer templateScheduleLock.Unlock()
+
+	ret = loadTemplateSchedules()
+	return
+}
+
+func SetTemplateSchedule(schedule *TemplateSchedule) (ret *TemplateSchedule, err error) {
+	if _, err = util.ParseCron(schedule.Cron); nil != err {
+		return
+	}
+	if nil == Conf.Box(schedule.Box) {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+	if "" == schedule.HPath || "/" == schedule.HPath {
+		err = errors.New("target path is empty")
+		return
+	}
+	if _, err = getTemplateSchedulePath(schedule.Template); nil != err {
+		return
+	}
+
+	templateScheduleLock.Lock()
+	defer templateScheduleLock.Unlock()
+
+	schedules := loadTemplateSchedules()
+	if "" == schedule.ID {
+		schedule.ID = ast.NewNodeID()
+		schedule.Created = util.CurrentTimeMillis()
+		schedules = append(schedules, schedule)
+	} else {
+		found := false
+		for i, s := range schedules {
+			if s.ID == schedule.ID {
+				schedule.Created = s.Created
+				schedules[i] = schedule
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = fmt.Errorf("template schedule [%s] not found", schedule.ID)
+			return
+		}
+	}
+
+	if err = saveTemplateSchedules(schedules); nil != err {
+		return
+	}
+	ret = schedule
+	return
+}
+
+func RemoveTemplateSchedule(id string) (err error) {
+	templateScheduleLock.Lock()
+	defer templateScheduleLock.Unlock()
+
+	schedules := loadTemplateSchedules()
+	var tmp []*TemplateSchedule
+	for _, s := range schedules {
+		if s.ID != id {
+			tmp = append(tmp, s)
+		}
+	}
+	if err = saveTemplateSchedules(tmp); nil != err {
+		return
+	}
+
+	state := loadTemplateScheduleState()
+	delete(state.LastRuns, id)
+	saveTemplateScheduleState(state)
+	return
+}
+
+// RunTemplateSchedule 立即执行指定的计划。
+func RunTemplateSchedule(id string) (ret *TemplateScheduleRun, err error) {
+	templateScheduleLock.Lock()
+	defer templateScheduleLock.Unlock()
+
+	for _, s := range loadTemplateSchedules() {
+		if s.ID == id {
+			state := loadTemplateScheduleState()
+			ret = runTemplateSchedule(s, state)
+			saveTemplateScheduleState(state)
+			return
+		}
+	}
+	err = fmt.Errorf("template schedule [%s] not found", id)
+	return
+}
+
+func GetTemplateScheduleRuns(id string) (ret []*TemplateScheduleRun) {
+	templateScheduleLock.Lock()
+	defer templateScheduleLock.Unlock()
+
+	ret = []*TemplateScheduleRun{}
+	state := loadTemplateScheduleState()
+	for i := len(state.Runs) - 1; 0 <= i; i-- {
+		if "" == id || state.Runs[i].ScheduleID == id {
+			ret = append(ret, state.Runs[i])
+		}
+	}
+	return
+}
+
+// TemplateScheduleJob 执行到期的模板计划，界面未打开时也会执行。内核停止期间错过的计划会在启动后补执行一次。
+func TemplateScheduleJob() {
+	if !util.IsBooted() || util.IsExiting.Load() || util.ReadOnly {
+		return
+	}
+
+	templateScheduleLock.Lock()
+	defer templateScheduleLock.Unlock()
+
+	schedules := loadTemplateSchedules()
+	if 1 > len(schedules) {
+		return
+	}
+
+	state := loadTemplateScheduleState()
+	now := time.Now()
+	changed := false
+	for _, s := range schedules {
+		if !s.Enabled {
+			continue
+		}
+
+		cron, err := util.ParseCron(s.Cron)
+		if nil != err {
+			continue
+		}
+
+		base := now.Add(-time.Minute)
+		if last := state.LastRuns[s.ID]; 0 < last {
+			base = time.UnixMilli(last)
+		}
+		next := cron.Next(base)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		runTemplateSchedule(s, state)
+		changed = true
+	}
+	if changed {
+		saveTemplateScheduleState(state)
+	}
+}
+
+func runTemplateSchedule(schedule *TemplateSchedule, state *templateScheduleState) (ret *TemplateScheduleRun) {
+	ret = &TemplateScheduleRun{ScheduleID: schedule.ID, Time: util.CurrentTimeMillis()}
+	defer func() {
+		state.LastRuns[schedule.ID] = ret.Time
+		state.Runs = append(state.Runs, ret)
+		if maxTemplateScheduleRuns < len(state.Runs) {
+			state.Runs = state.Runs[len(state.Runs)-maxTemplateScheduleRuns:]
+		}
+
+		if TemplateScheduleRunFailed == ret.Status {
+			logging.LogWarnf("run template schedule [%s] failed: %s", schedule.Name, ret.Msg)
+		} else {
+			logging.LogInfof("run template schedule [%s] [%s]: %s", schedule.Name, ret.Status, ret.HPath)
+		}
+	}()
+
+	fail := func(err error) *TemplateScheduleRun {
+		ret.Status = TemplateScheduleRunFailed
+		ret.Msg = err.Error()
+		return ret
+	}
+
+	box := Conf.Box(schedule.Box)
+	if nil == box {
+		return fail(errors.New(Conf.Language(0)))
+	}
+
+	tplPath, err := getTemplateSchedulePath(schedule.Template)
+	if nil != err {
+		return fail(err)
+	}
+
+	hPath, err := RenderGoTemplate(schedule.HPath)
+	if nil != err {
+		return fail(err)
+	}
+	ret.HPath = hPath
+
+	createDocLock.Lock()
+	defer createDocLock.Unlock()
+
+	WaitForWritingFiles()
+	if existRoot := treenode.GetBlockTreeRootByHPath(box.ID, hPath); nil != existRoot {
+		ret.Status = TemplateScheduleRunSkipped
+		ret.DocID = existRoot.RootID
+		ret.Msg = "document already exists"
+		return
+	}
+
+	id, err := createDocsByHPath(box.ID, hPath, "", "", "")
+	if nil != err {
+		return fail(err)
+	}
+	ret.DocID = id
+
+	if err = fillDocWithTemplate(id, tplPath); nil != err {
+		return fail(err)
+	}
+	IncSync()
+
+	ret.Status = TemplateScheduleRunCreated
+	return
+}
+
+func getTemplateSchedulePath(template string) (ret string, err error) {
+	templates := filepath.Join(util.DataDir, "templates")
+	ret = filepath.Join(templates, template)
+	if !util.IsSubPath(templates, ret) {
+		err = errors.New("template path must be under data/templates")
+		return
+	}
+	if !filelock.IsExist(ret) {
+		err = fmt.Errorf("template [%s] not found", template)
+	}
+	return
+}
+
+func loadTemplateSchedules() (ret []*TemplateSchedule) {
+	ret = []*TemplateSchedule{}
+	p := filepath.Join(util.DataDir, "storage", "template-schedules.json")
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read template schedules failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal template schedules failed: %s", err)
+	}
+	return
+}
+
+func saveTemplateSchedules(schedules []*TemplateSchedule) (err error) {
+	if nil == schedules {
+		schedules = []*TemplateSchedule{}
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(schedules, "", "  ")
+	if nil != err {
+		return
+	}
+
+	p := filepath.Join(util.DataDir, "storage", "template-schedules.json")
+	if err = os.MkdirAll(filepath.Dir(p), 0755); nil != err {
+		return
+	}
+	if err = filelock.WriteFile(p, data); nil != err {
+		logging.LogErrorf("write template schedules failed: %s", err)
+		return
+	}
+	IncSync()
+	return
+}
+
+func loadTemplateScheduleState() (ret *templateScheduleState) {
+	ret = &templateScheduleState{LastRuns: map[string]int64{}}
+	p := filepath.Join(util.TempDir, "template-schedule-runs.json")
+	if !gulu.File.IsExist(p) {
+		return
+	}
+
+	data, err := os.ReadFile(p)
+	if nil != err {
+		logging.LogErrorf("read template schedule runs failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		logging.LogErrorf("unmarshal template schedule runs failed: %s", err)
+	}
+	if nil == ret.LastRuns {
+		ret.LastRuns = map[string]int64{}
+	}
+	return
+}
+
+func saveTemplateScheduleState(state *templateScheduleState) {
+	data, err := gulu.JSON.MarshalJSON(state)
+	if nil != err {
+		logging.LogErrorf("marshal template schedule runs failed: %s", err)
+		return
+	}
+
+	p := filepath.Join(util.TempDir, "template-schedule-runs.json")
+	if err = gulu.File.WriteFileSafer(p, data, 0644); nil != err {
+		logging.LogErrorf("write template schedule runs failed: %s", err)
+	}
+}
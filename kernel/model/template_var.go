@@ -0,0 +1,180 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/araddon/dateparse"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TemplateVarTypeString = "string"
+	TemplateVarTypeDate   = "date"
+	TemplateVarTypeSelect = "select"
+)
+
+// TemplateVar 描述了模板在 front matter 中声明的变量。
+//
+//	---
+//	variables:
+//	  - name: project
+//	    label: 项目
+//	    type: string
+//	    required: true
+//	  - name: status
+//	    type: select
+//	    options: [todo, doing, done]
+//	    default: todo
+//	---
+type TemplateVar struct {
+	Name     string   `yaml:"name" json:"name"`         // 变量名，在模板中通过 .action{.name} 引用
+	Label    string   `yaml:"label" json:"label"`       // 表单中显示的标签
+	Type     string   `yaml:"type" json:"type"`         // 变量类型：string、date、select
+	Options  []string `yaml:"options" json:"options"`   // select 类型的可选项
+	Default  string   `yaml:"default" json:"default"`   // 默认值，date 类型支持 now
+	Required bool     `yaml:"required" json:"required"` // 是否必填
+}
+
+type templateFrontMatter struct {
+	Variables []*TemplateVar `yaml:"variables"`
+}
+
+// 内置的模板数据，变量不能使用这些名称
+var reservedTemplateVarNames = []string{"title", "id", "name", "alias"}
+
+// GetTemplateVarSchema 获取模板声明的变量。
+func GetTemplateVarSchema(p string) (ret []*TemplateVar, err error) {
+	md, err := os.ReadFile(p)
+	if nil != err {
+		return
+	}
+
+	ret, _, err = parseTemplateFrontMatter(md)
+	if nil == ret {
+		ret = []*TemplateVar{}
+	}
+	return
+}
+
+// parseTemplateFrontMatter 解析模板开头的 front matter，返回变量声明和去掉 front matter 后的模板内容。
+func parseTemplateFrontMatter(md []byte) (vars []*TemplateVar, body []byte, err error) {
+	body = md
+	content := bytes.TrimPrefix(md, []byte("\xef\xbb\xbf"))
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(content, []byte("---\n")) {
+		return
+	}
+
+	end := bytes.Index(content[4:], []byte("\n---"))
+	if 0 > end {
+		return
+	}
+	fm := content[4 : 4+end]
+	rest := content[4+end+4:]
+	if 0 < len(rest) && '\n' != rest[0] {
+		// 分隔线后还有其他内容，不是 front matter
+		return
+	}
+
+	frontMatter := &templateFrontMatter{}
+	if err = yaml.Unmarshal(fm, frontMatter); nil != err {
+		err = fmt.Errorf("parse template front matter failed: %s", err)
+		return
+	}
+
+	body = bytes.TrimPrefix(rest, []byte("\n"))
+	for _, v := range frontMatter.Variables {
+		if "" == v.Name {
+			err = errors.New("template variable name is empty")
+			return
+		}
+		if gulu.Str.Contains(v.Name, reservedTemplateVarNames) {
+			err = fmt.Errorf("template variable name [%s] is reserved", v.Name)
+			return
+		}
+		if "" == v.Type {
+			v.Type = TemplateVarTypeString
+		}
+		switch v.Type {
+		case TemplateVarTypeString, TemplateVarTypeDate:
+		case TemplateVarTypeSelect:
+			if 1 > len(v.Options) {
+				err = fmt.Errorf("template variable [%s] has no options", v.Name)
+				return
+			}
+		default:
+			err = fmt.Errorf("unsupported template variable type [%s]", v.Type)
+			return
+		}
+		if "" == v.Label {
+			v.Label = v.Name
+		}
+	}
+	vars = frontMatter.Variables
+	return
+}
+
+// resolveTemplateVars 校验提交的变量值并填充默认值。
+//
+// values 为 nil 时表示非交互渲染（比如创建日记），此时仅使用默认值，不检查必填项。
+func resolveTemplateVars(vars []*TemplateVar, values map[string]interface{}) (ret map[string]string, err error) {
+	ret = map[string]string{}
+	for _, v := range vars {
+		val := v.Default
+		if nil != values {
+			if submitted, ok := values[v.Name]; ok && nil != submitted {
+				val = cast.ToString(submitted)
+			}
+		}
+
+		if "" == val {
+			if v.Required && nil != values {
+				err = fmt.Errorf("template variable [%s] is required", v.Label)
+				return
+			}
+			ret[v.Name] = ""
+			continue
+		}
+
+		switch v.Type {
+		case TemplateVarTypeDate:
+			var t time.Time
+			if "now" == val {
+				t = time.Now()
+			} else if t, err = dateparse.ParseIn(val, time.Now().Location()); nil != err {
+				err = fmt.Errorf("template variable [%s] is not a valid date: %s", v.Label, val)
+				return
+			}
+			val = t.Format("2006-01-02")
+		case TemplateVarTypeSelect:
+			if !gulu.Str.Contains(val, v.Options) {
+				err = fmt.Errorf("template variable [%s] value [%s] is not in options", v.Label, val)
+				return
+			}
+		}
+		ret[v.Name] = val
+	}
+	return
+}
@@ -0,0 +1,254 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	totpPeriod             = 30
+	totpDigits             = 6
+	totpRecoveryCodeCount  = 10
+	totpRecoveryCodeLength = 10
+)
+
+var (
+	ErrTOTPInvalidCode = errors.New("invalid TOTP code")
+	ErrTOTPNotPending  = errors.New("TOTP setup is not started")
+
+	totpLock          = sync.Mutex{}
+	totpPendingSecret string
+	totpLastCounter   uint64 // 最近一次通过验证的时间步，防止验证码被重放
+)
+
+// TOTPRequired 判断请求是否需要通过 TOTP 两步验证。
+func TOTPRequired(req *http.Request) bool {
+	if nil == Conf.TOTP || !Conf.TOTP.Enabled || "" == Conf.TOTP.Secret || "" == Conf.AccessAuthCode {
+		return false
+	}
+
+	enforce := Conf.TOTP.Enforce
+	if "" != util.SiyuanTOTPEnforce {
+		enforce = util.SiyuanTOTPEnforce
+	}
+	switch enforce {
+	case conf.TOTPEnforceOff:
+		return false
+	case conf.TOTPEnforceAll:
+		return true
+	default:
+		// 经过受信任的反向代理时使用转发的客户端地址判断，否则代理转发的远程请求都会被当作本机请求
+		ip := net.ParseIP(util.GetClientIP(req))
+		return nil == ip || !ip.IsLoopback()
+	}
+}
+
+// IsTOTPVerified 判断会话是否已经通过 TOTP 两步验证。
+func IsTOTPVerified(req *http.Request, workspaceSession *util.WorkspaceSession) bool {
+	if !TOTPRequired(req) {
+		return true
+	}
+	return totpSecretFingerprint(Conf.TOTP.Secret) == workspaceSession.TOTPVerified
+}
+
+// VerifyTOTP 校验 TOTP 验证码或者恢复码，通过时在会话中记录。
+func VerifyTOTP(workspaceSession *util.WorkspaceSession, code string) bool {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if !verifyTOTPCode0(Conf.TOTP.Secret, code, time.Now()) && !useTOTPRecoveryCode(code) {
+		return false
+	}
+	workspaceSession.TOTPVerified = totpSecretFingerprint(Conf.TOTP.Secret)
+	return true
+}
+
+// SetupTOTP 生成待启用的密钥，返回密钥和用于认证器扫码的 otpauth URI。
+func SetupTOTP() (secret, uri string) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	buf := make([]byte, 20)
+	rand.Read(buf)
+	totpPendingSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)
+
+	label := "SiYuan:" + Conf.System.Name
+	params := url.Values{}
+	params.Set("secret", totpPendingSecret)
+	params.Set("issuer", "SiYuan")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return totpPendingSecret, "otpauth://totp/" + url.PathEscape(label) + "?" + params.Encode()
+}
+
+// EnableTOTP 使用待启用密钥生成的验证码启用两步验证，返回恢复码。
+func EnableTOTP(workspaceSession *util.WorkspaceSession, code, enforce string) (recoveryCodes []string, err error) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if "" == totpPendingSecret {
+		err = ErrTOTPNotPending
+		return
+	}
+	if !verifyTOTPCode0(totpPendingSecret, code, time.Now()) {
+		err = ErrTOTPInvalidCode
+		return
+	}
+
+	Conf.TOTP.Enabled = true
+	Conf.TOTP.Secret = totpPendingSecret
+	if conf.TOTPEnforceAll != enforce {
+		enforce = conf.TOTPEnforceRemote
+	}
+	Conf.TOTP.Enforce = enforce
+	recoveryCodes = generateTOTPRecoveryCodes()
+	totpPendingSecret = ""
+	Conf.Save()
+
+	workspaceSession.TOTPVerified = totpSecretFingerprint(Conf.TOTP.Secret)
+	logging.LogInfof("enabled TOTP two-factor authentication")
+	return
+}
+
+// DisableTOTP 使用验证码或者恢复码关闭两步验证。
+func DisableTOTP(code string) error {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if !verifyTOTPCode0(Conf.TOTP.Secret, code, time.Now()) && !useTOTPRecoveryCode(code) {
+		return ErrTOTPInvalidCode
+	}
+
+	Conf.TOTP.Enabled = false
+	Conf.TOTP.Secret = ""
+	Conf.TOTP.RecoveryCodes = []string{}
+	Conf.Save()
+	logging.LogInfof("disabled TOTP two-factor authentication")
+	return nil
+}
+
+// RegenerateTOTPRecoveryCodes 使用验证码重新生成恢复码，旧的恢复码全部失效。
+func RegenerateTOTPRecoveryCodes(code string) (recoveryCodes []string, err error) {
+	totpLock.Lock()
+	defer totpLock.Unlock()
+
+	if !Conf.TOTP.Enabled || !verifyTOTPCode0(Conf.TOTP.Secret, code, time.Now()) {
+		err = ErrTOTPInvalidCode
+		return
+	}
+	recoveryCodes = generateTOTPRecoveryCodes()
+	Conf.Save()
+	return
+}
+
+func generateTOTPRecoveryCodes() (ret []string) {
+	Conf.TOTP.RecoveryCodes = []string{}
+	for i := 0; i < totpRecoveryCodeCount; i++ {
+		code := strings.ToLower(gulu.Rand.String(totpRecoveryCodeLength))
+		ret = append(ret, code)
+		Conf.TOTP.RecoveryCodes = append(Conf.TOTP.RecoveryCodes, totpRecoveryCodeHash(code))
+	}
+	return
+}
+
+func useTOTPRecoveryCode(code string) bool {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if totpRecoveryCodeLength != len(code) {
+		return false
+	}
+
+	hash := totpRecoveryCodeHash(code)
+	for i, recoveryCode := range Conf.TOTP.RecoveryCodes {
+		if hmac.Equal([]byte(hash), []byte(recoveryCode)) {
+			Conf.TOTP.RecoveryCodes = append(Conf.TOTP.RecoveryCodes[:i], Conf.TOTP.RecoveryCodes[i+1:]...)
+			Conf.Save()
+			logging.LogWarnf("used a TOTP recovery code, [%d] left", len(Conf.TOTP.RecoveryCodes))
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTOTPCode0 校验验证码，允许前后各一个时间步的误差，同一时间步的验证码只能使用一次。
+func verifyTOTPCode0(secret, code string, now time.Time) bool {
+	code = strings.TrimSpace(code)
+	if "" == secret || totpDigits != len(code) {
+		return false
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if nil != err {
+		return false
+	}
+
+	counter := uint64(now.Unix() / totpPeriod)
+	for _, c := range []uint64{counter - 1, counter, counter + 1} {
+		if c <= totpLastCounter {
+			continue
+		}
+		if hmac.Equal([]byte(totpCode(key, c)), []byte(code)) {
+			totpLastCounter = c
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode 按照 RFC 6238 计算验证码。
+func totpCode(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+func totpSecretFingerprint(secret string) string {
+	if "" == secret {
+		return ""
+	}
+	hash := sha256.Sum256([]byte("totp:" + secret))
+	return hex.EncodeToString(hash[:8])
+}
+
+func totpRecoveryCodeHash(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
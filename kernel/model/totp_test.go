@@ -0,0 +1,94 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 中 SHA1 的测试密钥 "12345678901234567890" 的 Base32 编码
+const totpTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 附录 B 的 8 位验证码取后 6 位
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	key := []byte("12345678901234567890")
+	for _, c := range cases {
+		if code := totpCode(key, uint64(c.unix/totpPeriod)); c.code != code {
+			t.Errorf("time [%d]: expected [%s], got [%s]", c.unix, c.code, code)
+		}
+	}
+}
+
+func TestVerifyTOTPCode0(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	key := []byte("12345678901234567890")
+	counter := uint64(now.Unix() / totpPeriod)
+
+	cases := []struct {
+		name    string
+		counter uint64
+		ok      bool
+	}{
+		{"current step", counter, true},
+		{"previous step", counter - 1, true},
+		{"next step", counter + 1, true},
+		{"two steps behind", counter - 2, false},
+		{"two steps ahead", counter + 2, false},
+	}
+	for _, c := range cases {
+		totpLastCounter = 0
+		if ok := verifyTOTPCode0(totpTestSecret, totpCode(key, c.counter), now); c.ok != ok {
+			t.Errorf("%s: expected [%v], got [%v]", c.name, c.ok, ok)
+		}
+	}
+
+	totpLastCounter = 0
+	code := totpCode(key, counter)
+	if !verifyTOTPCode0(totpTestSecret, " "+code+" ", now) {
+		t.Fatalf("expected code with surrounding spaces to be accepted")
+	}
+	if verifyTOTPCode0(totpTestSecret, code, now) {
+		t.Fatalf("expected replayed code to be rejected")
+	}
+	if verifyTOTPCode0(totpTestSecret, totpCode(key, counter-1), now) {
+		t.Fatalf("expected code of an earlier step to be rejected after a later step was used")
+	}
+
+	totpLastCounter = 0
+	for _, invalid := range []string{"", "12345", "1234567", "abcdef"} {
+		if verifyTOTPCode0(totpTestSecret, invalid, now) {
+			t.Errorf("expected invalid code [%s] to be rejected", invalid)
+		}
+	}
+	if verifyTOTPCode0("", code, now) || verifyTOTPCode0("not base32!", code, now) {
+		t.Errorf("expected invalid secret to be rejected")
+	}
+	totpLastCounter = 0
+}
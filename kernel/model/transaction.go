@@ -116,22 +116,41 @@ func init() {
 
 func flushTx(tx *Transaction) {
 	defer logging.Recover()
+	if nil != tx.flushed {
+		defer close(tx.flushed)
+	}
 	flushLock.Lock()
 	defer flushLock.Unlock()
 
 	start := time.Now()
 	if txErr := performTx(tx); nil != txErr {
+		tx.txErr = txErr
 		switch txErr.code {
 		case TxErrCodeBlockNotFound:
 			util.PushTxErr("Transaction failed", txErr.code, nil)
 			return
 		case TxErrCodeDataIsSyncing:
 			util.PushMsg(Conf.Language(222), 5000)
+		case TxErrInvalidAttrViewVal:
+			// 列值校验失败时事务已经回滚，推送校验错误详情给前端
+			util.PushTxErr(txErr.msg, txErr.code, txErr.data)
+			return
+		case TxErrInvalidAttrViewOp:
+			// 属性视图操作参数无效时事务已经回滚，提示错误并重新加载属性视图以丢弃前端的修改
+			util.PushErrMsg(txErr.msg, 7000)
+			util.PushReloadAttrView(txErr.id)
+			return
+		case TxErrDocBlocksQuota:
+			// 超出文档块数配额时事务已经回滚，重新加载文档以丢弃前端的编辑
+			util.PushErrMsg(txErr.msg, 7000)
+			util.PushReloadDoc(txErr.id)
+			return
 		default:
 			txData, _ := gulu.JSON.MarshalJSON(tx)
 			logging.LogFatalf(logging.ExitCodeFatal, "transaction failed [%d]: %s\n  tx [%s]", txErr.code, txErr.msg, txData)
 		}
 	}
+	publishBlocksUpdated(tx)
 	elapsed := time.Now().Sub(start).Milliseconds()
 	if 0 < len(tx.DoOperations) {
 		if 2000 < elapsed {
@@ -143,6 +162,7 @@ func flushTx(tx *Transaction) {
 func PerformTransactions(transactions *[]*Transaction) {
 	for _, tx := range *transactions {
 		tx.m = &sync.Mutex{}
+		tx.flushed = make(chan bool)
 		txQueue <- tx
 	}
 	return
@@ -153,15 +173,20 @@ const (
 	TxErrCodeDataIsSyncing  = 1
 	TxErrCodeWriteTree      = 2
 	TxErrWriteAttributeView = 3
+	TxErrInvalidAttrViewVal = 4
+	TxErrDocBlocksQuota     = 5
+	TxErrInvalidAttrViewOp  = 6 // 属性视图操作参数无效（比如列设置校验失败），不会导致内核退出
 )
 
 type TxErr struct {
 	code int
 	msg  string
 	id   string
+	data interface{}
 }
 
 func performTx(tx *Transaction) (ret *TxErr) {
+	collabTransform(tx)
 	if 1 > len(tx.DoOperations) {
 		return
 	}
@@ -230,6 +255,8 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doSetAttrViewName(op)
 		case "setAttrViewFilters":
 			ret = tx.doSetAttrViewFilters(op)
+		case "setAttrViewFilterGroup":
+			ret = tx.doSetAttrViewFilterGroup(op)
 		case "setAttrViewSorts":
 			ret = tx.doSetAttrViewSorts(op)
 		case "setAttrViewPageSize":
@@ -296,8 +323,28 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doHideAttrViewName(op)
 		case "setAttrViewColDate":
 			ret = tx.doSetAttrViewColDate(op)
+		case "setAttrViewColRating":
+			ret = tx.doSetAttrViewColRating(op)
+		case "setAttrViewColCurrency":
+			ret = tx.doSetAttrViewColCurrency(op)
+		case "setAttrViewColDuration":
+			ret = tx.doSetAttrViewColDuration(op)
+		case "setAttrViewColValidation":
+			ret = tx.doSetAttrViewColValidation(op)
 		case "unbindAttrViewBlock":
 			ret = tx.doUnbindAttrViewBlock(op)
+		case "setAttrViewDataSource":
+			ret = tx.doSetAttrViewDataSource(op)
+		case "syncAttrViewDataSource":
+			ret = tx.doSyncAttrViewDataSource(op)
+		case "setAttrViewAutomations":
+			ret = tx.doSetAttrViewAutomations(op)
+		case "setAttrViewRowTemplate":
+			ret = tx.doSetAttrViewRowTemplate(op)
+		case "rescheduleAttrViewRow":
+			ret = tx.doRescheduleAttrViewRow(op)
+		case "setAttrViewGallery":
+			ret = tx.doSetAttrViewGallery(op)
 		}
 
 		if nil != ret {
@@ -306,10 +353,16 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		}
 	}
 
+	if ret = tx.checkQuota(); nil != ret {
+		tx.rollback()
+		return
+	}
+
 	if cr := tx.commit(); nil != cr {
 		logging.LogErrorf("commit tx failed: %s", cr)
 		return &TxErr{msg: cr.Error()}
 	}
+	collabCommit(tx)
 	return
 }
 
@@ -1117,6 +1170,7 @@ func (tx *Transaction) doUpdate(operation *Operation) (ret *TxErr) {
 	}
 
 	upsertAvBlockRel(updatedNode)
+	syncClozeFlashcards(updatedNode)
 
 	checkUpsertInUserGuide(tree)
 	return
@@ -1297,14 +1351,51 @@ type Transaction struct {
 	DoOperations   []*Operation `json:"doOperations"`
 	UndoOperations []*Operation `json:"undoOperations"`
 
-	trees map[string]*parse.Tree
-	nodes map[string]*ast.Node
+	RootID    string            `json:"rootID,omitempty"`    // 协同编辑时事务所属的文档 ID
+	BaseRev   int64             `json:"baseRev,omitempty"`   // 协同编辑时客户端生成事务所基于的文档修订号
+	Rev       int64             `json:"rev,omitempty"`       // 事务提交后文档的修订号
+	Conflicts []*CollabConflict `json:"conflicts,omitempty"` // 事务变换时遇到的冲突
+
+	session string    // 发起事务的客户端会话
+	flushed chan bool // 事务处理完毕后关闭
+	txErr   *TxErr    // 事务执行失败时的错误，flushed 关闭后才能读取
+
+	trees       map[string]*parse.Tree
+	nodes       map[string]*ast.Node
+	blockCounts map[string]int // 加载文档时的块数，用于检查文档块数配额
 
 	luteEngine *lute.Lute
 	m          *sync.Mutex
 	state      atomic.Int32 // 0: 初始化，1：未提交，:2: 已提交，3: 已回滚
 }
 
+// SetSession 设置发起事务的客户端会话，协同编辑变换时用于排除客户端自身的变更。
+func (tx *Transaction) SetSession(session string) {
+	tx.session = session
+}
+
+// WaitForFlush 等待事务在事务队列中处理完毕。
+// ErrTransactionFlushTimeout 表示等待事务处理完毕超时，此时事务可能仍在队列中等待执行。
+var ErrTransactionFlushTimeout = errors.New("wait for transaction flush timeout")
+
+// WaitForFlush 等待事务处理完毕，返回事务执行失败或者等待超时的错误。
+func (tx *Transaction) WaitForFlush() (err error) {
+	if nil == tx.flushed {
+		return
+	}
+
+	select {
+	case <-tx.flushed:
+		if nil != tx.txErr {
+			err = fmt.Errorf("transaction failed [%d]: %s", tx.txErr.code, tx.txErr.msg)
+		}
+	case <-time.After(7 * time.Second):
+		logging.LogWarnf("wait for transaction flush timeout")
+		err = ErrTransactionFlushTimeout
+	}
+	return
+}
+
 func (tx *Transaction) WaitForCommit() {
 	for {
 		if 1 == tx.state.Load() {
@@ -1321,6 +1412,7 @@ func (tx *Transaction) begin() (err error) {
 	}
 	tx.trees = map[string]*parse.Tree{}
 	tx.nodes = map[string]*ast.Node{}
+	tx.blockCounts = map[string]int{}
 	tx.luteEngine = util.NewLute()
 	tx.m.Lock()
 	tx.state.Store(1)
@@ -1329,7 +1421,7 @@ func (tx *Transaction) begin() (err error) {
 
 func (tx *Transaction) commit() (err error) {
 	for _, tree := range tx.trees {
-		if err = writeTreeUpsertQueue(tree); nil != err {
+		if err = writeTreeUpsertQueueDebounced(tree); nil != err {
 			return
 		}
 
@@ -1345,7 +1437,7 @@ func (tx *Transaction) commit() (err error) {
 }
 
 func (tx *Transaction) rollback() {
-	tx.trees, tx.nodes = nil, nil
+	tx.trees, tx.nodes, tx.blockCounts = nil, nil, nil
 	tx.state.Store(3)
 	tx.m.Unlock()
 	return
@@ -1371,6 +1463,18 @@ func (tx *Transaction) loadTree(id string) (ret *parse.Tree, err error) {
 		return
 	}
 	tx.trees[rootID] = ret
+	tx.blockCounts[rootID] = countTreeBlocks(ret)
+	return
+}
+
+func (tx *Transaction) checkQuota() (ret *TxErr) {
+	for _, tree := range tx.trees {
+		// 事务中新建的文档没有原始块数，按 0 处理
+		if err := checkDocBlocksQuota(tree, tx.blockCounts[tree.ID]); nil != err {
+			logging.LogWarnf("check quota of tree [%s] failed: %s", tree.ID, err)
+			return &TxErr{code: TxErrDocBlocksQuota, id: tree.ID, msg: err.Error()}
+		}
+	}
 	return
 }
 
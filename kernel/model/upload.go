@@ -72,6 +72,9 @@ func InsertLocalAssets(id string, assetPaths []string, isUpload bool) (succMap m
 			err = statErr
 			return
 		}
+		if err = checkAssetSizeQuota(id, fName, fi.Size()); nil != err {
+			return
+		}
 		f, openErr := os.Open(p)
 		if nil != openErr {
 			err = openErr
@@ -119,8 +122,9 @@ func Upload(c *gin.Context) {
 		return
 	}
 	assetsDirPath := filepath.Join(util.DataDir, "assets")
+	var id string
 	if nil != form.Value["id"] {
-		id := form.Value["id"][0]
+		id = form.Value["id"][0]
 		bt := treenode.GetBlockTree(id)
 		if nil == bt {
 			ret.Code = -1
@@ -168,6 +172,12 @@ func Upload(c *gin.Context) {
 		fName = strings.TrimSuffix(fName, ext)
 		ext = strings.ToLower(ext)
 		fName += ext
+		if quotaErr := checkAssetSizeQuota(id, fName, file.Size); nil != quotaErr {
+			errFiles = append(errFiles, fName)
+			ret.Msg = quotaErr.Error()
+			continue
+		}
+
 		f, openErr := file.Open()
 		if nil != openErr {
 			errFiles = append(errFiles, fName)
@@ -186,6 +196,10 @@ func Upload(c *gin.Context) {
 		if existAsset := sql.QueryAssetByHash(hash); nil != existAsset {
 			// 已经存在同样数据的资源文件的话不重复保存
 			succMap[baseName] = existAsset.Path
+		} else if converted := getConvertedAssetByHash(hash); "" != converted {
+			// 相同的原始图片已经在上传时转换过格式
+			succMap[baseName] = converted
+			f.Close()
 		} else {
 			if skipIfDuplicated {
 				// https://github.com/siyuan-note/siyuan/issues/10666
@@ -231,6 +245,12 @@ func Upload(c *gin.Context) {
 			}
 			f.Close()
 
+			if !needUnzip2Dir {
+				if convertedPath := convertUploadedImage(writePath, baseName, hash); "" != convertedPath {
+					fName = filepath.Base(convertedPath)
+				}
+			}
+
 			if needUnzip2Dir {
 				baseName = strings.TrimSuffix(file.Filename, ".rtfd.zip") + ".rtfd"
 				fName = baseName
@@ -294,6 +314,7 @@ func Upload(c *gin.Context) {
 	}
 
 	IncSync()
+	offloadUploadedAssets(succMap)
 }
 
 func getAssetsDir(boxLocalPath, docDirLocalPath string) (assets string) {
@@ -0,0 +1,188 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+// virtualRefDicts 是编译后的虚拟引用关键字词典，修改词典或者重建缓存时更新。
+var virtualRefDicts = &compiledVirtualRefDicts{}
+
+type compiledVirtualRefDicts struct {
+	keywords       []string
+	patterns       []*regexp.Regexp
+	excludes       []string
+	excludeRegexps []*regexp.Regexp
+
+	lock sync.RWMutex
+}
+
+const virtualRefDictMaxPatternHits = 256 // 单个文档中正则表达式最多匹配的关键字数
+
+func GetVirtualBlockRefDicts() []*conf.VirtualBlockRefDict {
+	return Conf.Editor.VirtualBlockRefDicts
+}
+
+// SetVirtualBlockRefDicts 校验并保存虚拟引用关键字词典，然后重建虚拟引用缓存。
+func SetVirtualBlockRefDicts(dicts []*conf.VirtualBlockRefDict) (err error) {
+	for _, dict := range dicts {
+		dict.Name = strings.TrimSpace(dict.Name)
+		if "" == dict.Name {
+			return errors.New("dictionary name is empty")
+		}
+		if "" == dict.ID {
+			dict.ID = ast.NewNodeID()
+		}
+		dict.Keywords = trimVirtualRefDictItems(dict.Keywords)
+		dict.Patterns = trimVirtualRefDictItems(dict.Patterns)
+		dict.Excludes = trimVirtualRefDictItems(dict.Excludes)
+		for _, pattern := range dict.Patterns {
+			if _, err = regexp.Compile(pattern); nil != err {
+				return fmt.Errorf("invalid pattern [%s] in dictionary [%s]: %s", pattern, dict.Name, err)
+			}
+		}
+		for _, exclude := range dict.Excludes {
+			if re := virtualRefExcludeRegexp(exclude); "" != re {
+				if _, err = regexp.Compile(re); nil != err {
+					return fmt.Errorf("invalid exclude [%s] in dictionary [%s]: %s", exclude, dict.Name, err)
+				}
+			}
+		}
+	}
+	if nil == dicts {
+		dicts = []*conf.VirtualBlockRefDict{}
+	}
+
+	Conf.Editor.VirtualBlockRefDicts = dicts
+	Conf.Save()
+	ResetVirtualBlockRefCache()
+	return
+}
+
+// rebuildVirtualRefDicts 编译已启用的词典。
+func rebuildVirtualRefDicts() {
+	var keywords, excludes []string
+	var patterns, excludeRegexps []*regexp.Regexp
+	for _, dict := range Conf.Editor.VirtualBlockRefDicts {
+		if !dict.Enabled {
+			continue
+		}
+
+		keywords = append(keywords, dict.Keywords...)
+		for _, pattern := range dict.Patterns {
+			re, err := regexp.Compile(pattern)
+			if nil != err {
+				logging.LogWarnf("compile virtual ref pattern [%s] failed: %s", pattern, err)
+				continue
+			}
+			patterns = append(patterns, re)
+		}
+		for _, exclude := range dict.Excludes {
+			if expr := virtualRefExcludeRegexp(exclude); "" != expr {
+				re, err := regexp.Compile(expr)
+				if nil != err {
+					logging.LogWarnf("compile virtual ref exclude [%s] failed: %s", exclude, err)
+					continue
+				}
+				excludeRegexps = append(excludeRegexps, re)
+			} else {
+				excludes = append(excludes, exclude)
+			}
+		}
+	}
+
+	virtualRefDicts.lock.Lock()
+	defer virtualRefDicts.lock.Unlock()
+	virtualRefDicts.keywords = gulu.Str.RemoveDuplicatedElem(keywords)
+	virtualRefDicts.patterns = patterns
+	virtualRefDicts.excludes = excludes
+	virtualRefDicts.excludeRegexps = excludeRegexps
+}
+
+// dictKeywords 返回词典中的关键字。
+func (dicts *compiledVirtualRefDicts) dictKeywords() (ret []string) {
+	dicts.lock.RLock()
+	defer dicts.lock.RUnlock()
+	return append(ret, dicts.keywords...)
+}
+
+// matchPatterns 返回内容中被正则表达式匹配到的文本。
+func (dicts *compiledVirtualRefDicts) matchPatterns(content string) (ret []string) {
+	dicts.lock.RLock()
+	defer dicts.lock.RUnlock()
+	for _, re := range dicts.patterns {
+		for _, hit := range re.FindAllString(content, virtualRefDictMaxPatternHits) {
+			if hit = strings.TrimSpace(hit); "" != hit {
+				ret = append(ret, hit)
+			}
+		}
+	}
+	return gulu.Str.RemoveDuplicatedElem(ret)
+}
+
+// exclude 移除词典排除列表中的关键字。
+func (dicts *compiledVirtualRefDicts) exclude(keywords []string) (ret []string) {
+	dicts.lock.RLock()
+	defer dicts.lock.RUnlock()
+	if 1 > len(dicts.excludes) && 1 > len(dicts.excludeRegexps) {
+		return keywords
+	}
+
+	for _, keyword := range keywords {
+		if gulu.Str.Contains(keyword, dicts.excludes) {
+			continue
+		}
+		excluded := false
+		for _, re := range dicts.excludeRegexps {
+			if re.MatchString(keyword) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			ret = append(ret, keyword)
+		}
+	}
+	return
+}
+
+func virtualRefExcludeRegexp(exclude string) string {
+	if 2 < len(exclude) && strings.HasPrefix(exclude, "/") && strings.HasSuffix(exclude, "/") {
+		return exclude[1 : len(exclude)-1]
+	}
+	return ""
+}
+
+func trimVirtualRefDictItems(items []string) (ret []string) {
+	ret = []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); "" != item {
+			ret = append(ret, item)
+		}
+	}
+	return gulu.Str.RemoveDuplicatedElem(ret)
+}
@@ -41,6 +41,7 @@ var virtualBlockRefCache, _ = ristretto.NewCache(&ristretto.Config{
 	NumCounters: 102400,
 	MaxCost:     10240,
 	BufferItems: 64,
+	Metrics:     true,
 })
 
 func getBlockVirtualRefKeywords(root *ast.Node) (ret []string) {
@@ -66,6 +67,12 @@ func getBlockVirtualRefKeywords(root *ast.Node) (ret []string) {
 
 func putBlockVirtualRefKeywords(blockContent string, root *ast.Node) (ret []string) {
 	keywords := getVirtualRefKeywords(root)
+	if Conf.Editor.VirtualBlockRef {
+		// 词典中的正则表达式按照文档内容匹配出关键字
+		if hits := virtualRefDicts.matchPatterns(blockContent); 0 < len(hits) {
+			keywords = prepareMarkKeywords(append(keywords, virtualRefDicts.exclude(hits)...))
+		}
+	}
 	if 1 > len(keywords) {
 		return
 	}
@@ -108,6 +115,7 @@ func CacheVirtualBlockRefJob() {
 
 func ResetVirtualBlockRefCache() {
 	virtualBlockRefCache.Clear()
+	rebuildVirtualRefDicts()
 	if !Conf.Editor.VirtualBlockRef {
 		return
 	}
@@ -219,6 +227,8 @@ func getVirtualRefKeywords(root *ast.Node) (ret []string) {
 		ret = val.([]string)
 	}
 
+	ret = append(ret, virtualRefDicts.dictKeywords()...)
+
 	if "" != strings.TrimSpace(Conf.Editor.VirtualBlockRefInclude) {
 		include := strings.ReplaceAll(Conf.Editor.VirtualBlockRefInclude, "\\,", "__comma@sep__")
 		includes := strings.Split(include, ",")
@@ -260,6 +270,8 @@ func getVirtualRefKeywords(root *ast.Node) (ret []string) {
 		}
 	}
 
+	ret = virtualRefDicts.exclude(ret)
+
 	// 虚拟引用排除当前文档名 https://github.com/siyuan-note/siyuan/issues/4537
 	// Virtual references exclude the name and aliases from the current document https://github.com/siyuan-note/siyuan/issues/9204
 	title := root.IALAttr("title")
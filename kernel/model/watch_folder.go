@@ -0,0 +1,194 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// WatchFolderSourceAttr 为导入的文档记录源文件路径的属性名。
+const WatchFolderSourceAttr = "custom-source-path"
+
+var watchFolderLock = sync.Mutex{}
+
+// 已经导入的源文件，键为源文件绝对路径，值为导入时的修改时间和大小，存放在配置目录下，不随数据同步
+func getWatchFolderStatePath() string {
+	return filepath.Join(util.ConfDir, "watch-folders.json")
+}
+
+func loadWatchFolderState() (ret map[string]string) {
+	ret = map[string]string{}
+	data, err := os.ReadFile(getWatchFolderStatePath())
+	if nil != err {
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); nil != err {
+		logging.LogErrorf("unmarshal watch folder state failed: %s", err)
+	}
+	return
+}
+
+func saveWatchFolderState(state map[string]string) {
+	data, err := gulu.JSON.MarshalJSON(state)
+	if nil != err {
+		return
+	}
+	if err = gulu.File.WriteFileSafer(getWatchFolderStatePath(), data, 0644); nil != err {
+		logging.LogErrorf("write watch folder state failed: %s", err)
+	}
+}
+
+// WatchFoldersJob 扫描监听文件夹，将新增的文件导入到目标路径下。
+//
+// Markdown 文件导入为文档，其他文件导入为资源文件并创建一篇链接到该资源文件的文档，文档属性 custom-source-path 记录源文件路径。
+func WatchFoldersJob() {
+	if util.ContainerAndroid == util.Container || util.ContainerIOS == util.Container {
+		return
+	}
+	if !util.IsBooted() || util.IsExiting.Load() || util.ReadOnly || 1 > len(Conf.Asset.WatchFolders) {
+		return
+	}
+
+	watchFolderLock.Lock()
+	defer watchFolderLock.Unlock()
+
+	state := loadWatchFolderState()
+	changed := false
+	for _, folder := range Conf.Asset.WatchFolders {
+		if !folder.Enabled || "" == folder.Path || !gulu.File.IsDir(folder.Path) {
+			continue
+		}
+		if box := Conf.Box(folder.Box); nil == box || box.Closed {
+			continue
+		}
+
+		entries, err := os.ReadDir(folder.Path)
+		if nil != err {
+			logging.LogErrorf("read watch folder [%s] failed: %s", folder.Path, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), ".tmp") {
+				continue
+			}
+
+			info, infoErr := entry.Info()
+			if nil != infoErr {
+				continue
+			}
+
+			// 等待文件写入完成，比如扫描仪或者浏览器仍在写入
+			if time.Since(info.ModTime()) < 3*time.Second {
+				continue
+			}
+
+			srcPath := filepath.Join(folder.Path, entry.Name())
+			fingerprint := strconv.FormatInt(info.ModTime().UnixMilli(), 10) + "-" + strconv.FormatInt(info.Size(), 10)
+			if fingerprint == state[srcPath] {
+				continue
+			}
+
+			if err = importWatchFolderFile(folder, srcPath); nil != err {
+				logging.LogErrorf("import watch folder file [%s] failed: %s", srcPath, err)
+				continue
+			}
+
+			changed = true
+			if folder.Remove {
+				if err = os.Remove(srcPath); nil != err {
+					logging.LogWarnf("remove imported watch folder file [%s] failed: %s", srcPath, err)
+				}
+				delete(state, srcPath)
+				continue
+			}
+			state[srcPath] = fingerprint
+		}
+	}
+
+	if changed {
+		saveWatchFolderState(state)
+	}
+}
+
+func importWatchFolderFile(folder *conf.WatchFolder, srcPath string) (err error) {
+	name := filepath.Base(srcPath)
+	ext := strings.ToLower(filepath.Ext(name))
+	title := strings.TrimSuffix(name, filepath.Ext(name))
+
+	var md string
+	if ".md" == ext || ".markdown" == ext {
+		data, readErr := os.ReadFile(srcPath)
+		if nil != readErr {
+			return readErr
+		}
+		md = string(data)
+	} else {
+		assetName := util.AssetName(util.FilterUploadFileName(name))
+		assetPath := path.Join("assets", assetName)
+		if err = filelock.Copy(srcPath, filepath.Join(util.DataDir, assetPath)); nil != err {
+			return
+		}
+
+		if gulu.Str.Contains(ext, util.SiYuanAssetsImage) {
+			md = fmt.Sprintf("![%s](%s)", title, assetPath)
+		} else {
+			md = fmt.Sprintf("[%s](%s)", name, assetPath)
+		}
+	}
+
+	hPath := getWatchFolderDocHPath(folder, title)
+	id, err := CreateWithMarkdown(folder.Box, hPath, md, "", "", false)
+	if nil != err {
+		return
+	}
+
+	if err = SetBlockAttrs(id, map[string]string{WatchFolderSourceAttr: srcPath}); nil != err {
+		return
+	}
+	logging.LogInfof("imported watch folder file [%s] to [%s]", srcPath, hPath)
+	return
+}
+
+// getWatchFolderDocHPath 返回导入文档的路径，存在同名文档时追加序号。
+func getWatchFolderDocHPath(folder *conf.WatchFolder, title string) (ret string) {
+	parentHPath := "/" + strings.Trim(folder.HPath, "/")
+	if "/" == parentHPath {
+		parentHPath = ""
+	}
+
+	title = util.FilterFileName(title)
+	ret = parentHPath + "/" + title
+	for i := 2; nil != treenode.GetBlockTreeRootByHPath(folder.Box, ret); i++ {
+		ret = parentHPath + "/" + title + " (" + strconv.Itoa(i) + ")"
+	}
+	return
+}
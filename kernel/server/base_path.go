@@ -0,0 +1,200 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// basePathHandler 在部署到子路径时去掉请求路径中的 URL 前缀。
+//
+// 反向代理可能保留前缀转发，也可能已经去掉前缀，这两种请求都可以处理。
+func basePathHandler(handler http.Handler) http.Handler {
+	if "" == util.BasePath {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if util.BasePath == r.URL.Path {
+			http.Redirect(w, r, util.BasePath+"/", http.StatusFound)
+			return
+		}
+
+		if p := strings.TrimPrefix(r.URL.Path, util.BasePath); p != r.URL.Path && strings.HasPrefix(p, "/") {
+			r.URL.Path = p
+			if "" != r.URL.RawPath {
+				r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, util.BasePath)
+			}
+			r.RequestURI = r.URL.RequestURI()
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// rewriteBasePath 在部署到子路径时为响应中的跳转地址和 HTML 页面中的绝对路径链接加上 URL 前缀，
+// 并注入脚本改写前端在运行时发起的请求、WebSocket 连接和动态加载的资源地址。
+//
+// 需要注册在 gzip 中间件之后，以便处理压缩前的响应内容。
+func rewriteBasePath(c *gin.Context) {
+	if "" == util.BasePath {
+		c.Next()
+		return
+	}
+
+	writer := &basePathWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	writer.flush()
+}
+
+type basePathWriter struct {
+	gin.ResponseWriter
+	prepared bool
+	html     bool
+	buf      bytes.Buffer
+}
+
+func (w *basePathWriter) prepare() {
+	if w.prepared {
+		return
+	}
+	w.prepared = true
+
+	header := w.Header()
+	if location := header.Get("Location"); strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") &&
+		!strings.HasPrefix(location, util.BasePath+"/") {
+		header.Set("Location", util.BasePath+location)
+	}
+	if strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+		w.html = true
+		header.Del("Content-Length")
+	}
+}
+
+func (w *basePathWriter) WriteHeader(code int) {
+	w.prepare()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *basePathWriter) Write(data []byte) (int, error) {
+	w.prepare()
+	if w.html {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *basePathWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *basePathWriter) flush() {
+	if !w.html || 1 > w.buf.Len() {
+		return
+	}
+	w.ResponseWriter.Write(rewriteBasePathHTML(w.buf.Bytes()))
+}
+
+var basePathAttrRegexp = regexp.MustCompile(`(\s(?:src|href|action|xlink:href)=["'])/([^/"'])`)
+
+func rewriteBasePathHTML(html []byte) []byte {
+	prefix := []byte(util.BasePath + "/")
+	html = basePathAttrRegexp.ReplaceAllFunc(html, func(attr []byte) []byte {
+		idx := bytes.IndexAny(attr, `"'`) + 1
+		if bytes.HasPrefix(attr[idx:], prefix) {
+			return attr
+		}
+		return append(append(append([]byte{}, attr[:idx]...), util.BasePath...), attr[idx:]...)
+	})
+
+	script := []byte(strings.ReplaceAll(basePathScript, "{{basePath}}", util.BasePath))
+	if idx := bytes.Index(html, []byte("<head>")); 0 <= idx {
+		idx += len("<head>")
+		return append(append(append([]byte{}, html[:idx]...), script...), html[idx:]...)
+	}
+	return append(script, html...)
+}
+
+// basePathScript 改写前端使用绝对路径发起的请求，同源的 WebSocket 地址也会加上 URL 前缀。
+const basePathScript = `<script>(function () {
+    const base = "{{basePath}}";
+    window.siyuanBasePath = base;
+    const fix = (url) => {
+        if ("string" === typeof url && url.startsWith("/") && !url.startsWith("//") && !url.startsWith(base + "/")) {
+            return base + url;
+        }
+        return url;
+    };
+    const fixAbs = (url) => {
+        try {
+            const u = new URL(url, location.href);
+            if (u.host === location.host && !u.pathname.startsWith(base + "/")) {
+                u.pathname = base + u.pathname;
+                return u.toString();
+            }
+        } catch (e) {
+        }
+        return url;
+    };
+    const fetch0 = window.fetch;
+    window.fetch = function (input, init) {
+        return fetch0.call(this, input instanceof Request ? input : fix(input), init);
+    };
+    const open0 = XMLHttpRequest.prototype.open;
+    XMLHttpRequest.prototype.open = function () {
+        arguments[1] = fix(arguments[1]);
+        return open0.apply(this, arguments);
+    };
+    const WebSocket0 = window.WebSocket;
+    window.WebSocket = function (url, protocols) {
+        return new WebSocket0(fixAbs(url), protocols);
+    };
+    window.WebSocket.prototype = WebSocket0.prototype;
+    Object.assign(window.WebSocket, {CONNECTING: 0, OPEN: 1, CLOSING: 2, CLOSED: 3});
+    const windowOpen0 = window.open;
+    window.open = function (url) {
+        arguments[0] = fix(url);
+        return windowOpen0.apply(this, arguments);
+    };
+    const setAttribute0 = Element.prototype.setAttribute;
+    Element.prototype.setAttribute = function (name, value) {
+        if ("src" === name || "href" === name || "xlink:href" === name) {
+            value = fix(value);
+        }
+        return setAttribute0.call(this, name, value);
+    };
+    [HTMLScriptElement, HTMLImageElement, HTMLIFrameElement, HTMLLinkElement, HTMLAnchorElement].forEach((type) => {
+        ["src", "href"].forEach((name) => {
+            const descriptor = Object.getOwnPropertyDescriptor(type.prototype, name);
+            if (descriptor && descriptor.set) {
+                Object.defineProperty(type.prototype, name, {
+                    get: descriptor.get,
+                    set(value) {
+                        descriptor.set.call(this, fix(value));
+                    },
+                    configurable: true,
+                });
+            }
+        });
+    });
+})();</script>`
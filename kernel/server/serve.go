@@ -18,6 +18,7 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"html/template"
 	"net"
@@ -28,6 +29,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -53,11 +55,14 @@ func Serve(fastMode bool) {
 	ginServer.UseH2C = true
 	ginServer.MaxMultipartMemory = 1024 * 1024 * 32 // 插入较大的资源文件时内存占用较大 https://github.com/siyuan-note/siyuan/issues/5023
 	ginServer.Use(
+		model.CheckNetworkACL,    // 网络访问控制列表
 		model.ControlConcurrency, // 请求串行化 Concurrency control when requesting the kernel API https://github.com/siyuan-note/siyuan/issues/9939
 		model.Timing,
+		model.ObserveRequest, // 运行指标采集
 		model.Recover,
 		corsMiddleware(), // 后端服务支持 CORS 预检请求验证 https://github.com/siyuan-note/siyuan/pull/5593
 		gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedExtensions([]string{".pdf", ".mp3", ".wav", ".ogg", ".mov", ".weba", ".mkv", ".mp4", ".webm"})),
+		rewriteBasePath, // 部署到子路径时改写响应中的链接
 	)
 
 	cookieStore.Options(sessions.Options{
@@ -78,6 +83,7 @@ func Serve(fastMode bool) {
 	serveEmojis(ginServer)
 	serveTemplates(ginServer)
 	servePublic(ginServer)
+	serveShare(ginServer)
 	serveRepoDiff(ginServer)
 	api.ServeAPI(ginServer)
 
@@ -113,7 +119,7 @@ func Serve(fastMode bool) {
 		rewritePortJSON(pid, port)
 	}
 
-	logging.LogInfof("kernel [pid=%s] http server [%s] is booting", pid, host+":"+port)
+	logging.LogInfof("kernel [pid=%s] http server [%s%s] is booting", pid, host+":"+port, util.BasePath)
 	util.HttpServing = true
 
 	go func() {
@@ -135,8 +141,10 @@ func Serve(fastMode bool) {
 	}()
 
 	go util.HookUILoaded()
+	handler := basePathHandler(ginServer.Handler())
+	go serveNetworkTLS(handler)
 
-	if err = http.Serve(ln, ginServer.Handler()); nil != err {
+	if err = http.Serve(ln, handler); nil != err {
 		if !fastMode {
 			logging.LogErrorf("boot kernel failed: %s", err)
 			os.Exit(logging.ExitCodeUnavailablePort)
@@ -144,6 +152,36 @@ func Serve(fastMode bool) {
 	}
 }
 
+// serveNetworkTLS 启用 ACME 证书时在 HTTPS 端口提供服务，并在 HTTP 端口处理 http-01 验证和跳转。
+func serveNetworkTLS(handler http.Handler) {
+	if !model.NetworkTLSEnabled() {
+		return
+	}
+
+	tlsConf := model.Conf.System.NetworkTLS
+	if 0 < tlsConf.HTTPPort {
+		go func() {
+			addr := ":" + strconv.Itoa(tlsConf.HTTPPort)
+			logging.LogInfof("ACME http server [%s] is booting", addr)
+			if err := http.ListenAndServe(addr, model.ACMEHTTPHandler()); nil != err {
+				logging.LogErrorf("boot ACME http server [%s] failed: %s", addr, err)
+			}
+		}()
+	}
+
+	model.StartNetworkTLS()
+
+	server := &http.Server{
+		Addr:      ":" + strconv.Itoa(tlsConf.HTTPSPort),
+		Handler:   handler,
+		TLSConfig: &tls.Config{GetCertificate: model.GetNetworkTLSCertificate, MinVersion: tls.VersionTLS12},
+	}
+	logging.LogInfof("https server [%s] is booting", server.Addr)
+	if err := server.ListenAndServeTLS("", ""); nil != err {
+		logging.LogErrorf("boot https server [%s] failed: %s", server.Addr, err)
+	}
+}
+
 func rewritePortJSON(pid, port string) {
 	portJSON := filepath.Join(util.HomeDir, ".config", "siyuan", "port.json")
 	pidPorts := map[string]string{}
@@ -368,23 +406,50 @@ func serveAssets(ginServer *gin.Engine) {
 	ginServer.GET("/assets/*path", model.CheckAuth, func(context *gin.Context) {
 		requestPath := context.Param("path")
 		relativePath := path.Join("assets", requestPath)
+		if signedURL := model.GetOffloadedAssetSignedURL(relativePath); "" != signedURL {
+			context.Redirect(http.StatusFound, signedURL)
+			return
+		}
+
 		p, err := model.GetAssetAbsPath(relativePath)
 		if nil != err {
+			if strings.HasPrefix(requestPath, "/thumb/") {
+				serveAssetThumb(context, path.Join("assets", strings.TrimPrefix(requestPath, "/thumb/")))
+				return
+			}
+
 			context.Status(404)
 			return
 		}
 		http.ServeFile(context.Writer, context.Request, p)
 		return
 	})
-	ginServer.GET("/history/*path", model.CheckAuth, func(context *gin.Context) {
+	ginServer.GET("/history/*path", model.CheckAuth, model.CheckNoRole, func(context *gin.Context) {
 		p := filepath.Join(util.HistoryDir, context.Param("path"))
 		http.ServeFile(context.Writer, context.Request, p)
 		return
 	})
 }
 
+// serveAssetThumb 响应资源文件缩略图 /assets/thumb/*，缩略图生成失败时回退到原始资源文件。
+func serveAssetThumb(context *gin.Context, relativePath string) {
+	thumb, err := model.GetAssetThumb(relativePath, model.ThumbSize(context.Query("size")))
+	if nil != err {
+		p, pathErr := model.GetAssetAbsPath(relativePath)
+		if nil != pathErr {
+			context.Status(404)
+			return
+		}
+		http.ServeFile(context.Writer, context.Request, p)
+		return
+	}
+
+	context.Header("Cache-Control", "private, max-age=86400")
+	http.ServeFile(context.Writer, context.Request, thumb)
+}
+
 func serveRepoDiff(ginServer *gin.Engine) {
-	ginServer.GET("/repo/diff/*path", model.CheckAuth, func(context *gin.Context) {
+	ginServer.GET("/repo/diff/*path", model.CheckAuth, model.CheckNoRole, func(context *gin.Context) {
 		requestPath := context.Param("path")
 		p := filepath.Join(util.TempDir, "repo", "diff", requestPath)
 		http.ServeFile(context.Writer, context.Request, p)
@@ -393,26 +458,34 @@ func serveRepoDiff(ginServer *gin.Engine) {
 }
 
 func serveDebug(ginServer *gin.Engine) {
+	ginServer.GET("/metrics", model.CheckAuth, model.CheckMetrics, model.ServeMetrics)
+
+	var guards []gin.HandlerFunc
 	if "prod" == util.Mode {
 		// The production environment will no longer register `/debug/pprof/` https://github.com/siyuan-note/siyuan/issues/10152
-		return
+		// 生产环境下仅在开启性能剖析后允许管理员访问
+		guards = append(guards, model.CheckAuth, model.CheckPprof)
 	}
 
-	ginServer.GET("/debug/pprof/", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/allocs", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/block", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/goroutine", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/heap", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/mutex", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/threadcreate", gin.WrapF(pprof.Index))
-	ginServer.GET("/debug/pprof/cmdline", gin.WrapF(pprof.Cmdline))
-	ginServer.GET("/debug/pprof/profile", gin.WrapF(pprof.Profile))
-	ginServer.GET("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
-	ginServer.GET("/debug/pprof/trace", gin.WrapF(pprof.Trace))
+	debugGET := func(relativePath string, handler http.HandlerFunc) {
+		ginServer.GET(relativePath, append(guards, gin.WrapF(handler))...)
+	}
+	debugGET("/debug/pprof/", pprof.Index)
+	debugGET("/debug/pprof/allocs", pprof.Index)
+	debugGET("/debug/pprof/block", pprof.Index)
+	debugGET("/debug/pprof/goroutine", pprof.Index)
+	debugGET("/debug/pprof/heap", pprof.Index)
+	debugGET("/debug/pprof/mutex", pprof.Index)
+	debugGET("/debug/pprof/threadcreate", pprof.Index)
+	debugGET("/debug/pprof/cmdline", pprof.Cmdline)
+	debugGET("/debug/pprof/profile", pprof.Profile)
+	debugGET("/debug/pprof/symbol", pprof.Symbol)
+	debugGET("/debug/pprof/trace", pprof.Trace)
 }
 
 func serveWebSocket(ginServer *gin.Engine) {
 	util.WebSocketServer.Config.MaxMessageSize = 1024 * 1024 * 8
+	util.WebSocketServer.Upgrader.EnableCompression = true // 支持 permessage-deflate 压缩，减少移动端和远程访问的流量
 
 	ginServer.GET("/ws", func(c *gin.Context) {
 		if err := util.WebSocketServer.HandleRequest(c.Writer, c.Request); nil != err {
@@ -427,8 +500,9 @@ func serveWebSocket(ginServer *gin.Engine) {
 	util.WebSocketServer.HandleConnect(func(s *melody.Session) {
 		//logging.LogInfof("ws check auth for [%s]", s.Request.RequestURI)
 		authOk := true
+		var authSessionID string
 
-		if "" != model.Conf.AccessAuthCode {
+		if "" != model.Conf.AccessAuthCode || model.OIDCEnabled() {
 			session, err := cookieStore.Get(s.Request, "siyuan")
 			if nil != err {
 				authOk = false
@@ -445,7 +519,13 @@ func serveWebSocket(ginServer *gin.Engine) {
 						logging.LogErrorf("unmarshal cookie failed: %s", err)
 					} else {
 						workspaceSess := util.GetWorkspaceSession(sess)
-						authOk = workspaceSess.AccessAuthCode == model.Conf.AccessAuthCode
+						authOk = ("" != model.Conf.AccessAuthCode && workspaceSess.AccessAuthCode == model.Conf.AccessAuthCode) ||
+							model.IsOIDCSessionValid(workspaceSess)
+						if !model.IsOIDCSessionValid(workspaceSess) {
+							authOk = authOk && model.IsTOTPVerified(s.Request, workspaceSess)
+						}
+						authOk = authOk && model.IsAuthSessionValid(workspaceSess.SessionID)
+						authSessionID = workspaceSess.SessionID
 					}
 				}
 			}
@@ -463,12 +543,18 @@ func serveWebSocket(ginServer *gin.Engine) {
 		}
 
 		util.AddPushChan(s)
+		if "" != authSessionID {
+			s.Set("authSession", authSessionID)
+		}
 		//sessionId, _ := s.Get("id")
 		//logging.LogInfof("ws [%s] connected", sessionId)
 	})
 
 	util.WebSocketServer.HandleDisconnect(func(s *melody.Session) {
 		util.RemovePushChan(s)
+		if id, ok := s.Get("id"); ok {
+			model.RemoveCollabPresence(id.(string))
+		}
 		//sessionId, _ := s.Get("id")
 		//logging.LogInfof("ws [%s] disconnected", sessionId)
 	})
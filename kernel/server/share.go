@@ -0,0 +1,244 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// serveShare 提供文档分享链接的只读访问，不需要鉴权，通过 token 和可选的密码控制访问范围。
+func serveShare(ginServer *gin.Engine) {
+	ginServer.GET("/share/:token", func(c *gin.Context) {
+		serveShareDoc(c, "")
+	})
+	ginServer.GET("/share/:token/doc/:id", func(c *gin.Context) {
+		serveShareDoc(c, c.Param("id"))
+	})
+	ginServer.POST("/share/:token", func(c *gin.Context) {
+		share := model.GetValidShare(c.Param("token"))
+		if nil == share {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		cookie, err := model.CheckSharePassword(share, c.PostForm("password"))
+		if nil != err {
+			logging.LogWarnf("invalid share password [token=%s, ip=%s]: %s", share.Token[:4], util.GetRemoteAddr(c.Request), err)
+			if errors.Is(err, model.ErrSharePasswordLocked) {
+				renderSharePage(c, http.StatusTooManyRequests, map[string]interface{}{"password": true, "locked": true})
+				return
+			}
+			renderSharePage(c, http.StatusUnauthorized, map[string]interface{}{"password": true, "wrong": true})
+			return
+		}
+
+		maxAge := 60 * 60 * 24 * 7
+		if 0 < share.Expired {
+			maxAge = int(time.Until(time.UnixMilli(share.Expired)).Seconds())
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(shareCookieName(share.Token), cookie, maxAge, util.BasePath+"/share/"+share.Token, "", util.SSL, true)
+		c.Redirect(http.StatusFound, "/share/"+share.Token)
+	})
+	ginServer.POST("/share/:token/comment", func(c *gin.Context) {
+		share := model.GetValidShare(c.Param("token"))
+		if nil == share || !model.IsShareAccessible(share, shareCookie(c, share)) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		docID := c.PostForm("docID")
+		to := "/share/" + share.Token
+		if "" != docID && docID != share.ID {
+			to += "/doc/" + docID
+		}
+		result := "ok"
+		if err := model.AddShareComment(share, docID, c.PostForm("blockID"), c.PostForm("name"), c.PostForm("content"), util.GetRemoteAddr(c.Request)); nil != err {
+			result = "invalid"
+			if errors.Is(err, model.ErrShareCommentTooMany) {
+				result = "tooMany"
+			}
+		}
+		c.Redirect(http.StatusFound, to+"?comment="+result)
+	})
+	ginServer.GET("/share/:token/assets/*path", func(c *gin.Context) {
+		share := model.GetValidShare(c.Param("token"))
+		if nil == share || !model.IsShareAccessible(share, shareCookie(c, share)) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		p, err := model.GetShareAssetAbsPath(share, "assets"+c.Param("path"))
+		if nil != err {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		http.ServeFile(c.Writer, c.Request, p)
+	})
+}
+
+func serveShareDoc(c *gin.Context, id string) {
+	share := model.GetValidShare(c.Param("token"))
+	if nil == share {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if !model.IsShareAccessible(share, shareCookie(c, share)) {
+		renderSharePage(c, http.StatusOK, map[string]interface{}{"password": true})
+		return
+	}
+
+	title, dom, docs, err := model.RenderShareDoc(share, id)
+	if nil != err {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	docID := id
+	if "" == docID {
+		docID = share.ID
+	}
+	var comments []*model.ShareComment
+	if share.Comment {
+		comments = model.GetApprovedShareComments(share, docID)
+	}
+
+	theme := model.Conf.Appearance.ThemeLight
+	if 1 == model.Conf.Appearance.Mode {
+		theme = model.Conf.Appearance.ThemeDark
+	}
+	renderSharePage(c, http.StatusOK, map[string]interface{}{
+		"title":       title,
+		"content":     template.HTML(dom),
+		"docs":        docs,
+		"token":       share.Token,
+		"rootID":      share.ID,
+		"docID":       docID,
+		"comment":     share.Comment,
+		"comments":    comments,
+		"commentMsg":  c.Query("comment"),
+		"theme":       theme,
+		"mode":        model.Conf.Appearance.Mode,
+		"icon":        model.Conf.Appearance.Icon,
+		"lang":        model.Conf.Appearance.Lang,
+		"codeTheme":   model.Conf.Appearance.CodeBlockThemeLight,
+		"codeThemeDk": model.Conf.Appearance.CodeBlockThemeDark,
+		"ver":         util.Ver,
+	})
+}
+
+func renderSharePage(c *gin.Context, status int, data map[string]interface{}) {
+	buf := &bytes.Buffer{}
+	if err := shareTpl.Execute(buf, data); nil != err {
+		logging.LogErrorf("execute share page failed: %s", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Header("X-Robots-Tag", "noindex")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func shareCookieName(token string) string {
+	return "siyuan-share-" + token[:8]
+}
+
+func shareCookie(c *gin.Context, share *model.Share) string {
+	cookie, _ := c.Cookie(shareCookieName(share.Token))
+	return cookie
+}
+
+var shareTpl = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="{{.lang}}" data-theme-mode="{{if eq .mode 1}}dark{{else}}light{{end}}">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+    <meta name="robots" content="noindex">
+    {{if .password}}<title>SiYuan</title>{{else}}<base href="/share/{{.token}}/">
+    <script src="/stage/protyle/js/protyle-html.js?v={{.ver}}"></script>
+    <link rel="stylesheet" type="text/css" href="/stage/build/export/base.css?{{.ver}}"/>
+    <link rel="stylesheet" type="text/css" href="/appearance/themes/{{.theme}}/theme.css?{{.ver}}"/>
+    <title>{{.title}}</title>{{end}}
+    <style>
+        body {font-family: var(--b3-font-family);background-color: var(--b3-theme-background);color: var(--b3-theme-on-background);margin: 0}
+        .share {display: flex;max-width: 1100px;margin: 0 auto}
+        .share__nav {width: 240px;flex-shrink: 0;padding: 24px 16px;font-size: 14px;line-height: 1.8}
+        .share__nav a {display: block;color: inherit;text-decoration: none;overflow: hidden;text-overflow: ellipsis;white-space: nowrap}
+        .share__password {max-width: 320px;margin: 20vh auto;text-align: center}
+        .share__password input {width: 100%;box-sizing: border-box;padding: 8px;margin: 8px 0}
+        .share__comments {max-width: 800px;margin: 32px auto;padding: 0 16px;font-size: 14px}
+        .share__comment {border-top: 1px solid var(--b3-border-color);padding: 8px 0;white-space: pre-wrap}
+        .share__comments input, .share__comments textarea {width: 100%;box-sizing: border-box;padding: 8px;margin: 4px 0}
+    </style>
+</head>
+<body>
+{{if .password}}<form class="share__password" method="post">
+    {{if .wrong}}<p style="color: #d23f31">Wrong password</p>{{end}}
+    {{if .locked}}<p style="color: #d23f31">Too many wrong passwords, please try again later</p>{{end}}
+    <input type="password" name="password" placeholder="Password" autofocus>
+    <button type="submit">OK</button>
+</form>{{else}}<div class="share">
+    {{if .docs}}<nav class="share__nav">
+        <a href="">{{.title}}</a>
+        {{range .docs}}<a href="doc/{{.ID}}" style="padding-left: {{.Depth}}em">{{.Title}}</a>
+        {{end}}
+    </nav>{{end}}
+    <div class="protyle-wysiwyg" style="max-width: 800px;margin: 0 auto;flex: 1" id="preview">{{.content}}</div>
+</div>
+{{if .comment}}<div class="share__comments">
+    {{range .comments}}<div class="share__comment"><b>{{if .Name}}{{.Name}}{{else}}Guest{{end}}</b>
+{{.Content}}</div>
+    {{end}}
+    {{if eq .commentMsg "ok"}}<p>Your comment has been submitted and is awaiting moderation.</p>
+    {{else if eq .commentMsg "tooMany"}}<p style="color: #d23f31">Too many comments, please try again later.</p>
+    {{else if eq .commentMsg "invalid"}}<p style="color: #d23f31">Failed to submit the comment.</p>{{end}}
+    <form method="post" action="comment">
+        <input type="hidden" name="docID" value="{{.docID}}">
+        <input type="text" name="name" maxlength="64" placeholder="Name (optional)">
+        <textarea name="content" rows="4" maxlength="4096" placeholder="Comment" required></textarea>
+        <button type="submit">Submit</button>
+    </form>
+</div>{{end}}
+<script src="/appearance/icons/{{.icon}}/icon.js?{{.ver}}"></script>
+<script src="/stage/build/export/protyle-method.js?{{.ver}}"></script>
+<script src="/stage/protyle/js/lute/lute.min.js?{{.ver}}"></script>
+<script>
+    window.siyuan = {config: {appearance: {mode: {{.mode}}, codeBlockThemeLight: {{.codeTheme}}, codeBlockThemeDark: {{.codeThemeDk}}},
+        editor: {codeLineWrap: true, fontSize: 16, codeLigatures: false, plantUMLServePath: "", codeSyntaxHighlightLineNum: false, katexMacros: "{}"}},
+        languages: {copy: "Copy"}};
+    const previewElement = document.getElementById("preview");
+    Protyle.highlightRender(previewElement, "/stage/protyle");
+    Protyle.mathRender(previewElement, "/stage/protyle", false);
+    Protyle.mermaidRender(previewElement, "/stage/protyle");
+    Protyle.flowchartRender(previewElement, "/stage/protyle");
+    Protyle.graphvizRender(previewElement, "/stage/protyle");
+    Protyle.chartRender(previewElement, "/stage/protyle");
+    Protyle.mindmapRender(previewElement, "/stage/protyle");
+    Protyle.abcRender(previewElement, "/stage/protyle");
+</script>{{end}}
+</body>
+</html>
+`))
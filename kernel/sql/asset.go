@@ -115,6 +115,44 @@ func QueryAssetByHash(hash string) (ret *Asset) {
 	return
 }
 
+func QueryAssetsByPath(path string) (ret []*Asset) {
+	sqlStmt := "SELECT * FROM assets WHERE path = ?"
+	rows, err := query(sqlStmt, path)
+	if nil != err {
+		logging.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if asset := scanAssetRows(rows); nil != asset {
+			ret = append(ret, asset)
+		}
+	}
+	return
+}
+
+// QueryAssetRefCounts 返回每个资源文件被引用的块数，键为资源文件路径。
+func QueryAssetRefCounts() (ret map[string]int) {
+	ret = map[string]int{}
+	sqlStmt := "SELECT path, COUNT(DISTINCT block_id) FROM assets GROUP BY path"
+	rows, err := query(sqlStmt)
+	if nil != err {
+		logging.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var count int
+		if err = rows.Scan(&path, &count); nil != err {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[path] = count
+	}
+	return
+}
+
 func scanAssetRows(rows *sql.Rows) (ret *Asset) {
 	var asset Asset
 	if err := rows.Scan(&asset.ID, &asset.BlockID, &asset.RootID, &asset.Box, &asset.DocPath, &asset.Path, &asset.Name, &asset.Title, &asset.Hash); nil != err {
@@ -32,11 +32,12 @@ type AssetContent struct {
 	Size    int64
 	Updated int64
 	Content string
+	Page    int // 页码，从 1 开始，为 0 时表示不分页
 }
 
 const (
-	AssetContentsFTSCaseInsensitiveInsert = "INSERT INTO asset_contents_fts_case_insensitive (id, name, ext, path, size, updated, content) VALUES %s"
-	AssetContentsPlaceholder              = "(?, ?, ?, ?, ?, ?, ?)"
+	AssetContentsFTSCaseInsensitiveInsert = "INSERT INTO asset_contents_fts_case_insensitive (id, name, ext, path, size, updated, content, page) VALUES %s"
+	AssetContentsPlaceholder              = "(?, ?, ?, ?, ?, ?, ?, ?)"
 )
 
 func insertAssetContents(tx *sql.Tx, assetContents []*AssetContent, context map[string]interface{}) (err error) {
@@ -76,6 +77,7 @@ func insertAssetContents0(tx *sql.Tx, bulk []*AssetContent, context map[string]i
 		valueArgs = append(valueArgs, b.Size)
 		valueArgs = append(valueArgs, b.Updated)
 		valueArgs = append(valueArgs, b.Content)
+		valueArgs = append(valueArgs, b.Page)
 	}
 
 	stmt := fmt.Sprintf(AssetContentsFTSCaseInsensitiveInsert, strings.Join(valueStrings, ","))
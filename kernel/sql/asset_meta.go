@@ -0,0 +1,113 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/siyuan-note/logging"
+)
+
+// AssetMeta 描述了资源文件的元数据，图片来自 EXIF，PDF 来自文档信息字典。
+type AssetMeta struct {
+	Path      string
+	Captured  string  // 拍摄或者创建时间，格式为 yyyyMMddHHmmss，未知时为空
+	Latitude  float64 // 纬度，没有 GPS 信息时为 0
+	Longitude float64 // 经度，没有 GPS 信息时为 0
+	Make      string  // 相机厂商
+	Model     string  // 相机型号
+	Author    string  // 文档作者
+	Title     string  // 文档标题
+}
+
+const (
+	AssetMetasInsert      = "INSERT INTO asset_metas (path, captured, latitude, longitude, make, model, author, title) VALUES %s"
+	AssetMetasPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+func insertAssetMetas(tx *sql.Tx, assetMetas []*AssetMeta) (err error) {
+	if 1 > len(assetMetas) {
+		return
+	}
+
+	var bulk []*AssetMeta
+	for _, assetMeta := range assetMetas {
+		bulk = append(bulk, assetMeta)
+		if 512 > len(bulk) {
+			continue
+		}
+
+		if err = insertAssetMetas0(tx, bulk); nil != err {
+			return
+		}
+		bulk = []*AssetMeta{}
+	}
+	if 0 < len(bulk) {
+		err = insertAssetMetas0(tx, bulk)
+	}
+	return
+}
+
+func insertAssetMetas0(tx *sql.Tx, bulk []*AssetMeta) (err error) {
+	valueStrings := make([]string, 0, len(bulk))
+	valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(AssetMetasPlaceholder, "?"))
+	for _, m := range bulk {
+		valueStrings = append(valueStrings, AssetMetasPlaceholder)
+		valueArgs = append(valueArgs, m.Path, m.Captured, m.Latitude, m.Longitude, m.Make, m.Model, m.Author, m.Title)
+	}
+
+	stmt := fmt.Sprintf(AssetMetasInsert, strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
+
+func deleteAssetMetasByPath(tx *sql.Tx, path string) (err error) {
+	err = execStmtTx(tx, "DELETE FROM asset_metas WHERE path = ?", path)
+	return
+}
+
+// GetAssetMetas 返回指定资源文件的元数据，键为资源文件路径。
+func GetAssetMetas(paths []string) (ret map[string]*AssetMeta) {
+	ret = map[string]*AssetMeta{}
+	if 1 > len(paths) {
+		return
+	}
+
+	args := make([]interface{}, 0, len(paths))
+	for _, p := range paths {
+		args = append(args, p)
+	}
+	stmt := "SELECT path, captured, latitude, longitude, make, model, author, title FROM asset_metas WHERE path IN (?" + strings.Repeat(", ?", len(paths)-1) + ")"
+	rows, err := queryAssetContent(stmt, args...)
+	if nil != err {
+		logging.LogErrorf("query asset metas failed: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m AssetMeta
+		if err = rows.Scan(&m.Path, &m.Captured, &m.Latitude, &m.Longitude, &m.Make, &m.Model, &m.Author, &m.Title); nil != err {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[m.Path] = &m
+	}
+	return
+}
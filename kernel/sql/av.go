@@ -52,6 +52,7 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 		Columns:          []*av.TableColumn{},
 		Rows:             []*av.TableRow{},
 		Filters:          view.Table.Filters,
+		FilterGroup:      view.Table.FilterGroup,
 		Sorts:            view.Table.Sorts,
 	}
 
@@ -74,6 +75,10 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 			Relation:     key.Relation,
 			Rollup:       key.Rollup,
 			Date:         key.Date,
+			Rating:       key.Rating,
+			Currency:     key.Currency,
+			Duration:     key.Duration,
+			Validation:   key.Validation,
 			Wrap:         col.Wrap,
 			Hidden:       col.Hidden,
 			Width:        col.Width,
@@ -151,6 +156,12 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 					tableCell.Value.Number.Format = col.NumberFormat
 					tableCell.Value.Number.FormatNumber()
 				}
+			case av.KeyTypeRating, av.KeyTypeProgress, av.KeyTypeCurrency, av.KeyTypeDuration: // 格式化评分、进度、货币和时长
+				if nil != tableCell.Value {
+					key, _ := attrView.GetKey(col.ID)
+					tableCell.Value.Type = col.Type
+					tableCell.Value.FormatMeasure(key)
+				}
 			case av.KeyTypeTemplate: // 渲染模板列
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeTemplate, Template: &av.ValueTemplate{Content: col.Template}}
 			case av.KeyTypeCreated: // 填充创建时间列值，后面再渲染
@@ -213,6 +224,8 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 					if av.KeyTypeNumber == destKey.Type {
 						destVal.Number.Format = destKey.NumberFormat
 						destVal.Number.FormatNumber()
+					} else if av.IsMeasureKeyType(destKey.Type) {
+						destVal.FormatMeasure(destKey)
 					}
 
 					cell.Value.Rollup.Contents = append(cell.Value.Rollup.Contents, destVal.Clone())
@@ -440,6 +453,10 @@ func RenderTemplateCol(ial map[string]string, rowValues []*av.KeyValues, tplCont
 					dataModel[rowValue.Key.Name+"_end"] = time.UnixMilli(v.Date.Content2)
 				}
 			}
+		} else if av.IsMeasureKeyType(v.Type) {
+			if number, ok := v.Measure(); ok {
+				dataModel[rowValue.Key.Name] = number
+			}
 		} else if av.KeyTypeRollup == v.Type {
 			if 0 < len(v.Rollup.Contents) {
 				var numbers []float64
@@ -547,6 +564,22 @@ func FillAttributeViewTableCellNilValue(tableCell *av.TableCell, rowID, colID st
 		if nil == tableCell.Value.Rollup {
 			tableCell.Value.Rollup = &av.ValueRollup{}
 		}
+	case av.KeyTypeRating:
+		if nil == tableCell.Value.Rating {
+			tableCell.Value.Rating = &av.ValueRating{}
+		}
+	case av.KeyTypeProgress:
+		if nil == tableCell.Value.Progress {
+			tableCell.Value.Progress = &av.ValueProgress{}
+		}
+	case av.KeyTypeCurrency:
+		if nil == tableCell.Value.Currency {
+			tableCell.Value.Currency = &av.ValueCurrency{}
+		}
+	case av.KeyTypeDuration:
+		if nil == tableCell.Value.Duration {
+			tableCell.Value.Duration = &av.ValueDuration{}
+		}
 	}
 }
 
@@ -562,10 +595,21 @@ func getAttributeViewContent(avID string,
 		return
 	}
 
+	// 对某些角色隐藏的视图和列不写入块内容，否则带角色的调用方可以通过 blocks 表或者全文索引查询到隐藏列的单元格
+	hiddenKeys := map[string]bool{}
+	for _, kv := range attrView.KeyValues {
+		if kv.Key.Access.HasHidden() {
+			hiddenKeys[kv.Key.ID] = true
+		}
+	}
+
 	buf := bytes.Buffer{}
 	buf.WriteString(attrView.Name)
 	buf.WriteByte(' ')
 	for _, v := range attrView.Views {
+		if v.Access.HasHidden() {
+			continue
+		}
 		buf.WriteString(v.Name)
 		buf.WriteByte(' ')
 	}
@@ -577,7 +621,7 @@ func getAttributeViewContent(avID string,
 
 	var view *av.View
 	for _, v := range attrView.Views {
-		if av.LayoutTypeTable == v.LayoutType {
+		if av.LayoutTypeTable == v.LayoutType || av.LayoutTypeGallery == v.LayoutType {
 			view = v
 			break
 		}
@@ -594,13 +638,16 @@ func getAttributeViewContent(avID string,
 	}
 
 	for _, col := range table.Columns {
+		if hiddenKeys[col.ID] {
+			continue
+		}
 		buf.WriteString(col.Name)
 		buf.WriteByte(' ')
 	}
 
 	for _, row := range table.Rows {
 		for _, cell := range row.Cells {
-			if nil == cell.Value {
+			if nil == cell.Value || hiddenKeys[cell.Value.KeyID] {
 				continue
 			}
 			buf.WriteString(cell.Value.String(true))
@@ -0,0 +1,258 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+// 属性视图数据镜像到 av_rows 和 av_cells 表，用于在 SQL 查询（嵌入块、模板）中关联块和数据库列值
+
+const (
+	AttributeViewRowsPlaceholder  = "(?, ?, ?, ?, ?, ?, ?, ?)"
+	AttributeViewCellsPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+// attributeViewIndexGens 记录了还未写入数据库的属性视图索引操作，存在时说明索引已经过期，不能用于过滤。
+var (
+	attributeViewIndexGens = sync.Map{}
+	attributeViewIndexGen  atomic.Int64
+)
+
+func IndexAttributeViewQueue(avID string) {
+	if "" == avID {
+		return
+	}
+
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	gen := attributeViewIndexGen.Add(1)
+	attributeViewIndexGens.Store(avID, gen)
+	newOp := &dbQueueOperation{avID: avID, avIndexGen: gen, inQueueTime: time.Now(), action: "index_av"}
+	for i, op := range operationQueue {
+		if "index_av" == op.action && op.avID == avID {
+			operationQueue[i] = newOp
+			return
+		}
+	}
+	operationQueue = append(operationQueue, newOp)
+}
+
+// IsAttributeViewIndexFresh 判断属性视图的 av_rows 和 av_cells 索引是否和数据文件一致。
+func IsAttributeViewIndexFresh(avID string) bool {
+	_, pending := attributeViewIndexGens.Load(avID)
+	return !pending
+}
+
+func attributeViewIndexCommitted(avID string, gen int64) {
+	attributeViewIndexGens.CompareAndDelete(avID, gen)
+}
+
+// indexAttributeView 增量更新属性视图索引，仅重写内容哈希发生变化的行。
+func indexAttributeView(tx *sql.Tx, avID string) (err error) {
+	if !av.IsAttributeViewExist(avID) {
+		err = deleteAttributeView(tx, avID)
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return nil
+	}
+
+	blockValues := attrView.GetBlockKeyValues()
+	if nil == blockValues {
+		err = deleteAttributeView(tx, avID)
+		return
+	}
+
+	indexedHashes, err := queryAttributeViewRowHashes(tx, avID)
+	if nil != err {
+		return
+	}
+
+	// 按行收集单元格，计算行哈希
+	rowCells := map[string][]*attributeViewCell{}
+	for _, kv := range attrView.KeyValues {
+		for _, value := range kv.Values {
+			data, jsonErr := gulu.JSON.MarshalJSON(value)
+			if nil != jsonErr {
+				logging.LogErrorf("marshal attribute view [%s] value [%s] failed: %s", avID, value.ID, jsonErr)
+				continue
+			}
+			rowCells[value.BlockID] = append(rowCells[value.BlockID], &attributeViewCell{key: kv.Key, value: value, data: string(data)})
+		}
+	}
+
+	var changedRowIDs []string
+	var rowValueStrings []string
+	var rowValueArgs []interface{}
+	var cellValueStrings []string
+	var cellValueArgs []interface{}
+	rowIDs := map[string]bool{}
+	for _, blockValue := range blockValues.Values {
+		rowID := blockValue.BlockID
+		rowIDs[rowID] = true
+		hash := attributeViewRowHash(attrView.Name, rowCells[rowID])
+		if indexedHash, ok := indexedHashes[rowID]; ok && indexedHash == hash {
+			continue
+		}
+		changedRowIDs = append(changedRowIDs, rowID)
+
+		blockID := rowID
+		if blockValue.IsDetached {
+			blockID = ""
+		}
+		rowValueStrings = append(rowValueStrings, AttributeViewRowsPlaceholder)
+		rowValueArgs = append(rowValueArgs, rowID, avID, attrView.Name, blockID, blockValue.IsDetached,
+			time.UnixMilli(blockValue.CreatedAt).Format("20060102150405"), time.UnixMilli(blockValue.UpdatedAt).Format("20060102150405"), hash)
+		for _, cell := range rowCells[rowID] {
+			cellValueStrings = append(cellValueStrings, AttributeViewCellsPlaceholder)
+			cellValueArgs = append(cellValueArgs, cell.value.ID, avID, rowID, blockID, cell.key.ID, cell.key.Name, string(cell.key.Type),
+				cell.value.String(true), attributeViewValueNumber(cell.value), cell.data)
+		}
+	}
+	for rowID := range indexedHashes {
+		if !rowIDs[rowID] {
+			changedRowIDs = append(changedRowIDs, rowID)
+		}
+	}
+
+	if err = deleteAttributeViewRows(tx, avID, changedRowIDs); nil != err {
+		return
+	}
+
+	for i := 0; i < len(rowValueStrings); i += 512 {
+		j := min(i+512, len(rowValueStrings))
+		if err = insertAttributeViewRows(tx, rowValueStrings[i:j], rowValueArgs[i*8:j*8]); nil != err {
+			return
+		}
+	}
+	for i := 0; i < len(cellValueStrings); i += 512 {
+		j := min(i+512, len(cellValueStrings))
+		if err = insertAttributeViewCells(tx, cellValueStrings[i:j], cellValueArgs[i*10:j*10]); nil != err {
+			return
+		}
+	}
+	return
+}
+
+type attributeViewCell struct {
+	key   *av.Key
+	value *av.Value
+	data  string
+}
+
+func attributeViewRowHash(avName string, cells []*attributeViewCell) string {
+	buf := bytes.Buffer{}
+	buf.WriteString(avName)
+	for _, cell := range cells {
+		buf.WriteString(cell.key.ID)
+		buf.WriteString(cell.key.Name)
+		buf.WriteString(string(cell.key.Type))
+		buf.WriteString(cell.data)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(buf.Bytes()))[:16]
+}
+
+func attributeViewValueNumber(value *av.Value) interface{} {
+	switch value.Type {
+	case av.KeyTypeNumber:
+		if nil != value.Number && value.Number.IsNotEmpty {
+			return value.Number.Content
+		}
+	case av.KeyTypeRating, av.KeyTypeProgress, av.KeyTypeCurrency, av.KeyTypeDuration:
+		if number, ok := value.Measure(); ok {
+			return number
+		}
+	}
+	return nil
+}
+
+func queryAttributeViewRowHashes(tx *sql.Tx, avID string) (ret map[string]string, err error) {
+	ret = map[string]string{}
+	rows, err := tx.Query("SELECT id, hash FROM av_rows WHERE av_id = ?", avID)
+	if nil != err {
+		logging.LogErrorf("query attribute view [%s] rows failed: %s", avID, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, hash string
+		if err = rows.Scan(&id, &hash); nil != err {
+			logging.LogErrorf("scan attribute view [%s] rows failed: %s", avID, err)
+			return
+		}
+		ret[id] = hash
+	}
+	return
+}
+
+func deleteAttributeViewRows(tx *sql.Tx, avID string, rowIDs []string) (err error) {
+	for i := 0; i < len(rowIDs); i += 512 {
+		j := min(i+512, len(rowIDs))
+		in := "('" + strings.Join(rowIDs[i:j], "','") + "')"
+		if err = execStmtTx(tx, "DELETE FROM av_rows WHERE av_id = ? AND id IN "+in, avID); nil != err {
+			return
+		}
+		if err = execStmtTx(tx, "DELETE FROM av_cells WHERE av_id = ? AND row_id IN "+in, avID); nil != err {
+			return
+		}
+	}
+	return
+}
+
+func insertAttributeViewRows(tx *sql.Tx, valueStrings []string, valueArgs []interface{}) (err error) {
+	if 1 > len(valueStrings) {
+		return
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO av_rows (id, av_id, av_name, block_id, is_detached, created, updated, hash) VALUES %s", strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
+
+func insertAttributeViewCells(tx *sql.Tx, valueStrings []string, valueArgs []interface{}) (err error) {
+	if 1 > len(valueStrings) {
+		return
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO av_cells (id, av_id, row_id, block_id, key_id, key_name, key_type, content, number, value) VALUES %s", strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
+
+func deleteAttributeView(tx *sql.Tx, avID string) (err error) {
+	if err = execStmtTx(tx, "DELETE FROM av_rows WHERE av_id = ?", avID); nil != err {
+		return
+	}
+	err = execStmtTx(tx, "DELETE FROM av_cells WHERE av_id = ?", avID)
+	return
+}
@@ -0,0 +1,129 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"strings"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+// QueryAttributeViewRowIDs 使用 av_cells 索引预先过滤属性视图的行。
+//
+// 索引只排除确定不满足过滤条件的行，所以返回结果是最终过滤结果的超集，调用方仍需要使用 FilterRows 精确过滤。
+// 索引未更新或者过滤条件都不支持使用索引时 ok 返回 false。
+func QueryAttributeViewRowIDs(avID string, filters []*av.ViewFilter) (ret map[string]bool, ok bool) {
+	if !IsAttributeViewIndexFresh(avID) {
+		return
+	}
+
+	stmt := "SELECT id FROM av_rows WHERE av_id = ?"
+	args := []interface{}{avID}
+	for _, filter := range filters {
+		cond, condArgs := attributeViewFilterExcludeCond(filter)
+		if "" == cond {
+			continue
+		}
+
+		stmt += " AND id NOT IN (SELECT row_id FROM av_cells WHERE av_id = ? AND key_id = ? AND " + cond + ")"
+		args = append(args, avID, filter.Column)
+		args = append(args, condArgs...)
+		ok = true
+	}
+	if !ok {
+		return
+	}
+
+	rows, err := query(stmt, args...)
+	if nil != err {
+		logging.LogErrorf("query attribute view [%s] rows failed: %s", avID, err)
+		ok = false
+		return
+	}
+	defer rows.Close()
+
+	ret = map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); nil != err {
+			logging.LogErrorf("scan attribute view [%s] rows failed: %s", avID, err)
+			ret, ok = nil, false
+			return
+		}
+		ret[id] = true
+	}
+	return
+}
+
+// attributeViewFilterExcludeCond 返回确定不满足过滤条件的单元格的查询条件，不支持时返回空。
+func attributeViewFilterExcludeCond(filter *av.ViewFilter) (cond string, args []interface{}) {
+	if nil == filter || nil == filter.Value {
+		return
+	}
+
+	switch filter.Value.Type {
+	case av.KeyTypeBlock, av.KeyTypeText:
+		// 文本列的 content 去掉了首尾空白，过滤值包含首尾空白时无法保证结果正确
+		var content string
+		if nil != filter.Value.Block {
+			content = filter.Value.Block.Content
+		} else if nil != filter.Value.Text {
+			content = filter.Value.Text.Content
+		}
+		if "" == content || strings.TrimSpace(content) != content {
+			return
+		}
+
+		args = []interface{}{string(filter.Value.Type), content}
+		switch filter.Operator {
+		case av.FilterOperatorIsEqual:
+			cond = "key_type = ? AND content != ?"
+		case av.FilterOperatorContains:
+			cond = "key_type = ? AND 0 = instr(content, ?)"
+		case av.FilterOperatorStartsWith:
+			cond = "key_type = ? AND 1 != instr(content, ?)"
+		case av.FilterOperatorEndsWith:
+			cond = "key_type = ? AND substr(content, -length(?)) != ?"
+			args = append(args, content)
+		default:
+			args = nil
+		}
+	case av.KeyTypeNumber:
+		if nil == filter.Value.Number || !filter.Value.Number.IsNotEmpty {
+			return
+		}
+
+		number := filter.Value.Number.Content
+		args = []interface{}{number}
+		switch filter.Operator {
+		case av.FilterOperatorIsEqual:
+			cond = "number IS NOT NULL AND number != ?"
+		case av.FilterOperatorIsGreater:
+			cond = "number IS NOT NULL AND number <= ?"
+		case av.FilterOperatorIsGreaterOrEqual:
+			cond = "number IS NOT NULL AND number < ?"
+		case av.FilterOperatorIsLess:
+			cond = "number IS NOT NULL AND number >= ?"
+		case av.FilterOperatorIsLessOrEqual:
+			cond = "number IS NOT NULL AND number > ?"
+		default:
+			args = nil
+		}
+	}
+	return
+}
@@ -134,6 +134,7 @@ func indexNode(tx *sql.Tx, id string) (err error) {
 			return
 		}
 	}
+	removeBlockCache(id)
 	return
 }
 
@@ -198,7 +199,7 @@ func nodeStaticContent(node *ast.Node, excludeTypes []string, includeTextMarkATi
 			var linkDestStr, ocrText string
 			if nil != linkDest {
 				linkDestStr = linkDest.TokensStr()
-				ocrText = util.GetAssetText(linkDestStr, false)
+				ocrText = util.GetAssetText(linkDestStr, false, nil)
 			}
 
 			linkText := n.ChildByType(ast.NodeLinkText)
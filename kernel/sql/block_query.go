@@ -19,6 +19,7 @@ package sql
 import (
 	"bytes"
 	"database/sql"
+	"errors"
 	"math"
 	"sort"
 	"strconv"
@@ -355,6 +356,36 @@ func QueryBookmarkBlocks() (ret []*Block) {
 	return
 }
 
+// QueryTaskItemBlocks 查询任务列表项块。boxes 为空时不过滤笔记本，tag 不为空时只查询包含该标签的列表项。
+func QueryTaskItemBlocks(boxes []string, tag string, limit int) (ret []*Block) {
+	sqlStmt := "SELECT * FROM blocks WHERE type = 'i' AND subtype = 't'"
+	var args []interface{}
+	if 0 < len(boxes) {
+		sqlStmt += " AND box IN (" + strings.TrimSuffix(strings.Repeat("?,", len(boxes)), ",") + ")"
+		for _, box := range boxes {
+			args = append(args, box)
+		}
+	}
+	if "" != tag {
+		sqlStmt += " AND tag LIKE ?"
+		args = append(args, "%#"+tag+"#%")
+	}
+	sqlStmt += " ORDER BY hpath, created LIMIT ?"
+	args = append(args, limit)
+	rows, err := query(sqlStmt, args...)
+	if nil != err {
+		logging.LogErrorf("sql query [%s] failed: %s", sqlStmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if block := scanBlockRows(rows); nil != block {
+			ret = append(ret, block)
+		}
+	}
+	return
+}
+
 func QueryBookmarkLabels() (ret []string) {
 	ret = []string{}
 	sqlStmt := "SELECT * FROM blocks WHERE ial LIKE ?"
@@ -381,10 +412,33 @@ func QueryBookmarkLabels() (ret []string) {
 }
 
 func QueryNoLimit(stmt string) (ret []map[string]interface{}, err error) {
-	return queryRawStmt(stmt, math.MaxInt)
+	return queryRawStmt(stmt, math.MaxInt, query)
 }
 
+// ErrReadonlyStmt 表示在只读查询中执行了写入语句。
+var ErrReadonlyStmt = errors.New("only read-only statements are allowed")
+
+// Query 执行 SQL 语句（/api/query/sql）。语句先在只读连接池上执行，需要写入时（INSERT、UPDATE、DELETE 以及 WITH ... DELETE 等）改在写连接上执行。
 func Query(stmt string, limit int) (ret []map[string]interface{}, err error) {
+	ret, err = queryStmt(stmt, limit, query)
+	if isReadonlyErr(err) {
+		ret, err = queryStmt(stmt, limit, queryWriter)
+	}
+	return
+}
+
+// QueryReadonly 仅在只读连接池上执行 SQL 语句，写入语句返回 ErrReadonlyStmt。
+//
+// 是否只读由 SQLite 执行语句时判断，不依赖语句前缀，用于插件、带角色的 API token 等受限的调用方。
+func QueryReadonly(stmt string, limit int) (ret []map[string]interface{}, err error) {
+	ret, err = queryStmt(stmt, limit, query)
+	if isReadonlyErr(err) {
+		ret, err = nil, ErrReadonlyStmt
+	}
+	return
+}
+
+func queryStmt(stmt string, limit int, queryFunc func(string, ...interface{}) (*sql.Rows, error)) (ret []map[string]interface{}, err error) {
 	// Kernel API `/api/query/sql` support `||` operator https://github.com/siyuan-note/siyuan/issues/9662
 	// 这里为了支持 || 操作符，使用了另一个 sql 解析器，但是这个解析器无法处理 UNION https://github.com/siyuan-note/siyuan/issues/8226
 	// 考虑到 UNION 的使用场景不多，这里还是以支持 || 操作符为主
@@ -395,7 +449,7 @@ func Query(stmt string, limit int) (ret []map[string]interface{}, err error) {
 			// 这个解析器无法处理 || 连接字符串操作符
 			parsedStmt, err2 := sqlparser.Parse(stmt)
 			if nil != err2 {
-				return queryRawStmt(stmt, limit)
+				return queryRawStmt(stmt, limit, queryFunc)
 			}
 
 			switch parsedStmt.(type) {
@@ -411,10 +465,10 @@ func Query(stmt string, limit int) (ret []map[string]interface{}, err error) {
 				union.Limit = limitClause
 				stmt = sqlparser.String(union)
 			default:
-				return queryRawStmt(stmt, limit)
+				return queryRawStmt(stmt, limit, queryFunc)
 			}
 		} else {
-			return queryRawStmt(stmt, limit)
+			return queryRawStmt(stmt, limit, queryFunc)
 		}
 	} else {
 		switch parsedStmt2.(type) {
@@ -425,12 +479,12 @@ func Query(stmt string, limit int) (ret []map[string]interface{}, err error) {
 			}
 			stmt = slct.String()
 		default:
-			return queryRawStmt(stmt, limit)
+			return queryRawStmt(stmt, limit, queryFunc)
 		}
 	}
 
 	ret = []map[string]interface{}{}
-	rows, err := query(stmt)
+	rows, err := queryFunc(stmt)
 	if nil != err {
 		logging.LogWarnf("sql query [%s] failed: %s", stmt, err)
 		return
@@ -460,6 +514,7 @@ func Query(stmt string, limit int) (ret []map[string]interface{}, err error) {
 		}
 		ret = append(ret, m)
 	}
+	err = rows.Err()
 	return
 }
 
@@ -488,8 +543,8 @@ func getLimitClause(parsedStmt sqlparser.Statement, limit int) (ret *sqlparser.L
 	return
 }
 
-func queryRawStmt(stmt string, limit int) (ret []map[string]interface{}, err error) {
-	rows, err := query(stmt)
+func queryRawStmt(stmt string, limit int, queryFunc func(string, ...interface{}) (*sql.Rows, error)) (ret []map[string]interface{}, err error) {
+	rows, err := queryFunc(stmt)
 	if nil != err {
 		if strings.Contains(err.Error(), "syntax error") {
 			return
@@ -528,6 +583,7 @@ func queryRawStmt(stmt string, limit int) (ret []map[string]interface{}, err err
 			break
 		}
 	}
+	err = rows.Err()
 	return
 }
 
@@ -151,6 +151,35 @@ func QueryRootBlockRefCount() (ret map[string]int) {
 	return
 }
 
+// QueryDocRefEdges 查询文档级引用边（引用文档 -> 被引用文档），rootIDs 为空时查询全部文档。
+func QueryDocRefEdges(rootIDs []string) (ret map[string][]string) {
+	ret = map[string][]string{}
+
+	stmt := "SELECT DISTINCT root_id, def_block_root_id FROM refs WHERE root_id != def_block_root_id"
+	if 0 < len(rootIDs) {
+		var params []string
+		for _, id := range rootIDs {
+			params = append(params, "'"+id+"'")
+		}
+		stmt += " AND root_id IN (" + strings.Join(params, ",") + ")"
+	}
+	rows, err := query(stmt)
+	if nil != err {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rootID, defRootID string
+		if err = rows.Scan(&rootID, &defRootID); nil != err {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[rootID] = append(ret[rootID], defRootID)
+	}
+	return
+}
+
 func QueryDefRootBlocksByRefRootID(refRootID string) (ret []*Block) {
 	rows, err := query("SELECT * FROM blocks WHERE id IN (SELECT DISTINCT def_block_root_id FROM refs WHERE root_id = ?)", refRootID)
 	if nil != err {
@@ -405,6 +434,31 @@ func QueryRefsByDefID(defBlockID string, containChildren bool) (ret []*Ref) {
 	return
 }
 
+// QueryRefsByBlockIDs 查询引用块或者定义块在 ids 中的引用关系。
+func QueryRefsByBlockIDs(ids []string) (ret []*Ref) {
+	if 1 > len(ids) {
+		return
+	}
+
+	var params []string
+	for _, id := range ids {
+		params = append(params, "'"+id+"'")
+	}
+	in := "(" + strings.Join(params, ",") + ")"
+	rows, err := query("SELECT * FROM refs WHERE block_id IN " + in + " OR def_block_id IN " + in)
+	if nil != err {
+		logging.LogErrorf("sql query failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if ref := scanRefRows(rows); nil != ref {
+			ret = append(ret, ref)
+		}
+	}
+	return
+}
+
 func QueryRefsByDefIDRefID(defBlockID, refBlockID string) (ret []*Ref) {
 	stmt := "SELECT * FROM refs WHERE def_block_id = ? AND block_id = ?"
 	rows, err := query(stmt, defBlockID, refBlockID)
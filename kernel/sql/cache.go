@@ -17,11 +17,13 @@
 package sql
 
 import (
+	"container/list"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
-	"github.com/dgraph-io/ristretto"
 	"github.com/jinzhu/copier"
 	gcache "github.com/patrickmn/go-cache"
 	"github.com/siyuan-note/logging"
@@ -37,14 +39,72 @@ func disableCache() {
 	cacheDisabled = true
 }
 
-var blockCache, _ = ristretto.NewCache(&ristretto.Config{
-	NumCounters: 102400,
-	MaxCost:     10240,
-	BufferItems: 64,
-})
+// blockCache 为按块 ID 索引的 LRU 块缓存，按估算的内存占用淘汰最久未使用的块。
+var blockCache = &blockLRU{
+	items:  map[string]*list.Element{},
+	roots:  map[string]map[string]bool{},
+	order:  list.New(),
+	budget: 64 * 1024 * 1024,
+}
+
+type blockLRU struct {
+	lock   sync.Mutex
+	items  map[string]*list.Element   // 块 ID -> 链表节点
+	roots  map[string]map[string]bool // 文档 ID -> 块 ID 集合，用于按文档失效
+	order  *list.List                 // 链表头部为最近使用的块
+	size   int64                      // 当前估算占用的字节数
+	budget int64                      // 内存预算（字节）
+
+	hits, misses, evictions uint64
+}
+
+type blockCacheEntry struct {
+	block *Block
+	size  int64
+}
+
+// SetBlockCacheBudget 设置块缓存的内存预算（字节），超出预算的块会被立即淘汰。
+func SetBlockCacheBudget(budget int64) {
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	blockCache.budget = budget
+	blockCache.evict()
+}
+
+// CacheStat 返回块缓存的命中和未命中次数。
+func CacheStat() (hits, misses uint64) {
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	return blockCache.hits, blockCache.misses
+}
+
+// BlockCacheStat 返回块缓存的统计信息。
+func BlockCacheStat() map[string]interface{} {
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+
+	var hitRate float64
+	if total := blockCache.hits + blockCache.misses; 0 < total {
+		hitRate = float64(blockCache.hits) / float64(total)
+	}
+	return map[string]interface{}{
+		"entries":   len(blockCache.items),
+		"size":      blockCache.size,
+		"budget":    blockCache.budget,
+		"hits":      blockCache.hits,
+		"misses":    blockCache.misses,
+		"hitRate":   hitRate,
+		"evictions": blockCache.evictions,
+	}
+}
 
 func ClearCache() {
-	blockCache.Clear()
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	blockCache.items = map[string]*list.Element{}
+	blockCache.roots = map[string]map[string]bool{}
+	blockCache.order.Init()
+	blockCache.size = 0
 }
 
 func putBlockCache(block *Block) {
@@ -57,7 +117,24 @@ func putBlockCache(block *Block) {
 		logging.LogErrorf("clone block failed: %v", err)
 		return
 	}
-	blockCache.Set(cloned.ID, cloned, 1)
+
+	size := blockSize(cloned)
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	blockCache.remove(cloned.ID)
+	if size > blockCache.budget {
+		return
+	}
+
+	blockCache.items[cloned.ID] = blockCache.order.PushFront(&blockCacheEntry{block: cloned, size: size})
+	ids := blockCache.roots[cloned.RootID]
+	if nil == ids {
+		ids = map[string]bool{}
+		blockCache.roots[cloned.RootID] = ids
+	}
+	ids[cloned.ID] = true
+	blockCache.size += size
+	blockCache.evict()
 }
 
 func getBlockCache(id string) (ret *Block) {
@@ -65,18 +142,85 @@ func getBlockCache(id string) (ret *Block) {
 		return
 	}
 
-	b, _ := blockCache.Get(id)
-	if nil != b {
-		ret = b.(*Block)
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	elem := blockCache.items[id]
+	if nil == elem {
+		blockCache.misses++
+		return
 	}
+	blockCache.hits++
+	blockCache.order.MoveToFront(elem)
+	ret = elem.Value.(*blockCacheEntry).block
 	return
 }
 
 func removeBlockCache(id string) {
-	blockCache.Del(id)
+	blockCache.lock.Lock()
+	blockCache.remove(id)
+	blockCache.lock.Unlock()
 	removeRefCacheByDefID(id)
 }
 
+// removeBlockCacheByRootID 移除文档下所有块的缓存。
+func removeBlockCacheByRootID(rootID string) {
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	for id := range blockCache.roots[rootID] {
+		blockCache.remove(id)
+	}
+}
+
+// removeBlockCacheByPath 移除笔记本下路径以 pathPrefix 开头的块的缓存，pathPrefix 为空时移除整个笔记本。
+func removeBlockCacheByPath(box, pathPrefix string) {
+	blockCache.lock.Lock()
+	defer blockCache.lock.Unlock()
+	for id, elem := range blockCache.items {
+		block := elem.Value.(*blockCacheEntry).block
+		if block.Box == box && strings.HasPrefix(block.Path, pathPrefix) {
+			blockCache.remove(id)
+		}
+	}
+}
+
+func (cache *blockLRU) remove(id string) {
+	elem := cache.items[id]
+	if nil == elem {
+		return
+	}
+
+	entry := elem.Value.(*blockCacheEntry)
+	cache.order.Remove(elem)
+	delete(cache.items, id)
+	if ids := cache.roots[entry.block.RootID]; nil != ids {
+		delete(ids, id)
+		if 1 > len(ids) {
+			delete(cache.roots, entry.block.RootID)
+		}
+	}
+	cache.size -= entry.size
+}
+
+func (cache *blockLRU) evict() {
+	for cache.size > cache.budget {
+		elem := cache.order.Back()
+		if nil == elem {
+			return
+		}
+		cache.remove(elem.Value.(*blockCacheEntry).block.ID)
+		cache.evictions++
+	}
+}
+
+// blockSize 估算块在缓存中占用的字节数，包括字符串内容和结构体、索引的固定开销。
+func blockSize(block *Block) int64 {
+	ret := len(block.ID) + len(block.ParentID) + len(block.RootID) + len(block.Hash) + len(block.Box) + len(block.Path) +
+		len(block.HPath) + len(block.Name) + len(block.Alias) + len(block.Memo) + len(block.Tag) + len(block.Content) +
+		len(block.FContent) + len(block.Markdown) + len(block.Type) + len(block.SubType) + len(block.IAL) +
+		len(block.Created) + len(block.Updated)
+	return int64(ret) + 512
+}
+
 var defIDRefsCache = gcache.New(30*time.Minute, 5*time.Minute) // [defBlockID]map[refBlockID]*Ref
 
 func GetRefsCacheByDefID(defID string) (ret []*Ref) {
@@ -47,17 +47,18 @@ import (
 )
 
 var (
-	db             *sql.DB
+	db             *sql.DB // 写连接，只保留一个连接避免写入之间互相竞争
+	readDB         *sql.DB // 只读连接池，所有查询都走这里，避免和写入争抢连接
 	historyDB      *sql.DB
 	assetContentDB *sql.DB
 )
 
-func init() {
-	regex := func(re, s string) (bool, error) {
-		re = strings.ReplaceAll(re, "\\\\", "\\")
-		return regexp.MatchString(re, s)
-	}
+func regex(re, s string) (bool, error) {
+	re = strings.ReplaceAll(re, "\\\\", "\\")
+	return regexp.MatchString(re, s)
+}
 
+func init() {
 	sql.Register("sqlite3_extended", &sqlite3.SQLiteDriver{
 		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
 			return conn.RegisterFunc("regexp", regex, true)
@@ -68,6 +69,8 @@ func init() {
 var initDatabaseLock = sync.Mutex{}
 
 func InitDatabase(forceRebuild bool) (err error) {
+	defer util.StartBootStage("database", false)()
+
 	initDatabaseLock.Lock()
 	defer initDatabaseLock.Unlock()
 
@@ -101,9 +104,7 @@ func InitDatabase(forceRebuild bool) (err error) {
 			err = nil
 		}
 	}
-	if gulu.File.IsExist(util.BlockTreePath) {
-		treenode.InitBlockTree(true)
-	}
+	treenode.InitBlockTree(true)
 
 	initDBConnection()
 	initDBTables()
@@ -216,6 +217,44 @@ func initDBTables() {
 	if nil != err {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [refs] failed: %s", err)
 	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS av_rows")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [av_rows] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE av_rows (id, av_id, av_name, block_id, is_detached, created, updated, hash)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [av_rows] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_av_rows_av_id ON av_rows(av_id)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_rows_av_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS av_cells")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [av_cells] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE av_cells (id, av_id, row_id, block_id, key_id, key_name, key_type, content, number, value)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [av_cells] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_av_cells_av_id ON av_cells(av_id)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_cells_av_id] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_av_cells_block_id ON av_cells(block_id)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_cells_block_id] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_av_cells_key_content ON av_cells(av_id, key_id, content)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_cells_key_content] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE INDEX idx_av_cells_key_number ON av_cells(av_id, key_id, number)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_cells_key_number] failed: %s", err)
+	}
 }
 
 func initDBConnection() {
@@ -237,21 +276,64 @@ func initDBConnection() {
 	if nil != err {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create database failed: %s", err)
 	}
-	db.SetMaxIdleConns(20)
-	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(1)
+	db.SetMaxOpenConns(1)
 	db.SetConnMaxLifetime(365 * 24 * time.Hour)
+
+	initReadDBConnection()
+}
+
+// initReadDBConnection 打开只读连接池和带角色的查询连接池。WAL 模式下读取不会阻塞写入，query_only 保证这些连接不会意外写入。
+func initReadDBConnection() {
+	caseSensitiveLike := "OFF"
+	if caseSensitive {
+		caseSensitiveLike = "ON"
+	}
+	dsn := util.DBPath + "?_query_only=true" +
+		"&_mmap_size=2684354560" +
+		"&_cache_size=-20480" +
+		"&_busy_timeout=7000" +
+		"&_temp_store=MEMORY" +
+		"&_case_sensitive_like=" + caseSensitiveLike
+	newReadDB, err := sql.Open("sqlite3_extended", dsn)
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create read database failed: %s", err)
+	}
+	conns := max(4, runtime.NumCPU())
+	newReadDB.SetMaxIdleConns(conns)
+	newReadDB.SetMaxOpenConns(conns)
+	newReadDB.SetConnMaxLifetime(365 * 24 * time.Hour)
+
+	// 先切换再关闭旧连接池，Close 会等待正在执行的查询结束
+	oldReadDB := readDB
+	readDB = newReadDB
+	if nil != oldReadDB {
+		if err = oldReadDB.Close(); nil != err {
+			logging.LogErrorf("close read database failed: %s", err)
+		}
+	}
+
+	initRoleDBConnection()
 }
 
 var initHistoryDatabaseLock = sync.Mutex{}
 
 func InitHistoryDatabase(forceRebuild bool) {
+	defer util.StartBootStage("historyDatabase", false)()
+
 	initHistoryDatabaseLock.Lock()
 	defer initHistoryDatabaseLock.Unlock()
 
 	initHistoryDBConnection()
 
+	rebuildHistories := false
 	if !forceRebuild && gulu.File.IsExist(util.HistoryDBPath) {
-		return
+		if isHistoryDBTablesLatest() {
+			return
+		}
+
+		logging.LogInfof("history database schema is outdated, rebuilding")
+		rebuildHistories = true
 	}
 
 	historyDB.Close()
@@ -262,6 +344,19 @@ func InitHistoryDatabase(forceRebuild bool) {
 
 	initHistoryDBConnection()
 	initHistoryDBTables()
+	if rebuildHistories {
+		eventbus.Publish(util.EvtSQLHistoryRebuild)
+	}
+}
+
+// isHistoryDBTablesLatest 判断历史数据库的表结构是否是最新的。
+func isHistoryDBTablesLatest() bool {
+	rows, err := historyDB.Query("SELECT box, doc_path, hash FROM histories LIMIT 1")
+	if nil != err {
+		return false
+	}
+	rows.Close()
+	return true
 }
 
 func initHistoryDBConnection() {
@@ -295,18 +390,45 @@ func initHistoryDBTables() {
 	if nil != err {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [histories_fts_case_insensitive] failed: %s", err)
 	}
+
+	// histories 保存历史的元数据，用于按照操作类型、笔记本、路径和时间过滤，避免扫描全文索引表
+	historyDB.Exec("DROP TABLE histories")
+	_, err = historyDB.Exec("CREATE TABLE histories (id, type, op, box, title, path, doc_path, created, hash)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [histories] failed: %s", err)
+	}
+	for _, index := range []string{
+		"CREATE INDEX idx_histories_created ON histories(created)",
+		"CREATE INDEX idx_histories_op_created ON histories(op, created)",
+		"CREATE INDEX idx_histories_box_created ON histories(box, created)",
+		"CREATE INDEX idx_histories_doc_path ON histories(doc_path)",
+		"CREATE INDEX idx_histories_id ON histories(id)",
+		"CREATE INDEX idx_histories_path ON histories(path)",
+	} {
+		if _, err = historyDB.Exec(index); nil != err {
+			logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [%s] failed: %s", index, err)
+		}
+	}
 }
 
 var initAssetContentDatabaseLock = sync.Mutex{}
 
 func InitAssetContentDatabase(forceRebuild bool) {
+	defer util.StartBootStage("assetContentDatabase", false)()
+
 	initAssetContentDatabaseLock.Lock()
 	defer initAssetContentDatabaseLock.Unlock()
 
 	initAssetContentDBConnection()
 
+	rebuildContents := false
 	if !forceRebuild && gulu.File.IsExist(util.AssetContentDBPath) {
-		return
+		if isAssetContentDBTablesLatest() {
+			return
+		}
+
+		logging.LogInfof("assets database schema is outdated, rebuilding")
+		rebuildContents = true
 	}
 
 	assetContentDB.Close()
@@ -317,6 +439,25 @@ func InitAssetContentDatabase(forceRebuild bool) {
 
 	initAssetContentDBConnection()
 	initAssetContentDBTables()
+	if rebuildContents {
+		eventbus.Publish(util.EvtSQLAssetContentRebuild)
+	}
+}
+
+// isAssetContentDBTablesLatest 判断资源文件内容数据库的表结构是否是最新的。
+func isAssetContentDBTablesLatest() bool {
+	rows, err := assetContentDB.Query("SELECT page FROM asset_contents_fts_case_insensitive LIMIT 1")
+	if nil != err {
+		return false
+	}
+	rows.Close()
+
+	rows, err = assetContentDB.Query("SELECT captured FROM asset_metas LIMIT 1")
+	if nil != err {
+		return false
+	}
+	rows.Close()
+	return true
 }
 
 func initAssetContentDBConnection() {
@@ -346,10 +487,24 @@ func initAssetContentDBConnection() {
 
 func initAssetContentDBTables() {
 	assetContentDB.Exec("DROP TABLE asset_contents_fts_case_insensitive")
-	_, err := assetContentDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, tokenize=\"siyuan case_insensitive\")")
+	_, err := assetContentDB.Exec("CREATE VIRTUAL TABLE asset_contents_fts_case_insensitive USING fts5(id UNINDEXED, name, ext, path, size UNINDEXED, updated UNINDEXED, content, page UNINDEXED, tokenize=\"siyuan case_insensitive\")")
 	if nil != err {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [asset_contents_fts_case_insensitive] failed: %s", err)
 	}
+
+	assetContentDB.Exec("DROP TABLE asset_metas")
+	_, err = assetContentDB.Exec("CREATE TABLE asset_metas (path, captured, latitude, longitude, make, model, author, title)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [asset_metas] failed: %s", err)
+	}
+	_, err = assetContentDB.Exec("CREATE INDEX idx_asset_metas_path ON asset_metas(path)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_asset_metas_path] failed: %s", err)
+	}
+	_, err = assetContentDB.Exec("CREATE INDEX idx_asset_metas_captured ON asset_metas(captured)")
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_asset_metas_captured] failed: %s", err)
+	}
 }
 
 var (
@@ -364,6 +519,11 @@ func SetCaseSensitive(b bool) {
 	} else {
 		db.Exec("PRAGMA case_sensitive_like = OFF;")
 	}
+
+	// 该设置是连接级别的，重新打开只读连接池使所有查询连接生效
+	if nil != readDB {
+		initReadDBConnection()
+	}
 }
 
 func SetIndexAssetPath(b bool) {
@@ -1039,7 +1199,7 @@ func deleteBlocksByBoxTx(tx *sql.Tx, box string) (err error) {
 			return
 		}
 	}
-	ClearCache()
+	removeBlockCacheByPath(box, "")
 	return
 }
 
@@ -1158,7 +1318,7 @@ func deleteByRootID(tx *sql.Tx, rootID string, context map[string]interface{}) (
 	if err = execStmtTx(tx, stmt, rootID); nil != err {
 		return
 	}
-	ClearCache()
+	removeBlockCacheByRootID(rootID)
 	eventbus.Publish(eventbus.EvtSQLDeleteBlocks, context, rootID)
 	return
 }
@@ -1204,7 +1364,9 @@ func batchDeleteByRootIDs(tx *sql.Tx, rootIDs []string, context map[string]inter
 	if err = execStmtTx(tx, stmt); nil != err {
 		return
 	}
-	ClearCache()
+	for _, rootID := range rootIDs {
+		removeBlockCacheByRootID(rootID)
+	}
 	eventbus.Publish(eventbus.EvtSQLDeleteBlocks, context, fmt.Sprintf("%d", len(rootIDs)))
 	return
 }
@@ -1244,7 +1406,7 @@ func batchDeleteByPathPrefix(tx *sql.Tx, boxID, pathPrefix string) (err error) {
 	if err = execStmtTx(tx, stmt, boxID, pathPrefix+"%"); nil != err {
 		return
 	}
-	ClearCache()
+	removeBlockCacheByPath(boxID, pathPrefix)
 	return
 }
 
@@ -1263,7 +1425,7 @@ func batchUpdateHPath(tx *sql.Tx, rootID, newHPath string, context map[string]in
 			return
 		}
 	}
-	ClearCache()
+	removeBlockCacheByRootID(rootID)
 	evtHash := fmt.Sprintf("%x", sha256.Sum256([]byte(rootID)))[:7]
 	eventbus.Publish(eventbus.EvtSQLUpdateBlocksHPaths, context, 1, evtHash)
 	return
@@ -1281,16 +1443,42 @@ func CloseDatabase() {
 	logging.LogInfof("closed database")
 }
 
+// ConnectionStats 返回写连接和只读连接池的使用情况。
+func ConnectionStats() (write, read sql.DBStats) {
+	if nil != db {
+		write = db.Stats()
+	}
+	if r := readDB; nil != r {
+		read = r.Stats()
+	}
+	return
+}
+
 func queryRow(query string, args ...interface{}) *sql.Row {
+	defer util.ObserveMetric("siyuan_sql_query_duration_seconds", time.Now())
+
 	query = strings.TrimSpace(query)
 	if "" == query {
 		logging.LogErrorf("statement is empty")
 		return nil
 	}
-	return db.QueryRow(query, args...)
+	return readDB.QueryRow(query, args...)
 }
 
 func query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer util.ObserveMetric("siyuan_sql_query_duration_seconds", time.Now())
+
+	query = strings.TrimSpace(query)
+	if "" == query {
+		return nil, errors.New("statement is empty")
+	}
+	return readDB.Query(query, args...)
+}
+
+// queryWriter 在写连接上执行语句，仅用于 /api/query/sql 中需要写入的语句。
+func queryWriter(query string, args ...interface{}) (*sql.Rows, error) {
+	defer util.ObserveMetric("siyuan_sql_query_duration_seconds", time.Now())
+
 	query = strings.TrimSpace(query)
 	if "" == query {
 		return nil, errors.New("statement is empty")
@@ -1298,6 +1486,12 @@ func query(query string, args ...interface{}) (*sql.Rows, error) {
 	return db.Query(query, args...)
 }
 
+// isReadonlyErr 判断是否是在只读连接（_query_only）上执行写入语句导致的错误。
+func isReadonlyErr(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqlite3.ErrReadonly == sqliteErr.Code
+}
+
 func beginTx() (tx *sql.Tx, err error) {
 	if tx, err = db.Begin(); nil != err {
 		logging.LogErrorf("begin tx failed: %s\n  %s", err, logging.ShortStack())
@@ -1457,6 +1651,18 @@ func closeDatabase() (err error) {
 		return
 	}
 
+	if nil != readDB {
+		if err = readDB.Close(); nil != err {
+			logging.LogErrorf("close read database failed: %s", err)
+		}
+		readDB = nil
+	}
+	if nil != roleDB {
+		if err = roleDB.Close(); nil != err {
+			logging.LogErrorf("close role database failed: %s", err)
+		}
+		roleDB = nil
+	}
 	err = db.Close()
 	debug.FreeOSMemory()
 	runtime.GC() // 没有这句的话文件句柄不会释放，后面就无法删除文件
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/88250/lute/ast"
 	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/logging"
 )
@@ -34,6 +35,7 @@ type History struct {
 	Content string
 	Created string
 	Path    string
+	Hash    string // 文档内容哈希，用于跳过内容未变更的历史
 }
 
 func QueryHistory(stmt string) (ret []map[string]interface{}, err error) {
@@ -96,6 +98,46 @@ func scanHistoryRows(rows *sql.Rows) (ret *History) {
 	return
 }
 
+// GetLatestHistoryHash 获取指定文档最近一条历史的内容哈希。
+func GetLatestHistoryHash(id string) (ret string) {
+	row := historyDB.QueryRow("SELECT hash FROM histories WHERE id = ? AND type = 1 ORDER BY created DESC LIMIT 1", id)
+	if err := row.Scan(&ret); nil != err && sql.ErrNoRows != err {
+		logging.LogWarnf("query latest history hash [%s] failed: %s", id, err)
+	}
+	return
+}
+
+// SelectDocHistoryHashes 按照文档 ID 和生成时间顺序返回所有带哈希的文档历史，只填充 ID、Op、Path、Created 和 Hash。
+func SelectDocHistoryHashes() (ret []*History) {
+	rows, err := historyDB.Query("SELECT id, op, path, created, hash FROM histories WHERE type = 1 AND '' != hash ORDER BY id, created")
+	if nil != err {
+		logging.LogWarnf("query doc history hashes failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		history := &History{}
+		if err = rows.Scan(&history.ID, &history.Op, &history.Path, &history.Created, &history.Hash); nil != err {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret = append(ret, history)
+	}
+	return
+}
+
+func deleteHistoriesByPaths(tx *sql.Tx, paths []string, context map[string]interface{}) (err error) {
+	for _, p := range paths {
+		if err = execStmtTx(tx, "DELETE FROM histories_fts_case_insensitive WHERE path = ?", p); nil != err {
+			return
+		}
+		if err = execStmtTx(tx, "DELETE FROM histories WHERE path = ?", p); nil != err {
+			return
+		}
+	}
+	return
+}
+
 func queryHistory(query string, args ...interface{}) (*sql.Rows, error) {
 	query = strings.TrimSpace(query)
 	if "" == query {
@@ -104,9 +146,21 @@ func queryHistory(query string, args ...interface{}) (*sql.Rows, error) {
 	return historyDB.Query(query, args...)
 }
 
-func deleteOutdatedHistories(tx *sql.Tx, before string, context map[string]interface{}) (err error) {
-	stmt := "DELETE FROM histories_fts_case_insensitive WHERE created < ?"
-	if err = execStmtTx(tx, stmt, before); nil != err {
+func deleteOutdatedHistories(tx *sql.Tx, before, boxID string, excludeBoxIDs []string, context map[string]interface{}) (err error) {
+	where := " WHERE created < ?"
+	args := []interface{}{before}
+	if "" != boxID {
+		where += " AND path LIKE ?"
+		args = append(args, "%/"+boxID+"/%")
+	}
+	for _, excludeBoxID := range excludeBoxIDs {
+		where += " AND path NOT LIKE ?"
+		args = append(args, "%/"+excludeBoxID+"/%")
+	}
+	if err = execStmtTx(tx, "DELETE FROM histories_fts_case_insensitive"+where, args...); nil != err {
+		return
+	}
+	if err = execStmtTx(tx, "DELETE FROM histories"+where, args...); nil != err {
 		return
 	}
 	return
@@ -115,8 +169,24 @@ func deleteOutdatedHistories(tx *sql.Tx, before string, context map[string]inter
 const (
 	HistoriesFTSCaseInsensitiveInsert = "INSERT INTO histories_fts_case_insensitive (id, type, op, title, content, path, created) VALUES %s"
 	HistoriesPlaceholder              = "(?, ?, ?, ?, ?, ?, ?)"
+	HistoriesInsert                   = "INSERT INTO histories (id, type, op, box, title, path, doc_path, created, hash) VALUES %s"
+	HistoriesMetaPlaceholder          = "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
 )
 
+// SplitHistoryPath 将历史路径 2006-01-02-150405-update/{box}/{doc_path} 拆分为笔记本 ID 和笔记本内的文档路径，资源文件的笔记本 ID 为空。
+func SplitHistoryPath(p string) (box, docPath string) {
+	parts := strings.SplitN(p, "/", 3)
+	if 3 > len(parts) || !ast.IsNodeIDPattern(parts[1]) {
+		if 2 <= len(parts) {
+			docPath = "/" + strings.Join(parts[1:], "/")
+		}
+		return
+	}
+	box = parts[1]
+	docPath = "/" + parts[2]
+	return
+}
+
 func insertHistories(tx *sql.Tx, histories []*History, context map[string]interface{}) (err error) {
 	if 1 > len(histories) {
 		return
@@ -161,6 +231,18 @@ func insertHistories0(tx *sql.Tx, bulk []*History, context map[string]interface{
 		return
 	}
 
+	valueStrings = make([]string, 0, len(bulk))
+	valueArgs = make([]interface{}, 0, len(bulk)*strings.Count(HistoriesMetaPlaceholder, "?"))
+	for _, b := range bulk {
+		box, docPath := SplitHistoryPath(b.Path)
+		valueStrings = append(valueStrings, HistoriesMetaPlaceholder)
+		valueArgs = append(valueArgs, b.ID, b.Type, b.Op, box, b.Title, b.Path, docPath, b.Created, b.Hash)
+	}
+	stmt = fmt.Sprintf(HistoriesInsert, strings.Join(valueStrings, ","))
+	if err = prepareExecInsertTx(tx, stmt, valueArgs); nil != err {
+		return
+	}
+
 	eventbus.Publish(eventbus.EvtSQLInsertHistory, context)
 	return
 }
@@ -0,0 +1,88 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// IndexedTree 描述了已经构建好数据库行的文档，用于重建索引时批量写入。
+type IndexedTree struct {
+	tree               *parse.Tree
+	blocks             []*Block
+	spans              []*Span
+	assets             []*Asset
+	attributes         []*Attribute
+	refs               []*Ref
+	fileAnnotationRefs []*FileAnnotationRef
+}
+
+// BuildIndexedTree 构建文档的数据库行，不访问数据库，可以在多个协程中并发调用。
+func BuildIndexedTree(tree *parse.Tree) (ret *IndexedTree) {
+	ret = &IndexedTree{tree: tree}
+	ret.blocks, ret.spans, ret.assets, ret.attributes = fromTree(tree.Root, tree)
+	ret.refs, ret.fileAnnotationRefs = refsFromTree(tree)
+	return
+}
+
+// BatchIndexTrees 在一个事务中写入多个文档的数据库行（包括全文检索表），用于重建索引。
+//
+// 写入失败时回滚整个事务，并将这些文档放回数据库队列逐个重试。
+func BatchIndexTrees(trees []*IndexedTree) (err error) {
+	if 1 > len(trees) {
+		return
+	}
+
+	txLock.Lock()
+	defer txLock.Unlock()
+
+	tx, err := beginTx()
+	if nil != err {
+		return
+	}
+
+	context := map[string]interface{}{}
+	var rootIDs []string
+	for _, t := range trees {
+		if util.IsExiting.Load() {
+			tx.Rollback()
+			return
+		}
+
+		if err = insertTree0(tx, t.tree, context, t.blocks, t.spans, t.assets, t.attributes, t.refs, t.fileAnnotationRefs); nil != err {
+			tx.Rollback()
+			logging.LogErrorf("batch index trees failed, fallback to queue: %s", err)
+			for _, retry := range trees {
+				IndexTreeQueue(retry.tree)
+			}
+			return
+		}
+		rootIDs = append(rootIDs, t.tree.ID)
+	}
+
+	if err = commitTx(tx); nil != err {
+		for _, retry := range trees {
+			IndexTreeQueue(retry.tree)
+		}
+		return
+	}
+	eventbus.Publish(util.EvtSQLRefsChanged, rootIDs, false)
+	return
+}
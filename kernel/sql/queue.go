@@ -23,6 +23,7 @@ import (
 	"path"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/88250/lute/parse"
@@ -40,7 +41,7 @@ var (
 
 type dbQueueOperation struct {
 	inQueueTime                   time.Time
-	action                        string      // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets
+	action                        string      // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets/index_av
 	indexTree                     *parse.Tree // index
 	upsertTree                    *parse.Tree // upsert/update_refs/delete_refs
 	removeTreeBox, removeTreePath string      // delete
@@ -51,10 +52,60 @@ type dbQueueOperation struct {
 	block                         *Block      // update_block_content
 	id                            string      // index_node
 	removeAssetHashes             []string    // delete_assets
+	avID                          string      // index_av
+	avIndexGen                    int64       // index_av
 }
 
+var (
+	flushInterval   = atomic.Int64{} // 队列写入间隔（毫秒）
+	flushBatchSize  = atomic.Int64{} // 每次写入的最大操作数，0 表示不限制
+	lastFlushTime   = atomic.Int64{} // 上次写入的时间（毫秒）
+	flushTruncated  = atomic.Bool{}  // 上次写入是否因为超过批量大小而有剩余操作
+	lastQueueLength = atomic.Int64{} // 上次推送的队列长度
+)
+
+func init() {
+	flushInterval.Store(util.SQLFlushInterval.Milliseconds())
+}
+
+// SetFlushPolicy 设置数据库队列的写入间隔和每次写入的最大操作数（0 表示不限制）。
+func SetFlushPolicy(interval time.Duration, batchSize int) {
+	flushInterval.Store(interval.Milliseconds())
+	flushBatchSize.Store(int64(batchSize))
+}
+
+// FlushTxJob 由定时任务高频调用，达到写入间隔、队列超过批量大小或者上次写入有剩余时提交写入任务。
 func FlushTxJob() {
-	task.AppendTask(task.DatabaseIndexCommit, FlushQueue)
+	length := QueueLength()
+	pushQueueLength(length)
+	if 1 > length {
+		return
+	}
+
+	batchSize := int(flushBatchSize.Load())
+	elapsed := time.Now().UnixMilli() - lastFlushTime.Load()
+	if elapsed < flushInterval.Load() && (1 > batchSize || length < batchSize) && !flushTruncated.Load() {
+		return
+	}
+	task.AppendTask(task.DatabaseIndexCommit, flushQueueBatch)
+}
+
+// QueueStatus 返回数据库队列长度、写入策略和上次写入时间（毫秒）。
+func QueueStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"length":      QueueLength(),
+		"interval":    flushInterval.Load(),
+		"batchSize":   flushBatchSize.Load(),
+		"lastFlushed": lastFlushTime.Load(),
+	}
+}
+
+// pushQueueLength 在队列长度变化时推送，以便前端提示索引落后的操作数。
+func pushQueueLength(length int) {
+	if int64(length) == lastQueueLength.Swap(int64(length)) {
+		return
+	}
+	util.BroadcastByType("main", "databaseQueue", 0, "", map[string]interface{}{"length": length})
 }
 
 func WaitForWritingDatabase() {
@@ -74,7 +125,11 @@ func WaitForWritingDatabase() {
 }
 
 func isWritingDatabase() bool {
-	time.Sleep(util.SQLFlushInterval + 50*time.Millisecond)
+	interval := time.Duration(flushInterval.Load()) * time.Millisecond
+	if util.SQLFlushInterval < interval {
+		interval = util.SQLFlushInterval
+	}
+	time.Sleep(interval + 50*time.Millisecond)
 	dbQueueLock.Lock()
 	defer dbQueueLock.Unlock()
 	if 0 < len(operationQueue) {
@@ -89,14 +144,30 @@ func IsEmptyQueue() bool {
 	return 1 > len(operationQueue)
 }
 
+// QueueLength 返回数据库队列中待执行的操作数。
+func QueueLength() int {
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+	return len(operationQueue)
+}
+
 func ClearQueue() {
 	dbQueueLock.Lock()
 	defer dbQueueLock.Unlock()
 	operationQueue = nil
 }
 
+// FlushQueue 立即写入队列中的全部操作。
 func FlushQueue() {
-	ops := getOperations()
+	flushOperations(getOperations(0))
+}
+
+func flushQueueBatch() {
+	flushOperations(getOperations(int(flushBatchSize.Load())))
+}
+
+func flushOperations(ops []*dbQueueOperation) {
+	lastFlushTime.Store(time.Now().UnixMilli())
 	total := len(ops)
 	if 1 > total {
 		return
@@ -118,6 +189,8 @@ func FlushQueue() {
 		groupOpsTotal[op.action]++
 	}
 
+	refsChangedRootIDs := map[string]bool{}
+	refsChangedAll := false
 	groupOpsCurrent := map[string]int{}
 	for i, op := range ops {
 		if util.IsExiting.Load() {
@@ -142,6 +215,12 @@ func FlushQueue() {
 			logging.LogErrorf("commit tx failed: %s", err)
 			continue
 		}
+		if "index_av" == op.action {
+			attributeViewIndexCommitted(op.avID, op.avIndexGen)
+		}
+		if collectRefsChanged(op, refsChangedRootIDs) {
+			refsChangedAll = true
+		}
 
 		if 16 < i && 0 == i%128 {
 			debug.FreeOSMemory()
@@ -156,9 +235,42 @@ func FlushQueue() {
 	if 7000 < elapsed {
 		logging.LogInfof("database op tx [%dms]", elapsed)
 	}
+	util.ObserveMetric("siyuan_sql_queue_flush_duration_seconds", start)
+
+	if refsChangedAll || 0 < len(refsChangedRootIDs) {
+		var rootIDs []string
+		if !refsChangedAll {
+			for rootID := range refsChangedRootIDs {
+				rootIDs = append(rootIDs, rootID)
+			}
+		}
+		eventbus.Publish(util.EvtSQLRefsChanged, rootIDs, refsChangedAll)
+	}
 
 	// Push database index commit event https://github.com/siyuan-note/siyuan/issues/8814
 	util.BroadcastByType("main", "databaseIndexCommit", 0, "", nil)
+	pushQueueLength(QueueLength())
+}
+
+// collectRefsChanged 收集引用关系可能发生变化的文档，无法确定具体文档时标记为全部变化。
+func collectRefsChanged(op *dbQueueOperation, rootIDs map[string]bool) (all bool) {
+	switch op.action {
+	case "index":
+		rootIDs[op.indexTree.ID] = true
+	case "upsert", "update_refs", "delete_refs":
+		rootIDs[op.upsertTree.ID] = true
+	case "rename", "rename_sub_tree":
+		rootIDs[op.renameTree.ID] = true
+	case "delete_id":
+		rootIDs[op.removeTreeID] = true
+	case "delete_ids":
+		for _, rootID := range op.removeTreeIDs {
+			rootIDs[rootID] = true
+		}
+	case "delete", "delete_box", "delete_box_refs":
+		all = true
+	}
+	return
 }
 
 func execOp(op *dbQueueOperation, tx *sql.Tx, context map[string]interface{}) (err error) {
@@ -195,6 +307,8 @@ func execOp(op *dbQueueOperation, tx *sql.Tx, context map[string]interface{}) (e
 		err = deleteAssetsByHashes(tx, op.removeAssetHashes)
 	case "index_node":
 		err = indexNode(tx, op.id)
+	case "index_av":
+		err = indexAttributeView(tx, op.avID)
 	default:
 		msg := fmt.Sprintf("unknown operation [%s]", op.action)
 		logging.LogErrorf(msg)
@@ -403,11 +517,20 @@ func RemoveTreePathQueue(treeBox, treePathPrefix string) {
 	operationQueue = append(operationQueue, newOp)
 }
 
-func getOperations() (ops []*dbQueueOperation) {
+// getOperations 取出队列中最多 limit 个操作（0 表示全部），剩余的操作留到下次写入。
+func getOperations(limit int) (ops []*dbQueueOperation) {
 	dbQueueLock.Lock()
 	defer dbQueueLock.Unlock()
 
+	if 0 < limit && limit < len(operationQueue) {
+		ops = operationQueue[:limit:limit]
+		operationQueue = append([]*dbQueueOperation{}, operationQueue[limit:]...)
+		flushTruncated.Store(true)
+		return
+	}
+
 	ops = operationQueue
 	operationQueue = nil
+	flushTruncated.Store(false)
 	return
 }
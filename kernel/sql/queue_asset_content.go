@@ -38,9 +38,10 @@ var (
 
 type assetContentDBQueueOperation struct {
 	inQueueTime time.Time
-	action      string // index/deletePath
+	action      string // index/indexMeta/deletePath
 
 	assetContents []*AssetContent // index
+	assetMetas    []*AssetMeta    // indexMeta
 	path          string          // deletePath
 }
 
@@ -111,8 +112,13 @@ func execAssetContentOp(op *assetContentDBQueueOperation, tx *sql.Tx, context ma
 	switch op.action {
 	case "index":
 		err = insertAssetContents(tx, op.assetContents, context)
+	case "indexMeta":
+		err = insertAssetMetas(tx, op.assetMetas)
 	case "deletePath":
-		err = deleteAssetContentsByPath(tx, op.path, context)
+		if err = deleteAssetContentsByPath(tx, op.path, context); nil != err {
+			return
+		}
+		err = deleteAssetMetasByPath(tx, op.path)
 	default:
 		msg := fmt.Sprintf("unknown asset content operation [%s]", op.action)
 		logging.LogErrorf(msg)
@@ -137,6 +143,18 @@ func IndexAssetContentsQueue(assetContents []*AssetContent) {
 	assetContentOperationQueue = append(assetContentOperationQueue, newOp)
 }
 
+func IndexAssetMetasQueue(assetMetas []*AssetMeta) {
+	if 1 > len(assetMetas) {
+		return
+	}
+
+	assetContentDBQueueLock.Lock()
+	defer assetContentDBQueueLock.Unlock()
+
+	newOp := &assetContentDBQueueOperation{inQueueTime: time.Now(), action: "indexMeta", assetMetas: assetMetas}
+	assetContentOperationQueue = append(assetContentOperationQueue, newOp)
+}
+
 func getAssetContentOperations() (ops []*assetContentDBQueueOperation) {
 	assetContentDBQueueLock.Lock()
 	defer assetContentDBQueueLock.Unlock()
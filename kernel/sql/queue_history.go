@@ -38,10 +38,13 @@ var (
 
 type historyDBQueueOperation struct {
 	inQueueTime time.Time
-	action      string // index/deleteOutdated
+	action      string // index/deleteOutdated/deletePaths
 
-	histories []*History // index
-	before    string     // deleteOutdated
+	histories     []*History // index
+	before        string     // deleteOutdated
+	boxID         string     // deleteOutdated，不为空时仅删除该笔记本的历史
+	excludeBoxIDs []string   // deleteOutdated，不删除这些笔记本的历史
+	paths         []string   // deletePaths
 }
 
 func FlushHistoryTxJob() {
@@ -112,7 +115,9 @@ func execHistoryOp(op *historyDBQueueOperation, tx *sql.Tx, context map[string]i
 	case "index":
 		err = insertHistories(tx, op.histories, context)
 	case "deleteOutdated":
-		err = deleteOutdatedHistories(tx, op.before, context)
+		err = deleteOutdatedHistories(tx, op.before, op.boxID, op.excludeBoxIDs, context)
+	case "deletePaths":
+		err = deleteHistoriesByPaths(tx, op.paths, context)
 	default:
 		msg := fmt.Sprintf("unknown history operation [%s]", op.action)
 		logging.LogErrorf(msg)
@@ -121,11 +126,29 @@ func execHistoryOp(op *historyDBQueueOperation, tx *sql.Tx, context map[string]i
 	return
 }
 
-func DeleteOutdatedHistories(before string) {
+func DeleteOutdatedHistories(before string, excludeBoxIDs []string) {
 	historyDBQueueLock.Lock()
 	defer historyDBQueueLock.Unlock()
 
-	newOp := &historyDBQueueOperation{inQueueTime: time.Now(), action: "deleteOutdated", before: before}
+	newOp := &historyDBQueueOperation{inQueueTime: time.Now(), action: "deleteOutdated", before: before, excludeBoxIDs: excludeBoxIDs}
+	historyOperationQueue = append(historyOperationQueue, newOp)
+}
+
+// DeleteOutdatedBoxHistories 删除指定笔记本中早于 before 的历史索引。
+func DeleteOutdatedBoxHistories(boxID, before string) {
+	historyDBQueueLock.Lock()
+	defer historyDBQueueLock.Unlock()
+
+	newOp := &historyDBQueueOperation{inQueueTime: time.Now(), action: "deleteOutdated", before: before, boxID: boxID}
+	historyOperationQueue = append(historyOperationQueue, newOp)
+}
+
+// DeleteHistoriesByPathsQueue 删除指定路径的历史索引。
+func DeleteHistoriesByPathsQueue(paths []string) {
+	historyDBQueueLock.Lock()
+	defer historyDBQueueLock.Unlock()
+
+	newOp := &historyDBQueueOperation{inQueueTime: time.Now(), action: "deletePaths", paths: paths}
 	historyOperationQueue = append(historyOperationQueue, newOp)
 }
 
@@ -0,0 +1,177 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// roleDB 是带角色的调用方执行 /api/query/sql 的连接池。
+//
+// 不通过改写语句限制访问（语句中的注释、引号等都可以绕过文本匹配），而是在连接上注册 authorizer，由 SQLite 在编译语句时检查实际访问的表和列。
+var roleDB *sql.DB
+
+// rolePolicies 记录了 roleDB 中每个连接的访问策略。
+var rolePolicies = sync.Map{}
+
+// rolePolicy 是连接的访问策略，连接同一时间只会被一个查询使用，所以不需要加锁。
+type rolePolicy struct {
+	enabled     bool // 仅在执行调用方的语句时启用，准备临时表时不受限制
+	hideAvCells bool // 存在隐藏列时只能查询过滤后的临时表 temp.av_cells，不能查询 main.av_cells
+}
+
+// sqliteRecursive 即 SQLITE_RECURSIVE，go-sqlite3 没有导出该常量。
+const sqliteRecursive = 33
+
+func init() {
+	sql.Register("sqlite3_role", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("regexp", regex, true); nil != err {
+				return err
+			}
+
+			policy := &rolePolicy{}
+			rolePolicies.Store(conn, policy)
+			conn.RegisterAuthorizer(policy.authorize)
+			return nil
+		},
+	})
+}
+
+// authorize 仅允许查询，拒绝写入、ATTACH、PRAGMA、事务等其他操作。
+//
+// 存在隐藏列时拒绝读取 main.av_cells。SQLite 传入的是解析后的 schema 和表名，所以不管语句中如何书写（比如 main/**/.av_cells），都会被拒绝。
+func (policy *rolePolicy) authorize(op int, table, column, schema string) int {
+	if !policy.enabled {
+		return sqlite3.SQLITE_OK
+	}
+
+	switch op {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	case sqlite3.SQLITE_READ:
+		if policy.hideAvCells && "main" == schema && "av_cells" == table {
+			return sqlite3.SQLITE_DENY
+		}
+		return sqlite3.SQLITE_OK
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+// initRoleDBConnection 打开带角色的查询连接池。连接不能设置 query_only，否则无法创建临时表，写入由 authorizer 拒绝。
+func initRoleDBConnection() {
+	caseSensitiveLike := "OFF"
+	if caseSensitive {
+		caseSensitiveLike = "ON"
+	}
+	dsn := util.DBPath + "?_mmap_size=2684354560" +
+		"&_cache_size=-20480" +
+		"&_busy_timeout=7000" +
+		"&_temp_store=MEMORY" +
+		"&_case_sensitive_like=" + caseSensitiveLike
+	newRoleDB, err := openRoleDB(dsn)
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create role database failed: %s", err)
+	}
+
+	oldRoleDB := roleDB
+	roleDB = newRoleDB
+	if nil != oldRoleDB {
+		if err = oldRoleDB.Close(); nil != err {
+			logging.LogErrorf("close role database failed: %s", err)
+		}
+	}
+}
+
+func openRoleDB(dsn string) (ret *sql.DB, err error) {
+	if ret, err = sql.Open("sqlite3_role", dsn); nil != err {
+		return
+	}
+	ret.SetMaxIdleConns(2)
+	ret.SetMaxOpenConns(2)
+	ret.SetConnMaxLifetime(365 * 24 * time.Hour)
+	return
+}
+
+// QueryRole 执行带角色的调用方的 SQL 语句，仅允许查询，并且查询不到 hiddenKeyIDs 列的单元格。
+func QueryRole(stmt string, limit int, hiddenKeyIDs []string) (ret []map[string]interface{}, err error) {
+	return queryRole(roleDB, stmt, limit, hiddenKeyIDs)
+}
+
+func queryRole(pool *sql.DB, stmt string, limit int, hiddenKeyIDs []string) (ret []map[string]interface{}, err error) {
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if nil != err {
+		return
+	}
+	defer conn.Close()
+
+	var policy *rolePolicy
+	if err = conn.Raw(func(driverConn interface{}) error {
+		if p, ok := rolePolicies.Load(driverConn); ok {
+			policy = p.(*rolePolicy)
+			return nil
+		}
+		return errors.New("role policy not found")
+	}); nil != err {
+		return
+	}
+
+	// 调用方的语句中不限定 schema 的 av_cells 会优先匹配临时表
+	if _, err = conn.ExecContext(ctx, "DROP TABLE IF EXISTS temp.av_cells"); nil != err {
+		return
+	}
+	if 0 < len(hiddenKeyIDs) {
+		args := make([]interface{}, len(hiddenKeyIDs))
+		for i, id := range hiddenKeyIDs {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+		if _, err = conn.ExecContext(ctx, "CREATE TEMP TABLE av_cells AS SELECT * FROM main.av_cells WHERE key_id NOT IN ("+placeholders+")", args...); nil != err {
+			return
+		}
+	}
+
+	policy.hideAvCells = 0 < len(hiddenKeyIDs)
+	policy.enabled = true
+	defer func() { policy.enabled = false }()
+
+	ret, err = queryStmt(stmt, limit, func(query string, args ...interface{}) (*sql.Rows, error) {
+		defer util.ObserveMetric("siyuan_sql_query_duration_seconds", time.Now())
+		return conn.QueryContext(ctx, query, args...)
+	})
+	if isAuthErr(err) {
+		ret, err = nil, av.ErrAccessDenied
+	}
+	return
+}
+
+// isAuthErr 判断是否是被 authorizer 拒绝导致的错误。
+func isAuthErr(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqlite3.ErrAuth == sqliteErr.Code
+}
@@ -0,0 +1,79 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+func TestQueryRole(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "siyuan.db")
+	writer, err := sql.Open("sqlite3_extended", dbPath)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE av_cells (id, av_id, row_id, block_id, key_id, key_name, key_type, content, number, value)",
+		"INSERT INTO av_cells (id, key_id, content) VALUES ('1', 'visible', 'foo'), ('2', 'hidden', 'secret')",
+	} {
+		if _, err = writer.Exec(stmt); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	pool, err := openRoleDB(dbPath)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	hiddenKeyIDs := []string{"hidden"}
+	ret, err := queryRole(pool, "SELECT key_id, content FROM av_cells", 64, hiddenKeyIDs)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(ret) || "visible" != ret[0]["key_id"] {
+		t.Fatalf("unexpected result %v", ret)
+	}
+
+	for _, stmt := range []string{
+		"SELECT * FROM main.av_cells",
+		"SELECT * FROM main/**/.av_cells",
+		"SELECT * FROM \"main\" . \"av_cells\"",
+		"SELECT count(*) FROM main.av_cells",
+		"WITH c AS (SELECT * FROM main/**/.av_cells) SELECT * FROM c",
+		"SELECT 1; DELETE FROM av_cells",
+		"DELETE FROM main.av_cells",
+		"ATTACH DATABASE '" + dbPath + "' AS other",
+		"PRAGMA query_only = OFF",
+	} {
+		if ret, err = queryRole(pool, stmt, 64, hiddenKeyIDs); !errors.Is(err, av.ErrAccessDenied) {
+			t.Fatalf("statement [%s] should be denied, got %v %v", stmt, ret, err)
+		}
+	}
+
+	// 没有隐藏列时可以直接查询 main.av_cells
+	if ret, err = queryRole(pool, "SELECT * FROM main/**/.av_cells", 64, nil); nil != err || 2 != len(ret) {
+		t.Fatalf("unexpected result %v %v", ret, err)
+	}
+}
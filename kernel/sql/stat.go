@@ -32,7 +32,7 @@ type Stat struct {
 func getDatabaseVer() (ret string) {
 	key := "siyuan_database_ver"
 	stmt := "SELECT value FROM stat WHERE `key` = ?"
-	row := db.QueryRow(stmt, key)
+	row := queryRow(stmt, key)
 	if err := row.Scan(&ret); nil != err {
 		if !strings.Contains(err.Error(), "no such table") {
 			logging.LogErrorf("query database version failed: %s", err)
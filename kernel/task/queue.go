@@ -67,6 +67,25 @@ func AppendTaskWithTimeout(action string, timeout time.Duration, handler interfa
 	})
 }
 
+// QueueLength 返回任务队列中等待执行的任务数。
+func QueueLength() int {
+	queueLock.Lock()
+	defer queueLock.Unlock()
+	return len(taskQueue)
+}
+
+// WaitForQueue 等待队列中的任务全部执行完成，用于命令行模式退出前等待后台任务。
+func WaitForQueue() {
+	for idle := 0; 4 > idle; {
+		time.Sleep(50 * time.Millisecond)
+		if 0 < len(getCurrentActions()) {
+			idle = 0
+			continue
+		}
+		idle++
+	}
+}
+
 func getCurrentActions() (ret []string) {
 	queueLock.Lock()
 
@@ -95,6 +114,9 @@ const (
 	HistoryGenerateFile             = "task.history.generateFile"          // 生成文件历史
 	HistoryDatabaseIndexFull        = "task.history.database.index.full"   // 历史数据库重建索引
 	HistoryDatabaseIndexCommit      = "task.history.database.index.commit" // 历史数据库索引提交
+	HistoryCompress                 = "task.history.compress"              // 历史文件压缩迁移
+	SyCompress                      = "task.sy.compress"                   // 文档文件压缩迁移
+	HistoryCompact                  = "task.history.compact"               // 历史去重压实
 	DatabaseIndexEmbedBlock         = "task.database.index.embedBlock"     // 数据库索引嵌入块
 	ReloadUI                        = "task.reload.ui"                     // 重载 UI
 	AssetContentDatabaseIndexFull   = "task.asset.database.index.full"     // 资源文件数据库重建索引
@@ -111,6 +133,9 @@ var uniqueActions = []string{
 	HistoryGenerateFile,
 	HistoryDatabaseIndexFull,
 	HistoryDatabaseIndexCommit,
+	HistoryCompress,
+	SyCompress,
+	HistoryCompact,
 	AssetContentDatabaseIndexFull,
 	AssetContentDatabaseIndexCommit,
 }
@@ -17,6 +17,8 @@
 package treenode
 
 import (
+	"container/list"
+	"database/sql"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,21 +30,19 @@ import (
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
-	"github.com/panjf2000/ants/v2"
-	util2 "github.com/siyuan-note/dejavu/util"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/task"
 	"github.com/siyuan-note/siyuan/kernel/util"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
-var blockTrees = &sync.Map{}
-
-type btSlice struct {
-	data    map[string]*BlockTree
-	changed time.Time
-	m       *sync.Mutex
-}
+// 区块树保存在磁盘上的 SQLite 数据库中，内存中仅保留一个小的热点缓存，这样工作空间再大内核常驻内存也能保持平稳。
+var (
+	blockTreeDB      *sql.DB
+	blockTreeDBLock  = sync.Mutex{}
+	blockTreeChanged = atomic.Bool{}
+)
 
 type BlockTree struct {
 	ID       string // 块 ID
@@ -56,130 +56,41 @@ type BlockTree struct {
 }
 
 func GetBlockTreesByType(typ string) (ret []*BlockTree) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.Type == typ {
-				ret = append(ret, b)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-	return
+	return queryBlockTrees("SELECT "+blockTreeColumns+" FROM blocktrees WHERE type = ?", typ)
 }
 
 func GetBlockTreeByPath(path string) (ret *BlockTree) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.Path == path {
-				ret = b
-				break
-			}
-		}
-		slice.m.Unlock()
-		return nil == ret
-	})
-	return
+	return queryBlockTree("SELECT "+blockTreeColumns+" FROM blocktrees WHERE path = ? LIMIT 1", path)
 }
 
 func CountTrees() (ret int) {
-	roots := map[string]bool{}
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			roots[b.RootID] = true
-		}
-		slice.m.Unlock()
-		return true
-	})
-	ret = len(roots)
-	return
+	return countBlockTrees("SELECT COUNT(DISTINCT root_id) FROM blocktrees")
 }
 
 func CountBlocks() (ret int) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		ret += len(slice.data)
-		slice.m.Unlock()
-		return true
-	})
-	return
+	return countBlockTrees("SELECT COUNT(*) FROM blocktrees")
 }
 
 func GetBlockTreeRootByPath(boxID, path string) (ret *BlockTree) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID && b.Path == path && b.RootID == b.ID {
-				ret = b
-				break
-			}
-		}
-		slice.m.Unlock()
-		return nil == ret
-	})
-	return
+	return queryBlockTree("SELECT "+blockTreeColumns+" FROM blocktrees WHERE box_id = ? AND path = ? AND id = root_id LIMIT 1", boxID, path)
 }
 
 func GetBlockTreeRootByHPath(boxID, hPath string) (ret *BlockTree) {
 	hPath = gulu.Str.RemoveInvisible(hPath)
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID && b.HPath == hPath && b.RootID == b.ID {
-				ret = b
-				break
-			}
-		}
-		slice.m.Unlock()
-		return nil == ret
-	})
-	return
+	return queryBlockTree("SELECT "+blockTreeColumns+" FROM blocktrees WHERE box_id = ? AND hpath = ? AND id = root_id LIMIT 1", boxID, hPath)
 }
 
 func GetBlockTreeRootsByHPath(boxID, hPath string) (ret []*BlockTree) {
 	hPath = gulu.Str.RemoveInvisible(hPath)
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID && b.HPath == hPath && b.RootID == b.ID {
-				ret = append(ret, b)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-	return
+	return queryBlockTrees("SELECT "+blockTreeColumns+" FROM blocktrees WHERE box_id = ? AND hpath = ? AND id = root_id", boxID, hPath)
 }
 
 func GetBlockTreeRootByHPathPreferredParentID(boxID, hPath, preferredParentID string) (ret *BlockTree) {
-	hPath = gulu.Str.RemoveInvisible(hPath)
-	var roots []*BlockTree
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID && b.HPath == hPath && b.RootID == b.ID {
-				if "" == preferredParentID {
-					ret = b
-					break
-				}
-
-				roots = append(roots, b)
-			}
-		}
-		slice.m.Unlock()
-		return nil == ret
-	})
+	if "" == preferredParentID {
+		return GetBlockTreeRootByHPath(boxID, hPath)
+	}
+
+	roots := GetBlockTreeRootsByHPath(boxID, hPath)
 	if 1 > len(roots) {
 		return
 	}
@@ -195,16 +106,7 @@ func GetBlockTreeRootByHPathPreferredParentID(boxID, hPath, preferredParentID st
 }
 
 func ExistBlockTree(id string) bool {
-	hash := btHash(id)
-	val, ok := blockTrees.Load(hash)
-	if !ok {
-		return false
-	}
-	slice := val.(*btSlice)
-	slice.m.Lock()
-	_, ok = slice.data[id]
-	slice.m.Unlock()
-	return ok
+	return nil != GetBlockTree(id)
 }
 
 func GetBlockTree(id string) (ret *BlockTree) {
@@ -212,15 +114,14 @@ func GetBlockTree(id string) (ret *BlockTree) {
 		return
 	}
 
-	hash := btHash(id)
-	val, ok := blockTrees.Load(hash)
-	if !ok {
+	if ret = btCache.get(id); nil != ret {
 		return
 	}
-	slice := val.(*btSlice)
-	slice.m.Lock()
-	ret = slice.data[id]
-	slice.m.Unlock()
+
+	ret = queryBlockTree("SELECT "+blockTreeColumns+" FROM blocktrees WHERE id = ?", id)
+	if nil != ret {
+		btCache.put(ret)
+	}
 	return
 }
 
@@ -230,147 +131,47 @@ func SetBlockTreePath(tree *parse.Tree) {
 }
 
 func RemoveBlockTreesByRootID(rootID string) {
-	var ids []string
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.RootID == rootID {
-				ids = append(ids, b.ID)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-
-	ids = gulu.Str.RemoveDuplicatedElem(ids)
-	for _, id := range ids {
-		val, ok := blockTrees.Load(btHash(id))
-		if !ok {
-			continue
-		}
-		slice := val.(*btSlice)
-		slice.m.Lock()
-		delete(slice.data, id)
-		slice.changed = time.Now()
-		slice.m.Unlock()
-	}
+	execBlockTree("DELETE FROM blocktrees WHERE root_id = ?", rootID)
+	btCache.removeIf(func(bt *BlockTree) bool { return bt.RootID == rootID })
 }
 
 func GetBlockTreesByPathPrefix(pathPrefix string) (ret []*BlockTree) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if strings.HasPrefix(b.Path, pathPrefix) {
-				ret = append(ret, b)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-	return
+	return queryBlockTrees("SELECT "+blockTreeColumns+" FROM blocktrees WHERE path LIKE ? ESCAPE '\\'", likePrefix(pathPrefix))
 }
 
 func GetBlockTreesByRootID(rootID string) (ret []*BlockTree) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.RootID == rootID {
-				ret = append(ret, b)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-	return
+	return queryBlockTrees("SELECT "+blockTreeColumns+" FROM blocktrees WHERE root_id = ?", rootID)
 }
 
 func RemoveBlockTreesByPathPrefix(pathPrefix string) {
-	var ids []string
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if strings.HasPrefix(b.Path, pathPrefix) {
-				ids = append(ids, b.ID)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-
-	ids = gulu.Str.RemoveDuplicatedElem(ids)
-	for _, id := range ids {
-		val, ok := blockTrees.Load(btHash(id))
-		if !ok {
-			continue
-		}
-		slice := val.(*btSlice)
-		slice.m.Lock()
-		delete(slice.data, id)
-		slice.changed = time.Now()
-		slice.m.Unlock()
-	}
+	execBlockTree("DELETE FROM blocktrees WHERE path LIKE ? ESCAPE '\\'", likePrefix(pathPrefix))
+	btCache.removeIf(func(bt *BlockTree) bool { return strings.HasPrefix(bt.Path, pathPrefix) })
 }
 
 func GetBlockTreesByBoxID(boxID string) (ret []*BlockTree) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID {
-				ret = append(ret, b)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-	return
+	return queryBlockTrees("SELECT "+blockTreeColumns+" FROM blocktrees WHERE box_id = ?", boxID)
 }
 
 func RemoveBlockTreesByBoxID(boxID string) (ids []string) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID {
-				ids = append(ids, b.ID)
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-
-	ids = gulu.Str.RemoveDuplicatedElem(ids)
-	for _, id := range ids {
-		val, ok := blockTrees.Load(btHash(id))
-		if !ok {
-			continue
-		}
-		slice := val.(*btSlice)
-		slice.m.Lock()
-		delete(slice.data, id)
-		slice.changed = time.Now()
-		slice.m.Unlock()
+	for _, bt := range GetBlockTreesByBoxID(boxID) {
+		ids = append(ids, bt.ID)
 	}
+	execBlockTree("DELETE FROM blocktrees WHERE box_id = ?", boxID)
+	btCache.removeIf(func(bt *BlockTree) bool { return bt.BoxID == boxID })
 	return
 }
 
 func RemoveBlockTree(id string) {
-	val, ok := blockTrees.Load(btHash(id))
-	if !ok {
-		return
-	}
-	slice := val.(*btSlice)
-	slice.m.Lock()
-	delete(slice.data, id)
-	slice.changed = time.Now()
-	slice.m.Unlock()
+	execBlockTree("DELETE FROM blocktrees WHERE id = ?", id)
+	btCache.remove(id)
 }
 
 func IndexBlockTree(tree *parse.Tree) {
+	existing := map[string]*BlockTree{}
+	for _, bt := range GetBlockTreesByRootID(tree.ID) {
+		existing[bt.ID] = bt
+	}
+
 	var changedNodes []*ast.Node
 	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
 		if !entering || !n.IsBlock() {
@@ -380,18 +181,7 @@ func IndexBlockTree(tree *parse.Tree) {
 			return ast.WalkContinue
 		}
 
-		hash := btHash(n.ID)
-		val, ok := blockTrees.Load(hash)
-		if !ok {
-			val = &btSlice{data: map[string]*BlockTree{}, changed: time.Time{}, m: &sync.Mutex{}}
-			blockTrees.Store(hash, val)
-		}
-		slice := val.(*btSlice)
-
-		slice.m.Lock()
-		bt := slice.data[n.ID]
-		slice.m.Unlock()
-
+		bt := existing[n.ID]
 		if nil != bt {
 			if bt.Updated != n.IALAttr("updated") || bt.Type != TypeAbbr(n.Type.String()) || bt.Path != tree.Path || bt.BoxID != tree.Box || bt.HPath != tree.HPath {
 				children := ChildBlockNodes(n) // 需要考虑子块，因为一些操作（比如移动块）后需要同时更新子块
@@ -403,44 +193,44 @@ func IndexBlockTree(tree *parse.Tree) {
 		}
 		return ast.WalkContinue
 	})
-
-	for _, n := range changedNodes {
-		updateBtSlice(n, tree)
+	if 1 > len(changedNodes) {
+		return
 	}
-}
 
-func updateBtSlice(n *ast.Node, tree *parse.Tree) {
-	var parentID string
-	if nil != n.Parent {
-		parentID = n.Parent.ID
+	var bts []*BlockTree
+	for _, n := range changedNodes {
+		var parentID string
+		if nil != n.Parent {
+			parentID = n.Parent.ID
+		}
+		bts = append(bts, &BlockTree{ID: n.ID, ParentID: parentID, RootID: tree.ID, BoxID: tree.Box, Path: tree.Path, HPath: tree.HPath, Updated: n.IALAttr("updated"), Type: TypeAbbr(n.Type.String())})
 	}
-
-	hash := btHash(n.ID)
-	val, ok := blockTrees.Load(hash)
-	if !ok {
-		val = &btSlice{data: map[string]*BlockTree{}, changed: time.Time{}, m: &sync.Mutex{}}
-		blockTrees.Store(hash, val)
+	if err := upsertBlockTrees(bts); nil != err {
+		logging.LogErrorf("index block tree [%s] failed: %s", tree.ID, err)
 	}
-	slice := val.(*btSlice)
-	slice.m.Lock()
-	slice.data[n.ID] = &BlockTree{ID: n.ID, ParentID: parentID, RootID: tree.ID, BoxID: tree.Box, Path: tree.Path, HPath: tree.HPath, Updated: n.IALAttr("updated"), Type: TypeAbbr(n.Type.String())}
-	slice.changed = time.Now()
-	slice.m.Unlock()
 }
 
 var blockTreeLock = sync.Mutex{}
 
+// InitBlockTree 初始化区块树，force 为 true 时清空区块树。
+//
+// 旧版本的区块树保存在 util.BlockTreePath 下的 msgpack 文件中，存在时导入数据库后删除。
 func InitBlockTree(force bool) {
 	blockTreeLock.Lock()
 	defer blockTreeLock.Unlock()
 
 	start := time.Now()
 	if force {
-		err := os.RemoveAll(util.BlockTreePath)
-		if nil != err {
+		if err := os.RemoveAll(util.BlockTreePath); nil != err {
 			logging.LogErrorf("remove block tree file failed: %s", err)
 		}
-		blockTrees = &sync.Map{}
+		execBlockTree("DELETE FROM blocktrees")
+		btCache.clear()
+		return
+	}
+
+	if !gulu.File.IsExist(util.BlockTreePath) {
+		getBlockTreeDB()
 		return
 	}
 
@@ -451,66 +241,55 @@ func InitBlockTree(force bool) {
 		return
 	}
 
-	loadErr := atomic.Bool{}
-	size := atomic.Int64{}
-	waitGroup := &sync.WaitGroup{}
-	p, _ := ants.NewPoolWithFunc(4, func(arg interface{}) {
-		defer waitGroup.Done()
-
-		entry := arg.(os.DirEntry)
-		p := filepath.Join(util.BlockTreePath, entry.Name())
-
-		f, err := os.OpenFile(p, os.O_RDONLY, 0644)
-		if nil != err {
-			logging.LogErrorf("open block tree failed: %s", err)
-			loadErr.Store(true)
-			return
+	var size int64
+	loadErr := false
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".msgpack") {
+			continue
 		}
-		defer f.Close()
 
-		info, err := f.Stat()
-		if nil != err {
-			logging.LogErrorf("stat block tree failed: %s", err)
-			loadErr.Store(true)
-			return
+		data, readErr := os.ReadFile(filepath.Join(util.BlockTreePath, entry.Name()))
+		if nil != readErr {
+			logging.LogErrorf("read block tree failed: %s", readErr)
+			loadErr = true
+			break
 		}
-		size.Add(info.Size())
+		size += int64(len(data))
 
 		sliceData := map[string]*BlockTree{}
-		if err = msgpack.NewDecoder(f).Decode(&sliceData); nil != err {
+		if err = msgpack.Unmarshal(data, &sliceData); nil != err {
 			logging.LogErrorf("unmarshal block tree failed: %s", err)
-			loadErr.Store(true)
-			return
+			loadErr = true
+			break
 		}
 
-		name := entry.Name()[0:strings.Index(entry.Name(), ".")]
-		blockTrees.Store(name, &btSlice{data: sliceData, changed: time.Time{}, m: &sync.Mutex{}})
-	})
-	for _, entry := range entries {
-		if !strings.HasSuffix(entry.Name(), ".msgpack") {
-			continue
+		var bts []*BlockTree
+		for _, bt := range sliceData {
+			bts = append(bts, bt)
+		}
+		if err = upsertBlockTrees(bts); nil != err {
+			logging.LogErrorf("migrate block tree failed: %s", err)
+			loadErr = true
+			break
 		}
-
-		waitGroup.Add(1)
-		p.Invoke(entry)
 	}
 
-	waitGroup.Wait()
-	p.Release()
-
-	if loadErr.Load() {
+	if loadErr {
 		logging.LogInfof("cause block tree load error, remove block tree file")
-		if removeErr := os.RemoveAll(util.BlockTreePath); nil != removeErr {
-			logging.LogErrorf("remove block tree file failed: %s", removeErr)
-			os.Exit(logging.ExitCodeFileSysErr)
-			return
-		}
-		blockTrees = &sync.Map{}
+		execBlockTree("DELETE FROM blocktrees")
+		btCache.clear()
+	}
+	if removeErr := os.RemoveAll(util.BlockTreePath); nil != removeErr {
+		logging.LogErrorf("remove block tree file failed: %s", removeErr)
+		os.Exit(logging.ExitCodeFileSysErr)
+		return
+	}
+	if loadErr {
 		return
 	}
 
 	elapsed := time.Since(start).Seconds()
-	logging.LogInfof("read block tree [%s] to [%s], elapsed [%.2fs]", humanize.BytesCustomCeil(uint64(size.Load()), 2), util.BlockTreePath, elapsed)
+	logging.LogInfof("migrated block tree [%s] to [%s], elapsed [%.2fs]", humanize.BytesCustomCeil(uint64(size), 2), util.BlockTreeDBPath, elapsed)
 	return
 }
 
@@ -518,63 +297,41 @@ func SaveBlockTreeJob() {
 	SaveBlockTree(false)
 }
 
+// SaveBlockTree 将区块树数据库的预写日志合并到数据库文件中。区块树在变更时已经实时写入磁盘，这里只是为了控制日志文件的大小。
 func SaveBlockTree(force bool) {
 	blockTreeLock.Lock()
 	defer blockTreeLock.Unlock()
 
 	if task.ContainIndexTask() {
-		//logging.LogInfof("skip saving block tree because indexing")
 		return
 	}
-	//logging.LogInfof("saving block tree")
 
-	start := time.Now()
-	if err := os.MkdirAll(util.BlockTreePath, 0755); nil != err {
-		logging.LogErrorf("create block tree dir [%s] failed: %s", util.BlockTreePath, err)
-		os.Exit(logging.ExitCodeFileSysErr)
+	if !force && !blockTreeChanged.Load() {
 		return
 	}
+	blockTreeChanged.Store(false)
 
-	size := uint64(0)
-	var count int
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		if !force && slice.changed.IsZero() {
-			slice.m.Unlock()
-			return true
-		}
-
-		data, err := msgpack.Marshal(slice.data)
-		if nil != err {
-			logging.LogErrorf("marshal block tree failed: %s", err)
-			os.Exit(logging.ExitCodeFileSysErr)
-			return false
-		}
-		slice.m.Unlock()
+	start := time.Now()
+	execBlockTree("PRAGMA wal_checkpoint(TRUNCATE)")
+	elapsed := time.Since(start).Seconds()
+	if 2 < elapsed {
+		logging.LogWarnf("checkpoint block tree [%s], elapsed [%.2fs]", util.BlockTreeDBPath, elapsed)
+	}
+}
 
-		p := filepath.Join(util.BlockTreePath, key.(string)) + ".msgpack"
-		if err = gulu.File.WriteFileSafer(p, data, 0644); nil != err {
-			logging.LogErrorf("write block tree failed: %s", err)
-			os.Exit(logging.ExitCodeFileSysErr)
-			return false
-		}
+// CloseBlockTree 关闭区块树数据库。
+func CloseBlockTree() {
+	blockTreeDBLock.Lock()
+	defer blockTreeDBLock.Unlock()
 
-		slice.m.Lock()
-		slice.changed = time.Time{}
-		slice.m.Unlock()
-		size += uint64(len(data))
-		count++
-		return true
-	})
-	if 0 < count {
-		//logging.LogInfof("wrote block trees [%d]", count)
+	if nil == blockTreeDB {
+		return
 	}
-
-	elapsed := time.Since(start).Seconds()
-	if 2 < elapsed {
-		logging.LogWarnf("save block tree [size=%s] to [%s], elapsed [%.2fs]", humanize.BytesCustomCeil(size, 2), util.BlockTreePath, elapsed)
+	if err := blockTreeDB.Close(); nil != err {
+		logging.LogErrorf("close block tree database failed: %s", err)
 	}
+	blockTreeDB = nil
+	btCache.clear()
 }
 
 func CeilTreeCount(count int) int {
@@ -603,6 +360,191 @@ func CeilBlockCount(count int) int {
 	return 10000*100 + 1
 }
 
-func btHash(id string) string {
-	return util2.Hash([]byte(id))[0:2]
+const blockTreeColumns = "id, root_id, parent_id, box_id, path, hpath, updated, type"
+
+func getBlockTreeDB() *sql.DB {
+	blockTreeDBLock.Lock()
+	defer blockTreeDBLock.Unlock()
+
+	if nil != blockTreeDB {
+		return blockTreeDB
+	}
+
+	// 区块树只需要很小的页缓存，避免随工作空间增大而占用内存
+	// 区块树损坏后需要全量重建索引，使用 NORMAL 避免断电时数据库损坏，WAL 模式下 NORMAL 只在检查点时同步
+	dsn := util.BlockTreeDBPath + "?_journal_mode=WAL" +
+		"&_synchronous=NORMAL" +
+		"&_cache_size=-2048" +
+		"&_busy_timeout=7000" +
+		"&_temp_store=MEMORY"
+	db, err := sql.Open("sqlite3", dsn)
+	if nil != err {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create block tree database failed: %s", err)
+	}
+	db.SetMaxIdleConns(4)
+	db.SetMaxOpenConns(4)
+	db.SetConnMaxLifetime(365 * 24 * time.Hour)
+
+	stmts := []string{
+		"CREATE TABLE IF NOT EXISTS blocktrees (id TEXT PRIMARY KEY, root_id TEXT, parent_id TEXT, box_id TEXT, path TEXT, hpath TEXT, updated TEXT, type TEXT)",
+		"CREATE INDEX IF NOT EXISTS idx_blocktrees_root_id ON blocktrees(root_id)",
+		"CREATE INDEX IF NOT EXISTS idx_blocktrees_box_id_path ON blocktrees(box_id, path)",
+		"CREATE INDEX IF NOT EXISTS idx_blocktrees_box_id_hpath ON blocktrees(box_id, hpath)",
+		"CREATE INDEX IF NOT EXISTS idx_blocktrees_path ON blocktrees(path)",
+		"CREATE INDEX IF NOT EXISTS idx_blocktrees_type ON blocktrees(type)",
+	}
+	for _, stmt := range stmts {
+		if _, err = db.Exec(stmt); nil != err {
+			logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create block tree table failed: %s", err)
+		}
+	}
+	blockTreeDB = db
+	return blockTreeDB
+}
+
+func upsertBlockTrees(bts []*BlockTree) (err error) {
+	if 1 > len(bts) {
+		return
+	}
+
+	tx, err := getBlockTreeDB().Begin()
+	if nil != err {
+		return
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO blocktrees (" + blockTreeColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if nil != err {
+		tx.Rollback()
+		return
+	}
+	defer stmt.Close()
+
+	for _, bt := range bts {
+		if _, err = stmt.Exec(bt.ID, bt.RootID, bt.ParentID, bt.BoxID, bt.Path, bt.HPath, bt.Updated, bt.Type); nil != err {
+			tx.Rollback()
+			return
+		}
+	}
+	if err = tx.Commit(); nil != err {
+		return
+	}
+
+	for _, bt := range bts {
+		btCache.put(bt)
+	}
+	blockTreeChanged.Store(true)
+	return
+}
+
+func execBlockTree(stmt string, args ...interface{}) {
+	if _, err := getBlockTreeDB().Exec(stmt, args...); nil != err {
+		logging.LogErrorf("exec block tree statement [%s] failed: %s", stmt, err)
+		return
+	}
+	blockTreeChanged.Store(true)
+}
+
+func queryBlockTree(stmt string, args ...interface{}) (ret *BlockTree) {
+	bts := queryBlockTrees(stmt, args...)
+	if 0 < len(bts) {
+		ret = bts[0]
+	}
+	return
+}
+
+func queryBlockTrees(stmt string, args ...interface{}) (ret []*BlockTree) {
+	rows, err := getBlockTreeDB().Query(stmt, args...)
+	if nil != err {
+		logging.LogErrorf("query block tree [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bt := &BlockTree{}
+		if err = rows.Scan(&bt.ID, &bt.RootID, &bt.ParentID, &bt.BoxID, &bt.Path, &bt.HPath, &bt.Updated, &bt.Type); nil != err {
+			logging.LogErrorf("scan block tree failed: %s", err)
+			return
+		}
+		ret = append(ret, bt)
+	}
+	return
+}
+
+func countBlockTrees(stmt string) (ret int) {
+	if err := getBlockTreeDB().QueryRow(stmt).Scan(&ret); nil != err {
+		logging.LogErrorf("count block tree failed: %s", err)
+	}
+	return
+}
+
+func likePrefix(prefix string) string {
+	prefix = strings.ReplaceAll(prefix, "\\", "\\\\")
+	prefix = strings.ReplaceAll(prefix, "%", "\\%")
+	prefix = strings.ReplaceAll(prefix, "_", "\\_")
+	return prefix + "%"
+}
+
+// btCache 为区块树的热点缓存，按最近使用淘汰。
+var btCache = &blockTreeCache{items: map[string]*list.Element{}, order: list.New(), max: 8192}
+
+type blockTreeCache struct {
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+	max   int
+}
+
+func (cache *blockTreeCache) get(id string) *BlockTree {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	elem := cache.items[id]
+	if nil == elem {
+		return nil
+	}
+	cache.order.MoveToFront(elem)
+	return elem.Value.(*BlockTree)
+}
+
+func (cache *blockTreeCache) put(bt *BlockTree) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if elem := cache.items[bt.ID]; nil != elem {
+		elem.Value = bt
+		cache.order.MoveToFront(elem)
+		return
+	}
+
+	cache.items[bt.ID] = cache.order.PushFront(bt)
+	for cache.max < cache.order.Len() {
+		back := cache.order.Back()
+		cache.order.Remove(back)
+		delete(cache.items, back.Value.(*BlockTree).ID)
+	}
+}
+
+func (cache *blockTreeCache) remove(id string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if elem := cache.items[id]; nil != elem {
+		cache.order.Remove(elem)
+		delete(cache.items, id)
+	}
+}
+
+func (cache *blockTreeCache) removeIf(match func(bt *BlockTree) bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for id, elem := range cache.items {
+		if match(elem.Value.(*BlockTree)) {
+			cache.order.Remove(elem)
+			delete(cache.items, id)
+		}
+	}
+}
+
+func (cache *blockTreeCache) clear() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.items = map[string]*list.Element{}
+	cache.order.Init()
 }
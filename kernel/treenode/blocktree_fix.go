@@ -18,7 +18,7 @@ package treenode
 
 import (
 	"github.com/88250/gulu"
-	"time"
+	"github.com/siyuan-note/logging"
 )
 
 func ClearRedundantBlockTrees(boxID string, paths []string) {
@@ -34,19 +34,7 @@ func getRedundantPaths(boxID string, paths []string) (ret []string) {
 		pathsMap[path] = true
 	}
 
-	btPathsMap := map[string]bool{}
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID {
-				btPathsMap[b.Path] = true
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-
+	btPathsMap := getBlockTreePaths(boxID)
 	for p, _ := range btPathsMap {
 		if !pathsMap[p] {
 			ret = append(ret, p)
@@ -57,18 +45,8 @@ func getRedundantPaths(boxID string, paths []string) (ret []string) {
 }
 
 func removeBlockTreesByPath(boxID, path string) {
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.Path == path && b.BoxID == boxID {
-				delete(slice.data, b.ID)
-				slice.changed = time.Now()
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
+	execBlockTree("DELETE FROM blocktrees WHERE box_id = ? AND path = ?", boxID, path)
+	btCache.removeIf(func(bt *BlockTree) bool { return bt.BoxID == boxID && bt.Path == path })
 }
 
 func GetNotExistPaths(boxID string, paths []string) (ret []string) {
@@ -77,19 +55,7 @@ func GetNotExistPaths(boxID string, paths []string) (ret []string) {
 		pathsMap[path] = true
 	}
 
-	btPathsMap := map[string]bool{}
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.BoxID == boxID {
-				btPathsMap[b.Path] = true
-			}
-		}
-		slice.m.Unlock()
-		return true
-	})
-
+	btPathsMap := getBlockTreePaths(boxID)
 	for p, _ := range pathsMap {
 		if !btPathsMap[p] {
 			ret = append(ret, p)
@@ -101,16 +67,26 @@ func GetNotExistPaths(boxID string, paths []string) (ret []string) {
 
 func GetRootUpdated() (ret map[string]string) {
 	ret = map[string]string{}
-	blockTrees.Range(func(key, value interface{}) bool {
-		slice := value.(*btSlice)
-		slice.m.Lock()
-		for _, b := range slice.data {
-			if b.RootID == b.ID {
-				ret[b.RootID] = b.Updated
-			}
+	for _, b := range queryBlockTrees("SELECT " + blockTreeColumns + " FROM blocktrees WHERE id = root_id") {
+		ret[b.RootID] = b.Updated
+	}
+	return
+}
+
+func getBlockTreePaths(boxID string) (ret map[string]bool) {
+	ret = map[string]bool{}
+	rows, err := getBlockTreeDB().Query("SELECT DISTINCT path FROM blocktrees WHERE box_id = ?", boxID)
+	if nil != err {
+		logging.LogErrorf("query block tree paths failed: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p string
+		if err = rows.Scan(&p); nil == err {
+			ret[p] = true
 		}
-		slice.m.Unlock()
-		return true
-	})
+	}
 	return
 }
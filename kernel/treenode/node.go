@@ -133,7 +133,7 @@ func ExportNodeStdMd(node *ast.Node, luteEngine *lute.Lute) string {
 }
 
 func IsNodeOCRed(node *ast.Node) (ret bool) {
-	if !util.TesseractEnabled || nil == node {
+	if !util.OCREnabled || nil == node {
 		return true
 	}
 
@@ -0,0 +1,84 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/logging"
+)
+
+// BootStage 描述了内核启动阶段的耗时。
+type BootStage struct {
+	Name     string `json:"name"`     // 阶段名称
+	Start    int64  `json:"start"`    // 相对内核启动的开始时间（毫秒）
+	Elapsed  int64  `json:"elapsed"`  // 耗时（毫秒），-1 表示仍在进行
+	Deferred bool   `json:"deferred"` // 是否为界面可交互后才执行的非关键阶段
+}
+
+var (
+	bootStartTime   = time.Now()
+	bootElapsed     int64 // 启动完成的耗时（毫秒）
+	bootStages      []*BootStage
+	bootStagesLock  = sync.Mutex{}
+	bootStageByName = map[string]*BootStage{}
+)
+
+// StartBootStage 记录启动阶段的开始，返回的函数在阶段结束时调用。同名阶段只记录第一次。
+func StartBootStage(name string, deferred bool) (end func()) {
+	start := time.Now()
+	bootStagesLock.Lock()
+	defer bootStagesLock.Unlock()
+	if nil != bootStageByName[name] {
+		return func() {}
+	}
+
+	stage := &BootStage{Name: name, Start: start.Sub(bootStartTime).Milliseconds(), Elapsed: -1, Deferred: deferred}
+	bootStages = append(bootStages, stage)
+	bootStageByName[name] = stage
+	return func() {
+		elapsed := time.Since(start).Milliseconds()
+		bootStagesLock.Lock()
+		stage.Elapsed = elapsed
+		bootStagesLock.Unlock()
+		logging.LogInfof("boot stage [%s] elapsed [%dms]", name, elapsed)
+	}
+}
+
+// GetBootStages 返回各启动阶段的耗时以及启动完成的耗时（毫秒，未完成时为 -1）。
+func GetBootStages() (stages []*BootStage, elapsed int64) {
+	bootStagesLock.Lock()
+	defer bootStagesLock.Unlock()
+	for _, stage := range bootStages {
+		s := *stage
+		stages = append(stages, &s)
+	}
+	elapsed = -1
+	if IsBooted() {
+		elapsed = bootElapsed
+	}
+	return
+}
+
+// WaitForInteractive 等待界面可交互，最多等待 timeout，用于推迟执行非关键的启动任务。
+func WaitForInteractive(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for !IsUILoaded && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,139 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 是解析后的 cron 表达式，格式为 “分 时 日 月 周”。
+//
+// 每个字段支持 *、*/n、a、a-b、a-b/n 以及用逗号分隔的组合，周的取值为 0-6（0 为周日）。
+// 另外支持 @hourly、@daily、@weekly、@monthly 简写。
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+func ParseCron(expr string) (ret *CronSchedule, err error) {
+	expr = strings.TrimSpace(expr)
+	if shortcut, ok := cronShortcuts[expr]; ok {
+		expr = shortcut
+	}
+
+	fields := strings.Fields(expr)
+	if 5 != len(fields) {
+		err = fmt.Errorf("invalid cron expression [%s]", expr)
+		return
+	}
+
+	ret = &CronSchedule{domStar: "*" == fields[2], dowStar: "*" == fields[4]}
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	targets := []*uint64{&ret.minute, &ret.hour, &ret.dom, &ret.month, &ret.dow}
+	for i, field := range fields {
+		if *targets[i], err = parseCronField(field, bounds[i][0], bounds[i][1]); nil != err {
+			err = fmt.Errorf("invalid cron expression [%s]: %s", expr, err)
+			return nil, err
+		}
+	}
+	if 0 != ret.dow&(1<<7) { // 7 也表示周日
+		ret.dow |= 1
+	}
+	return
+}
+
+func parseCronField(field string, min, max int) (ret uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); 0 <= idx {
+			if step, err = strconv.Atoi(part[idx+1:]); nil != err || 1 > step {
+				return 0, fmt.Errorf("invalid step [%s]", part)
+			}
+			part = part[:idx]
+		}
+
+		from, to := min, max
+		if "*" != part {
+			bound := strings.SplitN(part, "-", 2)
+			if from, err = strconv.Atoi(bound[0]); nil != err {
+				return 0, fmt.Errorf("invalid value [%s]", part)
+			}
+			to = from
+			if 2 == len(bound) {
+				if to, err = strconv.Atoi(bound[1]); nil != err {
+					return 0, fmt.Errorf("invalid value [%s]", part)
+				}
+			} else if 1 < step {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("value [%s] out of range [%d-%d]", part, min, max)
+		}
+
+		for v := from; v <= to; v += step {
+			ret |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// Next 返回 t 之后（不含 t）第一个匹配的时间，精确到分钟。四年内没有匹配时返回零值。
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(4, 0, 0)
+	for t.Before(limit) {
+		if 0 == schedule.month&(1<<uint(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !schedule.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if 0 == schedule.hour&(1<<uint(t.Hour())) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if 0 == schedule.minute&(1<<uint(t.Minute())) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := 0 != schedule.dom&(1<<uint(t.Day()))
+	dowMatch := 0 != schedule.dow&(1<<uint(t.Weekday()))
+	if schedule.domStar || schedule.dowStar {
+		// 与标准 cron 一致：日和周只指定了其中一个时按指定的匹配，都指定时满足其一即可
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
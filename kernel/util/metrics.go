@@ -0,0 +1,204 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsEnabled 是否采集运行指标，未开启时各埋点直接返回，避免额外开销。
+var MetricsEnabled = atomic.Bool{}
+
+// metricBuckets 为直方图的默认分桶（秒）。
+var metricBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+type metricHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type metricFamily struct {
+	name, typ, help string
+	counters        map[string]float64
+	histograms      map[string]*metricHistogram
+}
+
+var (
+	metricFamilies = map[string]*metricFamily{}
+	metricsLock    = sync.Mutex{}
+)
+
+// DescribeMetric 登记指标的类型和说明，typ 为 counter 或者 histogram。
+func DescribeMetric(name, typ, help string) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	getMetricFamily(name, typ).help = help
+}
+
+// IncMetric 将计数器加一，labels 为成对的标签名和标签值。
+func IncMetric(name string, labels ...string) {
+	if !MetricsEnabled.Load() {
+		return
+	}
+
+	key := metricLabels(labels)
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	getMetricFamily(name, "counter").counters[key]++
+}
+
+// ObserveMetric 将从 start 开始经过的时长记录到直方图中，labels 为成对的标签名和标签值。
+func ObserveMetric(name string, start time.Time, labels ...string) {
+	if !MetricsEnabled.Load() {
+		return
+	}
+
+	seconds := time.Since(start).Seconds()
+	key := metricLabels(labels)
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	family := getMetricFamily(name, "histogram")
+	h := family.histograms[key]
+	if nil == h {
+		h = &metricHistogram{counts: make([]uint64, len(metricBuckets))}
+		family.histograms[key] = h
+	}
+	for i, bound := range metricBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// WriteMetrics 按照 Prometheus 文本格式输出已采集的计数器和直方图。
+func WriteMetrics(buf *bytes.Buffer) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	var names []string
+	for name := range metricFamilies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := metricFamilies[name]
+		if 1 > len(family.counters) && 1 > len(family.histograms) {
+			continue
+		}
+
+		WriteMetricHeader(buf, name, family.typ, family.help)
+		for _, key := range sortedMetricKeys(family.counters) {
+			WriteMetricSample(buf, name, key, family.counters[key])
+		}
+
+		var keys []string
+		for key := range family.histograms {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			h := family.histograms[key]
+			for i, bound := range metricBuckets {
+				WriteMetricSample(buf, name+"_bucket", joinMetricLabels(key, "le=\""+strconv.FormatFloat(bound, 'g', -1, 64)+"\""), float64(h.counts[i]))
+			}
+			WriteMetricSample(buf, name+"_bucket", joinMetricLabels(key, "le=\"+Inf\""), float64(h.count))
+			WriteMetricSample(buf, name+"_sum", key, h.sum)
+			WriteMetricSample(buf, name+"_count", key, float64(h.count))
+		}
+	}
+}
+
+// WriteMetricHeader 输出指标的 HELP 和 TYPE 行。
+func WriteMetricHeader(buf *bytes.Buffer, name, typ, help string) {
+	if "" != help {
+		buf.WriteString("# HELP " + name + " " + strings.ReplaceAll(help, "\n", " ") + "\n")
+	}
+	buf.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// WriteMetricSample 输出一行指标样本，labels 为已经格式化好的标签（不含花括号）。
+func WriteMetricSample(buf *bytes.Buffer, name, labels string, value float64) {
+	buf.WriteString(name)
+	if "" != labels {
+		buf.WriteString("{" + labels + "}")
+	}
+	buf.WriteString(" ")
+	switch {
+	case math.IsInf(value, 1):
+		buf.WriteString("+Inf")
+	case math.IsInf(value, -1):
+		buf.WriteString("-Inf")
+	case math.IsNaN(value):
+		buf.WriteString("NaN")
+	default:
+		buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	buf.WriteString("\n")
+}
+
+// MetricLabels 将成对的标签名和标签值格式化为 Prometheus 标签。
+func MetricLabels(labels ...string) string {
+	return metricLabels(labels)
+}
+
+func metricLabels(labels []string) string {
+	if 2 > len(labels) {
+		return ""
+	}
+
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinMetricLabels(key, label string) string {
+	if "" == key {
+		return label
+	}
+	return key + "," + label
+}
+
+func sortedMetricKeys(m map[string]float64) (ret []string) {
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return
+}
+
+func getMetricFamily(name, typ string) *metricFamily {
+	family := metricFamilies[name]
+	if nil == family {
+		family = &metricFamily{name: name, typ: typ, counters: map[string]float64{}, histograms: map[string]*metricHistogram{}}
+		metricFamilies[name] = family
+	}
+	return family
+}
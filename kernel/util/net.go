@@ -17,10 +17,12 @@
 package util
 
 import (
+	"errors"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/88250/gulu"
@@ -125,16 +127,103 @@ func isOnline(checkURL string, skipTlsVerify bool) (ret bool) {
 }
 
 func GetRemoteAddr(req *http.Request) string {
-	ret := req.Header.Get("X-forwarded-for")
-	ret = strings.TrimSpace(ret)
-	if "" == ret {
-		ret = req.Header.Get("X-Real-IP")
+	return GetClientIP(req)
+}
+
+var (
+	trustedProxies     []*net.IPNet
+	trustedProxiesLock = sync.RWMutex{}
+)
+
+// SetTrustedProxies 设置受信任的反向代理，回环地址总是受信任。
+func SetTrustedProxies(proxies []*net.IPNet) {
+	trustedProxiesLock.Lock()
+	defer trustedProxiesLock.Unlock()
+	trustedProxies = proxies
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	if nil == ip {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	trustedProxiesLock.RLock()
+	defer trustedProxiesLock.RUnlock()
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP 返回请求的客户端 IP。
+//
+// 仅当直连地址是受信任的反向代理时才使用 X-Forwarded-For 和 X-Real-IP，X-Forwarded-For 从右往左跳过受信任的代理，
+// 第一个不受信任的地址即为客户端地址，避免客户端伪造请求头绕过访问控制。
+func GetClientIP(req *http.Request) string {
+	ret := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(ret); nil == err {
+		ret = host
 	}
-	ret = strings.TrimSpace(ret)
-	if "" == ret {
-		return req.RemoteAddr
+	if !isTrustedProxy(net.ParseIP(ret)) {
+		return ret
 	}
-	return strings.Split(ret, ",")[0]
+
+	if forwardedFor := strings.TrimSpace(req.Header.Get("X-Forwarded-For")); "" != forwardedFor {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; 0 <= i; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if nil == ip {
+				// 无法解析的地址不可信，停止继续向左查找
+				return ret
+			}
+			ret = hop
+			if !isTrustedProxy(ip) {
+				return ret
+			}
+		}
+		return ret
+	}
+
+	if realIP := strings.TrimSpace(req.Header.Get("X-Real-IP")); nil != net.ParseIP(realIP) {
+		ret = realIP
+	}
+	return ret
+}
+
+// ParseIPNets 解析 IP 或 CIDR 列表，单个 IP 按照主机地址处理。
+func ParseIPNets(list []string) (ret []*net.IPNet, err error) {
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if "" == item {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if nil == ip {
+				return nil, errors.New("invalid IP [" + item + "]")
+			}
+			bits := 128
+			if nil != ip.To4() {
+				ip, bits = ip.To4(), 32
+			}
+			ret = append(ret, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, parseErr := net.ParseCIDR(item)
+		if nil != parseErr {
+			return nil, errors.New("invalid CIDR [" + item + "]")
+		}
+		ret = append(ret, ipNet)
+	}
+	return
 }
 
 func JsonArg(c *gin.Context, result *gulu.Result) (arg map[string]interface{}, ok bool) {
@@ -0,0 +1,109 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetClientIP(t *testing.T) {
+	proxies, err := ParseIPNets([]string{"10.0.0.0/8", "fd00::/8"})
+	if nil != err {
+		t.Fatalf("parse trusted proxies failed: %s", err)
+	}
+	SetTrustedProxies(proxies)
+	defer SetTrustedProxies(nil)
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expected   string
+	}{
+		{"direct client", "203.0.113.7:1234", "", "", "203.0.113.7"},
+		{"spoofed forwarded for from untrusted peer", "203.0.113.7:1234", "127.0.0.1", "", "203.0.113.7"},
+		{"spoofed real ip from untrusted peer", "203.0.113.7:1234", "", "127.0.0.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:1234", "198.51.100.9", "", "198.51.100.9"},
+		{"loopback proxy", "127.0.0.1:1234", "198.51.100.9", "", "198.51.100.9"},
+		{"multiple hops", "10.0.0.2:1234", "198.51.100.9, 10.0.0.3, 10.0.0.4", "", "198.51.100.9"},
+		{"spoofed leftmost hop", "10.0.0.2:1234", "127.0.0.1, 198.51.100.9, 10.0.0.3", "", "198.51.100.9"},
+		{"all hops trusted", "10.0.0.2:1234", "10.0.0.3, 10.0.0.4", "", "10.0.0.3"},
+		{"invalid hop", "10.0.0.2:1234", "198.51.100.9, not-an-ip", "", "10.0.0.2"},
+		{"real ip from trusted proxy", "10.0.0.2:1234", "", "198.51.100.9", "198.51.100.9"},
+		{"invalid real ip", "10.0.0.2:1234", "", "not-an-ip", "10.0.0.2"},
+		{"ipv6 client", "[2001:db8::1]:1234", "", "", "2001:db8::1"},
+		{"ipv6 trusted proxy", "[fd00::2]:1234", "2001:db8::1", "", "2001:db8::1"},
+		{"ipv6 untrusted peer", "[2001:db8::2]:1234", "2001:db8::1", "", "2001:db8::2"},
+		{"ipv6 loopback proxy", "[::1]:1234", "2001:db8::1, fd00::3", "", "2001:db8::1"},
+	}
+
+	for _, c := range cases {
+		req := &http.Request{RemoteAddr: c.remoteAddr, Header: http.Header{}}
+		if "" != c.forwarded {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if "" != c.realIP {
+			req.Header.Set("X-Real-IP", c.realIP)
+		}
+		if ip := GetClientIP(req); c.expected != ip {
+			t.Errorf("%s: expected [%s], got [%s]", c.name, c.expected, ip)
+		}
+	}
+}
+
+func TestParseIPNets(t *testing.T) {
+	cases := []struct {
+		list     []string
+		expected []string
+		invalid  bool
+	}{
+		{[]string{"192.168.1.0/24", " 10.0.0.1 ", ""}, []string{"192.168.1.0/24", "10.0.0.1/32"}, false},
+		{[]string{"2001:db8::/32", "::1"}, []string{"2001:db8::/32", "::1/128"}, false},
+		{[]string{"192.168.1.10/24"}, []string{"192.168.1.0/24"}, false},
+		{nil, nil, false},
+		{[]string{"192.168.1.0/33"}, nil, true},
+		{[]string{"2001:db8::/129"}, nil, true},
+		{[]string{"192.168.1"}, nil, true},
+		{[]string{"example.com"}, nil, true},
+		{[]string{"10.0.0.0/8", "bad/8"}, nil, true},
+	}
+
+	for _, c := range cases {
+		nets, err := ParseIPNets(c.list)
+		if c.invalid {
+			if nil == err {
+				t.Errorf("%v: expected error", c.list)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("%v: unexpected error: %s", c.list, err)
+			continue
+		}
+		if len(c.expected) != len(nets) {
+			t.Errorf("%v: expected [%d] nets, got [%d]", c.list, len(c.expected), len(nets))
+			continue
+		}
+		for i, n := range nets {
+			if c.expected[i] != n.String() {
+				t.Errorf("%v: expected [%s], got [%s]", c.list, c.expected[i], n.String())
+			}
+		}
+	}
+}
@@ -18,7 +18,7 @@ package util
 
 import (
 	"bytes"
-	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -40,7 +40,7 @@ import (
 
 var (
 	TesseractBin     = "tesseract"
-	TesseractEnabled bool
+	OCREnabled       bool // 是否启用 OCR，使用本地 Tesseract 时需要检测到 Tesseract 和语言模型
 	TesseractMaxSize = 2 * 1000 * uint64(1000)
 	TesseractLangs   []string
 
@@ -71,6 +71,8 @@ func CleanNotExistAssetsTexts() {
 }
 
 func LoadAssetsTexts() {
+	defer StartBootStage("assetsTexts", false)()
+
 	assetsPath := GetDataAssetsAbsPath()
 	assetsTextsPath := filepath.Join(assetsPath, "ocr-texts.json")
 	if !filelock.IsExist(assetsTextsPath) {
@@ -102,7 +104,7 @@ func LoadAssetsTexts() {
 }
 
 func SaveAssetsTexts() {
-	if !assetsTextsChanged.Load() || !TesseractEnabled {
+	if !assetsTextsChanged.Load() || !OCREnabled {
 		return
 	}
 
@@ -149,7 +151,8 @@ func ExistsAssetText(asset string) (ret bool) {
 	return
 }
 
-func GetAssetText(asset string, force bool) (ret string) {
+// GetAssetText 获取资源文件的 OCR 文本，force 为 true 时重新识别，langs 为空时使用默认的语言模型。
+func GetAssetText(asset string, force bool, langs []string) (ret string) {
 	if !force {
 		assetsTextsLock.Lock()
 		ret = assetsTexts[asset]
@@ -160,7 +163,7 @@ func GetAssetText(asset string, force bool) (ret string) {
 	assetsPath := GetDataAssetsAbsPath()
 	assetAbsPath := strings.TrimPrefix(asset, "assets")
 	assetAbsPath = filepath.Join(assetsPath, assetAbsPath)
-	ret = Tesseract(assetAbsPath)
+	ret = OCR(assetAbsPath, langs)
 	assetsTextsLock.Lock()
 	assetsTexts[asset] = ret
 	assetsTextsLock.Unlock()
@@ -175,55 +178,63 @@ func IsTesseractExtractable(p string) bool {
 	return strings.HasSuffix(lowerName, ".png") || strings.HasSuffix(lowerName, ".jpg") || strings.HasSuffix(lowerName, ".jpeg")
 }
 
-// tesseractOCRLock 用于 Tesseract OCR 加锁串行执行提升稳定性 https://github.com/siyuan-note/siyuan/issues/7265
-var tesseractOCRLock = sync.Mutex{}
+// ocrLock 用于 OCR 加锁串行执行提升稳定性 https://github.com/siyuan-note/siyuan/issues/7265
+var ocrLock = sync.Mutex{}
+
+func OCR(imgAbsPath string, langs []string) string {
+	ret, _ := OCRWithErr(imgAbsPath, langs)
+	return ret
+}
+
+// ErrOCRSkipped 表示资源文件不满足识别条件，比如格式不支持或者文件过大。
+var ErrOCRSkipped = errors.New("ocr skipped")
 
-func Tesseract(imgAbsPath string) string {
-	if ContainerStd != Container || !TesseractEnabled {
-		return ""
+// OCRWithErr 识别图片中的文字，识别失败时返回失败原因。
+func OCRWithErr(imgAbsPath string, langs []string) (ret string, err error) {
+	if !OCREnabled {
+		err = fmt.Errorf("%w: ocr is disabled", ErrOCRSkipped)
+		return
+	}
+	if OCRProviderTesseract == ocrProvider.Name() && ContainerStd != Container {
+		err = fmt.Errorf("%w: tesseract is not available in container [%s]", ErrOCRSkipped, Container)
+		return
 	}
 
 	defer logging.Recover()
-	tesseractOCRLock.Lock()
-	defer tesseractOCRLock.Unlock()
+	ocrLock.Lock()
+	defer ocrLock.Unlock()
 
 	if !IsTesseractExtractable(imgAbsPath) {
-		return ""
+		err = fmt.Errorf("%w: unsupported format", ErrOCRSkipped)
+		return
 	}
 
 	info, err := os.Stat(imgAbsPath)
 	if nil != err {
-		return ""
+		return
 	}
 
 	if TesseractMaxSize < uint64(info.Size()) {
-		return ""
+		err = fmt.Errorf("%w: file size [%d] exceeds the limit [%d]", ErrOCRSkipped, info.Size(), TesseractMaxSize)
+		return
 	}
 
-	defer logging.Recover()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 7*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, TesseractBin, "-c", "debug_file=/dev/null", imgAbsPath, "stdout", "-l", strings.Join(TesseractLangs, "+"))
-	gulu.CmdAttr(cmd)
-	output, err := cmd.CombinedOutput()
-	if ctx.Err() == context.DeadlineExceeded {
-		logging.LogWarnf("tesseract [path=%s, size=%d] timeout", imgAbsPath, info.Size())
-		return ""
+	if 1 > len(langs) {
+		langs = OCRLangs
 	}
 
+	ret, err = ocrProvider.Recognize(imgAbsPath, langs)
 	if nil != err {
-		logging.LogWarnf("tesseract [path=%s, size=%d] failed: %s", imgAbsPath, info.Size(), err)
-		return ""
+		logging.LogWarnf("ocr [provider=%s, path=%s, size=%d] failed: %s", ocrProvider.Name(), imgAbsPath, info.Size(), err)
+		ret = ""
+		return
 	}
 
-	ret := string(output)
 	ret = gulu.Str.RemoveInvisible(ret)
 	ret = RemoveRedundantSpace(ret)
 	msg := fmt.Sprintf("OCR [%s] [%s]", html.EscapeString(info.Name()), html.EscapeString(ret))
 	PushStatusBar(msg)
-	return ret
+	return
 }
 
 var tesseractInited = atomic.Bool{}
@@ -238,6 +249,12 @@ func WaitForTesseractInit() {
 }
 
 func InitTesseract() {
+	initOCRProvider()
+	if OCRProviderTesseract != ocrProvider.Name() {
+		tesseractInited.Store(true)
+		return
+	}
+
 	ver := getTesseractVer()
 	if "" == ver {
 		tesseractInited.Store(true)
@@ -247,7 +264,7 @@ func InitTesseract() {
 	langs := getTesseractLangs()
 	if 1 > len(langs) {
 		logging.LogWarnf("no tesseract langs found")
-		TesseractEnabled = false
+		OCREnabled = false
 		tesseractInited.Store(true)
 		return
 	}
@@ -262,7 +279,7 @@ func InitTesseract() {
 	// Supports via environment var `SIYUAN_TESSERACT_ENABLED=false` to close OCR https://github.com/siyuan-note/siyuan/issues/9619
 	if enabled := os.Getenv("SIYUAN_TESSERACT_ENABLED"); "" != enabled {
 		if enabledBool, parseErr := strconv.ParseBool(enabled); nil == parseErr {
-			TesseractEnabled = enabledBool
+			OCREnabled = enabledBool
 			if !enabledBool {
 				logging.LogInfof("tesseract-ocr disabled by env")
 				tesseractInited.Store(true)
@@ -330,7 +347,7 @@ func getTesseractVer() (ret string) {
 		if 0 < len(parts) {
 			ret = strings.TrimPrefix(string(parts[0]), "tesseract ")
 			ret = strings.TrimSpace(ret)
-			TesseractEnabled = true
+			OCREnabled = true
 		}
 		return
 	}
@@ -338,7 +355,7 @@ func getTesseractVer() (ret string) {
 }
 
 func getTesseractLangs() (ret []string) {
-	if !TesseractEnabled {
+	if !OCREnabled {
 		return nil
 	}
 
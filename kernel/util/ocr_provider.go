@@ -0,0 +1,139 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+)
+
+// OCRProvider 描述了 OCR 引擎，通过环境变量 SIYUAN_OCR_PROVIDER 选择，默认使用本地 Tesseract。
+type OCRProvider interface {
+	Name() string
+	// Recognize 识别图片中的文字，langs 为使用的语言模型，为空时使用引擎默认的语言模型。
+	Recognize(imgAbsPath string, langs []string) (string, error)
+}
+
+const (
+	OCRProviderTesseract = "tesseract" // 本地 Tesseract
+	OCRProviderRemote    = "remote"    // 远程 HTTP 接口，比如自建的 PaddleOCR 或者 Tesseract 服务
+)
+
+var ocrProvider OCRProvider = &tesseractOCRProvider{}
+
+// OCRLangs 是默认使用的语言模型，通过环境变量 SIYUAN_OCR_LANGS（使用 + 分隔）指定，未指定时使用 TesseractLangs。
+var OCRLangs []string
+
+func GetOCRProviderName() string {
+	return ocrProvider.Name()
+}
+
+type tesseractOCRProvider struct{}
+
+func (provider *tesseractOCRProvider) Name() string {
+	return OCRProviderTesseract
+}
+
+func (provider *tesseractOCRProvider) Recognize(imgAbsPath string, langs []string) (ret string, err error) {
+	if 1 > len(langs) {
+		langs = TesseractLangs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 7*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, TesseractBin, "-c", "debug_file=/dev/null", imgAbsPath, "stdout", "-l", strings.Join(langs, "+"))
+	gulu.CmdAttr(cmd)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = errors.New("timeout")
+		return
+	}
+	if nil != err {
+		return
+	}
+	ret = string(output)
+	return
+}
+
+// remoteOCRProvider 将图片上传到远程接口识别，接口使用 multipart/form-data 接收 file 和 langs 字段，返回 {"text": "..."}。
+type remoteOCRProvider struct {
+	url   string
+	token string
+}
+
+func (provider *remoteOCRProvider) Name() string {
+	return OCRProviderRemote
+}
+
+func (provider *remoteOCRProvider) Recognize(imgAbsPath string, langs []string) (ret string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result := map[string]interface{}{}
+	request := httpclient.NewBrowserRequest().SetContext(ctx).SetFile("file", imgAbsPath).
+		SetFormData(map[string]string{"langs": strings.Join(langs, "+")}).SetSuccessResult(&result)
+	if "" != provider.token {
+		request.SetBearerAuthToken(provider.token)
+	}
+	resp, err := request.Post(provider.url)
+	if nil != err {
+		return
+	}
+	if 200 != resp.StatusCode {
+		err = fmt.Errorf("request [%s] responded with status code [%d]", provider.url, resp.StatusCode)
+		return
+	}
+
+	text, ok := result["text"].(string)
+	if !ok {
+		err = errors.New("invalid response, missing text")
+		return
+	}
+	ret = text
+	return
+}
+
+// initOCRProvider 根据环境变量初始化 OCR 引擎，使用远程接口时不依赖本地 Tesseract。
+func initOCRProvider() {
+	if envLangsVal := os.Getenv("SIYUAN_OCR_LANGS"); "" != envLangsVal {
+		OCRLangs = strings.Split(envLangsVal, "+")
+	}
+
+	if OCRProviderRemote != os.Getenv("SIYUAN_OCR_PROVIDER") {
+		return
+	}
+
+	url := os.Getenv("SIYUAN_OCR_REMOTE_URL")
+	if "" == url {
+		logging.LogWarnf("remote ocr provider requires env [SIYUAN_OCR_REMOTE_URL]")
+		return
+	}
+
+	ocrProvider = &remoteOCRProvider{url: url, token: os.Getenv("SIYUAN_OCR_REMOTE_TOKEN")}
+	OCREnabled = true
+	logging.LogInfof("remote ocr enabled [url=%s, langs=%s]", url, strings.Join(OCRLangs, "+"))
+}
@@ -0,0 +1,184 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/eventbus"
+)
+
+const (
+	OCRStatusPending    = "pending"    // 等待识别
+	OCRStatusProcessing = "processing" // 正在识别
+	OCRStatusDone       = "done"       // 识别完成
+	OCRStatusFailed     = "failed"     // 识别失败
+	OCRStatusSkipped    = "skipped"    // 不满足识别条件，比如格式不支持或者文件过大
+)
+
+// OCRAssetStatus 描述了资源文件的 OCR 状态。
+type OCRAssetStatus struct {
+	Path    string   `json:"path"`    // 资源文件路径，比如 assets/foo.png
+	Status  string   `json:"status"`  // 状态
+	Reason  string   `json:"reason"`  // 失败或者跳过的原因
+	Langs   []string `json:"langs"`   // 重新识别时指定的语言模型
+	Updated int64    `json:"updated"` // 状态更新时间
+}
+
+// OCRProgress 描述了重新识别的进度，通过事件总线 EvtOCRProgress 发布。
+type OCRProgress struct {
+	Total     int    `json:"total"`     // 本轮需要重新识别的资源文件总数
+	Processed int    `json:"processed"` // 已经处理的数量
+	Failed    int    `json:"failed"`    // 失败的数量
+	Current   string `json:"current"`   // 当前处理的资源文件
+}
+
+var (
+	ocrStatuses     = map[string]*OCRAssetStatus{}
+	ocrReQueue      []string // 等待重新识别的资源文件
+	ocrProgress     = &OCRProgress{}
+	ocrStatusesLock = sync.Mutex{}
+)
+
+// SetOCRStatus 设置资源文件的 OCR 状态。
+func SetOCRStatus(asset, status, reason string) {
+	ocrStatusesLock.Lock()
+	defer ocrStatusesLock.Unlock()
+
+	s := ocrStatuses[asset]
+	if nil == s {
+		s = &OCRAssetStatus{Path: asset}
+		ocrStatuses[asset] = s
+	}
+	s.Status = status
+	s.Reason = reason
+	s.Updated = time.Now().UnixMilli()
+}
+
+// GetOCRStatus 返回资源文件的 OCR 状态，没有记录时根据是否已有识别结果推断。
+func GetOCRStatus(asset string) (ret *OCRAssetStatus) {
+	ocrStatusesLock.Lock()
+	if s := ocrStatuses[asset]; nil != s {
+		ret = &OCRAssetStatus{}
+		*ret = *s
+	}
+	ocrStatusesLock.Unlock()
+	if nil != ret {
+		return
+	}
+
+	ret = &OCRAssetStatus{Path: asset, Status: OCRStatusPending}
+	if !IsTesseractExtractable(asset) {
+		ret.Status = OCRStatusSkipped
+		ret.Reason = "unsupported format"
+	} else if ExistsAssetText(asset) {
+		ret.Status = OCRStatusDone
+	}
+	return
+}
+
+// GetOCRStatuses 返回本次运行期间记录的 OCR 状态，status 不为空时按状态过滤。
+func GetOCRStatuses(status string) (ret []*OCRAssetStatus) {
+	ocrStatusesLock.Lock()
+	defer ocrStatusesLock.Unlock()
+
+	ret = []*OCRAssetStatus{}
+	for _, s := range ocrStatuses {
+		if "" != status && status != s.Status {
+			continue
+		}
+		c := *s
+		ret = append(ret, &c)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Updated > ret[j].Updated })
+	return
+}
+
+// PushOCRReQueue 将资源文件加入重新识别队列，langs 为空时使用默认的语言模型。
+func PushOCRReQueue(assets []string, langs []string) {
+	ocrStatusesLock.Lock()
+	defer ocrStatusesLock.Unlock()
+
+	if 1 > len(ocrReQueue) {
+		ocrProgress = &OCRProgress{}
+	}
+
+	for _, asset := range assets {
+		s := ocrStatuses[asset]
+		if nil == s {
+			s = &OCRAssetStatus{Path: asset}
+			ocrStatuses[asset] = s
+		}
+		if OCRStatusPending == s.Status && gulu.Str.Contains(asset, ocrReQueue) {
+			continue
+		}
+
+		s.Status = OCRStatusPending
+		s.Reason = ""
+		s.Langs = langs
+		s.Updated = time.Now().UnixMilli()
+		ocrReQueue = append(ocrReQueue, asset)
+		ocrProgress.Total++
+	}
+}
+
+// PopOCRReQueue 从重新识别队列中取出最多 n 个资源文件。
+func PopOCRReQueue(n int) (ret []*OCRAssetStatus) {
+	ocrStatusesLock.Lock()
+	defer ocrStatusesLock.Unlock()
+
+	for 0 < len(ocrReQueue) && len(ret) < n {
+		asset := ocrReQueue[0]
+		ocrReQueue = ocrReQueue[1:]
+		if s := ocrStatuses[asset]; nil != s {
+			c := *s
+			ret = append(ret, &c)
+		}
+	}
+	return
+}
+
+// OCRReQueueDone 记录一个重新识别的资源文件处理完成，并通过事件总线发布进度。
+func OCRReQueueDone(asset string, failed bool) {
+	ocrStatusesLock.Lock()
+	ocrProgress.Processed++
+	if failed {
+		ocrProgress.Failed++
+	}
+	ocrProgress.Current = asset
+	progress := *ocrProgress
+	ocrStatusesLock.Unlock()
+
+	eventbus.Publish(EvtOCRProgress, &progress)
+}
+
+// GetOCRQueueStat 返回 OCR 队列的状态：等待重新识别的数量、等待刷新到数据库的块数量以及重新识别的进度。
+func GetOCRQueueStat() (reQueueLen, nodeQueueLen int, progress *OCRProgress) {
+	ocrStatusesLock.Lock()
+	reQueueLen = len(ocrReQueue)
+	p := *ocrProgress
+	progress = &p
+	ocrStatusesLock.Unlock()
+
+	NodeOCRQueueLock.Lock()
+	nodeQueueLen = len(NodeOCRQueue)
+	NodeOCRQueueLock.Unlock()
+	return
+}
@@ -17,65 +17,13 @@
 package util
 
 import (
-	"context"
 	"net/http"
 	"net/url"
-	"strings"
-	"time"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/siyuan-note/logging"
 )
 
-func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, maxTokens int, temperature float64, timeout int) (ret string, stop bool, err error) {
-	var reqMsgs []openai.ChatCompletionMessage
-
-	for _, ctxMsg := range contextMsgs {
-		reqMsgs = append(reqMsgs, openai.ChatCompletionMessage{
-			Role:    "user",
-			Content: ctxMsg,
-		})
-	}
-	reqMsgs = append(reqMsgs, openai.ChatCompletionMessage{
-		Role:    "user",
-		Content: msg,
-	})
-
-	req := openai.ChatCompletionRequest{
-		Model:       model,
-		MaxTokens:   maxTokens,
-		Temperature: float32(temperature),
-		Messages:    reqMsgs,
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
-	resp, err := c.CreateChatCompletion(ctx, req)
-	if nil != err {
-		PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
-		logging.LogErrorf("create chat completion failed: %s", err)
-		stop = true
-		return
-	}
-
-	if 1 > len(resp.Choices) {
-		stop = true
-		return
-	}
-
-	buf := &strings.Builder{}
-	choice := resp.Choices[0]
-	buf.WriteString(choice.Message.Content)
-	if "length" == choice.FinishReason {
-		stop = false
-	} else {
-		stop = true
-	}
-
-	ret = buf.String()
-	ret = strings.TrimSpace(ret)
-	return
-}
-
 func NewOpenAIClient(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider string) *openai.Client {
 	config := openai.DefaultConfig(apiKey)
 	if "Azure" == apiProvider {
@@ -83,18 +31,23 @@ func NewOpenAIClient(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, api
 		config.APIVersion = apiVersion
 	}
 
+	config.HTTPClient = NewAIHTTPClient(apiProxy, apiUserAgent)
+	config.BaseURL = apiBaseURL
+	return openai.NewClientWithConfig(config)
+}
+
+// NewAIHTTPClient 创建访问 AI 服务的 HTTP 客户端，超时由调用方通过 context 控制。
+func NewAIHTTPClient(apiProxy, apiUserAgent string) *http.Client {
 	transport := &http.Transport{}
 	if "" != apiProxy {
 		proxyUrl, err := url.Parse(apiProxy)
 		if nil != err {
-			logging.LogErrorf("OpenAI API proxy failed: %v", err)
+			logging.LogErrorf("AI API proxy failed: %v", err)
 		} else {
 			transport.Proxy = http.ProxyURL(proxyUrl)
 		}
 	}
-	config.HTTPClient = &http.Client{Transport: newAddHeaderTransport(transport, apiUserAgent)}
-	config.BaseURL = apiBaseURL
-	return openai.NewClientWithConfig(config)
+	return &http.Client{Transport: newAddHeaderTransport(transport, apiUserAgent)}
 }
 
 type AddHeaderTransport struct {
@@ -267,6 +267,21 @@ func IsDisplayableAsset(p string) bool {
 	return false
 }
 
+// AssetThumbExts 为支持在内核中生成缩略图的资源文件后缀。
+var AssetThumbExts = []string{".jpg", ".jpe", ".jpeg", ".jfif", ".pjp", ".pjpeg", ".png", ".gif", ".webp", ".bmp", ".pdf", ".mov", ".mkv", ".mp4", ".webm"}
+
+func IsThumbnailableAsset(p string) bool {
+	return gulu.Str.Contains(strings.ToLower(filepath.Ext(p)), AssetThumbExts)
+}
+
+// AssetThumbPath 返回资源文件缩略图的访问路径 assets/thumb/*，不支持生成缩略图时返回原路径。
+func AssetThumbPath(p string) string {
+	if !strings.HasPrefix(p, "assets/") || strings.HasPrefix(p, "assets/thumb/") || !IsThumbnailableAsset(p) {
+		return p
+	}
+	return "assets/thumb/" + strings.TrimPrefix(p, "assets/")
+}
+
 func GetAbsPathInWorkspace(relPath string) (string, error) {
 	absPath := filepath.Join(WorkspaceDir, relPath)
 	if WorkspaceDir == absPath {
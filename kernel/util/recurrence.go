@@ -0,0 +1,204 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence 是解析后的重复规则，格式为 RFC 5545 RRULE 的子集，比如 FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR。
+//
+// 支持 FREQ（HOURLY、DAILY、WEEKLY、MONTHLY、YEARLY）、INTERVAL、COUNT、UNTIL 以及仅用于 WEEKLY 的 BYDAY。
+// 按月和按年重复时跳过不存在的日期，比如 31 日在小月不提醒，这与 RRULE 的约定一致。
+type Recurrence struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func ParseRecurrence(rule string) (ret *Recurrence, err error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	ret = &Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		if "" == part {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if 2 != len(kv) {
+			return nil, fmt.Errorf("invalid recurrence rule [%s]", rule)
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			switch val {
+			case "HOURLY", "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				ret.Freq = val
+			default:
+				return nil, fmt.Errorf("unsupported recurrence frequency [%s]", val)
+			}
+		case "INTERVAL":
+			if ret.Interval, err = strconv.Atoi(val); nil != err || 1 > ret.Interval {
+				return nil, fmt.Errorf("invalid recurrence interval [%s]", val)
+			}
+		case "COUNT":
+			if ret.Count, err = strconv.Atoi(val); nil != err || 1 > ret.Count {
+				return nil, fmt.Errorf("invalid recurrence count [%s]", val)
+			}
+		case "UNTIL":
+			if ret.Until, err = parseRecurrenceUntil(val); nil != err {
+				return nil, err
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := recurrenceWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("invalid recurrence weekday [%s]", day)
+				}
+				ret.ByDay = append(ret.ByDay, weekday)
+			}
+			sort.Slice(ret.ByDay, func(i, j int) bool { return ret.ByDay[i] < ret.ByDay[j] })
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part [%s]", key)
+		}
+	}
+
+	if "" == ret.Freq {
+		return nil, fmt.Errorf("recurrence frequency is required [%s]", rule)
+	}
+	if 0 < len(ret.ByDay) && "WEEKLY" != ret.Freq {
+		return nil, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY [%s]", rule)
+	}
+	return
+}
+
+func parseRecurrenceUntil(val string) (ret time.Time, err error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		loc := time.Local
+		if strings.HasSuffix(layout, "Z") {
+			loc = time.UTC
+		}
+		if ret, err = time.ParseInLocation(layout, val, loc); nil == err {
+			if "20060102" == layout {
+				// 只有日期时包含当天
+				ret = ret.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return
+		}
+	}
+	err = fmt.Errorf("invalid recurrence until [%s]", val)
+	return
+}
+
+// Next 返回从 start 开始按规则重复的时间中 after 之后（不含 after）的第一个，没有更多重复时返回零值。
+func (recurrence *Recurrence) Next(start, after time.Time) time.Time {
+	period := 0
+	if 0 == recurrence.Count {
+		// 不限制次数时直接跳到 after 附近，避免从很久以前的 start 逐个计算
+		period = recurrence.estimatePeriod(start, after)
+	}
+
+	index := 0
+	limit := period + 100000
+	for ; period < limit; period++ {
+		for _, t := range recurrence.occurrences(start, period) {
+			if t.Before(start) {
+				continue
+			}
+			index++
+			if 0 < recurrence.Count && index > recurrence.Count {
+				return time.Time{}
+			}
+			if !recurrence.Until.IsZero() && t.After(recurrence.Until) {
+				return time.Time{}
+			}
+			if t.After(after) {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (recurrence *Recurrence) estimatePeriod(start, after time.Time) (ret int) {
+	if !after.After(start) {
+		return 0
+	}
+
+	elapsed := after.Sub(start)
+	switch recurrence.Freq {
+	case "HOURLY":
+		ret = int(elapsed / time.Hour)
+	case "DAILY":
+		ret = int(elapsed / (24 * time.Hour))
+	case "WEEKLY":
+		ret = int(elapsed / (7 * 24 * time.Hour))
+	case "MONTHLY":
+		ret = int(elapsed/(24*time.Hour)) / 31
+	case "YEARLY":
+		ret = int(elapsed/(24*time.Hour)) / 366
+	}
+	// 夏令时等原因可能导致估算偏大，往前多退一个周期
+	ret = ret/recurrence.Interval - 1
+	if 0 > ret {
+		ret = 0
+	}
+	return
+}
+
+// occurrences 返回第 period 个周期内的重复时间，按时间升序。
+func (recurrence *Recurrence) occurrences(start time.Time, period int) (ret []time.Time) {
+	n := period * recurrence.Interval
+	switch recurrence.Freq {
+	case "HOURLY":
+		ret = append(ret, start.Add(time.Duration(n)*time.Hour))
+	case "DAILY":
+		ret = append(ret, start.AddDate(0, 0, n))
+	case "WEEKLY":
+		if 1 > len(recurrence.ByDay) {
+			ret = append(ret, start.AddDate(0, 0, 7*n))
+			return
+		}
+
+		weekStart := start.AddDate(0, 0, 7*n-int(start.Weekday()))
+		for _, weekday := range recurrence.ByDay {
+			ret = append(ret, weekStart.AddDate(0, 0, int(weekday)))
+		}
+	case "MONTHLY":
+		t := time.Date(start.Year(), start.Month()+time.Month(n), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		if t.Day() == start.Day() {
+			ret = append(ret, t)
+		}
+	case "YEARLY":
+		t := time.Date(start.Year()+n, start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		if t.Day() == start.Day() {
+			ret = append(ret, t)
+		}
+	}
+	return
+}
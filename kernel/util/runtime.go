@@ -41,6 +41,9 @@ import (
 // UseSingleLineSave 是否使用单行保存 .sy 和数据库 .json 文件。
 var UseSingleLineSave = true
 
+// SyCompression 是 .sy 文件使用的压缩算法，空字符串表示不压缩。
+var SyCompression = ""
+
 // IsUILoaded 是否已经加载了 UI。
 var IsUILoaded = false
 
@@ -67,7 +70,7 @@ var IsExiting = atomic.Bool{}
 var MobileOSVer string
 
 // DatabaseVer 数据库版本。修改表结构的话需要修改这里。
-const DatabaseVer = "20220501"
+const DatabaseVer = "20261016"
 
 func logBootInfo() {
 	plat := GetOSPlatform()
@@ -406,4 +409,20 @@ const (
 
 	EvtSQLHistoryRebuild      = "sql.history.rebuild"
 	EvtSQLAssetContentRebuild = "sql.assetContent.rebuild"
+	EvtSQLRefsChanged         = "sql.refs.changed"
+
+	EvtAttributeViewSaved = "av.saved"
+
+	EvtOCRProgress = "ocr.progress"
+
+	EvtHistoryCreated  = "history.created"
+	EvtSnapshotCreated = "repo.snapshot.created"
+
+	EvtPluginPermissionRequest = "plugin.permission.request"
+
+	EvtBlockUpdated      = "block.updated"
+	EvtDocCreated        = "doc.created"
+	EvtSyncFinished      = "sync.finished"
+	EvtFlashcardReviewed = "flashcard.reviewed"
+	EvtReminderFired     = "reminder.fired"
 )
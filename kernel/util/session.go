@@ -36,6 +36,18 @@ type SessionData struct {
 type WorkspaceSession struct {
 	AccessAuthCode string
 	Captcha        string
+	SessionID      string // 登录后分配的会话 ID，用于会话管理和吊销
+	TOTPVerified   string // 通过 TOTP 两步验证时的密钥指纹，密钥变更后需要重新验证
+
+	OIDCSubject string // 通过 OIDC 登录的用户标识
+	OIDCName    string // 通过 OIDC 登录的用户名
+	OIDCRole    string // 通过用户组映射得到的角色
+	OIDCExpired int64  // 会话过期时间（毫秒）
+
+	OIDCState    string // 以下字段仅在 OIDC 登录流程中暂存
+	OIDCNonce    string
+	OIDCVerifier string
+	OIDCTo       string
 }
 
 // Save saves the current session of the specified context.
@@ -40,6 +40,8 @@ func BuiltInTemplateFuncs() (ret template.FuncMap) {
 	ret["logf"] = logf
 	ret["parseTime"] = parseTime
 	ret["FormatFloat"] = FormatFloat
+	ret["parseJSON"] = parseJSON
+	ret["jsonGet"] = jsonGet
 	return
 }
 
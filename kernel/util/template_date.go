@@ -0,0 +1,224 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/spf13/cast"
+)
+
+// TemplateDateFuncs 返回模板中可用的日期计算函数。
+//
+// 函数参数中的日期可以是 time.Time、日期字符串或者毫秒时间戳，解析失败时返回错误而不是静默使用当前时间。
+func TemplateDateFuncs() (ret template.FuncMap) {
+	ret = template.FuncMap{}
+	ret["dateAdd"] = dateAdd
+	ret["addDays"] = func(days int, date interface{}) (time.Time, error) { return addDate(date, 0, 0, days) }
+	ret["addWeeks"] = func(weeks int, date interface{}) (time.Time, error) { return addDate(date, 0, 0, weeks*7) }
+	ret["addMonths"] = func(months int, date interface{}) (time.Time, error) { return addDate(date, 0, months, 0) }
+	ret["addYears"] = func(years int, date interface{}) (time.Time, error) { return addDate(date, years, 0, 0) }
+	ret["startOf"] = startOf
+	ret["endOf"] = endOf
+	ret["daysBetween"] = daysBetween
+	ret["formatDate"] = formatDate
+	ret["toTime"] = toTime
+	return
+}
+
+var dateAddPattern = regexp.MustCompile(`([+-]?\d+)\s*(y|mo|w|d|h|m|s)`)
+
+// dateAdd 按照偏移量计算日期，偏移量支持 y（年）、mo（月）、w（周）、d（天）、h（时）、m（分）、s（秒），比如 "1mo-2d"。
+func dateAdd(offset string, date interface{}) (ret time.Time, err error) {
+	if ret, err = toTime(date); nil != err {
+		return
+	}
+
+	offset = strings.ReplaceAll(offset, " ", "")
+	matches := dateAddPattern.FindAllStringSubmatchIndex(offset, -1)
+	if 1 > len(matches) {
+		err = fmt.Errorf("invalid date offset [%s]", offset)
+		return
+	}
+
+	consumed := 0
+	for _, m := range matches {
+		if m[0] != consumed {
+			break
+		}
+		consumed = m[1]
+
+		n, _ := strconv.Atoi(offset[m[2]:m[3]])
+		switch offset[m[4]:m[5]] {
+		case "y":
+			ret = ret.AddDate(n, 0, 0)
+		case "mo":
+			ret = ret.AddDate(0, n, 0)
+		case "w":
+			ret = ret.AddDate(0, 0, n*7)
+		case "d":
+			ret = ret.AddDate(0, 0, n)
+		case "h":
+			ret = ret.Add(time.Duration(n) * time.Hour)
+		case "m":
+			ret = ret.Add(time.Duration(n) * time.Minute)
+		case "s":
+			ret = ret.Add(time.Duration(n) * time.Second)
+		}
+	}
+	if consumed != len(offset) {
+		err = fmt.Errorf("invalid date offset [%s]", offset)
+	}
+	return
+}
+
+func addDate(date interface{}, years, months, days int) (ret time.Time, err error) {
+	if ret, err = toTime(date); nil != err {
+		return
+	}
+	ret = ret.AddDate(years, months, days)
+	return
+}
+
+// startOf 返回日期所在的 day、week（周一开始）、month、quarter 或 year 的开始时间。
+func startOf(unit string, date interface{}) (ret time.Time, err error) {
+	t, err := toTime(date)
+	if nil != err {
+		return
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch unit {
+	case "day":
+		ret = day
+	case "week":
+		ret = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		ret = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case "quarter":
+		ret = time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, t.Location())
+	case "year":
+		ret = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		err = fmt.Errorf("invalid date unit [%s]", unit)
+	}
+	return
+}
+
+// endOf 返回日期所在的 day、week、month、quarter 或 year 的最后一纳秒。
+func endOf(unit string, date interface{}) (ret time.Time, err error) {
+	start, err := startOf(unit, date)
+	if nil != err {
+		return
+	}
+
+	switch unit {
+	case "day":
+		ret = start.AddDate(0, 0, 1)
+	case "week":
+		ret = start.AddDate(0, 0, 7)
+	case "month":
+		ret = start.AddDate(0, 1, 0)
+	case "quarter":
+		ret = start.AddDate(0, 3, 0)
+	case "year":
+		ret = start.AddDate(1, 0, 0)
+	}
+	ret = ret.Add(-time.Nanosecond)
+	return
+}
+
+// daysBetween 返回两个日期之间相差的自然日天数，to 早于 from 时为负数。
+func daysBetween(from, to interface{}) (ret int, err error) {
+	f, err := startOf("day", from)
+	if nil != err {
+		return
+	}
+	t, err := startOf("day", to)
+	if nil != err {
+		return
+	}
+
+	// 按日历日期计算，避免夏令时切换导致的误差
+	ft := time.Date(f.Year(), f.Month(), f.Day(), 0, 0, 0, 0, time.UTC)
+	tt := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	ret = int(tt.Sub(ft).Hours() / 24)
+	return
+}
+
+var momentLayoutReplacer = strings.NewReplacer(
+	"YYYY", "2006", "YY", "06",
+	"MMMM", "January", "MMM", "Jan", "MM", "01", "M", "1",
+	"DD", "02", "D", "2",
+	"dddd", "Monday", "ddd", "Mon",
+	"HH", "15", "hh", "03", "h", "3",
+	"mm", "04", "ss", "05",
+	"A", "PM", "a", "pm",
+	"ZZ", "-0700", "Z", "-07:00",
+)
+
+// formatDate 使用 YYYY-MM-DD HH:mm:ss 风格的格式格式化日期，另外支持 Q（季度）、W（ISO 周）。
+func formatDate(layout string, date interface{}) (ret string, err error) {
+	t, err := toTime(date)
+	if nil != err {
+		return
+	}
+
+	_, week := t.ISOWeek()
+	layout = strings.ReplaceAll(layout, "Q", "\x00Q\x00")
+	layout = strings.ReplaceAll(layout, "WW", "\x00W\x00")
+	parts := strings.Split(layout, "\x00")
+	buf := strings.Builder{}
+	for _, part := range parts {
+		switch part {
+		case "Q":
+			buf.WriteString(strconv.Itoa(int(t.Month()-1)/3 + 1))
+		case "W":
+			buf.WriteString(fmt.Sprintf("%02d", week))
+		default:
+			buf.WriteString(t.Format(momentLayoutReplacer.Replace(part)))
+		}
+	}
+	ret = buf.String()
+	return
+}
+
+func toTime(date interface{}) (ret time.Time, err error) {
+	switch v := date.(type) {
+	case time.Time:
+		ret = v
+	case *time.Time:
+		ret = *v
+	case string:
+		if "" == v || "now" == v {
+			ret = time.Now()
+			return
+		}
+		ret, err = dateparse.ParseIn(v, time.Now().Location())
+	case int, int64, float64:
+		ret = time.UnixMilli(cast.ToInt64(v))
+	default:
+		err = fmt.Errorf("invalid date [%v]", date)
+	}
+	return
+}
@@ -0,0 +1,183 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/88250/gulu"
+)
+
+// templateHTTPMaxRespSize 模板网络请求响应体大小上限。
+const templateHTTPMaxRespSize = 4 * 1024 * 1024
+
+// templateHTTPTransport 是所有模板网络请求共用的连接池，每次渲染模板时只创建轻量的 http.Client，避免连接泄漏。
+var templateHTTPTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	IdleConnTimeout:       90 * time.Second,
+	MaxIdleConns:          16,
+	MaxIdleConnsPerHost:   4,
+}
+
+// TemplateHTTPFuncs 返回模板中可用的网络请求函数。只允许访问 allowDomains 中的域名（包括其子域名）。
+func TemplateHTTPFuncs(allowDomains []string, timeout time.Duration) (ret template.FuncMap) {
+	if 0 >= timeout {
+		timeout = 10 * time.Second
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: templateHTTPTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if 5 <= len(via) {
+				return errors.New("too many redirects")
+			}
+			return checkTemplateHTTPURL(req.URL, allowDomains)
+		},
+	}
+
+	ret = template.FuncMap{}
+	ret["httpGet"] = func(u string) (string, error) {
+		data, err := templateHTTPRequest(client, allowDomains, http.MethodGet, u, nil)
+		if nil != err {
+			return "", err
+		}
+		return string(data), nil
+	}
+	ret["httpGetJSON"] = func(u string) (interface{}, error) {
+		data, err := templateHTTPRequest(client, allowDomains, http.MethodGet, u, nil)
+		if nil != err {
+			return nil, err
+		}
+		return parseJSON(string(data))
+	}
+	ret["httpPostJSON"] = func(u string, body interface{}) (interface{}, error) {
+		reqBody, err := gulu.JSON.MarshalJSON(body)
+		if nil != err {
+			return nil, err
+		}
+		data, err := templateHTTPRequest(client, allowDomains, http.MethodPost, u, reqBody)
+		if nil != err {
+			return nil, err
+		}
+		return parseJSON(string(data))
+	}
+	return
+}
+
+func templateHTTPRequest(client *http.Client, allowDomains []string, method, u string, body []byte) (ret []byte, err error) {
+	parsed, err := url.Parse(u)
+	if nil != err {
+		return
+	}
+	if err = checkTemplateHTTPURL(parsed, allowDomains); nil != err {
+		return
+	}
+
+	req, err := http.NewRequest(method, parsed.String(), bytes.NewReader(body))
+	if nil != err {
+		return
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if nil != body {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if nil != err {
+		return
+	}
+	defer resp.Body.Close()
+
+	ret, err = io.ReadAll(io.LimitReader(resp.Body, templateHTTPMaxRespSize+1))
+	if nil != err {
+		return
+	}
+	if templateHTTPMaxRespSize < len(ret) {
+		return nil, fmt.Errorf("response of [%s] is too large", parsed.Host)
+	}
+	if 200 > resp.StatusCode || 299 < resp.StatusCode {
+		return nil, fmt.Errorf("request [%s] failed with status code [%d]", parsed.Redacted(), resp.StatusCode)
+	}
+	return
+}
+
+func checkTemplateHTTPURL(u *url.URL, allowDomains []string) error {
+	if "http" != u.Scheme && "https" != u.Scheme {
+		return fmt.Errorf("unsupported scheme [%s]", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range allowDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if "" == domain {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain [%s] is not allowed", host)
+}
+
+func parseJSON(str string) (ret interface{}, err error) {
+	err = gulu.JSON.UnmarshalJSON([]byte(str), &ret)
+	return
+}
+
+// jsonGet 按照以 . 分隔的路径获取 JSON 对象中的值，数组元素使用下标访问，比如 data.items.0.name。
+func jsonGet(obj interface{}, path string) interface{} {
+	if str, ok := obj.(string); ok {
+		var err error
+		if obj, err = parseJSON(str); nil != err {
+			return nil
+		}
+	}
+
+	if "" == path {
+		return obj
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := obj.(type) {
+		case map[string]interface{}:
+			obj = v[key]
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if nil != err || 0 > idx || len(v) <= idx {
+				return nil
+			}
+			obj = v[idx]
+		default:
+			return nil
+		}
+	}
+	return obj
+}
@@ -17,6 +17,7 @@
 package util
 
 import (
+	"bytes"
 	"sync"
 	"time"
 
@@ -41,7 +42,7 @@ func BroadcastByType(typ, cmd string, code int, msg string, data interface{}) {
 		event.Code = code
 		event.Msg = msg
 		event.Data = data
-		sess.Write(event.Bytes())
+		writeSession(sess, event.Bytes())
 	}
 }
 
@@ -69,6 +70,10 @@ func AddPushChan(session *melody.Session) {
 	session.Set("id", id)
 	typ := session.Request.URL.Query().Get("type")
 	session.Set("type", typ)
+	if "1" == session.Request.URL.Query().Get("batch") {
+		// 客户端支持批量消息时合并短时间内的推送
+		session.Set("outbox", &wsOutbox{})
+	}
 
 	if appSessions, ok := sessions.Load(appID); !ok {
 		appSess := &sync.Map{}
@@ -97,6 +102,64 @@ func RemovePushChan(session *melody.Session) {
 	}
 }
 
+// wsCoalesceWindow 为合并推送消息的时间窗口，快速输入时产生的大量事务广播会在窗口内合并为一条消息发送。
+const wsCoalesceWindow = 30 * time.Millisecond
+
+// wsOutbox 缓存会话在合并窗口内待发送的消息。
+type wsOutbox struct {
+	lock  sync.Mutex
+	msgs  [][]byte
+	timer *time.Timer
+}
+
+// writeSession 向会话发送消息。客户端支持批量消息时在合并窗口内去重后批量发送，多条消息以 JSON 数组的形式发送。
+func writeSession(session *melody.Session, msg []byte) {
+	val, ok := session.Get("outbox")
+	if !ok {
+		session.Write(msg)
+		return
+	}
+
+	outbox := val.(*wsOutbox)
+	outbox.lock.Lock()
+	for _, m := range outbox.msgs {
+		if bytes.Equal(m, msg) { // 丢弃重复的消息，比如多次重新加载文档的提示
+			outbox.lock.Unlock()
+			return
+		}
+	}
+	outbox.msgs = append(outbox.msgs, msg)
+	if 256 <= len(outbox.msgs) {
+		if nil != outbox.timer {
+			outbox.timer.Stop()
+		}
+		outbox.lock.Unlock()
+		outbox.flush(session)
+		return
+	}
+	if nil == outbox.timer {
+		outbox.timer = time.AfterFunc(wsCoalesceWindow, func() { outbox.flush(session) })
+	}
+	outbox.lock.Unlock()
+}
+
+func (outbox *wsOutbox) flush(session *melody.Session) {
+	outbox.lock.Lock()
+	msgs := outbox.msgs
+	outbox.msgs = nil
+	outbox.timer = nil
+	outbox.lock.Unlock()
+
+	switch len(msgs) {
+	case 0:
+		return
+	case 1:
+		session.Write(msgs[0])
+	default:
+		session.Write(append(append([]byte("["), bytes.Join(msgs, []byte(","))...), ']'))
+	}
+}
+
 func lenOfSyncMap(m *sync.Map) (ret int) {
 	m.Range(func(key, value interface{}) bool {
 		ret++
@@ -105,6 +168,22 @@ func lenOfSyncMap(m *sync.Map) (ret int) {
 	return
 }
 
+// ClosePushChansByAuthSession 关闭属于已吊销登录会话的 WebSocket 连接。
+func ClosePushChansByAuthSession(authSessionID string) {
+	sessions.Range(func(key, value interface{}) bool {
+		appSessions := value.(*sync.Map)
+		appSessions.Range(func(key, value interface{}) bool {
+			session := value.(*melody.Session)
+			if sid, _ := session.Get("authSession"); sid == authSessionID {
+				session.CloseWithMsg([]byte("  unauthenticated"))
+				RemovePushChan(session)
+			}
+			return true
+		})
+		return true
+	})
+}
+
 func ClosePushChan(id string) {
 	sessions.Range(func(key, value interface{}) bool {
 		appSessions := value.(*sync.Map)
@@ -284,7 +363,7 @@ func single(msg []byte, appId, sid string) {
 		appSessions.Range(func(key, value interface{}) bool {
 			session := value.(*melody.Session)
 			if id, _ := session.Get("id"); id == sid {
-				session.Write(msg)
+				writeSession(session, msg)
 			}
 			return true
 		})
@@ -297,7 +376,7 @@ func Broadcast(msg []byte) {
 		appSessions := value.(*sync.Map)
 		appSessions.Range(func(key, value interface{}) bool {
 			session := value.(*melody.Session)
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -312,7 +391,7 @@ func broadcastOtherApps(msg []byte, excludeApp string) {
 			if app, _ := session.Get("app"); app == excludeApp {
 				return true
 			}
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -332,7 +411,7 @@ func broadcastOtherAppMains(msg []byte, excludeApp string) {
 				return true
 			}
 
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -347,7 +426,7 @@ func broadcastApp(msg []byte, app string) {
 			if sessionApp, _ := session.Get("app"); sessionApp != app {
 				return true
 			}
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -362,7 +441,7 @@ func broadcastOthers(msg []byte, excludeSID string) {
 			if id, _ := session.Get("id"); id == excludeSID {
 				return true
 			}
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
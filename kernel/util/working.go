@@ -23,6 +23,7 @@ import (
 	"math/rand"
 	"mime"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -50,6 +51,8 @@ const (
 var (
 	RunInContainer             = false // 是否运行在容器中
 	SiyuanAccessAuthCodeBypass = false // 是否跳过空访问授权码检查
+	SiyuanTOTPEnforce          = ""    // 覆盖配置中的 TOTP 两步验证强制范围：off、remote 或者 all
+	BasePath                   = ""    // 通过反向代理部署在子路径下时的 URL 前缀，例如 /siyuan，为空时部署在根路径
 )
 
 func initEnvVars() {
@@ -58,6 +61,17 @@ func initEnvVars() {
 	if SiyuanAccessAuthCodeBypass, err = strconv.ParseBool(os.Getenv("SIYUAN_ACCESS_AUTH_CODE_BYPASS")); nil != err {
 		SiyuanAccessAuthCodeBypass = false
 	}
+	SiyuanTOTPEnforce = strings.ToLower(strings.TrimSpace(os.Getenv("SIYUAN_TOTP_ENFORCE")))
+	BasePath = normalizeBasePath(os.Getenv("SIYUAN_BASE_PATH"))
+}
+
+// normalizeBasePath 将 URL 前缀规范为以 / 开头且不以 / 结尾的形式，根路径返回空字符串。
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if "" == basePath {
+		return ""
+	}
+	return "/" + path.Clean(basePath)
 }
 
 var (
@@ -81,8 +95,13 @@ func Boot() {
 	ssl := flag.Bool("ssl", false, "for https and wss")
 	lang := flag.String("lang", "", "zh_CN/zh_CHT/en_US/fr_FR/es_ES/ja_JP")
 	mode := flag.String("mode", "prod", "dev/prod")
+	basePath := flag.String("basePath", "", "URL prefix when serving under a subdirectory behind a reverse proxy, e.g. /siyuan")
 	flag.Parse()
 
+	if "" != *basePath {
+		BasePath = normalizeBasePath(*basePath)
+	}
+
 	if "" != *wdPath {
 		WorkingDir = *wdPath
 	}
@@ -188,8 +207,11 @@ func GetBootProgress() int32 {
 
 func SetBooted() {
 	setBootDetails("Finishing boot...")
+	bootStagesLock.Lock()
+	bootElapsed = time.Since(bootStartTime).Milliseconds()
+	bootStagesLock.Unlock()
 	bootProgress.Store(100)
-	logging.LogInfof("kernel booted")
+	logging.LogInfof("kernel booted, elapsed [%dms]", bootElapsed)
 }
 
 var (
@@ -202,13 +224,15 @@ var (
 	DataDir            string        // 数据目录路径
 	RepoDir            string        // 仓库目录路径
 	HistoryDir         string        // 数据历史目录路径
+	QuarantineDir      string        // 未引用资源文件隔离区目录路径
 	TempDir            string        // 临时目录路径
 	LogPath            string        // 配置目录下的日志文件 siyuan.log 路径
 	DBName             = "siyuan.db" // SQLite 数据库文件名
 	DBPath             string        // SQLite 数据库文件路径
 	HistoryDBPath      string        // SQLite 历史数据库文件路径
 	AssetContentDBPath string        // SQLite 资源文件内容数据库文件路径
-	BlockTreePath      string        // 区块树文件路径
+	BlockTreePath      string        // 旧版区块树文件路径，仅用于迁移
+	BlockTreeDBPath    string        // 区块树数据库文件路径
 	AppearancePath     string        // 配置目录下的外观目录 appearance/ 路径
 	ThemesPath         string        // 配置目录下的外观目录下的 themes/ 路径
 	IconsPath          string        // 配置目录下的外观目录下的 icons/ 路径
@@ -272,6 +296,7 @@ func initWorkspaceDir(workspaceArg string) {
 	DataDir = filepath.Join(WorkspaceDir, "data")
 	RepoDir = filepath.Join(WorkspaceDir, "repo")
 	HistoryDir = filepath.Join(WorkspaceDir, "history")
+	QuarantineDir = filepath.Join(WorkspaceDir, "quarantine")
 	TempDir = filepath.Join(WorkspaceDir, "temp")
 	osTmpDir := filepath.Join(TempDir, "os")
 	os.RemoveAll(osTmpDir)
@@ -287,6 +312,7 @@ func initWorkspaceDir(workspaceArg string) {
 	HistoryDBPath = filepath.Join(TempDir, "history.db")
 	AssetContentDBPath = filepath.Join(TempDir, "asset_content.db")
 	BlockTreePath = filepath.Join(TempDir, "blocktree")
+	BlockTreeDBPath = filepath.Join(TempDir, "blocktree.db")
 	SnippetsPath = filepath.Join(DataDir, "snippets")
 }
 